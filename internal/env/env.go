@@ -0,0 +1,122 @@
+// Package env holds the variables of a shell's environment, which the
+// shell expands, changes with export and unset, and passes on to the
+// commands it runs.
+package env
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Env stores a shell's environment variables. The builtins in a pipeline
+// run at once and share it, so the variables are kept under a lock.
+type Env struct {
+	mu   sync.Mutex
+	vars map[string]string
+
+	// The environment as ToSlice returns it, built on first use after each
+	// change, so running commands doesn't format every variable each time
+	slice []string
+}
+
+// New creates an empty environment
+func New() *Env {
+	return &Env{vars: make(map[string]string)}
+}
+
+// FromList creates an environment from "KEY=VALUE" entries, as os.Environ
+// returns them. Entries without an = are left out.
+func FromList(list []string) *Env {
+	e := New()
+	for _, entry := range list {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			e.vars[key] = value
+		}
+	}
+	return e
+}
+
+// Set sets an environment variable
+func (e *Env) Set(key, value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if old, ok := e.vars[key]; ok && old == value {
+		return
+	}
+	e.vars[key] = value
+	e.slice = nil
+}
+
+// Get retrieves an environment variable
+func (e *Env) Get(key string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.vars[key]
+}
+
+// Lookup retrieves an environment variable, reporting whether it is set
+func (e *Env) Lookup(key string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	value, ok := e.vars[key]
+	return value, ok
+}
+
+// Unset removes an environment variable
+func (e *Env) Unset(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.vars[key]; !ok {
+		return
+	}
+	delete(e.vars, key)
+	e.slice = nil
+}
+
+// Clone returns a copy of the environment
+func (e *Env) Clone() *Env {
+	return &Env{vars: e.Vars()}
+}
+
+// Keys returns the names of all variables in sorted order
+func (e *Env) Keys() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.keys()
+}
+
+// keys returns the names of all variables in sorted order, with the lock
+// held
+func (e *Env) keys() []string {
+	keys := make([]string, 0, len(e.vars))
+	for k := range e.vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Vars returns a copy of the variables
+func (e *Env) Vars() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.vars)
+}
+
+// ToSlice converts the environment map to a slice of "KEY=VALUE" strings,
+// sorted by name. The slice is shared until the next change, so callers
+// must not modify it; appending to it makes a copy.
+func (e *Env) ToSlice() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.slice == nil {
+		e.slice = make([]string, 0, len(e.vars))
+		for _, k := range e.keys() {
+			e.slice = append(e.slice, k+"="+e.vars[k])
+		}
+	}
+	return slices.Clip(e.slice)
+}
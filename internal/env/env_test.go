@@ -0,0 +1,103 @@
+package env
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	// Test ToSlice conversion
+	t.Run("ToSlice", func(t *testing.T) {
+		// Create a clean environment for testing
+		cleanEnv := New()
+		cleanEnv.Set("TEST_VAR1", "value1")
+		cleanEnv.Set("TEST_VAR2", "value2")
+
+		slice := cleanEnv.ToSlice()
+		expected := map[string]string{
+			"TEST_VAR1": "value1",
+			"TEST_VAR2": "value2",
+		}
+
+		// Check that our test variables are in the slice
+		foundVars := 0
+		for _, entry := range slice {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				t.Errorf("Invalid entry in ToSlice(): %v", entry)
+				continue
+			}
+
+			if val, ok := expected[parts[0]]; ok {
+				if val != parts[1] {
+					t.Errorf("ToSlice() entry %v = %v, want %v", parts[0], parts[1], val)
+				}
+				foundVars++
+			}
+		}
+
+		if foundVars != len(expected) {
+			t.Errorf("Not all expected variables found in ToSlice(). Found %d, want %d", foundVars, len(expected))
+		}
+	})
+
+	// Test that the slice is kept until the environment changes
+	t.Run("ToSliceCached", func(t *testing.T) {
+		cleanEnv := New()
+		cleanEnv.Set("B", "2")
+		cleanEnv.Set("A", "1")
+		first := cleanEnv.ToSlice()
+		if got := strings.Join(first, " "); got != "A=1 B=2" {
+			t.Errorf("ToSlice() = %q, want sorted variables", got)
+		}
+		cleanEnv.Set("A", "1")
+		if second := cleanEnv.ToSlice(); &second[0] != &first[0] {
+			t.Error("ToSlice() was rebuilt without a change")
+		}
+		// Appending to the shared slice copies it
+		a := append(first, "X=1")
+		b := append(cleanEnv.ToSlice(), "Y=2")
+		if a[2] != "X=1" || b[2] != "Y=2" {
+			t.Errorf("appends to ToSlice() share storage: %q and %q", a, b)
+		}
+		cleanEnv.Set("C", "3")
+		cleanEnv.Unset("B")
+		if got := strings.Join(cleanEnv.ToSlice(), " "); got != "A=1 C=3" {
+			t.Errorf("ToSlice() after changes = %q, want %q", got, "A=1 C=3")
+		}
+	})
+
+	// Pipeline stages use the environment at once; run with -race
+	t.Run("Concurrent", func(t *testing.T) {
+		cleanEnv := New()
+		done := make(chan bool)
+		go func() {
+			for i := 0; i < 100; i++ {
+				cleanEnv.Set("A", strconv.Itoa(i))
+			}
+			close(done)
+		}()
+		for i := 0; i < 100; i++ {
+			cleanEnv.Keys()
+			cleanEnv.ToSlice()
+			cleanEnv.Vars()
+		}
+		<-done
+		if got := cleanEnv.Get("A"); got != "99" {
+			t.Errorf("A = %q, want 99", got)
+		}
+	})
+
+	t.Run("FromList", func(t *testing.T) {
+		e := FromList([]string{"A=1", "B=x=y", "C=", "broken"})
+		if got := strings.Join(e.ToSlice(), " "); got != "A=1 B=x=y C=" {
+			t.Errorf("FromList() = %q, want %q", got, "A=1 B=x=y C=")
+		}
+		clone := e.Clone()
+		clone.Set("A", "2")
+		if e.Get("A") != "1" {
+			t.Error("changing a clone changed the environment")
+		}
+	})
+}
@@ -0,0 +1,452 @@
+// Package lineedit reads command lines from a terminal, with emacs-style
+// editing keys, history browsing and incremental search. The line can be
+// colored and decorated as it is typed, and keys can be taken over, which is
+// how the shell adds completion, vi mode and its own bindings.
+package lineedit
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupt is returned by Readline when Ctrl-C is pressed
+var ErrInterrupt = errors.New("interrupted")
+
+// Display is how a line is shown on the terminal
+type Display struct {
+	Line  string   // the line itself, usually colored; its visible text must be the line's
+	Hint  string   // shown after the line while the cursor is at its end, such as a suggestion
+	Below []string // rows shown under the line, such as a completion menu
+}
+
+// Editor reads lines from a terminal. Its methods other than Write and
+// Refresh are meant to be called from the goroutine reading lines, or from
+// the Handler and Painter while a line is read.
+type Editor struct {
+	// Handler, if set, sees each key before the editor does. It returns the
+	// key for the editor to handle, which may be a different one, or false
+	// if it has dealt with the key itself.
+	Handler func(key Key) (Key, bool)
+
+	// Painter, if set, decides how the line is displayed
+	Painter func(line []rune, pos int) Display
+
+	in    *input
+	out   io.Writer
+	fd    int         // the terminal's descriptor, or -1 if input isn't a terminal
+	saved *term.State // the terminal's mode before Readline made it raw
+
+	mu      sync.Mutex // held while the line is drawn
+	screen  screen
+	reading bool // a line is being read and is on the screen
+
+	prompt string
+	line   []rune
+	pos    int
+
+	history   []string
+	index     historyIndex // finds the entries a search could match
+	histIndex int          // the entry being shown, len(history) for the new line
+	histSaved []rune       // the new line, while history is browsed
+
+	search *search // the incremental search under way, if any
+}
+
+// New creates an editor reading keys from in and drawing on out. Editing
+// needs in to be a terminal; otherwise lines are read as they come.
+func New(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{in: newInput(in), out: out, fd: -1}
+	e.screen.out = out
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		e.fd = int(f.Fd())
+	}
+	return e
+}
+
+// SetPrompt sets the prompt shown before the line. Only its last line is
+// redrawn as the line is edited; any before it are printed once.
+func (e *Editor) SetPrompt(prompt string) {
+	e.prompt = prompt
+}
+
+// Buffer returns the line being edited and the cursor position in it
+func (e *Editor) Buffer() ([]rune, int) {
+	return append([]rune(nil), e.line...), e.pos
+}
+
+// SetBuffer replaces the line being edited and moves the cursor to pos
+func (e *Editor) SetBuffer(line []rune, pos int) {
+	e.line = append([]rune(nil), line...)
+	e.pos = max(min(pos, len(e.line)), 0)
+}
+
+// Bell rings the terminal bell
+func (e *Editor) Bell() {
+	io.WriteString(e.out, "\a")
+}
+
+// Width returns the terminal's width in columns, or 80 if it has none
+func (e *Editor) Width() int {
+	if e.fd >= 0 {
+		if width, _, err := term.GetSize(e.fd); err == nil && width > 1 {
+			return width
+		}
+	}
+	return 80
+}
+
+// AddHistory adds line to the history browsed with Up and Down and searched
+// with Ctrl-R. Blank lines and repeats of the last entry are left out.
+func (e *Editor) AddHistory(line string) {
+	n := len(e.history)
+	if strings.TrimSpace(line) != "" && (n == 0 || e.history[n-1] != line) {
+		e.index.add(len(e.history), line)
+		e.history = append(e.history, line)
+	}
+	e.histIndex, e.histSaved = len(e.history), nil
+}
+
+// SetHistory replaces the history with lines, oldest first, for when
+// entries have been removed from it
+func (e *Editor) SetHistory(lines []string) {
+	e.history, e.index = nil, historyIndex{}
+	for _, line := range lines {
+		e.AddHistory(line)
+	}
+}
+
+// Write prints p above the line being read, which is drawn again below it.
+// Outside Readline it writes straight through. It can be called from any
+// goroutine.
+func (e *Editor) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.reading {
+		return e.out.Write(p)
+	}
+	e.screen.clear()
+	text := strings.ReplaceAll(string(p), "\n", "\r\n")
+	if !strings.HasSuffix(text, "\n") {
+		text += "\r\n"
+	}
+	io.WriteString(e.out, text)
+	e.writeHead()
+	e.draw(false)
+	return len(p), nil
+}
+
+// Refresh draws the line again, for when something it shows has changed
+// outside the Handler. It can be called from any goroutine.
+func (e *Editor) Refresh() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.reading {
+		e.draw(false)
+	}
+}
+
+// Readline reads a line, returning it without the newline. It returns
+// ErrInterrupt if Ctrl-C is pressed and io.EOF if Ctrl-D is pressed on an
+// empty line or input ends.
+func (e *Editor) Readline() (string, error) {
+	return e.ReadlineWithDefault("")
+}
+
+// ReadlineWithDefault reads a line that starts out as text
+func (e *Editor) ReadlineWithDefault(text string) (string, error) {
+	if e.fd < 0 {
+		return e.readPlain()
+	}
+	state, err := term.MakeRaw(e.fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	e.saved = state
+	defer term.Restore(e.fd, state)
+
+	e.mu.Lock()
+	e.SetBuffer([]rune(text), len(text))
+	e.histIndex, e.histSaved = len(e.history), nil
+	e.search = nil
+	e.reading = true
+	e.writeHead()
+	e.draw(false)
+	e.mu.Unlock()
+
+	for {
+		key, err := decodeKey(e.in.next)
+		e.mu.Lock()
+		accepted := false
+		if err == nil && key != 0 {
+			accepted, err = e.HandleKey(key)
+		}
+		switch {
+		case accepted:
+			e.draw(true)
+			e.screen.finish()
+		case err == ErrInterrupt:
+			e.draw(true)
+			io.WriteString(e.out, "^C")
+			e.screen.finish()
+		case err != nil:
+			e.draw(true)
+			e.screen.finish()
+		default:
+			e.draw(false)
+			e.mu.Unlock()
+			continue
+		}
+		e.reading = false
+		e.mu.Unlock()
+		return string(e.line), err
+	}
+}
+
+// Suspend runs fn with the line taken off the screen and the terminal back
+// in the mode it was in before Readline, for a program such as a fuzzy
+// finder to take it over. The line is drawn again afterwards. It is meant
+// to be called from the Handler; outside Readline it just runs fn.
+func (e *Editor) Suspend(fn func()) {
+	if !e.reading {
+		fn()
+		return
+	}
+	e.screen.clear()
+	term.Restore(e.fd, e.saved)
+	fn()
+	term.MakeRaw(e.fd)
+	e.writeHead()
+}
+
+// ReadKey waits for a key, for a picker the shell shows while no line is
+// being read. The caller puts the terminal in raw mode. Keys are read
+// through the editor so that none typed after the picker closes are lost to
+// it.
+func (e *Editor) ReadKey() (Key, error) {
+	return decodeKey(e.in.next)
+}
+
+// StartSearch starts an incremental search back through the history, as
+// Ctrl-R does
+func (e *Editor) StartSearch() {
+	e.search = newSearch(e)
+}
+
+// readPlain reads a line from input that isn't a terminal
+func (e *Editor) readPlain() (string, error) {
+	io.WriteString(e.out, e.prompt)
+	var line []rune
+	for {
+		r, err := e.in.next(true)
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
+		}
+		if r == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		line = append(line, r)
+	}
+}
+
+// writeHead prints the lines of the prompt before its last
+func (e *Editor) writeHead() {
+	if i := strings.LastIndex(e.prompt, "\n"); i >= 0 {
+		io.WriteString(e.out, strings.ReplaceAll(e.prompt[:i+1], "\n", "\r\n"))
+	}
+}
+
+// draw shows the prompt and line, with the Painter's decorations unless the
+// line is final
+func (e *Editor) draw(final bool) {
+	prompt := e.prompt[strings.LastIndex(e.prompt, "\n")+1:]
+	line, pos := e.line, e.pos
+	d := Display{Line: string(line)}
+	switch {
+	case e.search != nil:
+		prompt = e.search.prompt()
+		d = e.search.display(line, pos)
+	case e.Painter != nil:
+		d = e.Painter(line, pos)
+	}
+
+	flow := parseCells(prompt, "")
+	cursor := len(flow) + len(parseCells(string(line[:pos]), ""))
+	flow = append(flow, parseCells(d.Line, "")...)
+	var hint []cell
+	var below [][]cell
+	if !final {
+		if pos == len(line) {
+			hint = parseCells(d.Hint, "")
+		}
+		for _, row := range d.Below {
+			below = append(below, parseCells(row, ""))
+		}
+	}
+	width := e.Width()
+	e.screen.draw(layout(width, flow, cursor, hint, below), width)
+}
+
+// HandleKey acts on a key as if it had been typed. It reports whether the
+// key accepted the line, and returns ErrInterrupt or io.EOF if it ended
+// reading that way.
+func (e *Editor) HandleKey(key Key) (bool, error) {
+	if e.search != nil {
+		var done bool
+		if key, done = e.search.handle(e, key); done {
+			return false, nil
+		}
+	}
+	if e.Handler != nil {
+		var ok bool
+		if key, ok = e.Handler(key); !ok {
+			return false, nil
+		}
+	}
+
+	line, pos := e.line, e.pos
+	switch key {
+	case Enter, CtrlJ:
+		return true, nil
+	case CtrlC:
+		return false, ErrInterrupt
+	case CtrlD:
+		if len(line) == 0 {
+			return false, io.EOF
+		}
+		e.delete(pos, pos+1)
+	case CtrlA, KeyHome:
+		e.pos = 0
+	case CtrlE, KeyEnd:
+		e.pos = len(line)
+	case CtrlB, KeyLeft:
+		e.pos = max(pos-1, 0)
+	case CtrlF, KeyRight:
+		e.pos = min(pos+1, len(line))
+	case Meta | 'b', Meta | KeyLeft:
+		e.pos = BackwardWord(line, pos)
+	case Meta | 'f', Meta | KeyRight:
+		e.pos = ForwardWord(line, pos)
+	case Backspace, CtrlH:
+		e.delete(pos-1, pos)
+	case KeyDelete:
+		e.delete(pos, pos+1)
+	case CtrlK:
+		e.delete(pos, len(line))
+	case CtrlU:
+		e.delete(0, pos)
+	case CtrlW:
+		start := pos
+		for start > 0 && unicode.IsSpace(line[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(line[start-1]) {
+			start--
+		}
+		e.delete(start, pos)
+	case Meta | 'd':
+		e.delete(pos, ForwardWord(line, pos))
+	case Meta | Backspace, Meta | CtrlH:
+		e.delete(BackwardWord(line, pos), pos)
+	case CtrlT:
+		if pos > 0 && len(line) > 1 {
+			// At the end of the line the last two characters are swapped
+			pos = min(pos, len(line)-1)
+			line[pos-1], line[pos] = line[pos], line[pos-1]
+			e.pos = pos + 1
+		}
+	case CtrlP, KeyUp:
+		e.browse(-1)
+	case CtrlN, KeyDown:
+		e.browse(1)
+	case CtrlL:
+		io.WriteString(e.out, "\x1b[H\x1b[2J")
+		e.screen.reset()
+		e.writeHead()
+	case CtrlR:
+		e.StartSearch()
+	default:
+		if key < ' ' || key >= KeyUp {
+			// Keys without a meaning of their own do nothing
+			break
+		}
+		e.line = append(line[:pos:pos], append([]rune{rune(key)}, line[pos:]...)...)
+		e.pos = pos + 1
+	}
+	return false, nil
+}
+
+// delete removes line[from:to], as far as it is within the line, and
+// leaves the cursor where the text was
+func (e *Editor) delete(from, to int) {
+	from, to = max(from, 0), min(to, len(e.line))
+	if from >= to {
+		return
+	}
+	e.line = append(e.line[:from:from], e.line[to:]...)
+	e.pos = from
+}
+
+// browse steps through the history, to an older entry for a step of -1
+// and a newer one for 1, and past the newest back to the new line. Once
+// something is typed on the new line, only the entries holding it are
+// stepped through, as with zsh's history-substring-search, skipping those
+// the same as the entry shown.
+func (e *Editor) browse(step int) {
+	if e.histIndex == len(e.history) {
+		e.histSaved = e.line
+	}
+	query := string(e.histSaved)
+	for index := e.histIndex + step; index >= 0 && index <= len(e.history); index += step {
+		if index == len(e.history) {
+			e.histIndex = index
+			e.SetBuffer(e.histSaved, len(e.histSaved))
+			return
+		}
+		entry := e.history[index]
+		if query != "" && (!strings.Contains(entry, query) || entry == string(e.line)) {
+			continue
+		}
+		e.histIndex = index
+		line := []rune(entry)
+		e.SetBuffer(line, len(line))
+		return
+	}
+	e.Bell()
+}
+
+// isWordRune reports whether r is part of a word for the word movements,
+// which take anything but letters and digits as a separator
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// ForwardWord returns the end of the word at or after pos
+func ForwardWord(line []rune, pos int) int {
+	for pos < len(line) && !isWordRune(line[pos]) {
+		pos++
+	}
+	for pos < len(line) && isWordRune(line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// BackwardWord returns the start of the word before pos
+func BackwardWord(line []rune, pos int) int {
+	for pos > 0 && !isWordRune(line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(line[pos-1]) {
+		pos--
+	}
+	return pos
+}
@@ -0,0 +1,152 @@
+package lineedit
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// typeKeys handles the keys in text as if they had been typed, returning
+// the line and cursor after them
+func typeKeys(e *Editor, text string) (string, int) {
+	for _, key := range ParseKeys(text) {
+		e.HandleKey(key)
+	}
+	line, pos := e.Buffer()
+	return string(line), pos
+}
+
+func newTestEditor() *Editor {
+	return New(strings.NewReader(""), io.Discard)
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		want    string
+		wantPos int
+	}{
+		{"insert", "echo hi", "echo hi", 7},
+		{"move and insert", "ech\x01x\x05o", "xecho", 5},
+		{"arrows", "ac\x1b[Db\x1b[C!", "abc!", 4},
+		{"backspace and delete", "abcd\x7f\x1b[D\x1b[D\x1b[3~", "ac", 1},
+		{"words", "one two three\x1bb\x1bbX\x1bf!", "one Xtwo! three", 9},
+		{"kill to end and start", "one two\x1bb\x0b\x01\x1b[C\x15", "ne ", 0},
+		{"delete words", "one two three\x17\x1b\x7f", "one ", 4},
+		{"kill word forward", "one two\x01\x1bd", " two", 0},
+		{"transpose", "ab\x14", "ba", 2},
+		{"ctrl-d deletes", "ab\x02\x04", "a", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, pos := typeKeys(newTestEditor(), tt.keys)
+			if line != tt.want || pos != tt.wantPos {
+				t.Errorf("line = %q at %d, want %q at %d", line, pos, tt.want, tt.wantPos)
+			}
+		})
+	}
+}
+
+func TestHandleKeyResults(t *testing.T) {
+	e := newTestEditor()
+	if _, err := e.HandleKey(CtrlD); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line returned %v, want EOF", err)
+	}
+	if _, err := e.HandleKey(CtrlC); err != ErrInterrupt {
+		t.Errorf("Ctrl-C returned %v, want ErrInterrupt", err)
+	}
+	if accepted, err := e.HandleKey(Enter); !accepted || err != nil {
+		t.Errorf("Enter returned %v, %v", accepted, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	e := newTestEditor()
+	e.Handler = func(key Key) (Key, bool) {
+		switch key {
+		case 'x':
+			return 0, false
+		case 'y':
+			return 'z', true
+		}
+		return key, true
+	}
+	if line, _ := typeKeys(e, "axyb"); line != "azb" {
+		t.Errorf("line = %q, want the handler's keys", line)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := newTestEditor()
+	for _, line := range []string{"one", "two", "two", " ", "three"} {
+		e.AddHistory(line)
+	}
+	if len(e.history) != 3 {
+		t.Fatalf("history = %q, want repeats and blanks left out", e.history)
+	}
+
+	steps := []struct {
+		key  Key
+		want string
+	}{
+		{KeyUp, "three"}, {KeyUp, "two"}, {CtrlP, "one"}, {KeyUp, "one"},
+		{KeyDown, "two"}, {CtrlN, "three"}, {KeyDown, ""}, {KeyDown, ""},
+	}
+	for i, step := range steps {
+		e.HandleKey(step.key)
+		if line, pos := e.Buffer(); string(line) != step.want || pos != len(line) {
+			t.Fatalf("step %d: line = %q at %d, want %q at its end", i, string(line), pos, step.want)
+		}
+	}
+
+	e.SetHistory([]string{"alpha", "beta"})
+	if line, _ := typeKeys(e, "\x1b[A\x1b[A"); line != "alpha" {
+		t.Errorf("after SetHistory, Up Up = %q, want alpha", line)
+	}
+	e.SetBuffer(nil, 0)
+	if line, _ := typeKeys(e, "\x12one"); line != "" {
+		t.Errorf("search found %q in a replaced history", line)
+	}
+}
+
+func TestHistorySubstringSearch(t *testing.T) {
+	e := newTestEditor()
+	for _, line := range []string{"git status", "make", "git push", "ls", "git push", "cat .gitignore"} {
+		e.AddHistory(line)
+	}
+
+	// Only the entries holding what was typed are stepped through, and a
+	// repeat of the entry shown is skipped
+	e.SetBuffer([]rune("git"), 3)
+	for i, step := range []struct {
+		key  Key
+		want string
+	}{
+		{KeyUp, "cat .gitignore"}, {KeyUp, "git push"}, {KeyUp, "git status"}, {KeyUp, "git status"},
+		{KeyDown, "git push"}, {KeyDown, "cat .gitignore"}, {KeyDown, "git"},
+	} {
+		e.HandleKey(step.key)
+		if line, pos := e.Buffer(); string(line) != step.want || pos != len(line) {
+			t.Fatalf("step %d: line = %q at %d, want %q at its end", i, string(line), pos, step.want)
+		}
+	}
+
+	e.SetBuffer([]rune("nothing"), 7)
+	e.HandleKey(KeyUp)
+	if line, _ := e.Buffer(); string(line) != "nothing" {
+		t.Errorf("Up without a match = %q, want the line kept", string(line))
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	e := New(strings.NewReader("j\x1b[A\r"), io.Discard)
+	for _, want := range []Key{'j', KeyUp, Enter} {
+		if got, err := e.ReadKey(); got != want || err != nil {
+			t.Errorf("ReadKey() = %v, %v, want %v", got, err, want)
+		}
+	}
+	if _, err := e.ReadKey(); err != io.EOF {
+		t.Errorf("ReadKey() at the end = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,61 @@
+package lineedit
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// escTimeout is how long to wait for the rest of an escape sequence before
+// taking Esc as a key of its own
+const escTimeout = 30 * time.Millisecond
+
+// input reads runes from the terminal in the background, one at a time and
+// only when asked for, so that nothing is read while a command the shell
+// runs has the terminal
+type input struct {
+	want    chan struct{}
+	runes   chan inputRune
+	pending bool // a rune has been asked for and not yet taken
+}
+
+// inputRune is a rune read from the terminal, or the error that ended input
+type inputRune struct {
+	r   rune
+	err error
+}
+
+// newInput starts reading runes from r as they are asked for
+func newInput(r io.Reader) *input {
+	in := &input{want: make(chan struct{}), runes: make(chan inputRune)}
+	reader := bufio.NewReader(r)
+	go func() {
+		for range in.want {
+			r, _, err := reader.ReadRune()
+			in.runes <- inputRune{r, err}
+		}
+	}()
+	return in
+}
+
+// next returns the next rune. Unless wait is set it gives up after
+// escTimeout, returning errNoInput; the rune is then returned by a later
+// call.
+func (in *input) next(wait bool) (rune, error) {
+	if !in.pending {
+		in.want <- struct{}{}
+		in.pending = true
+	}
+	if wait {
+		got := <-in.runes
+		in.pending = false
+		return got.r, got.err
+	}
+	select {
+	case got := <-in.runes:
+		in.pending = false
+		return got.r, got.err
+	case <-time.After(escTimeout):
+		return 0, errNoInput
+	}
+}
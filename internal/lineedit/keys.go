@@ -0,0 +1,174 @@
+package lineedit
+
+import (
+	"errors"
+	"unicode"
+)
+
+// Key is a key press: a character, a control character such as CtrlA, or
+// one of the special keys below, optionally combined with Meta
+type Key rune
+
+// Control characters with their own meaning to the editor
+const (
+	CtrlA Key = iota + 1
+	CtrlB
+	CtrlC
+	CtrlD
+	CtrlE
+	CtrlF
+	CtrlG
+	CtrlH
+	CtrlI
+	CtrlJ
+	CtrlK
+	CtrlL
+	CtrlM
+	CtrlN
+	CtrlO
+	CtrlP
+	CtrlQ
+	CtrlR
+	CtrlS
+	CtrlT
+	CtrlU
+	CtrlV
+	CtrlW
+	CtrlX
+	CtrlY
+	CtrlZ
+	Esc Key = 27
+
+	Tab       = CtrlI
+	Enter     = CtrlM
+	Backspace = 127
+)
+
+// Special keys, numbered above every Unicode code point
+const (
+	KeyUp Key = unicode.MaxRune + 1 + iota
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+)
+
+// Meta marks a key typed with Meta (Alt), as in Meta|'b'. Terminals send
+// these as Esc followed by the key.
+const Meta Key = 1 << 22
+
+// errNoInput is returned by a key source that has nothing more to read
+// right away
+var errNoInput = errors.New("no input pending")
+
+// decodeKey reads one key, taking runes from next. next waits for input when
+// wait is set; otherwise it returns errNoInput unless a rune arrives almost
+// at once. That is how a lone Esc is told from the start of an escape
+// sequence, whose runes arrive together.
+func decodeKey(next func(wait bool) (rune, error)) (Key, error) {
+	r, err := next(true)
+	if err != nil {
+		return 0, err
+	}
+	if r != rune(Esc) {
+		return Key(r), nil
+	}
+	r, err = next(false)
+	switch {
+	case err == errNoInput:
+		return Esc, nil
+	case err != nil:
+		return 0, err
+	case r == '[':
+		return decodeSequence(next, true)
+	case r == 'O':
+		return decodeSequence(next, false)
+	}
+	return Meta | Key(r), nil
+}
+
+// decodeSequence reads the rest of a CSI (Esc [) or SS3 (Esc O) sequence.
+// Sequences for keys the editor has no use for decode to 0.
+func decodeSequence(next func(wait bool) (rune, error), csi bool) (Key, error) {
+	var params []rune
+	for {
+		r, err := next(false)
+		if err == errNoInput {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if csi && r >= 0x20 && r <= 0x3f {
+			params = append(params, r)
+			continue
+		}
+		return sequenceKey(string(params), r), nil
+	}
+}
+
+// sequenceKey returns the key for an escape sequence with the given
+// parameters and final character. A modifier parameter, as in 1;3C for
+// Alt-Right, adds Meta for Alt and Ctrl alike, which both move by words.
+func sequenceKey(params string, final rune) Key {
+	var mod Key
+	if i := len(params) - 2; i >= 0 && params[i] == ';' && params[i+1] != '1' {
+		mod = Meta
+		params = params[:i]
+	}
+	switch final {
+	case 'A':
+		return mod | KeyUp
+	case 'B':
+		return mod | KeyDown
+	case 'C':
+		return mod | KeyRight
+	case 'D':
+		return mod | KeyLeft
+	case 'H':
+		return mod | KeyHome
+	case 'F':
+		return mod | KeyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return mod | KeyHome
+		case "2":
+			return mod | KeyInsert
+		case "3":
+			return mod | KeyDelete
+		case "4", "8":
+			return mod | KeyEnd
+		case "5":
+			return mod | KeyPageUp
+		case "6":
+			return mod | KeyPageDown
+		}
+	}
+	return 0
+}
+
+// ParseKeys decodes the keys in s as if it had been typed all at once, for
+// tests and for replaying input
+func ParseKeys(s string) []Key {
+	runes := []rune(s)
+	next := func(bool) (rune, error) {
+		if len(runes) == 0 {
+			return 0, errNoInput
+		}
+		r := runes[0]
+		runes = runes[1:]
+		return r, nil
+	}
+	var keys []Key
+	for len(runes) > 0 {
+		if key, _ := decodeKey(next); key != 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
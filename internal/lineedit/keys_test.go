@@ -0,0 +1,31 @@
+package lineedit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Key
+	}{
+		{"ab", []Key{'a', 'b'}},
+		{"\x01\x7f\r", []Key{CtrlA, Backspace, Enter}},
+		{"\x1b", []Key{Esc}},
+		{"\x1bb", []Key{Meta | 'b'}},
+		{"\x1b\x7f", []Key{Meta | Backspace}},
+		{"\x1b[A\x1b[B\x1b[C\x1b[D", []Key{KeyUp, KeyDown, KeyRight, KeyLeft}},
+		{"\x1bOH\x1bOF", []Key{KeyHome, KeyEnd}},
+		{"\x1b[1~\x1b[3~\x1b[4~\x1b[5~", []Key{KeyHome, KeyDelete, KeyEnd, KeyPageUp}},
+		{"\x1b[1;5C\x1b[1;3D", []Key{Meta | KeyRight, Meta | KeyLeft}},
+		{"\x1b[1;1A", []Key{KeyUp}},
+		{"\x1b[200~x", []Key{'x'}},
+		{"é世", []Key{'é', '世'}},
+	}
+	for _, tt := range tests {
+		if got := ParseKeys(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKeys(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
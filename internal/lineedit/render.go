@@ -0,0 +1,224 @@
+package lineedit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// cell is one character on the screen and the style it is drawn in
+type cell struct {
+	text  string // the character, with any combining marks that follow it
+	width int    // columns it takes
+	style string // SGR sequences in effect, "" for the default style
+}
+
+// parseCells splits s into cells, following the SGR escape sequences that
+// color it. Operating system commands, such as the marks terminals take the
+// prompt's end from, are kept as cells of no width; other escape sequences
+// are dropped. Control characters are shown as ^X. style is the style in
+// effect where s starts.
+func parseCells(s, style string) []cell {
+	var cells []cell
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == rune(Esc) {
+			if i+1 < len(runes) && runes[i+1] == ']' {
+				// Up to BEL or ESC \
+				j := i + 2
+				for j < len(runes) && runes[j] != '\a' && !(runes[j] == rune(Esc) && j+1 < len(runes) && runes[j+1] == '\\') {
+					j++
+				}
+				if j < len(runes) && runes[j] == rune(Esc) {
+					j++
+				}
+				if j < len(runes) {
+					cells = append(cells, cell{text: string(runes[i : j+1]), style: style})
+				}
+				i = j
+				continue
+			}
+			if i+1 < len(runes) && runes[i+1] == '[' {
+				j := i + 2
+				for j < len(runes) && (runes[j] < 0x40 || runes[j] > 0x7e) {
+					j++
+				}
+				if j < len(runes) && runes[j] == 'm' {
+					if seq := string(runes[i : j+1]); seq == "\x1b[m" || seq == "\x1b[0m" {
+						style = ""
+					} else {
+						style += seq
+					}
+				}
+				i = j
+			}
+			continue
+		}
+		width := RuneWidth(r)
+		switch {
+		case r < 0x20 || r == 0x7f:
+			cells = append(cells, cell{text: "^" + string(r^0x40), width: width, style: style})
+		case width == 0 && len(cells) > 0:
+			cells[len(cells)-1].text += string(r)
+		default:
+			cells = append(cells, cell{text: string(r), width: width, style: style})
+		}
+	}
+	return cells
+}
+
+// frame is what the editor shows: rows of cells and where the cursor is
+type frame struct {
+	rows     [][]cell
+	row, col int
+}
+
+// layout wraps flow, the prompt and line, into rows width columns wide,
+// with the cursor before flow[cursor]. The hint follows on the last row, as
+// far as it fits, and the rows in below come after, cut to the width.
+func layout(width int, flow []cell, cursor int, hint []cell, below [][]cell) frame {
+	f := frame{rows: [][]cell{nil}}
+	col := 0
+	for i := 0; i <= len(flow); i++ {
+		var c cell
+		if i < len(flow) {
+			c = flow[i]
+		}
+		// A character that doesn't fit starts a new row, and so does the
+		// cursor when the row before it is full
+		if col > 0 && (col+c.width > width || (i == cursor && col >= width)) {
+			f.rows = append(f.rows, nil)
+			col = 0
+		}
+		if i == cursor {
+			f.row, f.col = len(f.rows)-1, col
+		}
+		if i < len(flow) {
+			f.rows[len(f.rows)-1] = append(f.rows[len(f.rows)-1], c)
+			col += c.width
+		}
+	}
+
+	// The hint stops a column short of the edge, so that the terminal
+	// doesn't wrap
+	last := len(f.rows) - 1
+	for _, c := range hint {
+		if col+c.width >= width {
+			break
+		}
+		f.rows[last] = append(f.rows[last], c)
+		col += c.width
+	}
+
+	for _, row := range below {
+		var cut []cell
+		used := 0
+		for _, c := range row {
+			if used+c.width > width {
+				break
+			}
+			cut = append(cut, c)
+			used += c.width
+		}
+		f.rows = append(f.rows, cut)
+	}
+	return f
+}
+
+// renderRow returns the text that draws a row of cells and its width
+func renderRow(cells []cell) (string, int) {
+	var b strings.Builder
+	style, width := "", 0
+	for _, c := range cells {
+		if c.style != style {
+			b.WriteString("\x1b[0m" + c.style)
+			style = c.style
+		}
+		b.WriteString(c.text)
+		width += c.width
+	}
+	if style != "" {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String(), width
+}
+
+// screen draws frames over one another, rewriting only the rows that
+// changed since the last one
+type screen struct {
+	out   io.Writer
+	rows  []string // the rows drawn last
+	row   int      // the cursor's row among them
+	width int      // the terminal width they were drawn at
+}
+
+// draw replaces the frame on the screen with f
+func (s *screen) draw(f frame, width int) {
+	var b strings.Builder
+	if s.row > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", s.row)
+	}
+	b.WriteByte('\r')
+	if width != s.width && s.rows != nil {
+		// The old rows wrapped differently, so nothing of them can be kept
+		b.WriteString("\x1b[J")
+		s.rows = nil
+	}
+
+	rows := make([]string, len(f.rows))
+	for i, cells := range f.rows {
+		row, used := renderRow(cells)
+		rows[i] = row
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		if i < len(s.rows) && s.rows[i] == row {
+			continue
+		}
+		b.WriteString(row)
+		// Erasing after a full row would erase its last character
+		if used < width {
+			b.WriteString("\x1b[K")
+		}
+	}
+	last := len(rows) - 1
+	if len(s.rows) > len(rows) {
+		b.WriteString("\r\n\x1b[J")
+		last++
+	}
+
+	if up := last - f.row; up > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", up)
+	}
+	b.WriteByte('\r')
+	if f.col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", f.col)
+	}
+	io.WriteString(s.out, b.String())
+	s.rows, s.row, s.width = rows, f.row, width
+}
+
+// clear erases the frame, leaving the cursor where it started
+func (s *screen) clear() {
+	if s.row > 0 {
+		fmt.Fprintf(s.out, "\x1b[%dA", s.row)
+	}
+	io.WriteString(s.out, "\r\x1b[J")
+	s.reset()
+}
+
+// finish leaves the frame on the screen and moves the cursor to the start
+// of the line below it
+func (s *screen) finish() {
+	if down := len(s.rows) - 1 - s.row; down > 0 {
+		fmt.Fprintf(s.out, "\x1b[%dB", down)
+	}
+	io.WriteString(s.out, "\r\n")
+	s.reset()
+}
+
+// reset forgets the frame, so the next one is drawn from the cursor
+func (s *screen) reset() {
+	s.rows, s.row = nil, 0
+}
@@ -0,0 +1,104 @@
+package lineedit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"hello", 5},
+		{"\x1b[1;32mgo\x1b[0m", 2},
+		{"世界", 4},
+		{"é", 1},
+		{"a\tb", 4},
+		{"🚀", 2},
+		{"$ \x1b]133;B\a", 2},
+		{"\x1b]8;;https://go.dev\x1b\\go\x1b]8;;\x1b\\", 2},
+	}
+	for _, tt := range tests {
+		if got := StringWidth(tt.text); got != tt.want {
+			t.Errorf("StringWidth(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+// rowTexts returns the text of each row of a frame
+func rowTexts(f frame) []string {
+	texts := make([]string, len(f.rows))
+	for i, row := range f.rows {
+		for _, c := range row {
+			texts[i] += c.text
+		}
+	}
+	return texts
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		flow     string
+		cursor   int
+		hint     string
+		below    []string
+		want     []string
+		row, col int
+	}{
+		{"fits", "$ ls", 4, "", nil, []string{"$ ls"}, 0, 4},
+		{"wraps", "$ abcdefgh", 3, "", nil, []string{"$ abcd", "efgh"}, 0, 3},
+		{"cursor after a full row", "$ abcd", 6, "", nil, []string{"$ abcd", ""}, 1, 0},
+		{"wide character moves down", "$ abc世", 6, "", nil, []string{"$ abc", "世"}, 1, 2},
+		{"hint is cut short", "$ a", 3, "bcdef", nil, []string{"$ abc"}, 0, 3},
+		{"marks take no room", "$ \x1b]133;B\als", 5, "", nil, []string{"$ \x1b]133;B\als"}, 0, 4},
+		{"rows below", "$ a", 3, "", []string{"one", "two three"}, []string{"$ a", "one", "two th"}, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var below [][]cell
+			for _, row := range tt.below {
+				below = append(below, parseCells(row, ""))
+			}
+			f := layout(6, parseCells(tt.flow, ""), tt.cursor, parseCells(tt.hint, ""), below)
+			if got := rowTexts(f); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+			if f.row != tt.row || f.col != tt.col {
+				t.Errorf("cursor at %d,%d, want %d,%d", f.row, f.col, tt.row, tt.col)
+			}
+		})
+	}
+}
+
+func TestScreenDraw(t *testing.T) {
+	var out bytes.Buffer
+	s := screen{out: &out}
+	draw := func(text string, cursor int, below ...string) string {
+		out.Reset()
+		var rows [][]cell
+		for _, row := range below {
+			rows = append(rows, parseCells(row, ""))
+		}
+		s.draw(layout(20, parseCells(text, ""), cursor, nil, rows), 20)
+		return out.String()
+	}
+
+	if got := draw("$ ls", 4); got != "\r$ ls\x1b[K\r\x1b[4C" {
+		t.Errorf("first draw = %q", got)
+	}
+	// Rows that didn't change aren't written again
+	if got := draw("$ ls", 4, "menu"); got != "\r\r\nmenu\x1b[K\x1b[1A\r\x1b[4C" {
+		t.Errorf("draw with a row below = %q", got)
+	}
+	// Rows no longer shown are erased
+	if got := draw("$ ls -l", 7); got != "\r$ ls -l\x1b[K\r\n\x1b[J\x1b[1A\r\x1b[7C" {
+		t.Errorf("draw with fewer rows = %q", got)
+	}
+	// Colors are reset at the end of a row
+	if got := draw("\x1b[32m$\x1b[0m x", 3); !strings.Contains(got, "\x1b[0m\x1b[32m$\x1b[0m x") {
+		t.Errorf("colored draw = %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package lineedit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// search is an incremental search back through the history, started with
+// Ctrl-R. The line shows the entry found as the query is typed.
+type search struct {
+	query  []rune
+	index  int  // the history entry found, len(history) before any is
+	failed bool // the query isn't in any entry
+
+	// The line as it was before the search, for Ctrl-G to bring back
+	saved    []rune
+	savedPos int
+}
+
+// newSearch starts a search from the end of the history
+func newSearch(e *Editor) *search {
+	line, pos := e.Buffer()
+	return &search{index: len(e.history), saved: line, savedPos: pos}
+}
+
+// prompt returns what is shown in place of the prompt during the search
+func (s *search) prompt() string {
+	failed := ""
+	if s.failed {
+		failed = "failed "
+	}
+	return fmt.Sprintf("(%sreverse-i-search)`%s': ", failed, string(s.query))
+}
+
+// find shows the newest entry at or before from that holds the query, with
+// the cursor at the query. If there is none the line stays as it is.
+func (s *search) find(e *Editor, from int) {
+	from = min(from, len(e.history)-1)
+	if candidates, ok := e.index.candidates(string(s.query), from); ok {
+		for j := len(candidates) - 1; j >= 0; j-- {
+			if s.show(e, candidates[j]) {
+				return
+			}
+		}
+	} else {
+		for i := from; i >= 0; i-- {
+			if s.show(e, i) {
+				return
+			}
+		}
+	}
+	s.failed = true
+	e.Bell()
+}
+
+// show shows history entry i if it holds the query, reporting whether it did
+func (s *search) show(e *Editor, i int) bool {
+	at := strings.Index(e.history[i], string(s.query))
+	if at < 0 {
+		return false
+	}
+	s.index, s.failed = i, false
+	e.SetBuffer([]rune(e.history[i]), len([]rune(e.history[i][:at])))
+	return true
+}
+
+// display returns how the line is shown during the search, with the query
+// highlighted where it was found
+func (s *search) display(line []rune, pos int) Display {
+	end := pos + len(s.query)
+	if s.failed || len(s.query) == 0 || end > len(line) || string(line[pos:end]) != string(s.query) {
+		return Display{Line: string(line)}
+	}
+	return Display{Line: string(line[:pos]) + "\x1b[7m" + string(s.query) + "\x1b[0m" + string(line[end:])}
+}
+
+// handle acts on a key during the search. Typing extends the query, Ctrl-R
+// finds an older match and Backspace shortens the query. Ctrl-G gives up,
+// bringing back the line as it was; Esc ends the search with the match left
+// to edit. Any other key ends the search and is then handled as usual, so
+// Enter runs the match. handle reports true for the keys it dealt with.
+func (s *search) handle(e *Editor, key Key) (Key, bool) {
+	switch {
+	case key == CtrlR:
+		s.find(e, s.index-1)
+	case key == Backspace || key == CtrlH:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+		}
+		if len(s.query) == 0 {
+			s.index, s.failed = len(e.history), false
+			e.SetBuffer(s.saved, s.savedPos)
+			break
+		}
+		s.find(e, len(e.history)-1)
+	case key == CtrlG:
+		e.SetBuffer(s.saved, s.savedPos)
+		e.search = nil
+	case key == Esc:
+		e.search = nil
+	case key >= ' ' && key < KeyUp:
+		s.query = append(s.query, rune(key))
+		s.find(e, s.index)
+	default:
+		e.search = nil
+		return key, false
+	}
+	return 0, true
+}
+
+// indexGram is the length of the substrings historyIndex records
+const indexGram = 3
+
+// historyIndex records which history entries hold each substring of
+// indexGram runes, so that a search for a longer query only looks at the
+// entries that could match rather than the whole history. Shorter queries
+// match so many entries that going through them in order is as quick.
+type historyIndex struct {
+	entries map[string][]int // indexes of the entries holding each substring, ascending
+}
+
+// add records the substrings of history entry i, which is newer than any
+// added before
+func (x *historyIndex) add(i int, entry string) {
+	if x.entries == nil {
+		x.entries = make(map[string][]int)
+	}
+	runes := []rune(entry)
+	for j := 0; j+indexGram <= len(runes); j++ {
+		gram := string(runes[j : j+indexGram])
+		if list := x.entries[gram]; len(list) == 0 || list[len(list)-1] != i {
+			x.entries[gram] = append(list, i)
+		}
+	}
+}
+
+// candidates returns the entries up to and including from that could hold
+// the query, oldest first: those holding its rarest substring. It reports
+// false if the query is too short to be looked up, leaving every entry a
+// candidate.
+func (x *historyIndex) candidates(query string, from int) ([]int, bool) {
+	runes := []rune(query)
+	if len(runes) < indexGram {
+		return nil, false
+	}
+	// The rarest substring narrows the entries down the most
+	var best []int
+	for j := 0; j+indexGram <= len(runes); j++ {
+		list := x.entries[string(runes[j:j+indexGram])]
+		if j == 0 || len(list) < len(best) {
+			best = list
+		}
+	}
+	end := sort.SearchInts(best, from+1)
+	return best[:end:end], true
+}
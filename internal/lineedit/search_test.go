@@ -0,0 +1,96 @@
+package lineedit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	e := newTestEditor()
+	for _, line := range []string{"git status", "ls -l", "git log", "echo hi"} {
+		e.AddHistory(line)
+	}
+	e.SetBuffer([]rune("draft"), 5)
+
+	if line, pos := typeKeys(e, "\x12git"); line != "git log" || pos != 0 {
+		t.Errorf("search for git found %q at %d", line, pos)
+	}
+	if got := e.search.prompt(); got != "(reverse-i-search)`git': " {
+		t.Errorf("search prompt = %q", got)
+	}
+	if line, _ := typeKeys(e, "\x12"); line != "git status" {
+		t.Errorf("Ctrl-R found %q, want the older match", line)
+	}
+	if line, _ := typeKeys(e, "\x12"); line != "git status" || !e.search.failed {
+		t.Errorf("Ctrl-R past the oldest match gave %q, failed %v", line, e.search.failed)
+	}
+	typeKeys(e, "\x07")
+	if line, pos := e.Buffer(); e.search != nil || string(line) != "draft" || pos != 5 {
+		t.Errorf("Ctrl-G left %q at %d, searching %v", string(line), pos, e.search != nil)
+	}
+
+	// Esc keeps the match to edit; other keys are then handled as usual
+	if line, pos := typeKeys(e, "\x12ls\x1b"); line != "ls -l" || pos != 0 || e.search != nil {
+		t.Errorf("Esc left %q at %d", line, pos)
+	}
+	typeKeys(e, "\x12echo")
+	if accepted, _ := e.HandleKey(Enter); !accepted || e.search != nil {
+		t.Error("Enter didn't accept the match")
+	}
+	if line, _ := e.Buffer(); string(line) != "echo hi" {
+		t.Errorf("accepted %q", string(line))
+	}
+}
+
+func TestSearchHighlight(t *testing.T) {
+	e := newTestEditor()
+	e.AddHistory("make test")
+	typeKeys(e, "\x12tes")
+	line, pos := e.Buffer()
+	if got := e.search.display(line, pos).Line; got != "make \x1b[7mtes\x1b[0mt" {
+		t.Errorf("display = %q, want the query highlighted", got)
+	}
+}
+
+func TestHistoryIndex(t *testing.T) {
+	e := newTestEditor()
+	for i := 0; i < 2000; i++ {
+		e.AddHistory(fmt.Sprintf("cmd-%d --flag=%d", i, i%7))
+	}
+	e.AddHistory("grep -r needle .")
+
+	// The index leaves out only entries that can't match
+	for _, query := range []string{"needle", "cmd-19", "flag=3", "1999 ", "missing"} {
+		var want []int
+		for i, entry := range e.history {
+			if strings.Contains(entry, query) {
+				want = append(want, i)
+			}
+		}
+		candidates, ok := e.index.candidates(query, len(e.history)-1)
+		if !ok {
+			t.Fatalf("candidates(%q) wasn't looked up", query)
+		}
+		var got []int
+		for _, i := range candidates {
+			if strings.Contains(e.history[i], query) {
+				got = append(got, i)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("candidates(%q) matched %v, want %v", query, got, want)
+		}
+	}
+	if candidates, _ := e.index.candidates("needle", len(e.history)-1); len(candidates) != 1 {
+		t.Errorf("candidates(needle) = %d entries, want just the one", len(candidates))
+	}
+	if _, ok := e.index.candidates("cm", 10); ok {
+		t.Error("a query shorter than the index's substrings was looked up")
+	}
+
+	if line, _ := typeKeys(e, "\x12cmd-12 "); line != "cmd-12 --flag=5" {
+		t.Errorf("search found %q", line)
+	}
+}
@@ -0,0 +1,88 @@
+package lineedit
+
+import "unicode"
+
+// wideRanges are the code points terminals draw two columns wide: East
+// Asian wide and fullwidth characters, and emoji
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo initials
+	{0x231a, 0x231b},   // watch, hourglass
+	{0x23e9, 0x23ec},   // media controls
+	{0x23f0, 0x23f3},   // clocks
+	{0x25fd, 0x25fe},   // small squares
+	{0x2614, 0x2615},   // umbrella, hot beverage
+	{0x2648, 0x2653},   // zodiac
+	{0x26a1, 0x26a1},   // high voltage
+	{0x26bd, 0x26be},   // balls
+	{0x26c4, 0x26c5},   // snowman, sun
+	{0x26d4, 0x26d4},   // no entry
+	{0x26ea, 0x26ea},   // church
+	{0x26f2, 0x26f5},   // fountain to sailboat
+	{0x26fa, 0x26fd},   // tent, fuel pump
+	{0x2705, 0x2705},   // check mark
+	{0x270a, 0x270b},   // fists
+	{0x2728, 0x2728},   // sparkles
+	{0x274c, 0x274e},   // crosses
+	{0x2753, 0x2757},   // question and exclamation marks
+	{0x2795, 0x2797},   // plus, minus, divide
+	{0x27b0, 0x27bf},   // loops
+	{0x2b1b, 0x2b1c},   // large squares
+	{0x2b50, 0x2b55},   // star, circle
+	{0x2e80, 0x303e},   // CJK radicals to CJK symbols
+	{0x3041, 0x33ff},   // Hiragana to CJK compatibility
+	{0x3400, 0x4dbf},   // CJK extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xa960, 0xa97f},   // Hangul Jamo extended
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe10, 0xfe19},   // vertical forms
+	{0xfe30, 0xfe6f},   // CJK compatibility forms
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x1f004, 0x1f004}, // mahjong tile
+	{0x1f0cf, 0x1f0cf}, // joker
+	{0x1f18e, 0x1f18e}, // AB button
+	{0x1f191, 0x1f19a}, // squared words
+	{0x1f200, 0x1f251}, // enclosed ideographs
+	{0x1f300, 0x1f64f}, // pictographs and emoticons
+	{0x1f680, 0x1f6ff}, // transport and map symbols
+	{0x1f7e0, 0x1f7eb}, // coloured circles and squares
+	{0x1f90c, 0x1f9ff}, // supplemental pictographs
+	{0x1fa70, 0x1faff}, // symbols and pictographs extended
+	{0x20000, 0x3fffd}, // CJK extensions B and later
+}
+
+// RuneWidth returns the number of terminal columns r occupies: 0 for
+// combining marks and other invisible characters, 2 for wide characters,
+// and 1 otherwise. Control characters count as the two columns of their ^X
+// form, which is how the editor shows them.
+func RuneWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7f:
+		return 2
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	for _, span := range wideRanges {
+		if r < span[0] {
+			break
+		}
+		if r <= span[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// StringWidth returns the number of columns s occupies, ignoring the escape
+// sequences that color it
+func StringWidth(s string) int {
+	width := 0
+	for _, c := range parseCells(s, "") {
+		width += c.width
+	}
+	return width
+}
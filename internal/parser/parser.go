@@ -0,0 +1,355 @@
+// Package parser splits command lines into words and operators and parses
+// them into pipelines joined by ;, && and ||. Words are kept as typed,
+// quotes and all, for the shell to expand just before running them.
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TokenKind distinguishes plain words from control and redirection operators
+type TokenKind int
+
+const (
+	TokenWord TokenKind = iota
+	TokenOperator
+)
+
+// Token is a single lexical element of a command line. Text holds the raw
+// source text, so quotes and escapes inside words are preserved.
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+// ErrIncomplete is returned when the input ends inside a quote or escape
+var ErrIncomplete = errors.New("unexpected end of input")
+
+// JoinContinued joins the lines of a command entered over several lines. A
+// backslash ending a line outside quotes continues it, so it is removed along
+// with the line break; other breaks are kept, as they are either inside quotes
+// or after an operator such as |, where they count as blanks.
+func JoinContinued(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i == len(lines)-1 {
+			b.WriteString(line)
+			break
+		}
+		if trimmed, ok := strings.CutSuffix(line, "\\"); ok {
+			if _, err := Tokenize(b.String() + trimmed); err == nil {
+				b.WriteString(trimmed)
+				continue
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// operators lists the recognised operators, longest first so that the lexer
+// always prefers the longest match
+var operators = []string{
+	"2>&1", "2>>", "&&", "||", ">>", "2>",
+	"|", "&", ";", "<", ">",
+}
+
+// Tokenize splits a command line into words and operators. Words keep their
+// quoting so that callers can decide whether to expand or display them.
+func Tokenize(input string) ([]Token, error) {
+	var tokens []Token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+
+		// Skip whitespace between tokens
+		if c == ' ' || c == '\t' || c == '\n' {
+			i++
+			continue
+		}
+
+		// Comments run to the end of the line
+		if c == '#' {
+			end := strings.IndexByte(input[i:], '\n')
+			if end < 0 {
+				break
+			}
+			i += end
+			continue
+		}
+
+		// Process substitution, >(cmd) or <(cmd), is a single word
+		if strings.HasPrefix(input[i:], ">(") || strings.HasPrefix(input[i:], "<(") {
+			end, err := ScanParens(input, i+1)
+			if err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, Token{Kind: TokenWord, Text: input[i:end]})
+			i = end
+			continue
+		}
+
+		if op := MatchOperator(input[i:]); op != "" {
+			tokens = append(tokens, Token{Kind: TokenOperator, Text: op})
+			i += len(op)
+			continue
+		}
+
+		end, err := ScanWord(input, i)
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, Token{Kind: TokenWord, Text: input[i:end]})
+		i = end
+	}
+	return tokens, nil
+}
+
+// MatchOperator returns the operator at the start of s, or "" if none
+func MatchOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// ScanWord returns the index just past the word starting at start
+func ScanWord(input string, start int) (int, error) {
+	i := start
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			return i, nil
+		case c == '\\':
+			if i+1 >= len(input) {
+				return i, ErrIncomplete
+			}
+			i += 2
+		case c == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return i, ErrIncomplete
+			}
+			i += end + 2
+		case c == '"':
+			end, err := ScanDoubleQuoted(input, i+1)
+			if err != nil {
+				return i, err
+			}
+			i = end
+		case c == '$' && i+1 < len(input) && input[i+1] == '(':
+			end, err := ScanParens(input, i+1)
+			if err != nil {
+				return i, err
+			}
+			i = end
+		case c == '$' && i+1 < len(input) && input[i+1] == '{':
+			end := strings.IndexByte(input[i+2:], '}')
+			if end < 0 {
+				return i, ErrIncomplete
+			}
+			i += end + 3
+		default:
+			// Operators end a word, except the 2> family which only counts
+			// at the start of a Token
+			if op := MatchOperator(input[i:]); i > start && op != "" && op[0] != '2' {
+				return i, nil
+			}
+			i++
+		}
+	}
+	return i, nil
+}
+
+// ScanDoubleQuoted returns the index just past the closing double quote of a
+// string whose contents begin at start
+func ScanDoubleQuoted(input string, start int) (int, error) {
+	i := start
+	for i < len(input) {
+		switch input[i] {
+		case '\\':
+			i += 2
+		case '"':
+			return i + 1, nil
+		case '$':
+			if i+1 < len(input) && input[i+1] == '(' {
+				end, err := ScanParens(input, i+1)
+				if err != nil {
+					return i, err
+				}
+				i = end
+				continue
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return i, ErrIncomplete
+}
+
+// ScanParens returns the index just past the parenthesis matching the one at
+// start, honouring nested parentheses and quotes
+func ScanParens(input string, start int) (int, error) {
+	depth := 0
+	i := start
+	for i < len(input) {
+		switch input[i] {
+		case '(':
+			depth++
+			i++
+		case ')':
+			depth--
+			i++
+			if depth == 0 {
+				return i, nil
+			}
+		case '\\':
+			i += 2
+		case '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return i, ErrIncomplete
+			}
+			i += end + 2
+		case '"':
+			end, err := ScanDoubleQuoted(input, i+1)
+			if err != nil {
+				return i, err
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return i, ErrIncomplete
+}
+
+// NormalizeCommand rewrites a command line into a canonical form: tokens are
+// separated by single spaces and trailing semicolons are dropped. Quoted text
+// is left untouched. Lines that cannot be tokenized are only trimmed.
+func NormalizeCommand(input string) string {
+	tokens, err := Tokenize(input)
+	if err != nil {
+		return strings.TrimSpace(input)
+	}
+	for len(tokens) > 0 {
+		last := tokens[len(tokens)-1]
+		if last.Kind != TokenOperator || last.Text != ";" {
+			break
+		}
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	parts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		parts[i] = tok.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+// Redirect describes a single I/O redirection attached to a command
+type Redirect struct {
+	Op     string // one of <, >, >>, 2>, 2>>, 2>&1
+	Target string // raw target word, empty for 2>&1
+}
+
+// Command is a single stage of a pipeline
+type Command struct {
+	Words     []string // raw words, expanded just before execution
+	Redirects []Redirect
+}
+
+// Pipeline is a sequence of commands connected with |
+type Pipeline struct {
+	Commands []*Command
+}
+
+// List is a sequence of pipelines joined by ;, && or ||
+type List struct {
+	Pipelines []*Pipeline
+	Ops       []string // Ops[i] joins Pipelines[i] and Pipelines[i+1]
+}
+
+// Parse tokenizes and parses a full command line
+func Parse(input string) (*List, error) {
+	tokens, err := Tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &List{}
+	current := &Pipeline{}
+	cmd := &Command{}
+
+	// finishCommand appends the command being built to the current pipeline
+	finishCommand := func(op string) error {
+		if len(cmd.Words) == 0 {
+			return fmt.Errorf("syntax error near unexpected token `%s'", op)
+		}
+		current.Commands = append(current.Commands, cmd)
+		cmd = &Command{}
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind == TokenWord {
+			cmd.Words = append(cmd.Words, tok.Text)
+			continue
+		}
+
+		switch tok.Text {
+		case "<", ">", ">>", "2>", "2>>":
+			if i+1 >= len(tokens) || tokens[i+1].Kind != TokenWord {
+				return nil, fmt.Errorf("syntax error: missing target for `%s'", tok.Text)
+			}
+			cmd.Redirects = append(cmd.Redirects, Redirect{Op: tok.Text, Target: tokens[i+1].Text})
+			i++
+		case "2>&1":
+			cmd.Redirects = append(cmd.Redirects, Redirect{Op: tok.Text})
+		case "|":
+			if err := finishCommand(tok.Text); err != nil {
+				return nil, err
+			}
+		case ";", "&&", "||":
+			if err := finishCommand(tok.Text); err != nil {
+				// A trailing or doubled semicolon is harmless
+				if tok.Text == ";" && len(current.Commands) == 0 {
+					continue
+				}
+				return nil, err
+			}
+			list.Pipelines = append(list.Pipelines, current)
+			list.Ops = append(list.Ops, tok.Text)
+			current = &Pipeline{}
+		case "&":
+			return nil, fmt.Errorf("background jobs are not supported")
+		}
+	}
+
+	// A line ending in a connector continues on the next line
+	if n := len(tokens); n > 0 && tokens[n-1].Kind == TokenOperator {
+		switch tokens[n-1].Text {
+		case "|", "&&", "||":
+			return nil, ErrIncomplete
+		}
+	}
+
+	if len(cmd.Words) > 0 || len(cmd.Redirects) > 0 {
+		if err := finishCommand("newline"); err != nil {
+			return nil, err
+		}
+	}
+	if len(current.Commands) > 0 {
+		list.Pipelines = append(list.Pipelines, current)
+	} else if len(list.Ops) > 0 {
+		list.Ops = list.Ops[:len(list.Ops)-1]
+	}
+	return list, nil
+}
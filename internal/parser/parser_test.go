@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"ls -la", []string{"ls", "-la"}},
+		{"ls|grep go", []string{"ls", "|", "grep", "go"}},
+		{"echo 'a  b' \"c | d\"", []string{"echo", "'a  b'", "\"c | d\""}},
+		{"cmd 2>err >out", []string{"cmd", "2>", "err", ">", "out"}},
+		{"echo a2>b", []string{"echo", "a2", ">", "b"}},
+		{"echo $(ls | wc -l) done", []string{"echo", "$(ls | wc -l)", "done"}},
+		{"echo hi # comment", []string{"echo", "hi"}},
+		{"ls | # comment\nwc", []string{"ls", "|", "wc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := Tokenize(tt.input)
+			if err != nil {
+				t.Fatalf("Tokenize(%q) error = %v", tt.input, err)
+			}
+			if len(tokens) != len(tt.want) {
+				t.Fatalf("Tokenize(%q) = %v, want %v", tt.input, tokens, tt.want)
+			}
+			for i, tok := range tokens {
+				if tok.Text != tt.want[i] {
+					t.Errorf("token[%d] = %q, want %q", i, tok.Text, tt.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("unterminated quote", func(t *testing.T) {
+		if _, err := Tokenize("echo 'oops"); err != ErrIncomplete {
+			t.Errorf("Tokenize() error = %v, want %v", err, ErrIncomplete)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	list, err := Parse("cat < in | sort > out 2>&1 && echo done; pwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Pipelines) != 3 {
+		t.Fatalf("pipelines = %d, want 3", len(list.Pipelines))
+	}
+	if got := strings.Join(list.Ops, " "); got != "&& ;" {
+		t.Errorf("ops = %q, want %q", got, "&& ;")
+	}
+	first := list.Pipelines[0]
+	if len(first.Commands) != 2 {
+		t.Fatalf("commands = %d, want 2", len(first.Commands))
+	}
+	if r := first.Commands[0].Redirects; len(r) != 1 || r[0].Op != "<" || r[0].Target != "in" {
+		t.Errorf("redirects = %+v, want < in", r)
+	}
+	if r := first.Commands[1].Redirects; len(r) != 2 || r[1].Op != "2>&1" {
+		t.Errorf("redirects = %+v, want > out 2>&1", r)
+	}
+
+	for _, bad := range []string{"| wc", "ls | | wc", "ls && && pwd"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", bad)
+		}
+	}
+	if _, err := Parse("ls |"); err != ErrIncomplete {
+		t.Errorf("Parse(trailing pipe) error = %v, want %v", err, ErrIncomplete)
+	}
+}
+
+func TestJoinContinued(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{"ls |", "wc -l"}, "ls |\nwc -l"},
+		{[]string{"echo one \\", "two"}, "echo one two"},
+		{[]string{"echo 'a \\", "b'"}, "echo 'a \\\nb'"},
+		{[]string{"echo a\\\\", "b"}, "echo a\\\\\nb"},
+	}
+	for _, tt := range tests {
+		if got := JoinContinued(tt.lines); got != tt.want {
+			t.Errorf("JoinContinued(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ls   -la", "ls -la"},
+		{"  git status ;", "git status"},
+		{"echo hi;;", "echo hi"},
+		{"echo 'keep   spaces'", "echo 'keep   spaces'"},
+		{"ls|wc -l", "ls | wc -l"},
+		{"echo 'unterminated  ", "echo 'unterminated"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeCommand(tt.input); got != tt.want {
+			t.Errorf("NormalizeCommand(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
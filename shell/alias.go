@@ -0,0 +1,157 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("alias", "alias [NAME[=VALUE]...]", "Define or list command aliases", builtinAlias)
+	registerBuiltin("unalias", "unalias -a | NAME...", "Remove command aliases", builtinUnalias)
+	registerFlags("unalias", Candidate{"-a", "Remove every alias"})
+	registerArgs("unalias", func(s *Shell, word string) []Candidate {
+		var candidates []Candidate
+		for _, name := range s.aliasNames() {
+			if s.matchWord(name, word) {
+				candidates = append(candidates, Candidate{Text: name, Description: s.aliases[name]})
+			}
+		}
+		return candidates
+	})
+}
+
+// An alias replaces the word it names where a command starts on the line
+// typed, as in bash: alias ll='ls -la' makes ll -h run ls -la -h. The value
+// may itself hold operators, as in alias gs='git status | head'. A value
+// ending in a space makes the next word a command position too, so aliases
+// after alias sudo='sudo ' are expanded. Aliases apply to lines typed or read
+// from the startup file, not to scripts run with Run.
+
+// aliasNames returns the defined aliases' names, sorted
+func (s *Shell) aliasNames() []string {
+	names := make([]string, 0, len(s.aliases))
+	for name := range s.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isAliasName reports whether name can be an alias: a plain word with
+// nothing the shell would expand or treat specially
+func isAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n=$'\"\\|&;<>()`*?[]~#")
+}
+
+// expandAliases replaces the aliases at the command positions of a line.
+// An alias isn't expanded again inside its own value, so alias ls='ls -F'
+// runs the ls command.
+func (s *Shell) expandAliases(line string) string {
+	if len(s.aliases) == 0 {
+		return line
+	}
+	tokens, err := parser.Tokenize(line)
+	if err != nil {
+		return line
+	}
+	words, changed := s.expandAliasTokens(tokens, make(map[string]bool))
+	if !changed {
+		return line
+	}
+	return strings.Join(words, " ")
+}
+
+// expandAliasTokens returns the text of tokens with their aliases expanded,
+// skipping those being expanded already, and whether any was
+func (s *Shell) expandAliasTokens(tokens []parser.Token, expanding map[string]bool) ([]string, bool) {
+	var words []string
+	changed := false
+	commandStart := true
+	for _, tok := range tokens {
+		if tok.Kind == parser.TokenOperator {
+			words = append(words, tok.Text)
+			commandStart = tok.Text == "|" || tok.Text == ";" || tok.Text == "&&" || tok.Text == "||"
+			continue
+		}
+		value, ok := s.aliases[tok.Text]
+		if !commandStart || !ok || expanding[tok.Text] {
+			words = append(words, tok.Text)
+			commandStart = false
+			continue
+		}
+		changed = true
+		expanding[tok.Text] = true
+		valueTokens, err := parser.Tokenize(value)
+		if err != nil {
+			words = append(words, value)
+		} else {
+			expanded, _ := s.expandAliasTokens(valueTokens, expanding)
+			words = append(words, expanded...)
+		}
+		delete(expanding, tok.Text)
+		commandStart = strings.HasSuffix(value, " ")
+	}
+	return words, changed
+}
+
+// builtinAlias defines aliases with NAME=VALUE and prints those named
+// alone, or every alias with no arguments, as alias commands
+func builtinAlias(s *Shell, args []string, stdio Stdio) int {
+	if len(args) == 1 {
+		for _, name := range s.aliasNames() {
+			fmt.Fprintf(stdio.Stdout, "alias %s=%s\n", name, shellQuote(s.aliases[name]))
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args[1:] {
+		name, value, define := strings.Cut(arg, "=")
+		switch {
+		case !isAliasName(name):
+			fmt.Fprintf(stdio.Stderr, "alias: invalid alias name: %s\n", name)
+			status = 1
+		case define:
+			s.aliases[name] = value
+		default:
+			value, ok := s.aliases[name]
+			if !ok {
+				fmt.Fprintf(stdio.Stderr, "alias: %s: not found\n", name)
+				status = 1
+				continue
+			}
+			fmt.Fprintf(stdio.Stdout, "alias %s=%s\n", name, shellQuote(value))
+		}
+	}
+	return status
+}
+
+// builtinUnalias removes the named aliases, or every one with -a
+func builtinUnalias(s *Shell, args []string, stdio Stdio) int {
+	var all bool
+	flags := newFlagSet("unalias")
+	flags.Bool(&all, "a")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if all {
+		clear(s.aliases)
+		return 0
+	}
+	if len(operands) == 0 {
+		return flags.usage(stdio)
+	}
+	status := 0
+	for _, name := range operands {
+		if _, ok := s.aliases[name]; !ok {
+			fmt.Fprintf(stdio.Stderr, "unalias: %s: not found\n", name)
+			status = 1
+			continue
+		}
+		delete(s.aliases, name)
+	}
+	return status
+}
@@ -0,0 +1,55 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandAliases(t *testing.T) {
+	shell := NewShell()
+	shell.aliases = map[string]string{
+		"ll":    "ls -la",
+		"ls":    "ls -F",
+		"gs":    "git status | head",
+		"sudo":  "sudo ",
+		"loop":  "loop2",
+		"loop2": "loop",
+	}
+	for line, want := range map[string]string{
+		"ll -h":           "ls -F -la -h",
+		"echo ll":         "echo ll",
+		"gs && ll":        "git status | head && ls -F -la",
+		"sudo ll":         "sudo ls -F -la",
+		"'ll'":            "'ll'",
+		"loop":            "loop",
+		"cat x | ll; pwd": "cat x | ls -F -la ; pwd",
+		"no aliases here": "no aliases here",
+	} {
+		if got := shell.expandAliases(line); got != want {
+			t.Errorf("expandAliases(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestAliasBuiltins(t *testing.T) {
+	shell := NewShell()
+	runCapture(t, shell, `alias greet='echo hello' bye="echo bye"`)
+	if out, _ := runCapture(t, shell, "alias"); out != "alias bye='echo bye'\nalias greet='echo hello'\n" {
+		t.Errorf("alias listed %q", out)
+	}
+	var out syncBuffer
+	if status := shell.runLineWith("greet world", Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}); status != 0 || out.String() != "hello world\n" {
+		t.Errorf("running an alias: %q, status %d", out.String(), status)
+	}
+	if out, status := runCapture(t, shell, "alias 'bad name=x'"); status != 1 || out != "alias: invalid alias name: bad name\n" {
+		t.Errorf("alias with a bad name: %q, status %d", out, status)
+	}
+	runCapture(t, shell, "unalias greet")
+	if out, status := runCapture(t, shell, "alias greet"); status != 1 || out != "alias: greet: not found\n" {
+		t.Errorf("alias after unalias: %q, status %d", out, status)
+	}
+	runCapture(t, shell, "unalias -a")
+	if len(shell.aliases) != 0 {
+		t.Errorf("unalias -a left %v", shell.aliases)
+	}
+}
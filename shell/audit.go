@@ -0,0 +1,373 @@
+package shell
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("audit", "audit [verify [FILE]]", "Show where commands are audited, or check an audit log for tampering", builtinAudit)
+	registerArgs("audit", func(s *Shell, word string) []Candidate {
+		if s.matchWord("verify", word) {
+			return []Candidate{{"verify", "Check that no record was changed, removed, added or cut off"}}
+		}
+		return nil
+	})
+}
+
+// With GOSHELL_AUDIT set when the shell starts, every command line run at
+// the prompt is recorded with when and where it ran, who ran it and its exit
+// status. GOSHELL_AUDIT=syslog sends the records to the system log, under
+// the authpriv facility, and any other value names a file they are
+// appended to, one JSON object per line. Each record in the file holds its
+// number, the hash of the one before and an HMAC of itself, made with a key
+// kept outside the file, in ~/.goshell_audit_key or the file
+// GOSHELL_AUDIT_KEY names. So a record changed, removed or inserted breaks
+// the chain, and without the key it can't be mended. The key file also
+// holds the number and hash of each audit file's last record, so records
+// cut off the end show too: audit verify checks all of it. Secrets in
+// commands are redacted as they are for the history file.
+
+// auditRecord is a command recorded in the audit log. Prev and Hash chain
+// the records of an audit file; syslog records have neither.
+type auditRecord struct {
+	Seq     int64  `json:"seq,omitempty"`
+	Time    string `json:"time"`
+	User    string `json:"user"`
+	Host    string `json:"host"`
+	PID     int    `json:"pid"`
+	Cwd     string `json:"cwd"`
+	Command string `json:"command"`
+	Status  int    `json:"status"`
+	Prev    string `json:"prev,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+// auditKeyFileName is the file in the home directory holding the audit key,
+// unless GOSHELL_AUDIT_KEY names another: the key in hex on the first line,
+// then for each audit file the number and hash of its last record and its
+// path, separated by tabs
+const auditKeyFileName = ".goshell_audit_key"
+
+// auditKeySize is the length of the audit key in bytes
+const auditKeySize = 32
+
+// auditLog is where the session's commands are recorded
+type auditLog struct {
+	path    string    // the audit file, unless logging to syslog
+	keyPath string    // the key file, with the audit file
+	syslog  io.Writer // the system log, for GOSHELL_AUDIT=syslog
+}
+
+// auditKeys is what a key file holds
+type auditKeys struct {
+	key   []byte
+	heads map[string]auditHead // by the audit file's absolute path
+}
+
+// auditHead is the last record of an audit file
+type auditHead struct {
+	seq  int64
+	hash string
+}
+
+// auditKeyPath returns the key file, GOSHELL_AUDIT_KEY or
+// ~/.goshell_audit_key
+func (s *Shell) auditKeyPath() string {
+	if path := s.env.Get("GOSHELL_AUDIT_KEY"); path != "" {
+		return path
+	}
+	return filepath.Join(s.homeDir(), auditKeyFileName)
+}
+
+// startAudit opens the audit log GOSHELL_AUDIT names, if it names one. It
+// and GOSHELL_AUDIT_KEY are read once, after the startup files, so the
+// session can't turn auditing off.
+func (s *Shell) startAudit() error {
+	dest := s.env.Get("GOSHELL_AUDIT")
+	switch dest {
+	case "":
+		return nil
+	case "syslog":
+		w, err := openSyslog()
+		if err != nil {
+			return err
+		}
+		s.audit = &auditLog{syslog: w}
+		return nil
+	}
+	path, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	s.audit = &auditLog{path: path, keyPath: s.auditKeyPath()}
+	return nil
+}
+
+// hash returns the HMAC of a record, made with key and its Hash empty
+func (r auditRecord) hash(key []byte) string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditCommand records a command line that has run and its exit status
+func (s *Shell) auditCommand(line string, status int) error {
+	if s.audit == nil {
+		return nil
+	}
+	r := auditRecord{
+		Time:    time.Now().Format(time.RFC3339Nano),
+		PID:     os.Getpid(),
+		Command: line,
+		Status:  status,
+	}
+	if u, err := user.Current(); err == nil {
+		r.User = u.Username
+	} else {
+		r.User = s.env.Get("USER")
+	}
+	r.Host, _ = os.Hostname()
+	r.Cwd, _ = s.Getwd()
+	if s.options["histredact"] {
+		if patterns, err := s.redactionPatterns(); err == nil {
+			r.Command = redactSecrets(patterns, r.Command)
+		}
+	}
+	if s.audit.syslog != nil {
+		data, _ := json.Marshal(r)
+		_, err := s.audit.syslog.Write(data)
+		return err
+	}
+	return appendAuditRecord(s.audit.path, s.audit.keyPath, r)
+}
+
+// appendAuditRecord chains a record to the last of an audit file, as the
+// key file records it, and appends it. The key file is locked throughout,
+// as other sessions write both files too, and a key is made the first time.
+func appendAuditRecord(path, keyPath string, r auditRecord) error {
+	kf, err := os.OpenFile(keyPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer kf.Close()
+	if err := lockFile(kf, true); err != nil {
+		return err
+	}
+	keys, err := readAuditKeys(kf)
+	if err != nil {
+		return err
+	}
+	if keys.key == nil {
+		keys.key = make([]byte, auditKeySize)
+		if _, err := rand.Read(keys.key); err != nil {
+			return err
+		}
+	}
+
+	// Records cut off the end leave a gap before this one
+	head := keys.heads[path]
+	r.Seq = head.seq + 1
+	r.Prev = head.hash
+	r.Hash = r.hash(keys.key)
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	keys.heads[path] = auditHead{seq: r.Seq, hash: r.Hash}
+	return writeAuditKeys(kf, keys)
+}
+
+// readAuditKeys reads a key file, which holds no key when empty
+func readAuditKeys(f *os.File) (*auditKeys, error) {
+	keys := &auditKeys{heads: make(map[string]auditHead)}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if n == 1 {
+			key, err := hex.DecodeString(line)
+			if err != nil || len(key) != auditKeySize {
+				return nil, fmt.Errorf("%s: line 1 isn't an audit key", f.Name())
+			}
+			keys.key = key
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		var seq int64
+		var err error
+		if len(fields) == 3 {
+			seq, err = strconv.ParseInt(fields[0], 10, 64)
+		}
+		if len(fields) != 3 || err != nil {
+			return nil, fmt.Errorf("%s: line %d isn't an audit file's last record", f.Name(), n)
+		}
+		keys.heads[fields[2]] = auditHead{seq: seq, hash: fields[1]}
+	}
+	return keys, scanner.Err()
+}
+
+// writeAuditKeys replaces what a key file holds
+func writeAuditKeys(f *os.File, keys *auditKeys) error {
+	var b strings.Builder
+	b.WriteString(hex.EncodeToString(keys.key) + "\n")
+	paths := slices.Sorted(maps.Keys(keys.heads))
+	for _, path := range paths {
+		head := keys.heads[path]
+		fmt.Fprintf(&b, "%d\t%s\t%s\n", head.seq, head.hash, path)
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(b.String()), 0)
+	return err
+}
+
+// loadAuditKeys reads the key file at path, under a shared lock
+func loadAuditKeys(path string) (*auditKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return nil, err
+	}
+	keys, err := readAuditKeys(f)
+	if err == nil && keys.key == nil {
+		err = fmt.Errorf("%s holds no audit key", f.Name())
+	}
+	return keys, err
+}
+
+// verifyAudit checks the chain of an audit file with the key its records
+// were made with, returning how many records it holds and the last one's
+// hash, or where it is broken
+func verifyAudit(r io.Reader, key []byte) (int64, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	var prev string
+	var count int64
+	for scanner.Scan() {
+		count++
+		var record auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, prev, fmt.Errorf("line %d: %v", count, err)
+		}
+		switch {
+		case record.Seq != count:
+			return count, prev, fmt.Errorf("line %d: record %d is out of sequence; records were removed or inserted", count, record.Seq)
+		case record.Prev != prev:
+			return count, prev, fmt.Errorf("line %d: the record before it was changed or removed", count)
+		case record.Hash != record.hash(key):
+			return count, prev, fmt.Errorf("line %d: the record was changed", count)
+		}
+		prev = record.Hash
+	}
+	return count, prev, scanner.Err()
+}
+
+// verifyAuditHead checks that an audit file whose chain is intact ends
+// with the record the key file says it does
+func verifyAuditHead(head auditHead, count int64, last string) error {
+	switch {
+	case count < head.seq:
+		return fmt.Errorf("records %d to %d were cut off the end", count+1, head.seq)
+	case count > head.seq || last != head.hash:
+		return fmt.Errorf("record %d isn't the last the key file has", count)
+	}
+	return nil
+}
+
+// builtinAudit shows where the session's commands are audited, or with
+// verify checks the chain of the session's audit file or another
+func builtinAudit(s *Shell, args []string, stdio Stdio) int {
+	switch {
+	case len(args) == 1:
+		switch {
+		case s.audit == nil:
+			fmt.Fprintln(stdio.Stdout, "Commands aren't audited; set GOSHELL_AUDIT to a file or syslog to audit them")
+		case s.audit.syslog != nil:
+			fmt.Fprintln(stdio.Stdout, "Commands are audited to syslog")
+		default:
+			fmt.Fprintf(stdio.Stdout, "Commands are audited to %s\n", s.tildePath(s.audit.path))
+		}
+		return 0
+	case args[1] != "verify" || len(args) > 3:
+		return newFlagSet("audit").usage(stdio)
+	}
+
+	path := ""
+	if len(args) == 3 {
+		path = args[2]
+	} else if s.audit != nil {
+		path = s.audit.path
+	}
+	if path == "" {
+		fmt.Fprintln(stdio.Stderr, "audit: no audit file to verify")
+		return 1
+	}
+	keyPath := s.auditKeyPath()
+	if s.audit != nil && s.audit.keyPath != "" {
+		keyPath = s.audit.keyPath
+	}
+	keys, err := loadAuditKeys(keyPath)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "audit:", unwrapPathError(err))
+		return 1
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "audit:", err)
+		return 1
+	}
+	head, ok := keys.heads[abs]
+	if !ok {
+		fmt.Fprintf(stdio.Stderr, "audit: %s: not audited with the key in %s\n", s.tildePath(path), s.tildePath(keyPath))
+		return 1
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "audit:", unwrapPathError(err))
+		return 1
+	}
+	defer f.Close()
+	count, last, err := verifyAudit(f, keys.key)
+	if err == nil {
+		err = verifyAuditHead(head, count, last)
+	}
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "audit: %s: %v\n", s.tildePath(path), err)
+		return 1
+	}
+	fmt.Fprintf(stdio.Stdout, "%s: %d records, the chain is intact\n", s.tildePath(path), count)
+	return 0
+}
@@ -0,0 +1,89 @@
+package shell
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, "audit.log")
+	shell := New(Options{Env: []string{"HOME=" + home, "GOSHELL_AUDIT=" + path}})
+	if err := shell.startAudit(); err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range []string{"echo hi", "export AWS_SECRET_ACCESS_KEY=hunter2", "false"} {
+		if err := shell.auditCommand(line, i%2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("audit file has %d records, want 3:\n%s", len(lines), data)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("the audit file holds a secret:\n%s", data)
+	}
+	if !strings.Contains(lines[2], `"command":"false","status":0`) || !strings.Contains(lines[2], `"seq":3`) {
+		t.Errorf("last record = %s", lines[2])
+	}
+	if out, status := runCapture(t, shell, "audit verify"); status != 0 || !strings.Contains(out, "3 records, the chain is intact") {
+		t.Errorf("audit verify = %q (status %d)", out, status)
+	}
+
+	keys, err := loadAuditKeys(filepath.Join(home, auditKeyFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, auditKeyFileName)); !strings.Contains(string(data), "3\t") {
+		t.Errorf("key file = %q, want the last record", data)
+	}
+
+	// Changing, removing or inserting a record breaks the chain, even with
+	// its hashes made again, as that takes the key
+	var first auditRecord
+	json.Unmarshal([]byte(lines[0]), &first)
+	forged := auditRecord{Seq: 2, Command: "ls", Prev: first.Hash}
+	forged.Hash = forged.hash(nil)
+	forgedLine, _ := json.Marshal(forged)
+	for _, tc := range []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"changed", []string{lines[0], strings.Replace(lines[1], `"status":1`, `"status":0`, 1), lines[2]}, "line 2: the record was changed"},
+		{"removed", []string{lines[0], lines[2]}, "line 2: record 3 is out of sequence"},
+		{"inserted", []string{lines[0], lines[1], lines[1], lines[2]}, "line 3: record 2 is out of sequence"},
+		{"forged", []string{lines[0], string(forgedLine)}, "line 2: the record was changed"},
+	} {
+		_, _, err := verifyAudit(strings.NewReader(strings.Join(tc.lines, "\n")), keys.key)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s record: verifyAudit = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+
+	// Another session continues the chain
+	other := New(Options{Env: []string{"HOME=" + home, "GOSHELL_AUDIT=" + path}})
+	other.startAudit()
+	other.auditCommand("pwd", 0)
+	if out, status := runCapture(t, shell, "audit verify"); status != 0 || !strings.Contains(out, "4 records, the chain is intact") {
+		t.Errorf("after a second session audit verify = %q (status %d)", out, status)
+	}
+
+	// Records cut off the end are missed, and leave a gap once the next is
+	// written
+	data, _ = os.ReadFile(path)
+	lines = strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+	os.WriteFile(path, []byte(strings.Join(lines[:2], "")), 0600)
+	if out, status := runCapture(t, shell, "audit verify"); status != 1 || !strings.Contains(out, "records 3 to 4 were cut off the end") {
+		t.Errorf("audit verify of a truncated file = %q (status %d)", out, status)
+	}
+	shell.auditCommand("echo again", 0)
+	if out, status := runCapture(t, shell, "audit verify"); status != 1 || !strings.Contains(out, "line 3: record 5 is out of sequence") {
+		t.Errorf("audit verify after a truncated file = %q (status %d)", out, status)
+	}
+}
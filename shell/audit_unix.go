@@ -0,0 +1,13 @@
+//go:build unix
+
+package shell
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// openSyslog connects to the system log, for the audit records
+func openSyslog() (io.Writer, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, "goshell")
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package shell
+
+import (
+	"errors"
+	"io"
+)
+
+// openSyslog fails, as Windows has no syslog; audit to a file instead
+func openSyslog() (io.Writer, error) {
+	return nil, errors.New("syslog isn't available on Windows; set GOSHELL_AUDIT to a file")
+}
@@ -0,0 +1,320 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"goshell/internal/lineedit"
+)
+
+func init() {
+	registerBuiltin("bind", `bind [-lp] [-r KEYSEQ] ['"KEYSEQ": ACTION'...]`, "Bind keys to editor actions or text", builtinBind)
+	registerFlags("bind",
+		Candidate{"-l", "List the editor actions keys can be bound to"},
+		Candidate{"-p", "List the key bindings as bind commands"},
+		Candidate{"-r", "Remove a key's binding"})
+}
+
+// keyBinding is what a key is bound to: one of the editorActions, or a macro
+// whose text is typed in place of the key. A macro ending in a newline also
+// runs the line.
+type keyBinding struct {
+	action string
+	macro  string
+}
+
+// defaultBindings returns the shell-side bindings a session starts with.
+// Keys without one keep the line editor's emacs-style behaviour.
+func defaultBindings() map[lineedit.Key]keyBinding {
+	return map[lineedit.Key]keyBinding{
+		lineedit.Tab:                       {action: "complete"},
+		lineedit.CtrlK:                     {action: "kill-line"},
+		lineedit.CtrlR:                     {action: "fzf-history"},
+		lineedit.CtrlT:                     {action: "fzf-file"},
+		lineedit.CtrlU:                     {action: "backward-kill-line"},
+		lineedit.CtrlW:                     {action: "unix-word-rubout"},
+		lineedit.CtrlY:                     {action: "yank"},
+		lineedit.Meta | 'd':                {action: "kill-word"},
+		lineedit.Meta | lineedit.Backspace: {action: "backward-kill-word"},
+		lineedit.Meta | 'y':                {action: "yank-pop"},
+	}
+}
+
+// editorAction is a named editing action that keys can be bound to
+type editorAction struct {
+	summary string
+	run     func(e *lineEditor, line []rune, pos int) ([]rune, int)
+}
+
+// editorActions are the actions bind accepts, by name
+var editorActions = map[string]editorAction{
+	"complete":          {"Complete the word before the cursor", (*lineEditor).complete},
+	"accept-suggestion": {"Append the autosuggestion to the line", (*lineEditor).acceptSuggestion},
+	"beginning-of-line": {"Move to the start of the line", func(_ *lineEditor, line []rune, _ int) ([]rune, int) {
+		return line, 0
+	}},
+	"end-of-line": {"Move to the end of the line", func(_ *lineEditor, line []rune, _ int) ([]rune, int) {
+		return line, len(line)
+	}},
+	"backward-char": {"Move back a character", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		return line, max(pos-1, 0)
+	}},
+	"forward-char": {"Move forward a character", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		return line, min(pos+1, len(line))
+	}},
+	"backward-word": {"Move to the start of the previous word", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		return line, lineedit.BackwardWord(line, pos)
+	}},
+	"forward-word": {"Move to the end of the next word", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		return line, lineedit.ForwardWord(line, pos)
+	}},
+	"kill-line": {"Kill from the cursor to the end of the line", func(e *lineEditor, line []rune, pos int) ([]rune, int) {
+		return e.kill(line, pos, len(line), false)
+	}},
+	"backward-kill-line": {"Kill from the start of the line to the cursor", func(e *lineEditor, line []rune, pos int) ([]rune, int) {
+		return e.kill(line, 0, pos, true)
+	}},
+	"kill-word": {"Kill to the end of the next word", func(e *lineEditor, line []rune, pos int) ([]rune, int) {
+		return e.kill(line, pos, lineedit.ForwardWord(line, pos), false)
+	}},
+	"backward-kill-word": {"Kill to the start of the previous word", func(e *lineEditor, line []rune, pos int) ([]rune, int) {
+		return e.kill(line, lineedit.BackwardWord(line, pos), pos, true)
+	}},
+	"unix-word-rubout": {"Kill to the previous blank", func(e *lineEditor, line []rune, pos int) ([]rune, int) {
+		return e.kill(line, backwardBlank(line, pos), pos, true)
+	}},
+	"yank":     {"Insert the most recent kill", (*lineEditor).yank},
+	"yank-pop": {"Replace the text just yanked with the kill before it", (*lineEditor).yankPop},
+	"transpose-chars": {"Swap the characters before and at the cursor", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		if len(line) < 2 || pos == 0 {
+			return line, pos
+		}
+		// At the end of the line the last two characters are swapped
+		pos = min(pos, len(line)-1)
+		line = append([]rune(nil), line...)
+		line[pos-1], line[pos] = line[pos], line[pos-1]
+		return line, pos + 1
+	}},
+	"upcase-word": {"Uppercase to the end of the word", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		end := lineedit.ForwardWord(line, pos)
+		return replaceRunes(line, pos, end, strings.ToUpper(string(line[pos:end])))
+	}},
+	"downcase-word": {"Lowercase to the end of the word", func(_ *lineEditor, line []rune, pos int) ([]rune, int) {
+		end := lineedit.ForwardWord(line, pos)
+		return replaceRunes(line, pos, end, strings.ToLower(string(line[pos:end])))
+	}},
+}
+
+// runBinding handles a key bound with bind, returning what handleKey
+// passes on to the line editor
+func (e *lineEditor) runBinding(b keyBinding) (lineedit.Key, bool) {
+	if b.action != "" {
+		e.action = b.action
+		run := editorActions[b.action].run
+		e.apply(func(line []rune, pos int) ([]rune, int) {
+			return run(e, line, pos)
+		})
+		return 0, false
+	}
+	text, submit := strings.CutSuffix(b.macro, "\n")
+	e.apply(func(line []rune, pos int) ([]rune, int) {
+		return replaceRunes(line, pos, pos, text)
+	})
+	if submit {
+		return lineedit.Enter, true
+	}
+	return 0, false
+}
+
+// parseKeySeq parses a key in readline's notation: a character, \C-x for
+// Control, \M-x or \ex for Meta, or one of the escapes \t \e \\ \" and \'
+func parseKeySeq(seq string) (lineedit.Key, error) {
+	rest, meta := strings.CutPrefix(seq, `\M-`)
+	if !meta {
+		if after, ok := strings.CutPrefix(seq, `\e`); ok && after != "" {
+			rest, meta = after, true
+		}
+	}
+	if meta {
+		key, err := parseKeySeq(rest)
+		if err != nil || key&lineedit.Meta != 0 {
+			return 0, fmt.Errorf("invalid key: %s", seq)
+		}
+		return lineedit.Meta | key, nil
+	}
+
+	runes := []rune(seq)
+	switch {
+	case len(runes) == 1 && runes[0] != '\\':
+		return lineedit.Key(runes[0]), nil
+	case len(runes) == 4 && strings.HasPrefix(seq, `\C-`):
+		c := unicode.ToUpper(runes[3])
+		if c == '?' {
+			return lineedit.Backspace, nil
+		}
+		if c >= '@' && c <= '_' {
+			return lineedit.Key(c & 0x1f), nil
+		}
+	case len(runes) == 2 && runes[0] == '\\':
+		switch runes[1] {
+		case 't':
+			return lineedit.Tab, nil
+		case 'e':
+			return lineedit.Esc, nil
+		case '\\', '"', '\'':
+			return lineedit.Key(runes[1]), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid key: %s", seq)
+}
+
+// keyName returns the notation parseKeySeq reads for key
+func keyName(key lineedit.Key) string {
+	switch {
+	case key&lineedit.Meta != 0:
+		return `\M-` + keyName(key&^lineedit.Meta)
+	case key == lineedit.Tab:
+		return `\t`
+	case key == lineedit.Esc:
+		return `\e`
+	case key == lineedit.Backspace:
+		return `\C-?`
+	case key < ' ':
+		return `\C-` + string(unicode.ToLower(rune(key)+'@'))
+	case key == '\\' || key == '"':
+		return `\` + string(rune(key))
+	}
+	return string(rune(key))
+}
+
+// macroEscapes maps the escapes allowed in macro text to what they stand for
+var macroEscapes = map[byte]string{'n': "\n", 't': "\t", '\\': `\`, '"': `"`, '\'': "'"}
+
+// unescapeMacro decodes the escapes in a macro's text
+func unescapeMacro(text string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			b.WriteByte(text[i])
+			continue
+		}
+		if i+1 >= len(text) || macroEscapes[text[i+1]] == "" {
+			return "", fmt.Errorf("invalid escape in %q", text)
+		}
+		i++
+		b.WriteString(macroEscapes[text[i]])
+	}
+	return b.String(), nil
+}
+
+// escapeMacro is the inverse of unescapeMacro, for listing macros
+func escapeMacro(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(text)
+}
+
+// parseBinding parses a binding written as "KEYSEQ": ACTION, or with a
+// quoted macro text in place of ACTION
+func parseBinding(spec string) (lineedit.Key, keyBinding, error) {
+	invalid := fmt.Errorf("invalid binding: %s", spec)
+	if !strings.HasPrefix(spec, `"`) {
+		return 0, keyBinding{}, invalid
+	}
+	end := 1
+	for end < len(spec) && spec[end] != '"' {
+		if spec[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(spec) {
+		return 0, keyBinding{}, invalid
+	}
+	rest, ok := strings.CutPrefix(strings.TrimLeft(spec[end+1:], " \t"), ":")
+	if !ok {
+		return 0, keyBinding{}, invalid
+	}
+	key, err := parseKeySeq(spec[1:end])
+	if err != nil {
+		return 0, keyBinding{}, err
+	}
+
+	value := strings.TrimSpace(rest)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		macro, err := unescapeMacro(value[1 : len(value)-1])
+		if err != nil {
+			return 0, keyBinding{}, err
+		}
+		return key, keyBinding{macro: macro}, nil
+	}
+	if _, ok := editorActions[value]; !ok {
+		return 0, keyBinding{}, fmt.Errorf("unknown action: %s", value)
+	}
+	return key, keyBinding{action: value}, nil
+}
+
+// builtinBind binds keys to editor actions or macros. With no bindings to
+// add it lists the current ones in a form bind reads back.
+func builtinBind(s *Shell, args []string, stdio Stdio) int {
+	var listActions, printBindings bool
+	var remove []string
+	flags := newFlagSet("bind")
+	flags.Bool(&listActions, "l")
+	flags.Bool(&printBindings, "p")
+	flags.Func(func(value string) error {
+		remove = append(remove, value)
+		return nil
+	}, "r")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	if listActions {
+		names := make([]string, 0, len(editorActions))
+		for name := range editorActions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdio.Stdout, "%-20s %s\n", name, editorActions[name].summary)
+		}
+	}
+
+	status := 0
+	for _, seq := range remove {
+		key, err := parseKeySeq(seq)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "bind:", err)
+			status = 1
+			continue
+		}
+		delete(s.bindings, key)
+	}
+	for _, spec := range operands {
+		key, binding, err := parseBinding(spec)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "bind:", err)
+			status = 1
+			continue
+		}
+		s.bindings[key] = binding
+	}
+
+	if printBindings || len(args) == 1 {
+		lines := make([]string, 0, len(s.bindings))
+		for key, binding := range s.bindings {
+			value := binding.action
+			if value == "" {
+				value = `"` + escapeMacro(binding.macro) + `"`
+			}
+			lines = append(lines, fmt.Sprintf(`"%s": %s`, keyName(key), value))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Fprintln(stdio.Stdout, line)
+		}
+	}
+	return status
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+func TestParseKeySeq(t *testing.T) {
+	tests := []struct {
+		seq  string
+		want lineedit.Key
+	}{
+		{"a", 'a'},
+		{`\C-g`, lineedit.CtrlG},
+		{`\C-G`, lineedit.CtrlG},
+		{`\C-?`, lineedit.Backspace},
+		{`\t`, lineedit.Tab},
+		{`\e`, lineedit.Esc},
+		{`\"`, '"'},
+		{`\M-b`, lineedit.Meta | 'b'},
+		{`\ef`, lineedit.Meta | 'f'},
+		{`\M-\C-g`, lineedit.Meta | lineedit.CtrlG},
+	}
+	for _, tt := range tests {
+		got, err := parseKeySeq(tt.seq)
+		if err != nil || got != tt.want {
+			t.Errorf("parseKeySeq(%q) = %d, %v; want %d", tt.seq, got, err, tt.want)
+			continue
+		}
+		if back, _ := parseKeySeq(keyName(got)); back != got {
+			t.Errorf("keyName(%d) = %q doesn't parse back", got, keyName(got))
+		}
+	}
+	for _, seq := range []string{"", "ab", `\C-`, `\M-`, `\M-\M-x`, `\q`} {
+		if _, err := parseKeySeq(seq); err == nil {
+			t.Errorf("parseKeySeq(%q) succeeded, want an error", seq)
+		}
+	}
+}
+
+func TestBindBuiltin(t *testing.T) {
+	shell := NewShell()
+	out, status := runCapture(t, shell, `bind '"\C-g": "git status\n"' '"\C-t": transpose-chars'`)
+	if status != 0 {
+		t.Fatalf("bind status = %d, output %q", status, out)
+	}
+	if got := shell.bindings[lineedit.CtrlG]; got.macro != "git status\n" {
+		t.Errorf("Ctrl-G bound to %+v, want the git status macro", got)
+	}
+
+	out, _ = runCapture(t, shell, "bind")
+	for _, want := range []string{`"\C-g": "git status\n"`, `"\C-t": transpose-chars`, `"\t": complete`, `"\M-y": yank-pop`} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("bind listing %q is missing %s", out, want)
+		}
+	}
+
+	runCapture(t, shell, `bind -r '\C-t'`)
+	if _, ok := shell.bindings[lineedit.CtrlT]; ok {
+		t.Error("bind -r left Ctrl-T bound")
+	}
+
+	for _, spec := range []string{`"\C-g" complete`, `"\C-g": no-such-action`, `\C-g: complete`} {
+		if out, status := runCapture(t, shell, "bind '"+spec+"'"); status == 0 {
+			t.Errorf("bind %s succeeded, want an error", spec)
+		} else if !strings.HasPrefix(out, "bind: ") {
+			t.Errorf("bind %s error = %q", spec, out)
+		}
+	}
+
+	out, _ = runCapture(t, shell, "bind -l")
+	if !strings.Contains(out, "kill-line ") || !strings.Contains(out, "complete ") {
+		t.Errorf("bind -l = %q, want the action names", out)
+	}
+}
+
+func TestEditorBindings(t *testing.T) {
+	shell := NewShell()
+	editor := newTestEditor(shell)
+	runCapture(t, shell, `bind '"\C-k": kill-line' '"\C-o": "| less"' '"\C-g": "git status\n"'`)
+
+	line := []rune("echo one two")
+	line, pos := press(editor, line, 5, lineedit.CtrlK)
+	if string(line) != "echo " || pos != 5 {
+		t.Errorf("kill-line gave %q at %d", string(line), pos)
+	}
+
+	line, pos = press(editor, line, pos, lineedit.CtrlO)
+	if string(line) != "echo | less" || pos != 11 {
+		t.Errorf("macro gave %q at %d", string(line), pos)
+	}
+
+	editor.ed.SetBuffer([]rune("cd repo && "), 11)
+	if accepted, _ := editor.ed.HandleKey(lineedit.CtrlG); !accepted {
+		t.Error("a macro ending in a newline didn't accept the line")
+	}
+	if line, _ := editor.ed.Buffer(); string(line) != "cd repo && git status" {
+		t.Errorf("macro set the buffer to %q", string(line))
+	}
+}
@@ -0,0 +1,577 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stdio bundles the streams a command reads from and writes to
+type Stdio struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Set between builtins that pass records down a pipeline in place of
+	// text; see records.go
+	recordsIn  <-chan *record
+	recordsOut chan<- *record
+}
+
+// BuiltinFunc implements a builtin command. It receives the expanded argument
+// list, including the command name, and returns the command's exit status.
+type BuiltinFunc func(s *Shell, args []string, stdio Stdio) int
+
+// builtin describes a registered builtin command
+type builtin struct {
+	usage   string // synopsis shown by help, e.g. "cd [dir]"
+	summary string // one-line description shown by help
+	run     BuiltinFunc
+	flags   []Candidate // options offered by completion
+	// args completes the builtin's operands, for those that aren't files
+	args func(s *Shell, word string) []Candidate
+	// records is set for builtins that read or write records
+	records bool
+}
+
+// builtins maps command names to their implementations. Builtins register
+// themselves from init functions so each can live next to its helpers.
+var builtins = map[string]*builtin{}
+
+// registerBuiltin makes run available as the builtin command name
+func registerBuiltin(name, usage, summary string, run BuiltinFunc) {
+	builtins[name] = &builtin{usage: usage, summary: summary, run: run}
+}
+
+// registerFlags records the options a builtin accepts so they can be
+// offered, with their descriptions, by completion
+func registerFlags(name string, flags ...Candidate) {
+	builtins[name].flags = flags
+}
+
+// registerArgs sets how completion offers a builtin's operands, in place of
+// file names
+func registerArgs(name string, args func(s *Shell, word string) []Candidate) {
+	builtins[name].args = args
+}
+
+// isBuiltin reports whether name is a builtin command
+func isBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// builtinNames returns the names of all builtins in sorted order
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	registerBuiltin("cd", "cd [-L|-P] [dir | - | -N] | --list | --pick", "Change directory (default: HOME; -N: the Nth most recent)", builtinCd)
+	registerBuiltin("clear", "clear [-x]", "Clear the screen and scrollback (-x: keep the scrollback)", builtinClear)
+	registerBuiltin("echo", "echo [-neE] [args...]", "Print arguments (-n: no newline; -e: interpret escapes)", builtinEcho)
+	registerBuiltin("env", "env", "Display environment variables", builtinEnv)
+	registerBuiltin("exit", "exit", "Exit the shell", builtinExit)
+	registerBuiltin("export", "export [-p] [KEY=VALUE]", "Set environment variables", builtinExport)
+	registerBuiltin("help", "help", "Show this help message", builtinHelp)
+	registerBuiltin("history", "history [-rt] [search PATTERN] | -c | -d N | export|import FILE | doctor", "Show, search or edit command history (-r: as typed; -t: with times; -c: clear; -d: delete entry N; export/import: as JSON; doctor: repair the file)", builtinHistory)
+	registerBuiltin("ls", "ls [-aAhlrsSt] [--json] [dir]", "List directory contents with colorized output", builtinLs)
+	registerBuiltin("pwd", "pwd [-L|-P]", "Print working directory (-P: with symlinks resolved)", builtinPwd)
+	registerBuiltin("unset", "unset KEY...", "Remove environment variables", builtinUnset)
+
+	registerFlags("cd",
+		Candidate{"-", "Return to the previous directory"},
+		Candidate{"-L", "Follow symlinks logically (the default)"},
+		Candidate{"-P", "Resolve symlinks first"},
+		Candidate{"--list", "List the recent directories, numbered for cd -N"},
+		Candidate{"--pick", "Choose a recent directory from a menu"})
+	registerFlags("clear", Candidate{"-x", "Keep the scrollback, clearing only the screen"})
+	registerFlags("echo",
+		Candidate{"-n", "Don't print the trailing newline"},
+		Candidate{"-e", "Interpret backslash escapes"},
+		Candidate{"-E", "Print backslashes as they are"})
+	registerFlags("export", Candidate{"-p", "Print variables as export commands"})
+	registerFlags("history",
+		Candidate{"-r", "Show commands as typed"},
+		Candidate{"-t", "Show when each command ran"},
+		Candidate{"-c", "Clear the history"},
+		Candidate{"-d", "Delete the entry at a position"})
+	registerFlags("pwd",
+		Candidate{"-L", "Print the directory as reached through symlinks (the default)"},
+		Candidate{"-P", "Print the directory with symlinks resolved"})
+	registerFlags("ls",
+		Candidate{"-l", "Use a long listing format"},
+		Candidate{"-a", "Show hidden files, and . and .."},
+		Candidate{"-A", "Show hidden files, without . and .."},
+		Candidate{"-t", "Sort by modification time, newest first"},
+		Candidate{"-S", "Sort by size, largest first"},
+		Candidate{"-r", "Reverse the order"},
+		Candidate{"-h", "Show sizes in KiB, MiB and GiB"},
+		Candidate{"-s", "Show each file's size and a total"},
+		Candidate{"--json", "Write the entries as JSON"},
+		Candidate{"--help", "Show the system ls help"})
+}
+
+func builtinCd(s *Shell, args []string, stdio Stdio) int {
+	physical, list, pick := false, false, false
+	flags := newFlagSet("cd")
+	flags.Bool(&physical, "P")
+	flags.define(&flagDef{set: func(string) error {
+		physical = false
+		return nil
+	}}, []string{"L"})
+	flags.Bool(&list, "list")
+	flags.Bool(&pick, "pick")
+	operands := args[1:]
+	// -N names a recent directory rather than options
+	if len(operands) != 1 || !isDirIndex(operands[0]) {
+		var err error
+		if operands, err = flags.Parse(operands); err != nil {
+			return flags.fail(stdio, err)
+		}
+	}
+	if len(operands) > 1 || (list || pick) && len(operands) > 0 {
+		return flags.usage(stdio)
+	}
+	if list {
+		s.listDirHistory(stdio)
+		return 0
+	}
+	if s.remote != nil && !pick {
+		return s.remoteCd(operands, stdio)
+	}
+
+	path := s.env.Get("HOME")
+	if len(operands) == 1 {
+		path = operands[0]
+	}
+	switch {
+	case pick:
+		dir, err := s.pickDirHistory(stdio)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "cd:", err)
+			return 1
+		}
+		if dir == "" {
+			return 1
+		}
+		path = dir
+	case path == "-":
+		// "cd -" returns to the previous directory and prints it
+		path = s.env.Get("OLDPWD")
+		if path == "" {
+			fmt.Fprintln(stdio.Stderr, "cd: OLDPWD not set")
+			return 1
+		}
+		fmt.Fprintln(stdio.Stdout, path)
+	case isDirIndex(path):
+		// So does "cd -N" for the Nth most recent one
+		dir, err := s.recentDir(path)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "cd:", err)
+			return 1
+		}
+		path = dir
+		fmt.Fprintln(stdio.Stdout, path)
+	}
+	if err := s.changeDir(path, physical); err != nil {
+		fmt.Fprintln(stdio.Stderr, "Error changing directory:", err)
+		return 1
+	}
+	return 0
+}
+
+// changeDir changes the working directory as cd does, remembering the one
+// it leaves in OLDPWD for cd -
+func (s *Shell) changeDir(path string, physical bool) error {
+	previous, _ := s.Getwd()
+	if err := s.chdir(path, physical); err != nil {
+		return err
+	}
+	if previous != "" {
+		s.env.Set("OLDPWD", previous)
+		if s.subshell {
+			// The shell's own directory hasn't changed
+			return nil
+		}
+		s.rememberDir(previous)
+	}
+	if dir, err := s.Getwd(); err == nil && dir != previous {
+		s.updateDotenv(s.stdio())
+		s.fireEvent(event{name: "chpwd", data: dir}, s.stdio())
+	}
+	return nil
+}
+
+// Escape sequences that clear the terminal: the cursor goes home, the
+// screen is erased and, unless it is kept, the scrollback too
+const (
+	clearScreen     = "\033[H\033[2J"
+	clearScrollback = "\033[3J"
+)
+
+// builtinClear clears the terminal by writing the escape sequences for it
+// rather than running clear. -x keeps the scrollback, as clear -x does.
+func builtinClear(s *Shell, args []string, stdio Stdio) int {
+	var keep bool
+	flags := newFlagSet("clear")
+	flags.Bool(&keep, "x")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) > 0 {
+		return flags.usage(stdio)
+	}
+	text := clearScreen
+	if !keep {
+		text += clearScrollback
+	}
+	if _, err := io.WriteString(stdio.Stdout, text); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// builtinEcho prints its arguments. By default it takes bash's options: -n
+// drops the newline, -e turns on backslash escapes and -E turns them off.
+// With the posix_echo or posix option it follows POSIX instead, where every
+// argument is printed and escapes are always interpreted.
+func builtinEcho(s *Shell, args []string, stdio Stdio) int {
+	operands := args[1:]
+	newline, escapes := true, s.options["posix_echo"] || s.options["posix"]
+	if !escapes {
+		// Anything that isn't a known option is printed, but -- still ends them
+		flags := newFlagSet("echo")
+		flags.lenient = true
+		flags.define(&flagDef{set: func(string) error {
+			newline = false
+			return nil
+		}}, []string{"n"})
+		flags.define(&flagDef{set: func(string) error {
+			escapes = true
+			return nil
+		}}, []string{"e"})
+		flags.define(&flagDef{set: func(string) error {
+			escapes = false
+			return nil
+		}}, []string{"E"})
+		operands, _ = flags.Parse(operands)
+	}
+
+	text := strings.Join(operands, " ")
+	if escapes {
+		var stop bool
+		text, stop = echoEscapes(text)
+		// \c ends the output, newline included
+		newline = newline && !stop
+	}
+	if newline {
+		text += "\n"
+	}
+	io.WriteString(stdio.Stdout, text)
+	return 0
+}
+
+// echoEscapes interprets the backslash escapes echo -e understands, the same
+// ones printf does in its format. It reports whether a \c cut the text short.
+func echoEscapes(text string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch c := text[i]; c {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'c':
+			return b.String(), true
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\':
+			b.WriteByte('\\')
+		case '0', 'x', 'u', 'U':
+			// \0NNN is octal and \xHH, \uHHHH and \UHHHHHHHH are hex; each
+			// takes as many digits as are there, up to its limit
+			base, limit := 16, map[byte]int{'0': 3, 'x': 2, 'u': 4, 'U': 8}[c]
+			if c == '0' {
+				base = 8
+			}
+			j := i + 1
+			for j < len(text) && j-i-1 < limit && digitValue(text[j]) < base {
+				j++
+			}
+			if j == i+1 && c != '0' {
+				// No digits, so the backslash is kept as it is
+				b.WriteString(text[i-1 : i+1])
+				continue
+			}
+			n, _ := strconv.ParseUint(text[i+1:j], base, 32)
+			if c == 'u' || c == 'U' {
+				b.WriteRune(rune(n))
+			} else {
+				b.WriteByte(byte(n))
+			}
+			i = j - 1
+		default:
+			b.WriteString(text[i-1 : i+1])
+		}
+	}
+	return b.String(), false
+}
+
+// digitValue returns the value of hex digit c, or 16 if it isn't one
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return 16
+}
+
+func builtinEnv(s *Shell, args []string, stdio Stdio) int {
+	// Print all environment variables
+	return s.paged(stdio, func(stdio Stdio) int {
+		for _, env := range s.env.ToSlice() {
+			fmt.Fprintln(stdio.Stdout, env)
+		}
+		return 0
+	})
+}
+
+func builtinExport(s *Shell, args []string, stdio Stdio) int {
+	var print bool
+	flags := newFlagSet("export")
+	flags.Bool(&print, "p")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) == 0 && !print {
+		return builtinEnv(s, args, stdio)
+	}
+	// -p prints the variables in a form that can be read back in
+	if print {
+		return s.paged(stdio, func(stdio Stdio) int {
+			for _, env := range s.env.ToSlice() {
+				key, value, _ := strings.Cut(env, "=")
+				fmt.Fprintf(stdio.Stdout, "export %s=%s\n", key, shellQuote(value))
+			}
+			return 0
+		})
+	}
+	// Handle export KEY=VALUE
+	status := 0
+	for _, arg := range operands {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) == 2 {
+			s.env.Set(parts[0], parts[1])
+		} else {
+			fmt.Fprintf(stdio.Stderr, "Invalid export syntax: %s\n", arg)
+			status = 1
+		}
+	}
+	return status
+}
+
+// shellQuote single-quotes a value so the shell reads it back unchanged
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func builtinExit(s *Shell, args []string, stdio Stdio) int {
+	fmt.Fprintln(stdio.Stdout, "Goodbye!")
+	s.exiting = true
+	return s.lastStatus
+}
+
+func builtinHelp(s *Shell, args []string, stdio Stdio) int {
+	fmt.Fprintln(stdio.Stdout, s.HelpText())
+	return 0
+}
+
+func builtinHistory(s *Shell, args []string, stdio Stdio) int {
+	var raw, times, clear bool
+	deleted := 0
+	flags := newFlagSet("history")
+	flags.Bool(&raw, "r")
+	flags.Bool(&times, "t")
+	flags.Bool(&clear, "c")
+	flags.Func(func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n == 0 {
+			return fmt.Errorf("-d: invalid position: %s", value)
+		}
+		deleted = n
+		return nil
+	}, "d")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	var pattern *regexp.Regexp
+	switch {
+	case clear || deleted != 0:
+		if len(operands) > 0 || (clear && deleted != 0) {
+			return flags.usage(stdio)
+		}
+		if clear {
+			err = s.clearHistory()
+		} else {
+			err = s.deleteHistory(deleted)
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "history:", err)
+			return 1
+		}
+		return 0
+	case len(operands) == 1 && operands[0] == "doctor":
+		path := s.historyPath()
+		report, err := checkHistoryFile(path, true)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "history doctor:", err)
+			return 1
+		}
+		fmt.Fprintf(stdio.Stdout, "%s: %s\n", path, report)
+		return 0
+	case len(operands) == 2 && operands[0] == "export":
+		if err := historyExport(s, operands[1], stdio); err != nil {
+			fmt.Fprintln(stdio.Stderr, "history export:", err)
+			return 1
+		}
+		return 0
+	case len(operands) == 2 && operands[0] == "import":
+		n, err := historyImport(s, operands[1], stdio)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "history import:", err)
+			return 1
+		}
+		fmt.Fprintf(stdio.Stdout, "Imported %d entries\n", n)
+		return 0
+	case len(operands) == 2 && operands[0] == "search":
+		if pattern, err = regexp.Compile(operands[1]); err != nil {
+			fmt.Fprintln(stdio.Stderr, "history: bad pattern:", err)
+			return 1
+		}
+	case len(operands) > 0:
+		return flags.usage(stdio)
+	}
+
+	// Times are shown with -t or when HISTTIMEFORMAT is set, as in bash
+	format := s.env.Get("HISTTIMEFORMAT")
+	if times && format == "" {
+		format = defaultHistTimeFormat
+	}
+	blank := strings.Repeat(" ", len(formatHistoryTime(format, time.Now())))
+	return s.paged(stdio, func(stdio Stdio) int {
+		found := false
+		for i, entry := range s.HistoryEntries() {
+			cmd := entry.Command
+			if raw && entry.Raw != "" {
+				cmd = entry.Raw
+			}
+			if pattern != nil {
+				if !pattern.MatchString(cmd) {
+					continue
+				}
+				found = true
+				if isTerminal(stdio.Stdout) {
+					cmd = highlightMatches(pattern, cmd)
+				}
+			}
+			stamp := blank
+			if !entry.Time.IsZero() {
+				stamp = formatHistoryTime(format, entry.Time)
+			}
+			fmt.Fprintf(stdio.Stdout, "%d  %s%s\n", i+1, stamp, cmd)
+		}
+		// A search finding nothing fails, as grep does
+		if pattern != nil && !found {
+			return 1
+		}
+		return 0
+	})
+}
+
+// highlightMatches shows the text pattern matches in text in bold red, as
+// grep does
+func highlightMatches(pattern *regexp.Regexp, text string) string {
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "" {
+			return match
+		}
+		return Bold + Red + match + Reset
+	})
+}
+
+func builtinPwd(s *Shell, args []string, stdio Stdio) int {
+	physical := false
+	flags := newFlagSet("pwd")
+	flags.Bool(&physical, "P")
+	flags.define(&flagDef{set: func(string) error {
+		physical = false
+		return nil
+	}}, []string{"L"})
+	if operands, err := flags.Parse(args[1:]); err != nil {
+		return flags.fail(stdio, err)
+	} else if len(operands) > 0 {
+		return flags.usage(stdio)
+	}
+	if s.remote != nil {
+		fmt.Fprintln(stdio.Stdout, s.remote.cwd)
+		return 0
+	}
+	dir, err := s.Getwd()
+	if physical {
+		dir, err = physicalWd()
+	}
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "Error getting working directory:", err)
+		return 1
+	}
+	fmt.Fprintln(stdio.Stdout, dir)
+	return 0
+}
+
+func builtinUnset(s *Shell, args []string, stdio Stdio) int {
+	flags := newFlagSet("unset")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) == 0 {
+		return flags.usage(stdio)
+	}
+	for _, key := range operands {
+		s.env.Unset(key)
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package shell
+
+import (
+	"sync"
+	"time"
+)
+
+// flightCache memoizes slow lookups, such as directory scans and the output
+// of generator commands, for a fixed time. Concurrent requests for a key share
+// a single computation, so a burst of key presses or prompts never runs the
+// same work more than once at a time.
+type flightCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*cacheEntry[V]
+}
+
+// cacheEntry is the cached result for one key
+type cacheEntry[V any] struct {
+	value   V
+	err     error
+	fetched time.Time
+	loaded  bool
+	loading chan struct{} // set while the value is being computed, closed when done
+}
+
+// newFlightCache creates a cache whose values are fresh for ttl
+func newFlightCache[V any](ttl time.Duration) *flightCache[V] {
+	return &flightCache[V]{ttl: ttl, entries: make(map[string]*cacheEntry[V])}
+}
+
+// Get returns the value for key, computing it with fn when it is missing or
+// stale. Callers that arrive while it is being computed wait for that result.
+func (c *flightCache[V]) Get(key string, fn func() (V, error)) (V, error) {
+	c.mu.Lock()
+	e := c.entry(key)
+	if e.loaded && time.Since(e.fetched) < c.ttl {
+		defer c.mu.Unlock()
+		return e.value, e.err
+	}
+	done := c.load(e, fn)
+	c.mu.Unlock()
+
+	<-done
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return e.value, e.err
+}
+
+// Peek returns the cached value for key without waiting. A stale value is
+// returned while a refresh runs in the background; ok is false until the
+// first value has been computed.
+func (c *flightCache[V]) Peek(key string, fn func() (V, error)) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entry(key)
+	if !e.loaded || time.Since(e.fetched) >= c.ttl {
+		c.load(e, fn)
+	}
+	return e.value, e.loaded && e.err == nil
+}
+
+// Clear drops every cached value, so each key is computed afresh when it is
+// next requested
+func (c *flightCache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cacheEntry[V])
+}
+
+// entry returns the entry for key, creating an empty one. c.mu must be held.
+func (c *flightCache[V]) entry(key string) *cacheEntry[V] {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry[V]{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// load starts computing an entry's value unless that is already under way,
+// and returns a channel that is closed once it is stored. c.mu must be held.
+func (c *flightCache[V]) load(e *cacheEntry[V], fn func() (V, error)) chan struct{} {
+	if e.loading != nil {
+		return e.loading
+	}
+	done := make(chan struct{})
+	e.loading = done
+	go func() {
+		value, err := fn()
+		c.mu.Lock()
+		e.value, e.err = value, err
+		e.fetched = time.Now()
+		e.loaded = true
+		e.loading = nil
+		c.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
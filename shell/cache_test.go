@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightCacheSingleFlight(t *testing.T) {
+	cache := newFlightCache[int](time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.Get("key", fn)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+	for i, got := range results {
+		if got != 42 {
+			t.Errorf("result %d = %d, want 42", i, got)
+		}
+	}
+	if got, _ := cache.Get("key", fn); got != 42 || calls.Load() != 1 {
+		t.Errorf("fresh Get() = %d after %d calls, want the cached 42", got, calls.Load())
+	}
+}
+
+func TestFlightCacheStale(t *testing.T) {
+	cache := newFlightCache[string](10 * time.Millisecond)
+	value := "old"
+	var mu sync.Mutex
+	fn := func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return value, nil
+	}
+
+	if _, ok := cache.Peek("key", fn); ok {
+		t.Fatal("Peek() reported a value before one was computed")
+	}
+	if got, _ := cache.Get("key", fn); got != "old" {
+		t.Fatalf("Get() = %q, want old", got)
+	}
+
+	mu.Lock()
+	value = "new"
+	mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+
+	// A stale value is still served while it is refreshed
+	if got, ok := cache.Peek("key", fn); !ok || got != "old" {
+		t.Errorf("stale Peek() = %q, %v, want old", got, ok)
+	}
+	if got, _ := cache.Get("key", fn); got != "new" {
+		t.Errorf("Get() after expiry = %q, want new", got)
+	}
+}
@@ -0,0 +1,505 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerBuiltin("calc", "calc [-xb] [EXPR...]", "Evaluate arithmetic with floats, hex and binary, functions and units", builtinCalc)
+	registerFlags("calc",
+		Candidate{"-x", "Show results in hexadecimal"},
+		Candidate{"-b", "Show results in binary"})
+}
+
+// calc evaluates floating point arithmetic: + - * / % and ^ or ** for
+// powers, bitwise & | << >> on whole numbers, parentheses, decimal, 0x hex,
+// 0b binary and 0o octal literals, the constants pi and e, the functions in
+// calcFunctions and size units such as 4KiB or 1.5GB. An expression can end
+// with "in UNIT" to give its result in that unit, and name = EXPR stores a
+// result for later lines, as does ans, the last result.
+
+// calcFunctions are the functions calc knows, by name and argument count
+var calcFunctions = map[string]struct {
+	args int
+	fn   func(x []float64) float64
+}{
+	"sqrt":  {1, func(x []float64) float64 { return math.Sqrt(x[0]) }},
+	"cbrt":  {1, func(x []float64) float64 { return math.Cbrt(x[0]) }},
+	"abs":   {1, func(x []float64) float64 { return math.Abs(x[0]) }},
+	"floor": {1, func(x []float64) float64 { return math.Floor(x[0]) }},
+	"ceil":  {1, func(x []float64) float64 { return math.Ceil(x[0]) }},
+	"round": {1, func(x []float64) float64 { return math.Round(x[0]) }},
+	"trunc": {1, func(x []float64) float64 { return math.Trunc(x[0]) }},
+	"exp":   {1, func(x []float64) float64 { return math.Exp(x[0]) }},
+	"ln":    {1, func(x []float64) float64 { return math.Log(x[0]) }},
+	"log":   {1, func(x []float64) float64 { return math.Log10(x[0]) }},
+	"log2":  {1, func(x []float64) float64 { return math.Log2(x[0]) }},
+	"sin":   {1, func(x []float64) float64 { return math.Sin(x[0]) }},
+	"cos":   {1, func(x []float64) float64 { return math.Cos(x[0]) }},
+	"tan":   {1, func(x []float64) float64 { return math.Tan(x[0]) }},
+	"asin":  {1, func(x []float64) float64 { return math.Asin(x[0]) }},
+	"acos":  {1, func(x []float64) float64 { return math.Acos(x[0]) }},
+	"atan":  {1, func(x []float64) float64 { return math.Atan(x[0]) }},
+	"atan2": {2, func(x []float64) float64 { return math.Atan2(x[0], x[1]) }},
+	"pow":   {2, func(x []float64) float64 { return math.Pow(x[0], x[1]) }},
+	"hypot": {2, func(x []float64) float64 { return math.Hypot(x[0], x[1]) }},
+	"min":   {2, func(x []float64) float64 { return math.Min(x[0], x[1]) }},
+	"max":   {2, func(x []float64) float64 { return math.Max(x[0], x[1]) }},
+}
+
+// calcUnits are the size units numbers can carry, binary and decimal
+var calcUnits = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40, "PiB": 1 << 50,
+	"KB": 1e3, "kB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12, "PB": 1e15,
+	"K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40,
+}
+
+// calculator holds the variables of a calc session
+type calculator struct {
+	vars map[string]float64
+}
+
+func newCalculator() *calculator {
+	return &calculator{vars: map[string]float64{"pi": math.Pi, "e": math.E}}
+}
+
+// builtinCalc evaluates the expression its arguments make up, or each line
+// of its input if there are none, with a prompt when that is a terminal
+func builtinCalc(s *Shell, args []string, stdio Stdio) int {
+	base := 10
+	flags := newFlagSet("calc")
+	flags.define(&flagDef{set: func(string) error { base = 16; return nil }}, []string{"x"})
+	flags.define(&flagDef{set: func(string) error { base = 2; return nil }}, []string{"b"})
+	// A leading minus sign belongs to the expression, as in calc -2*3
+	flags.lenient = true
+	words, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	calc := newCalculator()
+	if len(words) > 0 {
+		result, err := calc.eval(strings.Join(words, " "))
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "calc:", err)
+			return 1
+		}
+		fmt.Fprintln(stdio.Stdout, formatCalc(result, base))
+		return 0
+	}
+
+	interactive := isTerminal(stdio.Stdin) && isTerminal(stdio.Stdout)
+	reader := bufio.NewReader(stdio.Stdin)
+	status := 0
+	for {
+		if interactive {
+			fmt.Fprint(stdio.Stdout, "calc> ")
+		}
+		line, err := readLine(reader)
+		if err != nil {
+			if interactive && err == io.EOF {
+				fmt.Fprintln(stdio.Stdout)
+			}
+			return status
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return status
+		}
+		result, err := calc.eval(line)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "calc:", err)
+			status = 1
+			continue
+		}
+		fmt.Fprintln(stdio.Stdout, formatCalc(result, base))
+	}
+}
+
+// formatCalc formats a result: whole numbers without a fraction, others to
+// twelve significant digits, so that 0.1+0.2 shows as 0.3. In base 16 or 2
+// the whole part is shown with its prefix.
+func formatCalc(v float64, base int) string {
+	if base != 10 && !math.IsInf(v, 0) && !math.IsNaN(v) && math.Abs(v) < 1<<63 {
+		n := int64(v)
+		sign := ""
+		if n < 0 {
+			sign, n = "-", -n
+		}
+		prefix := map[int]string{16: "0x", 2: "0b"}[base]
+		return sign + prefix + strconv.FormatInt(n, base)
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', 12, 64)
+}
+
+// eval evaluates a line: an expression, perhaps converted with "in UNIT",
+// or an assignment to a variable. The result is kept as ans.
+func (c *calculator) eval(line string) (float64, error) {
+	tokens, err := lexCalc(line)
+	if err != nil {
+		return 0, err
+	}
+	p := &calcParser{tokens: tokens, calc: c}
+	target := ""
+	if len(tokens) > 2 && tokens[0].kind == calcName && tokens[1].text == "=" {
+		target = tokens[0].text
+		p.pos = 2
+	}
+	result, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek().kind == calcName && (p.peek().text == "in" || p.peek().text == "to") {
+		p.pos++
+		unit := p.next()
+		scale, ok := calcUnits[unit.text]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q", unit.text)
+		}
+		result /= scale
+	}
+	if tok := p.peek(); tok.kind != calcEnd {
+		return 0, fmt.Errorf("unexpected %q", tok.text)
+	}
+	if target != "" {
+		c.vars[target] = result
+	}
+	c.vars["ans"] = result
+	return result, nil
+}
+
+// calcTokenKind is what sort of token a calcToken is
+type calcTokenKind int
+
+const (
+	calcEnd calcTokenKind = iota
+	calcNumber
+	calcName
+	calcOp
+)
+
+type calcToken struct {
+	kind  calcTokenKind
+	text  string
+	value float64 // for numbers
+}
+
+// lexCalc splits an expression into tokens. A number followed directly by a
+// unit, as in 4KiB, is scaled by it.
+func lexCalc(src string) ([]calcToken, error) {
+	var tokens []calcToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			end := i + 1
+			for end < len(src) && (isWordByte(src[end]) || src[end] == '.' ||
+				(src[end] == '+' || src[end] == '-') && (src[end-1] == 'e' || src[end-1] == 'E') && !strings.HasPrefix(src[i:], "0x")) {
+				end++
+			}
+			text := src[i:end]
+			value, err := parseCalcNumber(text)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, calcToken{kind: calcNumber, text: text, value: value})
+			i = end
+		case unicode.IsLetter(rune(c)) || c == '_':
+			end := i + 1
+			for end < len(src) && isWordByte(src[end]) {
+				end++
+			}
+			tokens = append(tokens, calcToken{kind: calcName, text: src[i:end]})
+			i = end
+		default:
+			op := string(c)
+			for _, two := range []string{"**", "<<", ">>"} {
+				if strings.HasPrefix(src[i:], two) {
+					op = two
+				}
+			}
+			if len(op) == 1 && !strings.Contains("+-*/%^&|()=,", op) {
+				return nil, fmt.Errorf("unexpected %q", op)
+			}
+			tokens = append(tokens, calcToken{kind: calcOp, text: op})
+			i += len(op)
+		}
+	}
+	return append(tokens, calcToken{kind: calcEnd, text: "end of expression"}), nil
+}
+
+// parseCalcNumber parses a number literal: decimal, possibly with an
+// exponent, or 0x, 0b or 0o whole numbers, each perhaps with a unit
+func parseCalcNumber(text string) (float64, error) {
+	lower := strings.ToLower(text)
+	for prefix, base := range map[string]int{"0x": 16, "0b": 2, "0o": 8} {
+		if digits, ok := strings.CutPrefix(lower, prefix); ok {
+			n, err := strconv.ParseUint(digits, base, 64)
+			if err != nil {
+				return 0, fmt.Errorf("bad number %q", text)
+			}
+			return float64(n), nil
+		}
+	}
+	// The unit is whatever follows the number
+	end := 0
+	for end < len(text) && (text[end] >= '0' && text[end] <= '9' || text[end] == '.' ||
+		(text[end] == 'e' || text[end] == 'E') && end+1 < len(text) && strings.ContainsRune("0123456789+-", rune(text[end+1])) ||
+		(text[end] == '+' || text[end] == '-') && end > 0 && (text[end-1] == 'e' || text[end-1] == 'E')) {
+		end++
+	}
+	n, err := strconv.ParseFloat(text[:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad number %q", text)
+	}
+	if unit := text[end:]; unit != "" {
+		scale, ok := calcUnits[unit]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q in %q", unit, text)
+		}
+		n *= scale
+	}
+	return n, nil
+}
+
+// calcParser evaluates tokens by recursive descent, from the loosest
+// binding operators to the tightest
+type calcParser struct {
+	tokens []calcToken
+	pos    int
+	calc   *calculator
+}
+
+func (p *calcParser) peek() calcToken { return p.tokens[p.pos] }
+
+func (p *calcParser) next() calcToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != calcEnd {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of ops
+func (p *calcParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind == calcOp {
+		for _, op := range ops {
+			if tok.text == op {
+				p.pos++
+				return op, true
+			}
+		}
+	}
+	return "", false
+}
+
+// errNotWhole is returned for bitwise operations on fractions
+var errNotWhole = errors.New("bitwise operations need whole numbers")
+
+// expr parses bitwise or and and, the loosest operators
+func (p *calcParser) expr() (float64, error) {
+	left, err := p.shift()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.accept("|", "&")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.shift()
+		if err != nil {
+			return 0, err
+		}
+		if left != math.Trunc(left) || right != math.Trunc(right) {
+			return 0, errNotWhole
+		}
+		if op == "|" {
+			left = float64(int64(left) | int64(right))
+		} else {
+			left = float64(int64(left) & int64(right))
+		}
+	}
+}
+
+// shift parses << and >>
+func (p *calcParser) shift() (float64, error) {
+	left, err := p.additive()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.accept("<<", ">>")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.additive()
+		if err != nil {
+			return 0, err
+		}
+		if left != math.Trunc(left) || right != math.Trunc(right) || right < 0 || right > 63 {
+			return 0, errNotWhole
+		}
+		if op == "<<" {
+			left = float64(int64(left) << uint(right))
+		} else {
+			left = float64(int64(left) >> uint(right))
+		}
+	}
+}
+
+// additive parses + and -
+func (p *calcParser) additive() (float64, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.multiplicative()
+		if err != nil {
+			return 0, err
+		}
+		if op == "+" {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+// multiplicative parses *, / and %
+func (p *calcParser) multiplicative() (float64, error) {
+	left, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "*":
+			left *= right
+		case "/":
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			left /= right
+		case "%":
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+// unary parses a leading sign, which binds less tightly than powers, so
+// that -2^2 is -4
+func (p *calcParser) unary() (float64, error) {
+	if op, ok := p.accept("-", "+"); ok {
+		v, err := p.unary()
+		if op == "-" {
+			v = -v
+		}
+		return v, err
+	}
+	return p.power()
+}
+
+// power parses ^ and **, which group from the right
+func (p *calcParser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := p.accept("^", "**"); !ok {
+		return base, nil
+	}
+	exponent, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+// primary parses a number, a variable, a function call or a parenthesized
+// expression
+func (p *calcParser) primary() (float64, error) {
+	tok := p.next()
+	switch tok.kind {
+	case calcNumber:
+		return tok.value, nil
+	case calcName:
+		if f, ok := calcFunctions[tok.text]; ok {
+			return p.call(tok.text, f.args, f.fn)
+		}
+		if v, ok := p.calc.vars[tok.text]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("unknown name %q", tok.text)
+	case calcOp:
+		if tok.text == "(" {
+			v, err := p.expr()
+			if err != nil {
+				return 0, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return 0, errors.New("missing )")
+			}
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unexpected %q", tok.text)
+}
+
+// call parses the arguments of a function and calls it
+func (p *calcParser) call(name string, want int, fn func([]float64) float64) (float64, error) {
+	if _, ok := p.accept("("); !ok {
+		return 0, fmt.Errorf("%s needs its arguments in parentheses", name)
+	}
+	var args []float64
+	for {
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if _, ok := p.accept(")"); !ok {
+		return 0, errors.New("missing )")
+	}
+	if len(args) != want {
+		return 0, fmt.Errorf("%s takes %d %s", name, want, plural(want, "argument", "arguments"))
+	}
+	return fn(args), nil
+}
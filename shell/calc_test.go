@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCalcEval(t *testing.T) {
+	for expr, want := range map[string]float64{
+		"3*(4.5+1)":             16.5,
+		"1 + 2 * 3":             7,
+		"-2^2":                  -4,
+		"2^3^2":                 512,
+		"2 ** 10":               1024,
+		"7 % 4":                 3,
+		"0xff + 0b101":          260,
+		"0o17":                  15,
+		"1e3 + 2.5e-1":          1000.25,
+		".5 * 4":                2,
+		"sqrt(16) + abs(-2)":    6,
+		"max(3, 7) - min(3, 7)": 4,
+		"round(pi * 100)":       314,
+		"ln(e)":                 1,
+		"1 << 10 | 1":           1025,
+		"0xf0 & 0x3c":           0x30,
+		"4KiB":                  4096,
+		"1.5GiB in MiB":         1536,
+		"2GB to MB":             2000,
+	} {
+		got, err := newCalculator().eval(expr)
+		if err != nil || math.Abs(got-want) > 1e-9 {
+			t.Errorf("calc %s = %v, %v, want %v", expr, got, err, want)
+		}
+	}
+
+	for _, expr := range []string{"", "1 +", "(1", "1 / 0", "foo", "sqrt 4", "pow(2)", "1.5 | 1", "4XB", "0xg", "1 2", "3 in parsecs"} {
+		if got, err := newCalculator().eval(expr); err == nil {
+			t.Errorf("calc %q = %v, want an error", expr, got)
+		}
+	}
+}
+
+func TestCalcVariables(t *testing.T) {
+	calc := newCalculator()
+	for _, line := range []string{"rate = 1.5", "ans * 2"} {
+		if _, err := calc.eval(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := calc.eval("rate + ans"); err != nil || got != 4.5 {
+		t.Errorf("rate + ans = %v, %v, want 4.5", got, err)
+	}
+}
+
+func TestFormatCalc(t *testing.T) {
+	for _, tt := range []struct {
+		v    float64
+		base int
+		want string
+	}{
+		{0.1 + 0.2, 10, "0.3"},
+		{42, 10, "42"},
+		{-7, 10, "-7"},
+		{1.0 / 3, 10, "0.333333333333"},
+		{1e20, 10, "1e+20"},
+		{255, 16, "0xff"},
+		{-10, 2, "-0b1010"},
+	} {
+		if got := formatCalc(tt.v, tt.base); got != tt.want {
+			t.Errorf("formatCalc(%v, %d) = %q, want %q", tt.v, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestCalcBuiltin(t *testing.T) {
+	shell := NewShell()
+	if out, status := runCapture(t, shell, "calc -x '(1 << 8) - 1'"); out != "0xff\n" || status != 0 {
+		t.Errorf("calc -x = %q (status %d)", out, status)
+	}
+	if out, _ := runCapture(t, shell, "calc -3 + 1"); out != "-2\n" {
+		t.Errorf("calc -3 + 1 = %q", out)
+	}
+	out, status := runCapture(t, shell, "printf 'x = 4\\nx * 2\\n\\nnope\\n' | calc")
+	if !strings.HasPrefix(out, "4\n8\n") || !strings.Contains(out, `calc: unknown name "nope"`) || status != 1 {
+		t.Errorf("calc reading lines = %q (status %d)", out, status)
+	}
+}
@@ -0,0 +1,144 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+func init() {
+	registerBuiltin("cat", "cat [-n] [file...]", "Print files, with code highlighted on a terminal", builtinCat)
+	registerFlags("cat", Candidate{"-n", "Number the lines"})
+	registerBuiltin("view", "view [file...]", "Show files with highlighting and line numbers, through the pager", builtinView)
+}
+
+// catOptions are how cat and view print files
+type catOptions struct {
+	number bool // number the lines, counting on across files
+}
+
+// builtinCat prints files, or its input if there are none, as cat does. On a
+// terminal, files in a language it knows by their name are highlighted, as
+// bat does; anywhere else the bytes go out untouched. -n numbers the lines.
+// Options it doesn't know are left to the system's cat.
+func builtinCat(s *Shell, args []string, stdio Stdio) int {
+	var opts catOptions
+	flags := newFlagSet("cat")
+	flags.Bool(&opts.number, "n")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return s.runSystem(args, stdio)
+	}
+	return s.catFiles(files, opts, stdio)
+}
+
+// builtinView shows files highlighted and with their lines numbered,
+// through the pager when they don't fit on the terminal
+func builtinView(s *Shell, args []string, stdio Stdio) int {
+	files := args[1:]
+	return s.paged(stdio, func(stdio Stdio) int {
+		return s.catFiles(files, catOptions{number: true}, stdio)
+	})
+}
+
+// catFiles prints each of files in turn, - or no files at all meaning
+// stdin. A file that can't be read is reported and skipped.
+func (s *Shell) catFiles(files []string, opts catOptions, stdio Stdio) int {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	printer := catPrinter{out: out, opts: opts, terminal: isTerminal(stdio.Stdout)}
+
+	status := 0
+	for _, file := range files {
+		if file == "-" {
+			if err := printer.print(stdio.Stdin, nil); err != nil {
+				fmt.Fprintln(stdio.Stderr, "cat:", err)
+				status = 1
+			}
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "cat:", err)
+			status = 1
+			continue
+		}
+		err = printer.print(f, syntaxFor(file))
+		f.Close()
+		if errors.Is(err, syscall.EPIPE) {
+			// Whatever was reading, such as head, has all it wants
+			return status
+		}
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "cat: %s: %v\n", file, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// catPrinter writes files out for cat, keeping the line count across them
+type catPrinter struct {
+	out      *bufio.Writer
+	opts     catOptions
+	terminal bool
+	line     int // the number of the last line printed
+}
+
+// print writes input out, highlighted as sx if it isn't nil and the output
+// is a terminal. Binary files are never highlighted.
+func (p *catPrinter) print(input io.Reader, sx *syntax) error {
+	if !p.terminal && !p.opts.number {
+		_, err := io.Copy(p.out, input)
+		return err
+	}
+	reader := bufio.NewReader(input)
+	if sx != nil && (!p.terminal || isBinary(reader)) {
+		sx = nil
+	}
+	inComment := false
+	for {
+		text, err := reader.ReadString('\n')
+		if text == "" {
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		line, newline := text, ""
+		if line[len(line)-1] == '\n' {
+			line, newline = line[:len(line)-1], "\n"
+		}
+		if p.opts.number {
+			p.line++
+			if p.terminal {
+				fmt.Fprintf(p.out, "%s%6d%s\t", Dim, p.line, Reset)
+			} else {
+				fmt.Fprintf(p.out, "%6d\t", p.line)
+			}
+		}
+		if sx != nil {
+			line = sx.highlight(line, &inComment)
+		}
+		p.out.WriteString(line + newline)
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+}
+
+// isBinary reports whether the start of the input has a NUL byte in it,
+// which text never has
+func isBinary(reader *bufio.Reader) bool {
+	start, _ := reader.Peek(8000)
+	return bytes.IndexByte(start, 0) >= 0
+}
@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCat(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "main.go")
+	second := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(first, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("no newline"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	// Output that isn't a terminal is never highlighted
+	out, status := runCapture(t, shell, "cat "+first+" "+second)
+	if want := "package main\n\nfunc main() {}\nno newline"; out != want || status != 0 {
+		t.Errorf("cat = %q (status %d), want %q", out, status, want)
+	}
+
+	out, _ = runCapture(t, shell, "cat -n "+first+" "+second)
+	want := "     1\tpackage main\n     2\t\n     3\tfunc main() {}\n     4\tno newline"
+	if out != want {
+		t.Errorf("cat -n = %q, want %q", out, want)
+	}
+
+	out, _ = runCapture(t, shell, "echo hi | cat - "+second)
+	if out != "hi\nno newline" {
+		t.Errorf("cat - = %q", out)
+	}
+
+	if _, status := runCapture(t, shell, "cat "+filepath.Join(dir, "missing")+" "+second); status != 1 {
+		t.Errorf("cat with a missing file exited with %d, want 1", status)
+	}
+}
+
+func TestCatHighlight(t *testing.T) {
+	var out pagedOutput
+	writer := bufio.NewWriter(&out)
+	printer := catPrinter{out: writer, terminal: true}
+	if err := printer.print(strings.NewReader("x := \"a\" // note\n"), syntaxFor("main.go")); err != nil {
+		t.Fatal(err)
+	}
+	writer.Flush()
+	want := "x := " + synString + "\"a\"" + Reset + " " + synComment + "// note" + Reset + "\n"
+	if out.String() != want {
+		t.Errorf("highlighted = %q, want %q", out.String(), want)
+	}
+
+	// Binary files go out untouched
+	out.Reset()
+	if err := printer.print(strings.NewReader("func\x00\n"), goSyntax); err != nil {
+		t.Fatal(err)
+	}
+	writer.Flush()
+	if out.String() != "func\x00\n" {
+		t.Errorf("binary = %q", out.String())
+	}
+}
+
+func TestSyntaxHighlight(t *testing.T) {
+	inComment := false
+	for _, tt := range []struct {
+		sx   *syntax
+		line string
+		want string
+	}{
+		{goSyntax, "return 42", synKeyword + "return" + Reset + " " + synNumber + "42" + Reset},
+		{goSyntax, "a /* start", "a " + synComment + "/*" + Reset + synComment + " start" + Reset},
+		{goSyntax, "end */ b", synComment + "end */" + Reset + " b"},
+		{pythonSyntax, "'#' # c", synString + "'#'" + Reset + " " + synComment + "# c" + Reset},
+		{sqlSyntax, "SELECT x", synKeyword + "SELECT" + Reset + " x"},
+		{cSyntax, "#include <a.h>", synKeyword + "#include" + Reset + " <a.h>"},
+		{markdownSyntax, "# Title", synHeading + "# Title" + Reset},
+	} {
+		if got := tt.sx.highlight(tt.line, &inComment); got != tt.want {
+			t.Errorf("highlight(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+	if inComment {
+		t.Error("block comment left open")
+	}
+}
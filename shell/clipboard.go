@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+func init() {
+	registerBuiltin("clip", "clip [file...]", "Copy files or stdin to the clipboard", builtinClip)
+	registerBuiltin("paste", "paste", "Print the clipboard; with arguments, the system's paste merges lines", builtinPaste)
+}
+
+// errNoClipboard is returned where there is no clipboard to reach, such as
+// on a Linux console or over ssh without X forwarding
+var errNoClipboard = errors.New("no clipboard available")
+
+// builtinClip copies its files, or its input, to the clipboard, so that
+// pwd | clip works the same on every platform. Where there is no clipboard
+// but stdout is a terminal, the text is sent to the terminal's own clipboard
+// with OSC 52, which reaches the local machine even over ssh in most
+// terminals.
+func builtinClip(s *Shell, args []string, stdio Stdio) int {
+	var data bytes.Buffer
+	files := args[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		if err := copyInput(&data, file, stdio.Stdin); err != nil {
+			fmt.Fprintln(stdio.Stderr, "clip:", err)
+			return 1
+		}
+	}
+	err := writeClipboard(data.Bytes())
+	if errors.Is(err, errNoClipboard) && isTerminal(stdio.Stdout) {
+		fmt.Fprintf(stdio.Stdout, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString(data.Bytes()))
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "clip:", err)
+		return 1
+	}
+	return 0
+}
+
+// builtinPaste prints the clipboard. With arguments it is the POSIX paste,
+// which merges the lines of files, and is left to the system.
+func builtinPaste(s *Shell, args []string, stdio Stdio) int {
+	if len(args) > 1 {
+		return s.runSystem(args, stdio)
+	}
+	data, err := readClipboard()
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "paste:", err)
+		return 1
+	}
+	stdio.Stdout.Write(data)
+	return 0
+}
+
+// clipboardTool is a command that reads or writes a clipboard
+type clipboardTool struct {
+	copy  []string // reads the text to copy from stdin
+	paste []string // writes the clipboard to stdout
+}
+
+// runClipboardTool runs the copy or paste command of a tool, passing it
+// input and returning its output. A tool that isn't installed gives
+// errNoClipboard.
+func runClipboardTool(argv []string, input io.Reader) ([]byte, error) {
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s isn't installed", errNoClipboard, argv[0])
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, argv[1:]...)
+	cmd.Stdin = input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("%s: %s", argv[0], msg)
+		}
+		return nil, fmt.Errorf("%s: %v", argv[0], err)
+	}
+	return stdout.Bytes(), nil
+}
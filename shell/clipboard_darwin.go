@@ -0,0 +1,19 @@
+//go:build darwin
+
+package shell
+
+import "bytes"
+
+// macClipboard is the pasteboard, through the commands macOS ships with
+var macClipboard = clipboardTool{copy: []string{"pbcopy"}, paste: []string{"pbpaste"}}
+
+// writeClipboard puts data on the clipboard
+func writeClipboard(data []byte) error {
+	_, err := runClipboardTool(macClipboard.copy, bytes.NewReader(data))
+	return err
+}
+
+// readClipboard returns the text on the clipboard
+func readClipboard() ([]byte, error) {
+	return runClipboardTool(macClipboard.paste, nil)
+}
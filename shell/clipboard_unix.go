@@ -0,0 +1,56 @@
+//go:build unix && !darwin
+
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// The clipboard belongs to the display server, so it is reached through
+// the tools each has: wl-clipboard under Wayland, and xclip or else xsel
+// under X11
+var (
+	waylandClipboard = clipboardTool{copy: []string{"wl-copy"}, paste: []string{"wl-paste", "--no-newline"}}
+	x11Clipboards    = []clipboardTool{
+		{copy: []string{"xclip", "-selection", "clipboard", "-in"}, paste: []string{"xclip", "-selection", "clipboard", "-out"}},
+		{copy: []string{"xsel", "--clipboard", "--input"}, paste: []string{"xsel", "--clipboard", "--output"}},
+	}
+)
+
+// clipboardTools returns the tools that might reach the clipboard of the
+// display the shell runs under, in the order to try them
+func clipboardTools() []clipboardTool {
+	var tools []clipboardTool
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, waylandClipboard)
+	}
+	if os.Getenv("DISPLAY") != "" {
+		tools = append(tools, x11Clipboards...)
+	}
+	return tools
+}
+
+// writeClipboard puts data on the clipboard
+func writeClipboard(data []byte) error {
+	err := errNoClipboard
+	for _, tool := range clipboardTools() {
+		if _, err = runClipboardTool(tool.copy, bytes.NewReader(data)); !errors.Is(err, errNoClipboard) {
+			return err
+		}
+	}
+	return err
+}
+
+// readClipboard returns the text on the clipboard
+func readClipboard() ([]byte, error) {
+	err := errNoClipboard
+	for _, tool := range clipboardTools() {
+		var data []byte
+		if data, err = runClipboardTool(tool.paste, nil); !errors.Is(err, errNoClipboard) {
+			return data, err
+		}
+	}
+	return nil, err
+}
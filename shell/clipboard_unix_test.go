@@ -0,0 +1,34 @@
+//go:build unix && !darwin
+
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClipboard(t *testing.T) {
+	// A stand-in xclip keeps the clipboard in a file
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$*\" in\n*-in) cat > \"$0.data\" ;;\n*-out) cat \"$0.data\" ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	shell := NewShell()
+
+	if out, status := runCapture(t, shell, "echo copied | clip"); out != "" || status != 0 {
+		t.Fatalf("clip = %q (status %d)", out, status)
+	}
+	if out, status := runCapture(t, shell, "paste"); out != "copied\n" || status != 0 {
+		t.Errorf("paste = %q (status %d), want %q", out, status, "copied\n")
+	}
+
+	t.Setenv("DISPLAY", "")
+	if out, status := runCapture(t, shell, "paste"); out != "paste: no clipboard available\n" || status != 1 {
+		t.Errorf("paste without a display = %q (status %d)", out, status)
+	}
+}
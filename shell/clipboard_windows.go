@@ -0,0 +1,97 @@
+//go:build windows
+
+package shell
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32           = windows.NewLazySystemDLL("user32.dll")
+	kernel32         = windows.NewLazySystemDLL("kernel32.dll")
+	openClipboard    = user32.NewProc("OpenClipboard")
+	closeClipboard   = user32.NewProc("CloseClipboard")
+	emptyClipboard   = user32.NewProc("EmptyClipboard")
+	getClipboardData = user32.NewProc("GetClipboardData")
+	setClipboardData = user32.NewProc("SetClipboardData")
+	globalAlloc      = kernel32.NewProc("GlobalAlloc")
+	globalFree       = kernel32.NewProc("GlobalFree")
+	globalLock       = kernel32.NewProc("GlobalLock")
+	globalUnlock     = kernel32.NewProc("GlobalUnlock")
+)
+
+const (
+	cfUnicodeText = 13     // CF_UNICODETEXT, text as UTF-16
+	gmemMoveable  = 0x0002 // GMEM_MOVEABLE, as the clipboard needs
+)
+
+// withClipboard opens the clipboard, runs fn and closes it again. Another
+// program may have it open for a moment, so opening is retried briefly.
+func withClipboard(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		r, _, err := openClipboard.Call(0)
+		if r != 0 {
+			break
+		}
+		if attempt == 20 {
+			return fmt.Errorf("cannot open the clipboard: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer closeClipboard.Call()
+	return fn()
+}
+
+// writeClipboard puts data on the clipboard as text
+func writeClipboard(data []byte) error {
+	text, err := windows.UTF16FromString(string(data))
+	if err != nil {
+		return fmt.Errorf("cannot copy text with a NUL byte")
+	}
+	return withClipboard(func() error {
+		if r, _, err := emptyClipboard.Call(); r == 0 {
+			return fmt.Errorf("cannot empty the clipboard: %v", err)
+		}
+		mem, _, err := globalAlloc.Call(gmemMoveable, uintptr(len(text)*2))
+		if mem == 0 {
+			return fmt.Errorf("cannot copy: %v", err)
+		}
+		p, _, err := globalLock.Call(mem)
+		if p == 0 {
+			globalFree.Call(mem)
+			return fmt.Errorf("cannot copy: %v", err)
+		}
+		copy(unsafe.Slice(*(**uint16)(unsafe.Pointer(&p)), len(text)), text)
+		globalUnlock.Call(mem)
+		// The clipboard owns the memory once it takes it
+		if r, _, err := setClipboardData.Call(cfUnicodeText, mem); r == 0 {
+			globalFree.Call(mem)
+			return fmt.Errorf("cannot copy: %v", err)
+		}
+		return nil
+	})
+}
+
+// readClipboard returns the text on the clipboard, nothing if it holds
+// something other than text
+func readClipboard() ([]byte, error) {
+	var data []byte
+	err := withClipboard(func() error {
+		mem, _, _ := getClipboardData.Call(cfUnicodeText)
+		if mem == 0 {
+			return nil
+		}
+		p, _, err := globalLock.Call(mem)
+		if p == 0 {
+			return fmt.Errorf("cannot paste: %v", err)
+		}
+		defer globalUnlock.Call(mem)
+		data = []byte(windows.UTF16PtrToString(*(**uint16)(unsafe.Pointer(&p))))
+		return nil
+	})
+	return data, err
+}
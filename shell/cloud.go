@@ -0,0 +1,109 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	registerSegment("aws", "the AWS profile and region, if set", func(s *Shell) string {
+		profile := s.firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+		region := s.firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+		if profile == "" && region == "" {
+			return ""
+		}
+		text := profile
+		if region != "" {
+			if text != "" {
+				text += "@"
+			}
+			text += region
+		}
+		return cloudColor(profile) + "aws:" + text + Reset
+	})
+	registerSegment("gcp", "the Google Cloud project, if set", func(s *Shell) string {
+		project := s.gcpProject()
+		if project == "" {
+			return ""
+		}
+		return cloudColor(project) + "gcp:" + project + Reset
+	})
+}
+
+// The aws and gcp prompt segments show the account commands would act on,
+// as the kube segment shows the cluster. Accounts, projects and clusters
+// named like production ones stand out in red.
+
+// productionName matches names of production accounts, clusters and
+// projects: prod, production, prd or live as a word of their own
+var productionName = regexp.MustCompile(`(?i)(^|[^a-z])(prod|production|prd|live)([^a-z]|$)`)
+
+// cloudColor is the color a segment shows an account, cluster or project
+// named name in: bold red for production ones
+func cloudColor(name string) string {
+	if productionName.MatchString(name) {
+		return Bold + Red
+	}
+	return Yellow
+}
+
+// firstEnv returns the value of the first of the variables names that is
+// set
+func (s *Shell) firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := s.env.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// gcpProject returns the project gcloud would use: CLOUDSDK_CORE_PROJECT,
+// or the project of its active configuration
+func (s *Shell) gcpProject() string {
+	if project := s.env.Get("CLOUDSDK_CORE_PROJECT"); project != "" {
+		return project
+	}
+	dir := s.env.Get("CLOUDSDK_CONFIG")
+	if dir == "" {
+		dir = filepath.Join(s.homeDir(), ".config", "gcloud")
+	}
+	active := s.env.Get("CLOUDSDK_ACTIVE_CONFIG_NAME")
+	if active == "" {
+		active, _ = s.configs.read(filepath.Join(dir, "active_config"), func(data []byte) any {
+			return strings.TrimSpace(string(data))
+		}).(string)
+	}
+	if active == "" {
+		active = "default"
+	}
+	project, _ := s.configs.read(filepath.Join(dir, "configurations", "config_"+active), func(data []byte) any {
+		return iniValue(data, "core", "project")
+	}).(string)
+	return project
+}
+
+// iniValue returns the value of a key in a section of an INI file, as
+// gcloud writes its configurations
+func iniValue(data []byte, section, key string) string {
+	var current string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if ok && current == section && strings.TrimSpace(name) == key {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
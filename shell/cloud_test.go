@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloudSegments(t *testing.T) {
+	home := t.TempDir()
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	for _, name := range []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"CLOUDSDK_CORE_PROJECT", "CLOUDSDK_CONFIG", "CLOUDSDK_ACTIVE_CONFIG_NAME"} {
+		shell.env.Unset(name)
+	}
+	shell.env.Set("GOSHELL_PROMPT", "{aws} {gcp} > ")
+	if got, want := shell.Prompt(), "> "; got != want {
+		t.Errorf("Prompt() without accounts = %q, want %q", got, want)
+	}
+
+	shell.env.Set("AWS_PROFILE", "dev")
+	shell.env.Set("AWS_DEFAULT_REGION", "eu-west-1")
+	if got, want := shell.Prompt(), Yellow+"aws:dev@eu-west-1"+Reset+" > "; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+	shell.env.Set("AWS_PROFILE", "acme-prod")
+	if got, want := shell.Prompt(), Bold+Red+"aws:acme-prod@eu-west-1"+Reset+" > "; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+
+	gcloud := filepath.Join(home, ".config", "gcloud")
+	os.MkdirAll(filepath.Join(gcloud, "configurations"), 0755)
+	os.WriteFile(filepath.Join(gcloud, "active_config"), []byte("work\n"), 0600)
+	os.WriteFile(filepath.Join(gcloud, "configurations", "config_work"), []byte("[compute]\nproject = nope\n[core]\naccount = ada@example.com\nproject = web-staging\n"), 0600)
+	if got, want := shell.gcpProject(), "web-staging"; got != want {
+		t.Errorf("gcpProject() = %q, want %q", got, want)
+	}
+	shell.env.Set("CLOUDSDK_CORE_PROJECT", "web-live")
+	if got, want := shell.renderPrompt("{gcp}"), Bold+Red+"gcp:web-live"+Reset; got != want {
+		t.Errorf("renderPrompt({gcp}) = %q, want %q", got, want)
+	}
+}
+
+func TestProductionName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"prod": true, "acme-prod": true, "Production": true, "eks_prd_1": true, "live": true,
+		"dev": false, "product-team": false, "delivery": false, "": false,
+	} {
+		if got := productionName.MatchString(name); got != want {
+			t.Errorf("productionName matches %q = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package shell
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("digest", "digest ALGORITHM [file...]", "Print checksums of files: md5, sha1, sha256, sha512 and more", builtinDigest)
+	registerBuiltin("encode", "encode ENCODING [file...]", "Encode files as base64, base64url, base32, hex or url", builtinEncode)
+	registerBuiltin("decode", "decode ENCODING [file...]", "Decode base64, base64url, base32, hex or url", builtinDecode)
+}
+
+// digestAlgorithms are the hashes digest knows, by name
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha224": sha256.New224,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+// codec is an encoding encode and decode know
+type codec struct {
+	// encoder returns a writer that encodes what is written to it onto w,
+	// flushing the end when closed
+	encoder func(w io.Writer) io.WriteCloser
+	decode  func(data string) ([]byte, error)
+	// spaced means whitespace means nothing in the encoded form, so lines
+	// can be wrapped
+	spaced bool
+}
+
+// codecs are the encodings encode and decode know, by name
+var codecs = map[string]codec{
+	"base64": {
+		encoder: func(w io.Writer) io.WriteCloser { return base64.NewEncoder(base64.StdEncoding, w) },
+		decode:  base64.StdEncoding.DecodeString,
+		spaced:  true,
+	},
+	"base64url": {
+		encoder: func(w io.Writer) io.WriteCloser { return base64.NewEncoder(base64.URLEncoding, w) },
+		decode:  base64.URLEncoding.DecodeString,
+		spaced:  true,
+	},
+	"base32": {
+		encoder: func(w io.Writer) io.WriteCloser { return base32.NewEncoder(base32.StdEncoding, w) },
+		decode:  base32.StdEncoding.DecodeString,
+		spaced:  true,
+	},
+	"hex": {
+		encoder: func(w io.Writer) io.WriteCloser { return nopWriteCloser{hex.NewEncoder(w)} },
+		decode:  hex.DecodeString,
+		spaced:  true,
+	},
+	"url": {
+		encoder: func(w io.Writer) io.WriteCloser { return &urlEncoder{w: w} },
+		decode: func(data string) ([]byte, error) {
+			s, err := url.QueryUnescape(strings.TrimRight(data, "\r\n"))
+			return []byte(s), err
+		},
+	},
+}
+
+// nopWriteCloser is a writer with nothing to flush when closed
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// urlEncoder percent-encodes what is written to it, as a query string
+// value, when it is closed. A trailing newline is dropped, as it is most
+// likely echo's.
+type urlEncoder struct {
+	w    io.Writer
+	data strings.Builder
+}
+
+func (e *urlEncoder) Write(p []byte) (int, error) { return e.data.Write(p) }
+
+func (e *urlEncoder) Close() error {
+	_, err := io.WriteString(e.w, url.QueryEscape(strings.TrimSuffix(e.data.String(), "\n")))
+	return err
+}
+
+// builtinDigest prints a checksum for each file, or its input if there are
+// none, in the form sha256sum and its kin print, so the output is the same
+// on every platform whichever tools it has
+func builtinDigest(s *Shell, args []string, stdio Stdio) int {
+	if len(args) < 2 {
+		return usageWithNames(stdio, "digest", "algorithms", digestAlgorithms)
+	}
+	newHash, ok := digestAlgorithms[strings.ToLower(args[1])]
+	if !ok {
+		fmt.Fprintf(stdio.Stderr, "digest: unknown algorithm '%s'\n", args[1])
+		return usageWithNames(stdio, "digest", "algorithms", digestAlgorithms)
+	}
+	files := args[2:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, file := range files {
+		h := newHash()
+		if err := copyInput(h, file, stdio.Stdin); err != nil {
+			fmt.Fprintln(stdio.Stderr, "digest:", err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(stdio.Stdout, "%x  %s\n", h.Sum(nil), file)
+	}
+	return status
+}
+
+// builtinEncode encodes its files, or its input, as a single line
+func builtinEncode(s *Shell, args []string, stdio Stdio) int {
+	c, files, status := codecFor("encode", args, stdio)
+	if status != 0 {
+		return status
+	}
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	enc := c.encoder(out)
+	for _, file := range files {
+		if err := copyInput(enc, file, stdio.Stdin); err != nil {
+			fmt.Fprintln(stdio.Stderr, "encode:", err)
+			return 1
+		}
+	}
+	enc.Close()
+	out.WriteByte('\n')
+	return 0
+}
+
+// builtinDecode decodes its files, or its input, which may be wrapped over
+// several lines
+func builtinDecode(s *Shell, args []string, stdio Stdio) int {
+	c, files, status := codecFor("decode", args, stdio)
+	if status != 0 {
+		return status
+	}
+	var data strings.Builder
+	for _, file := range files {
+		if err := copyInput(&data, file, stdio.Stdin); err != nil {
+			fmt.Fprintln(stdio.Stderr, "decode:", err)
+			return 1
+		}
+	}
+	text := data.String()
+	if c.spaced {
+		text = strings.Join(strings.Fields(text), "")
+	}
+	decoded, err := c.decode(text)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "decode: invalid %s input: %v\n", args[1], err)
+		return 1
+	}
+	stdio.Stdout.Write(decoded)
+	return 0
+}
+
+// codecFor returns the encoding named by encode or decode's first argument
+// and the files that follow it, stdin if there are none, or the status to
+// exit with if there is no such encoding
+func codecFor(command string, args []string, stdio Stdio) (codec, []string, int) {
+	if len(args) < 2 {
+		return codec{}, nil, usageWithNames(stdio, command, "encodings", codecs)
+	}
+	c, ok := codecs[strings.ToLower(args[1])]
+	if !ok {
+		fmt.Fprintf(stdio.Stderr, "%s: unknown encoding '%s'\n", command, args[1])
+		return codec{}, nil, usageWithNames(stdio, command, "encodings", codecs)
+	}
+	files := args[2:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	return c, files, 0
+}
+
+// copyInput copies a file, or stdin if it is -, to w
+func copyInput(w io.Writer, file string, stdin io.Reader) error {
+	if file == "-" {
+		_, err := io.Copy(w, stdin)
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// usageWithNames prints a command's usage and the names its first argument
+// can take, returning 1
+func usageWithNames[T any](stdio Stdio, command, kind string, names map[string]T) int {
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	fmt.Fprintf(stdio.Stderr, "Usage: %s\n%s: %s\n", builtins[command].usage, strings.ToUpper(kind[:1])+kind[1:], strings.Join(list, ", "))
+	return 1
+}
@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(file, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	for _, tt := range []struct {
+		line string
+		want string
+	}{
+		{"digest sha256 FILE", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  FILE\n"},
+		{"digest MD5 FILE", "b1946ac92492d2347c6235b4d2611184  FILE\n"},
+		{"digest sha1 FILE", "f572d396fae9206628714fb2ce00f72e94f2258f  FILE\n"},
+		{"digest crc32 FILE", "363a3020  FILE\n"},
+		{"echo hello | digest sha256", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  -\n"},
+	} {
+		line := strings.ReplaceAll(tt.line, "FILE", file)
+		out, status := runCapture(t, shell, line)
+		if want := strings.ReplaceAll(tt.want, "FILE", file); out != want || status != 0 {
+			t.Errorf("%s = %q (status %d), want %q", tt.line, out, status, want)
+		}
+	}
+	for _, line := range []string{"digest", "digest sha3 " + file, "digest md5 missing"} {
+		if _, status := runCapture(t, shell, line); status != 1 {
+			t.Errorf("%s exited with %d, want 1", line, status)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	shell := NewShell()
+	for _, tt := range []struct {
+		encoding string
+		plain    string
+		encoded  string
+	}{
+		{"base64", "hi there?&", "aGkgdGhlcmU/Jg=="},
+		{"base64url", "hi there?&", "aGkgdGhlcmU_Jg=="},
+		{"base32", "hello", "NBSWY3DP"},
+		{"hex", "hello", "68656c6c6f"},
+		{"url", "a b/c?d=é", "a+b%2Fc%3Fd%3D%C3%A9"},
+	} {
+		out, status := runCapture(t, shell, "echo -n '"+tt.plain+"' | encode "+tt.encoding)
+		if out != tt.encoded+"\n" || status != 0 {
+			t.Errorf("encode %s of %q = %q (status %d), want %q", tt.encoding, tt.plain, out, status, tt.encoded)
+		}
+		out, status = runCapture(t, shell, "echo '"+tt.encoded+"' | decode "+tt.encoding)
+		if out != tt.plain || status != 0 {
+			t.Errorf("decode %s of %q = %q (status %d), want %q", tt.encoding, tt.encoded, out, status, tt.plain)
+		}
+	}
+
+	// Encoded text can be wrapped over lines
+	if out, _ := runCapture(t, shell, "printf 'aGkgdGhl\\ncmU/Jg==\\n' | decode base64"); out != "hi there?&" {
+		t.Errorf("decode of wrapped base64 = %q", out)
+	}
+	for _, line := range []string{"echo '%%%' | decode base64", "echo zz | decode hex", "encode rot13", "decode"} {
+		if _, status := runCapture(t, shell, line); status != 1 {
+			t.Errorf("%s exited with %d, want 1", line, status)
+		}
+	}
+}
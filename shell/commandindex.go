@@ -0,0 +1,162 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("rehash", "rehash", "Rescan PATH for executables", builtinRehash)
+}
+
+// commandIndexTTL is how long a PATH listing is used before it is rescanned
+const commandIndexTTL = 30 * time.Second
+
+// commandIndex lists the executables found on PATH, for completion,
+// highlighting and running commands without searching PATH each time.
+// Listings are cached per PATH value, so changing PATH gives a new one, and
+// rescanned in the background once they are older than commandIndexTTL, so
+// new executables show up without delaying completion. rehash rescans at
+// once.
+type commandIndex struct {
+	cache *flightCache[pathListing]
+}
+
+// pathListing is what a scan of PATH finds
+type pathListing struct {
+	names []string          // sorted executable names
+	paths map[string]string // where each name is found first, by name
+}
+
+// newCommandIndex creates an empty index
+func newCommandIndex() *commandIndex {
+	return &commandIndex{cache: newFlightCache[pathListing](commandIndexTTL)}
+}
+
+// listing returns the listing of the given PATH, scanning it if there is
+// none yet
+func (ci *commandIndex) listing(path string) pathListing {
+	scan := func() (pathListing, error) { return scanPath(path), nil }
+	if listing, ok := ci.cache.Peek(path, scan); ok {
+		return listing
+	}
+	listing, _ := ci.cache.Get(path, scan)
+	return listing
+}
+
+// names returns the sorted executable names found on the given PATH
+func (ci *commandIndex) names(path string) []string {
+	return ci.listing(path).names
+}
+
+// lookup returns where name is found on the given PATH, as exec.LookPath
+// would. Only directories given as absolute paths are indexed, as
+// exec.LookPath refuses to run commands found through relative ones.
+func (ci *commandIndex) lookup(path, name string) (string, bool) {
+	file, ok := ci.listing(path).paths[name]
+	return file, ok
+}
+
+// rehash forgets every listing, so the next use scans PATH again
+func (ci *commandIndex) rehash() {
+	ci.cache.Clear()
+}
+
+// scanPath lists the executables in every directory of a PATH value
+func scanPath(path string) pathListing {
+	listing := pathListing{paths: make(map[string]string)}
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if seen[name] || entry.IsDir() {
+				continue
+			}
+			file := filepath.Join(dir, name)
+			info, err := os.Stat(file)
+			if err != nil || !isExecutable(info) {
+				continue
+			}
+			seen[name] = true
+			listing.names = append(listing.names, name)
+			if filepath.IsAbs(dir) {
+				listing.paths[name] = file
+			}
+		}
+	}
+	sort.Strings(listing.names)
+	return listing
+}
+
+// commandPath returns the file to run for a command: the name itself if it
+// is a path, or else where it is found on the shell's PATH, from the index
+// if it is there. A command installed since PATH was scanned is looked for
+// in each directory in turn.
+func (s *Shell) commandPath(name string) (string, error) {
+	if filepath.Base(name) != name {
+		return name, nil
+	}
+	path := s.env.Get("PATH")
+	for _, candidate := range commandFiles(name) {
+		if file, ok := s.commands.lookup(path, candidate); ok {
+			return file, nil
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		for _, candidate := range commandFiles(name) {
+			file := filepath.Join(dir, candidate)
+			if info, err := os.Stat(file); err == nil && isExecutable(info) {
+				return file, nil
+			}
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// commandFiles returns the names a command may have on disk: the name
+// itself, and on Windows the name with each extension in PATHEXT
+func commandFiles(name string) []string {
+	files := []string{name}
+	if runtime.GOOS != "windows" || filepath.Ext(name) != "" {
+		return files
+	}
+	exts := os.Getenv("PATHEXT")
+	if exts == "" {
+		exts = ".com;.exe;.bat;.cmd"
+	}
+	for _, ext := range filepath.SplitList(exts) {
+		if ext != "" {
+			files = append(files, name+strings.ToLower(ext))
+		}
+	}
+	return files
+}
+
+// isExecutable reports whether a file can be run. Windows has no execute
+// permission, so any regular file there can be.
+func isExecutable(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && (runtime.GOOS == "windows" || info.Mode()&0111 != 0)
+}
+
+// builtinRehash rescans PATH, for when executables have been installed or
+// removed since it was last scanned
+func builtinRehash(s *Shell, args []string, stdio Stdio) int {
+	if len(args) != 1 {
+		return newFlagSet("rehash").usage(stdio)
+	}
+	s.commands.rehash()
+	s.commands.names(s.env.Get("PATH"))
+	return 0
+}
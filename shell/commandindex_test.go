@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCommandIndex(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	for _, file := range []string{filepath.Join(first, "tool"), filepath.Join(second, "tool"), filepath.Join(second, "other")} {
+		script := "#!/bin/sh\necho " + filepath.Base(filepath.Dir(file)) + "\n"
+		if err := os.WriteFile(file, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(first, "data"), nil, 0644)
+	shell := NewShell()
+	shell.env.Set("PATH", first+string(os.PathListSeparator)+second)
+
+	if got := shell.commands.names(shell.env.Get("PATH")); !slices.Equal(got, []string{"other", "tool"}) {
+		t.Errorf("names = %q", got)
+	}
+	// The first directory on PATH wins, as with exec.LookPath
+	if file, err := shell.commandPath("tool"); err != nil || file != filepath.Join(first, "tool") {
+		t.Errorf("commandPath(tool) = %q, %v", file, err)
+	}
+	if _, err := shell.commandPath("data"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("commandPath(data) error = %v, want not found", err)
+	}
+	if file, _ := shell.commandPath("./tool"); file != "./tool" {
+		t.Errorf("commandPath(./tool) = %q", file)
+	}
+
+	// A command installed since the scan is still found, and rehash adds it
+	// to the index
+	added := filepath.Join(second, "added")
+	os.WriteFile(added, nil, 0755)
+	if file, err := shell.commandPath("added"); err != nil || file != added {
+		t.Errorf("commandPath(added) = %q, %v", file, err)
+	}
+	if slices.Contains(shell.commands.names(shell.env.Get("PATH")), "added") {
+		t.Fatal("the index was rescanned before its time")
+	}
+	if _, status := runCapture(t, shell, "rehash"); status != 0 {
+		t.Errorf("rehash exited with %d", status)
+	}
+	if !slices.Contains(shell.commands.names(shell.env.Get("PATH")), "added") {
+		t.Error("rehash didn't add the new command to the index")
+	}
+
+	// Commands are found on the shell's PATH, not the one it started with
+	shell.env.Set("PATH", second)
+	if out, status := runCapture(t, shell, "tool"); out != filepath.Base(second)+"\n" || status != 0 {
+		t.Errorf("tool = %q (status %d)", out, status)
+	}
+	if file, _ := shell.commandPath("tool"); file != filepath.Join(second, "tool") {
+		t.Errorf("after changing PATH commandPath(tool) = %q", file)
+	}
+}
@@ -0,0 +1,174 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("complete", "complete [-c CMD -a ARGS [-d DESC] [-f] | -e -c CMD]", "Define completions for a command's arguments", builtinComplete)
+	registerFlags("complete",
+		Candidate{"-c", "Command the completion applies to"},
+		Candidate{"-a", "Words to offer, or (command) to generate them"},
+		Candidate{"-d", "Description shown next to the words"},
+		Candidate{"-f", "Don't offer file names as well"},
+		Candidate{"-e", "Erase the command's completions"})
+}
+
+// generatorTTL is how long a generator's output is reused, so pressing Tab
+// repeatedly doesn't rerun it
+const generatorTTL = 5 * time.Second
+
+// completionSpec is a user-defined source of argument completions for a
+// command: either a static word list or a generator command whose output
+// lines become the candidates. A script's completion function is a
+// generator too: fn produces the lines, and generator only names it.
+type completionSpec struct {
+	words       []string
+	generator   string
+	fn          func(s *Shell) (string, error)
+	description string
+	noFiles     bool
+}
+
+// String formats the spec as the complete command that defines it
+func (spec *completionSpec) String() string {
+	args := strings.Join(spec.words, " ")
+	if spec.generator != "" {
+		args = "(" + spec.generator + ")"
+	}
+	text := "-a " + shellQuote(args)
+	if spec.description != "" {
+		text += " -d " + shellQuote(spec.description)
+	}
+	if spec.noFiles {
+		text += " -f"
+	}
+	return text
+}
+
+// candidates returns the spec's candidates that match prefix
+func (spec *completionSpec) candidates(s *Shell, prefix string) []Candidate {
+	var result []Candidate
+	add := func(text, description string) {
+		if s.matchWord(text, prefix) {
+			result = append(result, Candidate{Text: text, Description: description})
+		}
+	}
+	for _, word := range spec.words {
+		add(word, spec.description)
+	}
+	if spec.generator == "" {
+		return result
+	}
+
+	// Generators print one candidate per line, optionally followed by a tab
+	// and a description
+	out, err := s.generate(spec)
+	if err != nil {
+		return result
+	}
+	for _, line := range strings.Split(out, "\n") {
+		text, description, found := strings.Cut(strings.TrimSpace(line), "\t")
+		if text == "" {
+			continue
+		}
+		if !found {
+			description = spec.description
+		}
+		add(escapeWord(text), description)
+	}
+	return result
+}
+
+// generate returns the output of a spec's generator. Output is cached per
+// generator and working directory for generatorTTL.
+func (s *Shell) generate(spec *completionSpec) (string, error) {
+	dir, _ := s.Getwd()
+	return s.generated.Get(spec.generator+"\x00"+dir, func() (string, error) {
+		if spec.fn != nil {
+			return spec.fn(s)
+		}
+		list, err := parser.Parse(spec.generator)
+		if err != nil {
+			return "", err
+		}
+		var out strings.Builder
+		s.runList(list, Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: io.Discard}, false)
+		return out.String(), nil
+	})
+}
+
+// completeArguments returns the candidates from the specs registered for a
+// command, and whether file names should be offered too
+func (s *Shell) completeArguments(specs []*completionSpec, word string) ([]Candidate, bool) {
+	var candidates []Candidate
+	files := true
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		for _, c := range spec.candidates(s, word) {
+			if !seen[c.Text] {
+				seen[c.Text] = true
+				candidates = append(candidates, c)
+			}
+		}
+		files = files && !spec.noFiles
+	}
+	return candidates, files
+}
+
+// builtinComplete registers, erases or lists programmable completions.
+// Without arguments it prints the registered completions as commands.
+func builtinComplete(s *Shell, args []string, stdio Stdio) int {
+	if len(args) == 1 {
+		commands := make([]string, 0, len(s.completions))
+		for name := range s.completions {
+			commands = append(commands, name)
+		}
+		sort.Strings(commands)
+		for _, name := range commands {
+			for _, spec := range s.completions[name] {
+				fmt.Fprintf(stdio.Stdout, "complete -c %s %s\n", shellQuote(name), spec)
+			}
+		}
+		return 0
+	}
+
+	spec := &completionSpec{}
+	var command, words string
+	erase := false
+	flags := newFlagSet("complete")
+	flags.Bool(&erase, "e")
+	flags.Bool(&spec.noFiles, "f")
+	flags.String(&command, "c")
+	flags.String(&words, "a")
+	flags.String(&spec.description, "d")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if command == "" || len(operands) > 0 {
+		return flags.usage(stdio)
+	}
+	if erase {
+		delete(s.completions, command)
+		return 0
+	}
+
+	words = strings.TrimSpace(words)
+	if strings.HasPrefix(words, "(") && strings.HasSuffix(words, ")") {
+		spec.generator = strings.TrimSpace(words[1 : len(words)-1])
+	} else {
+		spec.words = strings.Fields(words)
+	}
+	if len(spec.words) == 0 && spec.generator == "" && !spec.noFiles {
+		return flags.usage(stdio)
+	}
+	s.completions[command] = append(s.completions[command], spec)
+	return 0
+}
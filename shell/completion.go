@@ -0,0 +1,285 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Candidate is a single completion suggestion
+type Candidate struct {
+	Text        string // replacement for the word being completed
+	Description string // optional short description shown in listings
+}
+
+// wordBreaks are the characters that separate words for completion
+const wordBreaks = " \t|;&<>()"
+
+// Complete returns the completion candidates for the word ending at pos in
+// line, along with the offset at which that word starts. Offsets are in
+// runes.
+func (s *Shell) Complete(line []rune, pos int) ([]Candidate, int) {
+	candidates, start := s.completeWord(line, pos)
+	if s.fuzzyCompletion() {
+		// Rank the best matches for what was typed first
+		word := string(line[start:pos])
+		scores := make(map[string]int, len(candidates))
+		for _, c := range candidates {
+			scores[c.Text], _ = fuzzyMatch(c.Text, word)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return scores[candidates[i].Text] > scores[candidates[j].Text]
+		})
+	}
+	return candidates, start
+}
+
+// completeWord finds the candidates for the word ending at pos, choosing the
+// source from the word's context
+func (s *Shell) completeWord(line []rune, pos int) ([]Candidate, int) {
+	start := wordStart(line, pos)
+	word := string(line[start:pos])
+
+	if candidates, ok := s.completeVariable(word); ok {
+		return candidates, start
+	}
+	if isCommandPosition(line, start) && !strings.ContainsRune(word, '/') {
+		return s.completeCommand(word), start
+	}
+	name := commandName(line, start)
+	if specs := s.completions[name]; len(specs) > 0 {
+		candidates, files := s.completeArguments(specs, word)
+		if files {
+			candidates = append(candidates, s.completePath(word, false)...)
+		}
+		return candidates, start
+	}
+	if b, ok := builtins[name]; ok {
+		if strings.HasPrefix(word, "-") && len(b.flags) > 0 {
+			return s.completeFlags(b.flags, word), start
+		}
+		if b.args != nil && !strings.HasPrefix(word, "-") {
+			return b.args(s, word), start
+		}
+	}
+	return s.completePath(word, false), start
+}
+
+// wordStart returns the offset of the start of the word ending at pos.
+// Backslash-escaped separators are part of the word.
+func wordStart(line []rune, pos int) int {
+	start := pos
+	for start > 0 {
+		c := line[start-1]
+		if strings.ContainsRune(wordBreaks, c) && !(start > 1 && line[start-2] == '\\') {
+			break
+		}
+		start--
+	}
+	return start
+}
+
+// isCommandPosition reports whether a word starting at start is the name of
+// a command rather than an argument
+func isCommandPosition(line []rune, start int) bool {
+	i := start - 1
+	for i >= 0 && (line[i] == ' ' || line[i] == '\t') {
+		i--
+	}
+	return i < 0 || strings.ContainsRune("|;&(", line[i])
+}
+
+// completeVariable completes a $NAME or ${NAME reference at the end of word
+// against the shell's variables. It reports false if the word doesn't end in
+// one.
+func (s *Shell) completeVariable(word string) ([]Candidate, bool) {
+	dollar := strings.LastIndex(word, "$")
+	if dollar < 0 || (dollar > 0 && word[dollar-1] == '\\') || strings.Count(word[:dollar], "'")%2 == 1 {
+		return nil, false
+	}
+	name := word[dollar+1:]
+	braced := strings.HasPrefix(name, "{")
+	if braced {
+		name = name[1:]
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameChar(name[i], i == 0) {
+			return nil, false
+		}
+	}
+
+	var candidates []Candidate
+	for _, key := range s.env.Keys() {
+		if !s.matchWord(key, name) {
+			continue
+		}
+		text := word[:dollar] + "$" + key
+		if braced {
+			text = word[:dollar] + "${" + key + "}"
+		}
+		candidates = append(candidates, Candidate{Text: text, Description: s.env.Get(key)})
+	}
+	return candidates, true
+}
+
+// commandName returns the name of the command that the word starting at start
+// is an argument of
+func commandName(line []rune, start int) string {
+	i := start
+	for i > 0 && !strings.ContainsRune("|;&(", line[i-1]) {
+		i--
+	}
+	fields := strings.Fields(string(line[i:start]))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// completeFlags returns the flags that match prefix, keeping their
+// descriptions
+func (s *Shell) completeFlags(flags []Candidate, prefix string) []Candidate {
+	var candidates []Candidate
+	for _, flag := range flags {
+		if s.matchWord(flag.Text, prefix) {
+			candidates = append(candidates, flag)
+		}
+	}
+	return candidates
+}
+
+// completeCommand completes a command name against builtins, aliases and
+// executables on PATH
+func (s *Shell) completeCommand(prefix string) []Candidate {
+	seen := make(map[string]bool)
+	var candidates []Candidate
+	add := func(name string) {
+		if seen[name] {
+			return
+		}
+		if s.matchWord(name, prefix) {
+			seen[name] = true
+			candidates = append(candidates, Candidate{Text: name})
+		}
+	}
+
+	for _, name := range builtinNames() {
+		add(name)
+	}
+	for _, name := range s.aliasNames() {
+		add(name)
+	}
+	for _, name := range s.commands.names(s.env.Get("PATH")) {
+		add(name)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Text < candidates[j].Text })
+	return candidates
+}
+
+// completePath completes a file or directory path. Directories are given a
+// trailing slash; with dirsOnly set, files are skipped.
+func (s *Shell) completePath(word string, dirsOnly bool) []Candidate {
+	// Work on the unescaped form, keeping the prefix exactly as typed
+	typedDir := ""
+	if idx := strings.LastIndex(word, "/"); idx >= 0 {
+		typedDir = word[:idx+1]
+	}
+	base := unescapeWord(word[len(typedDir):])
+
+	dir := unescapeWord(typedDir)
+	if strings.HasPrefix(dir, "~/") || dir == "~" {
+		dir = s.homeDir() + dir[1:]
+	}
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []Candidate
+	for _, entry := range entries {
+		name := entry.Name()
+		if !s.matchWord(name, base) {
+			continue
+		}
+		// Hidden files are only offered when asked for explicitly
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if dirsOnly && !isDir {
+			continue
+		}
+		text := typedDir + escapeWord(name)
+		if isDir {
+			text += "/"
+		}
+		candidates = append(candidates, Candidate{Text: text})
+	}
+	return candidates
+}
+
+// escapeWord backslash-escapes characters that would otherwise split or
+// alter a word
+func escapeWord(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		if strings.ContainsRune(" \t|;&<>()'\"\\$*?[#~`", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescapeWord removes backslash escapes from a partially typed word
+func unescapeWord(word string) string {
+	if !strings.Contains(word, "\\") {
+		return word
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range word {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// commonPrefix returns the longest prefix shared by every candidate
+func commonPrefix(candidates []Candidate) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := candidates[0].Text
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c.Text, prefix) {
+			_, size := lastRune(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// lastRune returns the final rune of s and its encoded length
+func lastRune(s string) (rune, int) {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0, 0
+	}
+	r := runes[len(runes)-1]
+	return r, len(string(r))
+}
@@ -0,0 +1,387 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+// candidateTexts returns the text of each candidate
+func candidateTexts(candidates []Candidate) []string {
+	texts := make([]string, len(candidates))
+	for i, c := range candidates {
+		texts[i] = c.Text
+	}
+	return texts
+}
+
+// containsString reports whether list contains want
+func containsString(list []string, want string) bool {
+	for _, s := range list {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompleteCommand(t *testing.T) {
+	binDir := t.TempDir()
+	os.WriteFile(filepath.Join(binDir, "goshell-tool"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(binDir, "goshell-data"), nil, 0644)
+
+	shell := NewShell()
+	shell.env.Set("PATH", binDir)
+
+	texts := candidateTexts(shell.completeCommand("goshell-"))
+	if len(texts) != 1 || texts[0] != "goshell-tool" {
+		t.Errorf("completeCommand(goshell-) = %v, want [goshell-tool]", texts)
+	}
+	if texts := candidateTexts(shell.completeCommand("hist")); !containsString(texts, "history") {
+		t.Errorf("completeCommand(hist) = %v, want builtin history", texts)
+	}
+
+	// The index follows PATH changes
+	otherDir := t.TempDir()
+	os.WriteFile(filepath.Join(otherDir, "goshell-other"), []byte("#!/bin/sh\n"), 0755)
+	shell.env.Set("PATH", otherDir)
+	if texts := candidateTexts(shell.completeCommand("goshell-")); len(texts) != 1 || texts[0] != "goshell-other" {
+		t.Errorf("completeCommand after PATH change = %v, want [goshell-other]", texts)
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "some file.txt"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644)
+	shell := NewShell()
+
+	texts := candidateTexts(shell.completePath(dir+"/s", false))
+	if len(texts) != 2 || texts[0] != dir+"/some\\ file.txt" || texts[1] != dir+"/src/" {
+		t.Errorf("completePath() = %v, want escaped file and directory", texts)
+	}
+	if texts := candidateTexts(shell.completePath(dir+"/", true)); len(texts) != 1 || texts[0] != dir+"/src/" {
+		t.Errorf("completePath(dirsOnly) = %v, want [%s/src/]", texts, dir)
+	}
+	if texts := candidateTexts(shell.completePath(dir+"/.h", false)); len(texts) != 1 {
+		t.Errorf("completePath(.h) = %v, want the hidden file", texts)
+	}
+}
+
+func TestCompletionContext(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantStart int
+		command   bool
+	}{
+		{"ec", 0, true},
+		{"echo fi", 5, false},
+		{"ls | gr", 5, true},
+		{"cat my\\ fi", 4, false},
+	}
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		start := wordStart(line, len(line))
+		if start != tt.wantStart {
+			t.Errorf("wordStart(%q) = %d, want %d", tt.line, start, tt.wantStart)
+		}
+		if got := isCommandPosition(line, start); got != tt.command {
+			t.Errorf("isCommandPosition(%q) = %v, want %v", tt.line, got, tt.command)
+		}
+	}
+}
+
+func TestEditorComplete(t *testing.T) {
+	shell := NewShell()
+	editor := newTestEditor(shell)
+
+	line, pos := press(editor, []rune("hist"), 4, lineedit.Tab)
+	if string(line) != "history " || pos != 8 {
+		t.Errorf("Tab gave %q at %d, want %q", string(line), pos, "history ")
+	}
+
+	// Ambiguous words without a longer common prefix open the menu
+	line, _ = editor.complete([]rune("e"), 1)
+	if string(line) != "e" || editor.menu == nil {
+		t.Fatalf("complete(e) = %q, menu open: %v", string(line), editor.menu != nil)
+	}
+	if !strings.Contains(strings.Join(editor.Paint(line, 1).Below, "\n"), "echo") {
+		t.Errorf("Paint() doesn't show the candidates")
+	}
+}
+
+// newTestEditor returns an editor for the shell that has no input and
+// discards what it draws
+func newTestEditor(shell *Shell) *lineEditor {
+	return newLineEditor(shell, lineedit.New(strings.NewReader(""), io.Discard))
+}
+
+// press puts line in the editor with the cursor at pos, then handles key as
+// if it had been typed, returning the line and cursor after it
+func press(editor *lineEditor, line []rune, pos int, key lineedit.Key) ([]rune, int) {
+	editor.ed.SetBuffer(line, pos)
+	editor.ed.HandleKey(key)
+	return editor.ed.Buffer()
+}
+
+// typeKeys presses the keys in text one after another, decoding escape
+// sequences as they would be from the terminal
+func typeKeys(editor *lineEditor, line []rune, pos int, text string) ([]rune, int) {
+	for _, key := range lineedit.ParseKeys(text) {
+		line, pos = press(editor, line, pos, key)
+	}
+	return line, pos
+}
+
+func TestCompletionMenu(t *testing.T) {
+	shell := NewShell()
+	editor := newTestEditor(shell)
+	runCapture(t, shell, `complete -c deploy -a 'alpha beta gamma' -f`)
+
+	press := func(line []rune, pos int, key lineedit.Key) ([]rune, int) {
+		return press(editor, line, pos, key)
+	}
+
+	line := []rune("deploy ")
+	line, pos := press(line, len(line), '\t')
+	if editor.menu == nil {
+		t.Fatal("Tab didn't open the menu")
+	}
+	line, pos = press(line, pos, '\t')
+	line, pos = press(line, pos, lineedit.KeyDown)
+	line, pos = press(line, pos, lineedit.KeyUp)
+	if got := editor.menu.selected; got != 1 {
+		t.Errorf("selected = %d, want 1", got)
+	}
+	line, pos = press(line, pos, lineedit.Enter)
+	if string(line) != "deploy beta " || pos != len(line) || editor.menu != nil {
+		t.Errorf("accept = %q, %d, menu open: %v", string(line), pos, editor.menu != nil)
+	}
+
+	// Other keys close the menu and have their usual effect
+	line, pos = press([]rune("deploy "), 7, '\t')
+	line, pos = press(line, pos, 'x')
+	if string(line) != "deploy x" || pos != 8 || editor.menu != nil {
+		t.Errorf("x gave %q at %d, menu open: %v", string(line), pos, editor.menu != nil)
+	}
+}
+
+func TestEditorSuggestion(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("GOSHELL_HIGHLIGHT", "0")
+	shell.AddToHistory("echo hello")
+	editor := newTestEditor(shell)
+
+	line := []rune("ec")
+	if got := editor.Paint(line, 2); got.Line != "ec" || got.Hint != Dim+"ho hello"+Reset {
+		t.Errorf("Paint() = %+v, want the dim suggestion", got)
+	}
+	if got := editor.Paint(line, 1); got.Hint != "" {
+		t.Errorf("Paint() away from the end = %+v, want no suggestion", got)
+	}
+
+	// Right-arrow accepts the suggestion
+	got, pos := press(editor, line, 2, lineedit.KeyRight)
+	if string(got) != "echo hello" || pos != len(got) {
+		t.Errorf("accept = %q, %d, want %q", string(got), pos, "echo hello")
+	}
+	if got, pos = press(editor, got, 4, lineedit.KeyRight); pos != 5 {
+		t.Errorf("Right without a suggestion gave %q at %d", string(got), pos)
+	}
+}
+
+func TestMenuLayout(t *testing.T) {
+	candidates := []Candidate{{Text: "alpha"}, {Text: "beta"}, {Text: "gamma"}, {Text: "dir/sub/"}}
+	m := newCompletionMenu(candidates, 0, 0, 16)
+	m.selected = 2
+	got := strings.Join(m.render(16), "\n")
+	want := "alpha  \033[7mgamma\033[0m\nbeta   sub/"
+	if got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	m.moveColumn(1)
+	if m.selected != 1 {
+		t.Errorf("moveColumn(1) from the last column = %d, want 1", m.selected)
+	}
+	m.moveColumn(-1)
+	if m.selected != 2 {
+		t.Errorf("moveColumn(-1) from the first column = %d, want 2", m.selected)
+	}
+}
+
+func TestCompleteFlags(t *testing.T) {
+	shell := NewShell()
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"history -", []string{"-r", "-t", "-c", "-d"}},
+		{"export -", []string{"-p"}},
+		{"ls --h", []string{"--help"}},
+		{"echo hi | sort -", []string{"-n", "-h", "-r", "-u", "-k", "-t"}},
+		{"uniq -c", []string{"-c"}},
+	}
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		candidates, _ := shell.Complete(line, len(line))
+		texts := candidateTexts(candidates)
+		if strings.Join(texts, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("Complete(%q) = %v, want %v", tt.line, texts, tt.want)
+		}
+		for _, c := range candidates {
+			if c.Description == "" {
+				t.Errorf("Complete(%q): flag %s has no description", tt.line, c.Text)
+			}
+		}
+	}
+}
+
+func TestMenuDescribed(t *testing.T) {
+	candidates := []Candidate{{"-c", "Prefix lines with their count"}, {"-d", "Only print duplicated lines"}}
+	m := newCompletionMenu(candidates, 0, 0, 20)
+	got := strings.Join(m.render(20), "\n")
+	want := "\033[7m-c  -- Prefix lines \033[0m\n-d  -- Only print du"
+	if got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+}
+
+func TestMenuScroll(t *testing.T) {
+	var candidates []Candidate
+	for i := 0; i < 25; i++ {
+		candidates = append(candidates, Candidate{Text: fmt.Sprintf("item%02d", i)})
+	}
+	m := newCompletionMenu(candidates, 0, 0, 8)
+	m.move(-1)
+	lines := m.render(8)
+	if len(lines) != menuMaxRows+1 || !strings.Contains(lines[menuMaxRows-1], "item24") || !strings.Contains(lines[menuMaxRows], "16-25 of 25") {
+		t.Errorf("render() = %q", lines)
+	}
+}
+
+func TestProgrammableCompletion(t *testing.T) {
+	shell := NewShell()
+	runCapture(t, shell, `complete -c deploy -a 'staging production' -d Environment -f`)
+	runCapture(t, shell, `complete -c deploy -a '(printf "canary\tPartial rollout\n")'`)
+
+	line := []rune("deploy ")
+	candidates, _ := shell.Complete(line, len(line))
+	want := []Candidate{{"staging", "Environment"}, {"production", "Environment"}, {"canary", "Partial rollout"}}
+	if len(candidates) != len(want) {
+		t.Fatalf("Complete(deploy ) = %v, want %v", candidates, want)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidate %d = %v, want %v", i, candidates[i], want[i])
+		}
+	}
+
+	line = []rune("deploy pr")
+	if texts := candidateTexts(first(shell.Complete(line, len(line)))); len(texts) != 1 || texts[0] != "production" {
+		t.Errorf("Complete(deploy pr) = %v, want [production]", texts)
+	}
+
+	out, _ := runCapture(t, shell, "complete")
+	if !strings.Contains(out, `complete -c 'deploy' -a 'staging production' -d 'Environment' -f`) {
+		t.Errorf("complete listing = %q", out)
+	}
+
+	runCapture(t, shell, "complete -e -c deploy")
+	if len(shell.completions["deploy"]) != 0 {
+		t.Error("complete -e did not erase the completions")
+	}
+}
+
+// first returns the first of two results
+func first(candidates []Candidate, _ int) []Candidate {
+	return candidates
+}
+
+func TestCompleteVariable(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("GOSHELL_TEST_ONE", "1")
+	shell.env.Set("GOSHELL_TEST_TWO", "2")
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"echo $GOSHELL_TEST_O", []string{"$GOSHELL_TEST_ONE"}},
+		{"echo ${GOSHELL_TEST_", []string{"${GOSHELL_TEST_ONE}", "${GOSHELL_TEST_TWO}"}},
+		{"cd pre/$GOSHELL_TEST_T", []string{"pre/$GOSHELL_TEST_TWO"}},
+		{"$GOSHELL_TEST_T", []string{"$GOSHELL_TEST_TWO"}},
+	}
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		candidates, _ := shell.Complete(line, len(line))
+		if texts := candidateTexts(candidates); strings.Join(texts, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("Complete(%q) = %v, want %v", tt.line, texts, tt.want)
+		}
+	}
+
+	// Escaped and single-quoted dollars are not references
+	for _, word := range []string{`\$GOSHELL`, `'$GOSHELL`} {
+		if _, ok := shell.completeVariable(word); ok {
+			t.Errorf("completeVariable(%q) treated the word as a reference", word)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	if _, ok := fuzzyMatch("docker-compose.yml", "dckr"); !ok {
+		t.Error("dckr should match docker-compose.yml")
+	}
+	if _, ok := fuzzyMatch("docker", "dkc"); ok {
+		t.Error("dkc should not match docker out of order")
+	}
+	if _, ok := fuzzyMatch("Makefile", "mk"); !ok {
+		t.Error("lowercase patterns should match case-insensitively")
+	}
+	if _, ok := fuzzyMatch("makefile", "MK"); ok {
+		t.Error("patterns with capitals should match case-sensitively")
+	}
+
+	// Contiguous and word-start matches rank higher
+	contiguous, _ := fuzzyMatch("compose.yml", "comp")
+	scattered, _ := fuzzyMatch("cxoxmxp", "comp")
+	if contiguous <= scattered {
+		t.Errorf("contiguous score %d <= scattered score %d", contiguous, scattered)
+	}
+	wordStart, _ := fuzzyMatch("my-cat", "mc")
+	inner, _ := fuzzyMatch("myxcat", "mc")
+	if wordStart <= inner {
+		t.Errorf("word-start score %d <= inner score %d", wordStart, inner)
+	}
+}
+
+func TestFuzzyCompletion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"docker-compose.yml", "dockerfile-notes.txt", "README.md"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	shell := NewShell()
+
+	line := []rune("cat " + dir + "/dckr")
+	if candidates, _ := shell.Complete(line, len(line)); len(candidates) != 0 {
+		t.Errorf("prefix mode matched %v", candidateTexts(candidates))
+	}
+
+	shell.env.Set("GOSHELL_COMPLETION_MODE", "fuzzy")
+	line = []rune("cat " + dir + "/dcmp")
+	texts := candidateTexts(first(shell.Complete(line, len(line))))
+	if len(texts) != 1 || texts[0] != dir+"/docker-compose.yml" {
+		t.Errorf("fuzzy Complete(dcmp) = %v, want docker-compose.yml", texts)
+	}
+	line = []rune("cat " + dir + "/dckr")
+	texts = candidateTexts(first(shell.Complete(line, len(line))))
+	if len(texts) != 2 || texts[0] != dir+"/docker-compose.yml" {
+		t.Errorf("fuzzy Complete(dckr) = %v, want docker-compose.yml first", texts)
+	}
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rcFileName is the startup file read from the user's home directory
+const rcFileName = ".goshellrc"
+
+// rcPath returns the location of the user's startup file
+func (s *Shell) rcPath() string {
+	return filepath.Join(s.homeDir(), rcFileName)
+}
+
+// LoadRC runs each line of the user's startup file. Blank lines and comments
+// are skipped; a missing file is not an error.
+func (s *Shell) LoadRC() error {
+	f, err := os.Open(s.rcPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if status := s.runLine(line); status != 0 {
+			fmt.Fprintf(s.stderr, "%s:%d: command exited with status %d\n", rcFileName, lineNo, status)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,232 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerSegment("kube", "the kubectl context, and its namespace if not default", func(s *Shell) string {
+		kube := s.kubeContext()
+		if kube.context == "" {
+			return ""
+		}
+		text := kube.context
+		if kube.namespace != "" && kube.namespace != "default" {
+			text += ":" + kube.namespace
+		}
+		color := Cyan
+		if productionName.MatchString(kube.context) {
+			color = Bold + Red
+		}
+		return color + "⎈ " + text + Reset
+	})
+	registerSegment("docker", "the Docker context, unless it is the default", func(s *Shell) string {
+		context := s.dockerContext()
+		if context == "" || context == "default" {
+			return ""
+		}
+		return Blue + "🐳 " + context + Reset
+	})
+}
+
+// The kube and docker prompt segments show which cluster and which Docker
+// daemon commands would go to, as kubectl and docker pick them: from the
+// kubeconfig files KUBECONFIG lists, or ~/.kube/config, and from
+// DOCKER_CONTEXT or ~/.docker/config.json. The prompt is drawn often, so
+// each file is only read again once it changes.
+
+// configFiles holds what was parsed from configuration files, parsed again
+// only once a file's size or modification time changes
+type configFiles struct {
+	mu      sync.Mutex
+	entries map[string]*configFile
+}
+
+// configFile is what was parsed from a file, and the file as it was then
+type configFile struct {
+	modTime time.Time
+	size    int64
+	value   any
+}
+
+// read returns what parse makes of a file, or nil if it can't be read
+func (c *configFiles) read(path string, parse func(data []byte) any) any {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[path]; ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.value
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*configFile)
+	}
+	value := parse(data)
+	c.entries[path] = &configFile{modTime: info.ModTime(), size: info.Size(), value: value}
+	return value
+}
+
+// kubeconfig is what the kube segment needs of a kubeconfig file
+type kubeconfig struct {
+	current    string            // current-context
+	namespaces map[string]string // each context's namespace
+}
+
+// kubeContext is a kubectl context and its namespace
+type kubeContext struct {
+	context, namespace string
+}
+
+// kubeContext returns the current kubectl context. As kubectl merges the
+// files KUBECONFIG lists, the first to set current-context decides it, and
+// the first to define that context its namespace.
+func (s *Shell) kubeContext() kubeContext {
+	paths := filepath.SplitList(s.env.Get("KUBECONFIG"))
+	if len(paths) == 0 {
+		paths = []string{filepath.Join(s.homeDir(), ".kube", "config")}
+	}
+	var configs []*kubeconfig
+	var kube kubeContext
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		config, _ := s.configs.read(path, func(data []byte) any { return parseKubeconfig(data) }).(*kubeconfig)
+		if config == nil {
+			continue
+		}
+		configs = append(configs, config)
+		if kube.context == "" {
+			kube.context = config.current
+		}
+	}
+	for _, config := range configs {
+		if ns, ok := config.namespaces[kube.context]; ok {
+			kube.namespace = ns
+			break
+		}
+	}
+	return kube
+}
+
+// parseKubeconfig reads the current context and the contexts' namespaces
+// from a kubeconfig file. It is YAML, or JSON, but only ever of the shape
+// kubectl writes, so the few keys needed are picked out line by line.
+func parseKubeconfig(data []byte) *kubeconfig {
+	config := &kubeconfig{namespaces: make(map[string]string)}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc struct {
+			Current  string `json:"current-context"`
+			Contexts []struct {
+				Name    string `json:"name"`
+				Context struct {
+					Namespace string `json:"namespace"`
+				} `json:"context"`
+			} `json:"contexts"`
+		}
+		if json.Unmarshal(trimmed, &doc) == nil {
+			config.current = doc.Current
+			for _, c := range doc.Contexts {
+				config.namespaces[c.Name] = c.Context.Namespace
+			}
+		}
+		return config
+	}
+
+	var inContexts bool
+	var name, namespace string
+	item := false
+	endItem := func() {
+		if item && name != "" {
+			config.namespaces[name] = namespace
+		}
+		item, name, namespace = false, "", ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		content := strings.TrimSpace(line)
+		if content == "" || content[0] == '#' {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			// A top-level key
+			endItem()
+			key, value, _ := strings.Cut(content, ":")
+			inContexts = key == "contexts"
+			if key == "current-context" {
+				config.current = yamlScalar(value)
+			}
+			continue
+		}
+		if !inContexts {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(content, "- "); ok {
+			endItem()
+			item = true
+			content = rest
+		}
+		key, value, _ := strings.Cut(content, ":")
+		switch key {
+		case "name":
+			name = yamlScalar(value)
+		case "namespace":
+			namespace = yamlScalar(value)
+		}
+	}
+	endItem()
+	return config
+}
+
+// yamlScalar returns the value of a YAML scalar as written after a key,
+// without its quotes or a comment after it
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// dockerContext returns the Docker context docker would use: DOCKER_CONTEXT,
+// or the current context of its configuration. DOCKER_HOST, which docker
+// takes over the configuration, shows as the context.
+func (s *Shell) dockerContext() string {
+	if context := s.env.Get("DOCKER_CONTEXT"); context != "" {
+		return context
+	}
+	if host := s.env.Get("DOCKER_HOST"); host != "" {
+		return host
+	}
+	dir := s.env.Get("DOCKER_CONFIG")
+	if dir == "" {
+		dir = filepath.Join(s.homeDir(), ".docker")
+	}
+	context, _ := s.configs.read(filepath.Join(dir, "config.json"), func(data []byte) any {
+		var config struct {
+			CurrentContext string `json:"currentContext"`
+		}
+		json.Unmarshal(data, &config)
+		return config.CurrentContext
+	}).(string)
+	return context
+}
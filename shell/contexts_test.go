@@ -0,0 +1,100 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseKubeconfig(t *testing.T) {
+	yaml := `apiVersion: v1
+clusters:
+- cluster:
+    server: https://prod.example.com
+  name: prod
+contexts:
+- context:
+    cluster: prod
+    namespace: payments # the team's
+    user: admin
+  name: prod-admin
+- name: "dev"
+  context:
+    cluster: dev
+    user: dev
+current-context: 'prod-admin'
+kind: Config
+users:
+- name: admin
+  user:
+    namespace: not-a-context
+`
+	config := parseKubeconfig([]byte(yaml))
+	if config.current != "prod-admin" {
+		t.Errorf("current = %q, want prod-admin", config.current)
+	}
+	if ns, ok := config.namespaces["prod-admin"]; !ok || ns != "payments" {
+		t.Errorf("namespace of prod-admin = %q, %v, want payments", ns, ok)
+	}
+	if ns, ok := config.namespaces["dev"]; !ok || ns != "" {
+		t.Errorf("namespace of dev = %q, %v, want none", ns, ok)
+	}
+	if len(config.namespaces) != 2 {
+		t.Errorf("namespaces = %v, want prod-admin and dev only", config.namespaces)
+	}
+
+	json := `{"current-context": "dev", "contexts": [{"name": "dev", "context": {"namespace": "web"}}]}`
+	config = parseKubeconfig([]byte(json))
+	if config.current != "dev" || config.namespaces["dev"] != "web" {
+		t.Errorf("parseKubeconfig(JSON) = %+v, want dev in web", config)
+	}
+}
+
+func TestContextSegments(t *testing.T) {
+	home := t.TempDir()
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	for _, name := range []string{"KUBECONFIG", "DOCKER_CONTEXT", "DOCKER_HOST", "DOCKER_CONFIG"} {
+		shell.env.Unset(name)
+	}
+	shell.env.Set("GOSHELL_PROMPT", "{kube} {docker} > ")
+	if got, want := shell.Prompt(), "> "; got != want {
+		t.Errorf("Prompt() without configuration = %q, want %q", got, want)
+	}
+
+	os.MkdirAll(filepath.Join(home, ".kube"), 0755)
+	os.MkdirAll(filepath.Join(home, ".docker"), 0755)
+	kubeconfig := filepath.Join(home, ".kube", "config")
+	os.WriteFile(kubeconfig, []byte("contexts:\n- name: dev\n  context:\n    namespace: default\ncurrent-context: dev\n"), 0600)
+	os.WriteFile(filepath.Join(home, ".docker", "config.json"), []byte(`{"currentContext": "default"}`), 0600)
+	if got, want := shell.Prompt(), Cyan+"⎈ dev"+Reset+" > "; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+
+	// A changed file is read again
+	os.WriteFile(kubeconfig, []byte("contexts:\n- name: prod\n  context:\n    namespace: payments\ncurrent-context: prod\n"), 0600)
+	os.Chtimes(kubeconfig, time.Now(), time.Now().Add(time.Minute))
+	os.WriteFile(filepath.Join(home, ".docker", "config.json"), []byte(`{"currentContext": "colima"}`), 0600)
+	if got, want := shell.Prompt(), Bold+Red+"⎈ prod:payments"+Reset+" "+Blue+"🐳 colima"+Reset+" > "; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+
+	// KUBECONFIG files merge: the first current-context wins, and the
+	// first file defining that context gives its namespace
+	other := filepath.Join(home, "other")
+	os.WriteFile(other, []byte("current-context: staging\ncontexts:\n- name: prod\n  context:\n    namespace: other\n"), 0600)
+	shell.env.Set("KUBECONFIG", other+string(filepath.ListSeparator)+kubeconfig)
+	if got, want := shell.kubeContext(), (kubeContext{context: "staging"}); got != want {
+		t.Errorf("kubeContext() = %+v, want %+v", got, want)
+	}
+	shell.env.Set("KUBECONFIG", filepath.Join(home, "missing")+string(filepath.ListSeparator)+kubeconfig+string(filepath.ListSeparator)+other)
+	if got, want := shell.kubeContext(), (kubeContext{context: "prod", namespace: "payments"}); got != want {
+		t.Errorf("kubeContext() = %+v, want %+v", got, want)
+	}
+
+	shell.env.Set("DOCKER_CONTEXT", "remote")
+	if got := shell.dockerContext(); got != "remote" {
+		t.Errorf("dockerContext() = %q, want remote from DOCKER_CONTEXT", got)
+	}
+}
@@ -0,0 +1,130 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// The shell tracks its working directory logically, as bash and zsh do: after
+// cd into a symlink the path keeps the link's name, and cd .. leaves the link
+// rather than going to its target's parent. The physical directory is what
+// the kernel reports, with every symlink resolved. A subshell, which a
+// pipeline stage runs in, has a working directory of its own and leaves the
+// process's alone.
+
+// initCwd sets the logical working directory at startup. PWD is trusted when
+// it names the directory the shell was started in, so a shell started from a
+// symlinked directory keeps its name.
+func (s *Shell) initCwd() {
+	physical, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	s.cwd = physical
+	if pwd := s.env.Get("PWD"); filepath.IsAbs(pwd) && sameDir(pwd, physical) {
+		s.cwd = filepath.Clean(pwd)
+	}
+	s.env.Set("PWD", s.cwd)
+}
+
+// Getwd returns the logical working directory. If something other than cd
+// changed the directory, or it was moved, the physical one is returned.
+func (s *Shell) Getwd() (string, error) {
+	if s.subshell {
+		return s.cwd, nil
+	}
+	physical, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if s.cwd != "" && (s.cwd == physical || sameDir(s.cwd, physical)) {
+		return s.cwd, nil
+	}
+	return physical, nil
+}
+
+// physicalWd returns the working directory with every symlink resolved
+func physicalWd() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(dir)
+}
+
+// chdir changes the working directory. Logically, a relative path is joined
+// to the logical directory and .. removes the component before it, as the
+// text reads; physically, the path is resolved by the kernel. A logical
+// change that fails, as when .. leaves a link into a directory that no longer
+// has that parent, is retried physically.
+func (s *Shell) chdir(path string, physical bool) error {
+	if s.subshell {
+		return s.subshellChdir(path, physical)
+	}
+	if !physical {
+		target := path
+		if !filepath.IsAbs(target) {
+			current, err := s.Getwd()
+			if err != nil {
+				return err
+			}
+			target = filepath.Join(current, target)
+		}
+		target = filepath.Clean(target)
+		if os.Chdir(target) == nil {
+			s.setCwd(target)
+			return nil
+		}
+	}
+	if err := os.Chdir(path); err != nil {
+		return err
+	}
+	dir, err := physicalWd()
+	if err != nil {
+		return err
+	}
+	s.setCwd(dir)
+	return nil
+}
+
+// subshellChdir changes a subshell's working directory, which only its
+// Getwd sees
+func (s *Shell) subshellChdir(path string, physical bool) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.cwd, path)
+	}
+	target := filepath.Clean(path)
+	if physical {
+		resolved, err := filepath.EvalSymlinks(target)
+		if err != nil {
+			return err
+		}
+		target = resolved
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "chdir", Path: target, Err: syscall.ENOTDIR}
+	}
+	s.setCwd(target)
+	return nil
+}
+
+// setCwd records the logical working directory and exports it as PWD
+func (s *Shell) setCwd(dir string) {
+	s.cwd = dir
+	s.env.Set("PWD", dir)
+}
+
+// sameDir reports whether two paths name the same directory
+func sameDir(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
@@ -0,0 +1,190 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"goshell/internal/lineedit"
+)
+
+func init() {
+	registerBuiltin("df", "df [-h] [path...]", "Show the space used and free on mounted filesystems", builtinDf)
+	registerFlags("df", Candidate{"-h", "Show sizes in KiB, MiB and GiB"})
+}
+
+// filesystem is a mounted filesystem and the space on it, in bytes
+type filesystem struct {
+	device string // what is mounted, such as /dev/sda1
+	mount  string // where it is mounted
+	size   int64
+	free   int64 // free space, some of it perhaps reserved for root
+	avail  int64 // free space ordinary users can take
+}
+
+// used returns the space taken on the filesystem
+func (fs filesystem) used() int64 {
+	return fs.size - fs.free
+}
+
+// percentUsed returns how full the filesystem is for ordinary users,
+// rounding up as df does
+func (fs filesystem) percentUsed() int {
+	total := fs.used() + fs.avail
+	if total <= 0 {
+		return 0
+	}
+	return int((fs.used()*100 + total - 1) / total)
+}
+
+// builtinDf shows the size, used and free space of the mounted
+// filesystems, or of those the given paths are on, as df does: in
+// kilobytes, or in KiB, MiB and GiB with -h. Filesystems without a size,
+// such as /proc, are left out of the full list.
+func builtinDf(s *Shell, args []string, stdio Stdio) int {
+	var human bool
+	flags := newFlagSet("df")
+	flags.Bool(&human, "h")
+	paths, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	mounted, err := mountedFilesystems()
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "df:", err)
+		return 1
+	}
+
+	status := 0
+	var shown []filesystem
+	if len(paths) == 0 {
+		for _, fs := range mounted {
+			if fs.size > 0 {
+				shown = append(shown, fs)
+			}
+		}
+	}
+	for _, path := range paths {
+		fs, err := filesystemOf(path, mounted)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "df:", unwrapPathError(err))
+			status = 1
+			continue
+		}
+		shown = append(shown, fs)
+	}
+	if len(shown) > 0 {
+		printFilesystems(stdio.Stdout, shown, human, isTerminal(stdio.Stdout))
+	}
+	return status
+}
+
+// filesystemOf returns the filesystem path is on, with symlinks resolved
+func filesystemOf(path string, mounted []filesystem) (filesystem, error) {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	if err != nil {
+		return filesystem{}, err
+	}
+	found, ok := mountOf(abs, mounted)
+	if !ok {
+		return found, fmt.Errorf("%s: no filesystem found", path)
+	}
+	return found, nil
+}
+
+// mountOf returns the filesystem mounted on the longest leading part of an
+// absolute path
+func mountOf(path string, mounted []filesystem) (filesystem, bool) {
+	var found filesystem
+	for _, fs := range mounted {
+		if len(fs.mount) >= len(found.mount) && isPathPrefix(fs.mount, path) {
+			found = fs
+		}
+	}
+	return found, found.mount != ""
+}
+
+// isPathPrefix reports whether dir is path or one of the directories above
+// it
+func isPathPrefix(dir, path string) bool {
+	rest, ok := strings.CutPrefix(path, dir)
+	return ok && (rest == "" || strings.HasSuffix(dir, string(filepath.Separator)) || rest[0] == filepath.Separator)
+}
+
+// printFilesystems prints a table of filesystems, coloring how full each is
+// and where it is mounted when color is set
+func printFilesystems(w io.Writer, filesystems []filesystem, human, color bool) {
+	size := func(n int64) string {
+		if human {
+			return formatSize(n)
+		}
+		return strconv.FormatInt((n+1023)/1024, 10)
+	}
+	sizeHeader := "1K-blocks"
+	if human {
+		sizeHeader = "Size"
+	}
+	rows := [][]string{{"Filesystem", sizeHeader, "Used", "Avail", "Use%", "Mounted on"}}
+	for _, fs := range filesystems {
+		percent := fs.percentUsed()
+		use := strconv.Itoa(percent) + "%"
+		mount := fs.mount
+		if color {
+			use = usageColor(percent) + use + Reset
+			mount = kindColors[kindDir] + mount + Reset
+		}
+		rows = append(rows, []string{fs.device, size(fs.size), size(fs.used()), size(fs.avail), use, mount})
+	}
+
+	// Names are aligned to the left and numbers to the right
+	widths := make([]int, 5)
+	for _, row := range rows {
+		for i := range widths {
+			widths[i] = max(widths[i], lineedit.StringWidth(row[i]))
+		}
+	}
+	for i, row := range rows {
+		line := padRight(row[0], widths[0])
+		for j := 1; j < 5; j++ {
+			line += " " + padLeft(row[j], widths[j])
+		}
+		line += " " + row[5]
+		if i == 0 && color {
+			line = Bold + line + Reset
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// usageColor returns the color for how full a filesystem is: green while
+// there is plenty of space, yellow from 75% and red from 90%
+func usageColor(percent int) string {
+	switch {
+	case percent >= 90:
+		return Red
+	case percent >= 75:
+		return Yellow
+	}
+	return Green
+}
+
+// visibleFilesystems keeps only the last of the filesystems mounted on
+// the same directory, as it hides the others
+func visibleFilesystems(filesystems []filesystem) []filesystem {
+	seen := make(map[string]int)
+	var kept []filesystem
+	for _, fs := range filesystems {
+		if i, ok := seen[fs.mount]; ok {
+			kept[i] = fs
+			continue
+		}
+		seen[fs.mount] = len(kept)
+		kept = append(kept, fs)
+	}
+	return kept
+}
@@ -0,0 +1,44 @@
+package shell
+
+import "syscall"
+
+// mntNowait is getfsstat's MNT_NOWAIT, to report the statistics it has at
+// hand rather than wait on every filesystem
+const mntNowait = 2
+
+// mountedFilesystems returns the mounted filesystems with the space on
+// each, as getfsstat reports them
+func mountedFilesystems() ([]filesystem, error) {
+	n, err := syscall.Getfsstat(nil, mntNowait)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]syscall.Statfs_t, n)
+	if n, err = syscall.Getfsstat(stats, mntNowait); err != nil {
+		return nil, err
+	}
+	var filesystems []filesystem
+	for _, st := range stats[:n] {
+		block := int64(st.Bsize)
+		filesystems = append(filesystems, filesystem{
+			device: cString(st.Mntfromname[:]),
+			mount:  cString(st.Mntonname[:]),
+			size:   int64(st.Blocks) * block,
+			free:   int64(st.Bfree) * block,
+			avail:  int64(st.Bavail) * block,
+		})
+	}
+	return visibleFilesystems(filesystems), nil
+}
+
+// cString converts a NUL-terminated C string to a Go string
+func cString(chars []int8) string {
+	b := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
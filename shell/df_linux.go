@@ -0,0 +1,61 @@
+package shell
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// mountedFilesystems returns the filesystems in /proc/self/mounts with the
+// space on each. Those that can't be queried are given no size.
+func mountedFilesystems() ([]filesystem, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var filesystems []filesystem
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		fs := filesystem{device: unescapeMountField(fields[0]), mount: unescapeMountField(fields[1])}
+		var st syscall.Statfs_t
+		if syscall.Statfs(fs.mount, &st) == nil {
+			block := int64(st.Bsize)
+			fs.size = int64(st.Blocks) * block
+			fs.free = int64(st.Bfree) * block
+			fs.avail = int64(st.Bavail) * block
+		}
+		filesystems = append(filesystems, fs)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return visibleFilesystems(filesystems), nil
+}
+
+// unescapeMountField decodes the octal escapes such as \040 for a space
+// that the mount table writes in paths
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
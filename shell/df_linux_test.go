@@ -0,0 +1,11 @@
+package shell
+
+import "testing"
+
+func TestUnescapeMountField(t *testing.T) {
+	for field, want := range map[string]string{`/mnt/My\040Disk`: "/mnt/My Disk", `/plain`: "/plain", `/odd\04`: `/odd\04`} {
+		if got := unescapeMountField(field); got != want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package shell
+
+import "errors"
+
+// mountedFilesystems isn't supported on this platform
+func mountedFilesystems() ([]filesystem, error) {
+	return nil, errors.New("listing filesystems isn't supported on this system")
+}
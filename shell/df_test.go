@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMountOf(t *testing.T) {
+	mounted := []filesystem{{device: "root", mount: "/"}, {device: "home", mount: "/home"}, {device: "homer", mount: "/homer"}}
+	for path, want := range map[string]string{"/": "root", "/home": "home", "/home/me": "home", "/homer/x": "homer", "/etc": "root"} {
+		if found, _ := mountOf(path, mounted); found.device != want {
+			t.Errorf("filesystem of %s = %q, want %q", path, found.device, want)
+		}
+	}
+}
+
+func TestPrintFilesystems(t *testing.T) {
+	var out bytes.Buffer
+	printFilesystems(&out, []filesystem{
+		{device: "/dev/sda1", mount: "/", size: 100 << 20, free: 30 << 20, avail: 20 << 20},
+		{device: "tmpfs", mount: "/tmp", size: 8 << 10, free: 8 << 10, avail: 8 << 10},
+	}, false, false)
+	want := "Filesystem 1K-blocks  Used Avail Use% Mounted on\n" +
+		"/dev/sda1     102400 71680 20480  78% /\n" +
+		"tmpfs              8     0     8   0% /tmp\n"
+	if out.String() != want {
+		t.Errorf("df table =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestVisibleFilesystems(t *testing.T) {
+	got := visibleFilesystems([]filesystem{{device: "a", mount: "/"}, {device: "b", mount: "/mnt"}, {device: "c", mount: "/"}})
+	if len(got) != 2 || got[0].device != "c" || got[1].device != "b" {
+		t.Errorf("visible filesystems = %v", got)
+	}
+}
@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Each session remembers the directories cd has left, most recent first, so
+// cd -N can go back to any of them, cd --list shows them numbered and
+// cd --pick chooses one from a menu. cd - is cd -1.
+
+// dirHistorySize is how many directories a session remembers
+const dirHistorySize = 50
+
+// rememberDir records a directory cd has left. A directory left again moves
+// to the front rather than appearing twice.
+func (s *Shell) rememberDir(dir string) {
+	s.dirHistory = slices.DeleteFunc(s.dirHistory, func(d string) bool { return d == dir })
+	s.dirHistory = append(s.dirHistory, dir)
+	if len(s.dirHistory) > dirHistorySize {
+		s.dirHistory = slices.Delete(s.dirHistory, 0, len(s.dirHistory)-dirHistorySize)
+	}
+}
+
+// recentDirs returns the remembered directories, most recent first, leaving
+// out the working directory
+func (s *Shell) recentDirs() []string {
+	cwd, _ := s.Getwd()
+	var dirs []string
+	for i := len(s.dirHistory) - 1; i >= 0; i-- {
+		if s.dirHistory[i] != cwd {
+			dirs = append(dirs, s.dirHistory[i])
+		}
+	}
+	return dirs
+}
+
+// isDirIndex reports whether arg is -N, naming a recent directory
+func isDirIndex(arg string) bool {
+	n, err := strconv.Atoi(arg)
+	return err == nil && n < 0
+}
+
+// recentDir returns the directory -N names
+func (s *Shell) recentDir(index string) (string, error) {
+	n, _ := strconv.Atoi(index[1:])
+	dirs := s.recentDirs()
+	if n > len(dirs) {
+		return "", fmt.Errorf("%s: only %d recent %s", index, len(dirs), plural(len(dirs), "directory", "directories"))
+	}
+	return dirs[n-1], nil
+}
+
+// listDirHistory prints the recent directories numbered for cd -N
+func (s *Shell) listDirHistory(stdio Stdio) {
+	for i, dir := range s.recentDirs() {
+		fmt.Fprintf(stdio.Stdout, "%2d  %s\n", i+1, s.tildePath(dir))
+	}
+}
+
+// pickDirHistory lets the user choose a recent directory, returning "" if
+// the choice was cancelled
+func (s *Shell) pickDirHistory(stdio Stdio) (string, error) {
+	dirs := s.recentDirs()
+	if len(dirs) == 0 {
+		return "", errors.New("no recent directories")
+	}
+	shown := make([]string, len(dirs))
+	for i, dir := range dirs {
+		shown[i] = s.tildePath(dir)
+	}
+	i, err := s.pick(shown, stdio)
+	if err != nil || i < 0 {
+		return "", err
+	}
+	return dirs[i], nil
+}
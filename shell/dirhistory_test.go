@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+func TestDirHistory(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	shell := NewShell()
+	shell.env.Set("HOME", root)
+	for _, name := range []string{"a", "b", "c", "a"} {
+		if _, status := runCapture(t, shell, "cd "+filepath.Join(root, name)); status != 0 {
+			t.Fatalf("cd %s failed", name)
+		}
+	}
+
+	// a was left twice, so it appears once, and not at all while it is the
+	// working directory
+	want := " 1  ~/c\n 2  ~/b\n 3  " + start + "\n"
+	if out, _ := runCapture(t, shell, "cd --list"); out != want {
+		t.Errorf("cd --list = %q, want %q", out, want)
+	}
+	if out, status := runCapture(t, shell, "cd -2"); out != filepath.Join(root, "b")+"\n" || status != 0 {
+		t.Errorf("cd -2 = %q (status %d)", out, status)
+	}
+	if dir, _ := shell.Getwd(); dir != filepath.Join(root, "b") {
+		t.Errorf("after cd -2 the directory is %s", dir)
+	}
+	if out, _ := runCapture(t, shell, "cd --list"); !strings.HasPrefix(out, " 1  ~/a\n 2  ~/c\n") {
+		t.Errorf("cd --list after cd -2 = %q", out)
+	}
+	for _, line := range []string{"cd -9", "cd --list x", "cd --pick"} {
+		if _, status := runCapture(t, shell, line); status != 1 {
+			t.Errorf("%s exited with %d, want 1", line, status)
+		}
+	}
+}
+
+func TestRunPicker(t *testing.T) {
+	items := []string{"one", "two", "three"}
+	for _, tt := range []struct {
+		keys string
+		want int
+	}{
+		{"\r", 0},
+		{"\x1b[B\x1b[B\r", 2},
+		{"\x1b[A\r", 2},
+		{"jjk\r", 1},
+		{"3", 2},
+		{"9j\r", 1},
+		{"q", -1},
+		{"\x07", -1},
+	} {
+		keys := lineedit.ParseKeys(tt.keys)
+		readKey := func() (lineedit.Key, error) {
+			if len(keys) == 0 {
+				return 0, io.EOF
+			}
+			key := keys[0]
+			keys = keys[1:]
+			return key, nil
+		}
+		got, err := runPicker(io.Discard, readKey, items, 80)
+		if got != tt.want || err != nil {
+			t.Errorf("picking with %q = %d, %v, want %d", tt.keys, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package shell
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("dotenv", "dotenv [allow|deny|reload] [FILE]", "Show, allow or deny the environment file of the working directory", builtinDotenv)
+	registerArgs("dotenv", func(s *Shell, word string) []Candidate {
+		var candidates []Candidate
+		for _, c := range []Candidate{
+			{"allow", "Trust the environment file and load it"},
+			{"deny", "Stop trusting the environment file and unload it"},
+			{"reload", "Load the environment file again"},
+		} {
+			if s.matchWord(c.Text, word) {
+				candidates = append(candidates, c)
+			}
+		}
+		return candidates
+	})
+}
+
+// A directory can hold an environment file, .goshell.env or .env, with
+// variables for working in it, as direnv's .envrc does. When the working
+// directory is in the tree under such a file the shell sets its variables,
+// and when it leaves the tree it puts back the values they had before. As
+// the file may come with anything cloned or unpacked, it is only loaded once
+// dotenv allow has said it can be trusted, and again each time it changes.
+
+// dotenvNames are the environment files looked for, in order of preference
+var dotenvNames = []string{".goshell.env", ".env"}
+
+// allowedEnvFileName is the file in the home directory the trusted
+// environment files are kept in, as the SHA-256 of the contents they were
+// allowed with and their path, separated by a tab
+const allowedEnvFileName = ".goshell_allowed_env"
+
+// dotenvState is the environment file in effect
+type dotenvState struct {
+	path    string
+	stamp   fileStamp
+	allowed bool
+	// The values the variables it set had before, to put back; a variable
+	// that wasn't set has no entry in previous
+	names    []string
+	previous map[string]string
+}
+
+// dotenvVar is a variable an environment file sets
+type dotenvVar struct {
+	name, value string
+	expand      bool // the value was unquoted or in double quotes
+}
+
+// allowedEnvPath returns the file trusted environment files are kept in
+func (s *Shell) allowedEnvPath() string {
+	return filepath.Join(s.homeDir(), allowedEnvFileName)
+}
+
+// findDotenv returns the environment file nearest the working directory, in
+// it or a directory above it, or "" if there is none
+func (s *Shell) findDotenv() string {
+	dir, err := s.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range dotenvNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// updateDotenv brings the environment in line with the working directory:
+// it unloads the environment file in effect if the working directory has
+// left its tree or it has changed, and loads the one for the working
+// directory if it is allowed, or says how to allow it. It is called on
+// changing directory and before each prompt, so edits to the file are
+// picked up.
+func (s *Shell) updateDotenv(stdio Stdio) {
+	path := s.findDotenv()
+	current := s.dotenv
+	if current != nil && current.path == path && current.stamp == statStamp(path) {
+		return
+	}
+	s.unloadDotenv(stdio)
+	if path == "" {
+		return
+	}
+	s.dotenv = &dotenvState{path: path, stamp: statStamp(path)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "dotenv:", unwrapPathError(err))
+		return
+	}
+	allowed, err := s.isEnvFileAllowed(path, data)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "dotenv:", unwrapPathError(err))
+		return
+	}
+	if !allowed {
+		fmt.Fprintf(stdio.Stderr, "dotenv: %s isn't allowed; run dotenv allow to load it\n", s.tildePath(path))
+		return
+	}
+	s.loadDotenv(path, data, stdio)
+}
+
+// loadDotenv sets the variables of an allowed environment file, noting the
+// values they had
+func (s *Shell) loadDotenv(path string, data []byte, stdio Stdio) {
+	state := s.dotenv
+	state.allowed = true
+	state.previous = make(map[string]string)
+	vars, errs := parseDotenv(string(data))
+	for _, err := range errs {
+		fmt.Fprintf(stdio.Stderr, "dotenv: %s:%v\n", s.tildePath(path), err)
+	}
+	var changes []string
+	for _, v := range vars {
+		value := v.value
+		if v.expand {
+			value = os.Expand(value, s.env.Get)
+		}
+		if !slices.Contains(state.names, v.name) {
+			state.names = append(state.names, v.name)
+			if old, ok := s.env.Lookup(v.name); ok {
+				state.previous[v.name] = old
+				changes = append(changes, "~"+v.name)
+			} else {
+				changes = append(changes, "+"+v.name)
+			}
+		}
+		s.env.Set(v.name, value)
+	}
+	fmt.Fprintf(stdio.Stderr, "dotenv: loaded %s %s\n", s.tildePath(path), strings.Join(changes, " "))
+}
+
+// unloadDotenv puts back the variables the environment file in effect set
+func (s *Shell) unloadDotenv(stdio Stdio) {
+	state := s.dotenv
+	s.dotenv = nil
+	if state == nil || !state.allowed {
+		return
+	}
+	for i := len(state.names) - 1; i >= 0; i-- {
+		name := state.names[i]
+		if old, ok := state.previous[name]; ok {
+			s.env.Set(name, old)
+		} else {
+			s.env.Unset(name)
+		}
+	}
+	fmt.Fprintf(stdio.Stderr, "dotenv: unloaded %s\n", s.tildePath(state.path))
+}
+
+// parseDotenv reads the variables of an environment file: NAME=VALUE lines,
+// optionally after export, with blank lines and # comments. A value in
+// single quotes is taken as it is; one unquoted or in double quotes has its
+// $NAME and ${NAME} references expanded, and in double quotes \n, \t, \"
+// and \\ escapes. Lines that can't be read are returned as errors with
+// their line number.
+func parseDotenv(data string) ([]dotenvVar, []error) {
+	var vars []dotenvVar
+	var errs []error
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !isVariableName(name) {
+			errs = append(errs, fmt.Errorf("%d: not a NAME=VALUE line", lineNo))
+			continue
+		}
+		v, err := parseDotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d: %v", lineNo, err))
+			continue
+		}
+		v.name = name
+		vars = append(vars, v)
+	}
+	return vars, errs
+}
+
+// parseDotenvValue reads the value of an environment file line
+func parseDotenvValue(text string) (dotenvVar, error) {
+	switch {
+	case strings.HasPrefix(text, "'"):
+		value, _, ok := strings.Cut(text[1:], "'")
+		if !ok {
+			return dotenvVar{}, errors.New("unterminated quote")
+		}
+		return dotenvVar{value: value}, nil
+	case strings.HasPrefix(text, `"`):
+		var b strings.Builder
+		for i := 1; i < len(text); i++ {
+			switch c := text[i]; {
+			case c == '"':
+				return dotenvVar{value: b.String(), expand: true}, nil
+			case c == '\\' && i+1 < len(text):
+				i++
+				switch text[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\':
+					b.WriteByte(text[i])
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(text[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return dotenvVar{}, errors.New("unterminated quote")
+	}
+	// An unquoted value ends at a comment
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = strings.TrimSpace(text[:i])
+	}
+	return dotenvVar{value: text, expand: true}, nil
+}
+
+// envFileHash identifies the contents an environment file was allowed with
+func envFileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadAllowedEnv reads the trusted environment files, mapping their paths
+// to the hashes of their contents
+func (s *Shell) loadAllowedEnv() (map[string]string, error) {
+	allowed := make(map[string]string)
+	data, err := os.ReadFile(s.allowedEnvPath())
+	if os.IsNotExist(err) {
+		return allowed, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if hash, path, ok := strings.Cut(line, "\t"); ok {
+			allowed[path] = hash
+		}
+	}
+	return allowed, nil
+}
+
+// saveAllowedEnv writes the trusted environment files, sorted by path
+func (s *Shell) saveAllowedEnv(allowed map[string]string) error {
+	paths := make([]string, 0, len(allowed))
+	for path := range allowed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "%s\t%s\n", allowed[path], path)
+	}
+	return os.WriteFile(s.allowedEnvPath(), []byte(b.String()), 0600)
+}
+
+// isEnvFileAllowed reports whether an environment file was allowed with
+// the contents it has now
+func (s *Shell) isEnvFileAllowed(path string, data []byte) (bool, error) {
+	allowed, err := s.loadAllowedEnv()
+	if err != nil {
+		return false, err
+	}
+	return allowed[path] == envFileHash(data), nil
+}
+
+// builtinDotenv shows the environment file of the working directory and
+// whether it is loaded, and with allow or deny trusts it or stops trusting
+// it. A FILE, or the environment file of a directory, can be given in place
+// of the working directory's. reload loads the file again.
+func builtinDotenv(s *Shell, args []string, stdio Stdio) int {
+	if len(args) > 3 {
+		return newFlagSet("dotenv").usage(stdio)
+	}
+	action := "status"
+	if len(args) > 1 {
+		action = args[1]
+	}
+	path := s.findDotenv()
+	if len(args) == 3 {
+		path = args[2]
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			for _, name := range dotenvNames {
+				if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+					path = filepath.Join(path, name)
+					break
+				}
+			}
+		}
+		path, _ = filepath.Abs(path)
+	}
+
+	switch action {
+	case "status":
+		if len(args) == 3 {
+			return newFlagSet("dotenv").usage(stdio)
+		}
+		state := s.dotenv
+		switch {
+		case state == nil:
+			fmt.Fprintln(stdio.Stdout, "No environment file")
+		case !state.allowed:
+			fmt.Fprintf(stdio.Stdout, "%s isn't allowed\n", s.tildePath(state.path))
+		default:
+			fmt.Fprintf(stdio.Stdout, "%s is loaded\n", s.tildePath(state.path))
+			for _, name := range state.names {
+				fmt.Fprintf(stdio.Stdout, "  %s=%s\n", name, s.env.Get(name))
+			}
+		}
+		return 0
+	case "allow", "deny":
+		if path == "" {
+			fmt.Fprintln(stdio.Stderr, "dotenv: no environment file here")
+			return 1
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "dotenv:", unwrapPathError(err))
+			return 1
+		}
+		allowed, err := s.loadAllowedEnv()
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "dotenv:", unwrapPathError(err))
+			return 1
+		}
+		if action == "allow" {
+			allowed[path] = envFileHash(data)
+		} else {
+			delete(allowed, path)
+		}
+		if err := s.saveAllowedEnv(allowed); err != nil {
+			fmt.Fprintln(stdio.Stderr, "dotenv:", unwrapPathError(err))
+			return 1
+		}
+		s.unloadDotenv(stdio)
+		s.updateDotenv(stdio)
+		return 0
+	case "reload":
+		s.unloadDotenv(stdio)
+		s.updateDotenv(stdio)
+		return 0
+	}
+	return newFlagSet("dotenv").usage(stdio)
+}
@@ -0,0 +1,77 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	vars, errs := parseDotenv(`# settings
+export API_URL=http://localhost:8080 # local
+NAME='$literal value'
+GREETING="hello\t$NAME\n"
+
+not a variable
+EMPTY=
+`)
+	want := []dotenvVar{
+		{name: "API_URL", value: "http://localhost:8080", expand: true},
+		{name: "NAME", value: "$literal value"},
+		{name: "GREETING", value: "hello\t$NAME\n", expand: true},
+		{name: "EMPTY", expand: true},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("parseDotenv = %+v, want %+v", vars, want)
+	}
+	if len(errs) != 1 || errs[0].Error() != "6: not a NAME=VALUE line" {
+		t.Errorf("parseDotenv errors = %v", errs)
+	}
+}
+
+func TestDotenvLoading(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "project")
+	os.MkdirAll(filepath.Join(project, "src"), 0755)
+	os.WriteFile(filepath.Join(project, ".env"), []byte("TOKEN=abc\nEDITOR=nano\nURL=$TOKEN.example.com\n"), 0644)
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	var errs syncBuffer
+	shell := New(Options{Stderr: &errs, Env: []string{"HOME=" + home, "EDITOR=vi"}})
+
+	// Until it is allowed the file is only mentioned
+	shell.changeDir(project, false)
+	if _, ok := shell.env.Lookup("TOKEN"); ok || !strings.Contains(errs.String(), "isn't allowed") {
+		t.Fatalf("a file not allowed was loaded, or not mentioned: %q", errs.String())
+	}
+	if out, status := runCapture(t, shell, "dotenv allow"); status != 0 {
+		t.Fatalf("dotenv allow = %q (status %d)", out, status)
+	}
+	if got := shell.env.Get("URL"); got != "abc.example.com" || shell.env.Get("EDITOR") != "nano" {
+		t.Errorf("after dotenv allow URL = %q, EDITOR = %q", got, shell.env.Get("EDITOR"))
+	}
+
+	// Its variables stay below the directory and go on leaving it
+	shell.changeDir("src", false)
+	if shell.env.Get("TOKEN") != "abc" {
+		t.Error("TOKEN was unloaded in a subdirectory")
+	}
+	shell.changeDir(home, false)
+	if _, ok := shell.env.Lookup("TOKEN"); ok || shell.env.Get("EDITOR") != "vi" {
+		t.Errorf("after leaving the project TOKEN is set or EDITOR = %q", shell.env.Get("EDITOR"))
+	}
+
+	// A change to the file has to be allowed again
+	os.WriteFile(filepath.Join(project, ".env"), []byte("TOKEN=stolen\n"), 0644)
+	shell.changeDir(project, false)
+	if _, ok := shell.env.Lookup("TOKEN"); ok {
+		t.Error("a changed file was loaded without being allowed again")
+	}
+	runCapture(t, shell, "dotenv allow")
+	runCapture(t, shell, "dotenv deny")
+	if _, ok := shell.env.Lookup("TOKEN"); ok {
+		t.Error("TOKEN is still set after dotenv deny")
+	}
+}
@@ -0,0 +1,133 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("dryrun", "dryrun COMMAND...", "Show what a command line would run, expanded, without running it", builtinDryRun)
+}
+
+// A command line starting with dryrun, or any typed with set -o dryrun, is
+// parsed and expanded as usual, its aliases, variables and wildcards
+// included, and each pipeline it would run is shown rather than run: the
+// commands with their arguments quoted as they would reach them, and the
+// files redirected to or from and what would happen to them. Process
+// substitutions aren't started, and each pipeline is taken to succeed, so
+// the commands after && are shown and those after || aren't. set itself
+// runs, so set +o dryrun turns the option off.
+
+// dryRunPrefix starts a command line to show rather than run
+const dryRunPrefix = "dryrun"
+
+// cutDryRunPrefix removes dryrun from the start of a command line,
+// reporting whether it was there
+func cutDryRunPrefix(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimLeft(line, " \t"), dryRunPrefix)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return line, false
+	}
+	return rest, true
+}
+
+// quoteIfNeeded quotes a word unless the shell would read it back unchanged
+// as it is
+func quoteIfNeeded(word string) string {
+	if word == "" || strings.HasPrefix(word, "~") {
+		return shellQuote(word)
+	}
+	for _, c := range word {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:=@%+,~", c)) {
+			return shellQuote(word)
+		}
+	}
+	return word
+}
+
+// showDryRun writes the commands of an expanded pipeline, and its
+// redirections, in place of running it
+func (s *Shell) showDryRun(p *parser.Pipeline, stages []*stage, stdio Stdio) {
+	var parts []string
+	var notes []string
+	for i, st := range stages {
+		words := make([]string, len(st.args))
+		for j, arg := range st.args {
+			if isProcessSubstitution(arg) {
+				words[j] = arg
+			} else {
+				words[j] = quoteIfNeeded(arg)
+			}
+		}
+		// Remote commands are looked for on the remote host when they run
+		if len(st.args) > 0 && s.remote == nil {
+			if _, ok := s.lookupBuiltin(st.args[0]); !ok && !s.isAutoCd(st.args[0]) {
+				if _, err := s.commandPath(st.args[0]); err != nil {
+					notes = append(notes, st.args[0]+": command not found")
+				}
+			}
+		}
+		for _, r := range p.Commands[i].Redirects {
+			if r.Op == "2>&1" {
+				words = append(words, r.Op)
+				continue
+			}
+			target, note := s.dryRunTarget(r)
+			words = append(words, r.Op+" "+quoteIfNeeded(target))
+			if note != "" {
+				notes = append(notes, note)
+			}
+		}
+		parts = append(parts, strings.Join(words, " "))
+	}
+	line := "+ " + strings.Join(parts, " | ")
+	if len(notes) > 0 {
+		line += "  # " + strings.Join(notes, "; ")
+	}
+	fmt.Fprintln(stdio.Stdout, line)
+}
+
+// dryRunTarget expands the file of a redirection, as it would be opened,
+// and says what opening it would do
+func (s *Shell) dryRunTarget(r parser.Redirect) (string, string) {
+	targets := s.expandWord(r.Target)
+	if len(targets) != 1 {
+		return r.Target, r.Target + ": ambiguous redirect"
+	}
+	target := targets[0]
+	if strings.HasPrefix(r.Target, "%") {
+		path, err := s.fifoPath(target[1:])
+		if err != nil {
+			return target, err.Error()
+		}
+		return target, "named pipe " + path
+	}
+	info, err := os.Stat(target)
+	switch {
+	case r.Op == "<" && err != nil:
+		return target, fmt.Sprintf("%s: %v", target, unwrapPathError(err))
+	case r.Op == "<":
+		return target, ""
+	case err != nil:
+		return target, target + " would be created"
+	case info.Mode().IsRegular() && (r.Op == ">" || r.Op == "2>"):
+		return target, target + " would be overwritten"
+	case info.Mode().IsRegular():
+		return target, target + " would be appended to"
+	}
+	return target, ""
+}
+
+// builtinDryRun shows the command its arguments make. It only runs as a
+// builtin where it doesn't start the line, as in a pipeline; at the start
+// of a line it turns the whole line into a dry run.
+func builtinDryRun(s *Shell, args []string, stdio Stdio) int {
+	if len(args) < 2 {
+		return newFlagSet("dryrun").usage(stdio)
+	}
+	s.showDryRun(&parser.Pipeline{Commands: []*parser.Command{{}}}, []*stage{{args: args[1:]}}, stdio)
+	return 0
+}
@@ -0,0 +1,47 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "out.txt"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	shell := New(Options{Env: []string{"NAME=two words"}})
+	shell.changeDir(dir, false)
+
+	var out syncBuffer
+	stdio := Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	status := shell.runLineWith(`dryrun rm *.log && echo "$NAME" > out.txt >> new.txt 2>&1 || rm -rf build; cat <(ls) x | gti`, stdio)
+	want := "+ rm a.log b.log\n" +
+		"+ echo 'two words' > out.txt >> new.txt 2>&1  # out.txt would be overwritten; new.txt would be created\n" +
+		"+ cat <(ls) x | gti  # gti: command not found\n"
+	if status != 0 || out.String() != want {
+		t.Errorf("dryrun shows %q (status %d), want %q", out.String(), status, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.log")); err != nil || shell.dryRun {
+		t.Errorf("dryrun ran the line: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); err == nil {
+		t.Error("dryrun created a file redirected to")
+	}
+
+	// With the option on, only set runs
+	out = syncBuffer{}
+	stdio.Stdout, stdio.Stderr = &out, &out
+	shell.runLineWith("set -o dryrun", stdio)
+	shell.dryRun = shell.options["dryrun"]
+	shell.runLineWith("rm a.log < missing", stdio)
+	shell.runLineWith("set +o dryrun", stdio)
+	shell.dryRun = false
+	if got := out.String(); got != "+ rm a.log < missing  # missing: no such file or directory\n" || shell.options["dryrun"] {
+		t.Errorf("with dryrun on, shows %q", got)
+	}
+}
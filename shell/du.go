@@ -0,0 +1,177 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+func init() {
+	registerBuiltin("du", "du [-hs] [path...]", "Show the disk space directories take", builtinDu)
+	registerFlags("du",
+		Candidate{"-h", "Show sizes in KiB, MiB and GiB"},
+		Candidate{"-s", "Show only the total of each path"})
+}
+
+// duWorkers is how many directories du reads at once
+const duWorkers = 16
+
+// duDir is the disk space a directory and everything below it takes
+type duDir struct {
+	path    string
+	size    int64
+	isDir   bool
+	subdirs []*duDir
+}
+
+// duWalker measures directory trees, reading directories concurrently
+type duWalker struct {
+	shell   *Shell
+	slots   chan struct{} // taken by each goroutine reading a directory
+	stopped atomic.Bool   // set when Ctrl-C is pressed
+	failed  atomic.Bool
+
+	mu     sync.Mutex // guards stderr
+	stderr io.Writer
+}
+
+// builtinDu shows how much disk space each directory below the given paths
+// takes, the current directory's if none are given, as du does: in
+// kilobytes, or in KiB, MiB and GiB with -h. -s shows only the totals.
+// Subdirectories are read concurrently, and Ctrl-C stops the count.
+func builtinDu(s *Shell, args []string, stdio Stdio) int {
+	var human, summarize bool
+	flags := newFlagSet("du")
+	flags.Bool(&human, "h")
+	flags.Bool(&summarize, "s")
+	paths, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	walker := &duWalker{shell: s, slots: make(chan struct{}, duWorkers), stderr: stdio.Stderr}
+	color := isTerminal(stdio.Stdout)
+	return s.paged(stdio, func(stdio Stdio) int {
+		for _, path := range paths {
+			dir := walker.measure(path)
+			if walker.stopped.Load() {
+				return 130
+			}
+			if dir != nil {
+				printDu(stdio.Stdout, dir, human, summarize, color)
+			}
+		}
+		if walker.failed.Load() {
+			return 1
+		}
+		return 0
+	})
+}
+
+// measure adds up the disk space below path. Each subdirectory is measured
+// on its own goroutine while there are workers to spare, and on this one if
+// not. It returns nil if path can't be read at all.
+func (w *duWalker) measure(path string) *duDir {
+	info, err := os.Lstat(path)
+	if err != nil {
+		w.fail(path, err)
+		return nil
+	}
+	dir := &duDir{path: path, size: diskUsage(info), isDir: info.IsDir()}
+	if !info.IsDir() {
+		return dir
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		w.fail(path, err)
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		if entry.IsDir() {
+			subdirs = append(subdirs, child)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			w.fail(child, err)
+			continue
+		}
+		dir.size += diskUsage(info)
+	}
+
+	dir.subdirs = make([]*duDir, len(subdirs))
+	var wg sync.WaitGroup
+	for i, child := range subdirs {
+		if w.stop() {
+			break
+		}
+		select {
+		case w.slots <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				dir.subdirs[i] = w.measure(child)
+				<-w.slots
+			}()
+		default:
+			dir.subdirs[i] = w.measure(child)
+		}
+	}
+	wg.Wait()
+	for _, sub := range dir.subdirs {
+		if sub != nil {
+			dir.size += sub.size
+		}
+	}
+	return dir
+}
+
+// stop reports whether Ctrl-C has been pressed during the walk
+func (w *duWalker) stop() bool {
+	if w.stopped.Load() {
+		return true
+	}
+	if w.shell.interrupted() {
+		w.stopped.Store(true)
+		return true
+	}
+	return false
+}
+
+// fail reports a path that couldn't be read
+func (w *duWalker) fail(path string, err error) {
+	w.failed.Store(true)
+	w.mu.Lock()
+	fmt.Fprintf(w.stderr, "du: %s: %v\n", path, unwrapPathError(err))
+	w.mu.Unlock()
+}
+
+// printDu prints the space each directory takes, subdirectories before the
+// directories holding them
+func printDu(w io.Writer, dir *duDir, human, summarize, color bool) {
+	if !summarize {
+		for _, sub := range dir.subdirs {
+			if sub != nil {
+				printDu(w, sub, human, false, color)
+			}
+		}
+	}
+	size := strconv.FormatInt((dir.size+1023)/1024, 10)
+	if human {
+		size = formatSize(dir.size)
+	}
+	path := dir.path
+	if color && dir.isDir {
+		path = kindColors[kindDir] + path + Reset
+	}
+	fmt.Fprintf(w, "%s\t%s\n", size, path)
+}
@@ -0,0 +1,47 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDu(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a/deep", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "deep", "data"), make([]byte, 64<<10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	out, status := runCapture(t, shell, "du "+dir)
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		_, path, _ := strings.Cut(line, "\t")
+		paths = append(paths, strings.TrimPrefix(path, dir))
+	}
+	want := []string{"/a/deep", "/a", "/b", ""}
+	if status != 0 || strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("du = %q (status %d), want the directories %q", out, status, want)
+	}
+
+	walker := &duWalker{shell: shell, slots: make(chan struct{}, duWorkers)}
+	total := walker.measure(dir)
+	if total.size < 64<<10 || total.subdirs[0].subdirs[0].size < 64<<10 || total.subdirs[1].size >= 64<<10 {
+		t.Errorf("measured %d bytes, a/deep %d, b %d", total.size, total.subdirs[0].subdirs[0].size, total.subdirs[1].size)
+	}
+
+	out, _ = runCapture(t, shell, "du -sh "+dir)
+	if !strings.HasSuffix(out, "KiB\t"+dir+"\n") || strings.Count(out, "\n") != 1 {
+		t.Errorf("du -sh = %q", out)
+	}
+
+	if _, status := runCapture(t, shell, "du "+filepath.Join(dir, "missing")); status != 1 {
+		t.Errorf("du of a missing path exited with %d, want 1", status)
+	}
+}
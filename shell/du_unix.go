@@ -0,0 +1,18 @@
+//go:build unix
+
+package shell
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// diskUsage returns the space a file takes on disk, which is less than its
+// size for sparse files and usually more for small ones
+func diskUsage(info fs.FileInfo) int64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return int64(st.Blocks) * 512
+}
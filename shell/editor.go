@@ -0,0 +1,252 @@
+package shell
+
+import (
+	"strings"
+
+	"goshell/internal/lineedit"
+)
+
+// keyAction is an editing action bound to a key. It receives the current
+// buffer and cursor position and returns the new buffer and cursor.
+type keyAction func(line []rune, pos int) ([]rune, int)
+
+// lineEditor layers shell-specific key handling such as completion on top of
+// the line editor
+type lineEditor struct {
+	shell  *Shell
+	ed     *lineedit.Editor
+	prompt string          // the line's prompt, without the vi mode indicator
+	width  int             // terminal width when the menu was opened
+	menu   *completionMenu // open completion menu, if any
+	vi     viState         // vi-style editing state, see vi.go
+	multi  multiLine       // a command spanning several lines, see multiline.go
+	kills  killRing        // killed text for yanking, see killring.go
+
+	// The bound action run for this key and for the one before, so that
+	// actions such as yank-pop can tell what came directly before them
+	action, lastAction string
+
+	// The last line highlighted and its colorized form, reused while only
+	// the cursor moves
+	plain, colored string
+}
+
+// newLineEditor creates an editor for the shell on top of ed, taking over
+// its keys and display. Its key bindings are the shell's, set with the bind
+// builtin.
+func newLineEditor(shell *Shell, ed *lineedit.Editor) *lineEditor {
+	e := &lineEditor{shell: shell, ed: ed}
+	ed.Handler = e.handleKey
+	ed.Painter = e.Paint
+	shell.readKey = ed.ReadKey
+	return e
+}
+
+// startLine prepares the editor for reading a new line and returns the
+// prompt to show for it
+func (e *lineEditor) startLine(prompt string) string {
+	e.prompt = prompt
+	e.menu = nil
+	e.multi = multiLine{first: prompt[strings.LastIndex(prompt, "\n")+1:]}
+	e.vi = viState{register: e.vi.register}
+	return e.displayPrompt()
+}
+
+// updateHistory brings the history browsed and searched while editing up
+// to date with the shell's after entries were added to it. If it changed
+// in other ways as well, the whole history is replaced.
+func (e *lineEditor) updateHistory(added []HistoryEntry) {
+	if e.shell.historyStale {
+		e.shell.historyStale = false
+		lines := make([]string, len(e.shell.history))
+		for i, entry := range e.shell.history {
+			lines[i] = entry.Text()
+		}
+		e.ed.SetHistory(lines)
+		return
+	}
+	for _, entry := range added {
+		e.ed.AddHistory(entry.Text())
+	}
+}
+
+// displayPrompt returns the prompt with the vi mode indicator, if any, at
+// the start of its last line
+func (e *lineEditor) displayPrompt() string {
+	if !e.viEnabled() {
+		return e.prompt
+	}
+	i := strings.LastIndex(e.prompt, "\n") + 1
+	return e.prompt[:i] + viIndicators[e.vi.mode] + e.prompt[i:]
+}
+
+// handleKey is the line editor's key handler. While the completion menu is
+// open, its keys take precedence and any other key closes it; in vi mode
+// the vi commands come next, and Up and Down move between the lines of a
+// command spanning several. Keys with a shell-side binding run it, and the
+// rest are left to the line editor.
+func (e *lineEditor) handleKey(key lineedit.Key) (lineedit.Key, bool) {
+	e.lastAction, e.action = e.action, ""
+
+	if e.menu != nil {
+		if action := e.menuAction(key); action != nil {
+			e.apply(action)
+			return 0, false
+		}
+		e.menu = nil
+	}
+	if e.viEnabled() {
+		var ok bool
+		if key, ok = e.viFilter(key); !ok {
+			return 0, false
+		}
+	}
+	if e.moveRow(key) {
+		return 0, false
+	}
+	switch key {
+	case lineedit.KeyRight, lineedit.CtrlF, lineedit.KeyEnd, lineedit.CtrlE:
+		if e.suggestion(e.ed.Buffer()) != "" {
+			e.apply(e.acceptSuggestion)
+			return 0, false
+		}
+	}
+	if binding, ok := e.shell.bindings[key]; ok {
+		return e.runBinding(binding)
+	}
+	return key, true
+}
+
+// apply runs action on the line being edited
+func (e *lineEditor) apply(action keyAction) {
+	e.ed.SetBuffer(action(e.ed.Buffer()))
+}
+
+// complete performs Tab completion. A single candidate replaces the word; if
+// several remain, their common prefix is inserted, or a menu to choose from
+// opens when there is nothing more to insert.
+func (e *lineEditor) complete(line []rune, pos int) ([]rune, int) {
+	candidates, start := e.shell.Complete(line, pos)
+	word := string(line[start:pos])
+
+	switch {
+	case len(candidates) == 0:
+		e.ed.Bell()
+		return line, pos
+	case len(candidates) == 1:
+		text := candidates[0].Text
+		if !strings.HasSuffix(text, "/") {
+			text += " "
+		}
+		return replaceRunes(line, start, pos, text)
+	}
+
+	if prefix := commonPrefix(candidates); len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		return replaceRunes(line, start, pos, prefix)
+	}
+	e.width = e.ed.Width()
+	e.menu = newCompletionMenu(candidates, start, pos, e.width)
+	return line, pos
+}
+
+// menuAction returns the action of a key while the completion menu is open:
+// Tab and the arrow keys move the selection, Enter accepts it and Ctrl-G or
+// Ctrl-C close the menu. It returns nil for keys the menu doesn't handle.
+func (e *lineEditor) menuAction(key lineedit.Key) keyAction {
+	m := e.menu
+	move := func(step func()) keyAction {
+		return func(line []rune, pos int) ([]rune, int) {
+			step()
+			return line, pos
+		}
+	}
+	switch key {
+	case lineedit.Tab, lineedit.KeyDown, lineedit.CtrlN:
+		return move(func() { m.move(1) })
+	case lineedit.KeyUp, lineedit.CtrlP:
+		return move(func() { m.move(-1) })
+	case lineedit.KeyRight, lineedit.CtrlF:
+		return move(func() { m.moveColumn(1) })
+	case lineedit.KeyLeft, lineedit.CtrlB:
+		return move(func() { m.moveColumn(-1) })
+	case lineedit.Enter:
+		return e.acceptMenu
+	case lineedit.CtrlG, lineedit.CtrlC:
+		return func(line []rune, pos int) ([]rune, int) {
+			e.menu = nil
+			return line, pos
+		}
+	}
+	return nil
+}
+
+// acceptMenu closes the menu, replacing the completed word with the selected
+// candidate
+func (e *lineEditor) acceptMenu(line []rune, pos int) ([]rune, int) {
+	m := e.menu
+	e.menu = nil
+	if m.end > len(line) || m.end != pos {
+		return line, pos
+	}
+	text := m.candidates[m.selected].Text
+	if !strings.HasSuffix(text, "/") {
+		text += " "
+	}
+	return replaceRunes(line, m.start, m.end, text)
+}
+
+// suggestion returns the autosuggestion for the line: the rest of the most
+// recent history entry it starts. Suggestions are only made with the cursor
+// at the end of the line, and not in POSIX mode.
+func (e *lineEditor) suggestion(line []rune, pos int) string {
+	if pos != len(line) || e.menu != nil || e.shell.options["posix"] {
+		return ""
+	}
+	return e.shell.Suggest(string(line))
+}
+
+// acceptSuggestion appends the autosuggestion to the line
+func (e *lineEditor) acceptSuggestion(line []rune, pos int) ([]rune, int) {
+	return replaceRunes(line, pos, pos, e.suggestion(line, pos))
+}
+
+// Paint is the line editor's painter. It colorizes the line, and shows the
+// autosuggestion after it in dim text or the open completion menu below it.
+func (e *lineEditor) Paint(line []rune, pos int) lineedit.Display {
+	d := lineedit.Display{Line: e.highlight(line)}
+	if e.menu != nil {
+		if width := e.ed.Width(); width != e.width {
+			// The window was resized, so the menu is laid out afresh
+			m := newCompletionMenu(e.menu.candidates, e.menu.start, e.menu.end, width)
+			m.selected = e.menu.selected
+			e.menu, e.width = m, width
+		}
+		d.Below = e.menu.render(e.width)
+	} else if hint := e.suggestion(line, pos); hint != "" {
+		d.Hint = Dim + hint + Reset
+	}
+	return d
+}
+
+// highlight returns the line with syntax highlighting, if it is enabled
+func (e *lineEditor) highlight(line []rune) string {
+	text := string(line)
+	if !e.shell.highlighting() {
+		return text
+	}
+	if text != e.plain {
+		e.plain, e.colored = text, e.shell.Highlight(text)
+	}
+	return e.colored
+}
+
+// replaceRunes replaces line[start:end] with text, returning the new line and
+// the cursor position just after the inserted text
+func replaceRunes(line []rune, start, end int, text string) ([]rune, int) {
+	inserted := []rune(text)
+	result := make([]rune, 0, len(line)-(end-start)+len(inserted))
+	result = append(result, line[:start]...)
+	result = append(result, inserted...)
+	result = append(result, line[end:]...)
+	return result, start + len(inserted)
+}
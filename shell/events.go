@@ -0,0 +1,371 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("on-event", "on-event [EVENT [--path FILE] CMD | -r ID]", "Run a command when an event occurs", builtinOnEvent)
+	registerFlags("on-event",
+		Candidate{"--path", "File to watch for file_changed"},
+		Candidate{"-r", "Remove the handler with the given ID"})
+}
+
+// eventNames lists the events handlers can be registered for
+var eventNames = map[string]string{
+	"dir_changed":       "the working directory changed; data is the new directory",
+	"file_changed":      "a watched file was modified, created or removed; data is its path",
+	"job_finished":      "a command line finished; data is the command and $? its status",
+	"battery_low":       "battery charge fell below GOSHELL_BATTERY_LOW percent (default 20)",
+	"command_not_found": "a command wasn't found; its words follow the handler's, which runs in its place",
+	"preexec":           "a command line is about to run; data is the command",
+	"precmd":            "the prompt is about to be shown; data is the last command, $? its status",
+	"chpwd":             "cd or another builtin changed the directory; data is the new directory",
+}
+
+// defaultBatteryLow is the charge percentage below which battery_low fires
+const defaultBatteryLow = 20
+
+// eventHandler is a command registered to run for an event
+type eventHandler struct {
+	id      int
+	event   string
+	path    string // watched file, for file_changed
+	command string
+
+	// fn, if set, handles the event in place of running command, which
+	// then only names it. A script's handlers are functions.
+	fn func(s *Shell, args []string, stdio Stdio) int
+}
+
+// event is an occurrence waiting to be dispatched
+type event struct {
+	name, data string
+	vars       map[string]string // more variables the handlers see
+}
+
+// fileStamp is what a file watch compares to notice changes
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// eventState holds the registered handlers and what is needed to notice
+// events between prompts
+type eventState struct {
+	handlers   []*eventHandler
+	nextID     int
+	pending    []event
+	lastDir    string
+	stamps     map[string]fileStamp // by watched path
+	batteryLow bool
+	notFound   bool            // a command_not_found handler is running
+	running    map[string]bool // immediate events whose handlers are running
+}
+
+// queueEvent records an event to be dispatched before the next prompt
+func (s *Shell) queueEvent(name, data string) {
+	s.events.pending = append(s.events.pending, event{name: name, data: data})
+}
+
+// collectEvents notices the events that happened since the last prompt:
+// directory changes, changes to watched files and a low battery
+func (s *Shell) collectEvents() {
+	es := s.events
+	if dir, err := s.Getwd(); err == nil {
+		if es.lastDir != "" && dir != es.lastDir {
+			s.queueEvent("dir_changed", dir)
+		}
+		es.lastDir = dir
+	}
+
+	for _, h := range es.handlers {
+		if h.event != "file_changed" {
+			continue
+		}
+		stamp := statStamp(h.path)
+		if old, ok := es.stamps[h.path]; ok && old != stamp {
+			s.queueEvent("file_changed", h.path)
+		}
+		es.stamps[h.path] = stamp
+	}
+
+	if s.hasHandler("battery_low") {
+		threshold := defaultBatteryLow
+		if n, err := strconv.Atoi(s.env.Get("GOSHELL_BATTERY_LOW")); err == nil {
+			threshold = n
+		}
+		if level, ok := batteryLevel(); ok {
+			low := level < threshold
+			if low && !es.batteryLow {
+				s.queueEvent("battery_low", strconv.Itoa(level))
+			}
+			es.batteryLow = low
+		}
+	}
+}
+
+// DispatchEvents runs the handlers for every pending event. It is called
+// between prompts so handler output never interleaves with the line being
+// edited. Handlers see the event in GOSHELL_EVENT and GOSHELL_EVENT_DATA,
+// and don't change $?.
+func (s *Shell) DispatchEvents(stdio Stdio) {
+	s.collectEvents()
+	es := s.events
+	for len(es.pending) > 0 {
+		ev := es.pending[0]
+		es.pending = es.pending[1:]
+		s.runHandlers(ev, stdio)
+	}
+	// Changes made by the handlers themselves don't raise new events
+	if dir, err := s.Getwd(); err == nil {
+		es.lastDir = dir
+	}
+	for path := range es.stamps {
+		es.stamps[path] = statStamp(path)
+	}
+}
+
+// runHandlers runs the handlers registered for an event. They see the event
+// in GOSHELL_EVENT and GOSHELL_EVENT_DATA, with its other variables, which
+// have the values they had before again afterwards, and don't change $?.
+func (s *Shell) runHandlers(ev event, stdio Stdio) {
+	for _, h := range s.events.handlers {
+		if h.event != ev.name || (h.path != "" && h.path != ev.data) {
+			continue
+		}
+		var list *parser.List
+		if h.fn == nil {
+			var err error
+			if list, err = parser.Parse(h.command); err != nil {
+				fmt.Fprintf(stdio.Stderr, "on-event %d: %v\n", h.id, err)
+				continue
+			}
+		}
+		vars := map[string]string{"GOSHELL_EVENT": ev.name, "GOSHELL_EVENT_DATA": ev.data}
+		for name, value := range ev.vars {
+			vars[name] = value
+		}
+		// A variable that wasn't set has no entry in previous
+		previous := make(map[string]string)
+		for name, value := range vars {
+			if old, ok := s.env.Lookup(name); ok {
+				previous[name] = old
+			}
+			s.env.Set(name, value)
+		}
+		if h.fn != nil {
+			h.fn(s, []string{ev.data}, stdio)
+		} else {
+			s.runList(list, stdio, false)
+		}
+		for name := range vars {
+			if old, ok := previous[name]; ok {
+				s.env.Set(name, old)
+			} else {
+				s.env.Unset(name)
+			}
+		}
+	}
+}
+
+// fireEvent runs the handlers for an event at once, for the events that
+// can't wait for the next prompt. Handlers that cause the event they handle,
+// as a chpwd handler running cd would, don't run again for it.
+func (s *Shell) fireEvent(ev event, stdio Stdio) {
+	es := s.events
+	if es.running[ev.name] || !s.hasHandler(ev.name) {
+		return
+	}
+	if es.running == nil {
+		es.running = make(map[string]bool)
+	}
+	es.running[ev.name] = true
+	defer delete(es.running, ev.name)
+	s.runHandlers(ev, stdio)
+}
+
+// runNotFoundHandler runs the command_not_found handler, if one is
+// registered, in place of a command that wasn't found, as zsh runs
+// command_not_found_handler. The command's words are added to the end of
+// the handler's, so on-event command_not_found 'brew which-formula' runs
+// brew which-formula gti for gti. It reports false if there is no handler,
+// or the handler is already running and itself ran a missing command.
+func (s *Shell) runNotFoundHandler(args []string, stdio Stdio) (int, bool) {
+	es := s.events
+	if es.notFound {
+		return 0, false
+	}
+	for _, h := range es.handlers {
+		if h.event != "command_not_found" {
+			continue
+		}
+		var list *parser.List
+		if h.fn == nil {
+			words := []string{h.command}
+			for _, arg := range args {
+				words = append(words, shellQuote(arg))
+			}
+			var err error
+			if list, err = parser.Parse(strings.Join(words, " ")); err != nil {
+				fmt.Fprintf(stdio.Stderr, "on-event %d: %v\n", h.id, err)
+				return 127, true
+			}
+		}
+		es.notFound = true
+		s.env.Set("GOSHELL_EVENT", "command_not_found")
+		s.env.Set("GOSHELL_EVENT_DATA", args[0])
+		var status int
+		if h.fn != nil {
+			status = h.fn(s, args, stdio)
+		} else {
+			status = s.runList(list, stdio, false)
+		}
+		s.env.Unset("GOSHELL_EVENT")
+		s.env.Unset("GOSHELL_EVENT_DATA")
+		es.notFound = false
+		return status, true
+	}
+	return 0, false
+}
+
+// hasHandler reports whether any handler is registered for the event
+func (s *Shell) hasHandler(name string) bool {
+	for _, h := range s.events.handlers {
+		if h.event == name {
+			return true
+		}
+	}
+	return false
+}
+
+// statStamp records a file's current state
+func statStamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// batteryLevel returns the charge of the first battery found in
+// /sys/class/power_supply. It reports false on systems without one.
+func batteryLevel() (int, bool) {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	for _, dir := range supplies {
+		kind, err := os.ReadFile(filepath.Join(dir, "type"))
+		if err != nil || strings.TrimSpace(string(kind)) != "Battery" {
+			continue
+		}
+		capacity, err := os.ReadFile(filepath.Join(dir, "capacity"))
+		if err != nil {
+			continue
+		}
+		if level, err := strconv.Atoi(strings.TrimSpace(string(capacity))); err == nil {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// builtinOnEvent registers a command to run when an event occurs, removes a
+// registration with -r, or lists the registrations
+func builtinOnEvent(s *Shell, args []string, stdio Stdio) int {
+	es := s.events
+	usage := func() int {
+		fmt.Fprintln(stdio.Stderr, "Usage: on-event EVENT [--path FILE] COMMAND | on-event -r ID | on-event")
+		fmt.Fprintln(stdio.Stderr, "Events:")
+		for _, name := range []string{"dir_changed", "file_changed", "job_finished", "battery_low", "command_not_found", "preexec", "precmd", "chpwd"} {
+			fmt.Fprintf(stdio.Stderr, "  %-17s %s\n", name, eventNames[name])
+		}
+		return 1
+	}
+
+	var remove, path string
+	flags := newFlagSet("on-event")
+	flags.String(&remove, "r")
+	flags.String(&path, "path")
+	flags.stopAtOperand = true
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "on-event:", err)
+		return usage()
+	}
+
+	if len(operands) == 0 && remove == "" && path == "" {
+		for _, h := range es.handlers {
+			target := ""
+			if h.path != "" {
+				target = " --path " + shellQuote(h.path)
+			}
+			fmt.Fprintf(stdio.Stdout, "%d: on-event %s%s %s\n", h.id, h.event, target, shellQuote(h.command))
+		}
+		return 0
+	}
+
+	if remove != "" {
+		if len(operands) != 0 {
+			return usage()
+		}
+		id, err := strconv.Atoi(remove)
+		if err == nil {
+			for i, h := range es.handlers {
+				if h.id == id {
+					es.handlers = append(es.handlers[:i], es.handlers[i+1:]...)
+					if h.path != "" {
+						delete(es.stamps, h.path)
+					}
+					return 0
+				}
+			}
+		}
+		fmt.Fprintf(stdio.Stderr, "on-event: no handler with ID %s\n", remove)
+		return 1
+	}
+
+	if len(operands) == 0 {
+		return usage()
+	}
+	h := &eventHandler{event: operands[0]}
+	if _, ok := eventNames[h.event]; !ok {
+		fmt.Fprintf(stdio.Stderr, "on-event: unknown event: %s\n", h.event)
+		return usage()
+	}
+	// --path may also follow the event name
+	rest, err := flags.Parse(operands[1:])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "on-event:", err)
+		return usage()
+	}
+	if path != "" {
+		if h.path, err = filepath.Abs(path); err != nil {
+			fmt.Fprintln(stdio.Stderr, "on-event:", err)
+			return 1
+		}
+	}
+	if len(rest) == 0 || (h.event == "file_changed") != (h.path != "") {
+		return usage()
+	}
+	h.command = strings.Join(rest, " ")
+	s.addHandler(h)
+	return 0
+}
+
+// addHandler registers an event handler, giving it the next ID
+func (s *Shell) addHandler(h *eventHandler) {
+	es := s.events
+	es.nextID++
+	h.id = es.nextID
+	es.handlers = append(es.handlers, h)
+	if h.path != "" {
+		es.stamps[h.path] = statStamp(h.path)
+	}
+}
@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOnEvent(t *testing.T) {
+	shell := NewShell()
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	var out syncBuffer
+	stdio := Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "watched.txt")
+	runCapture(t, shell, `on-event dir_changed 'echo dir $GOSHELL_EVENT_DATA'`)
+	runCapture(t, shell, `on-event file_changed --path `+watched+` 'echo $GOSHELL_EVENT $GOSHELL_EVENT_DATA'`)
+	runCapture(t, shell, `on-event job_finished 'echo finished $GOSHELL_EVENT_DATA'`)
+
+	// Nothing has happened yet
+	shell.DispatchEvents(stdio)
+	if out.String() != "" {
+		t.Fatalf("first dispatch ran handlers: %q", out.String())
+	}
+
+	os.Chdir(dir)
+	os.WriteFile(watched, []byte("new"), 0644)
+	shell.queueEvent("job_finished", "make test")
+	shell.DispatchEvents(stdio)
+	got := out.String()
+	for _, want := range []string{"finished make test\n", "dir " + dir + "\n", "file_changed " + watched + "\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dispatch output %q is missing %q", got, want)
+		}
+	}
+	if shell.env.Get("GOSHELL_EVENT") != "" {
+		t.Error("GOSHELL_EVENT is still set after dispatch")
+	}
+
+	// Events are delivered once
+	before := out.String()
+	shell.DispatchEvents(stdio)
+	if out.String() != before {
+		t.Errorf("second dispatch ran handlers again: %q", strings.TrimPrefix(out.String(), before))
+	}
+
+	list, _ := runCapture(t, shell, "on-event")
+	if !strings.Contains(list, "1: on-event dir_changed 'echo dir $GOSHELL_EVENT_DATA'") {
+		t.Errorf("on-event listing = %q", list)
+	}
+	runCapture(t, shell, "on-event -r 1")
+	if shell.hasHandler("dir_changed") {
+		t.Error("on-event -r did not remove the handler")
+	}
+}
+
+func TestOnEventUsage(t *testing.T) {
+	shell := NewShell()
+	for _, line := range []string{"on-event nonsense 'echo'", "on-event file_changed 'echo'", "on-event dir_changed", "on-event -r 7"} {
+		if _, status := runCapture(t, shell, line); status == 0 {
+			t.Errorf("%s succeeded", line)
+		}
+	}
+}
+
+func TestCommandNotFoundHandler(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("PATH", t.TempDir())
+	runCapture(t, shell, `on-event command_not_found 'echo missing $GOSHELL_EVENT_DATA:'`)
+	out, status := runCapture(t, shell, "frobnicate 'a b' c")
+	if out != "missing frobnicate: frobnicate a b c\n" || status != 0 {
+		t.Errorf("handler output = %q (status %d)", out, status)
+	}
+	if shell.env.Get("GOSHELL_EVENT") != "" {
+		t.Error("GOSHELL_EVENT is still set after the handler")
+	}
+
+	// A handler that runs a missing command itself doesn't recurse
+	runCapture(t, shell, "on-event -r 1")
+	runCapture(t, shell, "on-event command_not_found 'nosuchhandler'")
+	if _, status := runCapture(t, shell, "frobnicate"); status != 127 {
+		t.Errorf("missing handler command exited with %d, want 127", status)
+	}
+}
+
+func TestImmediateEvents(t *testing.T) {
+	dir := t.TempDir()
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	var buf syncBuffer
+	shell := New(Options{Stdout: &buf, Stderr: &buf})
+	runCapture(t, shell, `on-event chpwd 'echo chpwd $GOSHELL_EVENT_DATA; cd /'`)
+	runCapture(t, shell, `on-event precmd 'echo precmd $GOSHELL_EVENT_DATA $? $GOSHELL_DURATION_MS'`)
+
+	// chpwd runs on the shell's own streams as cd does, and the cd in the
+	// handler doesn't set it off again
+	runCapture(t, shell, "cd "+dir)
+	if got := buf.String(); got != "chpwd "+dir+"\n" {
+		t.Errorf("chpwd output = %q", got)
+	}
+	if cwd, _ := shell.Getwd(); cwd != "/" {
+		t.Errorf("after the handler's cd the directory is %s", cwd)
+	}
+
+	var out syncBuffer
+	stdio := Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	shell.lastStatus = 3
+	shell.fireEvent(event{name: "precmd", data: "make", vars: map[string]string{"GOSHELL_DURATION_MS": "1200"}}, stdio)
+	if got := out.String(); got != "precmd make 3 1200\n" {
+		t.Errorf("precmd output = %q", got)
+	}
+	if shell.env.Get("GOSHELL_DURATION_MS") != "" || shell.lastStatus != 3 {
+		t.Error("the precmd handler changed the shell's state")
+	}
+	// A handler running inside another's leaves the outer one's variables
+	// as they were
+	shell.env.Set("GOSHELL_EVENT", "chpwd")
+	shell.env.Set("GOSHELL_DURATION_MS", "5")
+	shell.fireEvent(event{name: "precmd", data: "make", vars: map[string]string{"GOSHELL_DURATION_MS": "1200"}}, stdio)
+	if got, duration := shell.env.Get("GOSHELL_EVENT"), shell.env.Get("GOSHELL_DURATION_MS"); got != "chpwd" || duration != "5" {
+		t.Errorf("after a nested handler GOSHELL_EVENT=%q GOSHELL_DURATION_MS=%q, want chpwd and 5", got, duration)
+	}
+}
@@ -0,0 +1,461 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"goshell/internal/parser"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// stdio returns the shell's own standard streams: the process's, or those
+// given to New
+func (s *Shell) stdio() Stdio {
+	return Stdio{Stdin: s.stdin, Stdout: s.stdout, Stderr: s.stderr}
+}
+
+// runLine parses and executes a command line on the shell's own streams. It
+// returns the exit status of the last pipeline that ran.
+func (s *Shell) runLine(line string) int {
+	return s.runLineWith(line, s.stdio())
+}
+
+// runLineWith parses and executes a command line using the given streams,
+// after expanding its aliases. A line starting with dryrun is only shown.
+func (s *Shell) runLineWith(line string, stdio Stdio) int {
+	list, dryRun, err := s.parseCommandLine(line)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "Error parsing command:", err)
+		s.lastStatus = 2
+		return s.lastStatus
+	}
+	return s.runCommandLine(list, dryRun, stdio)
+}
+
+// parseCommandLine parses a command line after expanding its aliases,
+// reporting whether it starts with dryrun
+func (s *Shell) parseCommandLine(line string) (*parser.List, bool, error) {
+	line, dryRun := cutDryRunPrefix(line)
+	list, err := parser.Parse(s.expandAliases(line))
+	return list, dryRun, err
+}
+
+// runCommandLine executes a parsed command line, only showing its commands
+// when dryRun is set
+func (s *Shell) runCommandLine(list *parser.List, dryRun bool, stdio Stdio) int {
+	if dryRun && !s.dryRun {
+		s.dryRun = true
+		defer func() { s.dryRun = false }()
+	}
+	return s.runList(list, stdio, true)
+}
+
+// runList executes the pipelines of a command list, honouring && and ||.
+// When record is set each pipeline's status becomes the shell's $?; commands
+// running in the background leave it alone.
+func (s *Shell) runList(list *parser.List, stdio Stdio, record bool) int {
+	status := 0
+	for i, p := range list.Pipelines {
+		if i > 0 {
+			switch list.Ops[i-1] {
+			case "&&":
+				if status != 0 {
+					continue
+				}
+			case "||":
+				if status == 0 {
+					continue
+				}
+			}
+		}
+		status = s.runPipeline(p, stdio)
+		if record {
+			s.lastStatus = status
+		}
+		if s.exiting {
+			break
+		}
+	}
+	return status
+}
+
+// runArgs runs an already expanded argument list as a single command. Each
+// argument is quoted so that it reaches the command unchanged.
+func (s *Shell) runArgs(args []string, stdio Stdio) int {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = shellQuote(arg)
+	}
+	p := &parser.Pipeline{Commands: []*parser.Command{{Words: words}}}
+	return s.runPipeline(p, stdio)
+}
+
+// stage is a single running command of a pipeline
+type stage struct {
+	args    []string
+	stdio   Stdio
+	cmd     *exec.Cmd      // set for external commands
+	remote  *remoteCommand // set for commands run on the remote host
+	owned   []*os.File     // files to close once the stage has finished
+	status  int
+	profile *stageProfile // set when the pipeline runs with --profile
+	limit   *commandLimit // set when the pipeline runs under timeout
+}
+
+// runPipeline runs every command of a pipeline concurrently, connecting each
+// stage's stdout to the next stage's stdin. Builtins run in-process; a single
+// builtin runs on the calling goroutine so it can change shell state, and of
+// several, all but the last run in subshells, see subshell.go. The
+// pipeline's status is that of its last command.
+func (s *Shell) runPipeline(p *parser.Pipeline, stdio Stdio) int {
+	return s.runPipelineWithin(p, nil, stdio)
+}
+
+// runPipelineWithin runs a pipeline as runPipeline does, with its external
+// commands bounded by limit, which timeout sets
+func (s *Shell) runPipelineWithin(p *parser.Pipeline, limit *commandLimit, stdio Stdio) int {
+	p, profile := stripProfileModifier(p)
+	stages := make([]*stage, len(p.Commands))
+	var subs []*procSub
+	defer func() {
+		for _, sub := range subs {
+			sub.finish()
+		}
+	}()
+	for i, c := range p.Commands {
+		var args []string
+		for _, word := range c.Words {
+			if !isProcessSubstitution(word) {
+				args = append(args, s.expandWord(word)...)
+				continue
+			}
+			if s.dryRun {
+				args = append(args, word)
+				continue
+			}
+			sub, err := s.startProcessSubstitution(word, stdio)
+			if err != nil {
+				fmt.Fprintln(stdio.Stderr, "Error in process substitution:", err)
+				return 1
+			}
+			subs = append(subs, sub)
+			args = append(args, sub.path)
+		}
+		stages[i] = &stage{args: args, stdio: stdio, limit: s.limitFor(limit)}
+	}
+	if s.dryRun && !(len(stages) == 1 && len(stages[0].args) > 0 && stages[0].args[0] == "set") {
+		s.showDryRun(p, stages, stdio)
+		return 0
+	}
+	for _, st := range stages {
+		if !s.guardCommand(st.args, stdio) {
+			return 1
+		}
+	}
+
+	// Link stages with pipes, or with channels of records between builtins
+	// that pass them
+	for i := 0; i < len(stages)-1; i++ {
+		if s.linkRecords(stages[i], stages[i+1], p.Commands[i], p.Commands[i+1]) {
+			continue
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "Error creating pipe:", err)
+			closeStages(stages)
+			return 1
+		}
+		stages[i].stdio.Stdout = w
+		stages[i].owned = append(stages[i].owned, w)
+		stages[i+1].stdio.Stdin = r
+		stages[i+1].owned = append(stages[i+1].owned, r)
+	}
+
+	// Apply redirections, which override the pipe wiring
+	for i, c := range p.Commands {
+		if err := s.applyRedirects(stages[i], c.Redirects); err != nil {
+			fmt.Fprintln(stdio.Stderr, "Error redirecting:", err)
+			closeStages(stages)
+			return 1
+		}
+	}
+
+	if profile {
+		for _, st := range stages {
+			st.startProfile()
+		}
+		defer reportProfile(stages, stdio.Stderr)
+	}
+
+	if len(stages) == 1 {
+		st := stages[0]
+		defer closeStages(stages)
+		if len(st.args) == 0 {
+			return 0
+		}
+		defer st.finishProfile()
+		return s.runCommand(st)
+	}
+
+	// Start each command, waiting for each on its own goroutine
+	var wg sync.WaitGroup
+	for i, st := range stages {
+		if len(st.args) == 0 {
+			closeFiles(st.owned)
+			continue
+		}
+		if b, ok := s.lookupBuiltin(st.args[0]); ok {
+			sh := s
+			if i < len(stages)-1 {
+				sh = s.fork()
+			}
+			wg.Add(1)
+			go func(st *stage, run BuiltinFunc) {
+				defer wg.Done()
+				st.status = run(sh, st.args, st.stdio)
+				st.stdio.finishRecords()
+				st.finishProfile()
+				closeFiles(st.owned)
+			}(st, b.run)
+			continue
+		}
+		if err := s.startExternal(st); err != nil {
+			fmt.Fprintln(st.stdio.Stderr, "Error starting command:", err)
+			if errors.Is(err, exec.ErrNotFound) {
+				if fix := s.suggestCommand(st.args[0]); fix != "" {
+					fmt.Fprintf(st.stdio.Stderr, "goshell: did you mean '%s'?\n", fix)
+				}
+			}
+			st.status = 127
+			closeFiles(st.owned)
+			continue
+		}
+		// Close the parent's copies of the pipe ends. A profiled stage's
+		// output is copied through the counter until Wait returns, and a
+		// remote command's streams are copied over the connection, so their
+		// pipes stay open until then.
+		keepOpen := st.profile != nil || st.remote != nil
+		if !keepOpen {
+			closeFiles(st.owned)
+		}
+		wg.Add(1)
+		go func(st *stage) {
+			defer wg.Done()
+			if err := s.wait(st); err != nil {
+				if !st.timedOut() {
+					fmt.Fprintln(st.stdio.Stderr, "Error waiting for command:", err)
+				}
+				st.status = exitStatus(err)
+			}
+			st.finishProfile()
+			if keepOpen {
+				closeFiles(st.owned)
+			}
+		}(st)
+	}
+	wg.Wait()
+	return stages[len(stages)-1].status
+}
+
+// runCommand runs a pipeline's only command on the calling goroutine, so a
+// builtin can change the shell's state, and waits for it to finish
+func (s *Shell) runCommand(st *stage) int {
+	if b, ok := s.lookupBuiltin(st.args[0]); ok {
+		return b.run(s, st.args, st.stdio)
+	}
+	if len(st.args) == 1 && s.isAutoCd(st.args[0]) {
+		if err := s.changeDir(st.args[0], false); err != nil {
+			fmt.Fprintln(st.stdio.Stderr, "Error changing directory:", err)
+			return 1
+		}
+		return 0
+	}
+	if err := s.startExternal(st); errors.Is(err, exec.ErrNotFound) {
+		return s.commandNotFound(st, err)
+	} else if err != nil {
+		fmt.Fprintf(st.stdio.Stderr, "Error executing command: %v\n", err)
+		return 127
+	}
+	if err := s.wait(st); err != nil {
+		if !st.timedOut() {
+			fmt.Fprintf(st.stdio.Stderr, "Error executing command: %v\n", err)
+		}
+		return exitStatus(err)
+	}
+	return 0
+}
+
+// isAutoCd reports whether a command word alone should change into the
+// directory it names, as with zsh's autocd: the option is on and the word
+// is an existing directory rather than a builtin or a command on PATH
+func (s *Shell) isAutoCd(name string) bool {
+	if !s.options["autocd"] || s.options["posix"] || s.remote != nil || s.isCommand(name) {
+		return false
+	}
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// limitFor returns what bounds the external commands of a pipeline: its
+// timeout, or Run's context
+func (s *Shell) limitFor(limit *commandLimit) *commandLimit {
+	if limit == nil && s.ctx != nil {
+		return &commandLimit{ctx: s.ctx, grace: defaultGrace}
+	}
+	return limit
+}
+
+// startExternal starts a stage as an external process, on the remote host
+// in remote mode
+func (s *Shell) startExternal(st *stage) error {
+	if s.remote != nil {
+		return s.startRemote(st)
+	}
+	args := st.args
+	// Handle 'ls' specially to ensure colors are enabled
+	if args[0] == "ls" {
+		args = s.systemLs(args)
+	}
+
+	file, err := s.commandPath(args[0])
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(file, args[1:]...)
+	if st.limit != nil {
+		cmd = exec.CommandContext(st.limit.ctx, file, args[1:]...)
+		cmd.Cancel = func() error { return terminate(cmd.Process) }
+		cmd.WaitDelay = st.limit.grace
+	}
+	cmd.Args[0] = args[0]
+	cmd.Stdin = st.stdio.Stdin
+	cmd.Stdout = st.stdio.Stdout
+	cmd.Stderr = st.stdio.Stderr
+	cmd.Env = s.childEnv(st.stdio.Stderr)
+	st.cmd = cmd
+	return cmd.Start()
+}
+
+// wait waits for a stage's external command to finish
+func (s *Shell) wait(st *stage) error {
+	if st.remote != nil {
+		return s.waitRemote(st)
+	}
+	err := st.cmd.Wait()
+	s.usage.add(st.cmd.ProcessState)
+	return err
+}
+
+// timedOut reports whether the stage was stopped for running out of time
+func (st *stage) timedOut() bool {
+	return st.limit != nil && st.limit.ctx.Err() != nil
+}
+
+// runSystem runs the system's own version of a builtin, for the options
+// and cases the builtin leaves to it
+func (s *Shell) runSystem(args []string, stdio Stdio) int {
+	file, err := s.commandPath(args[0])
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "Error executing command: %v\n", err)
+		return 127
+	}
+	cmd := exec.Command(file, args[1:]...)
+	cmd.Args[0] = args[0]
+	cmd.Env = s.childEnv(stdio.Stderr)
+	cmd.Stdin = stdio.Stdin
+	cmd.Stdout = stdio.Stdout
+	cmd.Stderr = stdio.Stderr
+	err = cmd.Run()
+	s.usage.add(cmd.ProcessState)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintf(stdio.Stderr, "Error executing command: %v\n", err)
+			return 127
+		}
+		return exitStatus(err)
+	}
+	return 0
+}
+
+// applyRedirects opens the files named by a command's redirections and wires
+// them into the stage's streams
+func (s *Shell) applyRedirects(st *stage, redirects []parser.Redirect) error {
+	for _, r := range redirects {
+		if r.Op == "2>&1" {
+			st.stdio.Stderr = st.stdio.Stdout
+			continue
+		}
+
+		targets := s.expandWord(r.Target)
+		if len(targets) != 1 {
+			return fmt.Errorf("%s: ambiguous redirect", r.Target)
+		}
+		target := targets[0]
+
+		var f *os.File
+		var err error
+		// An unquoted %NAME refers to a named pipe created with fifo
+		if strings.HasPrefix(r.Target, "%") {
+			if target, err = s.fifoPath(target[1:]); err != nil {
+				return err
+			}
+		}
+		switch r.Op {
+		case "<":
+			f, err = os.Open(target)
+		case ">", "2>":
+			f, err = os.Create(target)
+		case ">>", "2>>":
+			f, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		}
+		if err != nil {
+			return err
+		}
+		st.owned = append(st.owned, f)
+
+		switch r.Op {
+		case "<":
+			st.stdio.Stdin = f
+		case ">", ">>":
+			st.stdio.Stdout = f
+		case "2>", "2>>":
+			st.stdio.Stderr = f
+		}
+	}
+	return nil
+}
+
+// closeStages releases every file owned by the given stages
+func closeStages(stages []*stage) {
+	for _, st := range stages {
+		closeFiles(st.owned)
+	}
+}
+
+// closeFiles closes each file, ignoring errors
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// exitStatus extracts a process exit status from an error returned by
+// exec.Cmd or, for a remote command, ssh.Session, defaulting to 1 for other
+// failures
+func exitStatus(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	var remoteErr *ssh.ExitError
+	if errors.As(err, &remoteErr) {
+		return remoteExitStatus(remoteErr)
+	}
+	return 1
+}
@@ -0,0 +1,199 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"goshell/internal/parser"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent writers, since
+// pipeline stages write from their own goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runCapture runs a command line through the parser and pipeline executor
+// and returns what it wrote to stdout
+func runCapture(t *testing.T, shell *Shell, line string) (string, int) {
+	t.Helper()
+	list, err := parser.Parse(line)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", line, err)
+	}
+	var out syncBuffer
+	stdio := Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	status := 0
+	for _, p := range list.Pipelines {
+		status = shell.runPipeline(p, stdio)
+	}
+	return out.String(), status
+}
+
+func TestRunPipeline(t *testing.T) {
+	shell := NewShell()
+
+	t.Run("Builtin Stages", func(t *testing.T) {
+		out, status := runCapture(t, shell, "echo hello | meter")
+		if out != "hello\n" || status != 0 {
+			t.Errorf("output = %q (status %d), want %q", out, status, "hello\n")
+		}
+	})
+
+	t.Run("Builtin Into External", func(t *testing.T) {
+		out, _ := runCapture(t, shell, "echo one two | wc -w")
+		if strings.TrimSpace(out) != "2" {
+			t.Errorf("output = %q, want 2", out)
+		}
+	})
+
+	t.Run("Builtins In Subshells", func(t *testing.T) {
+		// Run with -race: the stages run at once
+		out, status := runCapture(t, shell, "export A=1 | export B=2 | wc -c")
+		if strings.TrimSpace(out) != "0" || status != 0 {
+			t.Errorf("output = %q (status %d), want 0", out, status)
+		}
+		if _, ok := shell.env.Lookup("A"); ok {
+			t.Error("export in a pipeline stage set the shell's variable")
+		}
+		runCapture(t, shell, "export A=1 | export B=2")
+		if _, ok := shell.env.Lookup("A"); ok || shell.env.Get("B") != "2" {
+			t.Errorf("A, B = %q, %q, want the last stage's only", shell.env.Get("A"), shell.env.Get("B"))
+		}
+
+		start, _ := shell.Getwd()
+		dir := t.TempDir()
+		if out, _ := runCapture(t, shell, "cd "+dir+" | pwd"); out != start+"\n" {
+			t.Errorf("cd | pwd = %q, want %q", out, start+"\n")
+		}
+		if out, _ := runCapture(t, shell, "cd "+dir+" && pwd | cat"); out != dir+"\n" {
+			t.Errorf("cd && pwd | cat = %q, want %q", out, dir+"\n")
+		}
+		runCapture(t, shell, "cd "+start)
+		runCapture(t, shell, "cd "+dir+" | cat")
+		if cwd, _ := shell.Getwd(); cwd != start {
+			t.Errorf("after cd | cat the shell is in %s, want %s", cwd, start)
+		}
+		if cwd, _ := os.Getwd(); cwd != start {
+			t.Errorf("after cd | cat the process is in %s, want %s", cwd, start)
+		}
+
+		runCapture(t, shell, "alias x=y | alias z=w | alias")
+		if _, ok := shell.aliases["x"]; ok {
+			t.Error("alias in a pipeline stage defined the shell's alias")
+		}
+	})
+
+	t.Run("Redirection", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.txt")
+		runCapture(t, shell, "echo saved > "+path)
+		runCapture(t, shell, "echo again >> "+path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "saved\nagain\n" {
+			t.Errorf("file = %q, want %q", data, "saved\nagain\n")
+		}
+	})
+
+	t.Run("Exit Status", func(t *testing.T) {
+		if _, status := runCapture(t, shell, "false"); status != 1 {
+			t.Errorf("status = %d, want 1", status)
+		}
+	})
+}
+
+func TestRunLineConnectors(t *testing.T) {
+	shell := NewShell()
+	path := filepath.Join(t.TempDir(), "out.txt")
+	shell.runLine("false && echo skipped > " + path + "; true || echo skipped > " + path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("connectors ran a command that should have been skipped")
+	}
+	shell.runLine("false || echo ran > " + path)
+	if data, _ := os.ReadFile(path); string(data) != "ran\n" {
+		t.Errorf("file = %q, want %q", data, "ran\n")
+	}
+}
+
+func TestPipelineProfile(t *testing.T) {
+	shell := NewShell()
+	list, err := parser.Parse("pipeline --profile echo hello | tr a-z A-Z | head -n 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, report syncBuffer
+	status := shell.runPipeline(list.Pipelines[0], Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &report})
+	if status != 0 || out.String() != "HELLO\n" {
+		t.Fatalf("profiled pipeline = %q (status %d), want %q", out.String(), status, "HELLO\n")
+	}
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "STAGE") {
+		t.Fatalf("profile report = %q, want a header and three stages", report.String())
+	}
+	for i, want := range []string{"echo hello", "tr a-z A-Z", "head -n 1"} {
+		fields := strings.Fields(lines[i+1])
+		if !strings.Contains(lines[i+1], want) || fields[len(fields)-1] != "6B" {
+			t.Errorf("stage %d report = %q, want %s writing 6B", i+1, lines[i+1], want)
+		}
+	}
+	// echo is a builtin, so only the external stages have CPU figures
+	if !strings.Contains(lines[1], " - ") || strings.Contains(lines[3], " - ") {
+		t.Errorf("CPU columns = %q / %q", lines[1], lines[3])
+	}
+}
+
+func TestAutoCd(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "src")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	shell := NewShell()
+	shell.env.Set("HOME", root)
+
+	if _, status := runCapture(t, shell, "~/src"); status == 0 {
+		t.Fatal("a directory ran as a command with autocd off")
+	}
+	shell.setOption("autocd", true)
+	if out, status := runCapture(t, shell, "~/src"); out != "" || status != 0 {
+		t.Fatalf("~/src = %q (status %d)", out, status)
+	}
+	if cwd, _ := shell.Getwd(); cwd != dir {
+		t.Errorf("after ~/src the directory is %s, want %s", cwd, dir)
+	}
+	if got := shell.env.Get("OLDPWD"); got != start {
+		t.Errorf("OLDPWD = %q, want %q", got, start)
+	}
+	if _, status := runCapture(t, shell, ".."); status != 0 {
+		t.Error(".. failed")
+	}
+	if cwd, _ := shell.Getwd(); cwd != root {
+		t.Errorf("after .. the directory is %s, want %s", cwd, root)
+	}
+	// A directory given arguments is still run as a command
+	if _, status := runCapture(t, shell, "src x"); status == 0 {
+		t.Error("src x succeeded")
+	}
+}
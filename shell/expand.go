@@ -0,0 +1,245 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// expandWords expands every raw word of a command into its final arguments
+func (s *Shell) expandWords(words []string) []string {
+	var args []string
+	for _, word := range words {
+		args = append(args, s.expandWord(word)...)
+	}
+	return args
+}
+
+// expandedField is one argument a word expands to, with its text both as
+// it is and with quoted wildcards escaped, for matching
+type expandedField struct {
+	text, pattern strings.Builder
+	glob          bool // it has unquoted wildcards
+	kept          bool // it has text or quotes, so it isn't dropped when empty
+}
+
+// expandWord performs tilde, variable and quote expansion on a raw word,
+// followed by glob expansion when the word contains unquoted wildcards. A
+// word always expands to at least one argument unless a glob matched nothing
+// and the word was empty. In POSIX mode unquoted expansions are split into
+// more arguments at the characters in IFS, and wildcards in them expanded,
+// while a word that comes to nothing unquoted expands to no argument at all.
+func (s *Shell) expandWord(raw string) []string {
+	posix := s.options["posix"]
+	ifs, ok := s.env.Lookup("IFS")
+	if !ok {
+		ifs = defaultIFS
+	}
+	fields := []*expandedField{{}}
+	field := func() *expandedField { return fields[len(fields)-1] }
+	split := false // IFS whitespace has ended the field; more text starts the next
+
+	// write appends expanded text, escaping wildcards when it was quoted
+	write := func(str string, quoted bool) {
+		if split {
+			fields = append(fields, &expandedField{})
+			split = false
+		}
+		f := field()
+		f.kept = f.kept || quoted || str != ""
+		f.text.WriteString(str)
+		for _, r := range str {
+			if quoted && strings.ContainsRune("*?[\\", r) {
+				f.pattern.WriteByte('\\')
+			}
+			f.pattern.WriteRune(r)
+		}
+	}
+
+	// expanded writes the value of an unquoted expansion, splitting it in
+	// POSIX mode. IFS whitespace around the fields is dropped; any other
+	// IFS character ends a field, even an empty one.
+	expanded := func(value string) {
+		if !posix {
+			write(value, false)
+			return
+		}
+		for _, r := range value {
+			switch {
+			case !strings.ContainsRune(ifs, r):
+				write(string(r), false)
+				if strings.ContainsRune("*?[", r) {
+					field().glob = true
+				}
+			case strings.ContainsRune(defaultIFS, r):
+				split = split || field().kept
+			default:
+				field().kept = true
+				fields = append(fields, &expandedField{})
+				split = false
+			}
+		}
+	}
+
+	i := 0
+	if strings.HasPrefix(raw, "~") {
+		end := strings.IndexByte(raw, '/')
+		if end < 0 {
+			end = len(raw)
+		}
+		if end == 1 {
+			home := s.homeDir()
+			if s.remote != nil {
+				home = s.remote.home
+			}
+			write(home, true)
+			i = 1
+		}
+	}
+
+	for i < len(raw) {
+		c := raw[i]
+		switch c {
+		case '\\':
+			if i+1 < len(raw) {
+				write(raw[i+1:i+2], true)
+			}
+			i += 2
+		case '\'':
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 {
+				end = len(raw) - i - 1
+			}
+			write(raw[i+1:i+1+end], true)
+			i += end + 2
+		case '"':
+			write("", true)
+			i++
+			for i < len(raw) && raw[i] != '"' {
+				switch {
+				case raw[i] == '\\' && i+1 < len(raw) && strings.ContainsRune("$`\"\\", rune(raw[i+1])):
+					write(raw[i+1:i+2], true)
+					i += 2
+				case raw[i] == '$':
+					value, n := s.expandVariable(raw[i:])
+					write(value, true)
+					i += n
+				default:
+					write(raw[i:i+1], true)
+					i++
+				}
+			}
+			i++
+		case '$':
+			value, n := s.expandVariable(raw[i:])
+			expanded(value)
+			i += n
+		default:
+			write(raw[i:i+1], false)
+			if c == '*' || c == '?' || c == '[' {
+				field().glob = true
+			}
+			i++
+		}
+	}
+
+	var args []string
+	for _, f := range fields {
+		if f.glob {
+			if matches, err := s.glob(f.pattern.String()); err == nil && len(matches) > 0 {
+				args = append(args, matches...)
+				continue
+			}
+		}
+		if f.kept || !posix {
+			args = append(args, f.text.String())
+		}
+	}
+	return args
+}
+
+// glob returns the files matching a wildcard pattern, the remote host's in
+// remote mode
+func (s *Shell) glob(pattern string) ([]string, error) {
+	if s.remote != nil {
+		return s.remote.glob(pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+// expandVariable expands the variable reference at the start of str, which
+// begins with '$'. It returns the value and the number of bytes consumed.
+func (s *Shell) expandVariable(str string) (string, int) {
+	if len(str) < 2 {
+		return "$", 1
+	}
+
+	switch str[1] {
+	case '?':
+		return strconv.Itoa(s.lastStatus), 2
+	case '$':
+		return strconv.Itoa(os.Getpid()), 2
+	case '{':
+		end := strings.IndexByte(str, '}')
+		if end < 0 {
+			return str, len(str)
+		}
+		return s.variable(str[2:end]), end + 1
+	}
+
+	n := 1
+	for n < len(str) && isNameChar(str[n], n == 1) {
+		n++
+	}
+	if n == 1 {
+		return "$", 1
+	}
+	return s.variable(str[1:n]), n
+}
+
+// variable returns the value of a variable, or of $__LAST_OUT, the output
+// of the last command line
+func (s *Shell) variable(name string) string {
+	if name == lastOutputVar {
+		return s.lastOutputText()
+	}
+	return s.env.Get(name)
+}
+
+// isNameChar reports whether c may appear in a variable name. Digits are not
+// allowed as the first character.
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
+
+// homeDir returns the user's home directory from the shell environment
+func (s *Shell) homeDir() string {
+	if home := s.env.Get("HOME"); home != "" {
+		return home
+	}
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// tildePath returns path with the home directory shortened to ~, for
+// showing it
+func (s *Shell) tildePath(path string) string {
+	home := s.homeDir()
+	if home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~" + string(filepath.Separator) + rest
+	}
+	return path
+}
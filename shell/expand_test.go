@@ -0,0 +1,62 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandWord(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("NAME", "world")
+	shell.env.Set("HOME", "/home/test")
+	shell.lastStatus = 3
+
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"plain", "plain"},
+		{"$NAME", "world"},
+		{"${NAME}s", "worlds"},
+		{"'$NAME'", "$NAME"},
+		{"\"hello $NAME\"", "hello world"},
+		{"a\\ b", "a b"},
+		{"~/src", "/home/test/src"},
+		{"x~", "x~"},
+		{"$?", "3"},
+		{"$UNSET_VARIABLE_XYZ", ""},
+		{"cost$", "cost$"},
+	}
+
+	for _, tt := range tests {
+		got := shell.expandWord(tt.raw)
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("expandWord(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	shell := NewShell()
+
+	got := shell.expandWord(filepath.Join(dir, "*.go"))
+	want := []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("glob = %v, want %v", got, want)
+	}
+
+	quoted := "'" + filepath.Join(dir, "*.go") + "'"
+	if got := shell.expandWord(quoted); len(got) != 1 || got[0] != filepath.Join(dir, "*.go") {
+		t.Errorf("quoted glob = %v, want literal pattern", got)
+	}
+
+	if got := shell.expandWord(filepath.Join(dir, "*.none")); got[0] != filepath.Join(dir, "*.none") {
+		t.Errorf("unmatched glob = %v, want literal pattern", got)
+	}
+}
@@ -0,0 +1,121 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("fifo", "fifo create|rm NAME | fifo list", "Manage named pipes usable as %NAME in redirections", builtinFifo)
+}
+
+// fifoRuntimeDir returns the directory holding this session's named pipes,
+// creating it on first use. It lives under XDG_RUNTIME_DIR when that is set.
+func (s *Shell) fifoRuntimeDir() (string, error) {
+	if s.fifoDir != "" {
+		return s.fifoDir, nil
+	}
+	base := s.env.Get("XDG_RUNTIME_DIR")
+	if base == "" {
+		base = os.TempDir()
+	}
+	dir := filepath.Join(base, "goshell-fifo-"+strconv.Itoa(os.Getpid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	s.fifoDir = dir
+	return dir, nil
+}
+
+// fifoPath returns the path of the named pipe registered as name
+func (s *Shell) fifoPath(name string) (string, error) {
+	path, ok := s.fifos[name]
+	if !ok {
+		return "", fmt.Errorf("%%%s: no such fifo", name)
+	}
+	return path, nil
+}
+
+// removeFifos deletes the session's named pipes and their directory
+func (s *Shell) removeFifos() {
+	if s.fifoDir != "" {
+		os.RemoveAll(s.fifoDir)
+		s.fifoDir = ""
+	}
+	s.fifos = make(map[string]string)
+}
+
+// builtinFifo creates, lists and removes named pipes kept in a directory
+// private to this session. Redirections refer to them as %NAME, while other
+// programs and terminals use the path shown by create and list.
+func builtinFifo(s *Shell, args []string, stdio Stdio) int {
+	flags := newFlagSet("fifo")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) < 1 {
+		return flags.usage(stdio)
+	}
+
+	switch operands[0] {
+	case "list":
+		names := make([]string, 0, len(s.fifos))
+		for name := range s.fifos {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdio.Stdout, "%%%s\t%s\n", name, s.fifos[name])
+		}
+		return 0
+	case "create", "rm":
+		if len(operands) != 2 {
+			return flags.usage(stdio)
+		}
+	default:
+		return flags.usage(stdio)
+	}
+
+	name := strings.TrimPrefix(operands[1], "%")
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		fmt.Fprintf(stdio.Stderr, "fifo: invalid name: %s\n", operands[1])
+		return 1
+	}
+
+	if operands[0] == "rm" {
+		path, err := s.fifoPath(name)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "fifo:", err)
+			return 1
+		}
+		delete(s.fifos, name)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(stdio.Stderr, "fifo:", err)
+			return 1
+		}
+		return 0
+	}
+
+	if _, exists := s.fifos[name]; exists {
+		fmt.Fprintf(stdio.Stderr, "fifo: %%%s already exists\n", name)
+		return 1
+	}
+	dir, err := s.fifoRuntimeDir()
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "fifo:", err)
+		return 1
+	}
+	path := filepath.Join(dir, name)
+	if err := makeFifo(path); err != nil {
+		fmt.Fprintln(stdio.Stderr, "fifo:", err)
+		return 1
+	}
+	s.fifos[name] = path
+	fmt.Fprintln(stdio.Stdout, path)
+	return 0
+}
@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"goshell/internal/parser"
+)
+
+func TestFifo(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("XDG_RUNTIME_DIR", t.TempDir())
+	defer shell.Close()
+
+	out, status := runCapture(t, shell, "fifo create p")
+	path := strings.TrimSpace(out)
+	if status != 0 {
+		t.Fatalf("fifo create = %q (status %d)", out, status)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("%s is not a named pipe: %v", path, err)
+	}
+	if out, _ := runCapture(t, shell, "fifo list"); out != "%p\t"+path+"\n" {
+		t.Errorf("fifo list = %q", out)
+	}
+	if _, status := runCapture(t, shell, "fifo create p"); status == 0 {
+		t.Error("creating a duplicate fifo succeeded")
+	}
+
+	// %NAME works in redirections on both ends
+	writer, err := parser.Parse("echo through the pipe > %p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go shell.runPipeline(writer.Pipelines[0], Stdio{Stdin: strings.NewReader(""), Stdout: os.Stdout, Stderr: os.Stderr})
+	if out, _ := runCapture(t, shell, "cat < %p"); out != "through the pipe\n" {
+		t.Errorf("cat < %%p = %q", out)
+	}
+
+	if _, status := runCapture(t, shell, "fifo rm p"); status != 0 {
+		t.Error("fifo rm failed")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("fifo rm left %s behind", path)
+	}
+	if out, status := runCapture(t, shell, "echo x > %p"); status == 0 || !strings.Contains(out, "no such fifo") {
+		t.Errorf("redirect to a removed fifo = %q (status %d)", out, status)
+	}
+
+	dir := shell.fifoDir
+	shell.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Close left the fifo directory %s behind", dir)
+	}
+}
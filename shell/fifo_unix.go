@@ -0,0 +1,10 @@
+//go:build unix
+
+package shell
+
+import "syscall"
+
+// makeFifo creates a named pipe at path
+func makeFifo(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
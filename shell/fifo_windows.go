@@ -0,0 +1,11 @@
+//go:build windows
+
+package shell
+
+import "errors"
+
+// makeFifo creates a named pipe at path. Named pipes live in a separate
+// namespace on Windows, so filesystem FIFOs are unavailable.
+func makeFifo(path string) error {
+	return errors.New("named pipes are not supported on this platform")
+}
@@ -0,0 +1,558 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func init() {
+	registerBuiltin("mkdir", "mkdir [-pv] dir...", "Create directories", builtinMkdir)
+	registerFlags("mkdir",
+		Candidate{"-p", "Create missing parents too, and don't mind existing directories"},
+		Candidate{"-v", "Report each directory created"})
+	registerBuiltin("touch", "touch [-c] file...", "Create files or set their times to now", builtinTouch)
+	registerFlags("touch", Candidate{"-c", "Don't create files that don't exist"})
+	registerBuiltin("rm", "rm [-rfiv] path...", "Remove files and directories", builtinRm)
+	registerFlags("rm",
+		Candidate{"-r", "Remove directories and everything in them"},
+		Candidate{"-f", "Ignore missing files and never ask"},
+		Candidate{"-i", "Ask before removing each file"},
+		Candidate{"-v", "Report each file removed"})
+	registerBuiltin("cp", "cp [-rpiv] source... dest", "Copy files and directories", builtinCp)
+	registerFlags("cp",
+		Candidate{"-r", "Copy directories and everything in them"},
+		Candidate{"-p", "Keep permissions and modification times"},
+		Candidate{"-i", "Ask before overwriting a file"},
+		Candidate{"-v", "Report each file copied"})
+	registerBuiltin("mv", "mv [-iv] source... dest", "Move or rename files and directories", builtinMv)
+	registerFlags("mv",
+		Candidate{"-i", "Ask before overwriting a file"},
+		Candidate{"-v", "Report each file moved"})
+}
+
+// mkdir, touch, rm, cp and mv are builtins so that they take the same
+// options on every system, Windows included, and so that every change they
+// make to the filesystem goes through the shell. Their messages follow GNU
+// coreutils.
+
+// fileOp is a run of one of the file builtins, with the options they share
+type fileOp struct {
+	command     string
+	interactive bool // -i: ask before removing or overwriting anything
+	verbose     bool // -v: report each change
+	stdio       Stdio
+	failed      bool
+}
+
+// fail reports an error and remembers that the command failed
+func (op *fileOp) fail(format string, args ...any) {
+	fmt.Fprintf(op.stdio.Stderr, op.command+": "+format+"\n", args...)
+	op.failed = true
+}
+
+// report describes a change made, with -v
+func (op *fileOp) report(format string, args ...any) {
+	if op.verbose {
+		fmt.Fprintf(op.stdio.Stdout, format+"\n", args...)
+	}
+}
+
+// confirm asks whether to go ahead with -i, and is true without it
+func (op *fileOp) confirm(format string, args ...any) bool {
+	if !op.interactive {
+		return true
+	}
+	fmt.Fprintf(op.stdio.Stderr, op.command+": "+format+"? ", args...)
+	return readAnswer(op.stdio.Stdin)
+}
+
+// status returns the command's exit status
+func (op *fileOp) status() int {
+	if op.failed {
+		return 1
+	}
+	return 0
+}
+
+// readAnswer reads a line from r and reports whether it answers yes. It
+// reads a byte at a time so as to leave the rest of the input to whatever
+// reads it next.
+func readAnswer(r io.Reader) bool {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			break
+		}
+	}
+	answer := strings.TrimSpace(string(line))
+	return strings.HasPrefix(answer, "y") || strings.HasPrefix(answer, "Y")
+}
+
+// builtinMkdir creates directories. -p creates their missing parents too
+// and doesn't mind directories that already exist.
+func builtinMkdir(s *Shell, args []string, stdio Stdio) int {
+	op := &fileOp{command: "mkdir", stdio: stdio}
+	var parents bool
+	flags := newFlagSet("mkdir")
+	flags.Bool(&parents, "p")
+	flags.Bool(&op.verbose, "v")
+	dirs, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(dirs) == 0 {
+		return flags.usage(stdio)
+	}
+	for _, dir := range dirs {
+		if !parents {
+			if err := os.Mkdir(dir, 0777); err != nil {
+				op.fail("cannot create directory '%s': %v", dir, unwrapPathError(err))
+				continue
+			}
+			op.report("mkdir: created directory '%s'", dir)
+			continue
+		}
+		missing := missingDirs(dir)
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			op.fail("cannot create directory '%s': %v", dir, unwrapPathError(err))
+			continue
+		}
+		for _, created := range missing {
+			op.report("mkdir: created directory '%s'", created)
+		}
+	}
+	return op.status()
+}
+
+// missingDirs returns dir and those of its parents that don't exist yet,
+// outermost first
+func missingDirs(dir string) []string {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	slices.Reverse(missing)
+	return missing
+}
+
+// builtinTouch sets the access and modification times of files to now,
+// creating those that don't exist unless -c is given
+func builtinTouch(s *Shell, args []string, stdio Stdio) int {
+	op := &fileOp{command: "touch", stdio: stdio}
+	var noCreate bool
+	flags := newFlagSet("touch")
+	flags.Bool(&noCreate, "c")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(files) == 0 {
+		return flags.usage(stdio)
+	}
+	now := time.Now()
+	for _, file := range files {
+		err := os.Chtimes(file, now, now)
+		if errors.Is(err, fs.ErrNotExist) {
+			if noCreate {
+				continue
+			}
+			var f *os.File
+			if f, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0666); err == nil {
+				err = f.Close()
+			}
+		}
+		if err != nil {
+			op.fail("cannot touch '%s': %v", file, unwrapPathError(err))
+		}
+	}
+	return op.status()
+}
+
+// builtinRm removes files, and directories with everything in them with
+// -r. -f ignores files that don't exist and turns -i off. It refuses to
+// remove . and .., or / with -r.
+func builtinRm(s *Shell, args []string, stdio Stdio) int {
+	op := &fileOp{command: "rm", stdio: stdio}
+	var recursive, force bool
+	flags := newFlagSet("rm")
+	flags.Bool(&recursive, "r", "R")
+	flags.Bool(&force, "f")
+	flags.Bool(&op.interactive, "i")
+	flags.Bool(&op.verbose, "v")
+	paths, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(paths) == 0 && !force {
+		return flags.usage(stdio)
+	}
+	if force {
+		op.interactive = false
+	}
+	for _, path := range paths {
+		if base := filepath.Base(path); base == "." || base == ".." {
+			op.fail("refusing to remove '.' or '..' directory: skipping '%s'", path)
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			if !(force && errors.Is(err, fs.ErrNotExist)) {
+				op.fail("cannot remove '%s': %v", path, unwrapPathError(err))
+			}
+			continue
+		}
+		if info.IsDir() {
+			if !recursive {
+				op.fail("cannot remove '%s': is a directory", path)
+				continue
+			}
+			if clean := filepath.Clean(path); filepath.Dir(clean) == clean {
+				op.fail("it is dangerous to operate recursively on '%s'", path)
+				continue
+			}
+		}
+		op.remove(path, info)
+	}
+	return op.status()
+}
+
+// remove removes a file, or a directory and everything in it, asking about
+// each with -i and reporting each with -v
+func (op *fileOp) remove(path string, info fs.FileInfo) {
+	if !info.IsDir() {
+		if !op.confirm("remove %s '%s'", fileTypeName(info.Mode()), path) {
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			op.fail("cannot remove '%s': %v", path, unwrapPathError(err))
+			return
+		}
+		op.report("removed '%s'", path)
+		return
+	}
+	if !op.interactive && !op.verbose {
+		if err := os.RemoveAll(path); err != nil {
+			op.fail("cannot remove '%s': %v", path, unwrapPathError(err))
+		}
+		return
+	}
+
+	if !op.confirm("descend into directory '%s'", path) {
+		return
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		op.fail("cannot remove '%s': %v", path, unwrapPathError(err))
+		return
+	}
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			op.fail("cannot remove '%s': %v", child, unwrapPathError(err))
+			continue
+		}
+		op.remove(child, info)
+	}
+	if !op.confirm("remove directory '%s'", path) {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		op.fail("cannot remove '%s': %v", path, unwrapPathError(err))
+		return
+	}
+	op.report("removed directory '%s'", path)
+}
+
+// fileTargets splits the operands of cp and mv into the sources and where
+// each goes: into the last operand if it is a directory, or to it if there
+// is a single source
+func (op *fileOp) fileTargets(operands []string) (sources, targets []string, ok bool) {
+	if len(operands) < 2 {
+		if len(operands) == 1 {
+			op.fail("missing destination file operand after '%s'", operands[0])
+		} else {
+			op.fail("missing file operand")
+		}
+		return nil, nil, false
+	}
+	sources, dest := operands[:len(operands)-1], operands[len(operands)-1]
+	info, err := os.Stat(dest)
+	intoDir := err == nil && info.IsDir()
+	if len(sources) > 1 && !intoDir {
+		op.fail("target '%s' is not a directory", dest)
+		return nil, nil, false
+	}
+	for _, source := range sources {
+		target := dest
+		if intoDir {
+			target = filepath.Join(dest, filepath.Base(filepath.Clean(source)))
+		}
+		targets = append(targets, target)
+	}
+	return sources, targets, true
+}
+
+// fileCopy is how cp copies
+type fileCopy struct {
+	*fileOp
+	recursive bool // -r: copy directories, and symlinks as symlinks
+	preserve  bool // -p: keep permissions and modification times
+}
+
+// builtinCp copies files, and directories with everything in them with -r,
+// to the last operand, or into it if it is a directory. -p keeps the
+// files' permissions and times.
+func builtinCp(s *Shell, args []string, stdio Stdio) int {
+	cp := fileCopy{fileOp: &fileOp{command: "cp", stdio: stdio}}
+	flags := newFlagSet("cp")
+	flags.Bool(&cp.recursive, "r", "R")
+	flags.Bool(&cp.preserve, "p")
+	flags.Bool(&cp.interactive, "i")
+	flags.Bool(&cp.verbose, "v")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	sources, targets, ok := cp.fileTargets(operands)
+	if !ok {
+		return 1
+	}
+	for i, source := range sources {
+		cp.copy(source, targets[i])
+	}
+	return cp.status()
+}
+
+// copy copies a file or directory to target
+func (cp fileCopy) copy(source, target string) {
+	stat := os.Stat
+	if cp.recursive {
+		stat = os.Lstat
+	}
+	info, err := stat(source)
+	if err != nil {
+		cp.fail("cannot stat '%s': %v", source, unwrapPathError(err))
+		return
+	}
+	if existing, err := os.Stat(target); err == nil && os.SameFile(info, existing) {
+		cp.fail("'%s' and '%s' are the same file", source, target)
+		return
+	}
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		if !cp.recursive {
+			cp.fail("-r not specified; omitting directory '%s'", source)
+			return
+		}
+		if isInside(target, source) {
+			cp.fail("cannot copy a directory, '%s', into itself, '%s'", source, target)
+			return
+		}
+		cp.copyDir(source, target, info)
+	case mode&fs.ModeSymlink != 0:
+		link, err := os.Readlink(source)
+		if err != nil {
+			cp.fail("cannot read symbolic link '%s': %v", source, unwrapPathError(err))
+			return
+		}
+		if !cp.replace(target) {
+			return
+		}
+		if err := os.Symlink(link, target); err != nil {
+			cp.fail("cannot create symbolic link '%s': %v", target, unwrapPathError(err))
+			return
+		}
+		cp.report("'%s' -> '%s'", source, target)
+	case mode.IsRegular():
+		cp.copyFile(source, target, info)
+	default:
+		cp.fail("cannot copy special file '%s'", source)
+	}
+}
+
+// copyDir copies a directory and everything in it. The copy is writable
+// while it is filled in, and gets the source's permissions after.
+func (cp fileCopy) copyDir(source, target string, info fs.FileInfo) {
+	if err := os.Mkdir(target, info.Mode().Perm()|0700); err != nil && !errors.Is(err, fs.ErrExist) {
+		cp.fail("cannot create directory '%s': %v", target, unwrapPathError(err))
+		return
+	}
+	cp.report("'%s' -> '%s'", source, target)
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		cp.fail("cannot access '%s': %v", source, unwrapPathError(err))
+	}
+	for _, entry := range entries {
+		cp.copy(filepath.Join(source, entry.Name()), filepath.Join(target, entry.Name()))
+	}
+	cp.finish(target, info)
+}
+
+// copyFile copies a regular file's contents
+func (cp fileCopy) copyFile(source, target string, info fs.FileInfo) {
+	if _, err := os.Lstat(target); err == nil && !cp.confirm("overwrite '%s'", target) {
+		return
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		cp.fail("cannot open '%s' for reading: %v", source, unwrapPathError(err))
+		return
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		cp.fail("cannot create regular file '%s': %v", target, unwrapPathError(err))
+		return
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cp.fail("error copying '%s' to '%s': %v", source, target, unwrapPathError(err))
+		return
+	}
+	cp.report("'%s' -> '%s'", source, target)
+	cp.finish(target, info)
+}
+
+// finish gives a copy the source's permissions, which a directory was
+// created without, and with -p its times too
+func (cp fileCopy) finish(target string, info fs.FileInfo) {
+	if info.IsDir() || cp.preserve {
+		if err := os.Chmod(target, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+			cp.fail("preserving permissions for '%s': %v", target, unwrapPathError(err))
+		}
+	}
+	if cp.preserve {
+		accessed, _, _ := fileTimes(info)
+		if accessed.IsZero() {
+			accessed = info.ModTime()
+		}
+		if err := os.Chtimes(target, accessed, info.ModTime()); err != nil {
+			cp.fail("preserving times for '%s': %v", target, unwrapPathError(err))
+		}
+	}
+}
+
+// replace clears the way for a symlink to be copied to target, which has to
+// be removed first if it exists
+func (cp fileCopy) replace(target string) bool {
+	if _, err := os.Lstat(target); err != nil {
+		return true
+	}
+	if !cp.confirm("overwrite '%s'", target) {
+		return false
+	}
+	if err := os.Remove(target); err != nil {
+		cp.fail("cannot remove '%s': %v", target, unwrapPathError(err))
+		return false
+	}
+	return true
+}
+
+// isInside reports whether path is dir or somewhere below it
+func isInside(path, dir string) bool {
+	absPath, err1 := filepath.Abs(path)
+	absDir, err2 := filepath.Abs(dir)
+	return err1 == nil && err2 == nil && isPathPrefix(absDir, absPath)
+}
+
+// builtinMv moves files and directories to the last operand, or into it if
+// it is a directory. Moving to another filesystem copies, then removes the
+// original.
+func builtinMv(s *Shell, args []string, stdio Stdio) int {
+	op := &fileOp{command: "mv", stdio: stdio}
+	flags := newFlagSet("mv")
+	flags.Bool(&op.interactive, "i")
+	flags.Bool(&op.verbose, "v")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	sources, targets, ok := op.fileTargets(operands)
+	if !ok {
+		return 1
+	}
+	for i, source := range sources {
+		op.move(source, targets[i])
+	}
+	return op.status()
+}
+
+// move renames source to target, copying it across filesystems
+func (op *fileOp) move(source, target string) {
+	info, err := os.Lstat(source)
+	if err != nil {
+		op.fail("cannot stat '%s': %v", source, unwrapPathError(err))
+		return
+	}
+	if existing, err := os.Lstat(target); err == nil {
+		if os.SameFile(info, existing) {
+			op.fail("'%s' and '%s' are the same file", source, target)
+			return
+		}
+		if !op.confirm("overwrite '%s'", target) {
+			return
+		}
+	}
+	if info.IsDir() && isInside(target, source) {
+		op.fail("cannot move '%s' to a subdirectory of itself, '%s'", source, target)
+		return
+	}
+
+	err = os.Rename(source, target)
+	if errors.Is(err, syscall.EXDEV) {
+		// A rename can't cross filesystems, so the files are copied over
+		// whole and the originals removed once that has worked
+		cp := fileCopy{fileOp: &fileOp{command: op.command, stdio: op.stdio}, recursive: true, preserve: true}
+		if existing, statErr := os.Lstat(target); statErr == nil && !existing.IsDir() {
+			os.Remove(target)
+		}
+		cp.copy(source, target)
+		if cp.failed {
+			op.failed = true
+			return
+		}
+		err = os.RemoveAll(source)
+	}
+	if err != nil {
+		op.fail("cannot move '%s' to '%s': %v", source, target, unwrapLinkError(err))
+		return
+	}
+	op.report("renamed '%s' -> '%s'", source, target)
+}
+
+// unwrapLinkError returns the cause of an error from a rename, which mv
+// shows after both paths
+func unwrapLinkError(err error) error {
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return linkErr.Err
+	}
+	return unwrapPathError(err)
+}
@@ -0,0 +1,140 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMkdirTouch(t *testing.T) {
+	dir := t.TempDir()
+	shell := NewShell()
+
+	out, status := runCapture(t, shell, "mkdir -pv "+filepath.Join(dir, "a", "b"))
+	want := "mkdir: created directory '" + filepath.Join(dir, "a") + "'\nmkdir: created directory '" + filepath.Join(dir, "a", "b") + "'\n"
+	if out != want || status != 0 {
+		t.Errorf("mkdir -pv = %q (status %d), want %q", out, status, want)
+	}
+	if _, status := runCapture(t, shell, "mkdir "+filepath.Join(dir, "a")); status != 1 {
+		t.Errorf("mkdir of an existing directory exited with %d, want 1", status)
+	}
+	if _, status := runCapture(t, shell, "mkdir -p "+filepath.Join(dir, "a")); status != 0 {
+		t.Errorf("mkdir -p of an existing directory exited with %d, want 0", status)
+	}
+
+	file := filepath.Join(dir, "new")
+	runCapture(t, shell, "touch -c "+file)
+	if _, err := os.Stat(file); err == nil {
+		t.Error("touch -c created a file")
+	}
+	runCapture(t, shell, "touch "+file)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(file, old, old)
+	runCapture(t, shell, "touch "+file)
+	if info, err := os.Stat(file); err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("touch didn't create or update the file: %v", err)
+	}
+}
+
+func TestRm(t *testing.T) {
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "tree")
+	os.MkdirAll(filepath.Join(tree, "sub"), 0755)
+	os.WriteFile(filepath.Join(tree, "sub", "f"), nil, 0644)
+	shell := NewShell()
+
+	for _, tt := range []struct {
+		args   string
+		status int
+	}{
+		{tree, 1},
+		{filepath.Join(dir, "missing"), 1},
+		{"-f " + filepath.Join(dir, "missing"), 0},
+		{"-r " + tree + string(filepath.Separator) + ".", 1},
+	} {
+		if _, status := runCapture(t, shell, "rm "+tt.args); status != tt.status {
+			t.Errorf("rm %s exited with %d, want %d", tt.args, status, tt.status)
+		}
+	}
+
+	out, status := runCapture(t, shell, "rm -rv "+tree)
+	want := "removed '" + filepath.Join(tree, "sub", "f") + "'\nremoved directory '" + filepath.Join(tree, "sub") + "'\nremoved directory '" + tree + "'\n"
+	if out != want || status != 0 {
+		t.Errorf("rm -rv = %q (status %d), want %q", out, status, want)
+	}
+	if _, err := os.Stat(tree); err == nil {
+		t.Error("rm -r left the directory")
+	}
+}
+
+func TestRmInteractive(t *testing.T) {
+	dir := t.TempDir()
+	keep, remove := filepath.Join(dir, "keep"), filepath.Join(dir, "remove")
+	os.WriteFile(keep, nil, 0644)
+	os.WriteFile(remove, nil, 0644)
+	var out syncBuffer
+	stdio := Stdio{Stdin: strings.NewReader("n\ny\n"), Stdout: &out, Stderr: &out}
+	builtinRm(NewShell(), []string{"rm", "-i", keep, remove}, stdio)
+	if _, err := os.Stat(keep); err != nil {
+		t.Error("rm -i removed a file it was told not to")
+	}
+	if _, err := os.Stat(remove); err == nil {
+		t.Error("rm -i kept a file it was told to remove")
+	}
+	if !strings.Contains(out.String(), "rm: remove regular file '"+keep+"'? ") {
+		t.Errorf("rm -i asked %q", out.String())
+	}
+}
+
+func TestCpMv(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.WriteFile(filepath.Join(src, "sub", "data"), []byte("data"), 0640)
+	os.Symlink("sub/data", filepath.Join(src, "link"))
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	os.Chtimes(filepath.Join(src, "sub", "data"), old, old)
+	shell := NewShell()
+
+	if _, status := runCapture(t, shell, "cp "+src+" "+filepath.Join(dir, "copy")); status != 1 {
+		t.Errorf("cp of a directory without -r exited with %d, want 1", status)
+	}
+	if _, status := runCapture(t, shell, "cp -r "+src+" "+filepath.Join(src, "sub")); status != 1 {
+		t.Errorf("cp of a directory into itself exited with %d, want 1", status)
+	}
+
+	copied := filepath.Join(dir, "copy")
+	if out, status := runCapture(t, shell, "cp -rp "+src+" "+copied); status != 0 {
+		t.Fatalf("cp -rp = %q (status %d)", out, status)
+	}
+	info, err := os.Stat(filepath.Join(copied, "sub", "data"))
+	if err != nil || info.Mode().Perm() != 0640 || !info.ModTime().Equal(old) {
+		t.Errorf("cp -p copied %v, want mode 0640 and time %v", info, old)
+	}
+	if target, err := os.Readlink(filepath.Join(copied, "link")); err != nil || target != "sub/data" {
+		t.Errorf("cp -r copied the symlink as %q (%v)", target, err)
+	}
+
+	// Copying into a directory keeps the name
+	runCapture(t, shell, "cp "+filepath.Join(src, "sub", "data")+" "+dir)
+	if data, err := os.ReadFile(filepath.Join(dir, "data")); err != nil || string(data) != "data" {
+		t.Errorf("cp into a directory wrote %q (%v)", data, err)
+	}
+	if _, status := runCapture(t, shell, "cp "+filepath.Join(dir, "data")+" "+src+" "+filepath.Join(dir, "data")); status != 1 {
+		t.Errorf("cp of several files to a file exited with %d, want 1", status)
+	}
+
+	out, status := runCapture(t, shell, "mv -v "+filepath.Join(dir, "data")+" "+filepath.Join(dir, "moved"))
+	if status != 0 || out != "renamed '"+filepath.Join(dir, "data")+"' -> '"+filepath.Join(dir, "moved")+"'\n" {
+		t.Errorf("mv -v = %q (status %d)", out, status)
+	}
+	runCapture(t, shell, "mv "+filepath.Join(dir, "moved")+" "+copied)
+	if _, err := os.Stat(filepath.Join(copied, "moved")); err != nil {
+		t.Errorf("mv into a directory: %v", err)
+	}
+	if _, status := runCapture(t, shell, "mv "+src+" "+filepath.Join(src, "sub")); status != 1 {
+		t.Errorf("mv of a directory into itself exited with %d, want 1", status)
+	}
+}
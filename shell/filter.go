@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+func init() {
+	registerBuiltin("filter", "filter [-iv] PATTERN [file...]", "Print lines matching a regular expression", builtinFilter)
+	registerFlags("filter",
+		Candidate{"-i", "Ignore case"},
+		Candidate{"-v", "Print lines that don't match"})
+}
+
+// builtinFilter prints the lines of its input that match a regular
+// expression, as grep does, with the matches highlighted on a terminal. -v
+// prints the lines that don't match instead and -i ignores case. It exits
+// with 1 if no line was printed and 2 on an error, as grep does.
+func builtinFilter(s *Shell, args []string, stdio Stdio) int {
+	var invert, ignoreCase bool
+	flags := newFlagSet("filter")
+	flags.Bool(&invert, "v")
+	flags.Bool(&ignoreCase, "i")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		flags.fail(stdio, err)
+		return 2
+	}
+	if len(operands) == 0 {
+		flags.usage(stdio)
+		return 2
+	}
+	expr := operands[0]
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "filter: bad pattern:", err)
+		return 2
+	}
+
+	input, closeInputs, err := openInputs(operands[1:], stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "filter:", err)
+		return 2
+	}
+	defer closeInputs()
+
+	// On a terminal each line is shown as soon as it is found, for input
+	// that trickles in such as a log being followed
+	terminal := isTerminal(stdio.Stdout)
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+
+	reader := bufio.NewReader(input)
+	found := false
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "filter:", err)
+			return 2
+		}
+		if pattern.MatchString(line) == invert {
+			continue
+		}
+		found = true
+		if terminal && !invert {
+			line = highlightMatches(pattern, line)
+		}
+		fmt.Fprintln(out, line)
+		if terminal {
+			out.Flush()
+		}
+	}
+	if !found {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	if err := os.WriteFile(path, []byte("ssh alpha\ngit push\nSSH beta\nls\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	for _, tt := range []struct {
+		args   string
+		want   string
+		status int
+	}{
+		{"ssh " + path, "ssh alpha\n", 0},
+		{"-i ssh " + path, "ssh alpha\nSSH beta\n", 0},
+		{"-v -i ssh " + path, "git push\nls\n", 0},
+		{"'^g.t' " + path, "git push\n", 0},
+		{"nothing " + path, "", 1},
+	} {
+		out, status := runCapture(t, shell, "filter "+tt.args)
+		if out != tt.want || status != tt.status {
+			t.Errorf("filter %s = %q (status %d), want %q (status %d)", tt.args, out, status, tt.want, tt.status)
+		}
+	}
+
+	if _, status := runCapture(t, shell, "filter '(' "+path); status != 2 {
+		t.Errorf("filter with a bad pattern exited with %d, want 2", status)
+	}
+
+	out, _ := runCapture(t, shell, "cat "+path+" | filter -i beta")
+	if out != "SSH beta\n" {
+		t.Errorf("filter in a pipeline = %q, want %q", out, "SSH beta\n")
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	shell := NewShell()
+	for _, cmd := range []string{"ssh alpha", "ls", "ssh beta"} {
+		shell.AddToHistory(cmd)
+	}
+	out, _ := runCapture(t, shell, "history | filter ssh")
+	if out != "1  ssh alpha\n3  ssh beta\n" {
+		t.Errorf("history | filter ssh = %q", out)
+	}
+}
@@ -0,0 +1,368 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("find", "find [dir...] [-name GLOB] [-type f|d|l] [-size [+-]N[ckMG]] [-mtime [+-]N] [-exec CMD {} ;|+] [-print]",
+		"Search for files by name, type, size or age", builtinFind)
+	registerFlags("find",
+		Candidate{"-name", "Names matching a glob"},
+		Candidate{"-type", "Files (f), directories (d) or symlinks (l)"},
+		Candidate{"-size", "Size in blocks, or c, k, M or G units; +N more, -N less"},
+		Candidate{"-mtime", "Modified N days ago; +N longer, -N more recently"},
+		Candidate{"-exec", "Run a command on each file, {} standing for it"},
+		Candidate{"-print", "Print the file's path"})
+}
+
+// findBatch is how many paths -exec ... {} + passes to each command
+const findBatch = 1000
+
+// findFile is a file find has reached. Its info is read only if a test
+// needs it.
+type findFile struct {
+	path  string
+	entry fs.DirEntry
+	info  fs.FileInfo
+}
+
+// stat returns the file's info, reading it the first time
+func (f *findFile) stat() (fs.FileInfo, error) {
+	if f.info == nil {
+		info, err := f.entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		f.info = info
+	}
+	return f.info, nil
+}
+
+// findPrimary is a test or action of find's expression. A file is printed
+// or acted on only if every primary before the action holds for it.
+type findPrimary func(f *findFile) (bool, error)
+
+// findSearch is a parsed find command
+type findSearch struct {
+	roots     []string
+	primaries []findPrimary
+	batches   []*findExecBatch // -exec ... + commands, run as paths collect
+	acts      bool             // the expression has an action, so paths aren't printed by default
+
+	shell *Shell
+	out   *bufio.Writer
+	stdio Stdio
+}
+
+// findExecBatch collects the paths for an -exec ... {} + command
+type findExecBatch struct {
+	command []string
+	paths   []string
+	failed  bool
+}
+
+// builtinFind walks directory trees, as find does, printing or running a
+// command on the files that pass every test. It supports the primaries most
+// searches need, the same on every system; an expression with others, such
+// as ! or -o, is left to the system's find. Ctrl-C stops the search.
+func builtinFind(s *Shell, args []string, stdio Stdio) int {
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	search := &findSearch{shell: s, out: out, stdio: stdio}
+	err := search.parse(args[1:])
+	var unknown findUnknownError
+	if errors.As(err, &unknown) {
+		return s.runSystem(args, stdio)
+	}
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "find:", err)
+		return 1
+	}
+	return search.run()
+}
+
+// findUnknownError reports a primary the builtin doesn't support
+type findUnknownError string
+
+func (e findUnknownError) Error() string {
+	return "unknown primary or operator: " + string(e)
+}
+
+// parse reads the starting directories and the expression
+func (search *findSearch) parse(args []string) error {
+	i := 0
+	for ; i < len(args) && !strings.HasPrefix(args[i], "-") && args[i] != "!" && args[i] != "("; i++ {
+		search.roots = append(search.roots, args[i])
+	}
+	if len(search.roots) == 0 {
+		search.roots = []string{"."}
+	}
+	for i < len(args) {
+		name := args[i]
+		i++
+		// value returns the primary's argument
+		value := func() (string, error) {
+			if i >= len(args) {
+				return "", fmt.Errorf("missing argument to %s", name)
+			}
+			i++
+			return args[i-1], nil
+		}
+		var primary findPrimary
+		switch name {
+		case "-name":
+			pattern, err := value()
+			if err != nil {
+				return err
+			}
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("-name %s: %v", pattern, err)
+			}
+			primary = func(f *findFile) (bool, error) {
+				return filepath.Match(pattern, filepath.Base(f.path))
+			}
+		case "-type":
+			kind, err := value()
+			if err != nil {
+				return err
+			}
+			var want fs.FileMode
+			switch kind {
+			case "f":
+				want = 0
+			case "d":
+				want = fs.ModeDir
+			case "l":
+				want = fs.ModeSymlink
+			default:
+				return fmt.Errorf("unknown argument to -type: %s", kind)
+			}
+			primary = func(f *findFile) (bool, error) {
+				return f.entry.Type()&fs.ModeType == want, nil
+			}
+		case "-size":
+			spec, err := value()
+			if err != nil {
+				return err
+			}
+			cmp, unit, err := parseFindSize(spec)
+			if err != nil {
+				return err
+			}
+			primary = func(f *findFile) (bool, error) {
+				info, err := f.stat()
+				if err != nil {
+					return false, err
+				}
+				// Sizes are counted in whole units, rounding up
+				return cmp.matches((info.Size() + unit - 1) / unit), nil
+			}
+		case "-mtime":
+			spec, err := value()
+			if err != nil {
+				return err
+			}
+			cmp, err := parseFindNumber(spec)
+			if err != nil {
+				return fmt.Errorf("invalid argument to -mtime: %s", spec)
+			}
+			now := time.Now()
+			primary = func(f *findFile) (bool, error) {
+				info, err := f.stat()
+				if err != nil {
+					return false, err
+				}
+				// Ages are counted in whole days, rounding down
+				return cmp.matches(int64(now.Sub(info.ModTime()) / (24 * time.Hour))), nil
+			}
+		case "-exec":
+			end := i
+			for end < len(args) && args[end] != ";" && !(args[end] == "+" && end > i && args[end-1] == "{}") {
+				end++
+			}
+			if end >= len(args) || end == i {
+				return errors.New("missing argument to -exec")
+			}
+			command := args[i:end]
+			if args[end] == "+" {
+				batch := &findExecBatch{command: command[:len(command)-1]}
+				search.batches = append(search.batches, batch)
+				primary = func(f *findFile) (bool, error) {
+					batch.paths = append(batch.paths, f.path)
+					if len(batch.paths) == findBatch {
+						search.flush(batch)
+					}
+					return true, nil
+				}
+			} else {
+				primary = func(f *findFile) (bool, error) {
+					return search.exec(command, f.path) == 0, nil
+				}
+			}
+			search.acts = true
+			i = end + 1
+		case "-print":
+			primary = func(f *findFile) (bool, error) {
+				fmt.Fprintln(search.out, f.path)
+				return true, nil
+			}
+			search.acts = true
+		default:
+			return findUnknownError(name)
+		}
+		search.primaries = append(search.primaries, primary)
+	}
+	return nil
+}
+
+// findNumber compares a number to the N, +N or -N a find primary was given
+type findNumber struct {
+	n    int64
+	sign byte // '+' for more than n, '-' for less, 0 for exactly n
+}
+
+// parseFindNumber parses N, +N or -N
+func parseFindNumber(spec string) (findNumber, error) {
+	var cmp findNumber
+	if spec != "" && (spec[0] == '+' || spec[0] == '-') {
+		cmp.sign, spec = spec[0], spec[1:]
+	}
+	n, err := strconv.ParseInt(spec, 10, 64)
+	if err != nil || n < 0 {
+		return cmp, fmt.Errorf("invalid number: %s", spec)
+	}
+	cmp.n = n
+	return cmp, nil
+}
+
+// matches reports whether n passes the comparison
+func (cmp findNumber) matches(n int64) bool {
+	switch cmp.sign {
+	case '+':
+		return n > cmp.n
+	case '-':
+		return n < cmp.n
+	}
+	return n == cmp.n
+}
+
+// findSizeUnits are the units -size counts in, by their suffix
+var findSizeUnits = map[byte]int64{'b': 512, 'c': 1, 'k': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}
+
+// parseFindSize parses the argument of -size: a number with an optional
+// unit suffix, counting 512-byte blocks if there is none
+func parseFindSize(spec string) (findNumber, int64, error) {
+	unit, number := int64(512), spec
+	if n := len(spec); n > 0 {
+		if u, ok := findSizeUnits[spec[n-1]]; ok {
+			unit, number = u, spec[:n-1]
+		}
+	}
+	cmp, err := parseFindNumber(number)
+	if err != nil || cmp.n > math.MaxInt64/unit {
+		return cmp, 0, fmt.Errorf("invalid argument to -size: %s", spec)
+	}
+	return cmp, unit, nil
+}
+
+// run walks each starting directory in turn
+func (search *findSearch) run() int {
+	status := 0
+	for _, root := range search.roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if search.shell.interrupted() {
+				return errInterrupted
+			}
+			if err != nil {
+				fmt.Fprintf(search.stdio.Stderr, "find: %s: %v\n", search.display(root, path), unwrapPathError(err))
+				status = 1
+				return nil
+			}
+			f := &findFile{path: search.display(root, path), entry: entry}
+			for _, primary := range search.primaries {
+				ok, err := primary(f)
+				if err != nil {
+					fmt.Fprintf(search.stdio.Stderr, "find: %s: %v\n", f.path, unwrapPathError(err))
+					status = 1
+				}
+				if !ok {
+					return nil
+				}
+			}
+			if !search.acts {
+				fmt.Fprintln(search.out, f.path)
+			}
+			return nil
+		})
+		if errors.Is(err, errInterrupted) {
+			return 130
+		}
+	}
+	for _, batch := range search.batches {
+		search.flush(batch)
+		if batch.failed {
+			status = 1
+		}
+	}
+	return status
+}
+
+// errInterrupted stops a walk when Ctrl-C is pressed
+var errInterrupted = errors.New("interrupted")
+
+// display returns the path of a file as find prints it: under the starting
+// directory as it was given, so that find . prints ./name
+func (search *findSearch) display(root, path string) string {
+	if path == root {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator) + rel
+}
+
+// exec runs an -exec command for a path, with {} standing for it in the
+// arguments, and returns its status
+func (search *findSearch) exec(command []string, path string) int {
+	argv := make([]string, len(command))
+	for i, arg := range command {
+		argv[i] = strings.ReplaceAll(arg, "{}", path)
+	}
+	search.out.Flush()
+	return search.shell.runArgs(argv, search.stdio)
+}
+
+// flush runs an -exec ... + command on the paths collected for it
+func (search *findSearch) flush(batch *findExecBatch) {
+	if len(batch.paths) == 0 {
+		return
+	}
+	argv := append(append([]string(nil), batch.command...), batch.paths...)
+	batch.paths = batch.paths[:0]
+	search.out.Flush()
+	if search.shell.runArgs(argv, search.stdio) != 0 {
+		batch.failed = true
+	}
+}
+
+// unwrapPathError returns the cause of an error about a path, which find
+// shows after the path itself
+func unwrapPathError(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"main.go": 10, "src/util.go": 2000, "src/lib/notes.txt": 600} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-72 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "src", "util.go"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	for _, tt := range []struct {
+		args string
+		want string
+	}{
+		{"", ".\n./main.go\n./src\n./src/lib\n./src/lib/notes.txt\n./src/util.go\n"},
+		{"src -name '*.go'", "src/util.go\n"},
+		{". -type d", ".\n./src\n./src/lib\n"},
+		{"-type f -size +1", "./src/lib/notes.txt\n./src/util.go\n"},
+		{"-type f -size -2k", "./main.go\n./src/lib/notes.txt\n"},
+		{"-size 10c", "./main.go\n"},
+		{"-type f -mtime +1", "./src/util.go\n"},
+		{"-type f -mtime -1", "./main.go\n./src/lib/notes.txt\n"},
+		{"-name '*.txt' -exec echo found {} ';'", "found ./src/lib/notes.txt\n"},
+		{"-name '*.go' -exec echo {} +", "./main.go ./src/util.go\n"},
+		{"-type f -exec test -s {} \\; -print", "./main.go\n./src/lib/notes.txt\n./src/util.go\n"},
+	} {
+		out, status := runCapture(t, shell, "find "+tt.args)
+		if out != tt.want || status != 0 {
+			t.Errorf("find %s = %q (status %d), want %q", tt.args, out, status, tt.want)
+		}
+	}
+
+	for _, args := range []string{"-size 1x", "-type q", "-exec echo {}", "-name"} {
+		if _, status := runCapture(t, shell, "find "+args); status != 1 {
+			t.Errorf("find %s exited with %d, want 1", args, status)
+		}
+	}
+	out, status := runCapture(t, shell, "find missing")
+	if status != 1 || !strings.Contains(out, "find: missing: ") {
+		t.Errorf("find missing = %q (status %d)", out, status)
+	}
+}
+
+func TestFindInterrupted(t *testing.T) {
+	shell := NewShell()
+	shell.interrupts = make(chan os.Signal, 1)
+	shell.interrupts <- os.Interrupt
+	out, status := runCapture(t, shell, "find "+t.TempDir())
+	if out != "" || status != 130 {
+		t.Errorf("interrupted find = %q (status %d), want nothing and status 130", out, status)
+	}
+}
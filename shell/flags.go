@@ -0,0 +1,161 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// flagSet parses a builtin's options the way getopt does: single-letter
+// flags can be grouped (-nr), a flag's value is either attached (-k2) or the
+// next argument (-k 2), long options are written --name or --name=value, and
+// -- ends the options so that operands such as a file named -l can follow.
+// A lone - is an operand, usually standing for stdin. Options may come after
+// operands unless stopAtOperand is set.
+type flagSet struct {
+	command string
+	flags   map[string]*flagDef
+
+	// stopAtOperand makes the first operand end the options, for commands
+	// whose operands include another command's arguments
+	stopAtOperand bool
+	// lenient makes an argument with an unknown option an operand instead of
+	// an error, which also ends the options, as echo needs
+	lenient bool
+}
+
+// flagDef is a single option of a flagSet
+type flagDef struct {
+	takesValue bool
+	set        func(value string) error
+}
+
+// newFlagSet creates an empty flag set for the named builtin
+func newFlagSet(command string) *flagSet {
+	return &flagSet{command: command, flags: make(map[string]*flagDef)}
+}
+
+// define registers an option under each of its names. Single-letter names
+// are used as -x, longer ones as --name.
+func (f *flagSet) define(def *flagDef, names []string) {
+	for _, name := range names {
+		f.flags[name] = def
+	}
+}
+
+// Bool defines an option that sets *p when given
+func (f *flagSet) Bool(p *bool, names ...string) {
+	f.define(&flagDef{set: func(string) error {
+		*p = true
+		return nil
+	}}, names)
+}
+
+// String defines an option whose value is stored in *p
+func (f *flagSet) String(p *string, names ...string) {
+	f.define(&flagDef{takesValue: true, set: func(value string) error {
+		*p = value
+		return nil
+	}}, names)
+}
+
+// Func defines an option whose value is handled by fn, for values that need
+// checking or options that can be repeated
+func (f *flagSet) Func(fn func(value string) error, names ...string) {
+	f.define(&flagDef{takesValue: true, set: fn}, names)
+}
+
+// Parse processes the options in args, which exclude the command name, and
+// returns the operands
+func (f *flagSet) Parse(args []string) ([]string, error) {
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(operands, args[i+1:]...), nil
+		case len(arg) < 2 || arg[0] != '-' || (f.lenient && !f.known(arg)):
+			if f.stopAtOperand || f.lenient {
+				return append(operands, args[i:]...), nil
+			}
+			operands = append(operands, arg)
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			def := f.flags[name]
+			if def == nil || len(name) < 2 {
+				return nil, fmt.Errorf("unrecognized option '--%s'", name)
+			}
+			if !def.takesValue && hasValue {
+				return nil, fmt.Errorf("option '--%s' doesn't allow an argument", name)
+			}
+			if def.takesValue && !hasValue {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("option '--%s' requires an argument", name)
+				}
+				i++
+				value = args[i]
+			}
+			if err := def.set(value); err != nil {
+				return nil, err
+			}
+		default:
+			for j := 1; j < len(arg); j++ {
+				name := arg[j : j+1]
+				def := f.flags[name]
+				if def == nil {
+					return nil, fmt.Errorf("invalid option -- '%s'", name)
+				}
+				value := ""
+				if def.takesValue {
+					// The value is the rest of this argument or the next one
+					value = arg[j+1:]
+					if value == "" {
+						if i+1 >= len(args) {
+							return nil, fmt.Errorf("option requires an argument -- '%s'", name)
+						}
+						i++
+						value = args[i]
+					}
+					j = len(arg)
+				}
+				if err := def.set(value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return operands, nil
+}
+
+// known reports whether every option in arg is defined
+func (f *flagSet) known(arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		name, _, _ := strings.Cut(arg[2:], "=")
+		return len(name) > 1 && f.flags[name] != nil
+	}
+	for j := 1; j < len(arg); j++ {
+		def := f.flags[arg[j:j+1]]
+		if def == nil {
+			return false
+		}
+		if def.takesValue {
+			return true
+		}
+	}
+	return true
+}
+
+// fail reports an error in a builtin's arguments, followed by its usage, and
+// returns the status for it
+func (f *flagSet) fail(stdio Stdio, err error) int {
+	fmt.Fprintf(stdio.Stderr, "%s: %v\n", f.command, err)
+	f.usage(stdio)
+	return 1
+}
+
+// usage prints the builtin's synopsis and returns the status for a misuse
+func (f *flagSet) usage(stdio Stdio) int {
+	if b, ok := builtins[f.command]; ok {
+		fmt.Fprintf(stdio.Stderr, "Usage: %s\n", b.usage)
+	}
+	return 1
+}
@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlagSetParse(t *testing.T) {
+	tests := []struct {
+		args     []string
+		operands []string
+		want     string // the options seen, or the error
+	}{
+		{[]string{"-ab", "file"}, []string{"file"}, "a b"},
+		{[]string{"file", "-a"}, []string{"file"}, "a"},
+		{[]string{"-k2", "-k", "3"}, nil, "k=2 k=3"},
+		{[]string{"-bk", "x", "-"}, []string{"-"}, "b k=x"},
+		{[]string{"--long", "--key=1", "--key", "2"}, nil, "long k=1 k=2"},
+		{[]string{"-a", "--", "-b", "--"}, []string{"-b", "--"}, "a"},
+		{[]string{"-x"}, nil, "invalid option -- 'x'"},
+		{[]string{"--nope"}, nil, "unrecognized option '--nope'"},
+		{[]string{"-k"}, nil, "option requires an argument -- 'k'"},
+		{[]string{"--key"}, nil, "option '--key' requires an argument"},
+		{[]string{"--long=1"}, nil, "option '--long' doesn't allow an argument"},
+	}
+	for _, tt := range tests {
+		var seen []string
+		flags := newFlagSet("test")
+		for _, name := range []string{"a", "b", "long"} {
+			flags.define(&flagDef{set: func(string) error {
+				seen = append(seen, name)
+				return nil
+			}}, []string{name})
+		}
+		flags.Func(func(value string) error {
+			seen = append(seen, "k="+value)
+			return nil
+		}, "k", "key")
+
+		operands, err := flags.Parse(tt.args)
+		got := strings.Join(seen, " ")
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want || !reflect.DeepEqual(operands, tt.operands) {
+			t.Errorf("Parse(%q) = %q, %q, want %q, %q", tt.args, operands, got, tt.operands, tt.want)
+		}
+	}
+}
+
+func TestFlagSetModes(t *testing.T) {
+	var n bool
+	flags := newFlagSet("test")
+	flags.Bool(&n, "n")
+	flags.stopAtOperand = true
+	if operands, _ := flags.Parse([]string{"-n", "cmd", "-n"}); !n || !reflect.DeepEqual(operands, []string{"cmd", "-n"}) {
+		t.Errorf("stopAtOperand: operands = %q", operands)
+	}
+
+	n = false
+	flags.lenient = true
+	if operands, err := flags.Parse([]string{"-nx", "-n"}); err != nil || n || !reflect.DeepEqual(operands, []string{"-nx", "-n"}) {
+		t.Errorf("lenient: operands = %q, %v, n = %v", operands, err, n)
+	}
+}
+
+func TestEndOfOptions(t *testing.T) {
+	dir := t.TempDir()
+	oldDir, _ := os.Getwd()
+	defer os.Chdir(oldDir)
+	os.Chdir(dir)
+	os.Mkdir(filepath.Join(dir, "-l"), 0755)
+	os.WriteFile("-n", []byte("b\na\n"), 0644)
+
+	shell := NewShell()
+	tests := []struct {
+		line, want string
+	}{
+		{"echo -- -x", "-x\n"},
+		{"echo -x --", "-x --\n"},
+		{"sort -- -n", "a\nb\n"},
+		{"cd -- -l; pwd", filepath.Join(dir, "-l") + "\n"},
+		{"sort -q", "sort: invalid option -- 'q'\n"},
+		{"uniq -q", "uniq: invalid option -- 'q'\nUsage: uniq [-cd] [file]\n"},
+	}
+	for _, tt := range tests {
+		got, _ := runCapture(t, shell, tt.line)
+		if !strings.HasSuffix(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
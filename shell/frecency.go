@@ -0,0 +1,261 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("z", "z [-l] [FRAGMENT...]", "Change to the most frecent directory matching the fragments (-l: list the matches)", builtinZ)
+	registerFlags("z", Candidate{"-l", "List the matching directories with their scores"})
+}
+
+// Every directory the shell moves to is recorded with how often and how
+// lately it was visited, as z and zoxide do, so z can go to the directory a
+// fragment most likely means: the one with the highest frecency, its visit
+// count weighted by how recent the last visit was.
+
+// visitsFileName is the file in the home directory visited directories are
+// kept in, one per line as rank, Unix time of the last visit and path,
+// separated by tabs
+const visitsFileName = ".goshell_dirs"
+
+// maxVisitRank is the total rank past which every rank is scaled down, so
+// the counts age and directories no longer used drop out
+const maxVisitRank = 10000
+
+// dirVisits is a directory's record of visits
+type dirVisits struct {
+	path string
+	rank float64
+	last time.Time
+}
+
+// frecency weights a directory's rank by how recently it was visited
+func (v dirVisits) frecency(now time.Time) float64 {
+	switch age := now.Sub(v.last); {
+	case age < time.Hour:
+		return v.rank * 4
+	case age < 24*time.Hour:
+		return v.rank * 2
+	case age < 7*24*time.Hour:
+		return v.rank / 2
+	}
+	return v.rank / 4
+}
+
+// visitsPath returns the file visited directories are kept in
+func (s *Shell) visitsPath() string {
+	return filepath.Join(s.homeDir(), visitsFileName)
+}
+
+// visitDir records the working directory as visited if it has changed since
+// the last call. The interactive shell calls it before each prompt.
+func (s *Shell) visitDir() {
+	dir, err := s.Getwd()
+	if err != nil || dir == s.visited {
+		return
+	}
+	s.visited = dir
+	if dir == s.homeDir() {
+		// z with no fragment goes home, as cd does
+		return
+	}
+	// Failing to record a visit shouldn't get in the way of the command
+	s.recordVisit(dir, time.Now())
+}
+
+// recordVisit adds a visit to a directory to the visits file, under a lock
+// as other sessions write it too
+func (s *Shell) recordVisit(dir string, now time.Time) error {
+	f, err := os.OpenFile(s.visitsPath(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f, true); err != nil {
+		return err
+	}
+	visits, err := readVisits(f)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	total := 0.0
+	for i := range visits {
+		if visits[i].path == dir {
+			visits[i].rank++
+			visits[i].last = now
+			found = true
+		}
+		total += visits[i].rank
+	}
+	if !found {
+		visits = append(visits, dirVisits{path: dir, rank: 1, last: now})
+		total++
+	}
+	if total > maxVisitRank {
+		kept := visits[:0]
+		for _, v := range visits {
+			v.rank *= 0.9
+			if v.rank >= 1 {
+				kept = append(kept, v)
+			}
+		}
+		visits = kept
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(formatVisits(visits), 0)
+	return err
+}
+
+// readVisits reads the records in a visits file. Lines that can't be
+// parsed are skipped.
+func readVisits(r io.Reader) ([]dirVisits, error) {
+	var visits []dirVisits
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 || fields[2] == "" {
+			continue
+		}
+		rank, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		last, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		visits = append(visits, dirVisits{path: fields[2], rank: rank, last: time.Unix(last, 0)})
+	}
+	return visits, scanner.Err()
+}
+
+// formatVisits returns the text of a visits file holding visits
+func formatVisits(visits []dirVisits) []byte {
+	var b bytes.Buffer
+	for _, v := range visits {
+		fmt.Fprintf(&b, "%s\t%d\t%s\n", strconv.FormatFloat(v.rank, 'f', -1, 64), v.last.Unix(), v.path)
+	}
+	return b.Bytes()
+}
+
+// loadVisits reads the visits file. A missing file holds no visits.
+func (s *Shell) loadVisits() ([]dirVisits, error) {
+	f, err := os.Open(s.visitsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return nil, err
+	}
+	return readVisits(f)
+}
+
+// matchVisits returns the visited directories that still exist and match
+// every fragment, best first. The fragments must appear in the path in
+// order, ignoring case, and the last must be in its final component, so z
+// foo finds .../foo rather than .../foo/bar/baz. The working directory is
+// left out, as going there would do nothing.
+func (s *Shell) matchVisits(fragments []string, now time.Time) ([]dirVisits, error) {
+	visits, err := s.loadVisits()
+	if err != nil {
+		return nil, err
+	}
+	cwd, _ := s.Getwd()
+	var matches []dirVisits
+	for _, v := range visits {
+		if v.path == cwd || !matchFragments(v.path, fragments) {
+			continue
+		}
+		if info, err := os.Stat(v.path); err != nil || !info.IsDir() {
+			continue
+		}
+		matches = append(matches, v)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].frecency(now) > matches[j].frecency(now)
+	})
+	return matches, nil
+}
+
+// matchFragments reports whether each fragment appears in path after the
+// one before it, the last within the path's final component
+func matchFragments(path string, fragments []string) bool {
+	if len(fragments) == 0 {
+		return true
+	}
+	lower := strings.ToLower(path)
+	pos := 0
+	for _, fragment := range fragments {
+		fragment = strings.ToLower(fragment)
+		at := strings.Index(lower[pos:], fragment)
+		if at < 0 {
+			return false
+		}
+		pos += at + len(fragment)
+	}
+	last := strings.ToLower(fragments[len(fragments)-1])
+	return strings.Contains(strings.ToLower(filepath.Base(path)), last)
+}
+
+// builtinZ changes to the visited directory the fragments most likely
+// mean, as z and zoxide do: z proj goes to ~/work/project if that is where
+// you go most. A single argument that is a directory is changed to as cd
+// would, and z alone goes home. -l lists the matches and their scores
+// instead, best last.
+func builtinZ(s *Shell, args []string, stdio Stdio) int {
+	var list bool
+	flags := newFlagSet("z")
+	flags.Bool(&list, "l")
+	fragments, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if !list {
+		if len(fragments) == 0 {
+			return s.runArgs([]string{"cd"}, stdio)
+		}
+		if info, err := os.Stat(fragments[0]); len(fragments) == 1 && (fragments[0] == "-" || err == nil && info.IsDir()) {
+			return s.runArgs([]string{"cd", fragments[0]}, stdio)
+		}
+	}
+
+	now := time.Now()
+	matches, err := s.matchVisits(fragments, now)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "z:", err)
+		return 1
+	}
+	if list {
+		for i := len(matches) - 1; i >= 0; i-- {
+			fmt.Fprintf(stdio.Stdout, "%10.1f  %s\n", matches[i].frecency(now), s.tildePath(matches[i].path))
+		}
+		return 0
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(stdio.Stderr, "z: no match for '%s'\n", strings.Join(fragments, " "))
+		return 1
+	}
+	if err := s.changeDir(matches[0].path, false); err != nil {
+		fmt.Fprintln(stdio.Stderr, "Error changing directory:", err)
+		return 1
+	}
+	return 0
+}
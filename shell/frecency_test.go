@@ -0,0 +1,117 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchFragments(t *testing.T) {
+	for _, tt := range []struct {
+		path      string
+		fragments []string
+		want      bool
+	}{
+		{"/home/me/work/project", []string{"proj"}, true},
+		{"/home/me/work/project", []string{"PROJ"}, true},
+		{"/home/me/work/project", []string{"work", "proj"}, true},
+		{"/home/me/work/project", []string{"proj", "work"}, false},
+		{"/home/me/work/project/src", []string{"proj"}, false},
+		{"/home/me/work/project", []string{"nope"}, false},
+		{"/home/me/work/project", nil, true},
+	} {
+		if got := matchFragments(tt.path, tt.fragments); got != tt.want {
+			t.Errorf("matchFragments(%s, %q) = %v, want %v", tt.path, tt.fragments, got, tt.want)
+		}
+	}
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Now()
+	v := dirVisits{rank: 8}
+	for age, want := range map[time.Duration]float64{time.Minute: 32, 3 * time.Hour: 16, 3 * 24 * time.Hour: 4, 30 * 24 * time.Hour: 2} {
+		v.last = now.Add(-age)
+		if got := v.frecency(now); got != want {
+			t.Errorf("frecency of rank 8 visited %v ago = %v, want %v", age, got, want)
+		}
+	}
+}
+
+func TestZ(t *testing.T) {
+	home := t.TempDir()
+	dirs := map[string]string{}
+	for _, name := range []string{"work/project", "work/project/src", "old/project", "music"} {
+		dirs[name] = filepath.Join(home, name)
+		if err := os.MkdirAll(dirs[name], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+
+	// work/project is visited more often, old/project longer ago
+	now := time.Now()
+	for _, visit := range []struct {
+		dir string
+		age time.Duration
+	}{
+		{"work/project", time.Minute}, {"work/project", time.Minute}, {"work/project/src", time.Minute},
+		{"old/project", 30 * 24 * time.Hour}, {"old/project", 30 * 24 * time.Hour}, {"old/project", 30 * 24 * time.Hour},
+		{"music", time.Minute},
+	} {
+		if err := shell.recordVisit(dirs[visit.dir], now.Add(-visit.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(dirs["music"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, status := runCapture(t, shell, "z proj"); out != "" || status != 0 {
+		t.Fatalf("z proj = %q (status %d)", out, status)
+	}
+	if dir, _ := shell.Getwd(); dir != dirs["work/project"] {
+		t.Errorf("z proj went to %s, want %s", dir, dirs["work/project"])
+	}
+	// The working directory is left out, so the next best match is taken
+	runCapture(t, shell, "z proj")
+	if dir, _ := shell.Getwd(); dir != dirs["old/project"] {
+		t.Errorf("z proj from work/project went to %s, want %s", dir, dirs["old/project"])
+	}
+	runCapture(t, shell, "z w src")
+	if dir, _ := shell.Getwd(); dir != dirs["work/project/src"] {
+		t.Errorf("z w src went to %s", dir)
+	}
+
+	out, _ := runCapture(t, shell, "z -l")
+	want := "       0.8  ~/old/project\n       8.0  ~/work/project\n"
+	if out != want {
+		t.Errorf("z -l = %q, want %q", out, want)
+	}
+	// Missing directories don't match
+	if out, status := runCapture(t, shell, "z music"); status != 1 || !strings.Contains(out, "no match") {
+		t.Errorf("z music = %q (status %d)", out, status)
+	}
+	// A directory is changed to directly
+	runCapture(t, shell, "z ..")
+	if dir, _ := shell.Getwd(); dir != dirs["work/project"] {
+		t.Errorf("z .. went to %s", dir)
+	}
+
+	// Visits are counted once per change of directory
+	shell.visitDir()
+	shell.visitDir()
+	visits, err := shell.loadVisits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range visits {
+		if v.path == dirs["work/project"] && v.rank != 3 {
+			t.Errorf("work/project has rank %v after another visit, want 3", v.rank)
+		}
+	}
+}
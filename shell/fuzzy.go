@@ -0,0 +1,66 @@
+package shell
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fuzzyCompletion reports whether completion matches words fuzzily, which is
+// enabled with GOSHELL_COMPLETION_MODE=fuzzy
+func (s *Shell) fuzzyCompletion() bool {
+	return s.env.Get("GOSHELL_COMPLETION_MODE") == "fuzzy"
+}
+
+// matchWord reports whether a completion candidate matches the typed text:
+// as a prefix by default, or as a subsequence in fuzzy mode
+func (s *Shell) matchWord(candidate, typed string) bool {
+	if !s.fuzzyCompletion() {
+		return strings.HasPrefix(candidate, typed)
+	}
+	_, ok := fuzzyMatch(candidate, typed)
+	return ok
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in text in
+// order, and scores the match. Matches that are contiguous, start the text
+// or start a word within it (after - _ . / or a space, or at a capital)
+// score higher; skipped characters cost a little. Lowercase patterns match
+// case-insensitively.
+func fuzzyMatch(text, pattern string) (int, bool) {
+	ignoreCase := strings.ToLower(pattern) == pattern
+	score := 0
+	previous := rune(-1) // the character before the current one
+	lastMatch := -2      // index of the previous matched character
+	ti := 0
+	for _, p := range pattern {
+		matched := false
+		for ti < len(text) {
+			r, size := utf8.DecodeRuneInString(text[ti:])
+			index := ti
+			ti += size
+			prev := previous
+			previous = r
+			if r != p && !(ignoreCase && unicode.ToLower(r) == p) {
+				score--
+				continue
+			}
+			score += 1
+			switch {
+			case index == 0:
+				score += 10
+			case lastMatch == index-utf8.RuneLen(prev):
+				score += 5
+			case strings.ContainsRune("-_./ ", prev) || (unicode.IsUpper(r) && unicode.IsLower(prev)):
+				score += 8
+			}
+			lastMatch = index
+			matched = true
+			break
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	return score, true
+}
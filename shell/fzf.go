@@ -0,0 +1,100 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// When fzf is installed, Ctrl-R picks a command from the history with it
+// and Ctrl-T picks files to insert at the cursor. Without it the keys keep
+// their usual meanings: incremental search and transposing characters.
+func init() {
+	editorActions["fzf-history"] = editorAction{"Pick a command from the history with fzf", (*lineEditor).fzfHistory}
+	editorActions["fzf-file"] = editorAction{"Insert files picked with fzf at the cursor", (*lineEditor).fzfFile}
+}
+
+// fzfOptions are given to fzf before the ones particular to each picker, so
+// that it opens below the line rather than taking over the screen
+var fzfOptions = []string{"--height=40%", "--layout=reverse"}
+
+// fzfPath returns where fzf is on the shell's PATH, or "" if it isn't
+// installed
+func (s *Shell) fzfPath() string {
+	for _, dir := range filepath.SplitList(s.env.Get("PATH")) {
+		path := filepath.Join(dir, "fzf")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return path
+		}
+	}
+	return ""
+}
+
+// runFzf runs fzf with the terminal handed over to it, returning what was
+// picked. Candidates and picks are separated by NUL bytes, so they can span
+// lines. It returns nothing if the pick was cancelled or fzf failed.
+func (e *lineEditor) runFzf(path string, stdin *bytes.Reader, args ...string) []string {
+	cmd := exec.Command(path, slices.Concat(fzfOptions, []string{"--print0"}, args)...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	} else {
+		// fzf lists the files below its directory when stdin is a terminal
+		cmd.Stdin = os.Stdin
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	cmd.Env = e.shell.env.ToSlice()
+	if dir, err := e.shell.Getwd(); err == nil {
+		cmd.Dir = dir
+	}
+	var err error
+	e.ed.Suspend(func() { err = cmd.Run() })
+	if err != nil {
+		return nil
+	}
+	return strings.FieldsFunc(out.String(), func(r rune) bool { return r == 0 })
+}
+
+// fzfHistory replaces the line with a command picked from the history,
+// newest first, starting with the line as the query
+func (e *lineEditor) fzfHistory(line []rune, pos int) ([]rune, int) {
+	path := e.shell.fzfPath()
+	if path == "" {
+		e.ed.StartSearch()
+		return line, pos
+	}
+	var input bytes.Buffer
+	seen := make(map[string]bool)
+	for i := len(e.shell.history) - 1; i >= 0; i-- {
+		if text := e.shell.history[i].Text(); !seen[text] {
+			seen[text] = true
+			input.WriteString(text + "\x00")
+		}
+	}
+	picked := e.runFzf(path, bytes.NewReader(input.Bytes()), "--read0", "--no-multi", "--tiebreak=index", "--query="+string(line))
+	if len(picked) == 0 {
+		return line, pos
+	}
+	return []rune(picked[0]), len([]rune(picked[0]))
+}
+
+// fzfFile inserts the files picked with fzf at the cursor, escaped as
+// completion escapes them
+func (e *lineEditor) fzfFile(line []rune, pos int) ([]rune, int) {
+	path := e.shell.fzfPath()
+	if path == "" {
+		return editorActions["transpose-chars"].run(e, line, pos)
+	}
+	picked := e.runFzf(path, nil, "--multi")
+	if len(picked) == 0 {
+		return line, pos
+	}
+	for i, file := range picked {
+		picked[i] = escapeWord(file)
+	}
+	return replaceRunes(line, pos, pos, strings.Join(picked, " ")+" ")
+}
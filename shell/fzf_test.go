@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+// fakeFzf installs a script named fzf in a directory of its own, which
+// becomes the shell's PATH. It saves its arguments and input in the
+// directory and prints picks.
+func fakeFzf(t *testing.T, shell *Shell, picks string) string {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" > " + dir + "/args\ncat > " + dir + "/input\nprintf '" + picks + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, "fzf"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shell.env.Set("PATH", dir+":/bin:/usr/bin")
+	return dir
+}
+
+func TestFzfHistory(t *testing.T) {
+	shell := NewShell()
+	editor := newTestEditor(shell)
+	for _, cmd := range []string{"git status", "ls", "git status", "make"} {
+		shell.AddToHistory(cmd)
+	}
+	dir := fakeFzf(t, shell, `ls\0`)
+
+	line, pos := press(editor, []rune("gi"), 2, lineedit.CtrlR)
+	if string(line) != "ls" || pos != 2 {
+		t.Errorf("Ctrl-R = %q at %d, want the pick", string(line), pos)
+	}
+	input, _ := os.ReadFile(filepath.Join(dir, "input"))
+	if string(input) != "make\x00git status\x00ls\x00" {
+		t.Errorf("fzf read %q, want the history newest first without repeats", input)
+	}
+	if args, _ := os.ReadFile(filepath.Join(dir, "args")); !strings.Contains(string(args), "--query=gi") {
+		t.Errorf("fzf args = %q, want the line as the query", args)
+	}
+
+	// Cancelling leaves the line alone
+	fakeFzf(t, shell, ``)
+	if line, _ := press(editor, []rune("gi"), 2, lineedit.CtrlR); string(line) != "gi" {
+		t.Errorf("cancelled Ctrl-R = %q", string(line))
+	}
+
+	// Without fzf, Ctrl-R searches incrementally
+	editor.updateHistory(shell.HistoryEntries())
+	shell.env.Set("PATH", t.TempDir())
+	if line, _ := typeKeys(editor, nil, 0, "\x12sta"); string(line) != "git status" {
+		t.Errorf("Ctrl-R without fzf = %q, want the search's match", string(line))
+	}
+}
+
+func TestFzfFile(t *testing.T) {
+	shell := NewShell()
+	editor := newTestEditor(shell)
+	fakeFzf(t, shell, `a file.txt\0src/main.go\0`)
+
+	line, pos := press(editor, []rune("vim "), 4, lineedit.CtrlT)
+	if want := `vim a\ file.txt src/main.go `; string(line) != want || pos != len(want) {
+		t.Errorf("Ctrl-T = %q at %d, want %q", string(line), pos, want)
+	}
+
+	shell.env.Set("PATH", t.TempDir())
+	if line, _ := press(editor, []rune("ab"), 2, lineedit.CtrlT); string(line) != "ba" {
+		t.Errorf("Ctrl-T without fzf = %q, want the characters transposed", string(line))
+	}
+}
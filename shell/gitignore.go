@@ -0,0 +1,146 @@
+package shell
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// gitignoreRule is a pattern from a .gitignore file
+type gitignoreRule struct {
+	re      *regexp.Regexp // matched against paths relative to the file's directory
+	negate  bool           // a ! pattern, which brings back what an earlier one ignored
+	dirOnly bool           // a pattern ending in /, which only matches directories
+}
+
+// gitignore holds the rules of the .gitignore files that apply in a
+// directory: its own and those of the directories above it, each with the
+// directory it is relative to
+type gitignore struct {
+	parent *gitignore
+	dir    string
+	rules  []gitignoreRule
+}
+
+// readGitignore returns the rules that apply in dir: those in parent, which
+// apply in the directory above, and the ones in dir's .gitignore, if any
+func readGitignore(parent *gitignore, dir string) *gitignore {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return parent
+	}
+	defer f.Close()
+	g := &gitignore{parent: parent, dir: dir}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseGitignoreRule(scanner.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+	return g
+}
+
+// parseGitignoreRule parses a line of a .gitignore file, returning false for
+// blank lines and comments
+func parseGitignoreRule(line string) (gitignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitignoreRule{}, false
+	}
+	var rule gitignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+	// A pattern with a slash other than at its end is anchored to the
+	// .gitignore's directory; others match a name at any depth below it
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return gitignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates a .gitignore glob to a regular expression: * and
+// ? don't match a slash, ** matches across directories, and [...] is a
+// character class
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored reports whether the file at path is ignored. The last rule to
+// match decides, and the rules of deeper .gitignore files come after those
+// above them.
+func (g *gitignore) ignored(path string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	ignored := g.parent.ignored(path, isDir)
+	rel, err := filepath.Rel(g.dir, path)
+	if err != nil {
+		return ignored
+	}
+	rel = filepath.ToSlash(rel)
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,49 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# build output\n*.log\n!keep.log\nbuild/\n/todo.txt\ndocs/**/*.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, ".gitignore"), []byte("*.bak\n!important.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := readGitignore(nil, dir)
+	below := readGitignore(top, sub)
+
+	for _, tt := range []struct {
+		g     *gitignore
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{top, "app.log", false, true},
+		{top, "keep.log", false, false},
+		{top, "deep/in/app.log", false, true},
+		{top, "build", true, true},
+		{top, "build", false, false},
+		{top, "todo.txt", false, true},
+		{top, "sub/todo.txt", false, false},
+		{top, "docs/a/b/x.tmp", false, true},
+		{top, "docs/x.tmp", false, true},
+		{top, "x.tmp", false, false},
+		{below, "sub/old.bak", false, true},
+		{below, "sub/important.log", false, false},
+		{below, "sub/other.log", false, true},
+		{top, "sub/old.bak", false, false},
+	} {
+		if got := tt.g.ignored(filepath.Join(dir, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("ignored(%s, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
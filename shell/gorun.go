@@ -0,0 +1,198 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	goscanner "go/scanner"
+	gotoken "go/token"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+func init() {
+	registerBuiltin("gorun", "gorun [-l] CODE [ARGS...] | gorun -f FILE [ARGS...]", "Run Go code on the command's input and output", builtinGorun)
+	registerFlags("gorun",
+		Candidate{"-l", "Run the code once for each input line, held in line"},
+		Candidate{"-f", "Run a Go program from a file"})
+}
+
+// gorunPreferred picks the package a name refers to where the standard
+// library has several by that name
+var gorunPreferred = map[string]string{
+	"rand":     "math/rand",
+	"template": "text/template",
+	"scanner":  "text/scanner",
+	"parser":   "go/parser",
+}
+
+// gorunVersion matches the last element of a major version's import path,
+// as in math/rand/v2, which isn't the package's name
+var gorunVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// gorunPackages maps the name of each standard library package the
+// interpreter provides to its import path, so code can use packages
+// without importing them
+var gorunPackages = func() map[string]string {
+	packages := make(map[string]string)
+	for key := range stdlib.Symbols {
+		importPath := path.Dir(key)
+		if importPath == "." {
+			continue
+		}
+		name := path.Base(importPath)
+		if gorunVersion.MatchString(name) {
+			continue
+		}
+		if current, ok := packages[name]; ok && current < importPath {
+			continue
+		}
+		packages[name] = importPath
+	}
+	for name, importPath := range gorunPreferred {
+		packages[name] = importPath
+	}
+	return packages
+}()
+
+// gorunSource makes a program of code: a whole program is left as it is,
+// and otherwise code is the body of main, with the packages it uses
+// imported. In line mode the body runs for each line of the input, which
+// it finds in line. What is added goes on the first line, so errors give
+// the lines of code as it was written.
+func gorunSource(code string, lines bool) string {
+	if strings.HasPrefix(strings.TrimSpace(code), "package ") {
+		return code
+	}
+	imports := gorunImports(code)
+	var loop string
+	if lines {
+		imports["bufio"], imports["os"] = true, true
+		loop = "scanner := bufio.NewScanner(os.Stdin); scanner.Buffer(nil, 1<<20); " +
+			"for scanner.Scan() { line := scanner.Text(); _ = line; "
+		code += "\n}"
+	}
+	paths := make([]string, 0, len(imports))
+	for name := range imports {
+		paths = append(paths, strconv.Quote(gorunPackages[name]))
+	}
+	sort.Strings(paths)
+	return "package main; import (" + strings.Join(paths, "; ") + "); func main() { " + loop + code + "\n}\n"
+}
+
+// gorunImports returns the names of the standard library packages code
+// refers to, as name.Member
+func gorunImports(code string) map[string]bool {
+	imports := make(map[string]bool)
+	fset := gotoken.NewFileSet()
+	var sc goscanner.Scanner
+	sc.Init(fset.AddFile("", -1, len(code)), []byte(code), nil, 0)
+	var name string // the last token, if a name that isn't a member
+	afterDot := false
+	for {
+		_, tok, lit := sc.Scan()
+		if tok == gotoken.EOF {
+			break
+		}
+		if tok == gotoken.PERIOD && name != "" {
+			if _, ok := gorunPackages[name]; ok {
+				imports[name] = true
+			}
+		}
+		name = ""
+		if tok == gotoken.IDENT && !afterDot {
+			name = lit
+		}
+		afterDot = tok == gotoken.PERIOD
+	}
+	return imports
+}
+
+// builtinGorun runs Go code with the Go interpreter yaegi: os.Stdin,
+// os.Stdout and os.Stderr are the command's streams, os.Args holds gorun and
+// the arguments after the code, and os.Getenv sees the shell's variables.
+// The code is the body of main, with the standard library packages it
+// names imported, unless it is a whole program beginning with package. As
+// the interpreter is sandboxed, os.Exit ends the code rather than the shell
+// and packages such as os/exec aren't available.
+func builtinGorun(s *Shell, args []string, stdio Stdio) int {
+	var lines bool
+	var file string
+	flags := newFlagSet("gorun")
+	flags.Bool(&lines, "l")
+	flags.String(&file, "f")
+	flags.stopAtOperand = true
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	var code string
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "gorun:", unwrapPathError(err))
+			return 1
+		}
+		code = string(data)
+	} else if len(operands) == 0 {
+		return flags.usage(stdio)
+	} else {
+		code, operands = operands[0], operands[1:]
+	}
+
+	i := interp.New(interp.Options{
+		Stdin:  stdio.Stdin,
+		Stdout: stdio.Stdout,
+		Stderr: stdio.Stderr,
+		Args:   append([]string{"gorun"}, operands...),
+		Env:    s.childEnv(stdio.Stderr),
+	})
+	// The sandbox's os.Exit panics, which the interpreter reports, so
+	// os.Exit ends the code's goroutine quietly instead
+	exitStatus := -1
+	exit := func(code int) {
+		exitStatus = code
+		runtime.Goexit()
+	}
+	for _, symbols := range []interp.Exports{stdlib.Symbols, {"os/os": {"Exit": reflect.ValueOf(exit)}}} {
+		if err := i.Use(symbols); err != nil {
+			fmt.Fprintln(stdio.Stderr, "gorun:", err)
+			return 1
+		}
+	}
+	ctx, cancel := s.interruptContext()
+	defer cancel()
+	if _, err = i.EvalWithContext(ctx, gorunSource(code, lines)); err == nil && exitStatus >= 0 {
+		return exitStatus
+	}
+	return gorunStatus(err, stdio)
+}
+
+// gorunStatus reports how the code ended: 0 if it returned, 2 after a
+// panic, as a Go program exits, 130 if it was interrupted, or 1 after any
+// other error
+func gorunStatus(err error, stdio Stdio) int {
+	if err == nil {
+		return 0
+	}
+	var p interp.Panic
+	if errors.As(err, &p) {
+		fmt.Fprintf(stdio.Stderr, "gorun: panic: %v\n", p.Value)
+		return 2
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 130
+	}
+	fmt.Fprintln(stdio.Stderr, "gorun:", err)
+	return 1
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGorun(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(program, []byte("package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\tfmt.Println(\"program\", os.Args[1:])\n}\n"), 0644)
+
+	shell := NewShell()
+	shell.env.Set("GREETING", "hi")
+	for _, test := range []struct {
+		line, want string
+		status     int
+	}{
+		{`echo 'b a' | gorun 'data, _ := io.ReadAll(os.Stdin); fmt.Print(strings.ToUpper(string(data)))'`, "B A\n", 0},
+		{`printf '3\n1\n2\n' | gorun -l 'n, _ := strconv.Atoi(line); fmt.Println(n * n)'`, "9\n1\n4\n", 0},
+		{`gorun 'fmt.Println(os.Getenv("GREETING"), os.Args[1:])' x y`, "hi [x y]\n", 0},
+		{`gorun 'fmt.Println("before"); os.Exit(4); fmt.Println("after")'`, "before\n", 4},
+		{`gorun -f ` + program + ` a`, "program [a]\n", 0},
+	} {
+		out, status := runCapture(t, shell, test.line)
+		if out != test.want || status != test.status {
+			t.Errorf("%s: got %q, status %d, want %q, status %d", test.line, out, status, test.want, test.status)
+		}
+	}
+
+	if out, status := runCapture(t, shell, `gorun 'fmt.Println(missing)'`); status != 1 || !strings.Contains(out, "undefined: missing") {
+		t.Errorf("gorun with a compile error: %q, status %d", out, status)
+	}
+	if out, status := runCapture(t, shell, `gorun 'panic("oops")'`); status != 2 || !strings.Contains(out, "gorun: panic: oops") {
+		t.Errorf("gorun with a panic: %q, status %d", out, status)
+	}
+}
+
+func TestGorunImports(t *testing.T) {
+	got := gorunImports(`n := rand.Intn(3); x.strings.Y(); json.Marshal(filepath.Join("a", fmt.Sprint(n)))`)
+	want := map[string]bool{"rand": true, "json": true, "filepath": true, "fmt": true}
+	if len(got) != len(want) {
+		t.Errorf("gorunImports = %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("gorunImports = %v, missing %s", got, name)
+		}
+	}
+	if path := gorunPackages["rand"]; path != "math/rand" {
+		t.Errorf("rand is %s", path)
+	}
+}
+
+func TestGorunCancel(t *testing.T) {
+	sh := New(Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := sh.Run(ctx, "gorun 'for i := 0; ; i++ {}'")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gorun ran for %v after the context ended", elapsed)
+	}
+}
@@ -0,0 +1,143 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("guard", "guard [-b] PATTERN REASON | -r PATTERN", "Ask before running, or block, commands matching a pattern", builtinGuard)
+	registerFlags("guard",
+		Candidate{"-b", "Block matching commands rather than asking"},
+		Candidate{"-r", "Remove the rule for a pattern"})
+}
+
+// Guard rules catch commands that can do damage that can't be undone, such
+// as rm -rf / or git push --force. Each is a regular expression matched
+// against a command once it is expanded, its words joined by spaces and
+// any sudo or doas in front dropped. A command matching one is only run if
+// the answer to a question on the terminal is yes, and never when there is
+// no terminal to ask on, as in a script; a rule added with -b blocks it
+// outright. set +o guard turns the rules off.
+
+// guardRule is a pattern of commands to ask about or block
+type guardRule struct {
+	pattern string
+	re      *regexp.Regexp
+	reason  string
+	block   bool
+}
+
+// guardTopDir matches the root directory or one of the system's directories
+// directly under it, as rm -rf /* expands to
+const guardTopDir = `/(bin|boot|dev|etc|home|lib\S*|opt|root|sbin|srv|sys|usr|var|Applications|Library|System|Users|Windows)?/?(\s|$)`
+
+// defaultGuards are the rules a session starts with
+var defaultGuards = []struct{ pattern, reason string }{
+	{`^rm\s(.*\s)?-(\S*[rR]\S*|-recursive)\s(.*\s)?` + guardTopDir, "deletes a system directory"},
+	{`^(chmod|chown|chgrp)\s(.*\s)?-(\S*R\S*|-recursive)\s(.*\s)?` + guardTopDir, "changes every file of a system directory"},
+	{`^dd\s(.*\s)?of=/dev/(sd|hd|vd|xvd|nvme|mmcblk|disk|rdisk)`, "overwrites a disk"},
+	{`^(mkfs(\.\S+)?|wipefs)\s`, "erases a filesystem"},
+	{`^git\s(.*\s)?push\s(.*\s)?(-f|--force|\+\S+)(\s|$)`, "overwrites history on the remote"},
+}
+
+// defaultGuardRules compiles the default guard rules
+func defaultGuardRules() []*guardRule {
+	rules := make([]*guardRule, len(defaultGuards))
+	for i, g := range defaultGuards {
+		rules[i] = &guardRule{pattern: g.pattern, re: regexp.MustCompile(g.pattern), reason: g.reason}
+	}
+	return rules
+}
+
+// guardText is a command as guard rules see it, without sudo or doas and
+// their options
+func guardText(args []string) string {
+	for len(args) > 1 && (args[0] == "sudo" || args[0] == "doas") {
+		args = args[1:]
+		for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// guardCommand checks a command against the guard rules, reporting whether
+// it may run: it matches none, or it was confirmed on the terminal
+func (s *Shell) guardCommand(args []string, stdio Stdio) bool {
+	if !s.options["guard"] || len(args) == 0 {
+		return true
+	}
+	text := guardText(args)
+	for _, rule := range s.guards {
+		if !rule.re.MatchString(text) {
+			continue
+		}
+		if rule.block {
+			fmt.Fprintf(stdio.Stderr, "goshell: blocked %s: it %s\n", text, rule.reason)
+			return false
+		}
+		question := fmt.Sprintf("goshell: %s %s. Run it?", text, rule.reason)
+		ok, err := s.confirm(question, stdio)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "goshell: not running %s without confirmation: it %s (set +o guard to allow it)\n", text, rule.reason)
+		}
+		return ok
+	}
+	return true
+}
+
+// builtinGuard adds a rule asking before running commands matching a
+// pattern, or blocking them with -b, replacing the pattern's rule if it has
+// one; removes a pattern's rule with -r; or lists the rules
+func builtinGuard(s *Shell, args []string, stdio Stdio) int {
+	var block bool
+	var remove string
+	flags := newFlagSet("guard")
+	flags.Bool(&block, "b")
+	flags.String(&remove, "r")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	switch {
+	case remove != "":
+		if len(operands) != 0 || block {
+			return flags.usage(stdio)
+		}
+		i := slices.IndexFunc(s.guards, func(r *guardRule) bool { return r.pattern == remove })
+		if i < 0 {
+			fmt.Fprintf(stdio.Stderr, "guard: no rule for %s\n", remove)
+			return 1
+		}
+		s.guards = slices.Delete(s.guards, i, i+1)
+		return 0
+	case len(operands) == 0 && !block:
+		for _, rule := range s.guards {
+			line := "guard "
+			if rule.block {
+				line += "-b "
+			}
+			fmt.Fprintln(stdio.Stdout, line+shellQuote(rule.pattern)+" "+shellQuote(rule.reason))
+		}
+		return 0
+	case len(operands) != 2:
+		return flags.usage(stdio)
+	}
+
+	re, err := regexp.Compile(operands[0])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "guard: bad pattern:", err)
+		return 1
+	}
+	rule := &guardRule{pattern: operands[0], re: re, reason: operands[1], block: block}
+	if i := slices.IndexFunc(s.guards, func(r *guardRule) bool { return r.pattern == rule.pattern }); i >= 0 {
+		s.guards[i] = rule
+	} else {
+		s.guards = append(s.guards, rule)
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGuardRules(t *testing.T) {
+	shell := New(Options{})
+	for _, tc := range []struct {
+		args    string
+		guarded bool
+	}{
+		{"rm -rf /", true},
+		{"sudo rm -fr /usr", true},
+		{"rm --recursive --force /", true},
+		{"rm -r -f /bin /boot /dev", true},
+		{"rm -rf /tmp/build", false},
+		{"rm -rf build", false},
+		{"rm /", false},
+		{"chmod -R 777 /", true},
+		{"chmod 777 /srv/www/index.html", false},
+		{"dd if=disk.img of=/dev/sdb bs=4M", true},
+		{"dd if=/dev/zero of=zeros bs=1M count=1", false},
+		{"mkfs.ext4 /dev/sdb1", true},
+		{"git push --force origin main", true},
+		{"git push -f", true},
+		{"git push origin +main", true},
+		{"git push origin main", false},
+		{"git push --force-with-lease", false},
+		{"echo rm -rf /", false},
+	} {
+		var errs strings.Builder
+		ok := shell.guardCommand(strings.Fields(tc.args), Stdio{Stdin: strings.NewReader(""), Stderr: &errs})
+		if ok == tc.guarded {
+			t.Errorf("guardCommand(%q) = %v, want %v (%q)", tc.args, ok, !tc.guarded, errs.String())
+		}
+	}
+}
+
+func TestGuardBuiltin(t *testing.T) {
+	shell := New(Options{})
+
+	// Without a terminal to ask on, a guarded command doesn't run
+	out, status := runCapture(t, shell, "git push --force")
+	if status != 1 || !strings.Contains(out, "without confirmation: it overwrites history on the remote") {
+		t.Errorf("guarded command = %q (status %d)", out, status)
+	}
+
+	if out, status := runCapture(t, shell, "guard -b '^echo secret' 'prints the secret'"); status != 0 {
+		t.Fatalf("guard -b = %q (status %d)", out, status)
+	}
+	out, status = runCapture(t, shell, "echo secret")
+	if status != 1 || out != "goshell: blocked echo secret: it prints the secret\n" {
+		t.Errorf("blocked command = %q (status %d)", out, status)
+	}
+	if out, _ := runCapture(t, shell, "guard"); !strings.Contains(out, "guard -b '^echo secret' 'prints the secret'\n") {
+		t.Errorf("guard lists %q", out)
+	}
+
+	shell.setOption("guard", false)
+	if out, status := runCapture(t, shell, "echo secret"); status != 0 || out != "secret\n" {
+		t.Errorf("with guard off = %q (status %d)", out, status)
+	}
+	shell.setOption("guard", true)
+
+	if out, status := runCapture(t, shell, "guard -r '^echo secret'"); status != 0 {
+		t.Fatalf("guard -r = %q (status %d)", out, status)
+	}
+	if out, status := runCapture(t, shell, "echo secret"); status != 0 || out != "secret\n" {
+		t.Errorf("after guard -r = %q (status %d)", out, status)
+	}
+	if out, status := runCapture(t, shell, "guard '(' 'bad'"); status != 1 || !strings.Contains(out, "bad pattern") {
+		t.Errorf("bad pattern = %q (status %d)", out, status)
+	}
+}
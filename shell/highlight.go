@@ -0,0 +1,200 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+// Colors used to highlight the command line
+const (
+	hlCommand  = Green
+	hlUnknown  = Red
+	hlString   = Yellow
+	hlOperator = Cyan
+	hlVariable = Magenta
+	hlComment  = Dim
+)
+
+// highlighting reports whether the input line is colorized as it is typed.
+// Setting GOSHELL_HIGHLIGHT=0 turns it off.
+func (s *Shell) highlighting() bool {
+	return s.env.Get("GOSHELL_HIGHLIGHT") != "0"
+}
+
+// Highlight colorizes a command line for display: command names are green
+// when they can be run and red otherwise, quoted strings are yellow,
+// operators cyan and variable references magenta. Unlike parser.Tokenize it
+// never fails, so unfinished input such as an open quote is colored up to
+// the end of the line. The visible text is unchanged.
+func (s *Shell) Highlight(line string) string {
+	var b strings.Builder
+	paint := func(color, text string) {
+		if text != "" {
+			b.WriteString(color + text + Reset)
+		}
+	}
+
+	commandPos := true // the next word names a command
+	redirect := false  // the next word is a redirection target
+	i := 0
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			b.WriteByte(c)
+			i++
+			continue
+		case c == '#':
+			paint(hlComment, line[i:])
+			return b.String()
+		case strings.HasPrefix(line[i:], ">(") || strings.HasPrefix(line[i:], "<("):
+			end, err := parser.ScanParens(line, i+1)
+			if err != nil {
+				end = len(line)
+			}
+			paint(hlVariable, line[i:end])
+			i = end
+			commandPos, redirect = false, false
+			continue
+		}
+
+		if op := parser.MatchOperator(line[i:]); op != "" {
+			paint(hlOperator, op)
+			i += len(op)
+			switch op {
+			case "|", "||", "&", "&&", ";":
+				commandPos = true
+			case "2>&1":
+			default:
+				redirect = true
+			}
+			continue
+		}
+
+		end, err := parser.ScanWord(line, i)
+		if err != nil {
+			end = len(line)
+		}
+		word := line[i:end]
+		switch {
+		case commandPos && !redirect:
+			if strings.ContainsAny(word, `'"$`) {
+				s.highlightWord(&b, word)
+			} else if name := unescapeWord(word); s.isCommand(name) || s.isAutoCd(s.homePath(name)) {
+				paint(hlCommand, word)
+			} else {
+				paint(hlUnknown, word)
+			}
+			commandPos = false
+		default:
+			s.highlightWord(&b, word)
+		}
+		redirect = false
+		i = end
+	}
+	return b.String()
+}
+
+// highlightWord colors the quoted strings and variable references inside an
+// argument
+func (s *Shell) highlightWord(b *strings.Builder, word string) {
+	i := 0
+	for i < len(word) {
+		start := i
+		color := ""
+		switch c := word[i]; {
+		case c == '\\':
+			i = min(i+2, len(word))
+		case c == '\'':
+			color = hlString
+			if end := strings.IndexByte(word[i+1:], '\''); end >= 0 {
+				i += end + 2
+			} else {
+				i = len(word)
+			}
+		case c == '"':
+			color = hlString
+			end, err := parser.ScanDoubleQuoted(word, i+1)
+			if err != nil {
+				end = len(word)
+			}
+			i = end
+		case c == '$':
+			color = hlVariable
+			i = variableEnd(word, i)
+		default:
+			for i < len(word) && !strings.ContainsRune(`\'"$`, rune(word[i])) {
+				i++
+			}
+		}
+		if color == "" {
+			b.WriteString(word[start:i])
+		} else {
+			b.WriteString(color + word[start:i] + Reset)
+		}
+	}
+}
+
+// variableEnd returns the index just past the variable reference or command
+// substitution at word[start], which is '$'. Unterminated references run to
+// the end of the word.
+func variableEnd(word string, start int) int {
+	i := start + 1
+	if i >= len(word) {
+		return i
+	}
+	switch word[i] {
+	case '?', '$':
+		return i + 1
+	case '(':
+		end, err := parser.ScanParens(word, i)
+		if err != nil {
+			return len(word)
+		}
+		return end
+	case '{':
+		if end := strings.IndexByte(word[i:], '}'); end >= 0 {
+			return i + end + 1
+		}
+		return len(word)
+	}
+	for i < len(word) && isNameChar(word[i], i == start+1) {
+		i++
+	}
+	return i
+}
+
+// isCommand reports whether name can be run: a builtin, an executable on
+// PATH, or a path to an executable file
+func (s *Shell) isCommand(name string) bool {
+	if _, ok := builtins[name]; ok {
+		return true
+	}
+	if _, ok := s.aliases[name]; ok {
+		return true
+	}
+	if strings.ContainsRune(name, '/') {
+		name = s.homePath(name)
+		info, err := os.Stat(name)
+		return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+	}
+	names := s.commands.names(s.env.Get("PATH"))
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
+
+// homePath expands a leading ~ in a word typed as a command to the home
+// directory
+func (s *Shell) homePath(name string) string {
+	if name == "~" {
+		return s.homeDir()
+	}
+	if rest, ok := strings.CutPrefix(name, "~/"); ok {
+		return filepath.Join(s.homeDir(), rest)
+	}
+	return name
+}
@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("PATH", "")
+	tests := []struct {
+		line, want string
+	}{
+		{"echo hi", Green + "echo" + Reset + " hi"},
+		{"nosuchcmd", Red + "nosuchcmd" + Reset},
+		{`echo 'a b' "$X"`, Green + "echo" + Reset + " " + Yellow + "'a b'" + Reset + " " + Yellow + `"$X"` + Reset},
+		{"echo $HOME/x", Green + "echo" + Reset + " " + Magenta + "$HOME" + Reset + "/x"},
+		{"ls | sort > out", Green + "ls" + Reset + " " + Cyan + "|" + Reset + " " + Green + "sort" + Reset + " " + Cyan + ">" + Reset + " out"},
+		{"pwd && nope", Green + "pwd" + Reset + " " + Cyan + "&&" + Reset + " " + Red + "nope" + Reset},
+		{"echo 'open", Green + "echo" + Reset + " " + Yellow + "'open" + Reset},
+		{"echo # note", Green + "echo" + Reset + " " + Dim + "# note" + Reset},
+	}
+	for _, tt := range tests {
+		if got := shell.Highlight(tt.line); got != tt.want {
+			t.Errorf("Highlight(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightKeepsText(t *testing.T) {
+	shell := NewShell()
+	ansi := regexp.MustCompile("\033\\[[0-9;]*m")
+	for _, line := range []string{
+		`cat <(ls -l) 2>&1 | tee -a "log $(date)" ; echo ${HOME`,
+		`a\ b "unterminated $(x`,
+		"\techo\n",
+	} {
+		if got := ansi.ReplaceAllString(shell.Highlight(line), ""); got != line {
+			t.Errorf("Highlight(%q) shows %q", line, got)
+		}
+	}
+}
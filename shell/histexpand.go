@@ -0,0 +1,205 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+// History expansion lets a typed command refer back to earlier ones, as in
+// bash: !! is the last command, !n the one numbered n by the history
+// builtin, !-n the nth last, !text the last starting with text and !?text?
+// the last containing it. A word designator after a colon picks words out
+// of the command: :0 is the command name, :n the nth argument, :^ the first,
+// :$ the last, :* all of them and :n-m a range. !$, !^ and !* are short for
+// !!:$, !!:^ and !!:*. Single quotes and a backslash keep a ! as it is, as
+// does a ! followed by a blank, = or (.
+
+// expandHistory expands the history references in a typed command line. It
+// leaves the line alone when the histexpand option is off.
+func (s *Shell) expandHistory(line string) (string, error) {
+	if !s.options["histexpand"] || !strings.Contains(line, "!") {
+		return line, nil
+	}
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+			continue
+		case c == '\'' && quote != '"':
+			quote ^= '\''
+		case c == '"' && quote != '\'':
+			quote ^= '"'
+		case c == '!' && quote != '\'':
+			text, n, err := s.historyReference(line[i:])
+			if err != nil {
+				return "", err
+			}
+			if n > 0 {
+				b.WriteString(text)
+				i += n - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// historyReference expands the reference at the start of ref, which begins
+// with !. It returns the text it stands for and its length, or a length of
+// 0 if the ! doesn't start a reference.
+func (s *Shell) historyReference(ref string) (string, int, error) {
+	if len(ref) < 2 || strings.ContainsRune(" \t\n=(", rune(ref[1])) {
+		return "", 0, nil
+	}
+
+	// The event: which command the reference is to
+	var event string
+	var err error
+	n := 1
+	switch c := ref[1]; {
+	case c == '!':
+		event, err = s.historyEvent(-1)
+		n = 2
+	case c == '$' || c == '^' || c == '*':
+		event, err = s.historyEvent(-1)
+	case c == '-' || (c >= '0' && c <= '9'):
+		end := 2
+		for end < len(ref) && ref[end] >= '0' && ref[end] <= '9' {
+			end++
+		}
+		num, convErr := strconv.Atoi(ref[1:end])
+		if convErr != nil {
+			return "", 0, fmt.Errorf("%s: event not found", ref[:end])
+		}
+		event, err = s.historyEvent(num)
+		n = end
+	case c == '?':
+		end := strings.IndexByte(ref[2:], '?')
+		if end < 0 {
+			end = len(ref) - 2
+		}
+		event, err = s.searchHistory(ref[2:2+end], strings.Contains)
+		n = min(2+end+1, len(ref))
+	default:
+		end := 1
+		for end < len(ref) && !strings.ContainsRune(" \t\n:;&|<>()'\"", rune(ref[end])) {
+			end++
+		}
+		if end == 1 {
+			return "", 0, nil
+		}
+		event, err = s.searchHistory(ref[1:end], strings.HasPrefix)
+		n = end
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("%s: %v", ref[:n], err)
+	}
+
+	// The word designator, if any
+	designator := ""
+	switch {
+	case n == 1:
+		// !$, !^ and !*
+		designator = ref[1:2]
+		n = 2
+	case n < len(ref)-1 && ref[n] == ':':
+		end := n + 1
+		for end < len(ref) && strings.ContainsRune("0123456789^$*-", rune(ref[end])) {
+			end++
+		}
+		designator = ref[n+1 : end]
+		if designator == "" {
+			return "", 0, fmt.Errorf("%s: bad word specifier", ref[:end+1])
+		}
+		n = end
+	}
+	if designator == "" {
+		return event, n, nil
+	}
+	words, err := historyWords(event, designator)
+	if err != nil {
+		return "", 0, fmt.Errorf("%s: %v", ref[:n], err)
+	}
+	return words, n, nil
+}
+
+// historyEvent returns history entry num as the history builtin numbers
+// them, or the -num'th last entry if num is negative
+func (s *Shell) historyEvent(num int) (string, error) {
+	i := num - 1
+	if num < 0 {
+		i = len(s.history) + num
+	}
+	if i < 0 || i >= len(s.history) {
+		return "", fmt.Errorf("event not found")
+	}
+	return s.history[i].Text(), nil
+}
+
+// searchHistory returns the most recent history entry for which match
+// reports true with text
+func (s *Shell) searchHistory(text string, match func(entry, text string) bool) (string, error) {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if entry := s.history[i].Text(); match(entry, text) {
+			return entry, nil
+		}
+	}
+	return "", fmt.Errorf("event not found")
+}
+
+// historyWords returns the words of a command that a word designator picks
+func historyWords(command, designator string) (string, error) {
+	var words []string
+	if tokens, err := parser.Tokenize(command); err == nil {
+		for _, tok := range tokens {
+			words = append(words, tok.Text)
+		}
+	} else {
+		words = strings.Fields(command)
+	}
+
+	index := func(spec string) (int, bool) {
+		switch spec {
+		case "^":
+			return 1, true
+		case "$":
+			return len(words) - 1, true
+		}
+		i, err := strconv.Atoi(spec)
+		return i, err == nil
+	}
+	from, to := 0, 0
+	switch {
+	case designator == "*":
+		from, to = 1, len(words)-1
+	case strings.Contains(designator[1:], "-"):
+		i := strings.Index(designator[1:], "-") + 1
+		var ok1, ok2 bool
+		from, ok1 = index(designator[:i])
+		to, ok2 = index(designator[i+1:])
+		if !ok1 || !ok2 {
+			return "", fmt.Errorf("bad word specifier")
+		}
+	default:
+		var ok bool
+		if from, ok = index(designator); !ok {
+			return "", fmt.Errorf("bad word specifier")
+		}
+		to = from
+	}
+	if from < 0 || to >= len(words) || (from > to && designator != "*") {
+		return "", fmt.Errorf("bad word specifier")
+	}
+	if from > to {
+		return "", nil
+	}
+	return strings.Join(words[from:to+1], " "), nil
+}
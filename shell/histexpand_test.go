@@ -0,0 +1,49 @@
+package shell
+
+import "testing"
+
+func TestExpandHistory(t *testing.T) {
+	shell := NewShell()
+	for _, cmd := range []string{"vim notes.txt", "ls -l /tmp", "cp a.txt b.txt", "make test"} {
+		shell.AddToHistory(cmd)
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"sudo !!", "sudo make test"},
+		{"!2", "ls -l /tmp"},
+		{"!-2", "cp a.txt b.txt"},
+		{"!vim", "vim notes.txt"},
+		{"!?-l?", "ls -l /tmp"},
+		{"echo !$", "echo test"},
+		{"cp !cp:$ /backup", "cp b.txt /backup"},
+		{"echo !cp:^ !cp:*", "echo a.txt a.txt b.txt"},
+		{"echo !2:0-1", "echo ls -l"},
+		{"echo !!:1; !!", "echo test; make test"},
+		{"echo hi!", "echo hi!"},
+		{`echo "hi!"`, `echo "hi!"`},
+		{"echo '!!'", "echo '!!'"},
+		{`echo \!!`, `echo \!!`},
+		{"[ a != b ]", "[ a != b ]"},
+		{"echo ! x", "echo ! x"},
+	}
+	for _, tt := range tests {
+		got, err := shell.expandHistory(tt.line)
+		if err != nil || got != tt.want {
+			t.Errorf("expandHistory(%q) = %q, %v; want %q", tt.line, got, err, tt.want)
+		}
+	}
+
+	for _, line := range []string{"!nosuch", "!99", "!!:5", "!!:x"} {
+		if got, err := shell.expandHistory(line); err == nil {
+			t.Errorf("expandHistory(%q) = %q, want an error", line, got)
+		}
+	}
+
+	shell.setOption("histexpand", false)
+	if got, _ := shell.expandHistory("sudo !!"); got != "sudo !!" {
+		t.Errorf("with histexpand off, got %q", got)
+	}
+}
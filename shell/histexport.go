@@ -0,0 +1,122 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// historyRecord is a history entry as history export writes it and history
+// import reads it back, on this machine or another
+type historyRecord struct {
+	Command string    `json:"command"`
+	Time    time.Time `json:"time,omitzero"`
+	Status  *int      `json:"status,omitempty"`
+	Dir     string    `json:"dir,omitempty"`
+}
+
+// exportHistory writes the history to w as a JSON array of records, oldest
+// first. Secrets are redacted as they are in the history file.
+func (s *Shell) exportHistory(w io.Writer) error {
+	records := make([]historyRecord, len(s.history))
+	for i, entry := range s.history {
+		entry, err := s.redactEntry(entry)
+		if err != nil {
+			return err
+		}
+		records[i] = historyRecord{Command: entry.Text(), Time: entry.Time, Status: entry.Status, Dir: entry.Dir}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// importHistory reads records written by exportHistory from r and adds the
+// entries not already in the history to its end, and to the history file
+// with any secrets redacted. It returns the number of entries added.
+func (s *Shell) importHistory(r io.Reader) (int, error) {
+	var records []historyRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return 0, fmt.Errorf("not an exported history: %v", err)
+	}
+
+	// An entry is taken to be in the history already if a command with the
+	// same text ran at the same time, as far as the history file keeps it
+	type key struct {
+		command string
+		time    int64
+	}
+	have := make(map[key]bool)
+	for _, entry := range s.history {
+		have[key{entry.Command, entry.Time.Unix()}] = true
+	}
+	var added, saved []HistoryEntry
+	for _, record := range records {
+		entry := savedEntry(record.Command)
+		entry.Time, entry.Status, entry.Dir = record.Time, record.Status, record.Dir
+		k := key{entry.Command, entry.Time.Unix()}
+		if entry.Command == "" || have[k] {
+			continue
+		}
+		have[k] = true
+		added = append(added, entry)
+		if !strings.Contains(entry.Text(), "\n") {
+			redacted, err := s.redactEntry(entry)
+			if err != nil {
+				return 0, err
+			}
+			saved = append(saved, redacted)
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+	s.mergeHistory(added)
+	s.historyStale = true
+
+	limit := s.historyLimit("HISTFILESIZE")
+	others, err := s.historyFile().rewrite(func(entries []HistoryEntry) []HistoryEntry {
+		entries = append(entries, saved...)
+		if limit >= 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+		return entries
+	})
+	if s.options["sharehistory"] {
+		s.mergeHistory(others)
+	}
+	return len(added), err
+}
+
+// historyExport exports the history to a file, or to stdout if path is -
+func historyExport(s *Shell, path string, stdio Stdio) error {
+	if path == "-" {
+		return s.exportHistory(stdio.Stdout)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := s.exportHistory(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// historyImport imports the history exported to a file, or read from stdin
+// if path is -
+func historyImport(s *Shell, path string, stdio Stdio) (int, error) {
+	if path == "-" {
+		return s.importHistory(stdio.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return s.importHistory(f)
+}
@@ -0,0 +1,80 @@
+package shell
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryExportImport(t *testing.T) {
+	dir := t.TempDir()
+	export := filepath.Join(dir, "history.json")
+
+	source := NewShell()
+	source.env.Set("HISTFILE", filepath.Join(dir, "source_history"))
+	for i, cmd := range []string{"make test", "export API_KEY=xyz", "false"} {
+		source.AddToHistory(cmd)
+		source.recordHistoryStatus(source.lastHistoryEntry(), i%2)
+	}
+	if out, status := runCapture(t, source, "history export "+export); status != 0 || out != "" {
+		t.Fatalf("history export = %q, %d", out, status)
+	}
+	data, _ := os.ReadFile(export)
+	var records []historyRecord
+	if err := json.Unmarshal(data, &records); err != nil || len(records) != 3 {
+		t.Fatalf("export holds %s (%v)", data, err)
+	}
+	wd, _ := os.Getwd()
+	if r := records[0]; r.Command != "make test" || r.Time.IsZero() || r.Status == nil || *r.Status != 0 || r.Dir != wd {
+		t.Errorf("first record = %+v", r)
+	}
+	if r := records[1]; r.Command != "export API_KEY=***" || *r.Status != 1 {
+		t.Errorf("second record = %+v, want the secret redacted", r)
+	}
+
+	target := NewShell()
+	target.env.Set("HISTFILE", filepath.Join(dir, "target_history"))
+	target.AddToHistory("ls")
+	out, status := runCapture(t, target, "history import "+export)
+	if status != 0 || out != "Imported 3 entries\n" {
+		t.Errorf("history import = %q, %d", out, status)
+	}
+	if got := strings.Join(target.GetHistory(), ","); got != "ls,make test,export API_KEY=***,false" || !target.historyStale {
+		t.Errorf("history after import = %q", got)
+	}
+	if e := target.history[1]; !e.Time.Equal(records[0].Time) || e.Dir != wd || *e.Status != 0 {
+		t.Errorf("imported entry = %+v", e)
+	}
+	entries, _ := target.historyFile().load(-1)
+	if got := historyTexts(entries); got != "make test,export API_KEY=***,false" {
+		t.Errorf("history file after import holds %q", got)
+	}
+	if entries[0].Time.Unix() != records[0].Time.Unix() {
+		t.Errorf("history file lost the time: %v", entries[0].Time)
+	}
+
+	// Importing again adds nothing
+	if out, _ := runCapture(t, target, "history import "+export); out != "Imported 0 entries\n" {
+		t.Errorf("second import = %q", out)
+	}
+	os.WriteFile(export, []byte("not json"), 0600)
+	if _, status := runCapture(t, target, "history import "+export); status != 1 {
+		t.Errorf("import of a bad file = %d, want 1", status)
+	}
+}
+
+func TestHistoryRecordJSON(t *testing.T) {
+	data, _ := json.Marshal(historyRecord{Command: "ls"})
+	if string(data) != `{"command":"ls"}` {
+		t.Errorf("record without a time or status = %s", data)
+	}
+	status := 0
+	when := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	data, _ = json.Marshal(historyRecord{Command: "ls", Time: when, Status: &status})
+	if string(data) != `{"command":"ls","time":"2024-03-09T14:05:07Z","status":0}` {
+		t.Errorf("record = %s", data)
+	}
+}
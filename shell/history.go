@@ -0,0 +1,441 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"goshell/internal/parser"
+)
+
+// defaultHistFile is the file in the home directory the history is kept in
+// between sessions, unless HISTFILE names another
+const defaultHistFile = ".goshell_history"
+
+// defaultHistSize is the number of entries kept unless HISTSIZE says
+// otherwise
+const defaultHistSize = 1000
+
+// defaultHistTimeFormat is how history -t shows times when HISTTIMEFORMAT
+// isn't set
+const defaultHistTimeFormat = "%F %T  "
+
+// HistoryEntry is a single command recorded in the shell's history
+type HistoryEntry struct {
+	Command string    // normalized command line, used for display and dedup
+	Raw     string    // text exactly as typed, kept when HISTKEEPRAW is set
+	Time    time.Time // when the command ran, zero if it isn't known
+	Dir     string    // the working directory it ran in, if known
+	Status  *int      // its exit status, nil until it has finished or if it isn't known
+}
+
+// Text returns the entry as it should be recalled: the raw form when it was
+// kept, the normalized one otherwise
+func (e HistoryEntry) Text() string {
+	if e.Raw != "" {
+		return e.Raw
+	}
+	return e.Command
+}
+
+// historyHint remembers the last autosuggestion lookup, so typing further
+// characters only rescans entries older than the previous match
+type historyHint struct {
+	prefix string
+	index  int // of the matching entry, or -1 if none matched
+	size   int // history length at the time of the lookup
+}
+
+// AddToHistory adds a command to the shell's history. The command is
+// normalized first so retypings that differ only in spacing or trailing
+// semicolons are treated as duplicates, and a repeat of the last command
+// isn't added again. HISTCONTROL can leave out more, as described at
+// histControl. It reports whether an entry was added.
+func (s *Shell) AddToHistory(cmd string) bool {
+	if strings.HasPrefix(cmd, " ") && s.histControl("ignorespace") {
+		return false
+	}
+	cmd = strings.TrimLeft(cmd, " ")
+	normalized := parser.NormalizeCommand(cmd)
+	limit := s.historyLimit("HISTSIZE")
+
+	// Don't add empty commands or duplicates of the last command
+	if limit == 0 || normalized == "" || (len(s.history) > 0 && s.history[len(s.history)-1].Command == normalized) {
+		return false
+	}
+	erase := s.histControl("erasedups")
+	if !erase && s.histControl("ignoredups") && s.inHistory(normalized) {
+		return false
+	}
+
+	entry := HistoryEntry{Command: normalized, Time: time.Now()}
+	entry.Dir, _ = s.Getwd()
+	if s.env.Get("HISTKEEPRAW") != "" {
+		entry.Raw = cmd
+	}
+	if erase && s.inHistory(normalized) {
+		s.eraseHistory(func(old HistoryEntry) bool { return old.Command == normalized })
+	}
+	s.history = append(s.history, entry)
+	if limit > 0 && len(s.history) > limit {
+		s.history = s.history[len(s.history)-limit:]
+		s.lastHint = historyHint{}
+	}
+	return true
+}
+
+// recordHistoryStatus notes the exit status of the command entry was added
+// to the history for, once it has finished
+func (s *Shell) recordHistoryStatus(entry HistoryEntry, status int) {
+	// Other entries may have been merged in or removed while it ran
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i].Command == entry.Command && s.history[i].Time.Equal(entry.Time) {
+			s.history[i].Status = &status
+			return
+		}
+	}
+}
+
+// histControl reports whether HISTCONTROL, a colon-separated list of
+// settings as in bash, holds setting. The settings are:
+//
+//	ignorespace  leave out commands starting with a space, such as ones
+//	             holding a secret
+//	ignoredups   leave out commands already anywhere in the history
+//	ignoreboth   both of the above
+//	erasedups    remove earlier copies of a command as it is added, so it
+//	             is only kept as the latest; this wins over ignoredups
+func (s *Shell) histControl(setting string) bool {
+	for _, value := range strings.Split(s.env.Get("HISTCONTROL"), ":") {
+		if value == setting || (value == "ignoreboth" && (setting == "ignorespace" || setting == "ignoredups")) {
+			return true
+		}
+	}
+	return false
+}
+
+// inHistory reports whether a normalized command is in the history
+func (s *Shell) inHistory(command string) bool {
+	for _, entry := range s.history {
+		if entry.Command == command {
+			return true
+		}
+	}
+	return false
+}
+
+// eraseHistory removes the history entries for which drop reports true
+func (s *Shell) eraseHistory(drop func(HistoryEntry) bool) {
+	kept := s.history[:0]
+	for _, entry := range s.history {
+		if !drop(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	clear(s.history[len(kept):])
+	s.history = kept
+	s.lastHint = historyHint{}
+	s.historyStale = true
+}
+
+// historyPath returns the file the history is kept in between sessions:
+// HISTFILE, or ~/.goshell_history if it isn't set
+func (s *Shell) historyPath() string {
+	if path := s.env.Get("HISTFILE"); path != "" {
+		return path
+	}
+	return filepath.Join(s.homeDir(), defaultHistFile)
+}
+
+// historyLimit returns the number of entries HISTSIZE allows in memory or
+// HISTFILESIZE in the history file, or -1 if a negative value lifts the
+// limit. HISTFILESIZE defaults to HISTSIZE, and HISTSIZE to
+// defaultHistSize.
+func (s *Shell) historyLimit(name string) int {
+	value := s.env.Get(name)
+	if value == "" && name == "HISTFILESIZE" {
+		return s.historyLimit("HISTSIZE")
+	}
+	n, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		return defaultHistSize
+	case n < 0:
+		return -1
+	}
+	return n
+}
+
+// GetHistory returns the command history
+func (s *Shell) GetHistory() []string {
+	commands := make([]string, len(s.history))
+	for i, entry := range s.history {
+		commands[i] = entry.Command
+	}
+	return commands
+}
+
+// HistoryEntries returns the full history records, including raw text
+func (s *Shell) HistoryEntries() []HistoryEntry {
+	return s.history
+}
+
+// lastHistoryEntry returns the most recent history entry, which is what is
+// saved to the history file after a command is added
+func (s *Shell) lastHistoryEntry() HistoryEntry {
+	if len(s.history) == 0 {
+		return HistoryEntry{}
+	}
+	return s.history[len(s.history)-1]
+}
+
+// Suggest returns the rest of the most recent history entry that starts with
+// prefix, or "" if there is none
+func (s *Shell) Suggest(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	from := len(s.history) - 1
+	// Entries newer than the last match didn't start with the shorter prefix,
+	// so they can't start with this one either
+	if hint := s.lastHint; hint.size == len(s.history) && hint.prefix != "" && strings.HasPrefix(prefix, hint.prefix) {
+		from = hint.index
+	}
+	for i := from; i >= 0; i-- {
+		if text := s.history[i].Text(); len(text) > len(prefix) && strings.HasPrefix(text, prefix) {
+			s.lastHint = historyHint{prefix, i, len(s.history)}
+			return text[len(prefix):]
+		}
+	}
+	s.lastHint = historyHint{prefix, -1, len(s.history)}
+	return ""
+}
+
+// historyReport describes what checkHistoryFile found in a history file
+type historyReport struct {
+	entries   int    // valid entries
+	damaged   int    // entries dropped because they weren't valid text
+	truncated bool   // the file ended partway through an entry
+	backup    string // copy of the damaged file, if it was repaired
+}
+
+// ok reports whether the file needed no repair
+func (r historyReport) ok() bool {
+	return r.damaged == 0 && !r.truncated
+}
+
+// String summarizes the report for the user
+func (r historyReport) String() string {
+	if r.ok() {
+		return fmt.Sprintf("%d entries, no problems found", r.entries)
+	}
+	var problems []string
+	if r.damaged > 0 {
+		problems = append(problems, fmt.Sprintf("%d damaged entries", r.damaged))
+	}
+	if r.truncated {
+		problems = append(problems, "a truncated last entry")
+	}
+	text := fmt.Sprintf("%d entries, %s", r.entries, strings.Join(problems, " and "))
+	if r.backup != "" {
+		text += "; dropped them and saved the original as " + r.backup
+	}
+	return text
+}
+
+// checkHistoryFile verifies that a history file holds one valid entry per
+// line. Entries with invalid UTF-8 or control characters are damaged, as is
+// a final line without a newline, which was cut off while being written and
+// would otherwise run into the next entry appended. With repair set, a
+// damaged file is copied to a timestamped backup and rewritten in place
+// with only the valid entries. A missing file is not an error.
+func checkHistoryFile(path string, repair bool) (historyReport, error) {
+	var report historyReport
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return report, nil
+	} else if err != nil {
+		return report, err
+	}
+	defer f.Close()
+	// Other sessions wait to append until the file has been repaired
+	if err := lockFile(f, true); err != nil {
+		return report, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return report, err
+	}
+
+	var kept bytes.Buffer
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if line[len(line)-1] != '\n' {
+			report.truncated = true
+			continue
+		}
+		if !validHistoryEntry(line[:len(line)-1]) {
+			report.damaged++
+			continue
+		}
+		text := string(line[:len(line)-1])
+		if _, stamp := parseTimestamp(text); !stamp && trailingBackslashes(text)%2 == 0 && len(bytes.TrimSpace(line)) > 0 {
+			report.entries++
+		}
+		kept.Write(line)
+	}
+	if report.ok() || !repair {
+		return report, nil
+	}
+
+	report.backup = fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(report.backup, data, 0600); err != nil {
+		report.backup = ""
+		return report, err
+	}
+	// Rewrite in place rather than renaming, so other sessions appending to
+	// the file keep doing so
+	if err := f.Truncate(0); err != nil {
+		return report, err
+	}
+	_, err = f.WriteAt(kept.Bytes(), 0)
+	return report, err
+}
+
+// History files hold an entry per line. An entry may be preceded by a
+// comment holding the Unix time the command ran, as bash writes them, so
+// files from before timestamps were kept, or from bash, read the same. A
+// newline within an entry, in a quoted string, is written as a backslash
+// ending the line, as zsh does. The backslashes before it are doubled, so
+// a line ends in an odd number of backslashes only where the entry goes on,
+// which no complete command would otherwise do.
+
+// parseTimestamp returns the time in a history file's timestamp line,
+// reporting false if the line isn't one
+func parseTimestamp(line string) (time.Time, bool) {
+	digits, ok := strings.CutPrefix(line, "#")
+	if !ok || digits == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// parseHistory returns the entries in the text of a history file, oldest
+// first
+func parseHistory(data []byte) []HistoryEntry {
+	var entries []HistoryEntry
+	var when time.Time
+	var continued []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if n := trailingBackslashes(line); n%2 == 1 {
+			continued = append(continued, line[:len(line)-n+n/2])
+			continue
+		}
+		if continued != nil {
+			line = strings.Join(append(continued, line), "\n")
+			continued = nil
+		} else if t, ok := parseTimestamp(line); ok {
+			when = t
+			continue
+		}
+		// Lines that are only a comment aren't commands
+		if entry := savedEntry(line); entry.Command != "" {
+			entry.Time = when
+			entries = append(entries, entry)
+		}
+		when = time.Time{}
+	}
+	return entries
+}
+
+// savedEntry returns the history entry for text saved by the shell, which
+// is the text to recall: raw if it was kept
+func savedEntry(text string) HistoryEntry {
+	entry := HistoryEntry{Command: parser.NormalizeCommand(text)}
+	if entry.Command != text {
+		entry.Raw = text
+	}
+	return entry
+}
+
+// historyFileText returns entries as they are written to a history file
+func historyFileText(entries []HistoryEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		if !entry.Time.IsZero() {
+			fmt.Fprintf(&b, "#%d\n", entry.Time.Unix())
+		}
+		lines := strings.Split(entry.Text(), "\n")
+		for i, line := range lines[:len(lines)-1] {
+			lines[i] = line + strings.Repeat(`\`, trailingBackslashes(line)+1)
+		}
+		b.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// trailingBackslashes returns the number of backslashes line ends in
+func trailingBackslashes(line string) int {
+	return len(line) - len(strings.TrimRight(line, `\`))
+}
+
+// strftimeVerbs maps the strftime conversions HISTTIMEFORMAT may use to Go
+// time layouts
+var strftimeVerbs = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'H': "15",
+	'I': "03", 'M': "04", 'S': "05", 'p': "PM", 'b': "Jan", 'h': "Jan",
+	'B': "January", 'a': "Mon", 'A': "Monday", 'Z': "MST", 'z': "-0700",
+	'F': "2006-01-02", 'T': "15:04:05", 'D': "01/02/06", 'R': "15:04",
+}
+
+// formatHistoryTime formats t with a strftime format such as HISTTIMEFORMAT
+func formatHistoryTime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch verb := format[i]; {
+		case verb == '%':
+			b.WriteByte('%')
+		case verb == 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case verb == 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case strftimeVerbs[verb] != "":
+			b.WriteString(t.Format(strftimeVerbs[verb]))
+		default:
+			b.WriteString(format[i-1 : i+1])
+		}
+	}
+	return b.String()
+}
+
+// validHistoryEntry reports whether a line is plausible command text
+func validHistoryEntry(line []byte) bool {
+	if !utf8.Valid(line) {
+		return false
+	}
+	for _, r := range string(line) {
+		if unicode.IsControl(r) && r != '\t' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
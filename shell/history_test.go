@@ -0,0 +1,320 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryNormalization(t *testing.T) {
+	t.Run("Normalized Duplicates", func(t *testing.T) {
+		shell := NewShell()
+		shell.AddToHistory("ls  -la")
+		if shell.AddToHistory("ls -la ;") {
+			t.Errorf("AddToHistory() added a normalized duplicate")
+		}
+		history := shell.GetHistory()
+		if len(history) != 1 || history[0] != "ls -la" {
+			t.Errorf("History = %v, want [ls -la]", history)
+		}
+	})
+
+	t.Run("Keep Raw Text", func(t *testing.T) {
+		shell := NewShell()
+		shell.env.Set("HISTKEEPRAW", "1")
+		shell.AddToHistory("echo   hi;")
+		entries := shell.HistoryEntries()
+		if entries[0].Command != "echo hi" || entries[0].Raw != "echo   hi;" {
+			t.Errorf("Entry = %+v, want normalized and raw text", entries[0])
+		}
+		if got := shell.lastHistoryEntry().Text(); got != "echo   hi;" {
+			t.Errorf("lastHistoryEntry().Text() = %q, want raw text", got)
+		}
+	})
+}
+
+func TestHistControl(t *testing.T) {
+	add := func(shell *Shell, cmds ...string) string {
+		for _, cmd := range cmds {
+			shell.AddToHistory(cmd)
+		}
+		return strings.Join(shell.GetHistory(), ",")
+	}
+
+	shell := NewShell()
+	if got := add(shell, "ls", " secret", "pwd", "ls"); got != "ls,secret,pwd,ls" {
+		t.Errorf("history without HISTCONTROL = %q", got)
+	}
+	shell = NewShell()
+	shell.env.Set("HISTCONTROL", "ignorespace")
+	if got := add(shell, "ls", " export TOKEN=x", "pwd"); got != "ls,pwd" {
+		t.Errorf("history with ignorespace = %q", got)
+	}
+	shell = NewShell()
+	shell.env.Set("HISTCONTROL", "ignoredups")
+	if got := add(shell, "ls", "pwd", "ls  ;", " date"); got != "ls,pwd,date" {
+		t.Errorf("history with ignoredups = %q", got)
+	}
+	shell = NewShell()
+	shell.env.Set("HISTCONTROL", "ignoreboth")
+	if got := add(shell, "ls", "pwd", "ls", " date"); got != "ls,pwd" {
+		t.Errorf("history with ignoreboth = %q", got)
+	}
+	shell = NewShell()
+	shell.env.Set("HISTCONTROL", "ignoredups:erasedups")
+	if got := add(shell, "ls", "pwd", "date", "ls"); got != "pwd,date,ls" || !shell.historyStale {
+		t.Errorf("history with erasedups = %q", got)
+	}
+
+	// Erased duplicates go from the history file too
+	path := filepath.Join(t.TempDir(), "history")
+	shell = NewShell()
+	shell.env.Set("HISTFILE", path)
+	shell.env.Set("HISTCONTROL", "erasedups")
+	for _, cmd := range []string{"ls", "pwd", "ls"} {
+		shell.AddToHistory(cmd)
+		shell.saveHistory()
+	}
+	if entries, _ := (&historyFile{path: path}).load(-1); historyTexts(entries) != "pwd,ls" {
+		t.Errorf("file with erasedups holds %q", historyTexts(entries))
+	}
+}
+
+func TestHistoryEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	shell := NewShell()
+	shell.env.Set("HISTFILE", path)
+	for _, cmd := range []string{"git status", "ls", "git log", "pwd"} {
+		shell.AddToHistory(cmd)
+		shell.saveHistory()
+	}
+	fileTexts := func() string {
+		entries, _ := (&historyFile{path: path}).load(-1)
+		return historyTexts(entries)
+	}
+
+	out, status := runCapture(t, shell, "history search '^git (s|l)'")
+	if status != 0 || out != "1  git status\n3  git log\n" {
+		t.Errorf("history search = %q, %d", out, status)
+	}
+	if _, status := runCapture(t, shell, "history search nothing"); status != 1 {
+		t.Errorf("history search without a match = %d, want 1", status)
+	}
+	if _, status := runCapture(t, shell, "history search '('"); status != 1 {
+		t.Errorf("history search with a bad pattern = %d, want 1", status)
+	}
+	if got := highlightMatches(regexp.MustCompile("o*g"), "git log"); got != Bold+Red+"g"+Reset+"it l"+Bold+Red+"og"+Reset {
+		t.Errorf("highlightMatches() = %q", got)
+	}
+
+	if _, status := runCapture(t, shell, "history -d 2"); status != 0 {
+		t.Errorf("history -d 2 = %d", status)
+	}
+	if _, status := runCapture(t, shell, "history -d -1"); status != 0 {
+		t.Errorf("history -d -1 = %d", status)
+	}
+	if got := strings.Join(shell.GetHistory(), ","); got != "git status,git log" || !shell.historyStale {
+		t.Errorf("history after -d = %q", got)
+	}
+	if got := fileTexts(); got != "git status,git log" {
+		t.Errorf("file after -d holds %q", got)
+	}
+	if _, status := runCapture(t, shell, "history -d 5"); status != 1 {
+		t.Errorf("history -d past the end = %d, want 1", status)
+	}
+
+	if _, status := runCapture(t, shell, "history -c"); status != 0 || len(shell.history) != 0 || fileTexts() != "" {
+		t.Errorf("history -c = %d, leaving %q and a file with %q", status, shell.GetHistory(), fileTexts())
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	shell := NewShell()
+	shell.AddToHistory("git status")
+	shell.AddToHistory("go test ./...")
+	shell.AddToHistory("git commit")
+
+	tests := []struct{ prefix, want string }{
+		{"g", "it commit"},
+		{"gi", "t commit"},
+		{"git s", "tatus"},
+		{"go", " test ./..."},
+		{"git commit", ""},
+		{"x", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := shell.Suggest(tt.prefix); got != tt.want {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+
+	// A new entry takes over as the most recent match
+	shell.AddToHistory("git stash")
+	if got := shell.Suggest("git st"); got != "ash" {
+		t.Errorf("Suggest(git st) = %q, want %q", got, "ash")
+	}
+}
+
+func TestCheckHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if report, err := checkHistoryFile(path, true); err != nil || !report.ok() {
+		t.Errorf("missing file: %v, %v", report, err)
+	}
+
+	os.WriteFile(path, []byte("ls -la\necho \xff\xfe\ngit st\x00atus\npwd\n\ncd /tm"), 0600)
+	report, err := checkHistoryFile(path, false)
+	if err != nil || report.entries != 2 || report.damaged != 2 || !report.truncated || report.backup != "" {
+		t.Fatalf("check = %+v, %v", report, err)
+	}
+
+	report, err = checkHistoryFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "ls -la\npwd\n\n" {
+		t.Errorf("repaired file = %q", data)
+	}
+	if data, _ := os.ReadFile(report.backup); !strings.HasSuffix(string(data), "cd /tm") {
+		t.Errorf("backup %s = %q, want the original", report.backup, data)
+	}
+	if report, _ := checkHistoryFile(path, true); !report.ok() || report.entries != 2 {
+		t.Errorf("after repair = %+v", report)
+	}
+}
+
+func TestHistorySettings(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("HOME", "/home/user")
+	if got := shell.historyPath(); got != "/home/user/.goshell_history" {
+		t.Errorf("default history file = %q", got)
+	}
+	shell.env.Set("HISTFILE", "/data/hist")
+	if got := shell.historyPath(); got != "/data/hist" {
+		t.Errorf("history file with HISTFILE = %q", got)
+	}
+
+	if got := shell.historyLimit("HISTFILESIZE"); got != defaultHistSize {
+		t.Errorf("default HISTFILESIZE = %d", got)
+	}
+	shell.env.Set("HISTSIZE", "3")
+	if got := shell.historyLimit("HISTFILESIZE"); got != 3 {
+		t.Errorf("HISTFILESIZE = %d, want HISTSIZE's 3", got)
+	}
+	for _, cmd := range []string{"one", "two", "three", "four"} {
+		shell.AddToHistory(cmd)
+	}
+	if got := strings.Join(shell.GetHistory(), " "); got != "two three four" {
+		t.Errorf("history with HISTSIZE=3 = %q", got)
+	}
+	shell.env.Set("HISTSIZE", "-1")
+	shell.AddToHistory("five")
+	if n := len(shell.history); n != 4 {
+		t.Errorf("history with HISTSIZE=-1 has %d entries, want 4", n)
+	}
+	shell.env.Set("HISTSIZE", "0")
+	if shell.AddToHistory("six") {
+		t.Error("HISTSIZE=0 still added an entry")
+	}
+}
+
+func TestHistoryFileLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	file := &historyFile{path: path}
+	for _, text := range []string{"one", "two", "three", "four"} {
+		if _, err := file.append(HistoryEntry{Command: text}, 3, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "two\nthree\nfour\n" {
+		t.Errorf("file = %q, want the last 3 entries", data)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	entries, err := file.load(2)
+	if err != nil || len(entries) != 2 || entries[0].Command != "three" || entries[1].Command != "four" {
+		t.Errorf("load() = %+v, %v", entries, err)
+	}
+	file.append(HistoryEntry{Command: "five"}, -1, false)
+	if entries, _ := file.load(-1); len(entries) != 4 {
+		t.Errorf("unlimited file has %d entries, want 4", len(entries))
+	}
+}
+
+func TestHistoryTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	when := time.Date(2024, 3, 9, 14, 5, 7, 0, time.Local)
+	file := &historyFile{path: path}
+	os.WriteFile(path, []byte("old\n"), 0600)
+	for i, text := range []string{"ls", "pwd"} {
+		entry := HistoryEntry{Command: text, Time: when.Add(time.Duration(i) * time.Minute)}
+		if _, err := file.append(entry, 2, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	want := fmt.Sprintf("#%d\nls\n#%d\npwd\n", when.Unix(), when.Unix()+60)
+	if string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+
+	// Files from before timestamps were kept load as they did
+	os.WriteFile(path, []byte(fmt.Sprintf("old\n#%d\nls\n#note\n", when.Unix())), 0600)
+	entries, err := file.load(-1)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("load() = %+v, %v", entries, err)
+	}
+	if !entries[0].Time.IsZero() || !entries[1].Time.Equal(when) {
+		t.Errorf("load() = %+v", entries)
+	}
+
+	shell := NewShell()
+	shell.history = entries
+	if out, _ := runCapture(t, shell, "history -t"); !strings.Contains(out, "2  2024-03-09 14:05:07  ls") ||
+		!strings.Contains(out, "1                       old") {
+		t.Errorf("history -t = %q", out)
+	}
+	shell.env.Set("HISTTIMEFORMAT", "%d/%m/%y %I:%M%p %% ")
+	if out, _ := runCapture(t, shell, "history"); !strings.Contains(out, "2  09/03/24 02:05PM % ls") {
+		t.Errorf("history with HISTTIMEFORMAT = %q", out)
+	}
+}
+
+func TestHistoryFileNewlines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	file := &historyFile{path: path}
+	when := time.Date(2024, 3, 9, 14, 5, 7, 0, time.Local)
+	texts := []string{
+		"echo 'one\ntwo'",
+		"echo \"ends in \\\\\nnext\"",
+		"echo 'a\\\n\\b\n\n'",
+		"echo \\\\",
+		"pwd",
+	}
+	for i, text := range texts {
+		entry := HistoryEntry{Command: text, Time: when.Add(time.Duration(i) * time.Minute)}
+		if _, err := file.append(entry, -1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := file.load(-1)
+	if err != nil || len(entries) != len(texts) {
+		t.Fatalf("load() = %+v, %v", entries, err)
+	}
+	for i, entry := range entries {
+		if entry.Text() != texts[i] || !entry.Time.Equal(when.Add(time.Duration(i)*time.Minute)) {
+			t.Errorf("entry %d = %q at %v, want %q", i, entry.Text(), entry.Time, texts[i])
+		}
+	}
+	if report, err := checkHistoryFile(path, false); err != nil || !report.ok() || report.entries != len(texts) {
+		t.Errorf("checkHistoryFile = %+v, %v, want %d good entries", report, err, len(texts))
+	}
+}
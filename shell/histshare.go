@@ -0,0 +1,293 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Several sessions can keep their history in the same file. Each appends a
+// command as soon as it runs, under a lock, so none writes over another's.
+// With the sharehistory option on, a session also picks up the commands the
+// others have appended, before each prompt and whenever it saves one.
+
+// historyFile is a session's view of its history file
+type historyFile struct {
+	path string
+	seen int    // the length of the file when it was last read
+	tail []byte // the end of the file then, to find the place again after another session trims it
+}
+
+// load returns the last limit entries of the file, or all of them if limit
+// is negative, oldest first. A missing file holds no entries.
+func (h *historyFile) load(limit int) ([]HistoryEntry, error) {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	h.seen, h.tail = 0, nil
+	entries := h.readNew(data)
+	if limit >= 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// sync returns the entries other sessions have appended since the file was
+// last read
+func (h *historyFile) sync() ([]HistoryEntry, error) {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return h.readNew(data), nil
+}
+
+// append adds an entry to the end of the file, creating it readable only by
+// its owner, then trims the file to its last limit entries unless limit is
+// negative. With erase set, earlier entries for the same command are
+// removed. It returns the entries other sessions had appended since the
+// file was last read, which come before this one.
+func (h *historyFile) append(entry HistoryEntry, limit int, erase bool) ([]HistoryEntry, error) {
+	f, err := os.OpenFile(h.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, true); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	others := h.readNew(data)
+
+	text := historyFileText([]HistoryEntry{entry})
+	if _, err := f.WriteString(text); err != nil {
+		return others, err
+	}
+	data = append(data, text...)
+	entries := parseHistory(data)
+	kept := entries
+	if erase && len(entries) > 0 {
+		kept = slices.DeleteFunc(entries[:len(entries)-1:len(entries)-1], func(old HistoryEntry) bool {
+			return old.Command == entry.Command
+		})
+		kept = append(kept, entries[len(entries)-1])
+	}
+	if limit >= 0 && len(kept) > limit {
+		kept = kept[len(kept)-limit:]
+	}
+	if len(kept) < len(entries) {
+		data = []byte(historyFileText(kept))
+		if err := f.Truncate(0); err != nil {
+			return others, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return others, err
+		}
+	}
+	h.seen, h.tail = len(data), historyTail(data)
+	return others, nil
+}
+
+// rewrite replaces the file's entries with those edit returns for them. It
+// returns the entries other sessions had appended since the file was last
+// read, which edit sees as well.
+func (h *historyFile) rewrite(edit func([]HistoryEntry) []HistoryEntry) ([]HistoryEntry, error) {
+	f, err := os.OpenFile(h.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, true); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	others := h.readNew(data)
+
+	data = []byte(historyFileText(edit(parseHistory(data))))
+	if err := f.Truncate(0); err != nil {
+		return others, err
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return others, err
+	}
+	h.seen, h.tail = len(data), historyTail(data)
+	return others, nil
+}
+
+// readNew returns the entries in data, the whole file, that come after what
+// was seen when it was last read, and marks all of it as seen. If another
+// session has trimmed the file since, what was seen is found by its tail;
+// if that has been trimmed away too there is no telling what is new.
+func (h *historyFile) readNew(data []byte) []HistoryEntry {
+	start := len(data)
+	switch {
+	case h.seen <= len(data) && bytes.HasSuffix(data[:h.seen], h.tail):
+		start = h.seen
+	case len(h.tail) > 0:
+		if i := bytes.LastIndex(data, h.tail); i >= 0 {
+			start = i + len(h.tail)
+		}
+	}
+	h.seen, h.tail = len(data), historyTail(data)
+	return parseHistory(data[start:])
+}
+
+// historyTail returns the last two lines of a history file's text: the last
+// entry and its timestamp, or the entry before it, which together are very
+// likely not to appear anywhere else in the file
+func historyTail(data []byte) []byte {
+	i := bytes.LastIndexByte(data[:max(len(data)-1, 0)], '\n')
+	if i >= 0 {
+		i = bytes.LastIndexByte(data[:i], '\n')
+	}
+	return bytes.Clone(data[i+1:])
+}
+
+// historyFile returns the shell's history file, starting afresh if HISTFILE
+// now names another one
+func (s *Shell) historyFile() *historyFile {
+	if path := s.historyPath(); s.histFile == nil || s.histFile.path != path {
+		s.histFile = &historyFile{path: path}
+	}
+	return s.histFile
+}
+
+// loadHistory reads the history file into the history, returning the
+// entries read
+func (s *Shell) loadHistory() ([]HistoryEntry, error) {
+	entries, err := s.historyFile().load(s.historyLimit("HISTSIZE"))
+	if err != nil {
+		return nil, err
+	}
+	s.history = entries
+	s.lastHint = historyHint{}
+	return entries, nil
+}
+
+// saveHistory appends the history's last entry to the history file, with
+// any secrets in it redacted. With sharehistory on, the entries other
+// sessions have saved since the file was last read are merged in before it.
+// It returns the entries saved and merged, in the order they now appear in
+// the history. The file holds a line per entry, so commands with a line
+// break inside quotes are only kept for this session.
+func (s *Shell) saveHistory() ([]HistoryEntry, error) {
+	entry := s.lastHistoryEntry()
+	if strings.Contains(entry.Text(), "\n") {
+		return []HistoryEntry{entry}, nil
+	}
+	saved, err := s.redactEntry(entry)
+	if err != nil {
+		return []HistoryEntry{entry}, err
+	}
+	others, err := s.historyFile().append(saved, s.historyLimit("HISTFILESIZE"), s.histControl("erasedups"))
+	if !s.options["sharehistory"] || len(others) == 0 {
+		return []HistoryEntry{entry}, err
+	}
+	merged := append(others, entry)
+	s.history = s.history[:len(s.history)-1]
+	s.mergeHistory(merged)
+	return merged, err
+}
+
+// syncHistory merges the entries other sessions have saved since the
+// history file was last read into the history when sharehistory is on,
+// returning them
+func (s *Shell) syncHistory() ([]HistoryEntry, error) {
+	if !s.options["sharehistory"] {
+		return nil, nil
+	}
+	others, err := s.historyFile().sync()
+	if err != nil {
+		return nil, err
+	}
+	s.mergeHistory(others)
+	return others, nil
+}
+
+// mergeHistory adds entries to the end of the history, keeping it within
+// HISTSIZE
+func (s *Shell) mergeHistory(entries []HistoryEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	s.history = append(s.history, entries...)
+	if limit := s.historyLimit("HISTSIZE"); limit >= 0 && len(s.history) > limit {
+		s.history = s.history[len(s.history)-limit:]
+	}
+	s.lastHint = historyHint{}
+}
+
+// deleteHistory removes the history entry at position n as the history
+// builtin numbers them, or the -n'th last if n is negative, from the
+// history file as well
+func (s *Shell) deleteHistory(n int) error {
+	i := n - 1
+	if n < 0 {
+		i = len(s.history) + n
+	}
+	if n == 0 || i < 0 || i >= len(s.history) {
+		return fmt.Errorf("%d: history position out of range", n)
+	}
+	entry := s.history[i]
+	if saved, err := s.redactEntry(entry); err == nil {
+		entry = saved
+	}
+	s.history = slices.Delete(s.history, i, i+1)
+	s.lastHint = historyHint{}
+	s.historyStale = true
+
+	// The file may hold other sessions' entries too, so the entry is found
+	// by its text and time, which the file keeps to the second; the latest
+	// is taken to be this one
+	others, err := s.historyFile().rewrite(func(entries []HistoryEntry) []HistoryEntry {
+		for j := len(entries) - 1; j >= 0; j-- {
+			if entries[j].Text() == entry.Text() && entries[j].Time.Unix() == entry.Time.Unix() {
+				return slices.Delete(entries, j, j+1)
+			}
+		}
+		return entries
+	})
+	if s.options["sharehistory"] {
+		s.mergeHistory(others)
+	}
+	return err
+}
+
+// clearHistory removes every entry from the history and the history file
+func (s *Shell) clearHistory() error {
+	s.history = nil
+	s.lastHint = historyHint{}
+	s.historyStale = true
+	_, err := s.historyFile().rewrite(func([]HistoryEntry) []HistoryEntry { return nil })
+	return err
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// historyTexts returns the text of each entry, joined by commas
+func historyTexts(entries []HistoryEntry) string {
+	texts := make([]string, len(entries))
+	for i, entry := range entries {
+		texts[i] = entry.Text()
+	}
+	return strings.Join(texts, ",")
+}
+
+func TestSharedHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	session := func() *Shell {
+		shell := NewShell()
+		shell.env.Set("HISTFILE", path)
+		shell.setOption("sharehistory", true)
+		shell.loadHistory()
+		return shell
+	}
+	run := func(shell *Shell, cmd string) string {
+		shell.AddToHistory(cmd)
+		saved, err := shell.saveHistory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return historyTexts(saved)
+	}
+	a, b := session(), session()
+
+	// Neither overwrites what the other saved
+	run(a, "one")
+	if got := run(b, "two"); got != "one,two" {
+		t.Errorf("saveHistory() = %q, want the other session's entry first", got)
+	}
+	run(a, "three")
+	if entries, _ := (&historyFile{path: path}).load(-1); historyTexts(entries) != "one,two,three" {
+		t.Errorf("file holds %q", historyTexts(entries))
+	}
+	if got := historyTexts(a.history); got != "one,two,three" {
+		t.Errorf("first session's history = %q", got)
+	}
+	if merged, _ := b.syncHistory(); historyTexts(merged) != "three" {
+		t.Errorf("syncHistory() = %q, want three", historyTexts(merged))
+	}
+	if merged, _ := b.syncHistory(); len(merged) != 0 {
+		t.Errorf("syncHistory() again = %q, want nothing new", historyTexts(merged))
+	}
+
+	// Trimming the file by another session doesn't lose the place
+	b.env.Set("HISTFILESIZE", "3")
+	run(b, "four")
+	if merged, _ := a.syncHistory(); historyTexts(merged) != "four" {
+		t.Errorf("syncHistory() after trimming = %q, want four", historyTexts(merged))
+	}
+
+	// Without sharehistory, sessions keep to their own commands
+	a.setOption("sharehistory", false)
+	run(b, "five")
+	if got := run(a, "six"); got != "six" {
+		t.Errorf("saveHistory() without sharehistory = %q", got)
+	}
+	if merged, _ := a.syncHistory(); len(merged) != 0 {
+		t.Errorf("syncHistory() without sharehistory = %q", historyTexts(merged))
+	}
+}
@@ -0,0 +1,242 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("http", "http [-iLq] [-H 'NAME: VALUE']... [-d DATA|@FILE] [-t TIMEOUT] [METHOD] URL",
+		"Send an HTTP request and show the response, with JSON pretty-printed", builtinHTTP)
+	registerFlags("http",
+		Candidate{"-H", "Add a request header, as 'Name: value'"},
+		Candidate{"-d", "Send a body: the text given, @FILE or @- for stdin"},
+		Candidate{"-L", "Follow redirects"},
+		Candidate{"-t", "Give up after this long: 30s, 2m"},
+		Candidate{"-i", "Show the response headers even when piped"},
+		Candidate{"-q", "Show only the body, even on a terminal"})
+}
+
+// defaultHTTPTimeout is how long http waits for a response unless -t says
+const defaultHTTPTimeout = 30 * time.Second
+
+// httpMethods are the methods http takes as its first operand
+var httpMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// httpRequest is a request as the http builtin's arguments describe it
+type httpRequest struct {
+	method     string
+	url        string
+	headers    http.Header
+	body       []byte
+	hasBody    bool
+	follow     bool
+	timeout    time.Duration
+	headersToo bool // show the status and headers when piped as well
+	quiet      bool // show only the body, even on a terminal
+}
+
+// builtinHTTP sends a request with net/http, as a lightweight curl for
+// checking APIs: http GET https://api.example.com -H 'Auth: x' -d
+// @body.json. The method is GET, or POST if there is a body, unless one is
+// given. On a terminal the status line and headers are shown colored before
+// the body, and JSON bodies are pretty-printed; piped, the body alone goes
+// out as it came, so it can be passed on to json. A response with a status
+// of 400 or more makes the status 1, so that scripts can check requests
+// with &&.
+func builtinHTTP(s *Shell, args []string, stdio Stdio) int {
+	req := httpRequest{headers: make(http.Header), timeout: defaultHTTPTimeout}
+	flags := newFlagSet("http")
+	flags.Func(func(value string) error {
+		name, v, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid header '%s': want 'Name: value'", value)
+		}
+		req.headers.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+		return nil
+	}, "H")
+	flags.Func(func(value string) error {
+		body, err := httpBody(value, stdio.Stdin)
+		req.body, req.hasBody = append(req.body, body...), true
+		return err
+	}, "d")
+	flags.Func(func(value string) (err error) {
+		req.timeout, err = parseTimeout(value)
+		return err
+	}, "t")
+	flags.Bool(&req.follow, "L")
+	flags.Bool(&req.headersToo, "i")
+	flags.Bool(&req.quiet, "q")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) == 2 && httpMethods[strings.ToUpper(operands[0])] {
+		req.method, operands = strings.ToUpper(operands[0]), operands[1:]
+	}
+	if len(operands) != 1 {
+		return flags.usage(stdio)
+	}
+	req.url = operands[0]
+	return s.sendHTTP(req, stdio)
+}
+
+// httpBody returns the body -d gives: the text itself, or the contents of
+// the file named after an @, - meaning stdin
+func httpBody(value string, stdin io.Reader) ([]byte, error) {
+	name, ok := strings.CutPrefix(value, "@")
+	switch {
+	case !ok:
+		return []byte(value), nil
+	case name == "-":
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// sendHTTP sends a request and shows its response. Ctrl-C cancels it.
+func (s *Shell) sendHTTP(req httpRequest, stdio Stdio) int {
+	if !strings.Contains(req.url, "://") {
+		req.url = "http://" + req.url
+	}
+	if req.method == "" {
+		req.method = "GET"
+		if req.hasBody {
+			req.method = "POST"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(s.context(), req.timeout)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	var body io.Reader
+	if req.hasBody {
+		body = bytes.NewReader(req.body)
+	}
+	request, err := http.NewRequestWithContext(ctx, req.method, req.url, body)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "http:", err)
+		return 1
+	}
+	request.Header = req.headers
+	if req.hasBody && request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if json.Valid(req.body) {
+			request.Header.Set("Content-Type", "application/json")
+		}
+	}
+	client := &http.Client{}
+	if !req.follow {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			fmt.Fprintf(stdio.Stderr, "http: no response after %v\n", req.timeout)
+		case errors.Is(err, context.Canceled):
+			return 130
+		default:
+			fmt.Fprintln(stdio.Stderr, "http:", err)
+		}
+		return 1
+	}
+	defer response.Body.Close()
+
+	terminal := isTerminal(stdio.Stdout)
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	if (terminal || req.headersToo) && !req.quiet {
+		printHTTPHeaders(out, response, terminal)
+	}
+	if err := printHTTPBody(out, response, terminal); err != nil {
+		out.Flush()
+		if errors.Is(err, context.Canceled) {
+			return 130
+		}
+		fmt.Fprintln(stdio.Stderr, "http:", err)
+		return 1
+	}
+	if response.StatusCode >= 400 {
+		return 1
+	}
+	return 0
+}
+
+// printHTTPHeaders writes the status line and the headers of a response,
+// sorted by name, and a blank line after them
+func printHTTPHeaders(w io.Writer, response *http.Response, color bool) {
+	style := func(color, text string) string { return color + text + Reset }
+	if !color {
+		style = func(_, text string) string { return text }
+	}
+	statusColor := Green
+	switch {
+	case response.StatusCode >= 500:
+		statusColor = Red
+	case response.StatusCode >= 400:
+		statusColor = Yellow
+	case response.StatusCode >= 300:
+		statusColor = Cyan
+	}
+	fmt.Fprintf(w, "%s %s\n", response.Proto, style(Bold+statusColor, response.Status))
+	names := make([]string, 0, len(response.Header))
+	for name := range response.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range response.Header[name] {
+			fmt.Fprintf(w, "%s: %s\n", style(Cyan, name), value)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// printHTTPBody writes the body of a response. On a terminal a JSON body is
+// pretty-printed and colored, as json shows it; otherwise it goes out as it
+// came.
+func printHTTPBody(w *bufio.Writer, response *http.Response, terminal bool) error {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if !terminal || !isJSON {
+		_, err := io.Copy(w, response.Body)
+		return err
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeJSON(dec)
+	if err != nil {
+		// Not JSON after all, so shown as it is
+		_, err := w.Write(data)
+		return err
+	}
+	(&jsonPrinter{out: w, color: true}).print(value)
+	return nil
+}
@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			fmt.Fprintf(w, "%s %s auth=%s type=%s body=%s\n", r.Method, r.URL.Path, r.Header.Get("Auth"), r.Header.Get("Content-Type"), body)
+		case "/moved":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/slow":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	body := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(body, []byte(`{"a": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	for _, tt := range []struct {
+		args string
+		want string
+	}{
+		{"URL/echo -H 'Auth: x'", "GET /echo auth=x type= body=\n"},
+		{"-d name=a URL/echo", "POST /echo auth= type=application/x-www-form-urlencoded body=name=a\n"},
+		{"put URL/echo -d @" + body, `PUT /echo auth= type=application/json body={"a": 1}` + "\n"},
+		{"-L URL/moved", "GET /echo auth= type= body=\n"},
+		{"URL/moved", "<a href=\"/echo\">Found</a>.\n\n"},
+	} {
+		args := strings.ReplaceAll(tt.args, "URL", server.URL)
+		out, status := runCapture(t, shell, "http "+args)
+		if out != tt.want || status != 0 {
+			t.Errorf("http %s = %q (status %d), want %q", tt.args, out, status, tt.want)
+		}
+	}
+
+	// The host alone means http://
+	out, status := runCapture(t, shell, "http -i "+strings.TrimPrefix(server.URL, "http://")+"/echo")
+	if status != 0 || !strings.HasPrefix(out, "HTTP/1.1 200 OK\n") || !strings.Contains(out, "\nX-Method: GET\n\nGET /echo") {
+		t.Errorf("http -i = %q (status %d)", out, status)
+	}
+	if out, status := runCapture(t, shell, "http "+server.URL+"/missing"); status != 1 || !strings.Contains(out, "404") {
+		t.Errorf("http of a missing page = %q (status %d), want status 1", out, status)
+	}
+	if out, status := runCapture(t, shell, "http -t 0.1 "+server.URL+"/slow"); status != 1 || out != "http: no response after 100ms\n" {
+		t.Errorf("http -t 0.1 = %q (status %d)", out, status)
+	}
+	for _, args := range []string{"", "-H bad " + server.URL, "-t soon " + server.URL, "GET a b"} {
+		if _, status := runCapture(t, shell, "http "+args); status != 1 {
+			t.Errorf("http %s exited with %d, want 1", args, status)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package shell
+
+// ls and tree show an icon before each name, from the icon set
+// GOSHELL_ICONS names: emoji (the default), nerd for Nerd Font glyphs, ascii
+// for a letter for the file's type, or none. Emoji are wide in most
+// terminals but not all, which can throw off the columns; the other sets
+// are a single column wide.
+
+// iconSet is the icons ls and tree show for each kind of file, each with the
+// space that follows it
+type iconSet struct {
+	icons map[fileKind]string
+	width int // how many columns an icon and its space take
+}
+
+// icon returns the icon for a kind of file, falling back on the set's icon
+// for plain files
+func (set iconSet) icon(kind fileKind) string {
+	if icon, ok := set.icons[kind]; ok {
+		return icon
+	}
+	return set.icons[kindFile]
+}
+
+// iconSets are the icon sets GOSHELL_ICONS can name
+var iconSets = map[string]iconSet{
+	"emoji": {width: 3, icons: map[fileKind]string{
+		kindFile:         "📄 ",
+		kindDir:          "📁 ",
+		kindSymlink:      "🔗 ",
+		kindDevice:       "💽 ",
+		kindPipe:         "📊 ",
+		kindSocket:       "🔌 ",
+		kindExecutable:   "⚙️  ",
+		kindText:         "📄 ",
+		kindPDF:          "📕 ",
+		kindDocument:     "📘 ",
+		kindSpreadsheet:  "📗 ",
+		kindPresentation: "📙 ",
+		kindImage:        "🖼️  ",
+		kindAudio:        "🎵 ",
+		kindVideo:        "🎬 ",
+		kindArchive:      "📦 ",
+		kindGo:           "🔹 ",
+		kindPython:       "🐍 ",
+		kindJavaScript:   "🟨 ",
+		kindHTML:         "🌐 ",
+		kindCSS:          "🎨 ",
+		kindC:            "🔶 ",
+		kindJava:         "☕ ",
+		kindShell:        "💲 ",
+		kindRuby:         "💎 ",
+		kindConfig:       "🔧 ",
+	}},
+	"nerd": {width: 2, icons: map[fileKind]string{
+		kindFile:         "\uf15b ", // nf-fa-file
+		kindDir:          "\uf07b ", // nf-fa-folder
+		kindSymlink:      "\uf0c1 ", // nf-fa-link
+		kindDevice:       "\uf0a0 ", // nf-fa-hdd_o
+		kindPipe:         "\uf0ec ", // nf-fa-exchange
+		kindSocket:       "\uf1e6 ", // nf-fa-plug
+		kindExecutable:   "\uf013 ", // nf-fa-cog
+		kindText:         "\uf15c ", // nf-fa-file_text
+		kindPDF:          "\uf1c1 ", // nf-fa-file_pdf_o
+		kindDocument:     "\uf1c2 ", // nf-fa-file_word_o
+		kindSpreadsheet:  "\uf1c3 ", // nf-fa-file_excel_o
+		kindPresentation: "\uf1c4 ", // nf-fa-file_powerpoint_o
+		kindImage:        "\uf1c5 ", // nf-fa-file_image_o
+		kindAudio:        "\uf1c7 ", // nf-fa-file_audio_o
+		kindVideo:        "\uf1c8 ", // nf-fa-file_video_o
+		kindArchive:      "\uf1c6 ", // nf-fa-file_archive_o
+		kindGo:           "\ue627 ", // nf-seti-go
+		kindPython:       "\ue73c ", // nf-dev-python
+		kindJavaScript:   "\ue74e ", // nf-dev-javascript
+		kindHTML:         "\ue736 ", // nf-dev-html5
+		kindCSS:          "\ue749 ", // nf-dev-css3
+		kindC:            "\ue61e ", // nf-custom-c
+		kindJava:         "\ue738 ", // nf-dev-java
+		kindShell:        "\uf489 ", // nf-oct-terminal
+		kindRuby:         "\ue739 ", // nf-dev-ruby
+		kindConfig:       "\ue615 ", // nf-seti-config
+	}},
+	"ascii": {width: 2, icons: map[fileKind]string{
+		kindFile:       "- ",
+		kindDir:        "d ",
+		kindSymlink:    "l ",
+		kindDevice:     "b ",
+		kindPipe:       "p ",
+		kindSocket:     "s ",
+		kindExecutable: "x ",
+	}},
+	"none": {width: 0, icons: map[fileKind]string{}},
+}
+
+// iconSet returns the icon set GOSHELL_ICONS names, or if it names none the
+// emoji set, or no icons in POSIX mode
+func (s *Shell) iconSet() iconSet {
+	if set, ok := iconSets[s.env.Get("GOSHELL_ICONS")]; ok {
+		return set
+	}
+	if s.options["posix"] {
+		return iconSets["none"]
+	}
+	return iconSets["emoji"]
+}
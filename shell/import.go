@@ -0,0 +1,206 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("import", "import [-ny] FILE...", "Copy the aliases and exports of bash or zsh startup files into ~/.goshellrc", builtinImport)
+	registerFlags("import",
+		Candidate{"-n", "Show what would be added without adding it"},
+		Candidate{"-y", "Add it without asking"})
+}
+
+// importedLine is a line of a bash or zsh startup file that goshell can
+// run as it is, or one it can't and why
+type importedLine struct {
+	source string // FILE:LINE
+	text   string // the goshell command, or the line skipped
+	reason string // why the line was skipped, for one that was
+}
+
+// unsupportedExpansions describes what goshell can't expand, which keeps a
+// definition from being imported
+var unsupportedExpansions = []struct {
+	pattern *regexp.Regexp
+	reason  string
+}{
+	{regexp.MustCompile(`\$\(\(`), "arithmetic expansion isn't supported"},
+	{regexp.MustCompile("\\$\\(|`"), "command substitution isn't supported"},
+	{regexp.MustCompile(`\$\{[^}]*[^A-Za-z0-9_}]`), "parameter expansions other than ${NAME} aren't supported"},
+}
+
+// parseDotfile finds the alias and export commands of a bash or zsh startup
+// file. Each definition becomes a command of its own, with its value as it
+// was written, as goshell expands variables and quotes as bash does for
+// these. Definitions goshell can't run are returned as skipped. Anything
+// other than a line starting with alias or export, such as a conditional
+// definition, is passed over.
+func parseDotfile(name string, data string) (lines, skipped []importedLine) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimSpace(scanner.Text())
+		command, _, _ := strings.Cut(text, " ")
+		if command != "alias" && command != "export" {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", name, lineNo)
+		skip := func(reason string) {
+			skipped = append(skipped, importedLine{source: source, text: text, reason: reason})
+		}
+		tokens, err := parser.Tokenize(text)
+		if err != nil {
+			skip("it continues on the next line or isn't complete")
+			continue
+		}
+		if command == "alias" && len(tokens) > 1 && (tokens[1].Text == "-g" || tokens[1].Text == "-s") {
+			skip("global and suffix aliases aren't supported")
+			continue
+		}
+		for _, tok := range tokens[1:] {
+			if tok.Kind == parser.TokenOperator {
+				break
+			}
+			// Options are passed over, as are names without a value,
+			// which print an alias or export a variable already set
+			name, value, ok := strings.Cut(tok.Text, "=")
+			if strings.HasPrefix(tok.Text, "-") || !ok {
+				continue
+			}
+			if (command == "alias" && !isAliasName(name)) || (command == "export" && !isVariableName(name)) {
+				skip(fmt.Sprintf("%s isn't a name goshell accepts", name))
+				continue
+			}
+			reason := ""
+			for _, unsupported := range unsupportedExpansions {
+				if unsupported.pattern.MatchString(value) {
+					reason = unsupported.reason
+					break
+				}
+			}
+			if reason != "" {
+				skip(reason)
+				continue
+			}
+			lines = append(lines, importedLine{source: source, text: command + " " + tok.Text})
+		}
+	}
+	return lines, skipped
+}
+
+// isVariableName reports whether name can name a variable
+func isVariableName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isNameChar(name[i], i == 0) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// rcLines returns the lines of the startup file, to leave out definitions
+// it already has
+func (s *Shell) rcLines() map[string]bool {
+	data, _ := os.ReadFile(s.rcPath())
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+	return lines
+}
+
+// builtinImport carries the aliases and exports of bash or zsh startup files
+// over to ~/.goshellrc, to ease moving to goshell. It shows the commands it
+// will add, and the definitions it can't carry over, then asks before adding
+// them unless -y is given; -n only shows them. Once added they take effect
+// in the session too.
+func builtinImport(s *Shell, args []string, stdio Stdio) int {
+	var dryRun, yes bool
+	flags := newFlagSet("import")
+	flags.Bool(&dryRun, "n")
+	flags.Bool(&yes, "y")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) == 0 {
+		return flags.usage(stdio)
+	}
+
+	existing := s.rcLines()
+	var lines []importedLine
+	status := 0
+	for _, path := range operands {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "import:", unwrapPathError(err))
+			status = 1
+			continue
+		}
+		found, skipped := parseDotfile(s.tildePath(path), string(data))
+		for _, line := range skipped {
+			fmt.Fprintf(stdio.Stderr, "%s: skipped %s: %s\n", line.source, line.text, line.reason)
+		}
+		for _, line := range found {
+			if !existing[line.text] {
+				existing[line.text] = true
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(stdio.Stderr, "import: nothing to add")
+		return status
+	}
+	for _, line := range lines {
+		fmt.Fprintln(stdio.Stdout, line.text)
+	}
+	if dryRun {
+		return status
+	}
+
+	if !yes {
+		question := fmt.Sprintf("Add %d lines to %s?", len(lines), s.tildePath(s.rcPath()))
+		ok, err := s.confirm(question, stdio)
+		if errors.Is(err, errNoTerminal) {
+			fmt.Fprintln(stdio.Stderr, "import: not on a terminal to ask; add -y to add them")
+			return 1
+		} else if err != nil || !ok {
+			return 1
+		}
+	}
+
+	f, err := os.OpenFile(s.rcPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "import:", unwrapPathError(err))
+		return 1
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	source := ""
+	for _, line := range lines {
+		file := line.source[:strings.LastIndexByte(line.source, ':')]
+		if file != source {
+			fmt.Fprintf(w, "\n# Imported from %s\n", file)
+			source = file
+		}
+		fmt.Fprintln(w, line.text)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(stdio.Stderr, "import:", unwrapPathError(err))
+		return 1
+	}
+	for _, line := range lines {
+		s.runLineWith(line.text, stdio)
+	}
+	return status
+}
@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBashrc = `# ~/.bashrc
+export EDITOR=vim
+export PATH="$HOME/bin:$PATH" GOPATH=~/go
+export -n OLD
+export HISTSIZE
+alias ll='ls -la'
+  alias gs="git status" ..='cd ..'
+alias -g L='| less'
+export TODAY=$(date +%F)
+export NAME=${USER:-me}
+[ -d ~/.cargo ] && export CARGO=1
+if true; then
+fi
+`
+
+func TestParseDotfile(t *testing.T) {
+	lines, skipped := parseDotfile("~/.bashrc", testBashrc)
+	var got []string
+	for _, line := range lines {
+		got = append(got, line.source+" "+line.text)
+	}
+	want := []string{
+		"~/.bashrc:2 export EDITOR=vim",
+		`~/.bashrc:3 export PATH="$HOME/bin:$PATH"`,
+		"~/.bashrc:3 export GOPATH=~/go",
+		"~/.bashrc:6 alias ll='ls -la'",
+		`~/.bashrc:7 alias gs="git status"`,
+		"~/.bashrc:7 alias ..='cd ..'",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported lines:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	var reasons []string
+	for _, line := range skipped {
+		reasons = append(reasons, line.source+" "+line.reason)
+	}
+	wantReasons := []string{
+		"~/.bashrc:8 global and suffix aliases aren't supported",
+		"~/.bashrc:9 command substitution isn't supported",
+		"~/.bashrc:10 parameter expansions other than ${NAME} aren't supported",
+	}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("skipped lines: %q, want %q", reasons, wantReasons)
+	}
+}
+
+func TestImport(t *testing.T) {
+	home := t.TempDir()
+	bashrc := filepath.Join(home, ".bashrc")
+	os.WriteFile(bashrc, []byte("alias ll='ls -la'\nexport EDITOR=vim\n"), 0644)
+	rc := filepath.Join(home, rcFileName)
+	os.WriteFile(rc, []byte("export EDITOR=vim\n"), 0644)
+
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	if out, status := runCapture(t, shell, "import -n "+bashrc); status != 0 || out != "alias ll='ls -la'\n" {
+		t.Errorf("import -n: %q, status %d", out, status)
+	}
+	if out, status := runCapture(t, shell, "import "+bashrc); status != 1 || !strings.Contains(out, "add -y") {
+		t.Errorf("import off a terminal: %q, status %d", out, status)
+	}
+	runCapture(t, shell, "import -y "+bashrc)
+	data, _ := os.ReadFile(rc)
+	if want := "export EDITOR=vim\n\n# Imported from ~/.bashrc\nalias ll='ls -la'\n"; string(data) != want {
+		t.Errorf("startup file after import:\n%s", data)
+	}
+	if shell.aliases["ll"] != "ls -la" {
+		t.Error("the imported alias wasn't defined in the session")
+	}
+	if out, _ := runCapture(t, shell, "import -y "+bashrc); out != "import: nothing to add\n" {
+		t.Errorf("importing again: %q", out)
+	}
+}
@@ -0,0 +1,57 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Ctrl-C at the prompt reaches the line editor as a key. While a command
+// runs the terminal sends SIGINT to the shell along with the command, so the
+// interactive shell catches it rather than exiting, and builtins that can
+// run for long, such as find, check for it between steps to stop early.
+
+// catchInterrupts starts catching SIGINT, for the interactive shell
+func (s *Shell) catchInterrupts() {
+	s.interrupts = make(chan os.Signal, 1)
+	signal.Notify(s.interrupts, os.Interrupt)
+}
+
+// interrupted reports whether Ctrl-C has been pressed since it was last
+// asked, which it never has if interrupts aren't being caught
+func (s *Shell) interrupted() bool {
+	select {
+	case <-s.interrupts:
+		return true
+	default:
+		return false
+	}
+}
+
+// interruptPoll is how often interruptContext checks for Ctrl-C
+const interruptPoll = 50 * time.Millisecond
+
+// interruptContext returns a context that ends when Ctrl-C is pressed or
+// the shell's context ends, for builtins that run code which can be
+// cancelled but can't check for Ctrl-C itself, such as scripts. cancel must
+// be called once the code returns.
+func (s *Shell) interruptContext() (ctx context.Context, cancel context.CancelFunc) {
+	ctx, cancel = context.WithCancel(s.context())
+	go func() {
+		ticker := time.NewTicker(interruptPoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if s.interrupted() {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,380 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("json", "json [-cr] [PATH] [file...]", "Pretty-print JSON, or the parts of it a path such as .items[0].name picks", builtinJSON)
+	registerFlags("json",
+		Candidate{"-c", "Print each value on one line"},
+		Candidate{"-r", "Print strings without quotes"})
+}
+
+// Colors used to show JSON, as jq shows it
+const (
+	jsonKey     = Bold + Blue
+	jsonString  = synString
+	jsonNumber  = synNumber
+	jsonLiteral = synKeyword // true, false and null
+)
+
+// jsonObject is a decoded JSON object, keeping its keys in the order they
+// came in so that printing it changes nothing but the layout
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// jsonStep is one part of a path: a key, an index, or [] for every element
+type jsonStep struct {
+	key     string
+	index   int
+	isIndex bool
+	each    bool
+}
+
+// builtinJSON reads JSON values from files or its input and prints them
+// indented, colored on a terminal, as jq does. A path such as .items[0].name
+// or .users[].email picks out parts of each value: .key and ["key"] look up
+// keys, [N] indexes arrays from the start or, if negative, the end, and []
+// goes through every element. Keys that aren't there give null. -c prints
+// each value on one line and -r prints strings without quotes, for scripts.
+func builtinJSON(s *Shell, args []string, stdio Stdio) int {
+	var compact, raw bool
+	flags := newFlagSet("json")
+	flags.Bool(&compact, "c")
+	flags.Bool(&raw, "r")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	var path []jsonStep
+	if len(operands) > 0 && strings.HasPrefix(operands[0], ".") {
+		path, err = parseJSONPath(operands[0])
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "json:", err)
+			return 1
+		}
+		operands = operands[1:]
+	}
+	input, closeInputs, err := openInputs(operands, stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "json:", err)
+		return 1
+	}
+	defer closeInputs()
+
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	printer := &jsonPrinter{out: out, compact: compact, raw: raw, color: isTerminal(stdio.Stdout)}
+	dec := json.NewDecoder(input)
+	dec.UseNumber()
+	for {
+		value, err := decodeJSON(dec)
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "json:", err)
+			return 1
+		}
+		results, err := selectJSON(value, path)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "json:", err)
+			return 1
+		}
+		for _, result := range results {
+			printer.print(result)
+		}
+	}
+}
+
+// decodeJSON reads the next value from dec, with objects as *jsonObject
+func decodeJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{values: make(map[string]any)}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if _, seen := obj.values[key]; !seen {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, unexpectedEOF(err)
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, unexpectedEOF(err)
+	}
+	return tok, nil
+}
+
+// unexpectedEOF turns the end of the input partway through a value into an
+// error, as it isn't the clean end decodeJSON's callers stop at
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// parseJSONPath parses a path such as .items[0].name, ."odd key" or
+// .list[]
+func parseJSONPath(path string) ([]jsonStep, error) {
+	var steps []jsonStep
+	bad := func() ([]jsonStep, error) { return nil, fmt.Errorf("bad path %q", path) }
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "[\""):
+			key, n, err := quotedJSONKey(rest[1:])
+			after := strings.TrimLeft(rest[1+n:], " ")
+			if err != nil || !strings.HasPrefix(after, "]") {
+				return bad()
+			}
+			steps = append(steps, jsonStep{key: key})
+			rest = after[1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return bad()
+			}
+			if inside := strings.TrimSpace(rest[1:end]); inside == "" {
+				steps = append(steps, jsonStep{each: true})
+			} else {
+				index, err := strconv.Atoi(inside)
+				if err != nil {
+					return bad()
+				}
+				steps = append(steps, jsonStep{index: index, isIndex: true})
+			}
+			rest = rest[end+1:]
+		case rest[0] == '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '[' {
+				continue
+			}
+			if rest[0] == '"' {
+				key, n, err := quotedJSONKey(rest)
+				if err != nil {
+					return bad()
+				}
+				steps = append(steps, jsonStep{key: key})
+				rest = rest[n:]
+				continue
+			}
+			end := 0
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '-') {
+				end++
+			}
+			if end == 0 {
+				return bad()
+			}
+			steps = append(steps, jsonStep{key: rest[:end]})
+			rest = rest[end:]
+		default:
+			return bad()
+		}
+	}
+	return steps, nil
+}
+
+// quotedJSONKey reads the quoted key src starts with, returning it and how
+// many bytes it took
+func quotedJSONKey(src string) (string, int, error) {
+	dec := json.NewDecoder(strings.NewReader(src))
+	var key string
+	if err := dec.Decode(&key); err != nil {
+		return "", 0, err
+	}
+	return key, int(dec.InputOffset()), nil
+}
+
+// selectJSON follows a path through a value, returning what it leads to:
+// one value, or several if it goes through the elements of arrays
+func selectJSON(value any, path []jsonStep) ([]any, error) {
+	values := []any{value}
+	for _, step := range path {
+		var next []any
+		for _, v := range values {
+			switch {
+			case v == nil:
+				// Looking inside null gives null, as jq has it
+				if !step.each {
+					next = append(next, nil)
+				}
+			case step.each:
+				switch v := v.(type) {
+				case []any:
+					next = append(next, v...)
+				case *jsonObject:
+					for _, key := range v.keys {
+						next = append(next, v.values[key])
+					}
+				default:
+					return nil, fmt.Errorf("cannot iterate over %s", jsonTypeName(v))
+				}
+			case step.isIndex:
+				list, ok := v.([]any)
+				if !ok {
+					return nil, fmt.Errorf("cannot index %s with a number", jsonTypeName(v))
+				}
+				i := step.index
+				if i < 0 {
+					i += len(list)
+				}
+				if i < 0 || i >= len(list) {
+					next = append(next, nil)
+				} else {
+					next = append(next, list[i])
+				}
+			default:
+				obj, ok := v.(*jsonObject)
+				if !ok {
+					return nil, fmt.Errorf("cannot index %s with %q", jsonTypeName(v), step.key)
+				}
+				next = append(next, obj.values[step.key])
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// jsonTypeName names the type of a decoded value for error messages
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case *jsonObject:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+// jsonPrinter writes decoded values as JSON
+type jsonPrinter struct {
+	out     *bufio.Writer
+	compact bool // each value on one line, without spaces
+	raw     bool // strings at the top level without quotes
+	color   bool
+}
+
+// print writes a value and a newline
+func (p *jsonPrinter) print(v any) {
+	if s, ok := v.(string); ok && p.raw {
+		fmt.Fprintln(p.out, s)
+		return
+	}
+	p.value(v, 0)
+	p.out.WriteByte('\n')
+}
+
+// value writes v, indented as if it started a line at the given depth
+func (p *jsonPrinter) value(v any, depth int) {
+	switch v := v.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			p.out.WriteString("{}")
+			return
+		}
+		p.out.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				p.out.WriteByte(',')
+			}
+			p.newline(depth + 1)
+			p.styled(jsonKey, quoteJSON(key))
+			p.out.WriteByte(':')
+			if !p.compact {
+				p.out.WriteByte(' ')
+			}
+			p.value(v.values[key], depth+1)
+		}
+		p.newline(depth)
+		p.out.WriteByte('}')
+	case []any:
+		if len(v) == 0 {
+			p.out.WriteString("[]")
+			return
+		}
+		p.out.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				p.out.WriteByte(',')
+			}
+			p.newline(depth + 1)
+			p.value(elem, depth+1)
+		}
+		p.newline(depth)
+		p.out.WriteByte(']')
+	case string:
+		p.styled(jsonString, quoteJSON(v))
+	case json.Number:
+		p.styled(jsonNumber, v.String())
+	case bool:
+		p.styled(jsonLiteral, strconv.FormatBool(v))
+	default:
+		p.styled(jsonLiteral, "null")
+	}
+}
+
+// newline starts a line indented to depth, unless printing compactly
+func (p *jsonPrinter) newline(depth int) {
+	if p.compact {
+		return
+	}
+	p.out.WriteByte('\n')
+	p.out.WriteString(strings.Repeat("  ", depth))
+}
+
+// styled writes text in a color if coloring
+func (p *jsonPrinter) styled(color, text string) {
+	if p.color {
+		text = color + text + Reset
+	}
+	p.out.WriteString(text)
+}
+
+// quoteJSON quotes a string as JSON, leaving <, > and & as they are
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // a string always encodes
+	return strings.TrimSuffix(buf.String(), "\n")
+}
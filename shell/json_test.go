@@ -0,0 +1,117 @@
+package shell
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	for path, want := range map[string][]jsonStep{
+		".":                nil,
+		".name":            {{key: "name"}},
+		".items[0].name":   {{key: "items"}, {index: 0, isIndex: true}, {key: "name"}},
+		".items[-1]":       {{key: "items"}, {index: -1, isIndex: true}},
+		".users[].email":   {{key: "users"}, {each: true}, {key: "email"}},
+		`.["a key"].x-y`:   {{key: "a key"}, {key: "x-y"}},
+		`."odd.key"[ 2 ]`:  {{key: "odd.key"}, {index: 2, isIndex: true}},
+		`.[]["b"]`:         {{each: true}, {key: "b"}},
+		".items[].tags[1]": {{key: "items"}, {each: true}, {key: "tags"}, {index: 1, isIndex: true}},
+	} {
+		got, err := parseJSONPath(path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("parseJSONPath(%q) = %+v, %v, want %+v", path, got, err, want)
+		}
+	}
+	for _, path := range []string{".items[0", ".items[x]", "..", `.["a"`, ".a b"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"items": [{"name": "a"}, {"name": "b", "n": 2}], "none": null}`))
+	dec.UseNumber()
+	value, err := decodeJSON(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string][]any{
+		".items[0].name":  {"a"},
+		".items[].name":   {"a", "b"},
+		".items[-1].n":    {json.Number("2")},
+		".items[5]":       {nil},
+		".missing":        {nil},
+		".none.deep[0]":   {nil},
+		".items[1][]":     {"b", json.Number("2")},
+		".items[].n":      {nil, json.Number("2")},
+		".none[]":         nil,
+		".items[0].name1": {nil},
+	} {
+		steps, err := parseJSONPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := selectJSON(value, steps)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("selectJSON(%s) = %#v, %v, want %#v", path, got, err, want)
+		}
+	}
+	for _, path := range []string{".items.name", ".items[0][0]", ".items[0].name[]"} {
+		steps, _ := parseJSONPath(path)
+		if _, err := selectJSON(value, steps); err == nil {
+			t.Errorf("selectJSON(%s) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestJSONBuiltin(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.json")
+	data := `{"zeta": 1, "alpha": {"list": [1.50, true, null, "<&>"], "empty": {}}, "none": []}`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	for _, tt := range []struct {
+		args string
+		want string
+	}{
+		{file, `{
+  "zeta": 1,
+  "alpha": {
+    "list": [
+      1.50,
+      true,
+      null,
+      "<&>"
+    ],
+    "empty": {}
+  },
+  "none": []
+}
+`},
+		{"-c " + file, `{"zeta":1,"alpha":{"list":[1.50,true,null,"<&>"],"empty":{}},"none":[]}` + "\n"},
+		{".alpha.list[-1] " + file, "\"<&>\"\n"},
+		{"-r .alpha.list[-1] " + file, "<&>\n"},
+		{"-c .alpha.list[] " + file, "1.50\ntrue\nnull\n\"<&>\"\n"},
+	} {
+		out, status := runCapture(t, shell, "json "+tt.args)
+		if out != tt.want || status != 0 {
+			t.Errorf("json %s = %q (status %d), want %q", tt.args, out, status, tt.want)
+		}
+	}
+
+	// Values follow each other in a stream, as in JSON lines
+	if out, status := runCapture(t, shell, `echo '{"a": 1} {"a": 2}' | json .a`); out != "1\n2\n" || status != 0 {
+		t.Errorf("json .a on a stream = %q (status %d)", out, status)
+	}
+	for _, line := range []string{"echo '{\"a\": ' | json", "echo '[1 2]' | json", "echo '\"s\"' | json .a", "json .x missing.json"} {
+		if out, status := runCapture(t, shell, line); status != 1 || !strings.HasPrefix(out, "json: ") {
+			t.Errorf("%s = %q (status %d), want an error", line, out, status)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package shell
+
+import "unicode"
+
+// killRingSize is the number of kills kept for yanking
+const killRingSize = 16
+
+// killRing keeps text removed by the kill actions so that yank can put it
+// back, as in readline and emacs. Kills made one after another collect into
+// a single entry.
+type killRing struct {
+	kills [][]rune // oldest first
+
+	// The text the last yank or yank-pop inserted, for yank-pop to replace
+	yankStart, yankEnd int
+	yankIndex          int
+}
+
+// killActions are the actions whose text goes to the kill ring
+var killActions = []string{"kill-line", "backward-kill-line", "kill-word", "backward-kill-word", "unix-word-rubout"}
+
+// kill removes line[from:to], keeping it in the kill ring. If the previous
+// key killed too, the text joins that kill: after it when deleting forwards,
+// before it when deleting backwards.
+func (e *lineEditor) kill(line []rune, from, to int, backward bool) ([]rune, int) {
+	k := &e.kills
+	text := append([]rune(nil), line[from:to]...)
+	switch {
+	case contains(killActions, e.lastAction) && len(k.kills) > 0:
+		last := k.kills[len(k.kills)-1]
+		if backward {
+			text = append(text, last...)
+		} else {
+			text = append(append([]rune(nil), last...), text...)
+		}
+		k.kills[len(k.kills)-1] = text
+	case len(text) > 0:
+		k.kills = append(k.kills, text)
+		if len(k.kills) > killRingSize {
+			k.kills = k.kills[1:]
+		}
+	}
+	return replaceRunes(line, from, to, "")
+}
+
+// yank inserts the most recent kill at the cursor
+func (e *lineEditor) yank(line []rune, pos int) ([]rune, int) {
+	k := &e.kills
+	if len(k.kills) == 0 {
+		e.ed.Bell()
+		return line, pos
+	}
+	k.yankIndex = len(k.kills) - 1
+	k.yankStart = pos
+	line, k.yankEnd = replaceRunes(line, pos, pos, string(k.kills[k.yankIndex]))
+	return line, k.yankEnd
+}
+
+// yankPop replaces the text just yanked with the kill before it, cycling
+// round to the newest after the oldest. It only follows yank or yank-pop.
+func (e *lineEditor) yankPop(line []rune, pos int) ([]rune, int) {
+	k := &e.kills
+	if (e.lastAction != "yank" && e.lastAction != "yank-pop") || k.yankEnd > len(line) || pos != k.yankEnd {
+		e.ed.Bell()
+		return line, pos
+	}
+	k.yankIndex = (k.yankIndex + len(k.kills) - 1) % len(k.kills)
+	line, k.yankEnd = replaceRunes(line, k.yankStart, k.yankEnd, string(k.kills[k.yankIndex]))
+	return line, k.yankEnd
+}
+
+// backwardBlank returns the start of the blank-separated word before pos,
+// the unit unix-word-rubout kills
+func backwardBlank(line []rune, pos int) int {
+	for pos > 0 && unicode.IsSpace(line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(line[pos-1]) {
+		pos--
+	}
+	return pos
+}
@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+func TestKillRing(t *testing.T) {
+	editor := newTestEditor(NewShell())
+	editor.startLine("> ")
+
+	line := []rune("echo one two three")
+	pos := len(line)
+	steps := []struct {
+		keys    string
+		want    string
+		wantPos int
+	}{
+		{"\x17", "echo one two ", 13},
+		{"\x17", "echo one ", 9},
+		{"x\x17", "echo one ", 9},
+		{"\x19", "echo one x", 10},
+		{"\x1by", "echo one two three", 18},
+		{"\x1by", "echo one x", 10},
+		{"z\x1by", "echo one xz", 11},
+	}
+	for _, step := range steps {
+		line, pos = typeKeys(editor, line, pos, step.keys)
+		if string(line) != step.want || pos != step.wantPos {
+			t.Fatalf("after %q: %q at %d, want %q at %d", step.keys, string(line), pos, step.want, step.wantPos)
+		}
+	}
+
+	// A forward kill after a backward one joins it at the end
+	line, pos = press(editor, []rune("one two three"), 4, lineedit.CtrlU)
+	line, pos = press(editor, line, pos, lineedit.CtrlK)
+	if len(line) != 0 {
+		t.Fatalf("kills left %q", string(line))
+	}
+	line, pos = press(editor, line, pos, lineedit.CtrlY)
+	if string(line) != "one two three" || pos != 13 {
+		t.Errorf("yank gave %q at %d, want the joined kills", string(line), pos)
+	}
+}
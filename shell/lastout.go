@@ -0,0 +1,110 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerBuiltin("lastout", "lastout", "Print the output of the last command line again, with set -o lastout", builtinLastout)
+}
+
+// With set -o lastout, what each command line typed at the prompt prints is
+// kept, the last lastOutputSize bytes of it, so it can be used again
+// without running the commands again: lastout prints it, and $__LAST_OUT
+// expands to it. The commands write to the shell, which passes their output
+// on to the terminal, so programs that check find they aren't writing to a
+// terminal and may leave colors out. Full-screen programs, the ones desktop
+// notifications leave out, still run on the terminal, and keep nothing.
+
+// lastOutputSize bounds the output kept of a command line
+const lastOutputSize = 1 << 20
+
+// lastOutputVar is the variable the kept output expands from
+const lastOutputVar = "__LAST_OUT"
+
+// outputCapture passes output on to w, keeping the end of it
+type outputCapture struct {
+	w io.Writer
+
+	mu   sync.Mutex
+	kept []byte
+}
+
+func (c *outputCapture) Write(p []byte) (int, error) {
+	c.keep(p)
+	return c.w.Write(p)
+}
+
+func (c *outputCapture) passTo() io.Writer {
+	return c.w
+}
+
+// keep keeps p as output, without writing it
+func (c *outputCapture) keep(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.kept = append(c.kept, p...)
+	if len(c.kept) > lastOutputSize {
+		c.kept = append([]byte(nil), c.kept[len(c.kept)-lastOutputSize:]...)
+	}
+}
+
+// output returns what was kept
+func (c *outputCapture) output() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.kept
+}
+
+// runKeepingOutput runs a command line typed at the prompt, keeping what it
+// prints with set -o lastout. A line running lastout, or a full-screen
+// program, leaves the output kept before alone.
+func (s *Shell) runKeepingOutput(line string) int {
+	if !s.options["lastout"] || strings.HasPrefix(line+" ", "lastout ") || fullScreenCommand(line) {
+		return s.runLine(line)
+	}
+	capture := &outputCapture{w: s.stdout}
+	saved := s.stdout
+	s.stdout = capture
+	defer func() { s.stdout = saved }()
+	status := s.runLine(line)
+	s.lastOutput = capture.output()
+	return status
+}
+
+// fullScreenCommand reports whether a command line runs a full-screen
+// program, which needs the terminal to itself
+func fullScreenCommand(line string) bool {
+	first, _, _ := strings.Cut(line, " ")
+	return slices.Contains(notifyIgnored, filepath.Base(first))
+}
+
+// lastOutputText returns the output kept of the last command line as text,
+// without colors or its trailing newlines, as $__LAST_OUT expands to it
+func (s *Shell) lastOutputText() string {
+	text := stripANSI(strings.ReplaceAll(string(s.lastOutput), "\r\n", "\n"))
+	return strings.TrimRight(text, "\n")
+}
+
+// builtinLastout prints the output of the last command line again
+func builtinLastout(s *Shell, args []string, stdio Stdio) int {
+	flags := newFlagSet("lastout")
+	if operands, err := flags.Parse(args[1:]); err != nil {
+		return flags.fail(stdio, err)
+	} else if len(operands) > 0 {
+		return flags.usage(stdio)
+	}
+	if s.lastOutput == nil && !s.options["lastout"] {
+		fmt.Fprintln(stdio.Stderr, "lastout: no output kept; set -o lastout keeps it")
+		return 1
+	}
+	if text := s.lastOutputText(); text != "" {
+		fmt.Fprintln(stdio.Stdout, text)
+	}
+	return 0
+}
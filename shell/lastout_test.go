@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLastout(t *testing.T) {
+	shell := NewShell()
+	var out syncBuffer
+	shell.stdout = &out
+	shell.stderr = &out
+
+	if _, status := runCapture(t, shell, "lastout"); status != 1 {
+		t.Errorf("lastout without set -o lastout = %d, want 1", status)
+	}
+
+	shell.setOption("lastout", true)
+	shell.runKeepingOutput("echo one; sh -c 'printf \"two\\nthree\\n\"'")
+	if got, want := out.String(), "one\ntwo\nthree\n"; got != want {
+		t.Errorf("output shown = %q, want %q", got, want)
+	}
+	if got, want := shell.expandWord("$__LAST_OUT"), []string{"one\ntwo\nthree"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("$__LAST_OUT = %q, want %q", got, want)
+	}
+	if got, _ := runCapture(t, shell, "lastout | tail -1"); got != "three\n" {
+		t.Errorf("lastout | tail -1 = %q, want three", got)
+	}
+
+	// Running lastout keeps the output it shows, and so does a full-screen
+	// program
+	shell.runKeepingOutput("lastout")
+	shell.runKeepingOutput("less /dev/null")
+	if got := shell.lastOutputText(); got != "one\ntwo\nthree" {
+		t.Errorf("kept after lastout and less = %q", got)
+	}
+
+	shell.runKeepingOutput("echo " + strings.Repeat("x", lastOutputSize))
+	if got := len(shell.lastOutput); got != lastOutputSize {
+		t.Errorf("kept %d bytes, want at most %d", got, lastOutputSize)
+	}
+}
+
+func TestLastoutList(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	var out syncBuffer
+	shell.stdout = &out
+	shell.stderr = &out
+
+	// Keeping the output leaves ls the built-in listing
+	shell.setOption("lastout", true)
+	shell.runKeepingOutput("ls " + dir)
+	if got, want := stripANSI(out.String()), "📄 notes.txt\n"; got != want {
+		t.Errorf("ls printed %q, want %q", got, want)
+	}
+	if got := stripANSI(shell.lastOutputText()); got != "📄 notes.txt" {
+		t.Errorf("kept %q, want the listing", got)
+	}
+}
@@ -0,0 +1,771 @@
+package shell
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"goshell/internal/lineedit"
+)
+
+// lsOptions are the options of the built-in ls
+type lsOptions struct {
+	long    bool   // one entry per line, with its permissions, owner, size and time
+	all     bool   // hidden files, and . and ..
+	almost  bool   // hidden files without . and ..
+	sortBy  string // "time" or "size" for newest or largest first, or "" for by name
+	reverse bool
+	human   bool // sizes in KiB, MiB and GiB
+	sizes   bool // each file's size before its name, and a summary line
+	json    bool // a JSON array of the entries, for scripts
+}
+
+// lsSummary totals the entries in a listing
+type lsSummary struct {
+	count int
+	size  int64 // of the files, leaving out directories
+}
+
+// lsEntry is a file in a listing. Its info is nil if it couldn't be read.
+type lsEntry struct {
+	name string
+	info fs.FileInfo
+}
+
+// lsFiles is where a listing is read from: the local file system, or the
+// remote host's in remote mode
+type lsFiles interface {
+	readDir(dir string) ([]lsEntry, error)
+	stat(dir, name string) (fs.FileInfo, error)
+	readlink(dir, name string) (string, error)
+}
+
+// localFiles reads listings from the local file system
+type localFiles struct{}
+
+func (localFiles) readDir(dir string) ([]lsEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]lsEntry, len(dirEntries))
+	for i, entry := range dirEntries {
+		// The entry may have been removed since the directory was read
+		info, _ := entry.Info()
+		entries[i] = lsEntry{entry.Name(), info}
+	}
+	return entries, nil
+}
+
+func (localFiles) stat(dir, name string) (fs.FileInfo, error) {
+	return os.Stat(filepath.Join(dir, name))
+}
+
+func (localFiles) readlink(dir, name string) (string, error) {
+	return os.Readlink(filepath.Join(dir, name))
+}
+
+// listingFiles returns where ls reads listings from
+func (s *Shell) listingFiles() lsFiles {
+	if s.remote != nil {
+		return s.remote
+	}
+	return localFiles{}
+}
+
+func builtinLs(s *Shell, args []string, stdio Stdio) int {
+	var opts lsOptions
+	var help bool
+	flags := newFlagSet("ls")
+	flags.Bool(&opts.long, "l")
+	flags.Bool(&opts.all, "a")
+	flags.Bool(&opts.almost, "A")
+	flags.Bool(&opts.reverse, "r")
+	flags.Bool(&opts.human, "h")
+	flags.Bool(&opts.sizes, "s")
+	// Of -t and -S, the one given last decides the order
+	flags.define(&flagDef{set: func(string) error {
+		opts.sortBy = "time"
+		return nil
+	}}, []string{"t"})
+	flags.define(&flagDef{set: func(string) error {
+		opts.sortBy = "size"
+		return nil
+	}}, []string{"S"})
+	flags.Bool(&opts.json, "json")
+	flags.Bool(&help, "help")
+	operands, err := flags.Parse(args[1:])
+
+	// JSON, and records for a builtin after ls in a pipeline, are always
+	// written by the built-in listing, wherever they go
+	if stdio.recordsOut != nil && !help {
+		if err != nil {
+			return flags.fail(stdio, err)
+		}
+		if len(operands) > 1 {
+			return flags.usage(stdio)
+		}
+		dir := "."
+		if len(operands) == 1 {
+			dir = operands[0]
+		}
+		if err := listRecords(stdio, s.listingFiles(), dir, opts); err != nil {
+			fmt.Fprintln(stdio.Stderr, "ls:", err)
+			return 1
+		}
+		return 0
+	}
+	if opts.json && !help {
+		if err != nil {
+			return flags.fail(stdio, err)
+		}
+		if len(operands) > 1 {
+			return flags.usage(stdio)
+		}
+		dir := "."
+		if len(operands) == 1 {
+			dir = operands[0]
+		}
+		return s.paged(stdio, func(stdio Stdio) int {
+			if err := listJSON(stdio.Stdout, s.listingFiles(), dir, opts); err != nil {
+				fmt.Fprintln(stdio.Stderr, "ls:", err)
+				return 1
+			}
+			return 0
+		})
+	}
+
+	// Check if we should use the built-in colorized ls or system ls. Options
+	// the built-in listing lacks, several directories and output going to a
+	// pipe or file use system ls.
+	if err != nil || help || len(operands) > 1 || toPipeOrFile(stdio.Stdout) {
+		// For complex ls commands, fall back to system ls with color
+		if s.remote != nil {
+			return s.runRemote(s.systemLs(args), stdio)
+		}
+		return s.runSystem(s.systemLs(args), stdio)
+	}
+
+	// Use our built-in colorized ls for simple directory listings
+	dir := "."
+	if len(operands) == 1 {
+		dir = operands[0]
+	}
+	return s.paged(stdio, func(stdio Stdio) int {
+		if err := s.ColorizedLS(stdio.Stdout, dir, opts); err != nil {
+			fmt.Fprintln(stdio.Stderr, "Error listing directory:", err)
+			return 1
+		}
+		return 0
+	})
+}
+
+// systemLs returns the arguments running ls on the system, asking it for
+// colors as the built-in listing has them. POSIX mode runs ls as given,
+// since ls on BSD and macOS has no --color.
+func (s *Shell) systemLs(args []string) []string {
+	if s.options["posix"] {
+		return args
+	}
+	return append([]string{"ls", "--color=auto"}, args[1:]...)
+}
+
+// toPipeOrFile reports whether output goes to a pipe or file, rather than
+// to the terminal or to a writer of a program embedding the shell. Output
+// kept for lastout or the transcript goes where it is passed on to.
+func toPipeOrFile(w io.Writer) bool {
+	if t, ok := w.(teeOutput); ok {
+		return toPipeOrFile(t.passTo())
+	}
+	f, ok := w.(*os.File)
+	return ok && !isTerminal(f)
+}
+
+// ColorizedLS implements a colorized directory listing
+func (s *Shell) ColorizedLS(w io.Writer, dir string, opts lsOptions) error {
+	// If no directory is provided, use the current directory
+	if dir == "" {
+		var err error
+		dir, err = s.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+
+	files := s.listingFiles()
+	entries, summary, err := readListingFrom(files, dir, opts)
+	if err != nil {
+		return err
+	}
+	// Inside a git repository each name follows its status
+	var statuses map[string]gitMark
+	if s.remote == nil {
+		statuses = s.gitStatuses(dir)
+	}
+	theme := s.lsTheme()
+	if opts.long {
+		longListing(w, files, dir, entries, statuses, theme, opts)
+		printSummary(w, summary, opts)
+		return nil
+	}
+
+	// Create slices to store formatted entry names and how many columns
+	// each takes on the terminal
+	var formattedEntries []string
+	var widths []int
+	maxWidth := 0
+
+	// With -s each name follows its size, right-aligned
+	sizeWidth := 0
+	if opts.sizes {
+		for _, entry := range entries {
+			if entry.info != nil {
+				sizeWidth = max(sizeWidth, len(sizeText(entry.info.Size(), opts)))
+			}
+		}
+	}
+
+	// Format entries with appropriate colors and emoji icons
+	for _, entry := range entries {
+		name, info := entry.name, entry.info
+		if info == nil {
+			// If we can't get info, just add without color or icon
+			formattedEntries = append(formattedEntries, name)
+			widths = append(widths, lineedit.StringWidth(name))
+			maxWidth = max(maxWidth, widths[len(widths)-1])
+			continue
+		}
+
+		// Add colored name with icon to our entries list
+		formattedName := theme.styledName(name, info)
+		if opts.sizes {
+			formattedName = fmt.Sprintf("%*s %s", sizeWidth, sizeText(info.Size(), opts), formattedName)
+		}
+		if statuses != nil {
+			formattedName = statuses[name].String() + " " + formattedName
+		}
+		formattedEntries = append(formattedEntries, formattedName)
+
+		// Track the maximum width for columnar output. The icon set knows
+		// how wide its icons are drawn; names are measured by their
+		// characters, as wide CJK characters and emoji take two columns and
+		// combining marks none.
+		displayWidth := lineedit.StringWidth(name) + theme.icons.width
+		if info.IsDir() {
+			displayWidth++ // and the trailing slash
+		}
+		if opts.sizes {
+			displayWidth += sizeWidth + 1
+		}
+		if statuses != nil {
+			displayWidth += 2 // the status and a space
+		}
+		widths = append(widths, displayWidth)
+		if displayWidth > maxWidth {
+			maxWidth = displayWidth
+		}
+	}
+
+	// Print entries in a grid-like format
+	termWidth := 80 // Default terminal width
+	if ws, err := getTerminalSize(); err == nil {
+		termWidth = ws.Col
+	}
+
+	// Calculate columns based on terminal width and max filename width
+	// Add 2 for some padding between columns
+	colWidth := maxWidth + 2
+	numCols := termWidth / colWidth
+	if numCols < 1 {
+		numCols = 1
+	}
+
+	// Print entries in rows and columns
+	for i, entry := range formattedEntries {
+		// Print the entry with padding
+		fmt.Fprint(w, entry)
+
+		// Add appropriate spacing for columnar output
+		if (i+1)%numCols != 0 && i < len(formattedEntries)-1 {
+			// Print spaces to fill the column
+			fmt.Fprint(w, strings.Repeat(" ", colWidth-widths[i]))
+		} else {
+			// End of row or last entry
+			fmt.Fprintln(w)
+		}
+	}
+
+	printSummary(w, summary, opts)
+	return nil
+}
+
+// sizeText formats a file size, in binary units with -h
+func sizeText(n int64, opts lsOptions) string {
+	if opts.human {
+		return formatSize(n)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// printSummary prints the number of entries listed and their total size
+// after the listing when -s is given
+func printSummary(w io.Writer, summary lsSummary, opts lsOptions) {
+	if !opts.sizes {
+		return
+	}
+	fmt.Fprintf(w, "%d %s, %s total\n", summary.count, plural(summary.count, "entry", "entries"), sizeText(summary.size, opts))
+}
+
+// readListing returns the entries of dir in the order they are listed.
+// Hidden files are left out unless opts asks for them, and -a adds the
+// directory itself and its parent as . and .. first.
+func readListing(dir string, opts lsOptions) ([]lsEntry, lsSummary, error) {
+	return readListingFrom(localFiles{}, dir, opts)
+}
+
+// readListingFrom returns the entries of dir, read from files, in the order
+// they are listed
+func readListingFrom(files lsFiles, dir string, opts lsOptions) ([]lsEntry, lsSummary, error) {
+	dirEntries, err := files.readDir(dir)
+	if err != nil {
+		return nil, lsSummary{}, err
+	}
+	var entries []lsEntry
+	var summary lsSummary
+	for _, entry := range dirEntries {
+		if strings.HasPrefix(entry.name, ".") && !opts.all && !opts.almost {
+			continue
+		}
+		entries = append(entries, entry)
+		summary.count++
+		if entry.info != nil && !entry.info.IsDir() {
+			summary.size += entry.info.Size()
+		}
+	}
+
+	// Sort entries: by default directories first, then files, by name
+	less := func(a, b lsEntry) int {
+		aIsDir := a.info != nil && a.info.IsDir()
+		bIsDir := b.info != nil && b.info.IsDir()
+		if aIsDir != bIsDir {
+			if aIsDir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.name, b.name)
+	}
+	switch opts.sortBy {
+	case "time":
+		less = func(a, b lsEntry) int {
+			if c := compareInfo(a, b, func(info fs.FileInfo) int64 { return info.ModTime().UnixNano() }); c != 0 {
+				return c
+			}
+			return strings.Compare(a.name, b.name)
+		}
+	case "size":
+		less = func(a, b lsEntry) int {
+			if c := compareInfo(a, b, func(info fs.FileInfo) int64 { return info.Size() }); c != 0 {
+				return c
+			}
+			return strings.Compare(a.name, b.name)
+		}
+	}
+	slices.SortStableFunc(entries, less)
+	if opts.reverse {
+		slices.Reverse(entries)
+	}
+
+	if opts.all {
+		var dots []lsEntry
+		for _, name := range []string{".", ".."} {
+			info, _ := files.stat(dir, name)
+			dots = append(dots, lsEntry{name, info})
+		}
+		entries = append(dots, entries...)
+	}
+	return entries, summary, nil
+}
+
+// compareInfo orders entries by a property of their info, largest first.
+// An entry whose info couldn't be read counts as zero.
+func compareInfo(a, b lsEntry, key func(fs.FileInfo) int64) int {
+	var x, y int64
+	if a.info != nil {
+		x = key(a.info)
+	}
+	if b.info != nil {
+		y = key(b.info)
+	}
+	return cmp.Compare(y, x)
+}
+
+// lsRecord is an entry as ls --json writes it
+type lsRecord struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	Target  string    `json:"target,omitempty"` // what a symlink points to
+}
+
+// listJSON writes the entries of dir as a JSON array, in the order and with
+// the hidden files opts asks for
+func listJSON(w io.Writer, files lsFiles, dir string, opts lsOptions) error {
+	records, err := readRecordListing(files, dir, opts)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// listRecords sends the entries of dir down a pipeline as records, with the
+// fields ls --json gives them
+func listRecords(stdio Stdio, files lsFiles, dir string, opts lsOptions) error {
+	entries, err := readRecordListing(files, dir, opts)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		r := newRecord()
+		r.set("name", entry.Name)
+		r.set("type", entry.Type)
+		r.set("size", entry.Size)
+		r.set("mode", entry.Mode)
+		r.set("mtime", entry.ModTime)
+		if entry.Target != "" {
+			r.set("target", entry.Target)
+		}
+		if err := writeRecord(stdio, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecordListing reads the entries of dir as ls --json describes them,
+// leaving out those that couldn't be read
+func readRecordListing(files lsFiles, dir string, opts lsOptions) ([]lsRecord, error) {
+	entries, _, err := readListingFrom(files, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	records := []lsRecord{}
+	for _, entry := range entries {
+		if entry.info == nil {
+			continue
+		}
+		record := lsRecord{
+			Name:    entry.name,
+			Type:    fileType(entry.info.Mode()),
+			Size:    entry.info.Size(),
+			Mode:    modeString(entry.info.Mode()),
+			ModTime: entry.info.ModTime(),
+		}
+		if entry.info.Mode()&fs.ModeSymlink != 0 {
+			record.Target, _ = files.readlink(dir, entry.name)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// fileType names the type of a file for ls --json
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode&fs.ModeCharDevice != 0:
+		return "char_device"
+	case mode&fs.ModeDevice != 0:
+		return "block_device"
+	case mode&fs.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	}
+	return "file"
+}
+
+// fileKind is what sort of file an entry is, which decides the color and
+// icon it is listed with
+type fileKind int
+
+const (
+	kindFile fileKind = iota
+	kindDir
+	kindSymlink
+	kindDevice
+	kindPipe
+	kindSocket
+	kindExecutable
+	kindText
+	kindPDF
+	kindDocument
+	kindSpreadsheet
+	kindPresentation
+	kindImage
+	kindAudio
+	kindVideo
+	kindArchive
+	kindGo
+	kindPython
+	kindJavaScript
+	kindHTML
+	kindCSS
+	kindC
+	kindJava
+	kindShell
+	kindRuby
+	kindConfig
+)
+
+// extensionKinds are the kinds of regular files told apart by their
+// extension
+var extensionKinds = func() map[string]fileKind {
+	kinds := make(map[string]fileKind)
+	for kind, extensions := range map[fileKind][]string{
+		kindText:         {".txt", ".md", ".log", ".csv"},
+		kindPDF:          {".pdf"},
+		kindDocument:     {".doc", ".docx", ".odt"},
+		kindSpreadsheet:  {".xls", ".xlsx", ".ods"},
+		kindPresentation: {".ppt", ".pptx", ".odp"},
+		kindImage:        {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".svg"},
+		kindAudio:        {".mp3", ".wav", ".flac", ".ogg", ".m4a"},
+		kindVideo:        {".mp4", ".avi", ".mkv", ".mov", ".wmv"},
+		kindArchive:      {".zip", ".tar", ".gz", ".rar", ".7z"},
+		kindGo:           {".go"},
+		kindPython:       {".py"},
+		kindJavaScript:   {".js", ".ts"},
+		kindHTML:         {".html", ".htm"},
+		kindCSS:          {".css"},
+		kindC:            {".c", ".cpp", ".h", ".hpp"},
+		kindJava:         {".java"},
+		kindShell:        {".sh", ".bash", ".zsh"},
+		kindRuby:         {".rb"},
+		kindConfig:       {".json", ".yaml", ".yml", ".toml", ".xml"},
+	} {
+		for _, ext := range extensions {
+			kinds[ext] = kind
+		}
+	}
+	return kinds
+}()
+
+// kindColors are the built-in colors of each kind of file
+var kindColors = map[fileKind]string{
+	kindFile:         Reset,
+	kindDir:          Bold + Blue,
+	kindSymlink:      Bold + Cyan,
+	kindDevice:       Bold + Yellow,
+	kindPipe:         Bold + Yellow,
+	kindSocket:       Bold + Magenta,
+	kindExecutable:   Bold + Green,
+	kindText:         White,
+	kindPDF:          Red,
+	kindDocument:     Blue,
+	kindSpreadsheet:  Green,
+	kindPresentation: Yellow,
+	kindImage:        Magenta,
+	kindAudio:        Cyan,
+	kindVideo:        Yellow,
+	kindArchive:      Red,
+	kindGo:           Cyan,
+	kindPython:       Yellow,
+	kindJavaScript:   Yellow,
+	kindHTML:         Bold + Red,
+	kindCSS:          Bold + Magenta,
+	kindC:            Blue,
+	kindJava:         Red,
+	kindShell:        Green,
+	kindRuby:         Red,
+	kindConfig:       Yellow,
+}
+
+// fileKindOf returns what sort of file an entry is, from its type,
+// permissions and extension
+func fileKindOf(name string, info fs.FileInfo) fileKind {
+	switch mode := info.Mode(); {
+	case info.IsDir():
+		return kindDir
+	case mode&fs.ModeSymlink != 0:
+		return kindSymlink
+	case mode&fs.ModeDevice != 0:
+		return kindDevice
+	case mode&fs.ModeNamedPipe != 0:
+		return kindPipe
+	case mode&fs.ModeSocket != 0:
+		return kindSocket
+	case mode&0111 != 0:
+		return kindExecutable
+	}
+	if kind, ok := extensionKinds[strings.ToLower(filepath.Ext(name))]; ok {
+		return kind
+	}
+	return kindFile
+}
+
+// longListing prints entries of dir one per line, as ls -l does: the file's
+// type and permissions, number of links, owner, group, size and when it was
+// last modified, then its git status if it has one, its name and, for a
+// symlink, what it points to
+func longListing(w io.Writer, files lsFiles, dir string, entries []lsEntry, statuses map[string]gitMark, theme lsTheme, opts lsOptions) {
+	names := make(ownerNames)
+	now := time.Now()
+	var rows [][]string
+	for _, entry := range entries {
+		info := entry.info
+		if info == nil {
+			// The entry was removed since the directory was read
+			continue
+		}
+		links, owner, group := fileOwner(info)
+		if remote, ok := info.Sys().(*remoteOwner); ok && remote != nil {
+			links, owner, group = remote.links, remote.user, remote.group
+		}
+		name := theme.styledName(entry.name, info)
+		if statuses != nil {
+			name = statuses[entry.name].String() + " " + name
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if target, err := files.readlink(dir, entry.name); err == nil {
+				name += " -> " + target
+			}
+		}
+		rows = append(rows, []string{
+			modeString(info.Mode()),
+			strconv.FormatUint(links, 10),
+			names.user(owner),
+			names.group(group),
+			sizeText(info.Size(), opts),
+			modTime(info.ModTime(), now),
+			name,
+		})
+	}
+
+	// Pad each column to its widest value: counts and sizes to the right,
+	// names to the left, measured in terminal columns as user and group
+	// names need not be ASCII
+	widths := make([]int, 6)
+	for _, row := range rows {
+		for i := range widths {
+			widths[i] = max(widths[i], lineedit.StringWidth(row[i]))
+		}
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s %*s %s %s %*s %s %s\n", row[0],
+			widths[1], row[1], padRight(row[2], widths[2]), padRight(row[3], widths[3]),
+			widths[4], row[4], row[5], row[6])
+	}
+}
+
+// padRight pads text with spaces to width terminal columns
+func padRight(text string, width int) string {
+	return text + strings.Repeat(" ", max(width-lineedit.StringWidth(text), 0))
+}
+
+// padLeft pads text with spaces on the left to width terminal columns
+func padLeft(text string, width int) string {
+	return strings.Repeat(" ", max(width-lineedit.StringWidth(text), 0)) + text
+}
+
+// modeString returns a file's type and permissions as ls -l shows them, as
+// in drwxr-xr-x
+func modeString(mode fs.FileMode) string {
+	b := []byte("----------")
+	switch {
+	case mode&fs.ModeDir != 0:
+		b[0] = 'd'
+	case mode&fs.ModeSymlink != 0:
+		b[0] = 'l'
+	case mode&fs.ModeCharDevice != 0:
+		b[0] = 'c'
+	case mode&fs.ModeDevice != 0:
+		b[0] = 'b'
+	case mode&fs.ModeNamedPipe != 0:
+		b[0] = 'p'
+	case mode&fs.ModeSocket != 0:
+		b[0] = 's'
+	}
+	for i, c := range "rwxrwxrwx" {
+		if mode&(1<<(8-i)) != 0 {
+			b[i+1] = byte(c)
+		}
+	}
+	// The setuid, setgid and sticky bits show in place of the execute bits,
+	// in upper case where those aren't set
+	special := func(i int, set bool, c byte) {
+		if !set {
+			return
+		}
+		if b[i] == 'x' {
+			b[i] = c
+		} else {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	special(3, mode&fs.ModeSetuid != 0, 's')
+	special(6, mode&fs.ModeSetgid != 0, 's')
+	special(9, mode&fs.ModeSticky != 0, 't')
+	return string(b)
+}
+
+// modTime formats a modification time as ls -l does: with the time of day
+// for the last six months, and the year for older files and those dated in
+// the future
+func modTime(t, now time.Time) string {
+	if t.After(now.AddDate(0, -6, 0)) && !t.After(now.Add(time.Minute)) {
+		return t.Format("Jan _2 15:04")
+	}
+	return t.Format("Jan _2  2006")
+}
+
+// ownerNames looks up the names of users and groups by their IDs, once
+// each. An ID with no name is shown as it is.
+type ownerNames map[string]string
+
+func (n ownerNames) user(id string) string {
+	return n.lookup("u"+id, id, func() (string, error) {
+		u, err := user.LookupId(id)
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	})
+}
+
+func (n ownerNames) group(id string) string {
+	return n.lookup("g"+id, id, func() (string, error) {
+		g, err := user.LookupGroupId(id)
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	})
+}
+
+func (n ownerNames) lookup(key, id string, find func() (string, error)) string {
+	if name, ok := n[key]; ok {
+		return name
+	}
+	name, err := find()
+	if err != nil || name == "" {
+		name = id
+	}
+	n[key] = name
+	return name
+}
@@ -0,0 +1,282 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLongListing(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	// Whatever the umask
+	os.Chmod(filepath.Join(dir, "src"), 0755)
+	os.Chmod(filepath.Join(dir, "notes.txt"), 0640)
+	old := time.Date(2001, time.March, 4, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(dir, "notes.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("notes.txt", filepath.Join(dir, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := NewShell().ColorizedLS(&out, dir, lsOptions{long: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(stripANSI(out.String()), "\n"), "\n")
+	want := []struct{ prefix, suffix string }{
+		{"drwxr-xr-x ", "📁 src/"},
+		{"lrwxrwxrwx ", "🔗 link -> notes.txt"},
+		{"-rw-r----- ", "Mar  4  2001 📄 notes.txt"},
+	}
+	if runtime.GOOS == "windows" {
+		// Permissions aren't kept there, and making symlinks takes privileges
+		want = []struct{ prefix, suffix string }{{"d", want[0].suffix}, {"-", want[2].suffix}}
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("ls -l printed %q, want %d lines", lines, len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i].prefix) || !strings.HasSuffix(line, want[i].suffix) {
+			t.Errorf("line %d = %q, want %q...%q", i+1, line, want[i].prefix, want[i].suffix)
+		}
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if size := fields[len(fields)-6]; size != "6" {
+		t.Errorf("notes.txt size = %s, want 6", size)
+	}
+}
+
+func TestModeString(t *testing.T) {
+	for mode, want := range map[fs.FileMode]string{
+		0644:                                     "-rw-r--r--",
+		fs.ModeDir | 0755:                        "drwxr-xr-x",
+		fs.ModeDir | fs.ModeSticky | 0777:        "drwxrwxrwt",
+		fs.ModeSetuid | 0755:                     "-rwsr-xr-x",
+		fs.ModeSetgid | 0644:                     "-rw-r-Sr--",
+		fs.ModeDevice | fs.ModeCharDevice | 0620: "crw--w----",
+		fs.ModeNamedPipe | 0600:                  "prw-------",
+	} {
+		if got := modeString(mode); got != want {
+			t.Errorf("modeString(%v) = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestModTime(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 9, 30, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		t    time.Time
+		want string
+	}{
+		{now.Add(-time.Hour), "Jun 15 08:30"},
+		{time.Date(2024, time.February, 3, 14, 5, 0, 0, time.UTC), "Feb  3 14:05"},
+		{time.Date(2023, time.November, 20, 10, 0, 0, 0, time.UTC), "Nov 20  2023"},
+		{now.AddDate(0, 0, 2), "Jun 17  2024"},
+	} {
+		if got := modTime(tt.t, now); got != tt.want {
+			t.Errorf("modTime(%v) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestListingOrder(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, file := range []struct {
+		name string
+		size int
+	}{{"b.txt", 30000}, {"a.txt", 10000}, {".hidden", 20000}, {"c.txt", 5000}} {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, make([]byte, file.size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Each file is an hour older than the one before
+		mtime := now.Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Smaller than the files, whatever size the file system gives it
+	if err := os.Mkdir(filepath.Join(dir, "z"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "z"), now.Add(-time.Minute), now.Add(-time.Minute))
+
+	for _, tt := range []struct {
+		opts lsOptions
+		want string
+	}{
+		{lsOptions{}, "z a.txt b.txt c.txt"},
+		{lsOptions{almost: true}, "z .hidden a.txt b.txt c.txt"},
+		{lsOptions{all: true}, ". .. z .hidden a.txt b.txt c.txt"},
+		{lsOptions{reverse: true}, "c.txt b.txt a.txt z"},
+		{lsOptions{sortBy: "time"}, "b.txt z a.txt c.txt"},
+		{lsOptions{sortBy: "size", almost: true}, "b.txt .hidden a.txt c.txt z"},
+		{lsOptions{sortBy: "size", reverse: true}, "z c.txt a.txt b.txt"},
+	} {
+		entries, _, err := readListing(dir, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.name)
+		}
+		if got := strings.Join(names, " "); got != tt.want {
+			t.Errorf("readListing(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestListingSizes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, 3*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "small.txt"), make([]byte, 512), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	var out bytes.Buffer
+	if err := shell.ColorizedLS(&out, dir, lsOptions{long: true, human: true, sizes: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(stripANSI(out.String()), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("ls -lhs printed %q, want 4 lines", lines)
+	}
+	if !strings.Contains(lines[1], " 3.0KiB ") || !strings.Contains(lines[2], " 512B ") {
+		t.Errorf("ls -lhs sizes = %q, %q, want 3.0KiB and 512B", lines[1], lines[2])
+	}
+	if want := "3 entries, 3.5KiB total"; lines[3] != want {
+		t.Errorf("ls -lhs summary = %q, want %q", lines[3], want)
+	}
+
+	out.Reset()
+	if err := shell.ColorizedLS(&out, dir, lsOptions{sizes: true}); err != nil {
+		t.Fatal(err)
+	}
+	got := stripANSI(out.String())
+	if !strings.Contains(got, "3072 ") || !strings.Contains(got, " 512 ") || !strings.HasSuffix(got, "3 entries, 3584 total\n") {
+		t.Errorf("ls -s printed %q, want sizes in bytes and a summary", got)
+	}
+}
+
+func TestListingGridWidths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"abc.txt", "e\u0301.txt", "日本語.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shell := NewShell()
+	shell.env.Set("GOSHELL_ICONS", "none")
+
+	// Columns are as wide as the widest name, 日本語.txt at 10 columns, and
+	// two more; the combining accent takes none
+	var out bytes.Buffer
+	if err := shell.ColorizedLS(&out, dir, lsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "abc.txt     e\u0301.txt       日本語.txt\n"
+	if got := stripANSI(out.String()); got != want {
+		t.Errorf("ls printed %q, want %q", got, want)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "notes.txt"), mtime, mtime)
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("notes.txt", filepath.Join(dir, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Output going to a pipe is still the built-in listing's
+	out, status := runCapture(t, NewShell(), "ls --json "+dir)
+	if status != 0 {
+		t.Fatalf("ls --json failed: %s", out)
+	}
+	var records []lsRecord
+	if err := json.Unmarshal([]byte(out), &records); err != nil {
+		t.Fatalf("ls --json printed %q: %v", out, err)
+	}
+	byName := make(map[string]lsRecord)
+	var names []string
+	for _, record := range records {
+		byName[record.Name] = record
+		names = append(names, record.Name)
+	}
+	want := "src link notes.txt"
+	if runtime.GOOS == "windows" {
+		want = "src notes.txt"
+	}
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("ls --json listed %q, want %q", got, want)
+	}
+	if notes := byName["notes.txt"]; notes.Type != "file" || notes.Size != 6 || !notes.ModTime.Equal(mtime) || notes.Target != "" {
+		t.Errorf("notes.txt = %+v, want a 6-byte file modified at %v", notes, mtime)
+	}
+	if src := byName["src"]; src.Type != "directory" || !strings.HasPrefix(src.Mode, "d") {
+		t.Errorf("src = %+v, want a directory", src)
+	}
+	if link, ok := byName["link"]; ok && (link.Type != "symlink" || link.Target != "notes.txt") {
+		t.Errorf("link = %+v, want a symlink to notes.txt", link)
+	}
+
+	out, _ = runCapture(t, NewShell(), "ls --json -A "+dir)
+	if !strings.Contains(out, `"name": ".hidden"`) {
+		t.Errorf("ls --json -A printed %q, want the hidden file", out)
+	}
+}
+
+func TestListToWriter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A program running the shell gets the built-in listing, not system ls
+	var out bytes.Buffer
+	sh := New(Options{Stdout: &out, Stderr: &out, Env: []string{"PATH=/bin:/usr/bin"}})
+	if status, err := sh.Run(context.Background(), "ls "+dir); err != nil || status != 0 {
+		t.Fatalf("ls = %d, %v: %s", status, err, out.String())
+	}
+	if got, want := stripANSI(out.String()), "📁 src/       📄 notes.txt\n"; got != want {
+		t.Errorf("ls printed %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package shell
+
+import (
+	"io/fs"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns a file's number of hard links and the IDs of its owner
+// and group
+func fileOwner(info fs.FileInfo) (links uint64, owner, group string) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1, "", ""
+	}
+	return uint64(st.Nlink), strconv.FormatUint(uint64(st.Uid), 10), strconv.FormatUint(uint64(st.Gid), 10)
+}
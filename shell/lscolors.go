@@ -0,0 +1,125 @@
+package shell
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// LS_COLORS chooses the colors ls and tree list files in, as it does for
+// GNU ls, so that a dircolors setup applies in the shell too. It is a
+// colon-separated list of KEY=CODES, where KEY is a file type such as di
+// for directories or ex for executables, or *SUFFIX for names ending in
+// SUFFIX, and CODES are the numbers of an ANSI color, as in 1;34. Files it
+// doesn't cover keep the built-in colors.
+
+// lsColors is a parsed LS_COLORS
+type lsColors struct {
+	types    map[string]string // escape sequences by file type key
+	suffixes map[string]string // escape sequences by lowercase name suffix
+}
+
+// parseLSColors parses an LS_COLORS value, skipping entries it can't make
+// sense of
+func parseLSColors(spec string) lsColors {
+	colors := lsColors{types: make(map[string]string), suffixes: make(map[string]string)}
+	for _, entry := range strings.Split(spec, ":") {
+		key, codes, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || codes == "" || strings.Trim(codes, "0123456789;") != "" {
+			// ln=target, coloring links as what they point to, is skipped
+			// here too
+			continue
+		}
+		sequence := "\033[" + codes + "m"
+		if suffix, ok := strings.CutPrefix(key, "*"); ok {
+			colors.suffixes[strings.ToLower(suffix)] = sequence
+		} else {
+			colors.types[key] = sequence
+		}
+	}
+	return colors
+}
+
+// color returns the escape sequence LS_COLORS gives a file, if it gives it
+// one. Like GNU ls, a regular file is colored by its suffix only when it
+// isn't executable. The fi entry for other regular files is left to the
+// caller, as the built-in extension colors come before it.
+func (c lsColors) color(name string, info fs.FileInfo) (string, bool) {
+	mode := info.Mode()
+	var key string
+	switch {
+	case mode.IsDir():
+		switch {
+		case mode&fs.ModeSticky != 0 && mode&0002 != 0:
+			key = "tw"
+		case mode&0002 != 0:
+			key = "ow"
+		case mode&fs.ModeSticky != 0:
+			key = "st"
+		}
+		if sequence, ok := c.types[key]; ok {
+			return sequence, true
+		}
+		key = "di"
+	case mode&fs.ModeSymlink != 0:
+		key = "ln"
+	case mode&fs.ModeNamedPipe != 0:
+		key = "pi"
+	case mode&fs.ModeSocket != 0:
+		key = "so"
+	case mode&fs.ModeCharDevice != 0:
+		key = "cd"
+	case mode&fs.ModeDevice != 0:
+		key = "bd"
+	case mode&fs.ModeSetuid != 0 && c.types["su"] != "":
+		key = "su"
+	case mode&fs.ModeSetgid != 0 && c.types["sg"] != "":
+		key = "sg"
+	case mode&0111 != 0:
+		key = "ex"
+	default:
+		return c.suffixColor(name)
+	}
+	sequence, ok := c.types[key]
+	return sequence, ok
+}
+
+// suffixColor returns the escape sequence for the longest suffix of name
+// that LS_COLORS gives one, ignoring case
+func (c lsColors) suffixColor(name string) (string, bool) {
+	name = strings.ToLower(name)
+	sequence, longest := "", 0
+	for suffix, s := range c.suffixes {
+		if len(suffix) > longest && strings.HasSuffix(name, suffix) {
+			sequence, longest = s, len(suffix)
+		}
+	}
+	return sequence, longest > 0
+}
+
+// lsTheme is how ls and tree style the names of the files they list
+type lsTheme struct {
+	colors lsColors
+	icons  iconSet
+}
+
+// lsTheme returns the theme for listing files, from LS_COLORS and
+// GOSHELL_ICONS
+func (s *Shell) lsTheme() lsTheme {
+	return lsTheme{colors: parseLSColors(s.env.Get("LS_COLORS")), icons: s.iconSet()}
+}
+
+// styledName returns a file's name as it is listed: colored, after its icon,
+// with a trailing slash for directories
+func (t lsTheme) styledName(name string, info fs.FileInfo) string {
+	kind := fileKindOf(name, info)
+	color, icon := kindColors[kind], t.icons.icon(kind)
+	if sequence, ok := t.colors.color(name, info); ok {
+		color = sequence
+	} else if sequence, ok := t.colors.types["fi"]; ok && color == Reset && info.Mode().IsRegular() {
+		color = sequence
+	}
+	if info.IsDir() {
+		name += "/" // Add trailing slash for directories
+	}
+	return color + icon + name + Reset
+}
@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+)
+
+// fakeInfo is a file's info with just a name and mode
+type fakeInfo struct {
+	name string
+	mode fs.FileMode
+}
+
+func (f fakeInfo) Name() string       { return f.name }
+func (f fakeInfo) Size() int64        { return 0 }
+func (f fakeInfo) Mode() fs.FileMode  { return f.mode }
+func (f fakeInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeInfo) Sys() any           { return nil }
+
+func TestLSColors(t *testing.T) {
+	theme := lsTheme{colors: parseLSColors("di=01;34:ln=target:ex=01;32:tw=30;42:*.tar=01;31:*.TAR.GZ=35:fi=33:bogus:no=x"), icons: iconSets["emoji"]}
+	for _, tt := range []struct {
+		info fakeInfo
+		want string
+	}{
+		{fakeInfo{"src", fs.ModeDir | 0755}, "\033[01;34m📁 src/"},
+		{fakeInfo{"tmp", fs.ModeDir | fs.ModeSticky | 0777}, "\033[30;42m📁 tmp/"},
+		{fakeInfo{"run.sh", 0755}, "\033[01;32m⚙️  run.sh"},
+		{fakeInfo{"backup.tar", 0644}, "\033[01;31m📦 backup.tar"},
+		{fakeInfo{"backup.tar.gz", 0644}, "\033[35m📦 backup.tar.gz"},
+		{fakeInfo{"BACKUP.TAR", 0644}, "\033[01;31m📦 BACKUP.TAR"},
+		// Links are colored as they point to with ln=target, which keeps the
+		// built-in color here
+		{fakeInfo{"link", fs.ModeSymlink | 0777}, Bold + Cyan + "🔗 link"},
+		// The built-in extension colors come before fi
+		{fakeInfo{"main.go", 0644}, Cyan + "🔹 main.go"},
+		{fakeInfo{"data.bin", 0644}, "\033[33m📄 data.bin"},
+	} {
+		if got, want := theme.styledName(tt.info.name, tt.info), tt.want+Reset; got != want {
+			t.Errorf("styledName(%s) = %q, want %q", tt.info.name, got, want)
+		}
+	}
+
+	colors := parseLSColors("")
+	if len(colors.types) != 0 || len(colors.suffixes) != 0 {
+		t.Errorf("parseLSColors(\"\") = %+v, want no entries", colors)
+	}
+}
+
+func TestIconSets(t *testing.T) {
+	shell := NewShell()
+	for _, tt := range []struct {
+		set  string
+		info fakeInfo
+		want string
+	}{
+		{"", fakeInfo{"main.go", 0644}, "🔹 main.go"},
+		{"emoji", fakeInfo{"src", fs.ModeDir | 0755}, "📁 src/"},
+		{"nerd", fakeInfo{"main.go", 0644}, "\ue627 main.go"},
+		{"nerd", fakeInfo{"src", fs.ModeDir | 0755}, "\uf07b src/"},
+		{"ascii", fakeInfo{"src", fs.ModeDir | 0755}, "d src/"},
+		{"ascii", fakeInfo{"main.go", 0644}, "- main.go"},
+		{"none", fakeInfo{"main.go", 0644}, "main.go"},
+		{"unknown", fakeInfo{"main.go", 0644}, "🔹 main.go"},
+	} {
+		shell.env.Set("GOSHELL_ICONS", tt.set)
+		if got := stripANSI(shell.lsTheme().styledName(tt.info.name, tt.info)); got != tt.want {
+			t.Errorf("GOSHELL_ICONS=%s: styledName(%s) = %q, want %q", tt.set, tt.info.name, got, tt.want)
+		}
+	}
+}
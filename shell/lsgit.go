@@ -0,0 +1,161 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// When the directory ls lists is in a git repository, each entry is marked
+// with its status, from a single git status call for the whole directory. A
+// subdirectory takes the most pressing status of the files below it. The
+// statuses are reused for gitStatusTTL, so listing a large repository over
+// and over, as ls -R and watch do, doesn't run git each time, but not once
+// the index has changed, so git add f && ls shows f staged.
+
+// gitStatusTTL is how long the statuses of a directory are reused
+const gitStatusTTL = 2 * time.Second
+
+// gitMark is a file's status as ls shows it, in increasing order of how
+// much it needs attention
+type gitMark int
+
+const (
+	gitClean gitMark = iota
+	gitIgnored
+	gitUntracked
+	gitStaged
+	gitModified
+	gitConflict
+)
+
+// String returns the marker shown for the status, colored
+func (m gitMark) String() string {
+	switch m {
+	case gitIgnored:
+		return White + "!" + Reset
+	case gitUntracked:
+		return Magenta + "?" + Reset
+	case gitStaged:
+		return Green + "+" + Reset
+	case gitModified:
+		return Yellow + "M" + Reset
+	case gitConflict:
+		return Bold + Red + "U" + Reset
+	}
+	return " "
+}
+
+// gitRoot returns the top directory of the git repository dir is in, or ""
+// if it isn't in one
+func gitRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// gitStatuses returns the status of each entry of dir that isn't clean, by
+// name, or nil if dir isn't in a git repository or git can't tell. They are
+// cached by repository, directory and state of the index.
+func (s *Shell) gitStatuses(dir string) map[string]gitMark {
+	root := gitRoot(dir)
+	if root == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	prefix, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil
+	}
+	prefix = filepath.ToSlash(prefix) + "/"
+	if prefix == "./" {
+		prefix = ""
+	}
+	key := root + "\x00" + abs + "\x00" + gitIndexStamp(root)
+	statuses, _ := s.gitStatus.Get(key, func() (map[string]gitMark, error) {
+		return s.readGitStatuses(abs, prefix), nil
+	})
+	return statuses
+}
+
+// gitIndexStamp returns the modification time and size of the index of the
+// repository at root, which change whenever git stages or commits anything.
+// It is "" where the index can't be found, as in a linked worktree.
+func gitIndexStamp(root string) string {
+	info, err := os.Stat(filepath.Join(root, ".git", "index"))
+	if err != nil {
+		return ""
+	}
+	return info.ModTime().String() + " " + strconv.FormatInt(info.Size(), 10)
+}
+
+// readGitStatuses runs git status in the directory abs, prefix being its
+// path below the repository's top directory, for the statuses of its entries.
+// It leaves the index alone, which git status would otherwise refresh.
+func (s *Shell) readGitStatuses(abs, prefix string) map[string]gitMark {
+	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain", "-z", "--ignored", "--", ".")
+	cmd.Dir = abs
+	cmd.Env = s.env.ToSlice()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	statuses := make(map[string]gitMark)
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 4 {
+			continue
+		}
+		code, path := field[:2], field[3:]
+		if code[0] == 'R' || code[0] == 'C' {
+			// The path it was renamed or copied from follows
+			i++
+		}
+		name, ok := strings.CutPrefix(path, prefix)
+		if !ok {
+			continue
+		}
+		// The entry is the path's first component below dir
+		name, _, _ = strings.Cut(strings.TrimSuffix(name, "/"), "/")
+		if mark := gitStatusMark(code); mark > statuses[name] {
+			statuses[name] = mark
+		}
+	}
+	return statuses
+}
+
+// gitStatusMark returns the status a two-letter code of git status
+// --porcelain stands for
+func gitStatusMark(code string) gitMark {
+	x, y := code[0], code[1]
+	switch {
+	case code == "!!":
+		return gitIgnored
+	case code == "??":
+		return gitUntracked
+	case x == 'U' || y == 'U' || code == "AA" || code == "DD":
+		return gitConflict
+	case y != ' ':
+		return gitModified
+	default:
+		return gitStaged
+	}
+}
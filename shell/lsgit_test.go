@@ -0,0 +1,124 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitStatuses(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(path, text string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("src/main.go", "package main\n")
+	write("src/util.go", "package main\n")
+	write("clean.txt", "clean\n")
+	write("changed.txt", "before\n")
+	write(".gitignore", "*.log\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	write("changed.txt", "after\n")
+	write("staged.txt", "new\n")
+	git("add", "staged.txt")
+	write("new.txt", "untracked\n")
+	write("debug.log", "ignored\n")
+	write("src/util.go", "package main\n\nfunc util() {}\n")
+
+	statuses := NewShell().gitStatuses(dir)
+	want := map[string]gitMark{
+		"changed.txt": gitModified,
+		"staged.txt":  gitStaged,
+		"new.txt":     gitUntracked,
+		"debug.log":   gitIgnored,
+		"src":         gitModified,
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("gitStatuses = %v, want %v", statuses, want)
+	}
+	for name, mark := range want {
+		if statuses[name] != mark {
+			t.Errorf("status of %s = %v, want %v", name, statuses[name], mark)
+		}
+	}
+
+	// Listing a subdirectory, names are relative to it
+	if got := NewShell().gitStatuses(filepath.Join(dir, "src")); len(got) != 1 || got["util.go"] != gitModified {
+		t.Errorf("gitStatuses(src) = %v, want util.go modified", got)
+	}
+	if got := NewShell().gitStatuses(t.TempDir()); got != nil {
+		t.Errorf("gitStatuses outside a repository = %v, want nil", got)
+	}
+
+	// The statuses are reused for a while, by directory
+	shell := NewShell()
+	shell.gitStatuses(dir)
+	write("clean.txt", "changed\n")
+	if got := shell.gitStatuses(dir)["clean.txt"]; got != gitClean {
+		t.Errorf("status of clean.txt within gitStatusTTL = %v, want the cached %v", got, gitClean)
+	}
+	if got := shell.gitStatuses(filepath.Join(dir, "src")); len(got) != 1 {
+		t.Errorf("gitStatuses(src) after gitStatuses(.) = %v, want its own", got)
+	}
+	shell.gitStatus.Clear()
+	if got := shell.gitStatuses(dir)["clean.txt"]; got != gitModified {
+		t.Errorf("status of clean.txt once stale = %v, want %v", got, gitModified)
+	}
+
+	// Staging a file changes the index, which ls doesn't wait out
+	var out bytes.Buffer
+	sh := New(Options{Stdout: &out, Stderr: &out, Env: os.Environ()})
+	line := "ls -l " + dir + " && git -C " + dir + " add clean.txt && ls -l " + dir
+	if status, err := sh.Run(context.Background(), line); err != nil || status != 0 {
+		t.Fatalf("%s = %d, %v: %s", line, status, err, out.String())
+	}
+	var marks []string
+	for _, row := range strings.Split(stripANSI(out.String()), "\n") {
+		if strings.HasSuffix(row, "clean.txt") {
+			marks = append(marks, row)
+		}
+	}
+	if len(marks) != 2 || !strings.Contains(marks[0], " M ") || !strings.Contains(marks[1], " + ") {
+		t.Errorf("clean.txt before and after git add = %q, want modified, then staged", marks)
+	}
+}
+
+func TestGitStatusMark(t *testing.T) {
+	for code, want := range map[string]gitMark{
+		" M": gitModified,
+		"M ": gitStaged,
+		"MM": gitModified,
+		"A ": gitStaged,
+		" D": gitModified,
+		"R ": gitStaged,
+		"UU": gitConflict,
+		"AA": gitConflict,
+		"??": gitUntracked,
+		"!!": gitIgnored,
+	} {
+		if got := gitStatusMark(code); got != want {
+			t.Errorf("gitStatusMark(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
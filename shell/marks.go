@@ -0,0 +1,200 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goshell/internal/lineedit"
+)
+
+func init() {
+	registerBuiltin("mark", "mark [NAME] | -d NAME...", "Bookmark the current directory under a name (-d: remove bookmarks)", builtinMark)
+	registerFlags("mark", Candidate{"-d", "Remove the named bookmarks"})
+	registerArgs("mark", completeMarks)
+	registerBuiltin("jump", "jump NAME[/PATH]", "Change to a bookmarked directory, or a path under it", builtinJump)
+	registerArgs("jump", completeMarks)
+	registerBuiltin("marks", "marks", "List the directory bookmarks", builtinMarks)
+}
+
+// marksFileName is the file in the home directory bookmarks are kept in, a
+// name and a directory per line separated by a tab
+const marksFileName = ".goshell_marks"
+
+// marksPath returns the file bookmarks are kept in
+func (s *Shell) marksPath() string {
+	return filepath.Join(s.homeDir(), marksFileName)
+}
+
+// loadMarks reads the bookmarks, by name. They are read afresh each time,
+// so bookmarks made in one session can be used straight away in another.
+func (s *Shell) loadMarks() (map[string]string, error) {
+	marks := make(map[string]string)
+	f, err := os.Open(s.marksPath())
+	if os.IsNotExist(err) {
+		return marks, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name, dir, ok := strings.Cut(scanner.Text(), "\t"); ok && name != "" {
+			marks[name] = dir
+		}
+	}
+	return marks, scanner.Err()
+}
+
+// saveMarks writes the bookmarks, sorted by name
+func (s *Shell) saveMarks(marks map[string]string) error {
+	var b strings.Builder
+	for _, name := range sortedMarks(marks) {
+		fmt.Fprintf(&b, "%s\t%s\n", name, marks[name])
+	}
+	return os.WriteFile(s.marksPath(), []byte(b.String()), 0600)
+}
+
+// sortedMarks returns the names of the bookmarks in order
+func sortedMarks(marks map[string]string) []string {
+	names := make([]string, 0, len(marks))
+	for name := range marks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// builtinMark bookmarks the working directory under a name, the
+// directory's own name if none is given, replacing any bookmark of that
+// name. -d removes bookmarks instead.
+func builtinMark(s *Shell, args []string, stdio Stdio) int {
+	var remove bool
+	flags := newFlagSet("mark")
+	flags.Bool(&remove, "d")
+	names, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if remove && len(names) == 0 || !remove && len(names) > 1 {
+		return flags.usage(stdio)
+	}
+	marks, err := s.loadMarks()
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "mark:", err)
+		return 1
+	}
+
+	status := 0
+	if remove {
+		for _, name := range names {
+			if _, ok := marks[name]; !ok {
+				fmt.Fprintf(stdio.Stderr, "mark: no bookmark '%s'\n", name)
+				status = 1
+			}
+			delete(marks, name)
+		}
+	} else {
+		dir, err := s.Getwd()
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "mark:", err)
+			return 1
+		}
+		name := filepath.Base(dir)
+		if len(names) == 1 {
+			name = names[0]
+		}
+		if err := checkMarkName(name); err != nil {
+			fmt.Fprintln(stdio.Stderr, "mark:", err)
+			return 1
+		}
+		marks[name] = dir
+	}
+	if err := s.saveMarks(marks); err != nil {
+		fmt.Fprintln(stdio.Stderr, "mark:", err)
+		return 1
+	}
+	return status
+}
+
+// checkMarkName reports whether a bookmark can have a name. A slash would
+// make jump read the rest as a path under it.
+func checkMarkName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/\t\n") {
+		return fmt.Errorf("invalid bookmark name '%s'", name)
+	}
+	return nil
+}
+
+// builtinJump changes to a bookmarked directory, as cd does. A path after
+// the name, as in jump proj/src, goes on into a directory under it.
+func builtinJump(s *Shell, args []string, stdio Stdio) int {
+	if len(args) != 2 {
+		return newFlagSet("jump").usage(stdio)
+	}
+	name, rest, _ := strings.Cut(args[1], "/")
+	marks, err := s.loadMarks()
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "jump:", err)
+		return 1
+	}
+	dir, ok := marks[name]
+	if !ok {
+		fmt.Fprintf(stdio.Stderr, "jump: no bookmark '%s'\n", name)
+		return 1
+	}
+	if rest != "" {
+		dir = filepath.Join(dir, rest)
+	}
+	if err := s.changeDir(dir, false); err != nil {
+		fmt.Fprintln(stdio.Stderr, "Error changing directory:", err)
+		return 1
+	}
+	return 0
+}
+
+// builtinMarks lists the bookmarks and their directories, aligned, with
+// directories that no longer exist dimmed on a terminal
+func builtinMarks(s *Shell, args []string, stdio Stdio) int {
+	marks, err := s.loadMarks()
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "marks:", err)
+		return 1
+	}
+	names := sortedMarks(marks)
+	width := 0
+	for _, name := range names {
+		width = max(width, lineedit.StringWidth(name))
+	}
+	color := isTerminal(stdio.Stdout)
+	for _, name := range names {
+		dir := s.tildePath(marks[name])
+		if color {
+			style := kindColors[kindDir]
+			if info, err := os.Stat(marks[name]); err != nil || !info.IsDir() {
+				style = Dim
+			}
+			dir = style + dir + Reset
+		}
+		fmt.Fprintf(stdio.Stdout, "%s  %s\n", padRight(name, width), dir)
+	}
+	return 0
+}
+
+// completeMarks completes bookmark names
+func completeMarks(s *Shell, word string) []Candidate {
+	marks, err := s.loadMarks()
+	if err != nil {
+		return nil
+	}
+	var candidates []Candidate
+	for _, name := range sortedMarks(marks) {
+		if s.matchWord(name, word) {
+			candidates = append(candidates, Candidate{Text: name, Description: s.tildePath(marks[name])})
+		}
+	}
+	return candidates
+}
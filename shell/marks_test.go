@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMarks(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "work", "project")
+	if err := os.MkdirAll(filepath.Join(project, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	if err := shell.changeDir(project, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"mark", "mark p", "cd " + home} {
+		if out, status := runCapture(t, shell, line); out != "" || status != 0 {
+			t.Fatalf("%s = %q (status %d)", line, out, status)
+		}
+	}
+	if out, _ := runCapture(t, shell, "marks"); out != "p        ~/work/project\nproject  ~/work/project\n" {
+		t.Errorf("marks = %q", out)
+	}
+
+	// A new session sees the bookmarks, and jump goes on into a path
+	shell = NewShell()
+	shell.env.Set("HOME", home)
+	if out, status := runCapture(t, shell, "jump p/src"); out != "" || status != 0 {
+		t.Errorf("jump p/src = %q (status %d)", out, status)
+	}
+	if dir, _ := shell.Getwd(); dir != filepath.Join(project, "src") {
+		t.Errorf("after jump p/src the directory is %s", dir)
+	}
+	runCapture(t, shell, "jump project")
+	if dir, _ := shell.Getwd(); dir != project || shell.env.Get("OLDPWD") != filepath.Join(project, "src") {
+		t.Errorf("after jump project the directory is %s, OLDPWD %s", dir, shell.env.Get("OLDPWD"))
+	}
+
+	if got := completeMarks(shell, "pr"); !reflect.DeepEqual(got, []Candidate{{"project", "~/work/project"}}) {
+		t.Errorf("completeMarks(pr) = %v", got)
+	}
+	if candidates, _ := shell.Complete([]rune("jump "), 5); len(candidates) != 2 {
+		t.Errorf("completing jump offers %v, want the two bookmarks", candidates)
+	}
+
+	if _, status := runCapture(t, shell, "mark -d p"); status != 0 {
+		t.Errorf("mark -d p exited with %d", status)
+	}
+	for _, line := range []string{"jump p", "mark -d p", "mark a/b", "mark -d", "jump"} {
+		if _, status := runCapture(t, shell, line); status != 1 {
+			t.Errorf("%s exited with %d, want 1", line, status)
+		}
+	}
+	if out, _ := runCapture(t, shell, "marks"); out != "project  ~/work/project\n" {
+		t.Errorf("marks after mark -d = %q", out)
+	}
+}
@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// menuMaxRows is the number of candidate rows shown at once; longer menus
+// scroll to keep the selection visible
+const menuMaxRows = 10
+
+// menuSelected highlights the selected candidate in reverse video
+const menuSelected = "\033[7m"
+
+// completionMenu is a navigable list of completion candidates drawn below
+// the prompt. Candidates are laid out in columns, filled top to bottom like
+// the listings of ls; candidates with descriptions get one row each.
+type completionMenu struct {
+	candidates []Candidate
+	start, end int // the word being completed, in runes
+	selected   int
+	labels     []string
+	colWidth   int
+	cols, rows int
+	offset     int // first visible row
+	described  bool
+}
+
+// newCompletionMenu lays out candidates for a terminal of the given width
+func newCompletionMenu(candidates []Candidate, start, end, width int) *completionMenu {
+	m := &completionMenu{candidates: candidates, start: start, end: end}
+	m.labels = make([]string, len(candidates))
+	for i, c := range candidates {
+		m.labels[i] = menuLabel(c.Text)
+		if n := len([]rune(m.labels[i])); n > m.colWidth {
+			m.colWidth = n
+		}
+		m.described = m.described || c.Description != ""
+	}
+	m.colWidth += 2
+	m.cols = 1
+	if !m.described && width/m.colWidth > 1 {
+		m.cols = width / m.colWidth
+	}
+	m.rows = (len(candidates) + m.cols - 1) / m.cols
+	return m
+}
+
+// menuLabel is how a candidate is shown: paths by their last component
+func menuLabel(text string) string {
+	trimmed := strings.TrimSuffix(text, "/")
+	if i := strings.LastIndex(trimmed, "/"); i >= 0 {
+		return text[i+1:]
+	}
+	return text
+}
+
+// move changes the selection by delta positions, wrapping at either end
+func (m *completionMenu) move(delta int) {
+	n := len(m.candidates)
+	m.selected = ((m.selected+delta)%n + n) % n
+}
+
+// moveColumn moves the selection to the neighbouring column, wrapping onto
+// the next or previous row at the edges
+func (m *completionMenu) moveColumn(dir int) {
+	next := m.selected + dir*m.rows
+	if next >= 0 && next < len(m.candidates) {
+		m.selected = next
+		return
+	}
+	row := ((m.selected % m.rows) + dir + m.rows) % m.rows
+	if dir > 0 {
+		m.selected = row
+		return
+	}
+	// The last column may be short, so find the rightmost cell in the row
+	for col := m.cols - 1; col >= 0; col-- {
+		if i := col*m.rows + row; i < len(m.candidates) {
+			m.selected = i
+			return
+		}
+	}
+}
+
+// render returns the menu's lines, truncated to width, with the selected
+// candidate highlighted
+func (m *completionMenu) render(width int) []string {
+	visible := min(m.rows, menuMaxRows)
+	row := m.selected % m.rows
+	if row < m.offset {
+		m.offset = row
+	} else if row >= m.offset+visible {
+		m.offset = row - visible + 1
+	}
+
+	var lines []string
+	for r := m.offset; r < m.offset+visible; r++ {
+		var b strings.Builder
+		used := 0
+		for col := 0; col < m.cols; col++ {
+			i := col*m.rows + r
+			if i >= len(m.candidates) {
+				break
+			}
+			cell := m.labels[i]
+			if m.described && m.candidates[i].Description != "" {
+				cell += strings.Repeat(" ", m.colWidth-len([]rune(cell))) + "-- " + m.candidates[i].Description
+			}
+			if cellRunes := []rune(cell); used+len(cellRunes) > width && width > 0 {
+				cell = string(cellRunes[:max(width-used, 0)])
+			}
+			if i == m.selected {
+				cell = menuSelected + cell + Reset
+			}
+			b.WriteString(cell)
+			used += m.colWidth
+			if col < m.cols-1 && i+m.rows < len(m.candidates) {
+				b.WriteString(strings.Repeat(" ", m.colWidth-len([]rune(m.labels[i]))))
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	if visible < m.rows {
+		lines = append(lines, fmt.Sprintf("%srows %d-%d of %d%s", Dim, m.offset+1, m.offset+visible, m.rows, Reset))
+	}
+	return lines
+}
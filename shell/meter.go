@@ -0,0 +1,176 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	registerBuiltin("meter", "meter [-s SIZE] [file]", "Show pipeline throughput on stderr", builtinMeter)
+	registerFlags("meter", Candidate{"-s", "Expected total size, for a percentage"})
+}
+
+// meterInterval is how often the progress line is redrawn
+const meterInterval = 250 * time.Millisecond
+
+// builtinMeter copies its input to stdout unchanged while drawing a progress
+// line on stderr, like pv. The total size, and with it the percentage and
+// ETA, is known when reading a regular file or when given with -s.
+func builtinMeter(s *Shell, args []string, stdio Stdio) int {
+	var total int64
+	input := stdio.Stdin
+
+	flags := newFlagSet("meter")
+	flags.Func(func(value string) error {
+		size, err := parseSize(value)
+		if err != nil {
+			return fmt.Errorf("invalid size: %s", value)
+		}
+		total = size
+		return nil
+	}, "s")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(files) > 1 {
+		return flags.usage(stdio)
+	}
+	if len(files) == 1 && files[0] != "-" {
+		f, err := os.Open(files[0])
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "meter:", err)
+			return 1
+		}
+		defer f.Close()
+		input = f
+	}
+
+	// A regular file tells us how much data to expect
+	if f, ok := input.(*os.File); ok && total == 0 {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			total = info.Size()
+		}
+	}
+
+	var transferred int64
+	counter := &countingWriter{w: stdio.Stdout, n: &transferred}
+
+	// Only draw progress when someone is watching
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	start := time.Now()
+	if isTerminal(stdio.Stderr) {
+		go func() {
+			defer close(finished)
+			ticker := time.NewTicker(meterInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					fmt.Fprint(stdio.Stderr, "\r"+meterLine(atomic.LoadInt64(&transferred), total, time.Since(start)))
+				case <-done:
+					fmt.Fprintln(stdio.Stderr, "\r"+meterLine(atomic.LoadInt64(&transferred), total, time.Since(start)))
+					return
+				}
+			}
+		}()
+	} else {
+		close(finished)
+	}
+
+	_, err = io.Copy(counter, input)
+	close(done)
+	<-finished
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "meter:", err)
+		return 1
+	}
+	return 0
+}
+
+// countingWriter forwards writes and atomically counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+// meterLine renders a single progress line. Lines for a known total include
+// a progress bar, percentage and ETA.
+func meterLine(transferred, total int64, elapsed time.Duration) string {
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(transferred) / elapsed.Seconds()
+	}
+
+	line := fmt.Sprintf("%s%9s%s %9s/s %s", Bold, formatSize(transferred), Reset, formatSize(int64(rate)), formatDuration(elapsed))
+	if total <= 0 {
+		return line
+	}
+
+	fraction := float64(transferred) / float64(total)
+	if fraction > 1 {
+		fraction = 1
+	}
+	const barWidth = 20
+	filled := int(fraction * barWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+
+	eta := "--:--"
+	if rate > 0 {
+		eta = formatDuration(time.Duration(float64(total-transferred) / rate * float64(time.Second)))
+	}
+	return fmt.Sprintf("%s [%s%s%s] %3.0f%% ETA %s", line, Green, bar, Reset, fraction*100, eta)
+}
+
+// formatSize renders a byte count using binary units
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration renders a duration as m:ss, or h:mm:ss for longer spans
+func formatDuration(d time.Duration) string {
+	secs := int64(d.Round(time.Second) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// parseSize parses a byte count with an optional K, M, G or T suffix
+// (powers of 1024). A trailing "B" or "iB" is accepted.
+func parseSize(str string) (int64, error) {
+	str = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(str), "B"), "I")
+	multiplier := int64(1)
+	if n := len(str); n > 0 {
+		if idx := strings.IndexByte("KMGT", str[n-1]); idx >= 0 {
+			multiplier = int64(1) << (10 * uint(idx+1))
+			str = str[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", str)
+	}
+	return int64(value * float64(multiplier)), nil
+}
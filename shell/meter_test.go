@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeterPassthrough(t *testing.T) {
+	input := strings.Repeat("data", 1000)
+	var out, errOut bytes.Buffer
+	status := builtinMeter(NewShell(), []string{"meter"}, Stdio{
+		Stdin:  strings.NewReader(input),
+		Stdout: &out,
+		Stderr: &errOut,
+	})
+	if status != 0 {
+		t.Fatalf("meter status = %d, want 0", status)
+	}
+	if out.String() != input {
+		t.Errorf("meter altered its input")
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("meter drew progress on a non-terminal: %q", errOut.String())
+	}
+}
+
+func TestMeterLine(t *testing.T) {
+	line := stripANSI(meterLine(512*1024, 1024*1024, 2*time.Second))
+	for _, want := range []string{"512.0KiB", "256.0KiB/s", "50%", "ETA 0:02"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("meterLine() = %q, missing %q", line, want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"100":  100,
+		"4K":   4096,
+		"1.5M": 1572864,
+		"2GiB": 2 << 30,
+		"3kb":  3072,
+	}
+	for input, want := range tests {
+		if got, err := parseSize(input); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", input, got, err, want)
+		}
+	}
+	if _, err := parseSize("lots"); err == nil {
+		t.Errorf("parseSize(lots) succeeded, want error")
+	}
+}
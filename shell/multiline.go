@@ -0,0 +1,94 @@
+package shell
+
+import (
+	"goshell/internal/lineedit"
+	"goshell/internal/parser"
+)
+
+// continuationPrompt is shown for the second and later lines of a command
+// that doesn't fit on one, such as after a trailing | or inside open quotes
+const continuationPrompt = "> "
+
+// multiLine holds a command being entered over several lines. The line
+// editor edits one line at a time, so the others are kept here, and Up and
+// Down swap the line being edited for its neighbours until the command is
+// complete.
+type multiLine struct {
+	lines []string // every line so far, including the one being edited
+	row   int      // index of the line being edited
+	first string   // the prompt's last line, shown when the first line is edited
+}
+
+// submit records text as the line being edited. It returns the whole
+// command once it is complete; otherwise the next line is edited, and the
+// result says so.
+func (e *lineEditor) submit(text string) (string, bool) {
+	m := &e.multi
+	if m.lines == nil {
+		m.lines = []string{text}
+	} else {
+		m.lines[m.row] = text
+	}
+	command := parser.JoinContinued(m.lines)
+	if _, err := parser.Parse(command); err != parser.ErrIncomplete {
+		m.lines = nil
+		return command, true
+	}
+	if m.row == len(m.lines)-1 {
+		m.lines = append(m.lines, "")
+	}
+	// Each line starts out in insert mode, like the first
+	e.vi = viState{register: e.vi.register}
+	e.editRow(m.row + 1)
+	return "", false
+}
+
+// continuing reports whether a command spanning several lines is being
+// entered
+func (e *lineEditor) continuing() bool {
+	return len(e.multi.lines) > 1
+}
+
+// nextLine returns the prompt and initial text for the line submit moved to
+func (e *lineEditor) nextLine() (string, string) {
+	return e.displayPrompt(), e.multi.lines[e.multi.row]
+}
+
+// editRow makes row the line being edited, setting the prompt for it
+func (e *lineEditor) editRow(row int) {
+	m := &e.multi
+	m.row = row
+	e.prompt = continuationPrompt
+	if row == 0 {
+		e.prompt = m.first
+	}
+}
+
+// moveRow handles Up and Down while a command spans several lines, keeping
+// the edited line and bringing in its neighbour. It reports false when the
+// command is on a single line, leaving the key to history browsing.
+func (e *lineEditor) moveRow(key lineedit.Key) bool {
+	m := &e.multi
+	var row int
+	switch {
+	case !e.continuing():
+		return false
+	case key == lineedit.KeyUp || key == lineedit.CtrlP:
+		row = m.row - 1
+	case key == lineedit.KeyDown || key == lineedit.CtrlN:
+		row = m.row + 1
+	default:
+		return false
+	}
+	if row < 0 || row >= len(m.lines) {
+		e.ed.Bell()
+		return true
+	}
+	line, _ := e.ed.Buffer()
+	m.lines[m.row] = string(line)
+	e.editRow(row)
+	text := []rune(m.lines[row])
+	e.ed.SetPrompt(e.displayPrompt())
+	e.ed.SetBuffer(text, len(text))
+	return true
+}
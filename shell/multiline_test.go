@@ -0,0 +1,41 @@
+package shell
+
+import (
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+func TestMultiLineEditing(t *testing.T) {
+	editor := newTestEditor(NewShell())
+	editor.startLine("goshell> ")
+
+	if _, complete := editor.submit("echo one |"); complete {
+		t.Fatal("a line ending in | was taken as complete")
+	}
+	if prompt, text := editor.nextLine(); prompt != continuationPrompt || text != "" {
+		t.Errorf("nextLine() = %q, %q; want the continuation prompt and no text", prompt, text)
+	}
+
+	// Up brings back the first line, keeping what was typed on the second
+	if line, _ := press(editor, []rune("tr a-z"), 6, lineedit.KeyUp); string(line) != "echo one |" || editor.prompt != "goshell> " {
+		t.Errorf("after Up the buffer is %q with prompt %q", string(line), editor.prompt)
+	}
+	// Down returns to it, and Enter on any line runs the whole command
+	if line, _ := press(editor, []rune("echo two |"), 10, lineedit.KeyDown); string(line) != "tr a-z" || editor.prompt != continuationPrompt {
+		t.Errorf("after Down the buffer is %q with prompt %q", string(line), editor.prompt)
+	}
+	command, complete := editor.submit("tr a-z A-Z")
+	if !complete || command != "echo two |\ntr a-z A-Z" {
+		t.Errorf("submit() = %q, %v; want the joined command", command, complete)
+	}
+	if editor.continuing() {
+		t.Error("the editor is still continuing after the command completed")
+	}
+
+	// Up on a single line is left to history browsing
+	editor.startLine("goshell> ")
+	if key, ok := editor.handleKey(lineedit.KeyUp); !ok || key != lineedit.KeyUp {
+		t.Errorf("Up on a single line gave %d, %v", key, ok)
+	}
+}
@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// When a command typed at the prompt runs for longer than
+// GOSHELL_NOTIFY_AFTER (30 seconds unless set; 0 turns it off) and the
+// terminal isn't the focused window, a desktop notification says it has
+// finished, with its exit status: through Notification Center on macOS,
+// notify-send (libnotify) elsewhere on Unix and a toast on Windows. Where
+// the focused window can't be found out, the notification is always sent.
+
+// defaultNotifyAfter is how long a command runs before its finishing is
+// notified, unless GOSHELL_NOTIFY_AFTER says
+const defaultNotifyAfter = 30 * time.Second
+
+// notifyIgnored are the commands whose finishing isn't notified, as they
+// run for as long as you work in them
+var notifyIgnored = []string{"emacs", "htop", "less", "man", "more", "mosh", "nano", "nvim", "screen", "ssh", "tmux", "top", "vi", "vim"}
+
+// notifyAfter returns how long a command must run before its finishing is
+// notified, or 0 if it never is
+func (s *Shell) notifyAfter() time.Duration {
+	spec, ok := s.env.Lookup("GOSHELL_NOTIFY_AFTER")
+	if !ok {
+		return defaultNotifyAfter
+	}
+	d, err := parseTimeout(spec)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// notifyFinished notifies that a command line has finished, if it took
+// long enough and the terminal isn't focused. The notification is sent in
+// the background, so the prompt isn't held up.
+func (s *Shell) notifyFinished(line string, status int, took time.Duration) {
+	after := s.notifyAfter()
+	if after <= 0 || took < after {
+		return
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if slices.Contains(notifyIgnored, filepath.Base(first)) {
+		return
+	}
+	title := "Command finished"
+	if status != 0 {
+		title = fmt.Sprintf("Command failed with status %d", status)
+	}
+	body := fmt.Sprintf("%s (%s)", line, took.Round(time.Second))
+	env := s.env.ToSlice()
+	go func() {
+		if focused, known := terminalFocused(env); known && focused {
+			return
+		}
+		sendNotification(env, title, body)
+	}()
+}
+
+// envValue returns the value of a variable in an environment list
+func envValue(env []string, name string) string {
+	for _, entry := range env {
+		if value, ok := strings.CutPrefix(entry, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// runNotifyTool runs a command of the desktop's, returning what it prints
+func runNotifyTool(env []string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
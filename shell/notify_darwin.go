@@ -0,0 +1,44 @@
+//go:build darwin
+
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// terminalApps maps the TERM_PROGRAM of macOS terminals to the name of
+// their application's process
+var terminalApps = map[string]string{
+	"Apple_Terminal": "Terminal",
+	"iTerm.app":      "iTerm2",
+	"WezTerm":        "wezterm-gui",
+	"ghostty":        "Ghostty",
+	"vscode":         "Code",
+}
+
+// terminalFocused reports whether the terminal's application is the one in
+// front, and whether that could be found out
+func terminalFocused(env []string) (focused, known bool) {
+	app := terminalApps[envValue(env, "TERM_PROGRAM")]
+	if app == "" {
+		return false, false
+	}
+	front, err := runNotifyTool(env, "osascript", "-e", `tell application "System Events" to get name of first process whose frontmost is true`)
+	if err != nil {
+		return false, false
+	}
+	return front == app, true
+}
+
+// sendNotification shows a notification in Notification Center
+func sendNotification(env []string, title, body string) error {
+	script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+	_, err := runNotifyTool(env, "osascript", "-e", script)
+	return err
+}
+
+// appleScriptString quotes text as an AppleScript string
+func appleScriptString(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
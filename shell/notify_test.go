@@ -0,0 +1,33 @@
+package shell
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotifyAfter(t *testing.T) {
+	for _, tc := range []struct {
+		env  []string
+		want time.Duration
+	}{
+		{nil, defaultNotifyAfter},
+		{[]string{"GOSHELL_NOTIFY_AFTER=10"}, 10 * time.Second},
+		{[]string{"GOSHELL_NOTIFY_AFTER=2m"}, 2 * time.Minute},
+		{[]string{"GOSHELL_NOTIFY_AFTER=0"}, 0},
+		{[]string{"GOSHELL_NOTIFY_AFTER=soon"}, 0},
+	} {
+		if got := New(Options{Env: tc.env}).notifyAfter(); got != tc.want {
+			t.Errorf("notifyAfter with %v = %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestEnvValue(t *testing.T) {
+	env := []string{"WINDOWID=123", "WINDOWID_X=4", "DISPLAY=:0"}
+	if got := envValue(env, "WINDOWID"); got != "123" {
+		t.Errorf("envValue(WINDOWID) = %q", got)
+	}
+	if got := envValue(env, "TERM_PROGRAM"); got != "" {
+		t.Errorf("envValue(TERM_PROGRAM) = %q", got)
+	}
+}
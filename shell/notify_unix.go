@@ -0,0 +1,35 @@
+//go:build unix && !darwin
+
+package shell
+
+import (
+	"strconv"
+	"strings"
+)
+
+// terminalFocused reports whether the terminal's X11 window, which X
+// terminals name in WINDOWID, is the active one, and whether that could be
+// found out. Under Wayland it can't.
+func terminalFocused(env []string) (focused, known bool) {
+	id, err := strconv.ParseUint(envValue(env, "WINDOWID"), 10, 64)
+	if err != nil || envValue(env, "DISPLAY") == "" || envValue(env, "WAYLAND_DISPLAY") != "" {
+		return false, false
+	}
+	// xprop prints _NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00004
+	out, err := runNotifyTool(env, "xprop", "-root", "_NET_ACTIVE_WINDOW")
+	i := strings.LastIndex(out, "0x")
+	if err != nil || i < 0 {
+		return false, false
+	}
+	active, err := strconv.ParseUint(out[i+2:], 16, 64)
+	if err != nil {
+		return false, false
+	}
+	return active == id, true
+}
+
+// sendNotification shows a desktop notification through libnotify
+func sendNotification(env []string, title, body string) error {
+	_, err := runNotifyTool(env, "notify-send", "--app-name=goshell", title, body)
+	return err
+}
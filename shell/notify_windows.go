@@ -0,0 +1,39 @@
+//go:build windows
+
+package shell
+
+var (
+	getForegroundWindow = user32.NewProc("GetForegroundWindow")
+	getConsoleWindow    = kernel32.NewProc("GetConsoleWindow")
+)
+
+// toastScript shows a toast notification with the title and body in
+// GOSHELL_NOTIFY_TITLE and GOSHELL_NOTIFY_BODY, as PowerShell's, since an
+// application needs to be registered to show its own
+const toastScript = `$ErrorActionPreference = 'Stop'
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:GOSHELL_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:GOSHELL_NOTIFY_BODY)) > $null
+$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+
+// terminalFocused reports whether the console window is in the foreground,
+// and whether that could be found out. Windows Terminal hosts consoles in
+// a window of its own, so there it can't.
+func terminalFocused(env []string) (focused, known bool) {
+	console, _, _ := getConsoleWindow.Call()
+	if console == 0 || envValue(env, "WT_SESSION") != "" {
+		return false, false
+	}
+	foreground, _, _ := getForegroundWindow.Call()
+	return foreground == console, true
+}
+
+// sendNotification shows a toast notification
+func sendNotification(env []string, title, body string) error {
+	env = append(env[:len(env):len(env)], "GOSHELL_NOTIFY_TITLE="+title, "GOSHELL_NOTIFY_BODY="+body)
+	_, err := runNotifyTool(env, "powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	return err
+}
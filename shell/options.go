@@ -0,0 +1,133 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("set", "set [-o|+o] [OPTION[=VALUE]]", "Turn shell options on (-o) or off (+o)", builtinSet)
+	registerFlags("set",
+		Candidate{"-o", "Turn an option on, or list the options"},
+		Candidate{"+o", "Turn an option off, or print the settings as commands"})
+}
+
+// shellOptions describes the options that set can change
+var shellOptions = map[string]string{
+	"autocd":       "a directory typed as a command is changed into, as if by cd",
+	"correct":      "offer to run the closest command when one isn't found",
+	"dryrun":       "command lines typed are shown, expanded, rather than run",
+	"emacs":        "emacs-style line editing (the default)",
+	"guard":        "ask before running commands guard rules match, such as rm -rf / (on by default)",
+	"histexpand":   "! refers to earlier commands, as in !! and !$ (on by default)",
+	"lastout":      "keep what command lines print, for lastout and $__LAST_OUT",
+	"logfile":      "log prompts, commands and their output to a file: set -o logfile=PATH, with strftime dates",
+	"posix":        "behave as a POSIX shell for sh scripts: split expansions, prefer standard utilities to builtins",
+	"posix_echo":   "echo takes no options and always interprets escapes, as POSIX specifies",
+	"sharehistory": "commands saved by other sessions join the history as they run",
+	"title":        "the terminal's title shows the directory, or the command running (on by default)",
+	"vi":           "vi-style line editing with insert and normal modes",
+}
+
+// valueOptions are the options set -o gives a value, as in set -o
+// logfile=PATH, with how to set and get it; set +o NAME clears the value
+var valueOptions = map[string]struct {
+	set   func(s *Shell, value string) error
+	value func(s *Shell) string
+}{
+	"logfile": {(*Shell).setLogfile, (*Shell).logfile},
+}
+
+// editingModes are the options choosing how the line is edited; turning
+// one on turns the others off
+var editingModes = []string{"emacs", "vi"}
+
+// setOption turns a shell option on or off
+func (s *Shell) setOption(name string, on bool) {
+	if !contains(editingModes, name) {
+		s.options[name] = on
+		return
+	}
+	for _, mode := range editingModes {
+		s.options[mode] = false
+	}
+	// Turning the current mode off falls back to the default
+	if !on {
+		name = "emacs"
+	}
+	s.options[name] = true
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinSet turns options on with -o NAME and off with +o NAME, and gives
+// those that take one a value with -o NAME=VALUE. -o alone lists the
+// options and +o alone prints the commands that restore them.
+func builtinSet(s *Shell, args []string, stdio Stdio) int {
+	names := make([]string, 0, len(shellOptions))
+	for name := range shellOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(args) == 2 && (args[1] == "-o" || args[1] == "+o") {
+		for _, name := range names {
+			value := ""
+			if option, ok := valueOptions[name]; ok {
+				value = option.value(s)
+			}
+			switch {
+			case args[1] == "+o" && value != "":
+				fmt.Fprintf(stdio.Stdout, "set -o %s\n", quoteIfNeeded(name+"="+value))
+			case value != "":
+				fmt.Fprintf(stdio.Stdout, "%-15s %s\n", name, value)
+			case args[1] == "+o" && s.options[name]:
+				fmt.Fprintf(stdio.Stdout, "set -o %s\n", name)
+			case args[1] == "+o":
+				fmt.Fprintf(stdio.Stdout, "set +o %s\n", name)
+			case s.options[name]:
+				fmt.Fprintf(stdio.Stdout, "%-15s on\n", name)
+			default:
+				fmt.Fprintf(stdio.Stdout, "%-15s off\n", name)
+			}
+		}
+		return 0
+	}
+	if len(args) != 3 || (args[1] != "-o" && args[1] != "+o") {
+		fmt.Fprintln(stdio.Stderr, "Usage: set -o|+o [OPTION[=VALUE]]")
+		return 1
+	}
+	name, value, hasValue := strings.Cut(args[2], "=")
+	if _, ok := shellOptions[name]; !ok {
+		fmt.Fprintf(stdio.Stderr, "set: unknown option: %s\n", name)
+		return 1
+	}
+	option, takesValue := valueOptions[name]
+	switch {
+	case !takesValue && hasValue:
+		fmt.Fprintf(stdio.Stderr, "set: %s takes no value\n", name)
+		return 1
+	case !takesValue:
+		s.setOption(name, args[1] == "-o")
+		return 0
+	case args[1] == "+o":
+		value = ""
+	case value == "":
+		fmt.Fprintf(stdio.Stderr, "set: %s takes a value: set -o %s=VALUE\n", name, name)
+		return 1
+	}
+	if err := option.set(s, value); err != nil {
+		fmt.Fprintf(stdio.Stderr, "set: %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
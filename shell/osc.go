@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The shell tells the terminal what it is doing with operating system
+// command (OSC) escape sequences, which terminals that don't know them
+// ignore. OSC 7 reports the working directory, so terminals such as
+// iTerm2, WezTerm and GNOME Terminal open new tabs in it and resolve the
+// relative paths they make clickable. OSC 133 marks where each prompt,
+// command line and command's output start and where the command finished,
+// with its exit status, so terminals can jump from prompt to prompt, select
+// a command's output and show how each command went.
+
+// The OSC 133 marks
+const (
+	markPromptStart = "\x1b]133;A\a"
+	markPromptEnd   = "\x1b]133;B\a" // where the command line starts
+	markOutput      = "\x1b]133;C\a"
+)
+
+// integrating reports whether the shell tells the terminal w what it is
+// doing. A nil w is not a terminal.
+func (s *Shell) integrating(w io.Writer) bool {
+	return w != nil && s.env.Get("TERM") != "dumb"
+}
+
+// reportDir reports the working directory to the terminal w with OSC 7,
+// when it has changed since last reported. In remote mode it is the remote
+// host's.
+func (s *Shell) reportDir(w io.Writer) {
+	if !s.integrating(w) {
+		return
+	}
+	uri := s.dirURL()
+	if uri == s.reportedDir {
+		return
+	}
+	s.reportedDir = uri
+	io.WriteString(w, "\x1b]7;"+uri+"\a")
+}
+
+// dirURL returns the working directory as the file URL OSC 7 reports
+func (s *Shell) dirURL() string {
+	var host, dir string
+	if s.remote != nil {
+		host, dir = s.remote.host, s.remote.cwd
+	} else {
+		host, _ = os.Hostname()
+		dir, _ = s.Getwd()
+		dir = filepath.ToSlash(dir)
+		// Windows paths such as C:/Users become /C:/Users
+		if !strings.HasPrefix(dir, "/") {
+			dir = "/" + dir
+		}
+	}
+	return (&url.URL{Scheme: "file", Host: host, Path: dir}).String()
+}
+
+// markPrompt marks the start of a prompt on the terminal w, returning the
+// prompt with the mark of its end, which the line editor draws with it
+func (s *Shell) markPrompt(w io.Writer, prompt string) string {
+	if !s.integrating(w) {
+		return prompt
+	}
+	io.WriteString(w, markPromptStart)
+	return prompt + markPromptEnd
+}
+
+// markOutputStart marks on the terminal w where the output of the command
+// line about to run starts
+func (s *Shell) markOutputStart(w io.Writer) {
+	if s.integrating(w) {
+		io.WriteString(w, markOutput)
+	}
+}
+
+// markFinished marks on the terminal w that the command line finished,
+// with its exit status
+func (s *Shell) markFinished(w io.Writer, status int) {
+	if s.integrating(w) {
+		fmt.Fprintf(w, "\x1b]133;D;%d\a", status)
+	}
+}
@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportDir(t *testing.T) {
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	dir := filepath.Join(t.TempDir(), "my dir")
+	os.Mkdir(dir, 0755)
+	shell := NewShell()
+	shell.env.Set("TERM", "xterm-256color")
+	if err := shell.changeDir(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+
+	var out bytes.Buffer
+	shell.reportDir(nil)
+	shell.reportDir(&out)
+	shell.reportDir(&out)
+	want := "\x1b]7;file://" + host + strings.ReplaceAll(filepath.ToSlash(dir), " ", "%20") + "\a"
+	if got := out.String(); got != want {
+		t.Errorf("reported %q, want %q once", got, want)
+	}
+
+	out.Reset()
+	shell.changeDir(filepath.Dir(dir), false)
+	shell.reportDir(&out)
+	if got, want := out.String(), "\x1b]7;"+shell.dirURL()+"\a"; got != want {
+		t.Errorf("reported %q after cd, want %q", got, want)
+	}
+}
+
+func TestShellIntegrationMarks(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("TERM", "xterm-256color")
+	var out bytes.Buffer
+	if got, want := shell.markPrompt(&out, "$ "), "$ \x1b]133;B\a"; got != want {
+		t.Errorf("markPrompt() = %q, want %q", got, want)
+	}
+	shell.markOutputStart(&out)
+	shell.markFinished(&out, 2)
+	if got, want := out.String(), "\x1b]133;A\a\x1b]133;C\a\x1b]133;D;2\a"; got != want {
+		t.Errorf("marks = %q, want %q", got, want)
+	}
+
+	out.Reset()
+	shell.env.Set("TERM", "dumb")
+	if got := shell.markPrompt(&out, "$ "); got != "$ " {
+		t.Errorf("markPrompt() on a dumb terminal = %q", got)
+	}
+	shell.markFinished(&out, 0)
+	shell.markFinished(nil, 0)
+	if out.Len() > 0 {
+		t.Errorf("marked a dumb terminal: %q", out.String())
+	}
+}
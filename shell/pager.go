@@ -0,0 +1,87 @@
+package shell
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"goshell/internal/lineedit"
+)
+
+// Builtins whose output can run long, such as ls, tree, history and env,
+// show it through a pager when it wouldn't fit on the terminal: $PAGER, or
+// less if it isn't set. Output that isn't going to a terminal is never
+// paged, and PAGER=cat turns paging off.
+
+// pagedOutput collects a builtin's output for the pager. It counts as a
+// terminal, so the builtin colors its output as it would for the screen.
+type pagedOutput struct {
+	bytes.Buffer
+}
+
+// paged runs a builtin's work with its output collected when stdout is a
+// terminal, then shows the output through the pager if it is longer than
+// the terminal is tall, or as it is if not
+func (s *Shell) paged(stdio Stdio, run func(stdio Stdio) int) int {
+	if !isTerminal(stdio.Stdout) {
+		return run(stdio)
+	}
+	var out pagedOutput
+	collected := stdio
+	collected.Stdout = &out
+	status := run(collected)
+
+	size, err := getTerminalSize()
+	if err != nil || outputRows(out.String(), size.Col) < size.Row || !s.runPager(stdio, out.Bytes()) {
+		stdio.Stdout.Write(out.Bytes())
+	}
+	return status
+}
+
+// outputRows returns how many rows of a terminal width columns wide text
+// takes, with long lines wrapping
+func outputRows(text string, width int) int {
+	rows := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		cols := lineedit.StringWidth(strings.TrimSuffix(line, "\n"))
+		rows += max(1, (cols+width-1)/max(width, 1))
+	}
+	return rows
+}
+
+// runPager shows text through the pager, returning false if it couldn't be
+// started. less is told to show colors unless LESS says otherwise.
+func (s *Shell) runPager(stdio Stdio, text []byte) bool {
+	pager := strings.Fields(s.env.Get("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = bytes.NewReader(text)
+	// The pager needs the terminal, so output being kept is kept here
+	cmd.Stdout = stdio.Stdout
+	for {
+		t, ok := cmd.Stdout.(teeOutput)
+		if !ok {
+			break
+		}
+		t.keep(text)
+		cmd.Stdout = t.passTo()
+	}
+	cmd.Stderr = stdio.Stderr
+	cmd.Env = s.env.ToSlice()
+	if s.env.Get("LESS") == "" {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if dir, err := s.Getwd(); err == nil {
+		cmd.Dir = dir
+	}
+	if err := cmd.Start(); err != nil {
+		return false
+	}
+	cmd.Wait()
+	return true
+}
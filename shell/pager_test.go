@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputRows(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"one\ntwo\n", 2},
+		{"no newline", 1},
+		{"\n\n", 2},
+		{strings.Repeat("x", 25) + "\n", 3},
+		{strings.Repeat("日", 10) + "\n", 2},
+		{Red + strings.Repeat("x", 10) + Reset + "\n", 1},
+	} {
+		if got := outputRows(tt.text, 10); got != tt.want {
+			t.Errorf("outputRows(%q, 10) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRunPager(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$LESS\" \"$@\" > " + dir + "/args\ncat > " + dir + "/input\n"
+	if err := os.WriteFile(filepath.Join(dir, "pager"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	shell.env.Set("PAGER", filepath.Join(dir, "pager")+" -x")
+	shell.env.Unset("LESS")
+
+	var out bytes.Buffer
+	stdio := Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	if !shell.runPager(stdio, []byte("line 1\nline 2\n")) {
+		t.Fatal("runPager didn't run the pager")
+	}
+	if input, _ := os.ReadFile(filepath.Join(dir, "input")); string(input) != "line 1\nline 2\n" {
+		t.Errorf("pager read %q, want the output", input)
+	}
+	if args, _ := os.ReadFile(filepath.Join(dir, "args")); string(args) != "FRX -x\n" {
+		t.Errorf("pager got %q, want LESS=FRX and its arguments", args)
+	}
+
+	shell.env.Set("PAGER", filepath.Join(dir, "missing"))
+	if shell.runPager(stdio, []byte("text\n")) {
+		t.Error("runPager with a missing pager = true, want false")
+	}
+
+	// Output that isn't going to a terminal isn't paged
+	status := shell.paged(stdio, func(stdio Stdio) int {
+		stdio.Stdout.Write([]byte("direct\n"))
+		return 3
+	})
+	if status != 3 || out.String() != "direct\n" {
+		t.Errorf("paged wrote %q (status %d), want the output as is", out.String(), status)
+	}
+}
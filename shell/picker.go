@@ -0,0 +1,149 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"goshell/internal/lineedit"
+
+	"golang.org/x/term"
+)
+
+// errNoTerminal is returned by a picker that has no terminal to show on
+var errNoTerminal = errors.New("choosing needs a terminal")
+
+// pick lets the user choose one of items on the terminal: with fzf when it
+// is installed, as Ctrl-R does, and otherwise with a menu below the cursor
+// that the arrow keys move through. It returns the index of the item
+// chosen, or -1 if the choice was cancelled.
+func (s *Shell) pick(items []string, stdio Stdio) (int, error) {
+	in, ok := stdio.Stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) || !isTerminal(stdio.Stdout) {
+		return -1, errNoTerminal
+	}
+	if path := s.fzfPath(); path != "" {
+		return s.pickWithFzf(path, items, stdio)
+	}
+	if s.readKey == nil {
+		return -1, errNoTerminal
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return -1, err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	width := 80
+	if size, err := getTerminalSize(); err == nil {
+		width = size.Col
+	}
+	return runPicker(stdio.Stdout, s.readKey, items, width)
+}
+
+// confirm asks a yes or no question on the terminal, reading a single key:
+// y means yes and any other key no
+func (s *Shell) confirm(question string, stdio Stdio) (bool, error) {
+	in, ok := stdio.Stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) || !isTerminal(stdio.Stderr) || s.readKey == nil {
+		return false, errNoTerminal
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return false, err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	fmt.Fprintf(stdio.Stderr, "%s [y/N] ", question)
+	key, err := s.readKey()
+	if err != nil {
+		return false, err
+	}
+	yes := key == 'y' || key == 'Y'
+	if yes {
+		io.WriteString(stdio.Stderr, "y\r\n")
+	} else {
+		io.WriteString(stdio.Stderr, "n\r\n")
+	}
+	return yes, nil
+}
+
+// pickWithFzf has fzf show items, returning the index of the one picked
+func (s *Shell) pickWithFzf(path string, items []string, stdio Stdio) (int, error) {
+	cmd := exec.Command(path, slices.Concat(fzfOptions, []string{"--read0", "--print0", "--no-multi", "--tiebreak=index"})...)
+	cmd.Stdin = strings.NewReader(strings.Join(items, "\x00"))
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = stdio.Stderr
+	cmd.Env = s.env.ToSlice()
+	if err := cmd.Run(); err != nil {
+		// fzf exits with 130 when the pick is cancelled, and 1 when nothing matched
+		return -1, nil
+	}
+	return slices.Index(items, strings.TrimSuffix(out.String(), "\x00")), nil
+}
+
+// runPicker draws items as a numbered menu below the cursor and reads keys
+// until one is chosen: Up and Down, Ctrl-P and Ctrl-N or k and j move the
+// highlight, Enter chooses it, a digit chooses that item and Esc, q, Ctrl-C
+// or Ctrl-G cancel. The menu is cleared away afterwards.
+func runPicker(out io.Writer, readKey func() (lineedit.Key, error), items []string, width int) (int, error) {
+	if len(items) == 0 {
+		return -1, nil
+	}
+	rows := min(len(items), menuMaxRows)
+	selected, offset := 0, 0
+	io.WriteString(out, "\033[?25l") // hide the cursor while the menu is up
+	defer io.WriteString(out, "\r\033[J\033[?25h")
+	for {
+		if selected < offset {
+			offset = selected
+		} else if selected >= offset+rows {
+			offset = selected - rows + 1
+		}
+		var b strings.Builder
+		for i := offset; i < offset+rows; i++ {
+			label := fitWidth(fmt.Sprintf("%2d  %s", i+1, items[i]), width-1)
+			if i == selected {
+				label = menuSelected + label + Reset
+			}
+			b.WriteString("\r\033[K" + label + "\r\n")
+		}
+		fmt.Fprintf(&b, "\033[%dA", rows)
+		io.WriteString(out, b.String())
+
+		key, err := readKey()
+		if err != nil {
+			return -1, err
+		}
+		switch key {
+		case lineedit.KeyUp, lineedit.CtrlP, 'k':
+			selected = (selected - 1 + len(items)) % len(items)
+		case lineedit.KeyDown, lineedit.CtrlN, lineedit.Tab, 'j':
+			selected = (selected + 1) % len(items)
+		case lineedit.Enter, lineedit.CtrlJ:
+			return selected, nil
+		case lineedit.Esc, lineedit.CtrlC, lineedit.CtrlG, 'q':
+			return -1, nil
+		default:
+			if key >= '1' && key <= '9' && int(key-'1') < len(items) {
+				return int(key - '1'), nil
+			}
+		}
+	}
+}
+
+// fitWidth cuts text down to at most width terminal columns
+func fitWidth(text string, width int) string {
+	if lineedit.StringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && lineedit.StringWidth(string(runes)) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
@@ -0,0 +1,115 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("please", "please [-y]", "Run the last command again with sudo, after asking", builtinPlease)
+	registerFlags("please", Candidate{"-y", "Run it without asking"})
+}
+
+// pleaseCommand is what please runs the last command under
+const pleaseCommand = "sudo"
+
+// sudoLine puts sudo in front of each pipeline of a command list, keeping
+// the words as they were typed, so their quoting and variables, and the
+// redirections, which the shell still opens
+func sudoLine(list *parser.List) *parser.List {
+	sudo := &parser.List{Ops: list.Ops}
+	for _, p := range list.Pipelines {
+		commands := make([]*parser.Command, len(p.Commands))
+		copy(commands, p.Commands)
+		first := commands[0]
+		commands[0] = &parser.Command{Words: append([]string{pleaseCommand}, first.Words...), Redirects: first.Redirects}
+		sudo.Pipelines = append(sudo.Pipelines, &parser.Pipeline{Commands: commands})
+	}
+	return sudo
+}
+
+// formatCommandList writes a parsed command list back as a command line
+func formatCommandList(list *parser.List) string {
+	var b strings.Builder
+	for i, p := range list.Pipelines {
+		if i > 0 {
+			if list.Ops[i-1] != ";" {
+				b.WriteByte(' ')
+			}
+			b.WriteString(list.Ops[i-1] + " ")
+		}
+		for j, c := range p.Commands {
+			if j > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(strings.Join(c.Words, " "))
+			for _, r := range c.Redirects {
+				b.WriteString(" " + r.Op)
+				if r.Target != "" {
+					b.WriteString(" " + r.Target)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// builtinPlease runs the last command again with sudo in front, having
+// shown it and, without -y, asked on the terminal first. The command is
+// parsed again, so each pipeline gets sudo and the redirections and quoting
+// are as they were.
+func builtinPlease(s *Shell, args []string, stdio Stdio) int {
+	var yes bool
+	flags := newFlagSet("please")
+	flags.Bool(&yes, "y")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) != 0 {
+		return flags.usage(stdio)
+	}
+
+	// The last command other than please itself, which is in the history
+	// when typed
+	line := ""
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if first, _, _ := strings.Cut(s.history[i].Command, " "); first != "please" {
+			line = s.history[i].Command
+			break
+		}
+	}
+	if line == "" {
+		fmt.Fprintln(stdio.Stderr, "please: no command to run again")
+		return 1
+	}
+	list, err := parser.Parse(s.expandAliases(line))
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "please: %s: %v\n", line, err)
+		return 1
+	}
+	for _, p := range list.Pipelines {
+		if p.Commands[0].Words[0] == pleaseCommand {
+			fmt.Fprintf(stdio.Stderr, "please: %s already runs with %s\n", line, pleaseCommand)
+			return 1
+		}
+	}
+
+	sudo := sudoLine(list)
+	text := formatCommandList(sudo)
+	if !yes {
+		ok, err := s.confirm("please: run "+text+"?", stdio)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "please: %v; run please -y to run %s\n", err, text)
+			return 1
+		}
+		if !ok {
+			return 1
+		}
+	} else {
+		fmt.Fprintln(stdio.Stderr, text)
+	}
+	return s.runList(sudo, stdio, false)
+}
@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"goshell/internal/parser"
+)
+
+func TestFormatSudoLine(t *testing.T) {
+	list, err := parser.Parse(`cat "/etc/my file" $HOME/x | grep -v '#' > out.txt 2>&1 && systemctl restart nginx; ls`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := formatCommandList(sudoLine(list))
+	want := `sudo cat "/etc/my file" $HOME/x | grep -v '#' > out.txt 2>&1 && sudo systemctl restart nginx; sudo ls`
+	if got != want {
+		t.Errorf("sudo line = %q, want %q", got, want)
+	}
+	if again := formatCommandList(list); !strings.HasPrefix(again, "cat ") {
+		t.Errorf("sudoLine changed the original: %q", again)
+	}
+}
+
+func TestPlease(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sudo is a shell script")
+	}
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "sudo"), []byte("#!/bin/sh\necho \"sudo ran $# args: $*\"\n"), 0755)
+	shell := New(Options{Env: []string{"PATH=" + bin + ":/bin:/usr/bin", "NAME=two words"}})
+
+	if out, status := runCapture(t, shell, "please -y"); status != 1 || !strings.Contains(out, "no command") {
+		t.Errorf("please with no history = %q (status %d)", out, status)
+	}
+	shell.AddToHistory(`touch "$NAME" 'it''s'`)
+	shell.AddToHistory("please")
+	out, status := runCapture(t, shell, "please -y")
+	want := "sudo touch \"$NAME\" 'it''s'\nsudo ran 3 args: touch two words its\n"
+	if status != 0 || out != want {
+		t.Errorf("please -y = %q (status %d), want %q", out, status, want)
+	}
+
+	// Without a terminal to ask on, it doesn't run
+	if out, status := runCapture(t, shell, "please"); status != 1 || strings.Contains(out, "sudo ran") {
+		t.Errorf("please without a terminal = %q (status %d)", out, status)
+	}
+
+	shell.AddToHistory("sudo ls")
+	if out, status := runCapture(t, shell, "please -y"); status != 1 || !strings.Contains(out, "already runs with sudo") {
+		t.Errorf("please after sudo = %q (status %d)", out, status)
+	}
+}
@@ -0,0 +1,198 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("plugins", "plugins", "List the loaded plugins and what they add", builtinPlugins)
+}
+
+// Plugins extend the shell without changing it. A plugin is an executable
+// in ~/.goshell_plugins, in any language, which the shell runs with a
+// method name as its first argument:
+//
+//	describe              print a JSON manifest of what the plugin adds
+//	run NAME ARGS...      run one of its builtins, on the command's streams
+//	complete COMMAND      print completions for COMMAND's arguments in place
+//	                      of file names, one per line with an optional tab
+//	                      and description, as complete -a '(command)'
+//	                      generators do
+//	segment NAME          print a prompt segment's text
+//	hook EVENT            handle an event, as an on-event handler does,
+//	                      with GOSHELL_EVENT_DATA set
+//
+// The manifest names what the plugin provides:
+//
+//	{"builtins": [{"name": "hello", "usage": "hello [NAME]", "summary": "Greet"}],
+//	 "completions": ["kubectl"], "segments": [{"name": "kube", "summary": "..."}],
+//	 "hooks": ["dir_changed"]}
+
+// pluginsDirName is the directory in the home directory plugins are kept in
+const pluginsDirName = ".goshell_plugins"
+
+// pluginTimeout bounds how long a plugin may take to describe itself or to
+// render a prompt segment, so a broken plugin can't hang the prompt
+const pluginTimeout = 2 * time.Second
+
+// plugin is a loaded plugin and what its manifest says it provides
+type plugin struct {
+	path     string
+	Builtins []struct {
+		Name    string `json:"name"`
+		Usage   string `json:"usage"`
+		Summary string `json:"summary"`
+	} `json:"builtins"`
+	Completions []string `json:"completions"`
+	Segments    []struct {
+		Name    string `json:"name"`
+		Summary string `json:"summary"`
+	} `json:"segments"`
+	Hooks []string `json:"hooks"`
+}
+
+// name is how the plugin is referred to: its file name
+func (p *plugin) name() string {
+	return filepath.Base(p.path)
+}
+
+// pluginsPath returns the directory plugins are loaded from
+func (s *Shell) pluginsPath() string {
+	return filepath.Join(s.homeDir(), pluginsDirName)
+}
+
+// LoadPlugins loads every plugin in the plugins directory, registering what
+// each provides. A plugin that fails to describe itself is reported and
+// skipped; a missing directory holds no plugins.
+func (s *Shell) LoadPlugins() error {
+	entries, err := os.ReadDir(s.pluginsPath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(s.pluginsPath(), entry.Name())
+		if info, err := os.Stat(path); err != nil || !isExecutable(info) {
+			continue
+		}
+		if err := s.loadPlugin(path); err != nil {
+			fmt.Fprintf(s.stderr, "goshell: plugin %s: %v\n", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// loadPlugin asks a plugin what it provides and registers it all. Builtins
+// already defined keep their definitions.
+func (s *Shell) loadPlugin(path string) error {
+	out, err := s.callPlugin(path, "describe")
+	if err != nil {
+		return err
+	}
+	p := &plugin{path: path}
+	if err := json.Unmarshal(out, p); err != nil {
+		return fmt.Errorf("bad manifest: %v", err)
+	}
+
+	registered := p.Builtins[:0]
+	for _, b := range p.Builtins {
+		if _, ok := builtins[b.Name]; ok {
+			fmt.Fprintf(s.stderr, "goshell: plugin %s: builtin '%s' is already defined\n", p.name(), b.Name)
+			continue
+		} else if b.Name == "" {
+			continue
+		}
+		usage := b.Usage
+		if usage == "" {
+			usage = b.Name
+		}
+		registerBuiltin(b.Name, usage, b.Summary, p.runBuiltin)
+		registered = append(registered, b)
+	}
+	p.Builtins = registered
+	for _, command := range p.Completions {
+		generator := shellQuote(path) + " complete " + shellQuote(command)
+		s.completions[command] = append(s.completions[command], &completionSpec{generator: generator, noFiles: true})
+	}
+	for _, segment := range p.Segments {
+		name := segment.Name
+		registerSegment(name, segment.Summary, func(s *Shell) string {
+			out, err := s.callPlugin(path, "segment", name)
+			if err != nil {
+				return ""
+			}
+			return strings.TrimRight(string(out), "\r\n")
+		})
+	}
+	hooks := p.Hooks[:0]
+	for _, event := range p.Hooks {
+		if _, ok := eventNames[event]; !ok {
+			fmt.Fprintf(s.stderr, "goshell: plugin %s: unknown event: %s\n", p.name(), event)
+			continue
+		}
+		s.addHandler(&eventHandler{event: event, command: shellQuote(path) + " hook " + event})
+		hooks = append(hooks, event)
+	}
+	p.Hooks = hooks
+	s.plugins = append(s.plugins, p)
+	return nil
+}
+
+// callPlugin runs a plugin method that answers on its standard output,
+// giving it pluginTimeout to do so
+func (s *Shell) callPlugin(path string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(s.context(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = s.env.ToSlice()
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s took longer than %v", args[0], pluginTimeout)
+	}
+	return out, err
+}
+
+// runBuiltin runs one of the plugin's builtins by running the plugin with
+// the run method, on the command's own streams
+func (p *plugin) runBuiltin(s *Shell, args []string, stdio Stdio) int {
+	return s.runSystem(append([]string{p.path, "run"}, args...), stdio)
+}
+
+// builtinPlugins lists the loaded plugins with the builtins, completions,
+// prompt segments and hooks each provides
+func builtinPlugins(s *Shell, args []string, stdio Stdio) int {
+	if len(args) != 1 {
+		return newFlagSet("plugins").usage(stdio)
+	}
+	plugins := append([]*plugin(nil), s.plugins...)
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].name() < plugins[j].name() })
+	for _, p := range plugins {
+		fmt.Fprintf(stdio.Stdout, "%s  %s\n", p.name(), s.tildePath(p.path))
+		var names []string
+		for _, b := range p.Builtins {
+			names = append(names, b.Name)
+		}
+		var segments []string
+		for _, segment := range p.Segments {
+			segments = append(segments, "{"+segment.Name+"}")
+		}
+		for _, item := range []struct {
+			label string
+			names []string
+		}{{"builtins", names}, {"completions", p.Completions}, {"segments", segments}, {"hooks", p.Hooks}} {
+			if len(item.names) > 0 {
+				fmt.Fprintf(stdio.Stdout, "  %-12s %s\n", item.label+":", strings.Join(item.names, " "))
+			}
+		}
+	}
+	return 0
+}
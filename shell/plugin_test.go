@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goshell/internal/parser"
+)
+
+// testPlugin is a plugin written as a shell script, answering each method
+const testPlugin = `#!/bin/sh
+case "$1" in
+describe)
+	echo '{"builtins": [{"name": "plugin-hello", "usage": "plugin-hello [NAME]", "summary": "Greet"}, {"name": "echo"}],
+	       "completions": ["deploy"], "segments": [{"name": "plugin-env", "summary": "The environment"}],
+	       "hooks": ["dir_changed"]}' ;;
+run)
+	shift 2
+	read -r greeting
+	echo "$greeting, ${1:-world}" ;;
+complete)
+	printf 'staging\tTest servers\nproduction\n' ;;
+segment)
+	echo "[$DEPLOY_ENV]" ;;
+hook)
+	echo "hook $2 $GOSHELL_EVENT_DATA" ;;
+esac
+`
+
+func TestPlugins(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, pluginsDirName)
+	os.Mkdir(dir, 0755)
+	if err := os.WriteFile(filepath.Join(dir, "deploy-tools"), []byte(testPlugin), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644)
+	os.WriteFile(filepath.Join(dir, "broken"), []byte("#!/bin/sh\necho nonsense\n"), 0755)
+	defer delete(builtins, "plugin-hello")
+	defer delete(promptSegments, "plugin-env")
+
+	shell := NewShell()
+	var errs strings.Builder
+	shell.stderr = &errs
+	shell.env.Set("HOME", home)
+	if err := shell.LoadPlugins(); err != nil {
+		t.Fatal(err)
+	}
+	if got := errs.String(); !strings.Contains(got, "plugin broken: bad manifest") || !strings.Contains(got, "builtin 'echo' is already defined") {
+		t.Errorf("plugin errors = %q", got)
+	}
+
+	list, _ := parser.Parse("echo Hello | plugin-hello Ada")
+	var out syncBuffer
+	shell.runList(list, Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}, false)
+	if got := out.String(); got != "Hello, Ada\n" {
+		t.Errorf("plugin builtin wrote %q", got)
+	}
+
+	candidates, _ := shell.Complete([]rune("deploy st"), 9)
+	if len(candidates) != 1 || candidates[0].Text != "staging" || candidates[0].Description != "Test servers" {
+		t.Errorf("plugin completions = %v", candidates)
+	}
+
+	shell.env.Set("DEPLOY_ENV", "prod")
+	shell.env.Set("GOSHELL_PROMPT", "{plugin-env}> ")
+	if got := shell.Prompt(); got != "[prod]> " {
+		t.Errorf("Prompt() with a plugin segment = %q", got)
+	}
+
+	if !shell.hasHandler("dir_changed") {
+		t.Error("the plugin's hook wasn't registered")
+	}
+	listing, _ := runCapture(t, shell, "plugins")
+	want := "deploy-tools  ~/" + pluginsDirName + "/deploy-tools\n" +
+		"  builtins:    plugin-hello\n" +
+		"  completions: deploy\n" +
+		"  segments:    {plugin-env}\n" +
+		"  hooks:       dir_changed\n"
+	if listing != want {
+		t.Errorf("plugins = %q, want %q", listing, want)
+	}
+}
@@ -0,0 +1,65 @@
+package shell
+
+// POSIX mode, turned on with set -o posix or by starting goshell --posix,
+// makes the shell behave as sh scripts expect rather than as is friendliest
+// at the prompt:
+//
+//   - Unquoted expansions are split into fields at the characters in IFS
+//     and their wildcards expanded, and an unquoted expansion that comes
+//     to nothing is no argument at all, so $FLAGS can hold several options
+//     and an empty $1 disappears.
+//   - echo follows POSIX, as with set -o posix_echo.
+//   - The builtins standing in for standard utilities, such as ls, cat
+//     and sort, give way to the utilities on PATH, so scripts get the
+//     options and output they were written for rather than colors and
+//     icons. Builtins are still run where there is no such utility.
+//   - ls and tree show no icons unless GOSHELL_ICONS asks for them, ls on
+//     PATH is run without --color, autocd is off and the line editor makes
+//     no autosuggestions.
+
+// defaultIFS separates fields when IFS isn't set. Its characters are the
+// IFS whitespace, of which a run is a single separator.
+const defaultIFS = " \t\n"
+
+// posixUtilities are the builtins sharing their name with a standard
+// utility, which POSIX mode runs in their place
+var posixUtilities = map[string]bool{
+	"cat":     true,
+	"clear":   true,
+	"cp":      true,
+	"cut":     true,
+	"df":      true,
+	"du":      true,
+	"env":     true,
+	"find":    true,
+	"ls":      true,
+	"mkdir":   true,
+	"mv":      true,
+	"rm":      true,
+	"sed":     true,
+	"sort":    true,
+	"stat":    true,
+	"tee":     true,
+	"timeout": true,
+	"touch":   true,
+	"tr":      true,
+	"tree":    true,
+	"uniq":    true,
+	"xargs":   true,
+}
+
+// lookupBuiltin returns the builtin a command name runs, if it runs one.
+// In remote mode the builtins working on files run on the remote host
+// instead, see remote.go.
+func (s *Shell) lookupBuiltin(name string) (*builtin, bool) {
+	b, ok := builtins[name]
+	if ok && s.remote != nil && remoteUtilities[name] {
+		return nil, false
+	}
+	if ok && s.options["posix"] && posixUtilities[name] {
+		if _, err := s.commandPath(name); err == nil {
+			return nil, false
+		}
+	}
+	return b, ok
+}
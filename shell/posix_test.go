@@ -0,0 +1,141 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPosixFieldSplitting(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	shell := New(Options{POSIX: true, Env: []string{"FLAGS=-l  -a ", "EMPTY=", "GLOB=" + dir + "/*.go", "LIST=x::y:"}})
+	for _, test := range []struct {
+		word string
+		want []string
+	}{
+		{"$FLAGS", []string{"-l", "-a"}},
+		{`"$FLAGS"`, []string{"-l  -a "}},
+		{"pre$FLAGS", []string{"pre-l", "-a"}},
+		{"$FLAGS.txt", []string{"-l", "-a", ".txt"}},
+		{"$EMPTY", nil},
+		{"$UNSET$EMPTY", nil},
+		{`"$EMPTY"`, []string{""}},
+		{"''$EMPTY", []string{""}},
+		{"$GLOB", []string{dir + "/a.go", dir + "/b.go"}},
+		{`"$GLOB"`, []string{dir + "/*.go"}},
+	} {
+		if got := shell.expandWord(test.word); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expandWord(%q) = %q, want %q", test.word, got, test.want)
+		}
+	}
+
+	shell.env.Set("IFS", ":")
+	if got, want := shell.expandWord("$LIST"), []string{"x", "", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with IFS=: expandWord($LIST) = %q, want %q", got, want)
+	}
+	shell.env.Set("IFS", "")
+	if got, want := shell.expandWord("$FLAGS"), []string{"-l  -a "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with an empty IFS expandWord($FLAGS) = %q, want %q", got, want)
+	}
+
+	// Outside POSIX mode expansions stay whole
+	shell.setOption("posix", false)
+	shell.env.Unset("IFS")
+	for word, want := range map[string][]string{"$FLAGS": {"-l  -a "}, "$EMPTY": {""}, "$GLOB": {dir + "/*.go"}} {
+		if got := shell.expandWord(word); !reflect.DeepEqual(got, want) {
+			t.Errorf("without POSIX mode expandWord(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestPosixBuiltins(t *testing.T) {
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "sort"), []byte("#!/bin/sh\necho system sort\n"), 0755)
+	shell := New(Options{POSIX: true, Env: []string{"PATH=" + bin + ":/bin:/usr/bin"}})
+
+	if out, _ := runCapture(t, shell, "sort"); out != "system sort\n" {
+		t.Errorf("sort in POSIX mode ran %q, want the system's", out)
+	}
+	if out, _ := runCapture(t, shell, `echo -n 'a\tb'`); out != "-n a\tb\n" {
+		t.Errorf("echo in POSIX mode printed %q", out)
+	}
+	if _, ok := shell.lookupBuiltin("cd"); !ok {
+		t.Error("cd isn't a builtin in POSIX mode")
+	}
+	if shell.iconSet().width != 0 {
+		t.Error("POSIX mode shows icons")
+	}
+
+	shell.env.Set("PATH", t.TempDir())
+	if _, ok := shell.lookupBuiltin("sort"); !ok {
+		t.Error("without a system sort the builtin isn't run")
+	}
+}
+
+func TestPosixUtilities(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lines"), []byte("a\na\nb\n"), 0644)
+	shell := New(Options{POSIX: true, Env: []string{"PATH=/bin:/usr/bin"}})
+	// Each with an option POSIX gives the utility
+	for _, test := range []struct {
+		utility, line, want string
+	}{
+		{"mkdir", "mkdir -m 700 " + dir + "/d", ""},
+		{"touch", "touch -t 200001021504 " + dir + "/f", ""},
+		{"cp", "cp -p " + dir + "/f " + dir + "/g", ""},
+		{"mv", "mv -f " + dir + "/g " + dir + "/h", ""},
+		{"rm", "rm -f " + dir + "/h " + dir + "/missing", ""},
+		{"cut", "echo abcdef | cut -c 2-4", "bcd\n"},
+		{"tr", "echo hello | tr -d l", "heo\n"},
+		{"uniq", "uniq -u " + dir + "/lines", "b\n"},
+		{"env", "env -i X=1", "X=1\n"},
+	} {
+		if _, err := shell.commandPath(test.utility); err != nil {
+			t.Logf("no %s on PATH", test.utility)
+			continue
+		}
+		if _, ok := shell.lookupBuiltin(test.utility); ok {
+			t.Errorf("%s runs the builtin in POSIX mode", test.utility)
+		}
+		if out, status := runCapture(t, shell, test.line); out != test.want || status != 0 {
+			t.Errorf("%s = %q (status %d), want %q", test.line, out, status, test.want)
+		}
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "d")); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("mkdir -m 700 made %v (%v)", info, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "f")); err != nil || info.ModTime().Year() != 2000 {
+		t.Errorf("touch -t made %v (%v)", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "h")); !os.IsNotExist(err) {
+		t.Errorf("rm -f left h: %v", err)
+	}
+}
+
+func TestPosixSystemLs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script on PATH")
+	}
+	// An ls printing its arguments, as ls on BSD and macOS would reject
+	// --color
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "ls"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shell := New(Options{POSIX: true, Env: []string{"PATH=" + bin + ":/bin:/usr/bin"}})
+	if out, status := runCapture(t, shell, "ls -l /"); out != "-l /\n" || status != 0 {
+		t.Errorf("ls in POSIX mode ran with %q (status %d), want -l /", out, status)
+	}
+
+	shell.setOption("posix", false)
+	if out, _ := runCapture(t, shell, "ls --bogus"); out != "--color=auto --bogus\n" {
+		t.Errorf("ls outside POSIX mode ran with %q, want --color=auto", out)
+	}
+}
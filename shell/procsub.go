@@ -0,0 +1,89 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"goshell/internal/parser"
+)
+
+// procSub is a running process substitution. The command runs against a
+// named pipe whose path replaces the >(cmd) or <(cmd) word.
+type procSub struct {
+	dir    string
+	path   string
+	output bool // true for >(cmd): the command reads what is written to path
+	done   chan struct{}
+}
+
+// isProcessSubstitution reports whether a raw word is >(cmd) or <(cmd)
+func isProcessSubstitution(word string) bool {
+	return (strings.HasPrefix(word, ">(") || strings.HasPrefix(word, "<(")) && strings.HasSuffix(word, ")")
+}
+
+// startProcessSubstitution creates a named pipe and starts the substituted
+// command in the background, connected to it. Output substitutions read
+// from the pipe; input substitutions write to it.
+func (s *Shell) startProcessSubstitution(word string, stdio Stdio) (*procSub, error) {
+	list, err := parser.Parse(word[2 : len(word)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "goshell-procsub")
+	if err != nil {
+		return nil, err
+	}
+	sub := &procSub{
+		dir:    dir,
+		path:   filepath.Join(dir, "fifo"),
+		output: word[0] == '>',
+		done:   make(chan struct{}),
+	}
+	if err := makeFifo(sub.path); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// It runs alongside the command, so in a subshell, as pipeline stages do
+	sh := s.fork()
+	go func() {
+		defer close(sub.done)
+		// Opening a FIFO blocks until the other end is opened too
+		flag := os.O_RDONLY
+		if !sub.output {
+			flag = os.O_WRONLY
+		}
+		f, err := os.OpenFile(sub.path, flag, 0)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		innerStdio := stdio
+		if sub.output {
+			innerStdio.Stdin = f
+		} else {
+			innerStdio.Stdout = f
+		}
+		sh.runList(list, innerStdio, false)
+	}()
+	return sub, nil
+}
+
+// finish waits for the substituted command and removes its pipe. If the
+// outer command never opened the pipe, the far end is opened here so the
+// background command is released instead of blocking forever.
+func (p *procSub) finish() {
+	flag := os.O_WRONLY | syscall.O_NONBLOCK
+	if !p.output {
+		flag = os.O_RDONLY | syscall.O_NONBLOCK
+	}
+	if f, err := os.OpenFile(p.path, flag, 0); err == nil {
+		f.Close()
+	}
+	<-p.done
+	os.RemoveAll(p.dir)
+}
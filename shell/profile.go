@@ -0,0 +1,113 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("pipeline", "pipeline --profile CMD [| CMD...]", "Run a pipeline and report per-stage resource use", builtinPipeline)
+	registerFlags("pipeline", Candidate{"--profile", "Report wall time, CPU, memory and output per stage"})
+}
+
+// profileModifier is the prefix that turns on profiling for a pipeline
+var profileModifier = []string{"pipeline", "--profile"}
+
+// stageProfile records the resources used by one pipeline stage
+type stageProfile struct {
+	start, end time.Time
+	cpu        time.Duration // user plus system time; external commands only
+	maxRSS     int64         // peak resident set size in bytes; external commands only
+	external   bool
+	written    int64 // bytes written to stdout
+}
+
+// stripProfileModifier removes a leading "pipeline --profile" from a
+// pipeline, reporting whether it was present
+func stripProfileModifier(p *parser.Pipeline) (*parser.Pipeline, bool) {
+	if len(p.Commands) == 0 {
+		return p, false
+	}
+	first := p.Commands[0]
+	if len(first.Words) < len(profileModifier) {
+		return p, false
+	}
+	for i, word := range profileModifier {
+		if first.Words[i] != word {
+			return p, false
+		}
+	}
+	stripped := &parser.Command{Words: first.Words[len(profileModifier):], Redirects: first.Redirects}
+	commands := append([]*parser.Command{stripped}, p.Commands[1:]...)
+	return &parser.Pipeline{Commands: commands}, true
+}
+
+// startProfile starts measuring a stage, counting what it writes to stdout
+func (st *stage) startProfile() {
+	st.profile = &stageProfile{start: time.Now()}
+	st.stdio.Stdout = &countingWriter{w: st.stdio.Stdout, n: &st.profile.written}
+}
+
+// finishProfile records the end of a profiled stage and, for external
+// commands, the resources the process used
+func (st *stage) finishProfile() {
+	if st.profile == nil {
+		return
+	}
+	st.profile.end = time.Now()
+	if st.cmd != nil && st.cmd.ProcessState != nil {
+		state := st.cmd.ProcessState
+		st.profile.external = true
+		st.profile.cpu = state.UserTime() + state.SystemTime()
+		st.profile.maxRSS = maxRSS(state)
+	}
+}
+
+// reportProfile writes a table of per-stage measurements. CPU time and
+// memory can't be separated from the shell's own for builtins, so they are
+// shown as "-".
+func reportProfile(stages []*stage, w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tCOMMAND\tWALL\tCPU\tMAX RSS\tOUTPUT")
+	for i, st := range stages {
+		prof := st.profile
+		if prof == nil || len(st.args) == 0 {
+			continue
+		}
+		name := strings.Join(st.args, " ")
+		if runes := []rune(name); len(runes) > 30 {
+			name = string(runes[:29]) + "…"
+		}
+		cpu, rss := "-", "-"
+		if prof.external {
+			cpu = formatProfileDuration(prof.cpu)
+			rss = formatSize(prof.maxRSS)
+		}
+		end := prof.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, name,
+			formatProfileDuration(end.Sub(prof.start)), cpu, rss, formatSize(prof.written))
+	}
+	tw.Flush()
+}
+
+// formatProfileDuration renders a duration in seconds with millisecond
+// precision
+func formatProfileDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64) + "s"
+}
+
+// builtinPipeline is only reached when "pipeline" isn't followed by a
+// command; "pipeline --profile CMD" is handled by the executor
+func builtinPipeline(s *Shell, args []string, stdio Stdio) int {
+	fmt.Fprintln(stdio.Stderr, "Usage: pipeline --profile CMD [| CMD...]")
+	return 1
+}
@@ -0,0 +1,239 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("project", "project [--leave CMD] MARKER CMD | -r MARKER", "Run a command on entering a project with a marker file", builtinProject)
+	registerFlags("project",
+		Candidate{"--leave", "Command to run on leaving the project"},
+		Candidate{"-r", "Remove the rule for a marker"})
+}
+
+// Project rules, usually set in ~/.goshellrc, activate a project when the
+// working directory enters one: a directory holding a marker such as
+// .nvmrc, .python-version or go.mod. The rule's command runs in the shell
+// with the project's root in GOSHELL_PROJECT and the marker's first line,
+// such as the version .nvmrc names, in GOSHELL_PROJECT_VALUE. What it
+// changes in the environment is put back on leaving the project, after the
+// rule's --leave command has run, so activating a virtualenv is as simple
+// as exporting VIRTUAL_ENV and PATH. The rules run from a chpwd handler,
+// which the first rule registers.
+
+// projectHandlerName names the chpwd handler that runs the project rules
+const projectHandlerName = "project rules"
+
+// projectRule is the command to activate projects with a marker
+type projectRule struct {
+	marker string
+	enter  string
+	leave  string
+	active *activeProject
+}
+
+// activeProject is a project a rule has activated
+type activeProject struct {
+	root, value string
+	// The values the variables activation changed had before; one that
+	// wasn't set has no entry in previous
+	changed  []string
+	previous map[string]string
+}
+
+// projectState holds the project rules and the chpwd handler running them
+type projectState struct {
+	rules   []*projectRule
+	handler *eventHandler
+}
+
+// findProjectRoot returns the directory nearest the working directory, in
+// it or above, holding a marker, and the marker's first line
+func (s *Shell) findProjectRoot(marker string) (root, value string) {
+	dir, err := s.Getwd()
+	if err != nil {
+		return "", ""
+	}
+	for {
+		path := filepath.Join(dir, marker)
+		if info, err := os.Stat(path); err == nil {
+			if info.Mode().IsRegular() {
+				data, _ := os.ReadFile(path)
+				value, _, _ = strings.Cut(string(data), "\n")
+			}
+			return dir, strings.TrimSpace(value)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// updateProjects activates and deactivates projects to match the working
+// directory. A project stays active throughout its tree; moving into
+// another project with the same marker deactivates the first. Projects are
+// deactivated in the opposite order to which their rules were added.
+func (s *Shell) updateProjects(stdio Stdio) {
+	if s.projects == nil {
+		return
+	}
+	rules := s.projects.rules
+	roots := make([]string, len(rules))
+	values := make([]string, len(rules))
+	for i, rule := range rules {
+		roots[i], values[i] = s.findProjectRoot(rule.marker)
+	}
+	for i := len(rules) - 1; i >= 0; i-- {
+		if active := rules[i].active; active != nil && active.root != roots[i] {
+			s.deactivateProject(rules[i], stdio)
+		}
+	}
+	for i, rule := range rules {
+		if rule.active == nil && roots[i] != "" {
+			s.activateProject(rule, roots[i], values[i], stdio)
+		}
+	}
+}
+
+// runProjectCommand runs a rule's command with the project's variables set
+func (s *Shell) runProjectCommand(command, root, value string, stdio Stdio) {
+	list, err := parser.Parse(command)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "project:", err)
+		return
+	}
+	s.env.Set("GOSHELL_PROJECT", root)
+	s.env.Set("GOSHELL_PROJECT_VALUE", value)
+	s.runList(list, stdio, false)
+	s.env.Unset("GOSHELL_PROJECT")
+	s.env.Unset("GOSHELL_PROJECT_VALUE")
+}
+
+// activateProject runs a rule's command for a project, noting what it
+// changes in the environment
+func (s *Shell) activateProject(rule *projectRule, root, value string, stdio Stdio) {
+	before := s.env.Vars()
+	s.runProjectCommand(rule.enter, root, value, stdio)
+	active := &activeProject{root: root, value: value, previous: make(map[string]string)}
+	after := s.env.Vars()
+	for name, now := range after {
+		if old, ok := before[name]; !ok || old != now {
+			active.changed = append(active.changed, name)
+			if ok {
+				active.previous[name] = old
+			}
+		}
+	}
+	for name, old := range before {
+		if _, ok := after[name]; !ok {
+			active.changed = append(active.changed, name)
+			active.previous[name] = old
+		}
+	}
+	slices.Sort(active.changed)
+	rule.active = active
+}
+
+// deactivateProject runs a rule's --leave command for the project it
+// activated, then puts back what activating it changed
+func (s *Shell) deactivateProject(rule *projectRule, stdio Stdio) {
+	active := rule.active
+	rule.active = nil
+	if rule.leave != "" {
+		s.runProjectCommand(rule.leave, active.root, active.value, stdio)
+	}
+	for _, name := range active.changed {
+		if old, ok := active.previous[name]; ok {
+			s.env.Set(name, old)
+		} else {
+			s.env.Unset(name)
+		}
+	}
+}
+
+// builtinProject adds a rule activating the projects with a marker, which
+// replaces the marker's rule if it has one, removes a marker's rule with
+// -r, or lists the rules and the projects they have activated
+func builtinProject(s *Shell, args []string, stdio Stdio) int {
+	var leave, remove string
+	flags := newFlagSet("project")
+	flags.String(&leave, "leave")
+	flags.String(&remove, "r")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if s.projects == nil {
+		s.projects = &projectState{}
+	}
+	ps := s.projects
+
+	switch {
+	case remove != "":
+		if len(operands) != 0 || leave != "" {
+			return flags.usage(stdio)
+		}
+		for i, rule := range ps.rules {
+			if rule.marker == remove {
+				if rule.active != nil {
+					s.deactivateProject(rule, stdio)
+				}
+				ps.rules = slices.Delete(ps.rules, i, i+1)
+				return 0
+			}
+		}
+		fmt.Fprintf(stdio.Stderr, "project: no rule for %s\n", remove)
+		return 1
+	case len(operands) == 0 && leave == "":
+		for _, rule := range ps.rules {
+			line := "project"
+			if rule.leave != "" {
+				line += " --leave " + shellQuote(rule.leave)
+			}
+			line += " " + shellQuote(rule.marker) + " " + shellQuote(rule.enter)
+			if rule.active != nil {
+				line += "  # active in " + s.tildePath(rule.active.root)
+			}
+			fmt.Fprintln(stdio.Stdout, line)
+		}
+		return 0
+	case len(operands) != 2:
+		return flags.usage(stdio)
+	}
+
+	rule := &projectRule{marker: operands[0], enter: operands[1], leave: leave}
+	for _, command := range []string{rule.enter, rule.leave} {
+		if _, err := parser.Parse(command); command != "" && err != nil {
+			fmt.Fprintln(stdio.Stderr, "project:", err)
+			return 1
+		}
+	}
+	if i := slices.IndexFunc(ps.rules, func(r *projectRule) bool { return r.marker == rule.marker }); i >= 0 {
+		if ps.rules[i].active != nil {
+			s.deactivateProject(ps.rules[i], stdio)
+		}
+		ps.rules[i] = rule
+	} else {
+		ps.rules = append(ps.rules, rule)
+	}
+
+	// The rules run from a chpwd handler, which is registered again if it
+	// was removed with on-event -r
+	if ps.handler == nil || !slices.Contains(s.events.handlers, ps.handler) {
+		ps.handler = &eventHandler{event: "chpwd", command: projectHandlerName, fn: func(s *Shell, args []string, stdio Stdio) int {
+			s.updateProjects(stdio)
+			return 0
+		}}
+		s.addHandler(ps.handler)
+	}
+	s.updateProjects(stdio)
+	return 0
+}
@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectRules(t *testing.T) {
+	root := t.TempDir()
+	web := filepath.Join(root, "web")
+	api := filepath.Join(root, "api")
+	os.MkdirAll(filepath.Join(web, "src"), 0755)
+	os.MkdirAll(api, 0755)
+	os.WriteFile(filepath.Join(web, ".nvmrc"), []byte("v20.11.0\n"), 0644)
+	os.WriteFile(filepath.Join(api, ".nvmrc"), []byte("18\n"), 0644)
+	os.WriteFile(filepath.Join(api, "go.mod"), []byte("module api\n"), 0644)
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	var out syncBuffer
+	shell := New(Options{Stdout: &out, Stderr: &out, Env: []string{"PATH=/bin", "HOME=" + root}})
+	shell.changeDir(root, false)
+
+	for _, line := range []string{
+		`project --leave 'echo leaving $GOSHELL_PROJECT' .nvmrc 'export NODE=$GOSHELL_PROJECT_VALUE PATH=/node/$GOSHELL_PROJECT_VALUE:$PATH'`,
+		`project go.mod 'export GOFLAGS=-mod=vendor'`,
+	} {
+		if got, status := runCapture(t, shell, line); got != "" || status != 0 {
+			t.Fatalf("%s = %q (status %d)", line, got, status)
+		}
+	}
+	shell.changeDir(filepath.Join(web, "src"), false)
+	if shell.env.Get("NODE") != "v20.11.0" || shell.env.Get("PATH") != "/node/v20.11.0:/bin" {
+		t.Errorf("in web NODE = %q, PATH = %q", shell.env.Get("NODE"), shell.env.Get("PATH"))
+	}
+	if _, ok := shell.env.Lookup("GOSHELL_PROJECT"); ok {
+		t.Error("GOSHELL_PROJECT is left set")
+	}
+
+	// Moving to another project leaves the first before entering it
+	shell.changeDir(api, false)
+	if shell.env.Get("NODE") != "18" || shell.env.Get("PATH") != "/node/18:/bin" || shell.env.Get("GOFLAGS") != "-mod=vendor" {
+		t.Errorf("in api NODE = %q, PATH = %q, GOFLAGS = %q", shell.env.Get("NODE"), shell.env.Get("PATH"), shell.env.Get("GOFLAGS"))
+	}
+	if !strings.Contains(out.String(), "leaving "+web) {
+		t.Errorf("the leave command printed %q", out.String())
+	}
+	if list, _ := runCapture(t, shell, "project"); !strings.Contains(list, "go.mod' 'export GOFLAGS=-mod=vendor'  # active in ~/api") {
+		t.Errorf("project listed %q", list)
+	}
+
+	// Leaving every project puts the environment back
+	shell.changeDir(root, false)
+	for _, name := range []string{"NODE", "GOFLAGS"} {
+		if _, ok := shell.env.Lookup(name); ok {
+			t.Errorf("%s is still set outside the projects", name)
+		}
+	}
+	if shell.env.Get("PATH") != "/bin" {
+		t.Errorf("PATH outside the projects = %q", shell.env.Get("PATH"))
+	}
+
+	if _, status := runCapture(t, shell, "project -r go.mod"); status != 0 {
+		t.Error("project -r go.mod failed")
+	}
+	shell.changeDir(api, false)
+	if _, ok := shell.env.Lookup("GOFLAGS"); ok {
+		t.Error("a removed rule still ran")
+	}
+}
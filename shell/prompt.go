@@ -0,0 +1,186 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"goshell/internal/parser"
+)
+
+// defaultPrompt is shown when no prompt command is configured
+const defaultPrompt = "goshell> "
+
+// Prompt returns the prompt to display before reading the next command. When
+// GOSHELL_PROMPT_COMMAND is set, rendering is delegated to that command (for
+// example "starship prompt") and its stdout is used as the prompt; failures
+// fall back to the default prompt. Otherwise GOSHELL_PROMPT, if set, is the
+// prompt, with its segments filled in; the default prompt names the remote
+// host in remote mode.
+func (s *Shell) Prompt() string {
+	promptCmd := s.env.Get("GOSHELL_PROMPT_COMMAND")
+	if promptCmd == "" {
+		if format := s.env.Get("GOSHELL_PROMPT"); format != "" {
+			return s.renderPrompt(format)
+		}
+		if s.remote != nil {
+			return "goshell@" + s.remote.host + "> "
+		}
+		return defaultPrompt
+	}
+	if prompt, err := s.runPromptCommand(promptCmd); err == nil {
+		return prompt
+	}
+	return defaultPrompt
+}
+
+// promptSegment is a piece of information GOSHELL_PROMPT can show, such as
+// the working directory, written {name} in it
+type promptSegment struct {
+	summary string
+	render  func(s *Shell) string // returns "" when there is nothing to show
+}
+
+// promptSegments maps segment names to segments. Segments register
+// themselves from init functions, as builtins do, and plugins add their own.
+var promptSegments = map[string]*promptSegment{}
+
+// registerSegment adds a segment GOSHELL_PROMPT can show
+func registerSegment(name, summary string, render func(s *Shell) string) {
+	promptSegments[name] = &promptSegment{summary: summary, render: render}
+}
+
+func init() {
+	registerSegment("cwd", "the working directory, with ~ for home", func(s *Shell) string {
+		if s.remote != nil {
+			return s.remote.tildePath(s.remote.cwd)
+		}
+		dir, _ := s.Getwd()
+		return s.tildePath(dir)
+	})
+	registerSegment("dir", "the working directory's name", func(s *Shell) string {
+		if s.remote != nil {
+			if dir := s.remote.tildePath(s.remote.cwd); dir == "~" || dir == "/" {
+				return dir
+			}
+			return path.Base(s.remote.cwd)
+		}
+		dir, _ := s.Getwd()
+		if s.tildePath(dir) == "~" {
+			return "~"
+		}
+		return filepath.Base(dir)
+	})
+	registerSegment("user", "the user name", func(s *Shell) string {
+		return s.env.Get("USER")
+	})
+	registerSegment("host", "the host name, up to the first dot, the remote host's in remote mode", func(s *Shell) string {
+		host, _ := os.Hostname()
+		if s.remote != nil {
+			host = s.remote.host
+		}
+		host, _, _ = strings.Cut(host, ".")
+		return host
+	})
+	registerSegment("status", "the last command's exit status, if it failed", func(s *Shell) string {
+		if s.lastStatus == 0 {
+			return ""
+		}
+		return Red + strconv.Itoa(s.lastStatus) + Reset
+	})
+	registerSegment("duration", "how long the last command took, if over two seconds", func(s *Shell) string {
+		if s.lastDuration < 2*time.Second {
+			return ""
+		}
+		return Yellow + s.lastDuration.Round(100*time.Millisecond).String() + Reset
+	})
+}
+
+// renderPrompt fills in the segments of a GOSHELL_PROMPT format: each
+// {name} becomes what that segment shows. A segment with nothing to show
+// takes a space after it away too, so GOSHELL_PROMPT='{status} {dir}> '
+// has no leading space after a command succeeds. Braces around anything but
+// a segment name are left as they are.
+func (s *Shell) renderPrompt(format string) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(format, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(format[open:], '}')
+		if end < 0 {
+			break
+		}
+		segment, ok := promptSegments[format[open+1:open+end]]
+		if !ok {
+			b.WriteString(format[:open+1])
+			format = format[open+1:]
+			continue
+		}
+		b.WriteString(format[:open])
+		format = format[open+end+1:]
+		if text := segment.render(s); text != "" {
+			b.WriteString(text)
+		} else {
+			format = strings.TrimPrefix(format, " ")
+		}
+	}
+	b.WriteString(format)
+	return b.String()
+}
+
+// runPromptCommand executes an external prompt renderer. The last command's
+// exit status, duration and the number of jobs are passed in GOSHELL_STATUS,
+// GOSHELL_DURATION_MS and GOSHELL_JOBS, and as the corresponding flags when
+// the renderer is starship.
+func (s *Shell) runPromptCommand(promptCmd string) (string, error) {
+	tokens, err := parser.Tokenize(promptCmd)
+	if err != nil {
+		return "", err
+	}
+	var words []string
+	for _, tok := range tokens {
+		words = append(words, tok.Text)
+	}
+	args := s.expandWords(words)
+	if len(args) == 0 {
+		return "", parser.ErrIncomplete
+	}
+
+	status := strconv.Itoa(s.lastStatus)
+	duration := strconv.FormatInt(s.lastDuration.Milliseconds(), 10)
+	jobs := "0"
+	width := 80
+	if ws, err := getTerminalSize(); err == nil {
+		width = ws.Col
+	}
+
+	if filepath.Base(args[0]) == "starship" {
+		args = append(args,
+			"--status="+status,
+			"--cmd-duration="+duration,
+			"--jobs="+jobs,
+			"--terminal-width="+strconv.Itoa(width),
+		)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(s.env.ToSlice(),
+		"GOSHELL_STATUS="+status,
+		"GOSHELL_DURATION_MS="+duration,
+		"GOSHELL_JOBS="+jobs,
+		"COLUMNS="+strconv.Itoa(width),
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out.String(), "\r\n"), nil
+}
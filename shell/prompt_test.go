@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrompt(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		shell := NewShell()
+		shell.env.Unset("GOSHELL_PROMPT_COMMAND")
+		if got := shell.Prompt(); got != defaultPrompt {
+			t.Errorf("Prompt() = %q, want %q", got, defaultPrompt)
+		}
+	})
+
+	t.Run("External Command", func(t *testing.T) {
+		shell := NewShell()
+		shell.lastStatus = 7
+		shell.lastDuration = 1500 * time.Millisecond
+		shell.env.Set("GOSHELL_PROMPT_COMMAND", `sh -c 'printf "[%s %s %s]> \n" "$GOSHELL_STATUS" "$GOSHELL_DURATION_MS" "$GOSHELL_JOBS"'`)
+		if got, want := shell.Prompt(), "[7 1500 0]> "; got != want {
+			t.Errorf("Prompt() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Failing Command Falls Back", func(t *testing.T) {
+		shell := NewShell()
+		shell.env.Set("GOSHELL_PROMPT_COMMAND", "goshell-no-such-prompt-command")
+		if got := shell.Prompt(); got != defaultPrompt {
+			t.Errorf("Prompt() = %q, want %q", got, defaultPrompt)
+		}
+	})
+}
+
+func TestPromptSegments(t *testing.T) {
+	home := t.TempDir()
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	shell.env.Set("USER", "ada")
+	if err := shell.changeDir(home, false); err != nil {
+		t.Fatal(err)
+	}
+
+	shell.env.Set("GOSHELL_PROMPT", "{user} {status} {cwd} {nope}> ")
+	if got, want := shell.Prompt(), "ada ~ {nope}> "; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+	shell.lastStatus = 3
+	os.Mkdir(filepath.Join(home, "src"), 0755)
+	shell.changeDir(filepath.Join(home, "src"), false)
+	if got, want := shell.Prompt(), "ada "+Red+"3"+Reset+" ~/src {nope}> "; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+	shell.env.Set("GOSHELL_PROMPT", "{dir}{ {dir}")
+	if got, want := shell.Prompt(), "src{ src"; got != want {
+		t.Errorf("Prompt() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadRC(t *testing.T) {
+	home := t.TempDir()
+	rc := "# settings\nexport RC_LOADED=yes\n\nexport RC_OTHER=$RC_LOADED\n"
+	if err := os.WriteFile(filepath.Join(home, rcFileName), []byte(rc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	if err := shell.LoadRC(); err != nil {
+		t.Fatalf("LoadRC() error = %v", err)
+	}
+	if got := shell.env.Get("RC_OTHER"); got != "yes" {
+		t.Errorf("RC_OTHER = %q, want %q", got, "yes")
+	}
+}
@@ -0,0 +1,504 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("where", "where FIELD OP VALUE", "Keep the records whose field compares true with a value", builtinWhere)
+	registerBuiltin("sort-by", "sort-by [-r] FIELD...", "Sort records by the values of fields", builtinSortBy)
+	registerFlags("sort-by", Candidate{"-r", "Sort in reverse"})
+	registerBuiltin("select", "select FIELD...", "Keep only the named fields of each record", builtinSelect)
+	for _, name := range []string{"ls", "where", "sort-by", "select"} {
+		registerRecords(name)
+	}
+}
+
+// Builtins can pass records down a pipeline rather than text, as in
+// ls | where 'size > 1M' | sort-by mtime. Between two builtins that
+// understand records the stages are joined by a channel of records in place
+// of a pipe, so values keep their types: numbers stay numbers and times
+// times. Where records meet text, at an external command, a file or the
+// terminal, they are written as JSON, an object per line, and a builtin
+// reading records from text takes JSON objects, one per line or in an
+// array, as ls --json or most APIs give them.
+
+// record is a row of structured data: named fields in order. Values are
+// strings, int64 or float64 numbers, bools, time.Time or nil.
+type record struct {
+	keys   []string
+	values map[string]any
+}
+
+// newRecord makes an empty record
+func newRecord() *record {
+	return &record{values: make(map[string]any)}
+}
+
+// set sets a field, adding it at the end if it is new
+func (r *record) set(key string, value any) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = value
+}
+
+// get returns a field's value and whether the record has it
+func (r *record) get(key string) (any, bool) {
+	value, ok := r.values[key]
+	return value, ok
+}
+
+// MarshalJSON writes the record as a JSON object with its fields in order
+func (r *record) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// registerRecords marks a builtin as reading or writing records, so the
+// builtins next to it in a pipeline that do too pass it records
+func registerRecords(name string) {
+	builtins[name].records = true
+}
+
+// isRecordBuiltin reports whether a command runs a builtin that passes
+// records
+func (s *Shell) isRecordBuiltin(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	b, ok := s.lookupBuiltin(args[0])
+	return ok && b.records
+}
+
+// recordBuffer is how many records a stage can send ahead of the next
+const recordBuffer = 64
+
+// linkRecords joins neighboring stages of a pipeline with a channel of
+// records where both run builtins that pass them and neither redirects the
+// stream between them, reporting whether it did
+func (s *Shell) linkRecords(from, to *stage, fromCmd, toCmd *parser.Command) bool {
+	if !s.isRecordBuiltin(from.args) || !s.isRecordBuiltin(to.args) {
+		return false
+	}
+	for _, r := range fromCmd.Redirects {
+		if r.Op == ">" || r.Op == ">>" {
+			return false
+		}
+	}
+	for _, r := range toCmd.Redirects {
+		if r.Op == "<" {
+			return false
+		}
+	}
+	records := make(chan *record, recordBuffer)
+	from.stdio.recordsOut = records
+	to.stdio.recordsIn = records
+	return true
+}
+
+// finishRecords ends a stage's record streams once its builtin returns: the
+// stage after learns there are no more records, and those the stage before
+// still sends are discarded so it doesn't wait forever
+func (stdio Stdio) finishRecords() {
+	if stdio.recordsOut != nil {
+		close(stdio.recordsOut)
+	}
+	if stdio.recordsIn != nil {
+		for range stdio.recordsIn {
+		}
+	}
+}
+
+// writeRecord sends a record down the pipeline, or writes it as a line of
+// JSON where the output is text
+func writeRecord(stdio Stdio, r *record) error {
+	if stdio.recordsOut != nil {
+		stdio.recordsOut <- r
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = stdio.Stdout.Write(append(data, '\n'))
+	return err
+}
+
+// readRecords calls fn with each record of the input: those the stage
+// before sends, or the JSON objects of text input. A JSON value that isn't
+// an object is an error.
+func readRecords(stdio Stdio, fn func(r *record) error) error {
+	if stdio.recordsIn != nil {
+		for r := range stdio.recordsIn {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dec := json.NewDecoder(bufio.NewReader(stdio.Stdin))
+	dec.UseNumber()
+	inArray := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading JSON records: %v", err)
+		}
+		switch tok {
+		case json.Delim('['):
+			if inArray {
+				return errors.New("reading JSON records: nested array")
+			}
+			inArray = true
+			continue
+		case json.Delim(']'):
+			inArray = false
+			continue
+		case json.Delim('{'):
+		default:
+			return fmt.Errorf("reading JSON records: %v isn't an object", tok)
+		}
+		r, err := decodeRecord(dec)
+		if err != nil {
+			return fmt.Errorf("reading JSON records: %v", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeRecord reads the rest of a JSON object whose opening brace has been
+// read, keeping its fields in order. Nested arrays and objects are kept as
+// decoded.
+func decodeRecord(dec *json.Decoder) (*record, error) {
+	r := newRecord()
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				value = i
+			} else {
+				value, _ = n.Float64()
+			}
+		}
+		r.set(key, value)
+	}
+	_, err := dec.Token() // the closing brace
+	return r, err
+}
+
+// recordOps maps the comparison operators where takes, including the
+// test-style ones that need no quoting, to their canonical forms
+var recordOps = map[string]string{
+	"==": "==", "=": "==", "-eq": "==",
+	"!=": "!=", "-ne": "!=",
+	"<": "<", "-lt": "<",
+	"<=": "<=", "-le": "<=",
+	">": ">", "-gt": ">",
+	">=": ">=", "-ge": ">=",
+	"=~": "=~", "!~": "!~",
+}
+
+// recordCondition is a where comparison of a field with a value
+type recordCondition struct {
+	field, op, value string
+	re               *regexp.Regexp // for =~ and !~
+}
+
+// parseCondition parses where's arguments, which may be given as separate
+// words or, for operators such as > that the shell would take for
+// redirections, quoted together
+func parseCondition(args []string) (*recordCondition, error) {
+	words := strings.Fields(strings.Join(args, " "))
+	if len(words) < 3 {
+		return nil, errors.New("want FIELD OP VALUE")
+	}
+	op, ok := recordOps[words[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator: %s", words[1])
+	}
+	c := &recordCondition{field: words[0], op: op, value: strings.Join(words[2:], " ")}
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile(c.value)
+		if err != nil {
+			return nil, err
+		}
+		c.re = re
+	}
+	return c, nil
+}
+
+// match reports whether a record passes the condition. The value is read as
+// the field's type: a number, with an optional K, M, G or T suffix for
+// sizes, for numbers; a date, or a duration counting back from now, for
+// times; and text otherwise. A record without the field doesn't pass.
+func (c *recordCondition) match(r *record) bool {
+	value, ok := r.get(c.field)
+	if !ok || value == nil {
+		return false
+	}
+	if c.re != nil {
+		return c.re.MatchString(formatValue(value)) == (c.op == "=~")
+	}
+	operand, ok := parseOperand(value, c.value)
+	if !ok {
+		return false
+	}
+	result := compareValues(value, operand)
+	switch c.op {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	default:
+		return result >= 0
+	}
+}
+
+// parseOperand reads text as a value of the same type as like, reporting
+// false if it can't be
+func parseOperand(like any, text string) (any, bool) {
+	switch like.(type) {
+	case int64, float64:
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			return n, true
+		}
+		n, err := parseSize(text)
+		return float64(n), err == nil
+	case bool:
+		b, err := strconv.ParseBool(text)
+		return b, err == nil
+	}
+	if _, ok := recordTime(like); ok {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", time.DateOnly} {
+			if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+				return t, true
+			}
+		}
+		if d, err := parseTimeout(text); err == nil {
+			return time.Now().Add(-d), true
+		}
+	}
+	return text, true
+}
+
+// recordTime returns a value as a time: a time itself, or text in the form
+// JSON gives times
+func recordTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// compareValues orders two values: numerically for numbers, in time for
+// times, false before true, and as text for anything else
+func compareValues(a, b any) int {
+	if x, ok := recordNumber(a); ok {
+		if y, ok := recordNumber(b); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	if x, ok := recordTime(a); ok {
+		if y, ok := recordTime(b); ok {
+			return x.Compare(y)
+		}
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case x:
+				return 1
+			}
+			return -1
+		}
+	}
+	return strings.Compare(formatValue(a), formatValue(b))
+}
+
+// recordNumber returns a numeric value as a float64
+func recordNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// formatValue renders a value as text
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case int64, float64, bool:
+		return fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// builtinWhere keeps the records that pass a comparison:
+// where size -gt 1M, where 'mtime > 2d', where name =~ '\.go$'
+func builtinWhere(s *Shell, args []string, stdio Stdio) int {
+	if len(args) < 2 {
+		return newFlagSet("where").usage(stdio)
+	}
+	c, err := parseCondition(args[1:])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "where:", err)
+		return 2
+	}
+	err = readRecords(stdio, func(r *record) error {
+		if c.match(r) {
+			return writeRecord(stdio, r)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "where:", err)
+		return 1
+	}
+	return 0
+}
+
+// builtinSortBy sorts records by fields
+func builtinSortBy(s *Shell, args []string, stdio Stdio) int {
+	var reverse bool
+	flags := newFlagSet("sort-by")
+	flags.Bool(&reverse, "r")
+	fields, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(fields) == 0 {
+		return flags.usage(stdio)
+	}
+	var records []*record
+	if err := readRecords(stdio, func(r *record) error {
+		records = append(records, r)
+		return nil
+	}); err != nil {
+		fmt.Fprintln(stdio.Stderr, "sort-by:", err)
+		return 1
+	}
+	sortRecords(records, fields, reverse)
+	for _, r := range records {
+		if err := writeRecord(stdio, r); err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+// sortRecords sorts records by fields, each later one breaking ties in the
+// one before, in the order compareValues gives. Records without a field
+// come after those with it.
+func sortRecords(records []*record, fields []string, reverse bool) {
+	slices.SortStableFunc(records, func(a, b *record) int {
+		for _, field := range fields {
+			x, xok := a.get(field)
+			y, yok := b.get(field)
+			var result int
+			switch {
+			case !xok || !yok:
+				result = cmp.Compare(boolInt(!xok), boolInt(!yok))
+			case reverse:
+				result = compareValues(y, x)
+			default:
+				result = compareValues(x, y)
+			}
+			if result != 0 {
+				return result
+			}
+		}
+		return 0
+	})
+}
+
+// boolInt is 1 for true and 0 for false
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// builtinSelect keeps only the named fields of each record, in the order
+// named
+func builtinSelect(s *Shell, args []string, stdio Stdio) int {
+	if len(args) < 2 {
+		return newFlagSet("select").usage(stdio)
+	}
+	err := readRecords(stdio, func(r *record) error {
+		selected := newRecord()
+		for _, field := range args[1:] {
+			if value, ok := r.get(field); ok {
+				selected.set(field, value)
+			}
+		}
+		return writeRecord(stdio, selected)
+	})
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "select:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordPipelines(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "big"), make([]byte, 2<<20), 0644)
+	os.WriteFile(filepath.Join(dir, "small.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "old"), nil, 0644)
+	old := time.Now().Add(-72 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "old"), old, old)
+	shell := NewShell()
+
+	for _, test := range []struct {
+		line, want string
+	}{
+		{"ls " + dir + " | where size -gt 1MB | select name", `{"name":"big"}`},
+		{"ls " + dir + " | where 'size < 1K' | sort-by -r size name | select name size", `{"name":"small.go","size":13}` + "\n" + `{"name":"old","size":0}`},
+		{"ls " + dir + " | where name =~ '\\.go$' | select name type", `{"name":"small.go","type":"file"}`},
+		{"ls " + dir + " | where mtime -lt 2d | select name", `{"name":"old"}`},
+		{"ls " + dir + " | where mtime -gt 2d | sort-by mtime name | select name", `{"name":"big"}` + "\n" + `{"name":"small.go"}`},
+		{"ls " + dir + " | where missing == 1", ""},
+	} {
+		out, status := runCapture(t, shell, test.line)
+		if status != 0 || strings.TrimSpace(out) != test.want {
+			t.Errorf("%s printed %q (status %d), want %q", test.line, out, status, test.want)
+		}
+	}
+}
+
+func TestRecordsFromText(t *testing.T) {
+	shell := NewShell()
+	for _, test := range []struct {
+		input, command, want string
+	}{
+		{`{"n":2,"s":"b"}` + "\n" + `{"n":10,"s":"a"}`, "sort-by n", `{"n":2,"s":"b"}` + "\n" + `{"n":10,"s":"a"}`},
+		{`[{"n":2,"s":"b"},{"n":10,"s":"a"}]`, "sort-by s", `{"n":10,"s":"a"}` + "\n" + `{"n":2,"s":"b"}`},
+		{`{"n":1.5,"on":true}{"n":3,"on":false}{"x":1}`, "where on == true", `{"n":1.5,"on":true}`},
+		{`{"z":1,"a":2}`, "select a z", `{"a":2,"z":1}`},
+		{`{"when":"2024-05-01T10:00:00Z"}{"when":"2023-01-01T00:00:00Z"}`, "where when -ge 2024-01-01", `{"when":"2024-05-01T10:00:00Z"}`},
+	} {
+		var out syncBuffer
+		stdio := Stdio{Stdin: strings.NewReader(test.input), Stdout: &out, Stderr: &out}
+		if status := shell.runArgs(strings.Fields(test.command), stdio); status != 0 || strings.TrimSpace(out.String()) != test.want {
+			t.Errorf("%s of %s printed %q (status %d), want %q", test.command, test.input, out.String(), status, test.want)
+		}
+	}
+
+	var out syncBuffer
+	stdio := Stdio{Stdin: strings.NewReader("not json"), Stdout: &out, Stderr: &out}
+	if status := shell.runArgs([]string{"select", "a"}, stdio); status != 1 {
+		t.Errorf("select of text that isn't JSON = %d, want 1", status)
+	}
+	if status := shell.runArgs([]string{"where", "a", "~", "1"}, stdio); status != 2 {
+		t.Errorf("where with an unknown operator = %d, want 2", status)
+	}
+}
@@ -0,0 +1,652 @@
+package shell
+
+import (
+	"cmp"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+func init() {
+	registerBuiltin("rsh", "rsh [-d] [[USER@]HOST[:PORT]]", "Run commands on another host over SSH, or show or end the connection", builtinRsh)
+	registerFlags("rsh", Candidate{"-d", "Disconnect, running commands here again"})
+}
+
+// In remote mode, started with rsh HOST or goshell --remote HOST, the
+// commands typed run on another host over one SSH connection, each in a
+// session of its own started in the remote working directory. Builtins
+// still run here, but cd and pwd work on the remote working directory, ls
+// lists remote directories over SFTP, and ~ and wildcards expand against
+// the remote files. The builtins standing in for standard utilities that
+// work on files, such as rm, cp and find, give way to the remote host's, so
+// they act on the files the names expanded to. Redirections open local
+// files and the environment isn't sent over. rsh -d ends the connection and
+// goes back to running commands here.
+
+// remoteDialTimeout bounds connecting to a remote host
+const remoteDialTimeout = 15 * time.Second
+
+// remoteInputPoll is how often input for a remote command is checked for
+// whether the command has finished while waiting for it
+const remoteInputPoll = 50 * time.Millisecond
+
+// remoteKeyFiles are the private keys in ~/.ssh tried, after the agent's
+var remoteKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// remoteUtilities are the builtins that work on files and share their name
+// with a standard utility, which remote mode runs on the remote host in
+// their place
+var remoteUtilities = map[string]bool{
+	"cat":   true,
+	"cp":    true,
+	"cut":   true,
+	"df":    true,
+	"du":    true,
+	"find":  true,
+	"mkdir": true,
+	"mv":    true,
+	"rm":    true,
+	"sed":   true,
+	"sort":  true,
+	"stat":  true,
+	"tee":   true,
+	"touch": true,
+	"tr":    true,
+	"tree":  true,
+	"uniq":  true,
+}
+
+// remoteSignals are the numbers of the signals a remote command can be
+// killed by, for its exit status
+var remoteSignals = map[string]int{"HUP": 1, "INT": 2, "QUIT": 3, "KILL": 9, "PIPE": 13, "TERM": 15}
+
+// remoteSession is the connection to the host of remote mode
+type remoteSession struct {
+	target string // as given to rsh
+	host   string // the host name alone, for the prompt
+	client *ssh.Client
+	sftp   *ssh.Session // running the SFTP subsystem for files
+	files  *sftpClient
+	home   string // the remote user's home directory
+	cwd    string // the remote working directory
+	oldCwd string // the one before cd last changed it
+}
+
+// remoteCommand is an external command running on the remote host
+type remoteCommand struct {
+	session *ssh.Session
+	done    chan struct{} // closed once it has finished, to stop forwarding input
+	stopped chan struct{} // closed once input is no longer forwarded
+	restore func()        // puts the local terminal back, if it was made raw
+}
+
+// parseRemoteTarget splits [USER@]HOST[:PORT] into the user, the host and
+// the address to dial, defaulting to the given user and port 22
+func parseRemoteTarget(target, defaultUser string) (login, host, addr string, err error) {
+	login, host = defaultUser, target
+	if i := strings.LastIndexByte(target, '@'); i >= 0 {
+		login, host = target[:i], target[i+1:]
+	}
+	port := "22"
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	if host == "" || login == "" {
+		return "", "", "", fmt.Errorf("%s: expected [USER@]HOST[:PORT]", target)
+	}
+	return login, host, net.JoinHostPort(host, port), nil
+}
+
+// dialRemote connects to a host and starts SFTP on it, asking on the
+// terminal for anything authentication needs and whether to trust a host
+// key it hasn't seen
+func (s *Shell) dialRemote(target string, stdio Stdio) (*remoteSession, error) {
+	defaultUser := s.env.Get("USER")
+	if u, err := user.Current(); defaultUser == "" && err == nil {
+		defaultUser = u.Username
+	}
+	login, host, addr, err := parseRemoteTarget(target, defaultUser)
+	if err != nil {
+		return nil, err
+	}
+	knownHosts := filepath.Join(s.homeDir(), ".ssh", "known_hosts")
+	check, algorithms, err := s.knownHostKeys(knownHosts, addr)
+	if err != nil {
+		return nil, err
+	}
+	var agentConn net.Conn // the SSH agent's, if authenticating asked it
+	config := &ssh.ClientConfig{
+		User:              login,
+		Auth:              s.remoteAuth(login, host, stdio, &agentConn),
+		HostKeyCallback:   s.remoteHostKey(knownHosts, check, stdio),
+		HostKeyAlgorithms: algorithms,
+		Timeout:           remoteDialTimeout,
+	}
+	client, err := ssh.Dial("tcp", addr, config)
+	// The agent is only needed to authenticate
+	if agentConn != nil {
+		agentConn.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &remoteSession{target: target, host: host, client: client}
+	if r.sftp, err = client.NewSession(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	w, err := r.sftp.StdinPipe()
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	out, err := r.sftp.StdoutPipe()
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	if err := r.sftp.RequestSubsystem("sftp"); err != nil {
+		r.close()
+		return nil, fmt.Errorf("starting sftp: %v", err)
+	}
+	if r.files, err = newSFTPClient(out, w); err != nil {
+		r.close()
+		return nil, fmt.Errorf("starting sftp: %v", err)
+	}
+	if r.home, err = r.files.Realpath("."); err != nil {
+		r.close()
+		return nil, fmt.Errorf("finding the home directory: %v", err)
+	}
+	r.cwd = r.home
+	return r, nil
+}
+
+// close ends the connection
+func (r *remoteSession) close() {
+	if r.sftp != nil {
+		r.sftp.Close()
+	}
+	r.client.Close()
+}
+
+// remoteAuth returns the ways of authenticating to a host tried in turn:
+// the keys of the SSH agent and the usual key files, asking for a key's
+// passphrase if it has one, then a password or the questions the server
+// asks. The client tries each kind once, so the keys are one method. The
+// connection to the agent is left in agentConn for the caller to close once
+// authenticated, as its keys sign through it.
+func (s *Shell) remoteAuth(login, host string, stdio Stdio, agentConn *net.Conn) []ssh.AuthMethod {
+	keys := func() ([]ssh.Signer, error) {
+		var signers []ssh.Signer
+		if sock := s.env.Get("SSH_AUTH_SOCK"); sock != "" && *agentConn == nil {
+			if conn, err := net.Dial("unix", sock); err == nil {
+				*agentConn = conn
+				if agentKeys, err := agent.NewClient(conn).Signers(); err == nil {
+					signers = append(signers, agentKeys...)
+				}
+			}
+		}
+		for _, name := range remoteKeyFiles {
+			file := filepath.Join(s.homeDir(), ".ssh", name)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			signer, err := ssh.ParsePrivateKey(data)
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				passphrase, perr := readSecret(fmt.Sprintf("Enter passphrase for key '%s': ", s.tildePath(file)), stdio)
+				if perr != nil {
+					continue
+				}
+				signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+			}
+			if err != nil {
+				fmt.Fprintf(stdio.Stderr, "rsh: %s: %v\n", s.tildePath(file), err)
+				continue
+			}
+			signers = append(signers, signer)
+		}
+		return signers, nil
+	}
+	return []ssh.AuthMethod{
+		ssh.PublicKeysCallback(keys),
+		ssh.PasswordCallback(func() (string, error) {
+			return readSecret(fmt.Sprintf("%s@%s's password: ", login, host), stdio)
+		}),
+		ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			if instruction != "" {
+				fmt.Fprintln(stdio.Stderr, instruction)
+			}
+			answers := make([]string, len(questions))
+			for i, question := range questions {
+				answer, err := readSecret(question, stdio)
+				if err != nil {
+					return nil, err
+				}
+				answers[i] = answer
+			}
+			return answers, nil
+		}),
+	}
+}
+
+// readSecret asks for a password or passphrase on the terminal, without
+// showing what is typed
+func readSecret(prompt string, stdio Stdio) (string, error) {
+	in, ok := stdio.Stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) {
+		return "", errNoTerminal
+	}
+	fmt.Fprint(stdio.Stderr, prompt)
+	secret, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(stdio.Stderr)
+	return string(secret), err
+}
+
+// knownHostKeys reads the known hosts file, returning its check of host
+// keys and the algorithms of the keys it knows for addr, so the server is
+// asked for a key of one of those rather than one of another kind it hasn't
+// recorded. A missing file knows no hosts; one that can't be read is an
+// error rather than taken to know none.
+func (s *Shell) knownHostKeys(file, addr string) (ssh.HostKeyCallback, []string, error) {
+	check, err := knownhosts.New(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %v", s.tildePath(file), unwrapPathError(err))
+	}
+	// A key no host has is refused with the keys addr does have
+	probe, _ := ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(addr, &net.TCPAddr{}, probe), &keyErr) {
+		return check, nil, nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		switch typ := known.Key.Type(); typ {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			if !slices.Contains(algorithms, typ) {
+				algorithms = append(algorithms, typ)
+			}
+		}
+	}
+	return check, algorithms, nil
+}
+
+// remoteHostKey checks a server's key against the known hosts file. A key
+// for a host it doesn't know is shown and, if accepted on the terminal,
+// added to the file; one that differs from the key recorded is refused.
+func (s *Shell) remoteHostKey(file string, check ssh.HostKeyCallback, stdio Stdio) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("the host key of %s has changed; if that is expected, remove its old key from %s", hostname, s.tildePath(file))
+		}
+		question := fmt.Sprintf("rsh: %s is not a known host; its %s key fingerprint is %s. Connect?", hostname, key.Type(), ssh.FingerprintSHA256(key))
+		ok, err := s.confirm(question, stdio)
+		if err != nil {
+			return fmt.Errorf("%s is not a known host, and there is no terminal to accept its key on", hostname)
+		}
+		if !ok {
+			return fmt.Errorf("the host key of %s was not accepted", hostname)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{hostname}, key))
+		return err
+	}
+}
+
+// path resolves a remote path against the remote working directory
+func (r *remoteSession) path(name string) string {
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return path.Join(r.cwd, name)
+}
+
+// tildePath returns a remote path with the remote home directory shortened
+// to ~, for showing it
+func (r *remoteSession) tildePath(dir string) string {
+	if dir == r.home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(dir, r.home+"/"); ok && r.home != "/" {
+		return "~/" + rest
+	}
+	return dir
+}
+
+func (r *remoteSession) readDir(dir string) ([]lsEntry, error) {
+	infos, err := r.files.ReadDir(r.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]lsEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = lsEntry{info.name, info}
+	}
+	return entries, nil
+}
+
+func (r *remoteSession) stat(dir, name string) (fs.FileInfo, error) {
+	return r.files.Stat(path.Join(r.path(dir), name))
+}
+
+func (r *remoteSession) readlink(dir, name string) (string, error) {
+	return r.files.Readlink(path.Join(r.path(dir), name))
+}
+
+// chdir changes the remote working directory, as cd does
+func (r *remoteSession) chdir(dir string) error {
+	resolved, err := r.files.Realpath(r.path(dir))
+	if err != nil {
+		return err
+	}
+	info, err := r.files.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory")
+	}
+	r.oldCwd, r.cwd = r.cwd, resolved
+	return nil
+}
+
+// glob returns the remote files matching a wildcard pattern, in order, as
+// filepath.Glob does for local ones
+func (r *remoteSession) glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	matches := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		matches = []string{"/"}
+	}
+	for _, part := range strings.Split(pattern, "/") {
+		if part == "" {
+			continue
+		}
+		var next []string
+		for _, dir := range matches {
+			if !strings.ContainsAny(part, "*?[\\") {
+				if _, err := r.files.Lstat(r.path(path.Join(dir, part))); err == nil {
+					next = append(next, path.Join(dir, part))
+				}
+				continue
+			}
+			infos, err := r.files.ReadDir(r.path(cmp.Or(dir, ".")))
+			if err != nil {
+				continue
+			}
+			var names []string
+			for _, info := range infos {
+				if ok, _ := path.Match(part, info.name); ok {
+					names = append(names, path.Join(dir, info.name))
+				}
+			}
+			slices.Sort(names)
+			next = append(next, names...)
+		}
+		matches = next
+	}
+	if len(matches) == 1 && (matches[0] == "" || matches[0] == "/") {
+		return nil, nil
+	}
+	return matches, nil
+}
+
+// commandLine is the command the remote shell runs for a command's words:
+// each quoted as needed, run in the remote working directory
+func (r *remoteSession) commandLine(args []string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = quoteIfNeeded(arg)
+	}
+	return "cd " + quoteIfNeeded(r.cwd) + " && " + strings.Join(words, " ")
+}
+
+// startRemote starts a stage's command on the remote host. A command run
+// at the terminal gets a terminal there too, with the local one made raw so
+// keys such as Ctrl-C reach it as they are typed.
+func (s *Shell) startRemote(st *stage) error {
+	session, err := s.remote.client.NewSession()
+	if err != nil {
+		return err
+	}
+	rc := &remoteCommand{session: session, done: make(chan struct{})}
+	session.Stdout = st.stdio.Stdout
+	session.Stderr = st.stdio.Stderr
+	in, isFile := st.stdio.Stdin.(*os.File)
+	if isFile && term.IsTerminal(int(in.Fd())) && isTerminal(st.stdio.Stdout) {
+		width, height := 80, 24
+		if w, h, err := term.GetSize(int(in.Fd())); err == nil {
+			width, height = w, h
+		}
+		termType := cmp.Or(s.env.Get("TERM"), "xterm")
+		if err := session.RequestPty(termType, height, width, ssh.TerminalModes{}); err != nil {
+			session.Close()
+			return err
+		}
+		if state, err := term.MakeRaw(int(in.Fd())); err == nil {
+			rc.restore = func() { term.Restore(int(in.Fd()), state) }
+		}
+	}
+	switch {
+	case isFile:
+		w, err := session.StdinPipe()
+		if err != nil {
+			rc.finish()
+			return err
+		}
+		rc.stopped = make(chan struct{})
+		go func() {
+			defer close(rc.stopped)
+			forwardInput(w, in, rc.done)
+		}()
+	case st.stdio.Stdin != nil:
+		session.Stdin = st.stdio.Stdin
+	}
+	if err := session.Start(s.remote.commandLine(st.args)); err != nil {
+		rc.finish()
+		return err
+	}
+	st.remote = rc
+	return nil
+}
+
+// finish stops forwarding input to a remote command and puts the terminal
+// back once it has ended
+func (rc *remoteCommand) finish() {
+	close(rc.done)
+	if rc.stopped != nil {
+		<-rc.stopped
+	}
+	if rc.restore != nil {
+		rc.restore()
+	}
+	rc.session.Close()
+}
+
+// waitRemote waits for a stage's remote command to finish. Ctrl-C, for a
+// command without a terminal there, or running out of time signals it and
+// closes its session.
+func (s *Shell) waitRemote(st *stage) error {
+	rc := st.remote
+	defer rc.finish()
+	finished := make(chan error, 1)
+	go func() { finished <- rc.session.Wait() }()
+	var expired <-chan struct{}
+	if st.limit != nil {
+		expired = st.limit.ctx.Done()
+	}
+	for {
+		select {
+		case err := <-finished:
+			return err
+		case <-s.interrupts:
+			rc.session.Signal(ssh.SIGINT)
+			rc.session.Close()
+		case <-expired:
+			expired = nil
+			rc.session.Signal(ssh.SIGTERM)
+			rc.session.Close()
+		}
+	}
+}
+
+// forwardInput copies a remote command's input from a file until the file
+// ends or the command finishes, then closes w. It waits for input to be
+// ready before each read, so that a command that has finished doesn't
+// take the next key typed at the terminal.
+func forwardInput(w io.WriteCloser, in *os.File, done <-chan struct{}) {
+	defer w.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		ready, err := waitReadable(in, remoteInputPoll)
+		if err != nil {
+			return
+		}
+		if !ready {
+			continue
+		}
+		n, err := in.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// runRemote runs a command on the remote host, for builtins that leave
+// what they can't do to the host's own version
+func (s *Shell) runRemote(args []string, stdio Stdio) int {
+	st := &stage{args: args, stdio: stdio}
+	if err := s.startRemote(st); err != nil {
+		fmt.Fprintf(stdio.Stderr, "Error executing command: %v\n", err)
+		return 127
+	}
+	if err := s.waitRemote(st); err != nil {
+		return exitStatus(err)
+	}
+	return 0
+}
+
+// remoteExitStatus is the exit status of a remote command that failed,
+// 128 and the signal's number if a signal killed it
+func remoteExitStatus(err *ssh.ExitError) int {
+	if status := err.ExitStatus(); status >= 0 {
+		return status
+	}
+	if n, ok := remoteSignals[err.Signal()]; ok {
+		return 128 + n
+	}
+	return 1
+}
+
+// remoteCd changes the remote working directory, to the remote home
+// directory without an operand and back to the one before with -
+func (s *Shell) remoteCd(operands []string, stdio Stdio) int {
+	r := s.remote
+	dir := r.home
+	if len(operands) == 1 {
+		dir = operands[0]
+	}
+	if dir == "-" {
+		if r.oldCwd == "" {
+			fmt.Fprintln(stdio.Stderr, "cd: OLDPWD not set")
+			return 1
+		}
+		dir = r.oldCwd
+		fmt.Fprintln(stdio.Stdout, dir)
+	}
+	if err := r.chdir(dir); err != nil {
+		fmt.Fprintf(stdio.Stderr, "cd: %s: %v\n", dir, err)
+		return 1
+	}
+	return 0
+}
+
+// builtinRsh connects to a host, so the commands typed run there; shows
+// the host connected to without an operand; and disconnects with -d
+func builtinRsh(s *Shell, args []string, stdio Stdio) int {
+	var disconnect bool
+	flags := newFlagSet("rsh")
+	flags.Bool(&disconnect, "d")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) > 1 || disconnect && len(operands) > 0 {
+		return flags.usage(stdio)
+	}
+
+	switch {
+	case disconnect:
+		if s.remote == nil {
+			fmt.Fprintln(stdio.Stderr, "rsh: not connected")
+			return 1
+		}
+		s.remote.close()
+		s.remote = nil
+		return 0
+	case len(operands) == 0:
+		if s.remote == nil {
+			fmt.Fprintln(stdio.Stdout, "Commands run here; rsh HOST runs them on another host")
+		} else {
+			fmt.Fprintf(stdio.Stdout, "Commands run on %s, in %s\n", s.remote.target, s.remote.tildePath(s.remote.cwd))
+		}
+		return 0
+	}
+
+	r, err := s.dialRemote(operands[0], stdio)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "rsh: %s: %v\n", operands[0], err)
+		return 1
+	}
+	if s.remote != nil {
+		s.remote.close()
+	}
+	s.remote = r
+	return 0
+}
@@ -0,0 +1,197 @@
+package shell
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseRemoteTarget(t *testing.T) {
+	tests := []struct {
+		target, user, host, addr string
+	}{
+		{"example.com", "me", "example.com", "example.com:22"},
+		{"ann@example.com", "ann", "example.com", "example.com:22"},
+		{"ann@example.com:2222", "ann", "example.com", "example.com:2222"},
+		{"a@b@10.0.0.1", "a@b", "10.0.0.1", "10.0.0.1:22"},
+		{"[::1]:2200", "me", "::1", "[::1]:2200"},
+		{"::1", "me", "::1", "[::1]:22"},
+	}
+	for _, tt := range tests {
+		user, host, addr, err := parseRemoteTarget(tt.target, "me")
+		if err != nil || user != tt.user || host != tt.host || addr != tt.addr {
+			t.Errorf("parseRemoteTarget(%q) = %q, %q, %q, %v, want %q, %q, %q", tt.target, user, host, addr, err, tt.user, tt.host, tt.addr)
+		}
+	}
+	for _, target := range []string{"", "ann@", "@host"} {
+		if _, _, _, err := parseRemoteTarget(target, "me"); err == nil {
+			t.Errorf("parseRemoteTarget(%q) succeeded", target)
+		}
+	}
+}
+
+func TestRemoteCommandLine(t *testing.T) {
+	r := &remoteSession{cwd: "/home/ann/my files"}
+	got := r.commandLine([]string{"grep", "-r", "two words", "~ann", "it's"})
+	want := `cd '/home/ann/my files' && grep -r 'two words' '~ann' 'it'\''s'`
+	if got != want {
+		t.Errorf("commandLine = %q, want %q", got, want)
+	}
+}
+
+func TestRemoteMode(t *testing.T) {
+	shell := New(Options{Env: []string{"HOME=/local/home"}})
+	shell.remote = &remoteSession{files: serveFakeSFTP(t, fakeSFTPFiles), home: fakeSFTPHome, cwd: fakeSFTPHome}
+
+	tests := []struct {
+		line, want string
+		status     int
+	}{
+		{"echo *.txt ~/src/*", "notes.txt todo.txt /home/ann/src/main.go /home/ann/src/script.sh\n", 0},
+		{"echo /home/*/src", "/home/ann/src\n", 0},
+		{"echo *.md", "*.md\n", 0},
+		{"pwd", "/home/ann\n", 0},
+		{"cd src", "", 0},
+		{"pwd", "/home/ann/src\n", 0},
+		{"echo *", "main.go script.sh\n", 0},
+		{"cd -", "/home/ann\n", 0},
+		{"cd notes.txt", "cd: notes.txt: not a directory\n", 1},
+		{"cd nowhere", "cd: nowhere: No such file\n", 1},
+		{"cd /home", "", 0},
+		{"cd", "", 0},
+		{"pwd", "/home/ann\n", 0},
+	}
+	for _, tt := range tests {
+		if out, status := runCapture(t, shell, tt.line); out != tt.want || status != tt.status {
+			t.Errorf("%s = %q (status %d), want %q (status %d)", tt.line, out, status, tt.want, tt.status)
+		}
+	}
+
+	out, status := runCapture(t, shell, "ls --json src")
+	if status != 0 || !strings.Contains(out, `"name": "main.go"`) || !strings.Contains(out, `"size": 789`) {
+		t.Errorf("ls --json src = %q (status %d)", out, status)
+	}
+	if got := shell.renderPrompt("{cwd} {dir}"); got != "~ ~" {
+		t.Errorf("prompt = %q, want the remote directory", got)
+	}
+}
+
+// serveFakeSSH starts an SSH server accepting the given user key. It runs
+// sftp against fakeSFTPFiles and answers any other command with the
+// command and exit status 3.
+func serveFakeSSH(t *testing.T, userKey ssh.PublicKey) (addr string, hostKey ssh.PublicKey) {
+	t.Helper()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(userKey.Marshal()) {
+				return nil, fmt.Errorf("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, channels, requests, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go func() {
+				for newChannel := range channels {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range requests {
+							var payload struct{ Value string }
+							ssh.Unmarshal(req.Payload, &payload)
+							req.Reply(req.Type == "exec" || req.Type == "subsystem", nil)
+							switch req.Type {
+							case "subsystem":
+								go fakeSFTP(fakeSFTPFiles, channel, channel)
+							case "exec":
+								fmt.Fprintf(channel, "ran %s\n", payload.Value)
+								channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{3}))
+								channel.Close()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func TestRemoteConnect(t *testing.T) {
+	home := t.TempDir()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(home, ".ssh"), 0700)
+	os.WriteFile(filepath.Join(home, ".ssh", "id_ed25519"), pem.EncodeToMemory(block), 0600)
+	userKey, _ := ssh.NewPublicKey(pub)
+	addr, hostKey := serveFakeSSH(t, userKey)
+	shell := New(Options{Env: []string{"HOME=" + home, "USER=ann"}})
+
+	// The host isn't known, and there is no terminal to accept its key on
+	if out, status := runCapture(t, shell, "rsh "+addr); status != 1 || !strings.Contains(out, "not a known host") {
+		t.Errorf("rsh to an unknown host = %q (status %d)", out, status)
+	}
+	// A known_hosts that can't be read is reported, not taken to know no hosts
+	os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte("garbled\n"), 0600)
+	if out, status := runCapture(t, shell, "rsh "+addr); status != 1 || !strings.Contains(out, "known_hosts") {
+		t.Errorf("rsh with a garbled known_hosts = %q (status %d)", out, status)
+	}
+	known := knownhosts.Line([]string{addr}, hostKey) + "\n"
+	os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(known), 0600)
+	if out, status := runCapture(t, shell, "rsh "+addr); status != 0 || out != "" {
+		t.Fatalf("rsh = %q (status %d)", out, status)
+	}
+	defer shell.Close()
+
+	tests := []struct {
+		line, want string
+		status     int
+	}{
+		{"rsh", "Commands run on " + addr + ", in ~\n", 0},
+		{"cd src", "", 0},
+		{"grep -n 'a b' *.go", "ran cd /home/ann/src && grep -n 'a b' main.go\nError executing command: Process exited with status 3\n", 3},
+		{"rm *.go", "ran cd /home/ann/src && rm main.go\nError executing command: Process exited with status 3\n", 3},
+		{"ls -l | wc -l", "ran cd /home/ann/src && wc -l\nError waiting for command: Process exited with status 3\n", 3},
+		{"rsh -d", "", 0},
+		{"rsh", "Commands run here; rsh HOST runs them on another host\n", 0},
+	}
+	for _, tt := range tests {
+		if out, status := runCapture(t, shell, tt.line); out != tt.want || status != tt.status {
+			t.Errorf("%s = %q (status %d), want %q (status %d)", tt.line, out, status, tt.want, tt.status)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package shell
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// waitReadable waits up to timeout for a file to have input to read. A
+// console is signalled by events other than keys too, such as focus
+// changes, after which the read waits for a key.
+func waitReadable(f *os.File, timeout time.Duration) (bool, error) {
+	event, err := windows.WaitForSingleObject(windows.Handle(f.Fd()), uint32(timeout.Milliseconds()))
+	return event == windows.WAIT_OBJECT_0, err
+}
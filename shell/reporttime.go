@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With REPORTTIME set, as in zsh, a command line that took longer than that
+// many seconds is followed by a line saying how long it took and the CPU
+// time and peak memory of the commands it ran, before the next prompt.
+// Builtins run inside the shell, so only external commands' CPU time and
+// memory are counted.
+
+// commandUsage adds up the resources the commands of a line used. Pipeline
+// stages finish on goroutines of their own, so it is used under a lock.
+type commandUsage struct {
+	mu     sync.Mutex
+	user   time.Duration
+	system time.Duration
+	maxRSS int64
+}
+
+// add counts the resources a finished process used
+func (u *commandUsage) add(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.user += state.UserTime()
+	u.system += state.SystemTime()
+	u.maxRSS = max(u.maxRSS, maxRSS(state))
+}
+
+// reset forgets the resources counted for the last line
+func (u *commandUsage) reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.user, u.system, u.maxRSS = 0, 0, 0
+}
+
+// reportTime writes how long a command line took and what it used, if
+// REPORTTIME is set and it took longer
+func (s *Shell) reportTime(w io.Writer, line string, took time.Duration) {
+	spec := s.env.Get("REPORTTIME")
+	if spec == "" {
+		return
+	}
+	threshold, err := parseTimeout(spec)
+	if err != nil || took < threshold {
+		return
+	}
+	u := &s.usage
+	u.mu.Lock()
+	parts := []string{
+		formatProfileDuration(took) + " total",
+		formatProfileDuration(u.user) + " user",
+		formatProfileDuration(u.system) + " sys",
+	}
+	if u.maxRSS > 0 {
+		parts = append(parts, formatSize(u.maxRSS)+" max RSS")
+	}
+	u.mu.Unlock()
+	if runes := []rune(line); len(runes) > 40 {
+		line = string(runes[:39]) + "…"
+	}
+	report := strings.Join(parts, ", ") + "  " + line
+	if isTerminal(w) {
+		report = Dim + report + Reset
+	}
+	fmt.Fprintln(w, report)
+}
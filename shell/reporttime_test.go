@@ -0,0 +1,39 @@
+package shell
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportTime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs sh")
+	}
+	shell := New(Options{})
+	var out strings.Builder
+	shell.reportTime(&out, "sleep 1", 5*time.Second)
+	if out.Len() != 0 {
+		t.Errorf("reported without REPORTTIME: %q", out.String())
+	}
+
+	shell.env.Set("REPORTTIME", "2")
+	runCapture(t, shell, "sh -c 'i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done'")
+	if shell.usage.user+shell.usage.system == 0 {
+		t.Error("the command's CPU time wasn't counted")
+	}
+	shell.reportTime(&out, "make", time.Second)
+	if out.Len() != 0 {
+		t.Errorf("reported a command under REPORTTIME: %q", out.String())
+	}
+	shell.reportTime(&out, "make", 3*time.Second)
+	if got := out.String(); !strings.Contains(got, "3.000s total, ") || !strings.Contains(got, "s user, ") || !strings.Contains(got, "s sys") || !strings.Contains(got, "  make") {
+		t.Errorf("report = %q", got)
+	}
+
+	shell.usage.reset()
+	if shell.usage.user != 0 || shell.usage.maxRSS != 0 {
+		t.Error("reset kept the last line's usage")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package shell
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls redraw whenever the terminal window changes size, so
+// the line being edited and any completion menu under it are laid out for
+// the new width at once rather than at the next key
+func watchResize(redraw func()) {
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	go func() {
+		for range resized {
+			redraw()
+		}
+	}()
+}
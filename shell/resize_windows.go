@@ -0,0 +1,7 @@
+//go:build windows
+
+package shell
+
+// watchResize does nothing on Windows, which has no SIGWINCH; the line is
+// laid out for the new width at the next key
+func watchResize(redraw func()) {}
@@ -0,0 +1,1221 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerBuiltin("rows", "rows [-F SEP] PROGRAM [file...]", "Filter and reshape lines with awk-style patterns", builtinRows)
+	registerFlags("rows", Candidate{"-F", "Field separator (default: runs of blanks)"})
+}
+
+// rows implements the commonly used subset of awk: pattern { action } rules
+// with BEGIN and END, fields, variables, arithmetic, comparisons, regex
+// matches, print, printf, if/else and next.
+
+// rowsValue is a number, a string, or a string read from input that compares
+// numerically when it looks like a number
+type rowsValue struct {
+	str    string
+	num    float64
+	isNum  bool
+	strnum bool
+}
+
+func rowsNum(n float64) rowsValue  { return rowsValue{num: n, isNum: true} }
+func rowsStr(s string) rowsValue   { return rowsValue{str: s} }
+func rowsInput(s string) rowsValue { return rowsValue{str: s, strnum: true} }
+func rowsBool(b bool) rowsValue {
+	if b {
+		return rowsNum(1)
+	}
+	return rowsNum(0)
+}
+
+// toNum converts the value to a number; strings use their leading number
+func (v rowsValue) toNum() float64 {
+	if v.isNum {
+		return v.num
+	}
+	return leadingNumber(v.str)
+}
+
+// toStr converts the value to a string, printing integral numbers without a
+// fractional part
+func (v rowsValue) toStr() string {
+	if !v.isNum {
+		return v.str
+	}
+	if v.num == math.Trunc(v.num) && math.Abs(v.num) < 1e16 {
+		return strconv.FormatInt(int64(v.num), 10)
+	}
+	return fmt.Sprintf("%.6g", v.num)
+}
+
+// toBool reports whether the value is true: non-zero numbers and non-empty
+// strings
+func (v rowsValue) toBool() bool {
+	if v.isNum {
+		return v.num != 0
+	}
+	if v.strnum && v.looksNumeric() {
+		return v.toNum() != 0
+	}
+	return v.str != ""
+}
+
+// looksNumeric reports whether an input string is entirely a number
+func (v rowsValue) looksNumeric() bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+	return err == nil
+}
+
+// compareRows compares two values numerically when both are numbers (or
+// numeric-looking input) and as strings otherwise
+func compareRows(a, b rowsValue) int {
+	numeric := func(v rowsValue) bool { return v.isNum || (v.strnum && (v.str == "" || v.looksNumeric())) }
+	if numeric(a) && numeric(b) {
+		x, y := a.toNum(), b.toNum()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.toStr(), b.toStr())
+}
+
+// Token kinds
+const (
+	rowsEOF    = iota
+	rowsNumber // 12, 3.5
+	rowsString // "text"
+	rowsRegex  // /pattern/
+	rowsName   // identifiers and keywords
+	rowsOp     // operators and punctuation
+	rowsEnd    // ; or newline
+)
+
+type rowsToken struct {
+	kind int
+	text string
+}
+
+// rowsOperators lists the operators, longest first
+var rowsOperators = []string{
+	"&&", "||", "==", "!=", "<=", ">=", "!~", "++", "--", "+=", "-=", "*=", "/=", "%=",
+	"{", "}", "(", ")", ",", "$", "!", "<", ">", "~", "+", "-", "*", "/", "%", "=", "?", ":",
+}
+
+// lexRows splits a program into tokens. A slash starts a regex unless it
+// follows an operand, where it means division.
+func lexRows(src string) ([]rowsToken, error) {
+	var tokens []rowsToken
+	operandEnd := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		t := tokens[len(tokens)-1]
+		switch t.kind {
+		case rowsNumber, rowsString, rowsName:
+			return true
+		case rowsOp:
+			return t.text == ")" || t.text == "++" || t.text == "--"
+		}
+		return false
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i += 2
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '\n' || c == ';':
+			tokens = append(tokens, rowsToken{rowsEnd, string(c)})
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, rowsToken{rowsNumber, src[start:i]})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, rowsToken{rowsName, src[start:i]})
+		case c == '"':
+			var b strings.Builder
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+					b.WriteRune(unescapeChar(rune(src[i])))
+				} else {
+					b.WriteByte(src[i])
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			i++
+			tokens = append(tokens, rowsToken{rowsString, b.String()})
+		case c == '/' && !operandEnd():
+			var b strings.Builder
+			i++
+			for i < len(src) && src[i] != '/' {
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '/' {
+					i++
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, errors.New("unterminated regex")
+			}
+			i++
+			tokens = append(tokens, rowsToken{rowsRegex, b.String()})
+		default:
+			matched := false
+			for _, op := range rowsOperators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, rowsToken{rowsOp, op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, rowsToken{kind: rowsEOF}), nil
+}
+
+// rowsExpr is an expression node
+type rowsExpr interface {
+	eval(in *rowsInterp) rowsValue
+}
+
+// rowsStmt is a statement node
+type rowsStmt interface {
+	exec(in *rowsInterp) error
+}
+
+// errRowsNext stops processing the current line
+var errRowsNext = errors.New("next")
+
+// rowsRule is a pattern with its action; a nil pattern matches every line
+// and a nil action prints the line
+type rowsRule struct {
+	pattern rowsExpr
+	action  []rowsStmt
+}
+
+// rowsProgram is a parsed program
+type rowsProgram struct {
+	begin, end [][]rowsStmt
+	rules      []rowsRule
+}
+
+// rowsParser builds a program from tokens
+type rowsParser struct {
+	tokens []rowsToken
+	pos    int
+}
+
+func (p *rowsParser) peek() rowsToken { return p.tokens[p.pos] }
+
+func (p *rowsParser) next() rowsToken {
+	t := p.tokens[p.pos]
+	if t.kind != rowsEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isOp reports whether the next token is the given operator or keyword
+func (p *rowsParser) isOp(text string) bool {
+	t := p.peek()
+	return (t.kind == rowsOp || t.kind == rowsName) && t.text == text
+}
+
+// accept consumes the next token if it is the given operator or keyword
+func (p *rowsParser) accept(text string) bool {
+	if p.isOp(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *rowsParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %s", text)
+	}
+	return nil
+}
+
+// skipEnds skips statement terminators
+func (p *rowsParser) skipEnds() {
+	for p.peek().kind == rowsEnd {
+		p.pos++
+	}
+}
+
+func (p *rowsParser) errorf(format string, args ...interface{}) error {
+	near := p.peek().text
+	if p.peek().kind == rowsEOF {
+		near = "end of program"
+	}
+	return fmt.Errorf("syntax error near %s: %s", near, fmt.Sprintf(format, args...))
+}
+
+// parseRows parses a whole program
+func parseRows(src string) (*rowsProgram, error) {
+	tokens, err := lexRows(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &rowsParser{tokens: tokens}
+	prog := &rowsProgram{}
+	for {
+		p.skipEnds()
+		if p.peek().kind == rowsEOF {
+			return prog, nil
+		}
+		switch {
+		case p.accept("BEGIN"):
+			block, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			prog.begin = append(prog.begin, block)
+		case p.accept("END"):
+			block, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			prog.end = append(prog.end, block)
+		default:
+			var rule rowsRule
+			if !p.isOp("{") {
+				if rule.pattern, err = p.expr(); err != nil {
+					return nil, err
+				}
+			}
+			if p.isOp("{") {
+				if rule.action, err = p.block(); err != nil {
+					return nil, err
+				}
+			}
+			prog.rules = append(prog.rules, rule)
+		}
+	}
+}
+
+// block parses { statements }
+func (p *rowsParser) block() ([]rowsStmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []rowsStmt
+	for {
+		p.skipEnds()
+		if p.accept("}") {
+			return stmts, nil
+		}
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		if p.peek().kind != rowsEnd && !p.isOp("}") {
+			return nil, p.errorf("expected ; or }")
+		}
+	}
+}
+
+// statement parses a single statement
+func (p *rowsParser) statement() (rowsStmt, error) {
+	switch {
+	case p.isOp("{"):
+		stmts, err := p.block()
+		return rowsBlock(stmts), err
+	case p.accept("print"):
+		args, err := p.exprList()
+		return &rowsPrint{args: args}, err
+	case p.accept("printf"):
+		args, err := p.exprList()
+		if err == nil && len(args) == 0 {
+			err = p.errorf("printf needs a format")
+		}
+		return &rowsPrint{args: args, format: true}, err
+	case p.accept("next"):
+		return rowsNextStmt{}, nil
+	case p.accept("if"):
+		return p.ifStatement()
+	}
+	e, err := p.expr()
+	return rowsExprStmt{e}, err
+}
+
+// ifStatement parses the rest of if (cond) stmt [else stmt]
+func (p *rowsParser) ifStatement() (rowsStmt, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	p.skipEnds()
+	stmt := &rowsIf{cond: cond}
+	if stmt.then, err = p.statement(); err != nil {
+		return nil, err
+	}
+	// else may follow on the next line or after a semicolon
+	save := p.pos
+	p.skipEnds()
+	if p.accept("else") {
+		p.skipEnds()
+		stmt.otherwise, err = p.statement()
+		return stmt, err
+	}
+	p.pos = save
+	return stmt, nil
+}
+
+// exprList parses the comma-separated arguments of print and printf
+func (p *rowsParser) exprList() ([]rowsExpr, error) {
+	var list []rowsExpr
+	if p.peek().kind == rowsEnd || p.peek().kind == rowsEOF || p.isOp("}") {
+		return nil, nil
+	}
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.accept(",") {
+			return list, nil
+		}
+		p.skipEnds()
+	}
+}
+
+// Expressions are parsed by precedence, loosest first: assignment, ?:, ||,
+// &&, ~ and !~, comparison, concatenation, + and -, * / and %, unary
+// operators, postfix ++ and --, and primaries.
+
+func (p *rowsParser) expr() (rowsExpr, error) {
+	left, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "+=", "-=", "*=", "/=", "%="} {
+		if !p.isOp(op) {
+			continue
+		}
+		target, ok := left.(rowsLvalue)
+		if !ok {
+			return nil, p.errorf("cannot assign to this expression")
+		}
+		p.next()
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &rowsAssign{target: target, op: strings.TrimSuffix(op, "="), value: value}, nil
+	}
+	return left, nil
+}
+
+func (p *rowsParser) ternary() (rowsExpr, error) {
+	cond, err := p.or()
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	yes, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	no, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &rowsCond{cond, yes, no}, nil
+}
+
+// binaryLevel parses a left-associative chain of operators at one level
+func (p *rowsParser) binaryLevel(ops []string, operand func() (rowsExpr, error)) (rowsExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		matched := ""
+		for _, op := range ops {
+			if p.isOp(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return left, nil
+		}
+		p.next()
+		if matched == "&&" || matched == "||" {
+			p.skipEnds()
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &rowsBinary{op: matched, left: left, right: right}
+	}
+}
+
+func (p *rowsParser) or() (rowsExpr, error) {
+	return p.binaryLevel([]string{"||"}, p.and)
+}
+
+func (p *rowsParser) and() (rowsExpr, error) {
+	return p.binaryLevel([]string{"&&"}, p.match)
+}
+
+func (p *rowsParser) match() (rowsExpr, error) {
+	return p.binaryLevel([]string{"~", "!~"}, p.comparison)
+}
+
+func (p *rowsParser) comparison() (rowsExpr, error) {
+	left, err := p.concat()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"<", "<=", ">", ">=", "==", "!="} {
+		if p.isOp(op) {
+			p.next()
+			right, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			return &rowsBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// concat joins adjacent expressions, as in print $1 ":" $2
+func (p *rowsParser) concat() (rowsExpr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for p.startsOperand() {
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		left = &rowsBinary{op: "concat", left: left, right: right}
+	}
+	return left, nil
+}
+
+// startsOperand reports whether the next token can begin a concatenated
+// operand
+func (p *rowsParser) startsOperand() bool {
+	t := p.peek()
+	switch t.kind {
+	case rowsNumber, rowsString:
+		return true
+	case rowsName:
+		return !rowsKeywords[t.text]
+	case rowsOp:
+		return t.text == "$" || t.text == "("
+	}
+	return false
+}
+
+var rowsKeywords = map[string]bool{
+	"BEGIN": true, "END": true, "print": true, "printf": true, "next": true, "if": true, "else": true,
+}
+
+func (p *rowsParser) additive() (rowsExpr, error) {
+	return p.binaryLevel([]string{"+", "-"}, p.multiplicative)
+}
+
+func (p *rowsParser) multiplicative() (rowsExpr, error) {
+	return p.binaryLevel([]string{"*", "/", "%"}, p.unary)
+}
+
+func (p *rowsParser) unary() (rowsExpr, error) {
+	for _, op := range []string{"!", "-", "+"} {
+		if p.accept(op) {
+			operand, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return &rowsUnary{op: op, operand: operand}, nil
+		}
+	}
+	return p.postfix()
+}
+
+func (p *rowsParser) postfix() (rowsExpr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if target, ok := e.(rowsLvalue); ok {
+		if p.accept("++") {
+			return &rowsIncrement{target: target, delta: 1}, nil
+		}
+		if p.accept("--") {
+			return &rowsIncrement{target: target, delta: -1}, nil
+		}
+	}
+	return e, nil
+}
+
+func (p *rowsParser) primary() (rowsExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case rowsNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return rowsLiteral{rowsNum(n)}, nil
+	case rowsString:
+		return rowsLiteral{rowsStr(t.text)}, nil
+	case rowsRegex:
+		re, err := regexp.Compile(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return &rowsRegexLit{re}, nil
+	case rowsName:
+		if rowsKeywords[t.text] {
+			p.pos--
+			return nil, p.errorf("unexpected keyword")
+		}
+		if p.isOp("(") {
+			return p.call(t.text)
+		}
+		return &rowsVar{name: t.text}, nil
+	case rowsOp:
+		switch t.text {
+		case "$":
+			index, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			return &rowsField{index: index}, nil
+		case "(":
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	}
+	if t.kind != rowsEOF {
+		p.pos--
+	}
+	return nil, p.errorf("unexpected token")
+}
+
+// rowsFuncs are the supported builtin functions and their argument counts
+var rowsFuncs = map[string][2]int{
+	"length": {0, 1}, "tolower": {1, 1}, "toupper": {1, 1}, "substr": {2, 3}, "index": {2, 2}, "int": {1, 1},
+}
+
+// call parses the arguments of a builtin function call
+func (p *rowsParser) call(name string) (rowsExpr, error) {
+	arity, ok := rowsFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	p.next()
+	var args []rowsExpr
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) < arity[0] || len(args) > arity[1] {
+		return nil, fmt.Errorf("wrong number of arguments to %s", name)
+	}
+	return &rowsCall{name: name, args: args}, nil
+}
+
+// rowsInterp holds the state of a running program
+type rowsInterp struct {
+	vars      map[string]rowsValue
+	fields    []string // $1..$NF; $0 is kept in record
+	record    string
+	separator string // field separator; empty splits on runs of blanks
+	nr        int
+	out       *bufio.Writer
+	regexes   map[string]*regexp.Regexp
+	err       error // the first error evaluating an expression, which stops the program
+}
+
+// fail records an error evaluating an expression, unless there was one
+func (in *rowsInterp) fail(err error) {
+	if in.err == nil {
+		in.err = err
+	}
+}
+
+// setRecord sets $0 and splits it into fields
+func (in *rowsInterp) setRecord(line string) {
+	in.record = line
+	if in.separator == "" {
+		in.fields = strings.Fields(line)
+	} else if line == "" {
+		in.fields = nil
+	} else {
+		in.fields = strings.Split(line, in.separator)
+	}
+}
+
+// field returns $i
+func (in *rowsInterp) field(i int) string {
+	if i == 0 {
+		return in.record
+	}
+	if i < 0 || i > len(in.fields) {
+		return ""
+	}
+	return in.fields[i-1]
+}
+
+// setField assigns $i, rebuilding $0 from the fields
+func (in *rowsInterp) setField(i int, value string) {
+	if i == 0 {
+		in.setRecord(value)
+		return
+	}
+	for len(in.fields) < i {
+		in.fields = append(in.fields, "")
+	}
+	in.fields[i-1] = value
+	sep := in.separator
+	if sep == "" {
+		sep = " "
+	}
+	in.record = strings.Join(in.fields, sep)
+}
+
+// regex compiles a dynamic regex, caching the result
+func (in *rowsInterp) regex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := in.regexes[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	in.regexes[pattern] = re
+	return re, nil
+}
+
+// Expression nodes
+
+type rowsLiteral struct{ v rowsValue }
+
+func (e rowsLiteral) eval(in *rowsInterp) rowsValue { return e.v }
+
+// rowsRegexLit on its own matches against $0
+type rowsRegexLit struct{ re *regexp.Regexp }
+
+func (e *rowsRegexLit) eval(in *rowsInterp) rowsValue {
+	return rowsBool(e.re.MatchString(in.record))
+}
+
+// rowsLvalue is an expression that can be assigned to
+type rowsLvalue interface {
+	rowsExpr
+	assign(in *rowsInterp, v rowsValue)
+}
+
+type rowsVar struct{ name string }
+
+func (e *rowsVar) eval(in *rowsInterp) rowsValue {
+	switch e.name {
+	case "NR":
+		return rowsNum(float64(in.nr))
+	case "NF":
+		return rowsNum(float64(len(in.fields)))
+	}
+	if v, ok := in.vars[e.name]; ok {
+		return v
+	}
+	// Unset variables are both "" and 0
+	return rowsInput("")
+}
+
+func (e *rowsVar) assign(in *rowsInterp, v rowsValue) {
+	switch e.name {
+	case "NR":
+		in.nr = int(v.toNum())
+	case "NF":
+		n := int(v.toNum())
+		if n <= 0 {
+			in.setRecord("")
+			return
+		}
+		if n < len(in.fields) {
+			in.fields = in.fields[:n]
+		}
+		in.setField(n, in.field(n))
+	default:
+		in.vars[e.name] = v
+	}
+}
+
+type rowsField struct{ index rowsExpr }
+
+func (e *rowsField) eval(in *rowsInterp) rowsValue {
+	return rowsInput(in.field(int(e.index.eval(in).toNum())))
+}
+
+func (e *rowsField) assign(in *rowsInterp, v rowsValue) {
+	in.setField(int(e.index.eval(in).toNum()), v.toStr())
+}
+
+type rowsAssign struct {
+	target rowsLvalue
+	op     string // "" for plain assignment, else the arithmetic operator
+	value  rowsExpr
+}
+
+func (e *rowsAssign) eval(in *rowsInterp) rowsValue {
+	v := e.value.eval(in)
+	if e.op != "" {
+		v = in.arith(e.op, e.target.eval(in), v)
+	}
+	e.target.assign(in, v)
+	return v
+}
+
+type rowsIncrement struct {
+	target rowsLvalue
+	delta  float64
+}
+
+func (e *rowsIncrement) eval(in *rowsInterp) rowsValue {
+	old := e.target.eval(in).toNum()
+	e.target.assign(in, rowsNum(old+e.delta))
+	return rowsNum(old)
+}
+
+type rowsCond struct{ cond, yes, no rowsExpr }
+
+func (e *rowsCond) eval(in *rowsInterp) rowsValue {
+	if e.cond.eval(in).toBool() {
+		return e.yes.eval(in)
+	}
+	return e.no.eval(in)
+}
+
+type rowsUnary struct {
+	op      string
+	operand rowsExpr
+}
+
+func (e *rowsUnary) eval(in *rowsInterp) rowsValue {
+	v := e.operand.eval(in)
+	switch e.op {
+	case "!":
+		return rowsBool(!v.toBool())
+	case "-":
+		return rowsNum(-v.toNum())
+	}
+	return rowsNum(v.toNum())
+}
+
+type rowsBinary struct {
+	op          string
+	left, right rowsExpr
+}
+
+func (e *rowsBinary) eval(in *rowsInterp) rowsValue {
+	switch e.op {
+	case "&&":
+		return rowsBool(e.left.eval(in).toBool() && e.right.eval(in).toBool())
+	case "||":
+		return rowsBool(e.left.eval(in).toBool() || e.right.eval(in).toBool())
+	case "~", "!~":
+		text := e.left.eval(in).toStr()
+		var re *regexp.Regexp
+		if lit, ok := e.right.(*rowsRegexLit); ok {
+			re = lit.re
+		} else {
+			var err error
+			if re, err = in.regex(e.right.eval(in).toStr()); err != nil {
+				return rowsBool(false)
+			}
+		}
+		return rowsBool(re.MatchString(text) == (e.op == "~"))
+	case "concat":
+		return rowsStr(e.left.eval(in).toStr() + e.right.eval(in).toStr())
+	}
+
+	left, right := e.left.eval(in), e.right.eval(in)
+	switch e.op {
+	case "<":
+		return rowsBool(compareRows(left, right) < 0)
+	case "<=":
+		return rowsBool(compareRows(left, right) <= 0)
+	case ">":
+		return rowsBool(compareRows(left, right) > 0)
+	case ">=":
+		return rowsBool(compareRows(left, right) >= 0)
+	case "==":
+		return rowsBool(compareRows(left, right) == 0)
+	case "!=":
+		return rowsBool(compareRows(left, right) != 0)
+	}
+	return in.arith(e.op, left, right)
+}
+
+// errRowsDivision stops a program dividing by zero, as awk does
+var errRowsDivision = errors.New("division by zero")
+
+// arith applies an arithmetic operator. Division by zero yields zero and
+// stops the program once the statement has been evaluated.
+func (in *rowsInterp) arith(op string, a, b rowsValue) rowsValue {
+	x, y := a.toNum(), b.toNum()
+	switch op {
+	case "+":
+		return rowsNum(x + y)
+	case "-":
+		return rowsNum(x - y)
+	case "*":
+		return rowsNum(x * y)
+	case "/":
+		if y == 0 {
+			in.fail(errRowsDivision)
+			return rowsNum(0)
+		}
+		return rowsNum(x / y)
+	case "%":
+		if y == 0 {
+			in.fail(errRowsDivision)
+			return rowsNum(0)
+		}
+		return rowsNum(math.Mod(x, y))
+	}
+	return rowsNum(0)
+}
+
+type rowsCall struct {
+	name string
+	args []rowsExpr
+}
+
+func (e *rowsCall) eval(in *rowsInterp) rowsValue {
+	var args []rowsValue
+	for _, arg := range e.args {
+		args = append(args, arg.eval(in))
+	}
+	switch e.name {
+	case "length":
+		if len(args) == 0 {
+			return rowsNum(float64(len([]rune(in.record))))
+		}
+		return rowsNum(float64(len([]rune(args[0].toStr()))))
+	case "tolower":
+		return rowsStr(strings.ToLower(args[0].toStr()))
+	case "toupper":
+		return rowsStr(strings.ToUpper(args[0].toStr()))
+	case "index":
+		return rowsNum(float64(strings.Index(args[0].toStr(), args[1].toStr()) + 1))
+	case "int":
+		return rowsNum(math.Trunc(args[0].toNum()))
+	case "substr":
+		// Positions are 1-based and clamped to the string
+		runes := []rune(args[0].toStr())
+		start := int(args[1].toNum()) - 1
+		end := len(runes)
+		if len(args) == 3 {
+			end = start + int(args[2].toNum())
+		}
+		start = max(start, 0)
+		end = min(end, len(runes))
+		if start >= end {
+			return rowsStr("")
+		}
+		return rowsStr(string(runes[start:end]))
+	}
+	return rowsStr("")
+}
+
+// Statement nodes
+
+type rowsBlock []rowsStmt
+
+func (b rowsBlock) exec(in *rowsInterp) error {
+	for _, stmt := range b {
+		if err := stmt.exec(in); err != nil {
+			return err
+		}
+		if in.err != nil {
+			return in.err
+		}
+	}
+	return nil
+}
+
+type rowsExprStmt struct{ e rowsExpr }
+
+func (s rowsExprStmt) exec(in *rowsInterp) error {
+	s.e.eval(in)
+	return nil
+}
+
+type rowsNextStmt struct{}
+
+func (rowsNextStmt) exec(in *rowsInterp) error { return errRowsNext }
+
+type rowsIf struct {
+	cond            rowsExpr
+	then, otherwise rowsStmt
+}
+
+func (s *rowsIf) exec(in *rowsInterp) error {
+	cond := s.cond.eval(in).toBool()
+	if in.err != nil {
+		return in.err
+	}
+	if cond {
+		return s.then.exec(in)
+	}
+	if s.otherwise != nil {
+		return s.otherwise.exec(in)
+	}
+	return nil
+}
+
+// rowsPrint implements print, which joins its arguments with spaces, and
+// printf, whose first argument is the format
+type rowsPrint struct {
+	args   []rowsExpr
+	format bool
+}
+
+func (s *rowsPrint) exec(in *rowsInterp) error {
+	var values []rowsValue
+	for _, arg := range s.args {
+		values = append(values, arg.eval(in))
+	}
+	if in.err != nil {
+		return in.err
+	}
+	if s.format {
+		in.out.WriteString(rowsSprintf(values[0].toStr(), values[1:]))
+		return nil
+	}
+	if len(values) == 0 {
+		values = []rowsValue{rowsStr(in.record)}
+	}
+	for i, v := range values {
+		if i > 0 {
+			in.out.WriteByte(' ')
+		}
+		in.out.WriteString(v.toStr())
+	}
+	in.out.WriteByte('\n')
+	return nil
+}
+
+// rowsSprintf formats values with a printf-style format, converting each to
+// the type its verb expects
+func rowsSprintf(format string, values []rowsValue) string {
+	var b strings.Builder
+	next := func() rowsValue {
+		if len(values) == 0 {
+			return rowsStr("")
+		}
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		// Find the verb at the end of the flags, width and precision
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+		spec, verb := format[i:j], format[j]
+		switch verb {
+		case '%':
+			b.WriteByte('%')
+		case 'd', 'i':
+			fmt.Fprintf(&b, spec+"d", int64(next().toNum()))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(&b, spec+string(verb), int64(next().toNum()))
+		case 'e', 'E', 'f', 'g', 'G':
+			fmt.Fprintf(&b, spec+string(verb), next().toNum())
+		case 'c':
+			v := next()
+			if v.isNum {
+				fmt.Fprintf(&b, spec+"c", rune(v.num))
+			} else if r := []rune(v.str); len(r) > 0 {
+				fmt.Fprintf(&b, spec+"c", r[0])
+			}
+		case 's':
+			fmt.Fprintf(&b, spec+"s", next().toStr())
+		default:
+			b.WriteString(format[i : j+1])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// run executes the program over the input
+func (prog *rowsProgram) run(in *rowsInterp, input io.Reader) error {
+	for _, block := range prog.begin {
+		if err := rowsBlock(block).exec(in); err != nil && err != errRowsNext {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(input)
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		in.nr++
+		in.setRecord(line)
+		for _, rule := range prog.rules {
+			if rule.pattern != nil {
+				matched := rule.pattern.eval(in).toBool()
+				if in.err != nil {
+					return in.err
+				}
+				if !matched {
+					continue
+				}
+			}
+			action := rowsBlock(rule.action)
+			if rule.action == nil {
+				action = rowsBlock{&rowsPrint{}}
+			}
+			if err := action.exec(in); err == errRowsNext {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, block := range prog.end {
+		if err := rowsBlock(block).exec(in); err != nil && err != errRowsNext {
+			return err
+		}
+	}
+	return nil
+}
+
+// builtinRows runs an awk-style program over its input: for each line, the
+// action of every rule whose pattern matches runs with $1..$NF set to the
+// line's fields.
+func builtinRows(s *Shell, args []string, stdio Stdio) int {
+	separator := ""
+	flags := newFlagSet("rows")
+	flags.Func(func(value string) error {
+		separator = value
+		// A single escaped character such as \t stands for itself
+		if len(separator) == 2 && separator[0] == '\\' {
+			separator = string(unescapeChar(rune(separator[1])))
+		}
+		if separator == " " {
+			separator = ""
+		}
+		return nil
+	}, "F")
+	flags.stopAtOperand = true
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) == 0 {
+		return flags.usage(stdio)
+	}
+
+	prog, err := parseRows(operands[0])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "rows:", err)
+		return 1
+	}
+	input, closeInputs, err := openInputs(operands[1:], stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "rows:", err)
+		return 1
+	}
+	defer closeInputs()
+
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	in := &rowsInterp{
+		vars:      make(map[string]rowsValue),
+		separator: separator,
+		out:       out,
+		regexes:   make(map[string]*regexp.Regexp),
+	}
+	if err := prog.run(in, input); err != nil {
+		fmt.Fprintln(stdio.Stderr, "rows:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRows(t *testing.T) {
+	input := "alice 30 120\nbob 25 80\ncarol 35 200\n"
+	tests := []struct {
+		name    string
+		program string
+		input   string
+		want    string
+	}{
+		{"filter and project", `$3 > 100 {print $1, $3}`, input, "alice 120\ncarol 200\n"},
+		{"pattern only", `/bob/`, input, "bob 25 80\n"},
+		{"sum in END", `{total += $3} END {print total}`, input, "400\n"},
+		{"string comparison", `$1 == "carol" {print NR}`, input, "3\n"},
+		{"numeric fields compare as numbers", `$2 < 100`, "9 9\n10 10\n", "9 9\n10 10\n"},
+		{"concatenation", `{print $1 ":" $2 * 2}`, "a 2\n", "a:4\n"},
+		{"last field", `{print $NF, NF}`, "x y z\n", "z 3\n"},
+		{"regex match", `$1 ~ /^[ab]/ && !($2 > 26) {print $1}`, input, "bob\n"},
+		{"begin and printf", `BEGIN {printf "%-6s|%5.1f\n", "name", 1.25}`, "", "name  |  1.2\n"},
+		{"if else and next", `{if ($2 > 28) print "old"; else {print "young"; next}; print "--"}`, input, "old\n--\nyoung\nold\n--\n"},
+		{"field assignment", `{$2 = "X"; print}`, "a b c\n", "a X c\n"},
+		{"functions", `{print toupper(substr($1, 1, 3)), length($1), index($1, "l")}`, "hello\n", "HEL 5 3\n"},
+		{"counters", `{n++} END {print n, n / 4}`, input, "3 0.75\n"},
+		{"uninitialized is zero", `END {print x == 0, x ""}`, "", "1 \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := runBuiltin(t, []string{"rows", tt.program}, tt.input)
+			if status != 0 || got != tt.want {
+				t.Errorf("rows %q = %q (status %d), want %q", tt.program, got, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowsSeparator(t *testing.T) {
+	got, _ := runBuiltin(t, []string{"rows", "-F", ":", `{print $1 "-" $3}`}, "root:x:0\n")
+	if got != "root-0\n" {
+		t.Errorf("rows -F : = %q, want %q", got, "root-0\n")
+	}
+	got, _ = runBuiltin(t, []string{"rows", "-F", `\t`, `{print $2}`}, "a b\tc d\n")
+	if got != "c d\n" {
+		t.Errorf(`rows -F \t = %q, want %q`, got, "c d\n")
+	}
+}
+
+func TestRowsSyntaxError(t *testing.T) {
+	for _, program := range []string{`{print $1`, `$1 >`, `{x = }`, `{foo(1)}`} {
+		if _, status := runBuiltin(t, []string{"rows", program}, "a\n"); status == 0 {
+			t.Errorf("rows %q succeeded, want a syntax error", program)
+		}
+	}
+}
+
+func TestRowsDivisionByZero(t *testing.T) {
+	for _, tt := range []struct {
+		program, want string
+	}{
+		{`{print 1/0}`, ""},
+		{`{print "before"} NR == 2 {x %= 0; print "after"}`, "before\nbefore\n"},
+		{`$1 / 0 {print}`, ""},
+		{`BEGIN {if (1 / 0) print "yes"}`, ""},
+	} {
+		var out, errOut bytes.Buffer
+		status := builtinRows(NewShell(), []string{"rows", tt.program}, Stdio{Stdin: strings.NewReader("1\n2\n"), Stdout: &out, Stderr: &errOut})
+		if status == 0 || out.String() != tt.want || errOut.String() != "rows: division by zero\n" {
+			t.Errorf("rows %q = %q, %q (status %d), want %q and the division by zero", tt.program, out.String(), errOut.String(), status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"goshell/internal/env"
+	"goshell/internal/parser"
+)
+
+// Options configures a shell made with New
+type Options struct {
+	// The streams commands read from and write to. Those left nil are the
+	// process's own.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Env holds the environment as KEY=VALUE entries. If it is nil the
+	// shell starts with the process's environment.
+	Env []string
+
+	// POSIX starts the shell in POSIX mode, as set -o posix does, for
+	// running sh scripts
+	POSIX bool
+}
+
+// ExitStatus is the exit status of a command: 0 for success
+type ExitStatus int
+
+// New creates a shell for running commands from a Go program. Unlike the
+// interactive shell it reads no startup file and no history. The working
+// directory is the process's, which cd changes for the whole program.
+func New(opts Options) *Shell {
+	s := NewShell()
+	if opts.Stdin != nil {
+		s.stdin = opts.Stdin
+	}
+	if opts.Stdout != nil {
+		s.stdout = opts.Stdout
+	}
+	if opts.Stderr != nil {
+		s.stderr = opts.Stderr
+	}
+	if opts.Env != nil {
+		s.env = env.FromList(opts.Env)
+	}
+	s.setOption("posix", opts.POSIX)
+	return s
+}
+
+// Run runs input as a script, a command line at a time, on the shell's
+// streams and returns the status of the last command, as $? would be. A
+// line ending in a pipe or inside open quotes continues on the next, as at
+// the prompt. Running stops at the exit builtin, or with an error for a
+// line that can't be parsed or once ctx is done; external commands still
+// running then are stopped as timeout stops them.
+func (s *Shell) Run(ctx context.Context, input string) (ExitStatus, error) {
+	saved := s.ctx
+	s.ctx, s.exiting = ctx, false
+	defer func() { s.ctx = saved }()
+
+	var pending []string
+	for _, line := range strings.Split(input, "\n") {
+		if err := ctx.Err(); err != nil {
+			return ExitStatus(s.lastStatus), err
+		}
+		pending = append(pending, line)
+		command := parser.JoinContinued(pending)
+		list, dryRun, err := s.parseCommandLine(command)
+		if err == parser.ErrIncomplete {
+			continue
+		}
+		pending = nil
+		if err != nil {
+			s.lastStatus = 2
+			return ExitStatus(s.lastStatus), fmt.Errorf("parsing %q: %w", command, err)
+		}
+		s.runCommandLine(list, dryRun, s.stdio())
+		if s.exiting {
+			break
+		}
+	}
+	if len(pending) > 0 && strings.TrimSpace(parser.JoinContinued(pending)) != "" {
+		return ExitStatus(s.lastStatus), fmt.Errorf("parsing %q: %w", parser.JoinContinued(pending), parser.ErrIncomplete)
+	}
+	return ExitStatus(s.lastStatus), ctx.Err()
+}
+
+// context returns the context commands run under: Run's, or one that is
+// never done
+func (s *Shell) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
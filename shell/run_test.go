@@ -0,0 +1,62 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sh := New(Options{
+		Stdin:  strings.NewReader("from stdin\n"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Env:    []string{"GREETING=hello", "PATH=/bin:/usr/bin"},
+	})
+	script := "echo $GREETING |\n  tr a-z A-Z\ncat\necho 'two\nlines'\nfalse"
+	status, err := sh.Run(context.Background(), script)
+	if err != nil || status != 1 {
+		t.Fatalf("Run = %d, %v", status, err)
+	}
+	if want := "HELLO\nfrom stdin\ntwo\nlines\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+	if sh.env.Get("HOME") != "" {
+		t.Error("the environment was inherited as well as given")
+	}
+
+	if _, err := sh.Run(context.Background(), "echo ok\necho 'open"); err == nil {
+		t.Error("an unterminated quote ran without an error")
+	}
+	stdout.Reset()
+	if status, err := sh.Run(context.Background(), "echo before; exit\necho after"); err != nil || status != 0 || stdout.String() != "before\nGoodbye!\n" {
+		t.Errorf("Run with exit = %d, %v, output %q", status, err, stdout.String())
+	}
+
+	// Aliases and dryrun work as at the prompt
+	stdout.Reset()
+	if status, err := sh.Run(context.Background(), "alias greet='echo $GREETING'\ngreet world\ndryrun greet again"); err != nil || status != 0 {
+		t.Errorf("Run with an alias = %d, %v", status, err)
+	}
+	if got, want := stdout.String(), "hello world\n+ echo hello again\n"; got != want {
+		t.Errorf("Run with an alias printed %q, want %q", got, want)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	sh := New(Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := sh.Run(ctx, "sleep 10\necho not reached")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %v after its context ended", elapsed)
+	}
+}
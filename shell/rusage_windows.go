@@ -0,0 +1,11 @@
+//go:build windows
+
+package shell
+
+import "os"
+
+// maxRSS returns the peak resident set size of a finished process in bytes.
+// It is not available on this platform.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,149 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// A variable can hold a reference to a secret in place of the secret, as in
+// export API_KEY=@keychain:my-service, so the secret is never written in
+// the startup file or the history. The shell itself only ever sees the
+// reference, which echo $API_KEY prints; the secret is read from its store
+// when a command is started, and only that command's environment holds it.
+// The stores are:
+//
+//   - @keychain:NAME, the system's: the login keychain on macOS, the Secret
+//     Service (GNOME Keyring or KWallet, through secret-tool) on Linux and
+//     the BSDs, and the Credential Manager on Windows
+//   - @pass:PATH, an entry of pass, the standard Unix password manager
+//   - @op:VAULT/ITEM/FIELD, a 1Password secret reference, read with its
+//     CLI, op
+//
+// A secret is read once a session, the first time a command needs it.
+
+// secretStores maps the prefix of each kind of secret reference to how to
+// read the secret it names
+var secretStores = map[string]func(s *Shell, name string) (string, error){
+	"@keychain:": readKeychain,
+	"@pass:":     readPass,
+	"@op:":       readOnePassword,
+}
+
+// secretCache holds the secrets read in the session, by reference. Pipeline
+// stages may start at once, so it is used under a lock.
+type secretCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// isSecretRef reports whether a variable's value refers to a secret
+func isSecretRef(value string) bool {
+	if !strings.HasPrefix(value, "@") {
+		return false
+	}
+	for prefix := range secretStores {
+		if strings.HasPrefix(value, prefix) && len(value) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecret reads the secret a reference names, or returns the one
+// read before
+func (s *Shell) resolveSecret(ref string) (string, error) {
+	c := &s.secrets
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok := c.values[ref]; ok {
+		return value, nil
+	}
+	for prefix, read := range secretStores {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			value, err := read(s, name)
+			if err != nil {
+				return "", err
+			}
+			if c.values == nil {
+				c.values = make(map[string]string)
+			}
+			c.values[ref] = value
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("unknown secret store")
+}
+
+// childEnv returns the environment for a command the shell starts, with
+// the secrets its variables refer to read in. A variable whose secret can't
+// be read is left out, saying why on stderr, so the command doesn't take
+// the reference for the secret.
+func (s *Shell) childEnv(stderr io.Writer) []string {
+	env := s.env.ToSlice()
+	var resolved []string
+	for i, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		if !isSecretRef(value) {
+			if resolved != nil {
+				resolved = append(resolved, entry)
+			}
+			continue
+		}
+		if resolved == nil {
+			resolved = append(make([]string, 0, len(env)), env[:i]...)
+		}
+		secret, err := s.resolveSecret(value)
+		if err != nil {
+			fmt.Fprintf(stderr, "goshell: %s: can't read %s: %v\n", name, value, err)
+			continue
+		}
+		resolved = append(resolved, name+"="+secret)
+	}
+	if resolved == nil {
+		return env
+	}
+	return resolved
+}
+
+// runSecretTool runs a password manager's command in the shell's
+// environment, which holds its settings and sessions such as OP_SESSION, and
+// returns what it prints, without the final newline. What it says on stderr
+// makes the error.
+func (s *Shell) runSecretTool(args ...string) (string, error) {
+	file, err := s.commandPath(args[0])
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(s.context(), file, args[1:]...)
+	cmd.Env = s.env.ToSlice()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", args[0], msg)
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(stdout.String(), "\n"), "\r"), nil
+}
+
+// readPass reads the password of a pass entry, its first line
+func readPass(s *Shell, name string) (string, error) {
+	out, err := s.runSecretTool("pass", "show", name)
+	first, _, _ := strings.Cut(out, "\n")
+	return first, err
+}
+
+// readOnePassword reads a 1Password secret reference, given with or without
+// its op:// scheme
+func readOnePassword(s *Shell, name string) (string, error) {
+	if !strings.HasPrefix(name, "op://") {
+		name = "op://" + name
+	}
+	return s.runSecretTool("op", "read", "--no-newline", name)
+}
@@ -0,0 +1,10 @@
+//go:build darwin
+
+package shell
+
+// readKeychain reads the password of a generic password item in the login
+// keychain by its service name, as security add-generic-password -s NAME
+// stores it
+func readKeychain(s *Shell, name string) (string, error) {
+	return s.runSecretTool("security", "find-generic-password", "-s", name, "-w")
+}
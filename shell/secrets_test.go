@@ -0,0 +1,51 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSecretReferences(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake password managers are shell scripts")
+	}
+	bin := t.TempDir()
+	calls := filepath.Join(t.TempDir(), "calls")
+	os.WriteFile(filepath.Join(bin, "pass"), []byte("#!/bin/sh\necho \"$@\" >> "+calls+"\n[ \"$2\" = missing ] && { echo 'Error: missing is not in the password store.' >&2; exit 1; }\nprintf 's3cret\\nuser: me\\n'\n"), 0755)
+	os.WriteFile(filepath.Join(bin, "op"), []byte("#!/bin/sh\nprintf '%s' \"$OP_SESSION:$3\"\n"), 0755)
+	shell := New(Options{Env: []string{
+		"PATH=" + bin + ":/bin:/usr/bin",
+		"OP_SESSION=token",
+		"API_KEY=@pass:work/api",
+		"DB_PASSWORD=@op:Dev/db/password",
+		"BROKEN=@pass:missing",
+		"PLAIN=@home",
+	}})
+
+	out, status := runCapture(t, shell, "env")
+	for _, want := range []string{"API_KEY=@pass:work/api\n", "PLAIN=@home\n"} {
+		if status != 0 || !strings.Contains(out, want) {
+			t.Errorf("the env builtin printed %q, want the reference %q", out, want)
+		}
+	}
+
+	out, _ = runCapture(t, shell, "/usr/bin/env")
+	for _, want := range []string{"API_KEY=s3cret\n", "DB_PASSWORD=token:op://Dev/db/password\n", "PLAIN=@home\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("a command's environment %q doesn't have %q", out, want)
+		}
+	}
+	if strings.Contains(out, "BROKEN=") || !strings.Contains(out, "goshell: BROKEN: can't read @pass:missing: pass: Error: missing is not in the password store.") {
+		t.Errorf("a secret that can't be read was passed on or not reported: %q", out)
+	}
+
+	// Each secret is read once
+	runCapture(t, shell, "/usr/bin/env")
+	data, _ := os.ReadFile(calls)
+	if got := strings.Count(string(data), "show work/api"); got != 1 {
+		t.Errorf("pass was run %d times for work/api, want 1", got)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build unix && !darwin
+
+package shell
+
+// readKeychain reads a secret from the Secret Service, which GNOME Keyring
+// and KWallet provide, by its service attribute, as secret-tool store
+// --label=NAME service NAME stores it
+func readKeychain(s *Shell, name string) (string, error) {
+	return s.runSecretTool("secret-tool", "lookup", "service", name)
+}
@@ -0,0 +1,62 @@
+//go:build windows
+
+package shell
+
+import (
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+	credRead = advapi32.NewProc("CredReadW")
+	credFree = advapi32.NewProc("CredFree")
+)
+
+// credTypeGeneric is CRED_TYPE_GENERIC, the type of credentials stored for
+// programs rather than for signing in to Windows
+const credTypeGeneric = 1
+
+// credential is the CREDENTIALW structure CredReadW returns
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// readKeychain reads a generic credential from the Credential Manager by
+// its target name, as cmdkey /generic:NAME /user:USER /pass stores it. The
+// password is UTF-16 when stored by cmdkey and most programs, and taken as
+// UTF-8 otherwise.
+func readKeychain(s *Shell, name string) (string, error) {
+	target, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ret, _, err := credRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		return "", err
+	}
+	defer credFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	if len(blob)%2 == 0 && len(blob) > 0 && blob[1] == 0 {
+		chars := make([]uint16, len(blob)/2)
+		for i := range chars {
+			chars[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+		}
+		return string(utf16.Decode(chars)), nil
+	}
+	return string(blob), nil
+}
@@ -0,0 +1,422 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerBuiltin("sed", "sed [-nE] [-e] SCRIPT [file...]", "Edit lines: s///, d, p and q with addresses", builtinSed)
+	registerFlags("sed",
+		Candidate{"-n", "Only print lines explicitly printed"},
+		Candidate{"-E", "Use extended regular expressions"},
+		Candidate{"-r", "Same as -E"},
+		Candidate{"-e", "Add a script"})
+}
+
+// sedAddress selects lines by number, by the last line ($) or by regex
+type sedAddress struct {
+	line  int // 1-based line number; 0 when unused
+	last  bool
+	regex *regexp.Regexp
+}
+
+// matches reports whether the address selects the given line
+func (a *sedAddress) matches(lineNo int, isLast bool, text string) bool {
+	switch {
+	case a.regex != nil:
+		return a.regex.MatchString(text)
+	case a.last:
+		return isLast
+	}
+	return a.line == lineNo
+}
+
+// sedCommand is a single editing command with its optional address range
+type sedCommand struct {
+	from, to *sedAddress
+	negate   bool
+	inRange  bool // whether a two-address range is currently active
+	name     byte // s, d, p or q
+
+	// Substitution fields
+	pattern     *regexp.Regexp
+	replacement string
+	global      bool
+	occurrence  int
+	print       bool
+}
+
+// selects reports whether the command applies to the current line, tracking
+// range state across lines
+func (c *sedCommand) selects(lineNo int, isLast bool, text string) bool {
+	var hit bool
+	switch {
+	case c.from == nil:
+		hit = true
+	case c.to == nil:
+		hit = c.from.matches(lineNo, isLast, text)
+	case c.inRange:
+		hit = true
+		if c.to.matches(lineNo, isLast, text) || (c.to.regex == nil && !c.to.last && lineNo >= c.to.line) {
+			c.inRange = false
+		}
+	case c.from.matches(lineNo, isLast, text):
+		hit = true
+		// A line-number end at or before the start ends the range at once
+		c.inRange = !(c.to.regex == nil && !c.to.last && c.to.line <= lineNo)
+	}
+	return hit != c.negate
+}
+
+// sedParser walks a script, parsing one command at a time
+type sedParser struct {
+	script   string
+	pos      int
+	extended bool
+}
+
+// parseSedScript parses a script of ;- or newline-separated commands
+func parseSedScript(script string, extended bool) ([]*sedCommand, error) {
+	p := &sedParser{script: script, extended: extended}
+	var commands []*sedCommand
+	for {
+		p.skip(" \t\n;")
+		if p.pos >= len(p.script) {
+			return commands, nil
+		}
+		cmd, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, cmd)
+	}
+}
+
+// skip advances past any of the given characters
+func (p *sedParser) skip(chars string) {
+	for p.pos < len(p.script) && strings.IndexByte(chars, p.script[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// command parses an optionally addressed command
+func (p *sedParser) command() (*sedCommand, error) {
+	cmd := &sedCommand{}
+	var err error
+	if cmd.from, err = p.address(); err != nil {
+		return nil, err
+	}
+	if cmd.from != nil && p.pos < len(p.script) && p.script[p.pos] == ',' {
+		p.pos++
+		if cmd.to, err = p.address(); err != nil {
+			return nil, err
+		}
+		if cmd.to == nil {
+			return nil, fmt.Errorf("unexpected `,'")
+		}
+	}
+	p.skip(" \t")
+	if p.pos < len(p.script) && p.script[p.pos] == '!' {
+		cmd.negate = true
+		p.pos++
+		p.skip(" \t")
+	}
+	if p.pos >= len(p.script) {
+		return nil, fmt.Errorf("missing command")
+	}
+
+	cmd.name = p.script[p.pos]
+	p.pos++
+	switch cmd.name {
+	case 'd', 'p', 'q':
+	case 's':
+		if err := p.substitution(cmd); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown command: `%c'", cmd.name)
+	}
+	return cmd, nil
+}
+
+// address parses a line number, $ or /regex/, returning nil if none is present
+func (p *sedParser) address() (*sedAddress, error) {
+	if p.pos >= len(p.script) {
+		return nil, nil
+	}
+	c := p.script[p.pos]
+	switch {
+	case c == '$':
+		p.pos++
+		return &sedAddress{last: true}, nil
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.script) && p.script[p.pos] >= '0' && p.script[p.pos] <= '9' {
+			p.pos++
+		}
+		n, _ := strconv.Atoi(p.script[start:p.pos])
+		return &sedAddress{line: n}, nil
+	case c == '/':
+		p.pos++
+		pattern, err := p.delimited('/')
+		if err != nil {
+			return nil, err
+		}
+		re, err := compileSedRegex(pattern, p.extended, false)
+		if err != nil {
+			return nil, err
+		}
+		return &sedAddress{regex: re}, nil
+	}
+	return nil, nil
+}
+
+// delimited reads text up to an unescaped delimiter, consuming it. Escaped
+// delimiters are unescaped; other escapes are kept for the regex engine.
+func (p *sedParser) delimited(delim byte) (string, error) {
+	var b strings.Builder
+	for p.pos < len(p.script) {
+		c := p.script[p.pos]
+		if c == '\\' && p.pos+1 < len(p.script) {
+			if p.script[p.pos+1] == delim {
+				b.WriteByte(delim)
+			} else {
+				b.WriteString(p.script[p.pos : p.pos+2])
+			}
+			p.pos += 2
+			continue
+		}
+		p.pos++
+		if c == delim {
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+	}
+	return "", fmt.Errorf("unterminated `s' command")
+}
+
+// substitution parses the body of an s command: delimiter, pattern,
+// replacement and flags
+func (p *sedParser) substitution(cmd *sedCommand) error {
+	if p.pos >= len(p.script) {
+		return fmt.Errorf("unterminated `s' command")
+	}
+	delim := p.script[p.pos]
+	p.pos++
+	pattern, err := p.delimited(delim)
+	if err != nil {
+		return err
+	}
+	replacement, err := p.delimited(delim)
+	if err != nil {
+		return err
+	}
+
+	ignoreCase := false
+	for p.pos < len(p.script) && !strings.ContainsRune(" \t\n;", rune(p.script[p.pos])) {
+		c := p.script[p.pos]
+		switch {
+		case c == 'g':
+			cmd.global = true
+		case c == 'p':
+			cmd.print = true
+		case c == 'i' || c == 'I':
+			ignoreCase = true
+		case c >= '0' && c <= '9':
+			cmd.occurrence = cmd.occurrence*10 + int(c-'0')
+		default:
+			return fmt.Errorf("unknown option to `s': %c", c)
+		}
+		p.pos++
+	}
+
+	if cmd.pattern, err = compileSedRegex(pattern, p.extended, ignoreCase); err != nil {
+		return err
+	}
+	cmd.replacement = convertSedReplacement(replacement)
+	return nil
+}
+
+// compileSedRegex compiles a sed pattern. Basic regular expressions are
+// translated to Go syntax: \( \) \{ \} \+ \? and \| are operators while their
+// bare forms are literal.
+func compileSedRegex(pattern string, extended, ignoreCase bool) (*regexp.Regexp, error) {
+	if !extended {
+		var b strings.Builder
+		for i := 0; i < len(pattern); i++ {
+			c := pattern[i]
+			if c == '\\' && i+1 < len(pattern) && strings.IndexByte("(){}+?|", pattern[i+1]) >= 0 {
+				b.WriteByte(pattern[i+1])
+				i++
+				continue
+			}
+			if c == '\\' && i+1 < len(pattern) {
+				b.WriteString(pattern[i : i+2])
+				i++
+				continue
+			}
+			if strings.IndexByte("(){}+?|", c) >= 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		pattern = b.String()
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// convertSedReplacement rewrites a sed replacement (& and \1..\9) into the
+// template syntax used by regexp.Expand
+func convertSedReplacement(repl string) string {
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		switch {
+		case c == '\\' && i+1 < len(repl):
+			next := repl[i+1]
+			i++
+			switch {
+			case next >= '0' && next <= '9':
+				fmt.Fprintf(&b, "${%c}", next)
+			case next == 'n':
+				b.WriteByte('\n')
+			case next == 't':
+				b.WriteByte('\t')
+			case next == '$':
+				b.WriteString("$$")
+			default:
+				b.WriteByte(next)
+			}
+		case c == '&':
+			b.WriteString("${0}")
+		case c == '$':
+			b.WriteString("$$")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// substitute applies an s command to a line, reporting whether it replaced
+// anything
+func (c *sedCommand) substitute(line string) (string, bool) {
+	matches := c.pattern.FindAllStringSubmatchIndex(line, -1)
+	if len(matches) == 0 {
+		return line, false
+	}
+
+	var b strings.Builder
+	last := 0
+	replaced := false
+	for i, m := range matches {
+		n := i + 1
+		wanted := (c.occurrence == 0 && (c.global || n == 1)) ||
+			(c.occurrence > 0 && (n == c.occurrence || (c.global && n > c.occurrence)))
+		if !wanted {
+			continue
+		}
+		b.WriteString(line[last:m[0]])
+		b.Write(c.pattern.ExpandString(nil, c.replacement, line, m))
+		last = m[1]
+		replaced = true
+	}
+	b.WriteString(line[last:])
+	return b.String(), replaced
+}
+
+// builtinSed is a minimal stream editor supporting s///, d, p and q with
+// line-number, $ and /regex/ addresses and ranges. -n disables automatic
+// printing and -E selects extended regular expressions.
+func builtinSed(s *Shell, args []string, stdio Stdio) int {
+	quiet, extended := false, false
+	var scripts []string
+	flags := newFlagSet("sed")
+	flags.Bool(&quiet, "n")
+	flags.Bool(&extended, "E", "r")
+	flags.Func(func(script string) error {
+		scripts = append(scripts, script)
+		return nil
+	}, "e")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	// Without -e the first operand is the script
+	if len(scripts) == 0 {
+		if len(files) == 0 {
+			return flags.usage(stdio)
+		}
+		scripts, files = files[:1], files[1:]
+	}
+
+	commands, err := parseSedScript(strings.Join(scripts, "\n"), extended)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "sed:", err)
+		return 1
+	}
+
+	input, closeInputs, err := openInputs(files, stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "sed:", err)
+		return 1
+	}
+	defer closeInputs()
+
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	reader := bufio.NewReader(input)
+
+	// Read one line ahead so $ can be recognised
+	next, err := readLine(reader)
+	lineNo := 0
+	for err == nil {
+		line := next
+		lineNo++
+		next, err = readLine(reader)
+		isLast := err != nil
+
+		deleted, quit := false, false
+		for _, cmd := range commands {
+			if !cmd.selects(lineNo, isLast, line) {
+				continue
+			}
+			switch cmd.name {
+			case 's':
+				var replaced bool
+				line, replaced = cmd.substitute(line)
+				if replaced && cmd.print {
+					fmt.Fprintln(out, line)
+				}
+			case 'd':
+				deleted = true
+			case 'p':
+				fmt.Fprintln(out, line)
+			case 'q':
+				quit = true
+			}
+			if deleted || quit {
+				break
+			}
+		}
+		if !deleted && !quiet {
+			fmt.Fprintln(out, line)
+		}
+		if quit {
+			return 0
+		}
+	}
+	if err != io.EOF {
+		fmt.Fprintln(stdio.Stderr, "sed:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package shell
+
+import "testing"
+
+func TestSed(t *testing.T) {
+	input := "one\ntwo\nthree\nfour\n"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"sed", "s/o/0/"}, "0ne\ntw0\nthree\nf0ur\n"},
+		{[]string{"sed", "s/e/E/g"}, "onE\ntwo\nthrEE\nfour\n"},
+		{[]string{"sed", "s/e/E/2"}, "one\ntwo\nthreE\nfour\n"},
+		{[]string{"sed", "2d"}, "one\nthree\nfour\n"},
+		{[]string{"sed", "-n", "2,3p"}, "two\nthree\n"},
+		{[]string{"sed", "-n", "$p"}, "four\n"},
+		{[]string{"sed", "/^t/d"}, "one\nfour\n"},
+		{[]string{"sed", "/two/,/three/s/^/> /"}, "one\n> two\n> three\nfour\n"},
+		{[]string{"sed", "2q"}, "one\ntwo\n"},
+		{[]string{"sed", "1!d"}, "one\n"},
+		{[]string{"sed", "s/\\(o\\)\\(n\\)/\\2\\1/"}, "noe\ntwo\nthree\nfour\n"},
+		{[]string{"sed", "-E", "s/(t)(w|h)/[&]/"}, "one\n[tw]o\n[th]ree\nfour\n"},
+		{[]string{"sed", "-e", "s|o|/|", "-e", "3d"}, "/ne\ntw/\nf/ur\n"},
+		{[]string{"sed", "s/one/a$b/"}, "a$b\ntwo\nthree\nfour\n"},
+	}
+	for _, tt := range tests {
+		if got, status := runBuiltin(t, tt.args, input); status != 0 || got != tt.want {
+			t.Errorf("%v = %q (status %d), want %q", tt.args, got, status, tt.want)
+		}
+	}
+
+	for _, script := range []string{"s/a/b", "x", "s/a/b/z"} {
+		if _, status := runBuiltin(t, []string{"sed", script}, input); status == 0 {
+			t.Errorf("sed %q succeeded, want error", script)
+		}
+	}
+}
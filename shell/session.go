@@ -0,0 +1,253 @@
+package shell
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// goshell attach NAME runs the shell behind a session server that holds
+// its terminal, a pseudo-terminal, so closing the terminal window or losing
+// the SSH connection it was opened over only detaches from the session, and
+// attach NAME again picks it up as it was. Ctrl-\ detaches on purpose.
+// goshell sessions lists the sessions running. Each server listens on a
+// Unix socket named after its session, in a directory only the user can
+// enter, and ends when the shell in it exits.
+
+// defaultSessionName is the session goshell attach attaches to without a
+// name
+const defaultSessionName = "main"
+
+// sessionDetachKey detaches from a session, as in dtach: Ctrl-\
+const sessionDetachKey = 0x1c
+
+// sessionScreenSize is how much of a session's latest output is kept, to
+// show again on attaching
+const sessionScreenSize = 32 << 10
+
+// sessionMaxMessage bounds the messages read from the other end
+const sessionMaxMessage = 1 << 20
+
+// Messages between goshell attach and the session server are a type, the
+// length of what follows as 4 bytes, and that
+const (
+	sessionAttach = 'a' // client: attach, with the window size
+	sessionQuery  = 'q' // client: describe the session and hang up
+	sessionInfo   = 'i' // server: the description, as JSON
+	sessionData   = 'd' // input for the shell, or output from it
+	sessionResize = 'w' // client: the window's new size
+	sessionExit   = 'x' // server: the shell exited, with its status
+	sessionTaken  = 't' // server: the session was attached from elsewhere
+)
+
+// sessionDescription is what a session server says about its session
+type sessionDescription struct {
+	Name     string    `json:"name"`
+	PID      int       `json:"pid"` // of the shell
+	Started  time.Time `json:"started"`
+	Attached bool      `json:"attached"`
+}
+
+// writeSessionMessage sends a message to the other end
+func writeSessionMessage(w io.Writer, typ byte, payload []byte) error {
+	msg := make([]byte, 5, 5+len(payload))
+	msg[0] = typ
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(payload)))
+	_, err := w.Write(append(msg, payload...))
+	return err
+}
+
+// readSessionMessage reads a message from the other end
+func readSessionMessage(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > sessionMaxMessage {
+		return 0, nil, fmt.Errorf("session message of %d bytes is too long", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// windowSizeMessage is the payload of an attach or resize message
+func windowSizeMessage(rows, cols int) []byte {
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, uint16(rows)), uint16(cols))
+}
+
+// parseWindowSize reads the rows and columns of a window size message
+func parseWindowSize(payload []byte) (rows, cols uint16, ok bool) {
+	if len(payload) != 4 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:]), true
+}
+
+// validSessionName reports whether a session can be called name, which
+// names its socket
+func validSessionName(name string) bool {
+	if name == "" || name[0] == '.' || len(name) > 64 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionDir returns the directory holding the sockets of the user's
+// sessions, creating it on first use. It lives under XDG_RUNTIME_DIR when
+// that is set, and must be the user's alone.
+func sessionDir() (string, error) {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		base = os.TempDir()
+	}
+	dir := filepath.Join(base, "goshell-sessions-"+strconv.Itoa(os.Getuid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s is not a private directory", dir)
+	}
+	return dir, nil
+}
+
+// sessionSocket returns the path of the socket of a session
+func sessionSocket(name string) (string, error) {
+	if !validSessionName(name) {
+		return "", fmt.Errorf("%q is not a session name; use letters, digits, '-', '_' and '.'", name)
+	}
+	dir, err := sessionDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".sock"), nil
+}
+
+// describeSession asks the server of a session about it
+func describeSession(path string) (*sessionDescription, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if err := writeSessionMessage(conn, sessionQuery, nil); err != nil {
+		return nil, err
+	}
+	typ, payload, err := readSessionMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if typ != sessionInfo {
+		return nil, errors.New("unexpected answer from the session")
+	}
+	var desc sessionDescription
+	return &desc, json.Unmarshal(payload, &desc)
+}
+
+// listSessions writes the user's running sessions, removing the sockets
+// of servers that have gone
+func listSessions(w, errOut io.Writer) int {
+	dir, err := sessionDir()
+	if err != nil {
+		fmt.Fprintln(errOut, "goshell sessions:", err)
+		return 1
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintln(errOut, "goshell sessions:", err)
+		return 1
+	}
+	var sessions []*sessionDescription
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".sock") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		desc, err := describeSession(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) || isConnRefused(err) {
+				os.Remove(path)
+			}
+			continue
+		}
+		sessions = append(sessions, desc)
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(w, "No sessions; goshell attach NAME starts one")
+		return 0
+	}
+	slices.SortFunc(sessions, func(a, b *sessionDescription) int { return strings.Compare(a.Name, b.Name) })
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tSTARTED\tPID")
+	for _, desc := range sessions {
+		status := "detached"
+		if desc.Attached {
+			status = "attached"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", desc.Name, status, desc.Started.Local().Format("2006-01-02 15:04"), desc.PID)
+	}
+	tw.Flush()
+	return 0
+}
+
+// sessionMain runs goshell attach and goshell sessions, and the session
+// server attach starts, reporting whether args named one of them and the
+// exit status
+func sessionMain(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "attach":
+		name := defaultSessionName
+		switch len(args) {
+		case 1:
+		case 2:
+			name = args[1]
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: goshell attach [NAME]")
+			return 2, true
+		}
+		return attachSession(name), true
+	case "sessions":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: goshell sessions")
+			return 2, true
+		}
+		return listSessions(os.Stdout, os.Stderr), true
+	case sessionServerFlag:
+		if len(args) != 2 {
+			return 2, true
+		}
+		return serveSession(args[1]), true
+	}
+	return 0, false
+}
+
+// sessionServerFlag starts goshell as the server of the session named
+// after it, as attach does in the background
+const sessionServerFlag = "--session-server"
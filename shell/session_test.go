@@ -0,0 +1,145 @@
+//go:build unix
+
+package shell
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionMessages(t *testing.T) {
+	var buf bytes.Buffer
+	writeSessionMessage(&buf, sessionAttach, windowSizeMessage(50, 132))
+	writeSessionMessage(&buf, sessionData, []byte("ls\r"))
+	writeSessionMessage(&buf, sessionTaken, nil)
+
+	typ, payload, err := readSessionMessage(&buf)
+	if rows, cols, ok := parseWindowSize(payload); typ != sessionAttach || err != nil || !ok || rows != 50 || cols != 132 {
+		t.Errorf("attach message = %c %v %v, size %dx%d", typ, payload, err, rows, cols)
+	}
+	if typ, payload, err := readSessionMessage(&buf); typ != sessionData || string(payload) != "ls\r" || err != nil {
+		t.Errorf("data message = %c %q %v", typ, payload, err)
+	}
+	if typ, payload, err := readSessionMessage(&buf); typ != sessionTaken || len(payload) != 0 || err != nil {
+		t.Errorf("taken message = %c %q %v", typ, payload, err)
+	}
+	if _, _, err := readSessionMessage(strings.NewReader("d\xff\xff\xff\xff")); err == nil {
+		t.Error("an overlong message was read")
+	}
+}
+
+func TestValidSessionName(t *testing.T) {
+	for _, name := range []string{"main", "work-2", "api_server.prod"} {
+		if !validSessionName(name) {
+			t.Errorf("validSessionName(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"", ".hidden", "a/b", "../x", "with space", strings.Repeat("x", 65)} {
+		if validSessionName(name) {
+			t.Errorf("validSessionName(%q) = true", name)
+		}
+	}
+}
+
+// readSessionUntil reads a session's messages until its output holds want
+// or a message of type until arrives, returning the output and that message
+func readSessionUntil(t *testing.T, conn net.Conn, want string, until byte) (string, []byte) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var out strings.Builder
+	for {
+		typ, payload, err := readSessionMessage(conn)
+		if err != nil {
+			t.Fatalf("reading the session: %v; output so far %q", err, out.String())
+		}
+		switch {
+		case typ == sessionData:
+			out.Write(payload)
+			if want != "" && strings.Contains(out.String(), want) {
+				return out.String(), nil
+			}
+		case typ == until:
+			return out.String(), payload
+		}
+	}
+}
+
+func TestSessionServer(t *testing.T) {
+	// Socket paths are short, so the directory is too
+	dir, err := os.MkdirTemp("", "gs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	socket, err := sessionSocket("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("/bin/sh", "-c", `stty -echo; echo ready; while read line; do [ "$line" = quit ] && exit 3; echo "got $line"; done`)
+	served := make(chan int, 1)
+	go func() { served <- runSessionServer("test", ln, cmd) }()
+
+	first, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	writeSessionMessage(first, sessionAttach, windowSizeMessage(30, 100))
+	readSessionUntil(t, first, "ready", 0)
+	writeSessionMessage(first, sessionData, []byte("one\r"))
+	readSessionUntil(t, first, "got one", 0)
+
+	var out bytes.Buffer
+	listSessions(&out, &out)
+	if !strings.Contains(out.String(), "test  attached") {
+		t.Errorf("sessions = %q, want test attached", out.String())
+	}
+	// A socket nothing listens on is cleaned up
+	stale := filepath.Join(filepath.Dir(socket), "gone.sock")
+	if l, err := net.Listen("unix", stale); err == nil {
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+	}
+	out.Reset()
+	listSessions(&out, &out)
+	if strings.Contains(out.String(), "gone") {
+		t.Errorf("sessions = %q, listing a server that has gone", out.String())
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("the stale socket is still there: %v", err)
+	}
+
+	// Attaching from elsewhere takes the session over, showing its output
+	// so far again
+	second, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	writeSessionMessage(second, sessionAttach, windowSizeMessage(30, 100))
+	readSessionUntil(t, first, "", sessionTaken)
+	readSessionUntil(t, second, "got one", 0)
+	writeSessionMessage(second, sessionData, []byte("two\r"))
+	readSessionUntil(t, second, "got two", 0)
+
+	writeSessionMessage(second, sessionData, []byte("quit\r"))
+	if _, status := readSessionUntil(t, second, "", sessionExit); !bytes.Equal(status, []byte{3}) {
+		t.Errorf("exit status = %v, want 3", status)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server didn't stop with its shell")
+	}
+}
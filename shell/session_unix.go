@@ -0,0 +1,377 @@
+//go:build unix
+
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// sessionStartTimeout bounds waiting for a new session's server to listen
+const sessionStartTimeout = 5 * time.Second
+
+// sessionServer holds the terminal of a session's shell and relays it to
+// the client attached, if any
+type sessionServer struct {
+	name    string
+	pty     *os.File
+	pid     int
+	started time.Time
+
+	mu     sync.Mutex
+	client net.Conn // the attached client
+	screen []byte   // the latest output, shown again on attaching
+}
+
+// isConnRefused reports whether dialing a socket failed as nothing listens
+// on it any more
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// startSessionServer starts the server of a session in the background, in
+// a session of its own so it outlives the terminal. What it reports goes
+// to a log file beside its socket.
+func startSessionServer(name, socket string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	log, err := os.OpenFile(sessionLog(socket), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	cmd := exec.Command(exe, sessionServerFlag, name)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// sessionLog is the file the server of a session reports to
+func sessionLog(socket string) string {
+	return strings.TrimSuffix(socket, ".sock") + ".log"
+}
+
+// serveSession runs the server of a session: the shell on a terminal of
+// its own, and a socket for attaching to it
+func serveSession(name string) int {
+	socket, err := sessionSocket(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goshell:", err)
+		return 1
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goshell:", err)
+		return 1
+	}
+	defer os.Remove(socket)
+	defer func() {
+		if info, err := os.Stat(sessionLog(socket)); err == nil && info.Size() == 0 {
+			os.Remove(sessionLog(socket))
+		}
+	}()
+	signal.Ignore(syscall.SIGHUP, syscall.SIGINT, syscall.SIGPIPE)
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goshell:", err)
+		return 1
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), "GOSHELL_SESSION="+name)
+	return runSessionServer(name, ln, cmd)
+}
+
+// runSessionServer runs cmd on a new terminal and relays it to the clients
+// that attach on ln until cmd exits, which ends the session
+func runSessionServer(name string, ln net.Listener, cmd *exec.Cmd) int {
+	defer ln.Close()
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 24, Cols: 80})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goshell:", err)
+		return 1
+	}
+	defer ptmx.Close()
+	s := &sessionServer{name: name, pty: ptmx, pid: cmd.Process.Pid, started: time.Now()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	// Programs the shell left running in the background may hold the
+	// terminal open, so its output is only waited for a moment after the
+	// shell exits
+	relayed := make(chan struct{})
+	go func() {
+		defer close(relayed)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := ptmx.Read(buf)
+			if n > 0 {
+				s.output(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	status := 0
+	if err := cmd.Wait(); err != nil {
+		status = exitStatus(err)
+	}
+	select {
+	case <-relayed:
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		writeSessionMessage(s.client, sessionExit, []byte{byte(status)})
+		s.client.Close()
+	}
+	return 0
+}
+
+// output keeps the shell's output for showing on attaching and sends it to
+// the client attached. A client that stops reading is dropped rather than
+// holding the shell up.
+func (s *sessionServer) output(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.screen = append(s.screen, p...)
+	if len(s.screen) > sessionScreenSize {
+		s.screen = append([]byte(nil), s.screen[len(s.screen)-sessionScreenSize:]...)
+	}
+	if s.client == nil {
+		return
+	}
+	s.client.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := writeSessionMessage(s.client, sessionData, p); err != nil {
+		s.client.Close()
+		s.client = nil
+	}
+}
+
+// serve answers a client: describes the session, or attaches it, taking
+// the session from any client attached before
+func (s *sessionServer) serve(conn net.Conn) {
+	typ, payload, err := readSessionMessage(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	switch typ {
+	case sessionQuery:
+		s.mu.Lock()
+		desc := sessionDescription{Name: s.name, PID: s.pid, Started: s.started, Attached: s.client != nil}
+		s.mu.Unlock()
+		data, _ := json.Marshal(desc)
+		writeSessionMessage(conn, sessionInfo, data)
+		conn.Close()
+		return
+	case sessionAttach:
+	default:
+		conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	if s.client != nil {
+		writeSessionMessage(s.client, sessionTaken, nil)
+		s.client.Close()
+	}
+	s.client = conn
+	// The screen is cleared and the latest output shown again from the
+	// start of a line, so it doesn't begin inside an escape sequence
+	screen := s.screen
+	if len(screen) == sessionScreenSize {
+		if i := bytes.IndexByte(screen, '\n'); i >= 0 {
+			screen = screen[i+1:]
+		}
+	}
+	writeSessionMessage(conn, sessionData, append([]byte("\x1b[H\x1b[2J"), screen...))
+	s.mu.Unlock()
+	s.resize(payload)
+	s.redraw()
+
+	for {
+		typ, payload, err := readSessionMessage(conn)
+		if err != nil {
+			break
+		}
+		switch typ {
+		case sessionData:
+			s.pty.Write(payload)
+		case sessionResize:
+			s.resize(payload)
+		}
+	}
+	s.mu.Lock()
+	if s.client == conn {
+		s.client = nil
+	}
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// resize sets the size of the session's terminal to the client's window
+func (s *sessionServer) resize(payload []byte) {
+	if rows, cols, ok := parseWindowSize(payload); ok && rows > 0 && cols > 0 {
+		pty.Setsize(s.pty, &pty.Winsize{Rows: rows, Cols: cols})
+	}
+}
+
+// redraw has the program in the foreground of the session draw its screen
+// again, as it does when the window changes size
+func (s *sessionServer) redraw() {
+	if pgrp, err := unix.IoctlGetInt(int(s.pty.Fd()), unix.TIOCGPGRP); err == nil && pgrp > 0 {
+		unix.Kill(-pgrp, unix.SIGWINCH)
+	}
+}
+
+// dialSession connects to the server of a session, starting it first if
+// it isn't running
+func dialSession(name string) (net.Conn, error) {
+	socket, err := sessionSocket(name)
+	if err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		return conn, nil
+	}
+	// A socket left by a server that has gone is in the way
+	os.Remove(socket)
+	if err := startSessionServer(name, socket); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(sessionStartTimeout)
+	for {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the session didn't start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// attachSession attaches the terminal to a session, starting it if need be,
+// until it is detached with Ctrl-\ or from elsewhere, or the shell in it
+// exits, whose status it then exits with
+func attachSession(name string) int {
+	if os.Getenv("GOSHELL_SESSION") == name {
+		fmt.Fprintf(os.Stderr, "goshell attach: already in session %s\n", name)
+		return 1
+	}
+	in := int(os.Stdin.Fd())
+	if !term.IsTerminal(in) {
+		fmt.Fprintln(os.Stderr, "goshell attach: needs a terminal")
+		return 1
+	}
+	conn, err := dialSession(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goshell attach:", err)
+		return 1
+	}
+	defer conn.Close()
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goshell attach:", err)
+		return 1
+	}
+
+	// Input, window sizes and the detach key are written concurrently
+	var writeMu sync.Mutex
+	send := func(typ byte, payload []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return writeSessionMessage(conn, typ, payload)
+	}
+	windowSize := func() []byte {
+		cols, rows, err := term.GetSize(in)
+		if err != nil {
+			cols, rows = 80, 24
+		}
+		return windowSizeMessage(rows, cols)
+	}
+	send(sessionAttach, windowSize())
+
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	defer signal.Stop(resized)
+	go func() {
+		for range resized {
+			send(sessionResize, windowSize())
+		}
+	}()
+
+	ended := make(chan string, 2)
+	status := 0
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if i := bytes.IndexByte(buf[:n], sessionDetachKey); i >= 0 {
+				send(sessionData, buf[:i])
+				ended <- "detached from session " + name
+				return
+			}
+			if n > 0 && send(sessionData, buf[:n]) != nil || err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			typ, payload, err := readSessionMessage(conn)
+			if err != nil {
+				ended <- "lost the connection to session " + name
+				return
+			}
+			switch typ {
+			case sessionData:
+				os.Stdout.Write(payload)
+			case sessionTaken:
+				ended <- "session " + name + " was attached elsewhere"
+				return
+			case sessionExit:
+				if len(payload) == 1 {
+					status = int(payload[0])
+				}
+				ended <- "session " + name + " ended"
+				return
+			}
+		}
+	}()
+	why := <-ended
+	term.Restore(in, state)
+	fmt.Fprintf(os.Stderr, "\r\n[%s]\n", why)
+	return status
+}
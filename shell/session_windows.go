@@ -0,0 +1,28 @@
+//go:build windows
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// isConnRefused reports whether dialing a socket failed as nothing listens
+// on it any more
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.Errno(10061)) // WSAECONNREFUSED
+}
+
+// serveSession is unavailable, as Windows has no pseudo-terminals for it
+func serveSession(name string) int {
+	fmt.Fprintln(os.Stderr, "goshell: sessions aren't supported on Windows")
+	return 1
+}
+
+// attachSession is unavailable, as Windows has no pseudo-terminals for it
+func attachSession(name string) int {
+	fmt.Fprintln(os.Stderr, "goshell attach: sessions aren't supported on Windows")
+	return 1
+}
@@ -0,0 +1,444 @@
+package shell
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Remote mode lists and checks directories on the remote host with SFTP, the
+// file transfer subsystem every OpenSSH server runs. sftpClient speaks the
+// few requests of version 3 of its protocol that takes, one at a time:
+// reading directories, stat and lstat, readlink and realpath.
+
+// SFTP packet types
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpClose    = 4
+	sftpLstat    = 7
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpReadlink = 19
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpName     = 104
+	sftpAttrs    = 105
+)
+
+// SFTP status codes and attribute flags
+const (
+	sftpStatusEOF    = 1
+	sftpNoSuchFile   = 2
+	sftpDenied       = 3
+	sftpAttrSize     = 0x1
+	sftpAttrUIDGID   = 0x2
+	sftpAttrPerms    = 0x4
+	sftpAttrTimes    = 0x8
+	sftpAttrExtended = 0x80000000
+)
+
+// sftpMaxPacket bounds the packets read from the server
+const sftpMaxPacket = 1 << 20
+
+// sftpClient makes SFTP requests over the streams of the subsystem
+type sftpClient struct {
+	mu     sync.Mutex
+	r      io.Reader
+	w      io.Writer
+	nextID uint32
+}
+
+// sftpFileInfo is a remote file, as SFTP describes it
+type sftpFileInfo struct {
+	name  string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+	owner *remoteOwner // from the long name of a directory entry
+}
+
+// remoteOwner is the number of links, owner and group of a remote file, as
+// the server lists them
+type remoteOwner struct {
+	links       uint64
+	user, group string
+}
+
+func (fi *sftpFileInfo) Name() string       { return fi.name }
+func (fi *sftpFileInfo) Size() int64        { return fi.size }
+func (fi *sftpFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *sftpFileInfo) ModTime() time.Time { return fi.mtime }
+func (fi *sftpFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *sftpFileInfo) Sys() any           { return fi.owner }
+
+// sftpError is an error status the server answered with
+type sftpError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return fmt.Sprintf("sftp error %d", e.code)
+}
+
+// Is makes a missing file fs.ErrNotExist and a refused one fs.ErrPermission
+func (e *sftpError) Is(target error) bool {
+	return e.code == sftpNoSuchFile && target == fs.ErrNotExist ||
+		e.code == sftpDenied && target == fs.ErrPermission
+}
+
+// newSFTPClient starts an SFTP session over a server's input and output
+func newSFTPClient(r io.Reader, w io.Writer) (*sftpClient, error) {
+	c := &sftpClient{r: r, w: w}
+	var init sftpPacket
+	init.putByte(sftpInit)
+	init.putUint32(3)
+	if err := c.send(init); err != nil {
+		return nil, err
+	}
+	reply, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+	if typ, _ := reply.readByte(); typ != sftpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet %d", typ)
+	}
+	return c, nil
+}
+
+// sftpPacket builds a request or reads a reply
+type sftpPacket []byte
+
+func (p *sftpPacket) putByte(b byte)     { *p = append(*p, b) }
+func (p *sftpPacket) putUint32(n uint32) { *p = binary.BigEndian.AppendUint32(*p, n) }
+func (p *sftpPacket) putString(s string) { p.putUint32(uint32(len(s))); *p = append(*p, s...) }
+
+func (p *sftpPacket) skip(n int) error {
+	if len(*p) < n {
+		return errSFTPShort
+	}
+	*p = (*p)[n:]
+	return nil
+}
+
+// errSFTPShort is a reply that ends too soon
+var errSFTPShort = errors.New("sftp: short packet")
+
+func (p *sftpPacket) readByte() (byte, error) {
+	if len(*p) < 1 {
+		return 0, errSFTPShort
+	}
+	b := (*p)[0]
+	*p = (*p)[1:]
+	return b, nil
+}
+
+func (p *sftpPacket) readUint32() (uint32, error) {
+	if len(*p) < 4 {
+		return 0, errSFTPShort
+	}
+	n := binary.BigEndian.Uint32(*p)
+	*p = (*p)[4:]
+	return n, nil
+}
+
+func (p *sftpPacket) readUint64() (uint64, error) {
+	if len(*p) < 8 {
+		return 0, errSFTPShort
+	}
+	n := binary.BigEndian.Uint64(*p)
+	*p = (*p)[8:]
+	return n, nil
+}
+
+func (p *sftpPacket) readString() (string, error) {
+	n, err := p.readUint32()
+	if err != nil || uint32(len(*p)) < n {
+		return "", errSFTPShort
+	}
+	s := string((*p)[:n])
+	*p = (*p)[n:]
+	return s, nil
+}
+
+// readAttrs reads a file's attributes into info
+func (p *sftpPacket) readAttrs(info *sftpFileInfo) error {
+	flags, err := p.readUint32()
+	if err != nil {
+		return err
+	}
+	if flags&sftpAttrSize != 0 {
+		size, err := p.readUint64()
+		if err != nil {
+			return err
+		}
+		info.size = int64(size)
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		if err := p.skip(8); err != nil {
+			return err
+		}
+	}
+	if flags&sftpAttrPerms != 0 {
+		perms, err := p.readUint32()
+		if err != nil {
+			return err
+		}
+		info.mode = sftpFileMode(perms)
+	}
+	if flags&sftpAttrTimes != 0 {
+		if err := p.skip(4); err != nil { // the access time
+			return err
+		}
+		mtime, err := p.readUint32()
+		if err != nil {
+			return err
+		}
+		info.mtime = time.Unix(int64(mtime), 0)
+	}
+	if flags&sftpAttrExtended != 0 {
+		count, err := p.readUint32()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < 2*count; i++ {
+			if _, err := p.readString(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sftpFileMode converts Unix permission bits, with the file type, to a
+// FileMode
+func sftpFileMode(perms uint32) fs.FileMode {
+	mode := fs.FileMode(perms & 0777)
+	switch perms & 0170000 {
+	case 0040000:
+		mode |= fs.ModeDir
+	case 0120000:
+		mode |= fs.ModeSymlink
+	case 0020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0060000:
+		mode |= fs.ModeDevice
+	case 0010000:
+		mode |= fs.ModeNamedPipe
+	case 0140000:
+		mode |= fs.ModeSocket
+	}
+	if perms&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if perms&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if perms&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// send writes a packet, prefixed with its length
+func (c *sftpClient) send(p sftpPacket) error {
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(p)), uint32(len(p)))
+	_, err := c.w.Write(append(frame, p...))
+	return err
+}
+
+// receive reads a packet
+func (c *sftpClient) receive() (sftpPacket, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(c.r, length[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 || n > sftpMaxPacket {
+		return nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	p := make(sftpPacket, n)
+	if _, err := io.ReadFull(c.r, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// request sends a request of a type with a path or handle, and returns the
+// type and rest of the reply, or the error a status reply holds
+func (c *sftpClient) request(typ byte, arg string) (byte, sftpPacket, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	id := c.nextID
+	var p sftpPacket
+	p.putByte(typ)
+	p.putUint32(id)
+	p.putString(arg)
+	if err := c.send(p); err != nil {
+		return 0, nil, err
+	}
+	reply, err := c.receive()
+	if err != nil {
+		return 0, nil, err
+	}
+	replyType, err := reply.readByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	if replyID, err := reply.readUint32(); err != nil || replyID != id {
+		return 0, nil, fmt.Errorf("sftp: reply to the wrong request")
+	}
+	if replyType == sftpStatus {
+		code, err := reply.readUint32()
+		if err != nil {
+			return 0, nil, err
+		}
+		msg, _ := reply.readString()
+		return sftpStatus, nil, &sftpError{code: code, msg: msg}
+	}
+	return replyType, reply, nil
+}
+
+// names reads the entries of a name reply
+func (p *sftpPacket) names() ([]*sftpFileInfo, error) {
+	count, err := p.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	var infos []*sftpFileInfo
+	for i := uint32(0); i < count; i++ {
+		name, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		long, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		info := &sftpFileInfo{name: name, owner: parseLongName(long)}
+		if err := p.readAttrs(info); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// parseLongName reads the links, owner and group of a directory entry from
+// the ls -l line the server describes it with
+func parseLongName(long string) *remoteOwner {
+	fields := strings.Fields(long)
+	if len(fields) < 4 {
+		return nil
+	}
+	owner := &remoteOwner{user: fields[2], group: fields[3]}
+	fmt.Sscan(fields[1], &owner.links)
+	return owner
+}
+
+// ReadDir returns the entries of a directory, without . and ..
+func (c *sftpClient) ReadDir(dir string) ([]*sftpFileInfo, error) {
+	typ, reply, err := c.request(sftpOpendir, dir)
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpHandle {
+		return nil, fmt.Errorf("sftp: unexpected packet %d", typ)
+	}
+	handle, err := reply.readString()
+	if err != nil {
+		return nil, err
+	}
+	defer c.request(sftpClose, handle)
+	var entries []*sftpFileInfo
+	for {
+		typ, reply, err := c.request(sftpReaddir, handle)
+		var status *sftpError
+		if errors.As(err, &status) && status.code == sftpStatusEOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if typ != sftpName {
+			return nil, fmt.Errorf("sftp: unexpected packet %d", typ)
+		}
+		infos, err := reply.names()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.name != "." && info.name != ".." {
+				entries = append(entries, info)
+			}
+		}
+	}
+}
+
+// stat returns the attributes of a file, following symlinks unless lstat
+func (c *sftpClient) stat(name string, lstat bool) (*sftpFileInfo, error) {
+	typ := byte(sftpStat)
+	if lstat {
+		typ = sftpLstat
+	}
+	replyType, reply, err := c.request(typ, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if replyType != sftpAttrs {
+		return nil, fmt.Errorf("sftp: unexpected packet %d", replyType)
+	}
+	info := &sftpFileInfo{name: path.Base(name)}
+	return info, reply.readAttrs(info)
+}
+
+// Stat returns the attributes of a file, following symlinks
+func (c *sftpClient) Stat(name string) (*sftpFileInfo, error) {
+	return c.stat(name, false)
+}
+
+// Lstat returns the attributes of a file, not following symlinks
+func (c *sftpClient) Lstat(name string) (*sftpFileInfo, error) {
+	return c.stat(name, true)
+}
+
+// nameRequest makes a request answered with a single name
+func (c *sftpClient) nameRequest(typ byte, name string) (string, error) {
+	replyType, reply, err := c.request(typ, name)
+	if err != nil {
+		return "", err
+	}
+	if replyType != sftpName {
+		return "", fmt.Errorf("sftp: unexpected packet %d", replyType)
+	}
+	infos, err := reply.names()
+	if err != nil {
+		return "", err
+	}
+	if len(infos) != 1 {
+		return "", fmt.Errorf("sftp: %d names for %s", len(infos), name)
+	}
+	return infos[0].name, nil
+}
+
+// Readlink returns what a symlink points to
+func (c *sftpClient) Readlink(name string) (string, error) {
+	return c.nameRequest(sftpReadlink, name)
+}
+
+// Realpath returns the absolute, cleaned path of a file
+func (c *sftpClient) Realpath(name string) (string, error) {
+	return c.nameRequest(sftpRealpath, name)
+}
@@ -0,0 +1,213 @@
+package shell
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"testing"
+)
+
+// fakeSFTPFile is a file served by serveFakeSFTP
+type fakeSFTPFile struct {
+	perms uint32 // with the file type, as in st_mode
+	size  uint64
+}
+
+// fakeSFTPHome is the home directory of the fake SFTP server
+const fakeSFTPHome = "/home/ann"
+
+// serveFakeSFTP starts an SFTP server for the requests sftpClient makes,
+// serving files by their absolute paths, and returns a client of it
+func serveFakeSFTP(t *testing.T, files map[string]fakeSFTPFile) *sftpClient {
+	t.Helper()
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	t.Cleanup(func() {
+		clientOut.Close()
+		serverOut.Close()
+	})
+	go fakeSFTP(files, serverIn, serverOut)
+	c, err := newSFTPClient(clientIn, clientOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// fakeSFTP answers SFTP requests until its input ends. Each directory read
+// is answered two names at a time.
+func fakeSFTP(files map[string]fakeSFTPFile, serverIn io.Reader, serverOut io.Writer) {
+	open := map[string][]string{}
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(serverIn, length[:]); err != nil {
+			return
+		}
+		p := make(sftpPacket, binary.BigEndian.Uint32(length[:]))
+		if _, err := io.ReadFull(serverIn, p); err != nil {
+			return
+		}
+		typ, _ := p.readByte()
+		var reply sftpPacket
+		if typ == sftpInit {
+			reply.putByte(sftpVersion)
+			reply.putUint32(3)
+		} else {
+			id, _ := p.readUint32()
+			arg, _ := p.readString()
+			status := func(code uint32, msg string) {
+				reply.putByte(sftpStatus)
+				reply.putUint32(id)
+				reply.putUint32(code)
+				reply.putString(msg)
+				reply.putString("")
+			}
+			names := func(full bool, names ...string) {
+				reply.putByte(sftpName)
+				reply.putUint32(id)
+				reply.putUint32(uint32(len(names)))
+				for _, name := range names {
+					f := files[name]
+					if name == "." || name == ".." {
+						f = fakeSFTPFile{perms: 0040755}
+					}
+					if full {
+						reply.putString(name)
+					} else {
+						reply.putString(path.Base(name))
+					}
+					reply.putString("-rw-r--r--    1 ann      staff          0 Jan  1 00:00 " + path.Base(name))
+					putFakeAttrs(&reply, f)
+				}
+			}
+			if !path.IsAbs(arg) && typ != sftpReaddir && typ != sftpClose {
+				arg = path.Join(fakeSFTPHome, arg)
+			}
+			f, exists := files[arg]
+			switch {
+			case typ == sftpOpendir && exists && f.perms&0040000 != 0:
+				entries := []string{".", ".."}
+				for name := range files {
+					if path.Dir(name) == arg && name != arg {
+						entries = append(entries, name)
+					}
+				}
+				open[arg] = entries
+				reply.putByte(sftpHandle)
+				reply.putUint32(id)
+				reply.putString(arg)
+			case typ == sftpOpendir && exists:
+				status(4, "Failure")
+			case typ == sftpReaddir && len(open[arg]) == 0:
+				status(sftpStatusEOF, "EOF")
+			case typ == sftpReaddir:
+				n := min(2, len(open[arg]))
+				names(false, open[arg][:n]...)
+				open[arg] = open[arg][n:]
+			case typ == sftpClose:
+				delete(open, arg)
+				status(0, "")
+			case !exists:
+				status(sftpNoSuchFile, "No such file")
+			case typ == sftpStat || typ == sftpLstat:
+				reply.putByte(sftpAttrs)
+				reply.putUint32(id)
+				putFakeAttrs(&reply, f)
+			case typ == sftpRealpath:
+				names(true, arg)
+			default:
+				status(sftpDenied, "Permission denied")
+			}
+		}
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(reply)))
+		if _, err := serverOut.Write(append(frame, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// putFakeAttrs writes a file's size, permissions and times
+func putFakeAttrs(p *sftpPacket, f fakeSFTPFile) {
+	p.putUint32(sftpAttrSize | sftpAttrPerms | sftpAttrTimes)
+	p.putUint32(uint32(f.size >> 32))
+	p.putUint32(uint32(f.size))
+	p.putUint32(f.perms)
+	p.putUint32(1700000000)
+	p.putUint32(1700000000)
+}
+
+// fakeSFTPFiles are a home directory with a few files in it
+var fakeSFTPFiles = map[string]fakeSFTPFile{
+	"/":                       {perms: 0040755},
+	"/home":                   {perms: 0040755},
+	"/home/ann":               {perms: 0040755},
+	"/home/ann/notes.txt":     {perms: 0100644, size: 1234},
+	"/home/ann/todo.txt":      {perms: 0100600, size: 56},
+	"/home/ann/src":           {perms: 0040755},
+	"/home/ann/src/main.go":   {perms: 0100644, size: 789},
+	"/home/ann/src/script.sh": {perms: 0104755, size: 10},
+}
+
+func TestSFTPClient(t *testing.T) {
+	c := serveFakeSFTP(t, fakeSFTPFiles)
+
+	entries, err := c.ReadDir(fakeSFTPHome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	if want := []string{"notes.txt", "src", "todo.txt"}; !slices.Equal(names, want) {
+		t.Errorf("ReadDir = %q, want %q", names, want)
+	}
+	if owner, ok := entries[0].Sys().(*remoteOwner); !ok || owner.user != "ann" || owner.group != "staff" || owner.links != 1 {
+		t.Errorf("owner = %+v", entries[0].Sys())
+	}
+
+	info, err := c.Stat("/home/ann/src/script.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "script.sh" || info.Size() != 10 || info.Mode() != fs.ModeSetuid|0755 || info.ModTime().Unix() != 1700000000 {
+		t.Errorf("Stat = %s %d %v %v", info.Name(), info.Size(), info.Mode(), info.ModTime())
+	}
+	if info, err := c.Lstat("src"); err != nil || !info.IsDir() {
+		t.Errorf("Lstat(src) = %v, %v, want a directory", info, err)
+	}
+	if _, err := c.Stat("/home/ann/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a missing file = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := c.ReadDir("/home/ann/notes.txt"); err == nil {
+		t.Errorf("ReadDir of a file = %v, want an error", err)
+	}
+	if _, err := c.Readlink("/home/ann/notes.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Readlink refused = %v, want fs.ErrPermission", err)
+	}
+	if home, err := c.Realpath("."); err != nil || home != fakeSFTPHome {
+		t.Errorf("Realpath(.) = %q, %v", home, err)
+	}
+}
+
+func TestSFTPFileMode(t *testing.T) {
+	tests := []struct {
+		perms uint32
+		want  fs.FileMode
+	}{
+		{0100644, 0644},
+		{0040755, fs.ModeDir | 0755},
+		{0120777, fs.ModeSymlink | 0777},
+		{0041777, fs.ModeDir | fs.ModeSticky | 0777},
+		{0020620, fs.ModeDevice | fs.ModeCharDevice | 0620},
+	}
+	for _, tt := range tests {
+		if got := sftpFileMode(tt.perms); got != tt.want {
+			t.Errorf("sftpFileMode(%o) = %v, want %v", tt.perms, got, tt.want)
+		}
+	}
+}
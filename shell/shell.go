@@ -0,0 +1,436 @@
+// Package shell is GoShell's interpreter: parsing and running command
+// lines, the builtins, completion, history and the interactive loop that
+// the goshell command runs. Programs embedding it use New, Options and
+// Shell.Run.
+//
+// The parts needing nothing of the shell are packages of their own:
+// command lines are split and parsed by goshell/internal/parser, the
+// environment variables are kept by goshell/internal/env, and the line
+// editor is goshell/internal/lineedit. Expansion, the builtins and the
+// prompt work on the shell's options, aliases and working directory, and
+// run commands, so they are part of this package.
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"goshell/internal/env"
+	"goshell/internal/lineedit"
+	"goshell/internal/parser"
+
+	"golang.org/x/term"
+)
+
+// ANSI color codes
+const (
+	Reset     = "\033[0m"
+	Bold      = "\033[1m"
+	Dim       = "\033[2m"
+	Red       = "\033[31m"
+	Green     = "\033[32m"
+	Yellow    = "\033[33m"
+	Blue      = "\033[34m"
+	Magenta   = "\033[35m"
+	Cyan      = "\033[36m"
+	White     = "\033[37m"
+	BgRed     = "\033[41m"
+	BgGreen   = "\033[42m"
+	BgYellow  = "\033[43m"
+	BgBlue    = "\033[44m"
+	BgMagenta = "\033[45m"
+	BgCyan    = "\033[46m"
+	BgWhite   = "\033[47m"
+)
+
+// ShellEnv stores the shell's environment variables, see package
+// goshell/internal/env
+type ShellEnv = env.Env
+
+// NewShellEnv creates a new shell environment with system environment variables
+func NewShellEnv() *ShellEnv {
+	e := env.FromList(os.Environ())
+
+	// Set default LS_COLORS for colorized output
+	if _, exists := e.Lookup("LS_COLORS"); !exists {
+		e.Set("LS_COLORS", "di=1;34:ln=1;36:so=1;35:pi=1;33:ex=1;32:bd=1;33:cd=1;33:su=1;31:sg=1;31:tw=1;34:ow=1;34")
+	}
+
+	return e
+}
+
+// Shell represents the shell state
+type Shell struct {
+	env          *ShellEnv
+	history      []HistoryEntry
+	lastHint     historyHint                  // memo of the last autosuggestion lookup
+	commands     *commandIndex                // executables on PATH, for completion
+	completions  map[string][]*completionSpec // registered with the complete builtin
+	generated    *flightCache[string]         // output of completion generators
+	options      map[string]bool              // changed with the set builtin
+	bindings     map[lineedit.Key]keyBinding  // shell-side key bindings, see bind.go
+	fifos        map[string]string            // named pipes by name, see fifo.go
+	fifoDir      string                       // session directory holding the named pipes
+	events       *eventState                  // on-event handlers, see events.go
+	lastStatus   int                          // exit status of the most recent command
+	lastDuration time.Duration                // wall time of the most recent command
+	cwd          string                       // logical working directory, see cwd.go
+	exiting      bool                         // set by the exit builtin
+	histFile     *historyFile                 // the history file as last read, see histshare.go
+	interrupts   chan os.Signal               // SIGINT while a command runs, see interrupt.go
+	visited      string                       // the directory last recorded as visited, see frecency.go
+	dirHistory   []string                     // directories cd has left, most recent last, see dirhistory.go
+	readKey      func() (lineedit.Key, error) // reads a key for a picker, see picker.go
+	stdin        io.Reader                    // the shell's own streams, see run.go
+	stdout       io.Writer
+	stderr       io.Writer
+	ctx          context.Context   // set while Run runs commands, see run.go
+	plugins      []*plugin         // loaded from the plugins directory, see plugin.go
+	aliases      map[string]string // defined with the alias builtin, see alias.go
+	dotenv       *dotenvState      // the environment file in effect, see dotenv.go
+	projects     *projectState     // project rules, see project.go
+	secrets      secretCache       // secrets variables refer to, see secrets.go
+	audit        *auditLog         // where commands are audited, see audit.go
+	guards       []*guardRule      // commands to confirm or block, see guard.go
+	dryRun       bool              // show the line's commands rather than run them, see dryrun.go
+	usage        commandUsage      // resources the line's commands used, see reporttime.go
+	remote       *remoteSession    // the host commands run on in remote mode, see remote.go
+	configs      configFiles       // kubeconfig and Docker configuration, see contexts.go
+	title        io.Writer         // the terminal whose title the shell set, see title.go
+	reportedDir  string            // the working directory last reported to the terminal, see osc.go
+	lastOutput   []byte            // what the last command line printed, see lastout.go
+	transcript   *transcriptLog    // set with set -o logfile, see transcript.go
+
+	// The builtins scripts defined, which scripts may define again, see
+	// starlark.go
+	scriptBuiltins map[string]bool
+
+	// The statuses ls marks entries in git repositories with, see lsgit.go
+	gitStatus *flightCache[map[string]gitMark]
+
+	// The history has changed other than by adding entries at its end, so
+	// the editor's copy of it has to be replaced
+	historyStale bool
+
+	// A copy of the shell a pipeline stage runs in, see subshell.go
+	subshell bool
+}
+
+// NewShell creates a new shell instance
+func NewShell() *Shell {
+	s := &Shell{
+		env:         NewShellEnv(),
+		history:     make([]HistoryEntry, 0),
+		commands:    newCommandIndex(),
+		generated:   newFlightCache[string](generatorTTL),
+		gitStatus:   newFlightCache[map[string]gitMark](gitStatusTTL),
+		options:     map[string]bool{"emacs": true, "guard": true, "histexpand": true, "histredact": true, "title": true},
+		guards:      defaultGuardRules(),
+		bindings:    defaultBindings(),
+		completions: make(map[string][]*completionSpec),
+		fifos:       make(map[string]string),
+		aliases:     make(map[string]string),
+		events:      &eventState{stamps: make(map[string]fileStamp)},
+		stdin:       os.Stdin,
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+	}
+	s.initCwd()
+	return s
+}
+
+// Close releases the resources held by the session, such as its named pipes
+func (s *Shell) Close() {
+	s.removeFifos()
+	s.restoreTitle()
+	if s.transcript != nil {
+		s.transcript.close()
+	}
+	if s.remote != nil {
+		s.remote.close()
+	}
+}
+
+// HelpText returns the list of available commands and their descriptions
+func (s *Shell) HelpText() string {
+	var b strings.Builder
+	b.WriteString("Available commands:")
+	for _, name := range builtinNames() {
+		cmd := builtins[name]
+		fmt.Fprintf(&b, "\n  %-17s %s", cmd.usage, cmd.summary)
+	}
+	return b.String()
+}
+
+// PrintHelp prints available commands and their descriptions
+func (s *Shell) PrintHelp() string {
+	helpText := s.HelpText()
+	fmt.Fprintln(s.stdout, helpText)
+	return helpText
+}
+
+// ansiEscape matches an ANSI escape sequence, such as a color code
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+
+// stripANSI removes ANSI escape codes from a string
+func stripANSI(str string) string {
+	return ansiEscape.ReplaceAllString(str, "")
+}
+
+// TermSize represents terminal dimensions
+type TermSize struct {
+	Row, Col int
+}
+
+// getTerminalSize returns the dimensions of the terminal, asking the
+// terminal driver through whichever of the standard streams is one
+func getTerminalSize() (TermSize, error) {
+	// Default size in case we can't detect
+	defaultSize := TermSize{Row: 24, Col: 80}
+
+	err := errors.New("not a terminal")
+	for _, f := range []*os.File{os.Stdout, os.Stdin, os.Stderr} {
+		var col, row int
+		if col, row, err = term.GetSize(int(f.Fd())); err == nil && col > 0 && row > 0 {
+			return TermSize{Row: row, Col: col}, nil
+		}
+	}
+	return defaultSize, err
+}
+
+// teeOutput is output passed on to another writer and kept along the way,
+// for lastout or the transcript
+type teeOutput interface {
+	io.Writer
+	passTo() io.Writer
+	keep(p []byte)
+}
+
+// isTerminal reports whether w is a character device such as a terminal, or
+// collects output for the pager to show on one, or passes it on to one
+func isTerminal(w interface{}) bool {
+	if _, ok := w.(*pagedOutput); ok {
+		return true
+	}
+	if t, ok := w.(teeOutput); ok {
+		return isTerminal(t.passTo())
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// readCommand reads a command from the terminal, prompting for more lines
+// while it is incomplete, as after a trailing pipe or inside open quotes
+func readCommand(editor *lineEditor, prompt string) (string, error) {
+	editor.ed.SetPrompt(editor.startLine(prompt))
+	text := ""
+	for {
+		line, err := editor.ed.ReadlineWithDefault(text)
+		if err == io.EOF && editor.continuing() {
+			return "", parser.ErrIncomplete
+		}
+		if err != nil {
+			return "", err
+		}
+		if command, complete := editor.submit(line); complete {
+			return command, nil
+		}
+		prompt, text = editor.nextLine()
+		editor.ed.SetPrompt(prompt)
+	}
+}
+
+// Main runs the interactive shell on the process's terminal until it exits,
+// as the goshell command does. --posix starts it in POSIX mode,
+// --import-bash FILE imports a bash or zsh startup file's aliases and
+// exports into ~/.goshellrc and exits, as import -y does, and --remote
+// TARGET runs the commands typed on another host, as rsh does. goshell
+// attach NAME runs it in a session that can be detached from and attached
+// to again, and goshell sessions lists those, see session.go.
+func Main() {
+	if status, ok := sessionMain(os.Args[1:]); ok {
+		os.Exit(status)
+	}
+	posix := false
+	var imports []string
+	remote := ""
+	for args := os.Args[1:]; len(args) > 0; args = args[1:] {
+		switch {
+		case args[0] == "--posix":
+			posix = true
+		case args[0] == "--import-bash" && len(args) > 1:
+			imports = append(imports, args[1])
+			args = args[1:]
+		case args[0] == "--remote" && len(args) > 1:
+			remote = args[1]
+			args = args[1:]
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: goshell [--posix] [--import-bash FILE] [--remote [USER@]HOST[:PORT]] | attach [NAME] | sessions")
+			os.Exit(2)
+		}
+	}
+	shell := NewShell()
+	defer shell.Close()
+	shell.setOption("posix", posix)
+	if len(imports) > 0 {
+		status := builtinImport(shell, append([]string{"import", "-y"}, imports...), shell.stdio())
+		shell.Close()
+		os.Exit(status)
+	}
+	editor := newLineEditor(shell, lineedit.New(os.Stdin, os.Stdout))
+	var terminal io.Writer // the terminal, told what the shell is doing, see title.go and osc.go
+	if isTerminal(os.Stdout) {
+		terminal = os.Stdout
+	}
+
+	// Plugins are loaded first so the startup files can use what they add,
+	// and the Starlark startup file before the other so its builtins can
+	// be run there
+	if err := shell.LoadPlugins(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading plugins:", err)
+	}
+	if err := shell.LoadStarlarkRC(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading Starlark startup file:", starlarkError(err))
+	}
+	if err := shell.LoadRC(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading startup file:", err)
+	}
+	if shell.exiting {
+		return
+	}
+	if err := shell.startAudit(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error opening audit log:", err)
+	}
+
+	// The startup file may have set HISTFILE, so the history is loaded
+	// after it. A damaged history file is salvaged first.
+	historyPath := shell.historyPath()
+	if report, err := checkHistoryFile(historyPath, true); err != nil {
+		fmt.Fprintln(os.Stderr, "Error checking history file:", err)
+	} else if !report.ok() {
+		fmt.Fprintf(os.Stderr, "goshell: history file %s: %s\n", historyPath, report)
+	}
+	if entries, err := shell.loadHistory(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading history file:", err)
+	} else {
+		editor.updateHistory(entries)
+	}
+
+	shell.catchInterrupts()
+	watchResize(editor.ed.Refresh)
+
+	if remote != "" {
+		if status := builtinRsh(shell, []string{"rsh", remote}, shell.stdio()); status != 0 {
+			shell.Close()
+			os.Exit(status)
+		}
+	}
+
+	// Index PATH in the background so the first Tab press is fast
+	go shell.commands.names(shell.env.Get("PATH"))
+
+	last := "" // the last command line run, for precmd handlers
+	for {
+		shell.visitDir()
+		shell.updateDotenv(shell.stdio())
+		shell.DispatchEvents(shell.stdio())
+		shell.fireEvent(event{name: "precmd", data: last, vars: map[string]string{
+			"GOSHELL_DURATION_MS": strconv.FormatInt(shell.lastDuration.Milliseconds(), 10),
+		}}, shell.stdio())
+		if entries, err := shell.syncHistory(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading history file:", err)
+		} else {
+			editor.updateHistory(entries)
+		}
+
+		shell.showTitle(terminal, shell.idleTitle())
+		shell.reportDir(terminal)
+		prompt := shell.Prompt()
+		input, err := readCommand(editor, shell.markPrompt(terminal, prompt))
+		if err != nil {
+			if err == lineedit.ErrInterrupt {
+				continue
+			} else if err == io.EOF {
+				fmt.Println("Goodbye!")
+				return
+			}
+			fmt.Fprintln(os.Stderr, "Error reading input:", err)
+			continue
+		}
+
+		// Trim whitespace, noting a leading space, which keeps the command
+		// out of the history when HISTCONTROL has ignorespace
+		spaced := strings.HasPrefix(input, " ")
+		input = strings.TrimSpace(input)
+
+		// Expand history references, showing the command that results
+		expanded, err := shell.expandHistory(input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "goshell:", err)
+			continue
+		}
+		if expanded != input {
+			fmt.Println(expanded)
+			input = expanded
+		}
+
+		// Skip empty commands
+		if input == "" {
+			continue
+		}
+
+		// Add command to history, saving it to the history file at once
+		typed := input
+		if spaced {
+			typed = " " + input
+		}
+		added := shell.AddToHistory(typed)
+		entry := shell.lastHistoryEntry()
+		if added {
+			entries, err := shell.saveHistory()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error saving history:", err)
+			}
+			editor.updateHistory(entries)
+		}
+
+		shell.fireEvent(event{name: "preexec", data: input}, shell.stdio())
+		shell.interrupted() // forget a Ctrl-C pressed after the last command ended
+		last = input
+		start := time.Now()
+		shell.usage.reset()
+		shell.dryRun = shell.options["dryrun"]
+		shell.showTitle(terminal, shell.commandTitle(input))
+		shell.markOutputStart(terminal)
+		status := shell.runLogged(prompt, input)
+		shell.markFinished(terminal, status)
+		shell.dryRun = false
+		shell.lastDuration = time.Since(start)
+		if added {
+			shell.recordHistoryStatus(entry, status)
+		}
+		if err := shell.auditCommand(input, status); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+		}
+		shell.notifyFinished(input, status, shell.lastDuration)
+		shell.reportTime(os.Stderr, input, shell.lastDuration)
+		shell.queueEvent("job_finished", input)
+		if shell.exiting {
+			return
+		}
+	}
+}
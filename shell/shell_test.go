@@ -1,4 +1,4 @@
-package main
+package shell
 
 import (
 	"bytes"
@@ -67,41 +67,6 @@ func TestShellEnv(t *testing.T) {
 			t.Errorf("Get() after Unset() = %v, want empty string", got)
 		}
 	})
-
-	// Test ToSlice conversion
-	t.Run("ToSlice", func(t *testing.T) {
-		// Create a clean environment for testing
-		cleanEnv := &ShellEnv{env: make(map[string]string)}
-		cleanEnv.Set("TEST_VAR1", "value1")
-		cleanEnv.Set("TEST_VAR2", "value2")
-
-		slice := cleanEnv.ToSlice()
-		expected := map[string]string{
-			"TEST_VAR1": "value1",
-			"TEST_VAR2": "value2",
-		}
-
-		// Check that our test variables are in the slice
-		foundVars := 0
-		for _, entry := range slice {
-			parts := strings.SplitN(entry, "=", 2)
-			if len(parts) != 2 {
-				t.Errorf("Invalid entry in ToSlice(): %v", entry)
-				continue
-			}
-
-			if val, ok := expected[parts[0]]; ok {
-				if val != parts[1] {
-					t.Errorf("ToSlice() entry %v = %v, want %v", parts[0], parts[1], val)
-				}
-				foundVars++
-			}
-		}
-
-		if foundVars != len(expected) {
-			t.Errorf("Not all expected variables found in ToSlice(). Found %d, want %d", foundVars, len(expected))
-		}
-	})
 }
 
 func TestShell(t *testing.T) {
@@ -289,6 +254,117 @@ func TestBuiltInCommands(t *testing.T) {
 	}
 }
 
+func TestCdPrevious(t *testing.T) {
+	shell := NewShell()
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+
+	dir := t.TempDir()
+	if _, status := runCapture(t, shell, "cd "+dir); status != 0 {
+		t.Fatalf("cd %s failed", dir)
+	}
+	out, status := runCapture(t, shell, "cd -")
+	if got, _ := os.Getwd(); status != 0 || got != start || out != start+"\n" {
+		t.Errorf("cd - = %q (status %d, cwd %s), want %s", out, status, got, start)
+	}
+	if got := shell.env.Get("OLDPWD"); got != dir {
+		t.Errorf("OLDPWD = %q, want %q", got, dir)
+	}
+}
+
+func TestCdLogical(t *testing.T) {
+	shell := NewShell()
+	start, _ := os.Getwd()
+	defer os.Chdir(start)
+
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real")
+	link := filepath.Join(dir, "link")
+	if err := os.MkdirAll(filepath.Join(real, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	resolved, _ := filepath.EvalSymlinks(real)
+
+	steps := []struct {
+		command string
+		want    string
+	}{
+		{"cd " + link + "/sub", ""},
+		{"pwd", link + "/sub\n"},
+		{"pwd -P", resolved + "/sub\n"},
+		{"cd ..", ""},
+		{"pwd", link + "\n"},
+		{"cd -", link + "/sub\n"},
+		{"cd -P ..", ""},
+		{"pwd", resolved + "\n"},
+		{"pwd -PL", resolved + "\n"},
+	}
+	for _, step := range steps {
+		if out, status := runCapture(t, shell, step.command); status != 0 || out != step.want {
+			t.Fatalf("%s = %q (status %d), want %q", step.command, out, status, step.want)
+		}
+	}
+	if got := shell.env.Get("PWD"); got != resolved {
+		t.Errorf("PWD = %q, want %q", got, resolved)
+	}
+	if got := shell.env.Get("OLDPWD"); got != link+"/sub" {
+		t.Errorf("OLDPWD = %q, want %q", got, link+"/sub")
+	}
+}
+
+func TestExportPrint(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("GOSHELL_QUOTED", "it's here")
+	out, _ := runCapture(t, shell, "export -p")
+	if want := `export GOSHELL_QUOTED='it'\''s here'`; !strings.Contains(out, want) {
+		t.Errorf("export -p output missing %q", want)
+	}
+}
+
+func TestClear(t *testing.T) {
+	shell := NewShell()
+	if out, _ := runCapture(t, shell, "clear"); out != "\033[H\033[2J\033[3J" {
+		t.Errorf("clear wrote %q", out)
+	}
+	if out, _ := runCapture(t, shell, "clear -x"); out != "\033[H\033[2J" {
+		t.Errorf("clear -x wrote %q", out)
+	}
+	if _, status := runCapture(t, shell, "clear now"); status == 0 {
+		t.Error("clear with an operand succeeded")
+	}
+}
+
+func TestEchoOptions(t *testing.T) {
+	tests := []struct {
+		command string
+		posix   bool
+		want    string
+	}{
+		{`echo a b`, false, "a b\n"},
+		{`echo -n a b`, false, "a b"},
+		{`echo 'a\tb'`, false, `a\tb` + "\n"},
+		{`echo -e 'a\tb\x41\0101é'`, false, "a\tbAAé\n"},
+		{`echo -ne 'a\n'`, false, "a\n"},
+		{`echo -eE 'a\n'`, false, `a\n` + "\n"},
+		{`echo -e 'a\cb'`, false, "a"},
+		{`echo -e 'a\qb\x'`, false, `a\qb\x` + "\n"},
+		{`echo -x -n`, false, "-x -n\n"},
+		{`echo -- -n`, false, "-n\n"},
+		{`echo -n 'a\tb'`, true, "-n a\tb\n"},
+		{`echo -e a`, true, "-e a\n"},
+	}
+	for _, tt := range tests {
+		shell := NewShell()
+		shell.setOption("posix_echo", tt.posix)
+		if out, _ := runCapture(t, shell, tt.command); out != tt.want {
+			t.Errorf("%s (posix %v) = %q, want %q", tt.command, tt.posix, out, tt.want)
+		}
+	}
+}
+
 func TestEnvironmentVariables(t *testing.T) {
 	shell := NewShell()
 
@@ -0,0 +1,459 @@
+package shell
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerBuiltin("sort", "sort [-nhru] [-k N[,M]] [-t SEP] [file...]", "Sort lines of text", builtinSort)
+	registerBuiltin("uniq", "uniq [-cd] [file]", "Collapse adjacent duplicate lines", builtinUniq)
+	registerFlags("sort",
+		Candidate{"-n", "Compare by numeric value"},
+		Candidate{"-h", "Compare human-readable sizes (2K, 1G)"},
+		Candidate{"-r", "Reverse the order"},
+		Candidate{"-u", "Output only the first of equal lines"},
+		Candidate{"-k", "Sort by field N[,M]"},
+		Candidate{"-t", "Field separator"})
+	registerFlags("uniq",
+		Candidate{"-c", "Prefix lines with their count"},
+		Candidate{"-d", "Only print duplicated lines"})
+}
+
+// sortChunkSize is the number of bytes sort buffers before spilling a sorted
+// run to a temporary file
+var sortChunkSize = 64 << 20
+
+// sortOptions controls how lines are compared
+type sortOptions struct {
+	numeric   bool
+	human     bool
+	reverse   bool
+	unique    bool
+	separator string // field separator; empty means runs of blanks
+	keyStart  int    // first key field, 1-based; 0 compares whole lines
+	keyEnd    int    // last key field, 0 for end of line
+}
+
+// parseSortArgs parses sort's flags, returning the options and input files
+func parseSortArgs(args []string) (*sortOptions, []string, error) {
+	opts := &sortOptions{}
+	flags := newFlagSet("sort")
+	flags.Bool(&opts.numeric, "n")
+	flags.Bool(&opts.human, "h")
+	flags.Bool(&opts.reverse, "r")
+	flags.Bool(&opts.unique, "u")
+	flags.Func(opts.parseKey, "k")
+	flags.String(&opts.separator, "t")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return opts, files, nil
+}
+
+// parseKey parses a -k key definition of the form N or N,M
+func (o *sortOptions) parseKey(spec string) error {
+	start, end, hasEnd := strings.Cut(spec, ",")
+	n, err := strconv.Atoi(start)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid key: %s", spec)
+	}
+	o.keyStart = n
+	if hasEnd {
+		m, err := strconv.Atoi(end)
+		if err != nil || m < n {
+			return fmt.Errorf("invalid key: %s", spec)
+		}
+		o.keyEnd = m
+	}
+	return nil
+}
+
+// key extracts the part of a line that is compared
+func (o *sortOptions) key(line string) string {
+	if o.keyStart == 0 {
+		return line
+	}
+	var fields []string
+	if o.separator != "" {
+		fields = strings.Split(line, o.separator)
+	} else {
+		fields = strings.Fields(line)
+	}
+	if o.keyStart > len(fields) {
+		return ""
+	}
+	end := len(fields)
+	if o.keyEnd > 0 && o.keyEnd < end {
+		end = o.keyEnd
+	}
+	sep := o.separator
+	if sep == "" {
+		sep = " "
+	}
+	return strings.Join(fields[o.keyStart-1:end], sep)
+}
+
+// compareKeys compares two lines by key only, returning -1, 0 or 1
+func (o *sortOptions) compareKeys(a, b string) int {
+	ka, kb := o.key(a), o.key(b)
+	var c int
+	switch {
+	case o.numeric:
+		c = compareFloats(leadingNumber(ka), leadingNumber(kb))
+	case o.human:
+		c = compareFloats(humanNumber(ka), humanNumber(kb))
+	default:
+		c = strings.Compare(ka, kb)
+	}
+	if o.reverse {
+		c = -c
+	}
+	return c
+}
+
+// less orders two lines, falling back to a whole-line comparison when the
+// keys are equal so output is deterministic
+func (o *sortOptions) less(a, b string) bool {
+	if c := o.compareKeys(a, b); c != 0 {
+		return c < 0
+	}
+	if o.unique {
+		return false
+	}
+	if o.reverse {
+		return a > b
+	}
+	return a < b
+}
+
+// compareFloats compares two numbers, returning -1, 0 or 1
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// leadingNumber parses the number at the start of s, ignoring leading
+// blanks. Text without a leading number sorts as zero.
+func leadingNumber(s string) float64 {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || (end == 0 && (s[end] == '-' || s[end] == '+'))) {
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// humanNumber parses a human-readable size such as 1.5K or 2G
+func humanNumber(s string) float64 {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	n := leadingNumber(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	if end < len(s) {
+		if idx := strings.IndexByte("KMGTPE", byte(unicode.ToUpper(rune(s[end])))); idx >= 0 {
+			for i := 0; i <= idx; i++ {
+				n *= 1024
+			}
+		}
+	}
+	return n
+}
+
+// openInputs returns a reader over the named files, or stdin when none are
+// given. "-" also means stdin.
+func openInputs(files []string, stdin io.Reader) (io.Reader, func(), error) {
+	if len(files) == 0 {
+		return stdin, func() {}, nil
+	}
+	var readers []io.Reader
+	var opened []*os.File
+	closeAll := func() { closeFiles(opened) }
+	for _, name := range files {
+		if name == "-" {
+			readers = append(readers, stdin)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		opened = append(opened, f)
+		readers = append(readers, f)
+	}
+	return io.MultiReader(readers...), closeAll, nil
+}
+
+// readLine reads a line without its trailing newline. It returns io.EOF only
+// when no more data is available.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// builtinSort sorts its input lines. Input larger than sortChunkSize is
+// sorted in runs spilled to temporary files and merged, so memory use stays
+// bounded.
+func builtinSort(s *Shell, args []string, stdio Stdio) int {
+	opts, files, err := parseSortArgs(args)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "sort:", err)
+		return 2
+	}
+	input, closeInputs, err := openInputs(files, stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "sort:", err)
+		return 2
+	}
+	defer closeInputs()
+
+	var runs []string
+	defer func() {
+		for _, run := range runs {
+			os.Remove(run)
+		}
+	}()
+
+	reader := bufio.NewReader(input)
+	var lines []string
+	size := 0
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "sort:", err)
+			return 2
+		}
+		lines = append(lines, line)
+		size += len(line) + 1
+		if size >= sortChunkSize {
+			run, err := writeSortedRun(lines, opts)
+			if err != nil {
+				fmt.Fprintln(stdio.Stderr, "sort:", err)
+				return 2
+			}
+			runs = append(runs, run)
+			lines, size = nil, 0
+		}
+	}
+
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+
+	if len(runs) == 0 {
+		sort.SliceStable(lines, func(i, j int) bool { return opts.less(lines[i], lines[j]) })
+		writeSortedLines(out, lines, opts)
+		return 0
+	}
+
+	if len(lines) > 0 {
+		run, err := writeSortedRun(lines, opts)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "sort:", err)
+			return 2
+		}
+		runs = append(runs, run)
+	}
+	if err := mergeRuns(out, runs, opts); err != nil {
+		fmt.Fprintln(stdio.Stderr, "sort:", err)
+		return 2
+	}
+	return 0
+}
+
+// writeSortedLines writes lines in order, dropping key duplicates with -u
+func writeSortedLines(w *bufio.Writer, lines []string, opts *sortOptions) {
+	for i, line := range lines {
+		if opts.unique && i > 0 && opts.compareKeys(lines[i-1], line) == 0 {
+			continue
+		}
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+}
+
+// writeSortedRun sorts lines and writes them to a temporary file, returning
+// its path
+func writeSortedRun(lines []string, opts *sortOptions) (string, error) {
+	sort.SliceStable(lines, func(i, j int) bool { return opts.less(lines[i], lines[j]) })
+	f, err := os.CreateTemp("", "goshell-sort")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, line := range lines {
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// runHead is the next unread line of a sorted run
+type runHead struct {
+	line   string
+	index  int // position of the run, used to keep the merge stable
+	reader *bufio.Reader
+}
+
+// runHeap orders run heads by their current line
+type runHeap struct {
+	heads []*runHead
+	opts  *sortOptions
+}
+
+func (h *runHeap) Len() int { return len(h.heads) }
+func (h *runHeap) Less(i, j int) bool {
+	a, b := h.heads[i], h.heads[j]
+	if h.opts.less(a.line, b.line) {
+		return true
+	}
+	if h.opts.less(b.line, a.line) {
+		return false
+	}
+	return a.index < b.index
+}
+func (h *runHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *runHeap) Push(x interface{}) { h.heads = append(h.heads, x.(*runHead)) }
+func (h *runHeap) Pop() interface{} {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+// mergeRuns performs a k-way merge of sorted run files into w
+func mergeRuns(w *bufio.Writer, runs []string, opts *sortOptions) error {
+	h := &runHeap{opts: opts}
+	for i, path := range runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		reader := bufio.NewReader(f)
+		line, err := readLine(reader)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h.heads = append(h.heads, &runHead{line: line, index: i, reader: reader})
+	}
+	heap.Init(h)
+
+	var previous *string
+	for h.Len() > 0 {
+		head := h.heads[0]
+		if !opts.unique || previous == nil || opts.compareKeys(*previous, head.line) != 0 {
+			w.WriteString(head.line)
+			w.WriteByte('\n')
+			line := head.line
+			previous = &line
+		}
+
+		line, err := readLine(head.reader)
+		switch {
+		case err == io.EOF:
+			heap.Pop(h)
+		case err != nil:
+			return err
+		default:
+			head.line = line
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
+
+// builtinUniq collapses adjacent identical lines. -c prefixes each line with
+// its count and -d prints only lines that were repeated.
+func builtinUniq(s *Shell, args []string, stdio Stdio) int {
+	count, duplicatesOnly := false, false
+	flags := newFlagSet("uniq")
+	flags.Bool(&count, "c")
+	flags.Bool(&duplicatesOnly, "d")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(files) > 1 {
+		return flags.usage(stdio)
+	}
+
+	input, closeInputs, err := openInputs(files, stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "uniq:", err)
+		return 1
+	}
+	defer closeInputs()
+
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+
+	emit := func(line string, n int) {
+		if duplicatesOnly && n < 2 {
+			return
+		}
+		if count {
+			fmt.Fprintf(out, "%7d %s\n", n, line)
+		} else {
+			fmt.Fprintln(out, line)
+		}
+	}
+
+	reader := bufio.NewReader(input)
+	var current string
+	n := 0
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "uniq:", err)
+			return 1
+		}
+		if n > 0 && line == current {
+			n++
+			continue
+		}
+		if n > 0 {
+			emit(current, n)
+		}
+		current, n = line, 1
+	}
+	if n > 0 {
+		emit(current, n)
+	}
+	return 0
+}
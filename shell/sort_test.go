@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// runBuiltin runs a builtin directly with the given input and returns its
+// stdout and exit status
+func runBuiltin(t *testing.T, args []string, input string) (string, int) {
+	t.Helper()
+	b, ok := builtins[args[0]]
+	if !ok {
+		t.Fatalf("no builtin named %q", args[0])
+	}
+	var out, errOut bytes.Buffer
+	status := b.run(NewShell(), args, Stdio{Stdin: strings.NewReader(input), Stdout: &out, Stderr: &errOut})
+	return out.String(), status
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		want  string
+	}{
+		{"lexical", []string{"sort"}, "pear\napple\nfig\n", "apple\nfig\npear\n"},
+		{"numeric", []string{"sort", "-n"}, "10\n9\n100\n", "9\n10\n100\n"},
+		{"reverse numeric", []string{"sort", "-nr"}, "10\n9\n100\n", "100\n10\n9\n"},
+		{"human", []string{"sort", "-h"}, "1G\n10K\n2M\n512\n", "512\n10K\n2M\n1G\n"},
+		{"key and separator", []string{"sort", "-t", ",", "-k2", "-n"}, "a,3\nb,1\nc,2\n", "b,1\nc,2\na,3\n"},
+		{"key range", []string{"sort", "-k", "2,2"}, "x b 9\ny a 1\n", "y a 1\nx b 9\n"},
+		{"unique", []string{"sort", "-u"}, "b\na\nb\na\n", "a\nb\n"},
+		{"missing newline", []string{"sort"}, "b\na", "a\nb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := runBuiltin(t, tt.args, tt.input)
+			if status != 0 || got != tt.want {
+				t.Errorf("%v = %q (status %d), want %q", tt.args, got, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortExternalMerge(t *testing.T) {
+	saved := sortChunkSize
+	sortChunkSize = 16
+	defer func() { sortChunkSize = saved }()
+
+	input := "9\n3\n7\n1\n8\n2\n6\n4\n5\n3\n10\n"
+	got, _ := runBuiltin(t, []string{"sort", "-n"}, input)
+	if want := "1\n2\n3\n3\n4\n5\n6\n7\n8\n9\n10\n"; got != want {
+		t.Errorf("merged sort = %q, want %q", got, want)
+	}
+
+	got, _ = runBuiltin(t, []string{"sort", "-nu"}, input)
+	if want := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"; got != want {
+		t.Errorf("merged unique sort = %q, want %q", got, want)
+	}
+}
+
+func TestUniq(t *testing.T) {
+	input := "a\na\nb\nc\nc\nc\na\n"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"uniq"}, "a\nb\nc\na\n"},
+		{[]string{"uniq", "-c"}, "      2 a\n      1 b\n      3 c\n      1 a\n"},
+		{[]string{"uniq", "-d"}, "a\nc\n"},
+		{[]string{"uniq", "-cd"}, "      2 a\n      3 c\n"},
+	}
+	for _, tt := range tests {
+		if got, _ := runBuiltin(t, tt.args, input); got != tt.want {
+			t.Errorf("%v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
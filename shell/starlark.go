@@ -0,0 +1,403 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	starsyntax "go.starlark.net/syntax"
+)
+
+func init() {
+	registerBuiltin("starlark", "starlark FILE [ARGS...]", "Run a Starlark script with the shell API", builtinStarlark)
+}
+
+// Configuration that outgrows the shell's own command language can be
+// written in Starlark, a dialect of Python, in ~/.goshellrc.star or in
+// scripts run with the starlark builtin. Scripts see the script's arguments
+// in args and the shell through the shell module:
+//
+//	shell.run(CMD)                      run a command line, returning its status
+//	shell.output(CMD)                   run a command line, returning its output
+//	shell.getenv(NAME, default="")      read a variable, or default if it is
+//	                                    unset or empty
+//	shell.setenv(NAME, VALUE)           set a variable; None unsets it
+//	shell.cwd()                         the working directory
+//	shell.builtin(NAME, FN, usage="", summary="")
+//	                                    define a builtin; FN gets the
+//	                                    arguments and returns the status
+//	shell.complete(CMD, words=[], fn=None, description="", files=True)
+//	                                    define completions for CMD's arguments;
+//	                                    FN returns the candidates, each a word
+//	                                    or a (word, description) pair
+//	shell.segment(NAME, FN, summary="") define a {NAME} prompt segment
+//	shell.on(EVENT, FN)                 handle an event; FN gets its data
+//
+// Functions a script defines run later, when the builtin is run, the prompt
+// drawn or the event fired; print in them writes to the command's output.
+
+// starlarkRCFileName is the Starlark startup file in the home directory
+const starlarkRCFileName = ".goshellrc.star"
+
+// starlarkFileOptions lets scripts use the whole language, including the
+// top-level ifs and loops and the reassignment a configuration file wants
+var starlarkFileOptions = &starsyntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// LoadStarlarkRC runs the user's Starlark startup file. A missing file is
+// not an error.
+func (s *Shell) LoadStarlarkRC() error {
+	path := filepath.Join(s.homeDir(), starlarkRCFileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	return s.execStarlark(path, nil, s.stdio())
+}
+
+// execStarlark runs a Starlark file with args as its arguments
+func (s *Shell) execStarlark(path string, args []string, stdio Stdio) error {
+	thread, done := s.starlarkThread(path, stdio)
+	defer done()
+	predeclared := starlark.StringDict{
+		"shell": s.starlarkModule(),
+		"args":  starlarkStrings(args),
+	}
+	_, err := starlark.ExecFileOptions(starlarkFileOptions, thread, path, nil, predeclared)
+	return err
+}
+
+// starlarkThread returns a thread to run Starlark code on, printing to
+// stdio and cancelled by Ctrl-C or the end of Run's context. done must be
+// called when the code returns.
+func (s *Shell) starlarkThread(name string, stdio Stdio) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(stdio.Stdout, msg)
+		},
+	}
+	thread.SetLocal("stdio", stdio)
+
+	ctx, cancel := s.interruptContext()
+	go func() {
+		<-ctx.Done()
+		thread.Cancel("interrupted")
+	}()
+	return thread, cancel
+}
+
+// callStarlark calls a script's function on a thread of its own, as the
+// builtins, segments and handlers a script defines are called
+func (s *Shell) callStarlark(fn starlark.Callable, args starlark.Tuple, stdio Stdio) (starlark.Value, error) {
+	thread, done := s.starlarkThread(fn.Name(), stdio)
+	defer done()
+	return starlark.Call(thread, fn, args, nil)
+}
+
+// starlarkError formats an error from a script, with the call stack that
+// led to it when there is one
+func starlarkError(err error) string {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return evalErr.Backtrace()
+	}
+	return err.Error()
+}
+
+// starlarkStatus converts what a script's builtin or handler returned to an
+// exit status: None is success, and an int is the status itself
+func starlarkStatus(v starlark.Value) (int, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return 0, nil
+	case starlark.Int:
+		status, err := starlark.AsInt32(v)
+		return status, err
+	case starlark.Bool:
+		if v {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("got %s, want an int or None for the status", v.Type())
+}
+
+// starlarkStrings makes a Starlark list of strings
+func starlarkStrings(strs []string) *starlark.List {
+	values := make([]starlark.Value, len(strs))
+	for i, str := range strs {
+		values[i] = starlark.String(str)
+	}
+	return starlark.NewList(values)
+}
+
+// starlarkModule returns the shell module scripts use to reach the shell
+func (s *Shell) starlarkModule() *starlarkstruct.Module {
+	members := starlark.StringDict{}
+	for name, fn := range map[string]func(*Shell, *starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error){
+		"run":      starlarkRun,
+		"output":   starlarkOutput,
+		"getenv":   starlarkGetenv,
+		"setenv":   starlarkSetenv,
+		"cwd":      starlarkCwd,
+		"builtin":  starlarkBuiltin,
+		"complete": starlarkComplete,
+		"segment":  starlarkSegment,
+		"on":       starlarkOn,
+	} {
+		members[name] = starlark.NewBuiltin("shell."+name, func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return fn(s, thread, b, args, kwargs)
+		})
+	}
+	return &starlarkstruct.Module{Name: "shell", Members: members}
+}
+
+// threadStdio returns the streams the code on a thread writes to
+func threadStdio(thread *starlark.Thread) Stdio {
+	return thread.Local("stdio").(Stdio)
+}
+
+// starlarkRun runs a command line on the script's streams: shell.run(CMD)
+func starlarkRun(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var line string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmd", &line); err != nil {
+		return nil, err
+	}
+	return starlark.MakeInt(s.runLineWith(line, threadStdio(thread))), nil
+}
+
+// starlarkOutput runs a command line and returns what it wrote to its
+// standard output, as $(...) would: shell.output(CMD)
+func starlarkOutput(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var line string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmd", &line); err != nil {
+		return nil, err
+	}
+	stdio := threadStdio(thread)
+	var out strings.Builder
+	stdio.Stdout = &out
+	s.runLineWith(line, stdio)
+	return starlark.String(strings.TrimRight(out.String(), "\n")), nil
+}
+
+// starlarkGetenv reads a variable: shell.getenv(NAME, default="")
+func starlarkGetenv(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, def string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "default?", &def); err != nil {
+		return nil, err
+	}
+	if value := s.env.Get(name); value != "" {
+		return starlark.String(value), nil
+	}
+	return starlark.String(def), nil
+}
+
+// starlarkSetenv sets a variable, or unsets it for None:
+// shell.setenv(NAME, VALUE)
+func starlarkSetenv(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "value", &value); err != nil {
+		return nil, err
+	}
+	switch value := value.(type) {
+	case starlark.NoneType:
+		s.env.Unset(name)
+	case starlark.String:
+		s.env.Set(name, string(value))
+	default:
+		s.env.Set(name, value.String())
+	}
+	return starlark.None, nil
+}
+
+// starlarkCwd returns the working directory: shell.cwd()
+func starlarkCwd(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	dir, err := s.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(dir), nil
+}
+
+// starlarkBuiltin defines a builtin that calls a script's function with its
+// arguments: shell.builtin(NAME, FN, usage="", summary=""). A script may
+// redefine the builtins scripts defined, so a startup file can be run again
+// after it is edited, but no others.
+func starlarkBuiltin(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, usage, summary string
+	var fn starlark.Callable
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "fn", &fn, "usage?", &usage, "summary?", &summary); err != nil {
+		return nil, err
+	}
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return nil, fmt.Errorf("%s: bad builtin name %q", b.Name(), name)
+	}
+	if _, ok := builtins[name]; ok && !s.scriptBuiltins[name] {
+		return nil, fmt.Errorf("%s: builtin '%s' is already defined", b.Name(), name)
+	}
+	if usage == "" {
+		usage = name
+	}
+	if s.scriptBuiltins == nil {
+		s.scriptBuiltins = make(map[string]bool)
+	}
+	registerBuiltin(name, usage, summary, func(s *Shell, args []string, stdio Stdio) int {
+		result, err := s.callStarlark(fn, starlark.Tuple{starlarkStrings(args[1:])}, stdio)
+		if err == nil {
+			var status int
+			if status, err = starlarkStatus(result); err == nil {
+				return status
+			}
+		}
+		fmt.Fprintf(stdio.Stderr, "%s: %s\n", args[0], starlarkError(err))
+		return 1
+	})
+	s.scriptBuiltins[name] = true
+	return starlark.None, nil
+}
+
+// starlarkComplete defines completions for a command's arguments, as the
+// complete builtin does: shell.complete(CMD, words=[], fn=None,
+// description="", files=True). fn is called for the candidates as a
+// generator command would be run.
+func starlarkComplete(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var command, description string
+	var words *starlark.List
+	var fn starlark.Callable
+	files := true
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "cmd", &command, "words?", &words, "fn?", &fn, "description?", &description, "files?", &files); err != nil {
+		return nil, err
+	}
+	spec := &completionSpec{description: description, noFiles: !files}
+	if words != nil {
+		for i := 0; i < words.Len(); i++ {
+			word, ok := starlark.AsString(words.Index(i))
+			if !ok {
+				return nil, fmt.Errorf("%s: words[%d] is %s, not a string", b.Name(), i, words.Index(i).Type())
+			}
+			spec.words = append(spec.words, word)
+		}
+	}
+	if fn != nil {
+		spec.generator = "starlark " + fn.Name()
+		spec.fn = func(s *Shell) (string, error) {
+			return s.starlarkCandidates(fn)
+		}
+	}
+	if len(spec.words) == 0 && spec.fn == nil && !spec.noFiles {
+		return nil, fmt.Errorf("%s: no words or fn given", b.Name())
+	}
+	s.completions[command] = append(s.completions[command], spec)
+	return starlark.None, nil
+}
+
+// starlarkCandidates calls a script's completion function and formats the
+// candidates it returns as a generator's output lines
+func (s *Shell) starlarkCandidates(fn starlark.Callable) (string, error) {
+	stdio := Stdio{Stdin: strings.NewReader(""), Stdout: &strings.Builder{}, Stderr: &strings.Builder{}}
+	result, err := s.callStarlark(fn, nil, stdio)
+	if err != nil {
+		return "", err
+	}
+	iterable, ok := result.(starlark.Iterable)
+	if !ok {
+		return "", fmt.Errorf("%s returned %s, not a list", fn.Name(), result.Type())
+	}
+	var lines []string
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var item starlark.Value
+	for iter.Next(&item) {
+		if text, ok := starlark.AsString(item); ok {
+			lines = append(lines, text)
+		} else if pair, ok := item.(starlark.Tuple); ok && len(pair) == 2 {
+			text, _ := starlark.AsString(pair[0])
+			description, _ := starlark.AsString(pair[1])
+			lines = append(lines, text+"\t"+description)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// starlarkSegment defines a prompt segment whose text a script's function
+// returns: shell.segment(NAME, FN, summary="")
+func starlarkSegment(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, summary string
+	var fn starlark.Callable
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "fn", &fn, "summary?", &summary); err != nil {
+		return nil, err
+	}
+	registerSegment(name, summary, func(s *Shell) string {
+		result, err := s.callStarlark(fn, nil, s.stdio())
+		if err != nil {
+			return ""
+		}
+		if text, ok := starlark.AsString(result); ok {
+			return text
+		} else if result == starlark.None {
+			return ""
+		}
+		return result.String()
+	})
+	return starlark.None, nil
+}
+
+// starlarkOn handles an event with a script's function, as on-event does:
+// shell.on(EVENT, FN). The function gets the event's data, or for
+// command_not_found the missing command's words, whose status its result
+// becomes.
+func starlarkOn(s *Shell, thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var event string
+	var fn starlark.Callable
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "event", &event, "fn", &fn); err != nil {
+		return nil, err
+	}
+	if _, ok := eventNames[event]; !ok || event == "file_changed" {
+		return nil, fmt.Errorf("%s: unknown event: %s", b.Name(), event)
+	}
+	s.addHandler(&eventHandler{
+		event:   event,
+		command: "starlark " + fn.Name(),
+		fn: func(s *Shell, args []string, stdio Stdio) int {
+			arg := starlark.Value(starlark.String(args[0]))
+			if event == "command_not_found" {
+				arg = starlarkStrings(args)
+			}
+			result, err := s.callStarlark(fn, starlark.Tuple{arg}, stdio)
+			if err == nil {
+				var status int
+				if status, err = starlarkStatus(result); err == nil {
+					return status
+				}
+			}
+			fmt.Fprintf(stdio.Stderr, "on-event %s: %s\n", event, starlarkError(err))
+			return 1
+		},
+	})
+	return starlark.None, nil
+}
+
+// builtinStarlark runs a Starlark script
+func builtinStarlark(s *Shell, args []string, stdio Stdio) int {
+	if len(args) < 2 {
+		return newFlagSet("starlark").usage(stdio)
+	}
+	if err := s.execStarlark(args[1], args[2:], stdio); err != nil {
+		fmt.Fprintf(stdio.Stderr, "starlark: %s\n", starlarkError(err))
+		return 1
+	}
+	return 0
+}
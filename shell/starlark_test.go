@@ -0,0 +1,97 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testStarlarkRC is a Starlark startup file using each part of the shell
+// module
+const testStarlarkRC = `
+def greet(args):
+    name = args[0] if args else shell.getenv("GREETING_NAME", "world")
+    print("Hello, %s" % name)
+    if name == "nobody":
+        return 3
+
+shell.builtin("star-greet", greet, usage="star-greet [NAME]", summary="Greet")
+shell.complete("deploy", fn=lambda: ["staging", ("production", "Live servers")], files=False)
+shell.segment("star-branch", lambda: "[" + shell.output("echo main") + "]")
+
+def not_found(words):
+    print("no " + " ".join(words))
+    return 9
+
+shell.on("command_not_found", not_found)
+shell.setenv("FROM_STARLARK", "yes")
+`
+
+func TestStarlark(t *testing.T) {
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, starlarkRCFileName), []byte(testStarlarkRC), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(builtins, "star-greet")
+	defer delete(promptSegments, "star-branch")
+
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	if err := shell.LoadStarlarkRC(); err != nil {
+		t.Fatal(starlarkError(err))
+	}
+	if shell.env.Get("FROM_STARLARK") != "yes" {
+		t.Error("shell.setenv didn't set the variable")
+	}
+
+	for _, test := range []struct {
+		line, want string
+		status     int
+	}{
+		{"star-greet Ada", "Hello, Ada\n", 0},
+		{"star-greet", "Hello, world\n", 0},
+		{"star-greet nobody", "Hello, nobody\n", 3},
+		{"no-such-command -v", "no no-such-command -v\n", 9},
+	} {
+		out, status := runCapture(t, shell, test.line)
+		if out != test.want || status != test.status {
+			t.Errorf("%s: got %q, status %d, want %q, status %d", test.line, out, status, test.want, test.status)
+		}
+	}
+
+	candidates, _ := shell.Complete([]rune("deploy pr"), 9)
+	if len(candidates) != 1 || candidates[0].Text != "production" || candidates[0].Description != "Live servers" {
+		t.Errorf("completions = %v", candidates)
+	}
+
+	shell.env.Set("GOSHELL_PROMPT", "{star-branch}> ")
+	if got := shell.Prompt(); got != "[main]> " {
+		t.Errorf("Prompt() with a Starlark segment = %q", got)
+	}
+
+	// Running the file again redefines its builtin rather than failing
+	if err := shell.LoadStarlarkRC(); err != nil {
+		t.Errorf("running the startup file again: %s", starlarkError(err))
+	}
+}
+
+func TestStarlarkScript(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.star")
+	os.WriteFile(script, []byte(`
+for arg in args:
+    shell.run("echo " + arg)
+fail("stopped")
+`), 0644)
+	shell := NewShell()
+	shell.env.Set("HOME", t.TempDir())
+	if err := shell.LoadStarlarkRC(); err != nil {
+		t.Errorf("LoadStarlarkRC without a startup file: %v", err)
+	}
+
+	var out syncBuffer
+	status := shell.runLineWith("starlark "+script+" a b", Stdio{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out})
+	if got := out.String(); status != 1 || !strings.HasPrefix(got, "a\nb\nstarlark: Traceback") || !strings.Contains(got, "Error in fail: fail: stopped") {
+		t.Errorf("starlark script: status %d, output %q", status, got)
+	}
+}
@@ -0,0 +1,144 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerBuiltin("stat", "stat [-L] file...", "Show a file's type, size, permissions, owner and times", builtinStat)
+	registerFlags("stat", Candidate{"-L", "Follow symlinks"})
+}
+
+// statTimeLayout is how stat shows times, to the nanosecond as GNU stat does
+const statTimeLayout = "2006-01-02 15:04:05.000000000 -0700"
+
+// builtinStat shows what is known about each file: its type, size,
+// permissions in symbolic and octal form, owner, group, times and, for a
+// symlink, its target, with the name styled as ls lists it. It describes
+// symlinks themselves unless -L is given. The output is the same on every
+// platform, unlike the system's stat, apart from the fields a platform
+// doesn't have.
+func builtinStat(s *Shell, args []string, stdio Stdio) int {
+	var follow bool
+	flags := newFlagSet("stat")
+	flags.Bool(&follow, "L")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(files) == 0 {
+		return flags.usage(stdio)
+	}
+
+	color := isTerminal(stdio.Stdout)
+	theme := s.lsTheme()
+	names := make(ownerNames)
+	status := 0
+	for i, file := range files {
+		stat := os.Lstat
+		if follow {
+			stat = os.Stat
+		}
+		info, err := stat(file)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "stat: cannot stat '%s': %v\n", file, unwrapPathError(err))
+			status = 1
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(stdio.Stdout)
+		}
+		printStat(stdio.Stdout, file, info, theme, names, color)
+	}
+	return status
+}
+
+// printStat prints the fields stat shows for a file, colored if color is
+// set
+func printStat(w io.Writer, file string, info fs.FileInfo, theme lsTheme, names ownerNames, color bool) {
+	field := func(label, value string) {
+		line := fmt.Sprintf("%s%7s:%s %s", Bold, label, Reset, value)
+		if !color {
+			line = stripANSI(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	// The name is styled whole, as the extension and any trailing slash
+	// are the last part's
+	name := theme.styledName(strings.TrimSuffix(filepath.Clean(file), string(filepath.Separator)), info)
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := os.Readlink(file); err == nil {
+			name += " -> " + target
+		}
+	}
+	field("File", name)
+	field("Type", fileTypeName(info.Mode()))
+	size := strconv.FormatInt(info.Size(), 10)
+	if info.Size() >= 1024 {
+		size += " (" + formatSize(info.Size()) + ")"
+	}
+	field("Size", size)
+	field("Mode", fmt.Sprintf("%s (%04o)", modeString(info.Mode()), octalMode(info.Mode())))
+
+	links, owner, group := fileOwner(info)
+	if owner != "" {
+		field("Links", strconv.FormatUint(links, 10))
+		field("Owner", Yellow+names.user(owner)+Reset+" ("+owner+")")
+		field("Group", Yellow+names.group(group)+Reset+" ("+group+")")
+	}
+	accessed, changed, born := fileTimes(info)
+	field("Modify", info.ModTime().Format(statTimeLayout))
+	for _, t := range []struct {
+		label string
+		time  time.Time
+	}{{"Access", accessed}, {"Change", changed}, {"Birth", born}} {
+		if !t.time.IsZero() {
+			field(t.label, t.time.Format(statTimeLayout))
+		}
+	}
+}
+
+// fileTypeName describes a file's type in words
+func fileTypeName(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symbolic link"
+	case mode&fs.ModeCharDevice != 0:
+		return "character device"
+	case mode&fs.ModeDevice != 0:
+		return "block device"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&0111 != 0:
+		return "executable file"
+	}
+	return "regular file"
+}
+
+// octalMode returns a file's permissions as chmod takes them in octal,
+// with the setuid, setgid and sticky bits
+func octalMode(mode fs.FileMode) uint32 {
+	octal := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		octal |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		octal |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		octal |= 01000
+	}
+	return octal
+}
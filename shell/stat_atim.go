@@ -0,0 +1,20 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package shell
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns when a file was last read and when its inode last
+// changed. This platform doesn't report when files were created, so born
+// is zero.
+func fileTimes(info fs.FileInfo) (accessed, changed, born time.Time) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	return time.Unix(st.Atim.Unix()), time.Unix(st.Ctim.Unix()), time.Time{}
+}
@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || netbsd
+
+package shell
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns when a file was last read, when its inode last changed
+// and when it was created
+func fileTimes(info fs.FileInfo) (accessed, changed, born time.Time) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	return time.Unix(st.Atimespec.Unix()), time.Unix(st.Ctimespec.Unix()), time.Unix(st.Birthtimespec.Unix())
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !openbsd && !dragonfly && !solaris && !darwin && !freebsd && !netbsd
+
+package shell
+
+import (
+	"io/fs"
+	"time"
+)
+
+// fileTimes returns when a file was last read, when its inode last changed
+// and when it was created. This platform reports none of them through
+// os.Stat, so all are zero.
+func fileTimes(info fs.FileInfo) (accessed, changed, born time.Time) {
+	return
+}
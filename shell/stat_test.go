@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.sh")
+	if err := os.WriteFile(path, make([]byte, 2048), 0754); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0754); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("run.sh", link); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	shell.env.Set("GOSHELL_ICONS", "none")
+
+	out, status := runCapture(t, shell, "stat "+path)
+	for _, want := range []string{
+		"   File: " + path + "\n",
+		"   Type: executable file\n",
+		"   Size: 2048 (2.0KiB)\n",
+		"   Mode: -rwxr-xr-- (0754)\n",
+		" Modify: ",
+	} {
+		if status != 0 || !strings.Contains(out, want) {
+			t.Errorf("stat = %q (status %d), want it to contain %q", out, status, want)
+		}
+	}
+	if strings.Contains(out, "\033[") {
+		t.Errorf("stat colored output that isn't going to a terminal: %q", out)
+	}
+
+	out, _ = runCapture(t, shell, "stat "+link)
+	if !strings.Contains(out, "File: "+link+" -> run.sh\n") || !strings.Contains(out, "Type: symbolic link\n") {
+		t.Errorf("stat of a symlink = %q", out)
+	}
+	out, _ = runCapture(t, shell, "stat -L "+link)
+	if !strings.Contains(out, "Type: executable file\n") {
+		t.Errorf("stat -L of a symlink = %q", out)
+	}
+
+	out, status = runCapture(t, shell, "stat "+filepath.Join(dir, "missing")+" "+path)
+	if status != 1 || !strings.Contains(out, "cannot stat") || !strings.Contains(out, "Size: 2048") {
+		t.Errorf("stat with a missing file = %q (status %d)", out, status)
+	}
+}
+
+func TestOctalMode(t *testing.T) {
+	for mode, want := range map[fs.FileMode]uint32{
+		0644:                              0644,
+		fs.ModeDir | fs.ModeSticky | 0777: 01777,
+		fs.ModeSetuid | 0755:              04755,
+		fs.ModeSetgid | 0750:              02750,
+	} {
+		if got := octalMode(mode); got != want {
+			t.Errorf("octalMode(%v) = %04o, want %04o", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"maps"
+	"slices"
+)
+
+// The builtins of a pipeline run at once, each on a goroutine of its own.
+// All but the last run in a subshell, as sh runs them: a copy of the shell
+// whose working directory, variables, aliases, options and other settings
+// are its own, so cd DIR | cat leaves the shell where it was and stages
+// don't change the same maps at once. The last runs in the shell itself,
+// as in zsh, so what it sets is kept.
+
+// fork returns a subshell, a copy of the shell for a pipeline stage. Its
+// maps and slices are copies; what is behind pointers, such as the history
+// file, event handlers and plugins, is shared, and the secrets and
+// configuration files read are read again.
+func (s *Shell) fork() *Shell {
+	return &Shell{
+		env:            s.env.Clone(),
+		history:        slices.Clone(s.history),
+		commands:       s.commands,
+		completions:    maps.Clone(s.completions),
+		generated:      s.generated,
+		options:        maps.Clone(s.options),
+		bindings:       maps.Clone(s.bindings),
+		fifos:          maps.Clone(s.fifos),
+		fifoDir:        s.fifoDir,
+		events:         s.events,
+		lastStatus:     s.lastStatus,
+		lastDuration:   s.lastDuration,
+		cwd:            s.cwdForSubshell(),
+		histFile:       s.histFile,
+		interrupts:     s.interrupts,
+		visited:        s.visited,
+		dirHistory:     slices.Clone(s.dirHistory),
+		readKey:        s.readKey,
+		stdin:          s.stdin,
+		stdout:         s.stdout,
+		stderr:         s.stderr,
+		ctx:            s.ctx,
+		plugins:        s.plugins,
+		aliases:        maps.Clone(s.aliases),
+		dotenv:         s.dotenv,
+		projects:       s.projects,
+		audit:          s.audit,
+		guards:         slices.Clone(s.guards),
+		dryRun:         s.dryRun,
+		remote:         s.remote,
+		title:          s.title,
+		reportedDir:    s.reportedDir,
+		lastOutput:     s.lastOutput,
+		transcript:     s.transcript,
+		scriptBuiltins: maps.Clone(s.scriptBuiltins),
+		gitStatus:      s.gitStatus,
+		subshell:       true,
+	}
+}
+
+// cwdForSubshell returns the working directory a subshell starts in
+func (s *Shell) cwdForSubshell() string {
+	dir, err := s.Getwd()
+	if err != nil {
+		return s.cwd
+	}
+	return dir
+}
@@ -0,0 +1,96 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// When a command isn't found the shell suggests the builtin or executable
+// on PATH whose name is closest to it, so gti suggests git. With the
+// correct option on it offers to run that command instead.
+
+// suggestCommand returns the known command whose name is closest to name,
+// or "" if none is close enough to be what was meant. Names one edit away
+// are suggested, or two for names longer than four characters, where an
+// edit is inserting, deleting or changing a character or swapping two
+// adjacent ones. Ties go to the name that sorts first.
+func (s *Shell) suggestCommand(name string) string {
+	limit := 1
+	if utf8.RuneCountInString(name) > 4 {
+		limit = 2
+	}
+	candidates := s.commands.names(s.env.Get("PATH"))
+	for builtin := range builtins {
+		candidates = append(candidates, builtin)
+	}
+	sort.Strings(candidates)
+
+	best, bestDistance := "", limit+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of edits that turn a into b: inserting,
+// deleting or substituting a rune, or transposing two adjacent runes
+func editDistance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	// rows[i][j] is the distance between the first i runes of a and the
+	// first j of b; only the last three rows are kept
+	prev2 := make([]int, len(y)+1)
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(y)]
+}
+
+// commandNotFound runs the command_not_found handler in place of a command
+// that isn't a builtin or on PATH, or without one reports it, suggesting
+// the closest command. With the correct option it asks whether to run that
+// instead, and does if the answer is yes.
+func (s *Shell) commandNotFound(st *stage, err error) int {
+	if status, ok := s.runNotFoundHandler(st.args, st.stdio); ok {
+		return status
+	}
+	fmt.Fprintf(st.stdio.Stderr, "Error executing command: %v\n", err)
+	fix := s.suggestCommand(st.args[0])
+	if fix == "" {
+		return 127
+	}
+	if !s.options["correct"] {
+		fmt.Fprintf(st.stdio.Stderr, "goshell: did you mean '%s'?\n", fix)
+		return 127
+	}
+	question := fmt.Sprintf("goshell: run '%s' instead?", fix)
+	if ok, err := s.confirm(question, st.stdio); err != nil || !ok {
+		if err != nil {
+			fmt.Fprintf(st.stdio.Stderr, "goshell: did you mean '%s'?\n", fix)
+		}
+		return 127
+	}
+	st.args = append([]string{fix}, st.args[1:]...)
+	return s.runCommand(st)
+}
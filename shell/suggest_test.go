@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"git", "git", 0},
+		{"gti", "git", 1},
+		{"gi", "git", 1},
+		{"gitt", "git", 1},
+		{"got", "git", 1},
+		{"mkae", "make", 1},
+		{"dokcer", "docker", 1},
+		{"pyhton3", "python3", 1},
+		{"", "ls", 2},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"git", "docker", "notes.txt"} {
+		mode := os.FileMode(0755)
+		if filepath.Ext(name) != "" {
+			mode = 0644
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shell := NewShell()
+	shell.env.Set("PATH", dir)
+
+	tests := map[string]string{
+		"gti":      "git",
+		"dcoker":   "docker",
+		"dockr":    "docker",
+		"ehco":     "echo",
+		"git":      "",
+		"xyz":      "",
+		"dkr":      "",
+		"notes.tx": "",
+	}
+	for name, want := range tests {
+		if got := shell.suggestCommand(name); got != want {
+			t.Errorf("suggestCommand(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	out, status := runCapture(t, shell, "gti status")
+	if want := "goshell: did you mean 'git'?\n"; status != 127 || !strings.HasSuffix(out, want) {
+		t.Errorf("gti status = %q (status %d), want a suggestion of git", out, status)
+	}
+}
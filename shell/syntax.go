@@ -0,0 +1,275 @@
+package shell
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Colors used to highlight source code
+const (
+	synKeyword = Magenta
+	synString  = Green
+	synNumber  = Yellow
+	synComment = Dim
+	synHeading = Bold + Blue
+)
+
+// syntax describes a language well enough to color its keywords, strings,
+// numbers and comments
+type syntax struct {
+	lineComments []string  // markers that start a comment running to the end of the line
+	blockComment [2]string // the markers a comment spanning lines starts and ends with
+	quotes       string    // the characters strings are quoted with
+	keywords     map[string]bool
+	foldCase     bool // keywords are matched ignoring case
+	headings     bool // lines starting with # are headings, as in Markdown
+}
+
+// words returns a set of keywords
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	goSyntax = &syntax{
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`",
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto
+			if import interface map package range return select struct switch type var
+			true false nil iota`),
+	}
+	cSyntax = &syntax{
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'",
+		keywords: words(`auto break case char const continue default do double else enum extern float for
+			goto if inline int long register return short signed sizeof static struct switch typedef
+			union unsigned void volatile while class namespace template typename public private
+			protected virtual new delete this true false nullptr bool #include #define #ifdef
+			#ifndef #endif #if #else`),
+	}
+	javaSyntax = &syntax{
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'",
+		keywords: words(`abstract boolean break byte case catch char class const continue default do
+			double else enum extends final finally float for if implements import instanceof int
+			interface long new package private protected public return short static super switch
+			synchronized this throw throws try void volatile while true false null var record`),
+	}
+	jsSyntax = &syntax{
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`",
+		keywords: words(`async await break case catch class const continue debugger default delete do
+			else export extends finally for function if import in instanceof let new of return
+			static super switch this throw try typeof var void while yield true false null
+			undefined interface type enum implements`),
+	}
+	rustSyntax = &syntax{
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"",
+		keywords: words(`as async await break const continue crate dyn else enum extern false fn for if
+			impl in let loop match mod move mut pub ref return self Self static struct super trait
+			true type unsafe use where while`),
+	}
+	pythonSyntax = &syntax{
+		lineComments: []string{"#"}, quotes: "\"'",
+		keywords: words(`and as assert async await break class continue def del elif else except
+			finally for from global if import in is lambda nonlocal not or pass raise return try
+			while with yield True False None self`),
+	}
+	rubySyntax = &syntax{
+		lineComments: []string{"#"}, quotes: "\"'",
+		keywords: words(`alias and begin break case class def do else elsif end ensure false
+			for if in module next nil not or redo rescue retry return self super then true undef
+			unless until when while yield require attr_accessor`),
+	}
+	shellSyntax = &syntax{
+		lineComments: []string{"#"}, quotes: "\"'",
+		keywords: words(`if then else elif fi case esac for while until do done in function select
+			return local export readonly unset shift exit break continue echo source`),
+	}
+	jsonSyntax = &syntax{quotes: "\"", keywords: words("true false null")}
+	yamlSyntax = &syntax{lineComments: []string{"#"}, quotes: "\"'", keywords: words("true false null yes no on off")}
+	tomlSyntax = &syntax{lineComments: []string{"#"}, quotes: "\"'", keywords: words("true false")}
+	htmlSyntax = &syntax{blockComment: [2]string{"<!--", "-->"}, quotes: "\""}
+	cssSyntax  = &syntax{blockComment: [2]string{"/*", "*/"}, quotes: "\"'", keywords: words("important inherit initial none auto")}
+	sqlSyntax  = &syntax{
+		lineComments: []string{"--"}, blockComment: [2]string{"/*", "*/"}, quotes: "'\"",
+		keywords: words(`select from where and or not insert into values update set delete create table
+			drop alter index join left right inner outer on group by order having limit as null
+			is in like distinct union primary key`),
+		foldCase: true,
+	}
+	markdownSyntax = &syntax{headings: true}
+)
+
+// syntaxByExtension maps file extensions to the language of the file
+var syntaxByExtension = map[string]*syntax{
+	".go":   goSyntax,
+	".c":    cSyntax,
+	".h":    cSyntax,
+	".cpp":  cSyntax,
+	".hpp":  cSyntax,
+	".cc":   cSyntax,
+	".java": javaSyntax,
+	".kt":   javaSyntax,
+	".js":   jsSyntax,
+	".mjs":  jsSyntax,
+	".ts":   jsSyntax,
+	".tsx":  jsSyntax,
+	".jsx":  jsSyntax,
+	".rs":   rustSyntax,
+	".py":   pythonSyntax,
+	".rb":   rubySyntax,
+	".sh":   shellSyntax,
+	".bash": shellSyntax,
+	".zsh":  shellSyntax,
+	".json": jsonSyntax,
+	".yaml": yamlSyntax,
+	".yml":  yamlSyntax,
+	".toml": tomlSyntax,
+	".html": htmlSyntax,
+	".htm":  htmlSyntax,
+	".xml":  htmlSyntax,
+	".css":  cssSyntax,
+	".sql":  sqlSyntax,
+	".md":   markdownSyntax,
+}
+
+// syntaxByName maps the names of files without a telling extension to
+// their language
+var syntaxByName = map[string]*syntax{
+	"Makefile":    shellSyntax,
+	"Dockerfile":  shellSyntax,
+	".bashrc":     shellSyntax,
+	".zshrc":      shellSyntax,
+	".profile":    shellSyntax,
+	rcFileName:    shellSyntax,
+	"go.mod":      goSyntax,
+	"Cargo.toml":  tomlSyntax,
+	"Gemfile":     rubySyntax,
+	"Rakefile":    rubySyntax,
+	"Jenkinsfile": javaSyntax,
+}
+
+// syntaxFor returns the language of a file from its name, or nil if it
+// isn't known
+func syntaxFor(path string) *syntax {
+	name := filepath.Base(path)
+	if sx, ok := syntaxByName[name]; ok {
+		return sx
+	}
+	return syntaxByExtension[strings.ToLower(filepath.Ext(name))]
+}
+
+// highlight colors a line of source code. inComment carries whether a
+// block comment is still open from one line to the next.
+func (sx *syntax) highlight(line string, inComment *bool) string {
+	if sx.headings && strings.HasPrefix(line, "#") {
+		return synHeading + line + Reset
+	}
+	var b strings.Builder
+	paint := func(color, text string) {
+		if text != "" {
+			b.WriteString(color + text + Reset)
+		}
+	}
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch {
+		case *inComment:
+			end := strings.Index(rest, sx.blockComment[1])
+			if end < 0 {
+				paint(synComment, rest)
+				return b.String()
+			}
+			end += len(sx.blockComment[1])
+			paint(synComment, rest[:end])
+			*inComment = false
+			i += end
+		case sx.blockComment[0] != "" && strings.HasPrefix(rest, sx.blockComment[0]):
+			*inComment = true
+			paint(synComment, sx.blockComment[0])
+			i += len(sx.blockComment[0])
+		case sx.startsLineComment(rest):
+			paint(synComment, rest)
+			return b.String()
+		case strings.IndexByte(sx.quotes, rest[0]) >= 0:
+			end := quotedEnd(rest)
+			paint(synString, rest[:end])
+			i += end
+		case rest[0] == '#' && sx.keywords[wordAt(rest[1:], "#")]:
+			// A preprocessor directive such as #include
+			word := wordAt(rest[1:], "#")
+			paint(synKeyword, word)
+			i += len(word)
+		case isWordByte(rest[0]):
+			word := wordAt(rest, "")
+			switch {
+			case sx.isKeyword(word):
+				paint(synKeyword, word)
+			case word[0] >= '0' && word[0] <= '9':
+				paint(synNumber, word)
+			default:
+				b.WriteString(word)
+			}
+			i += len(word)
+		default:
+			b.WriteByte(rest[0])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// isKeyword reports whether word is one of the language's keywords
+func (sx *syntax) isKeyword(word string) bool {
+	if sx.foldCase {
+		word = strings.ToLower(word)
+	}
+	return sx.keywords[word]
+}
+
+// startsLineComment reports whether text starts with one of the language's
+// line comment markers
+func (sx *syntax) startsLineComment(text string) bool {
+	for _, marker := range sx.lineComments {
+		if strings.HasPrefix(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// quotedEnd returns where the string text starts with ends: after its
+// closing quote, skipping escaped ones, or at the end of the line
+func quotedEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(text)
+}
+
+// isWordByte reports whether c can be part of an identifier, keyword or
+// number. Bytes of multibyte characters count, so that words in any script
+// are kept whole.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || c < 0x80 && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)))
+}
+
+// wordAt returns the word text starts with, including prefix, which has
+// already been matched before text
+func wordAt(text, prefix string) string {
+	end := 0
+	for end < len(text) && (isWordByte(text[end]) || text[end] == '.' && end > 0 && text[0] >= '0' && text[0] <= '9') {
+		end++
+	}
+	return prefix + text[:end]
+}
@@ -0,0 +1,297 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goshell/internal/lineedit"
+)
+
+func init() {
+	registerBuiltin("table", "table [-r] [-c COLUMNS] [-s COLUMN] [-f tsv|csv|json] [file]", "Show TSV, CSV, JSON lines or records as an aligned table", builtinTable)
+	registerFlags("table",
+		Candidate{"-c", "Show only these columns, separated by commas"},
+		Candidate{"-s", "Sort the rows by a column"},
+		Candidate{"-r", "Sort in reverse"},
+		Candidate{"-f", "Read the input as tsv, csv or json rather than guessing"})
+	registerRecords("table")
+}
+
+// tableMinWidth is as narrow as a column is cut to make a table fit the
+// terminal
+const tableMinWidth = 6
+
+// tableCellReplacer keeps a cell on one line
+var tableCellReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+// builtinTable reads rows and shows them as a table, its columns aligned
+// and numbers to the right. The rows are the records of the builtin before
+// it in a pipeline, or read from a file or stdin as JSON objects, TSV or
+// CSV, whose first line names the columns; which one is guessed from the
+// input unless -f says. -c picks the columns to show, in order, and -s
+// sorts the rows by one. On a terminal the header is bold and columns too
+// wide for the screen are cut short.
+func builtinTable(s *Shell, args []string, stdio Stdio) int {
+	var columns, sortBy, format string
+	var reverse bool
+	flags := newFlagSet("table")
+	flags.String(&columns, "c")
+	flags.String(&sortBy, "s")
+	flags.String(&format, "f")
+	flags.Bool(&reverse, "r")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) > 1 || (format != "" && format != "tsv" && format != "csv" && format != "json") {
+		return flags.usage(stdio)
+	}
+	if len(operands) == 1 {
+		f, err := os.Open(operands[0])
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "table:", unwrapPathError(err))
+			return 1
+		}
+		defer f.Close()
+		stdio.Stdin = f
+	}
+
+	records, err := readTable(stdio, format)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "table:", err)
+		return 1
+	}
+	if sortBy != "" {
+		sortRecords(records, []string{sortBy}, reverse)
+	}
+	var shown []string
+	if columns != "" {
+		shown = strings.Split(columns, ",")
+	} else {
+		shown = recordColumns(records)
+	}
+	return s.paged(stdio, func(stdio Stdio) int {
+		width := 0
+		color := isTerminal(stdio.Stdout)
+		if color {
+			if size, err := getTerminalSize(); err == nil {
+				width = size.Col
+			}
+		}
+		renderTable(stdio.Stdout, records, shown, width, color)
+		return 0
+	})
+}
+
+// readTable reads the rows of a table: records from the stage before, or
+// text in the format given or, with none, the one it looks like
+func readTable(stdio Stdio, format string) ([]*record, error) {
+	var records []*record
+	add := func(r *record) error {
+		records = append(records, r)
+		return nil
+	}
+	if stdio.recordsIn != nil {
+		err := readRecords(stdio, add)
+		return records, err
+	}
+	data, err := io.ReadAll(stdio.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = guessTableFormat(data)
+	}
+	switch format {
+	case "json":
+		err := readRecords(Stdio{Stdin: bytes.NewReader(data)}, add)
+		return records, err
+	case "tsv":
+		var rows [][]string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			rows = append(rows, strings.Split(strings.TrimSuffix(scanner.Text(), "\r"), "\t"))
+		}
+		return rowRecords(rows), scanner.Err()
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return rowRecords(rows), nil
+}
+
+// guessTableFormat tells JSON, which starts with an object or array, from
+// TSV, whose first line has tabs, and CSV
+func guessTableFormat(data []byte) string {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "json"
+	}
+	first, _, _ := bytes.Cut(trimmed, []byte("\n"))
+	if bytes.ContainsRune(first, '\t') {
+		return "tsv"
+	}
+	return "csv"
+}
+
+// rowRecords makes records of rows of text, the first naming the columns.
+// Cells holding numbers become numbers, so they sort and align as numbers.
+func rowRecords(rows [][]string) []*record {
+	if len(rows) == 0 {
+		return nil
+	}
+	header := rows[0]
+	var records []*record
+	for _, row := range rows[1:] {
+		r := newRecord()
+		for i, cell := range row {
+			name := strconv.Itoa(i + 1)
+			if i < len(header) && header[i] != "" {
+				name = header[i]
+			}
+			r.set(name, cellValue(cell))
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// cellValue reads a cell of text as a number if it is one
+func cellValue(cell string) any {
+	if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	return cell
+}
+
+// recordColumns returns the fields of records, in the order they first
+// appear
+func recordColumns(records []*record) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, r := range records {
+		for _, key := range r.keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	return columns
+}
+
+// tableCell renders a value for a table
+func tableCell(value any) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Local().Format("2006-01-02 15:04")
+	}
+	return tableCellReplacer.Replace(formatValue(value))
+}
+
+// renderTable writes records as a table of the given columns. Columns
+// holding only numbers are aligned to the right, and colored with color.
+// With a width, the widest columns are narrowed until the table fits,
+// cutting their cells short.
+func renderTable(w io.Writer, records []*record, columns []string, width int, color bool) {
+	if len(columns) == 0 {
+		return
+	}
+	cells := make([][]string, len(records))
+	widths := make([]int, len(columns))
+	numeric := make([]bool, len(columns))
+	for j, column := range columns {
+		widths[j] = lineedit.StringWidth(tableCellReplacer.Replace(column))
+		numeric[j] = true
+	}
+	for i, r := range records {
+		cells[i] = make([]string, len(columns))
+		for j, column := range columns {
+			value, ok := r.get(column)
+			if !ok || value == nil {
+				continue
+			}
+			if _, ok := recordNumber(value); !ok {
+				numeric[j] = false
+			}
+			cells[i][j] = tableCell(value)
+			widths[j] = max(widths[j], lineedit.StringWidth(cells[i][j]))
+		}
+	}
+
+	if width > 0 {
+		total := 2 * (len(columns) - 1)
+		for _, w := range widths {
+			total += w
+		}
+		for total > width {
+			widest := 0
+			for j := range widths {
+				if widths[j] > widths[widest] {
+					widest = j
+				}
+			}
+			if widths[widest] <= tableMinWidth {
+				break
+			}
+			widths[widest]--
+			total--
+		}
+	}
+
+	line := func(row []string, header bool) string {
+		var b strings.Builder
+		for j, cell := range row {
+			cell = truncateCell(cell, widths[j])
+			if numeric[j] {
+				cell = padLeft(cell, widths[j])
+			} else if j < len(row)-1 {
+				cell = padRight(cell, widths[j])
+			}
+			if color && numeric[j] && !header {
+				cell = Cyan + cell + Reset
+			}
+			if j > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(cell)
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	names := make([]string, len(columns))
+	for j, column := range columns {
+		names[j] = tableCellReplacer.Replace(column)
+	}
+	header := line(names, true)
+	if color {
+		header = Bold + header + Reset
+	}
+	fmt.Fprintln(w, header)
+	for _, row := range cells {
+		fmt.Fprintln(w, line(row, false))
+	}
+}
+
+// truncateCell cuts text to width terminal columns, ending it with an
+// ellipsis where it was cut
+func truncateCell(text string, width int) string {
+	if lineedit.StringWidth(text) <= width {
+		return text
+	}
+	return fitWidth(text, width-1) + "…"
+}
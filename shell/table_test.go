@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTableFormats(t *testing.T) {
+	shell := NewShell()
+	for _, test := range []struct {
+		input, command, want string
+	}{
+		{"name\tsize\nb.txt\t10\na.txt\t9\n", "table", "name   size\nb.txt    10\na.txt     9\n"},
+		{"name,size\nb.txt,10\n\"a, z.txt\",9\n", "table -s size", "name      size\na, z.txt     9\nb.txt       10\n"},
+		{`{"name":"x","n":1.5}` + "\n" + `{"name":"longer","ok":true}`, "table", "name      n  ok\nx       1.5\nlonger       true\n"},
+		{`[{"a":1,"b":2},{"a":3,"b":4}]`, "table -c b,a -s a -r", "b  a\n4  3\n2  1\n"},
+		{"a\tb\n1\t2\n", "table -f csv", "a b\n1 2\n"},
+	} {
+		var out syncBuffer
+		stdio := Stdio{Stdin: strings.NewReader(test.input), Stdout: &out, Stderr: &out}
+		if status := shell.runArgs(strings.Fields(test.command), stdio); status != 0 || out.String() != test.want {
+			t.Errorf("%s of %q printed %q (status %d), want %q", test.command, test.input, out.String(), status, test.want)
+		}
+	}
+}
+
+func TestTableFromRecords(t *testing.T) {
+	out, status := runCapture(t, NewShell(), `sort-by n < /dev/null | table`)
+	if status != 0 || out != "" {
+		t.Errorf("an empty table printed %q (status %d)", out, status)
+	}
+
+	var buf bytes.Buffer
+	records := []*record{newRecord(), newRecord()}
+	records[0].set("path", "/usr/share/doc/a-very-long-package-name/README")
+	records[0].set("size", int64(1200))
+	records[1].set("path", "/tmp/x")
+	records[1].set("size", int64(7))
+	renderTable(&buf, records, []string{"path", "size"}, 30, false)
+	want := "path                      size\n/usr/share/doc/a-very-l…  1200\n/tmp/x                       7\n"
+	if buf.String() != want {
+		t.Errorf("table narrowed to 30 columns = %q, want %q", buf.String(), want)
+	}
+}
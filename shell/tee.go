@@ -0,0 +1,48 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func init() {
+	registerBuiltin("tee", "tee [-a] [file...]", "Copy stdin to stdout and files", builtinTee)
+	registerFlags("tee", Candidate{"-a", "Append to the files"})
+}
+
+// builtinTee copies its input to stdout and to every named file. With -a the
+// files are appended to instead of truncated. Process substitutions such as
+// >(gzip > out.gz) work as targets because they expand to named pipes.
+func builtinTee(s *Shell, args []string, stdio Stdio) int {
+	var appending bool
+	flags := newFlagSet("tee")
+	flags.Bool(&appending, "a", "append")
+	paths, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	status := 0
+	writers := []io.Writer{stdio.Stdout}
+	for _, path := range paths {
+		f, err := os.OpenFile(path, mode, 0666)
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "tee:", err)
+			status = 1
+			continue
+		}
+		defer f.Close()
+		writers = append(writers, f)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), stdio.Stdin); err != nil {
+		fmt.Fprintln(stdio.Stderr, "tee:", err)
+		return 1
+	}
+	return status
+}
@@ -0,0 +1,52 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTee(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	shell := NewShell()
+
+	out, status := runCapture(t, shell, "echo hello | tee "+first+" "+second)
+	if out != "hello\n" || status != 0 {
+		t.Errorf("tee output = %q (status %d), want %q", out, status, "hello\n")
+	}
+	runCapture(t, shell, "echo again | tee -a "+first)
+
+	for path, want := range map[string]string{first: "hello\nagain\n", second: "hello\n"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), data, want)
+		}
+	}
+}
+
+func TestTeeProcessSubstitution(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upper.txt")
+	shell := NewShell()
+
+	out, _ := runCapture(t, shell, "echo hello | tee >(tr a-z A-Z > "+path+")")
+	if out != "hello\n" {
+		t.Errorf("tee output = %q, want %q", out, "hello\n")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "HELLO\n" {
+		t.Errorf("substituted command wrote %q, want %q", data, "HELLO\n")
+	}
+
+	out, _ = runCapture(t, shell, "cat <(echo from-input)")
+	if out != "from-input\n" {
+		t.Errorf("input substitution = %q, want %q", out, "from-input\n")
+	}
+}
@@ -0,0 +1,303 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerBuiltin("cut", "cut [-d D] -f LIST", "Extract fields (-f) or characters (-c) from lines", builtinCut)
+	registerBuiltin("tr", "tr [-ds] SET1 [SET2]", "Translate, delete or squeeze characters", builtinTr)
+	registerFlags("cut",
+		Candidate{"-d", "Field delimiter (default: tab)"},
+		Candidate{"-f", "Select fields"},
+		Candidate{"-c", "Select characters"})
+	registerFlags("tr",
+		Candidate{"-d", "Delete characters in SET1"},
+		Candidate{"-s", "Squeeze repeated characters"})
+}
+
+// fieldRange is an inclusive 1-based range from a cut list; end 0 means the
+// range is open-ended
+type fieldRange struct {
+	start, end int
+}
+
+// parseRangeList parses a cut list such as "1,3-5,7-"
+func parseRangeList(list string) ([]fieldRange, error) {
+	var ranges []fieldRange
+	for _, part := range strings.Split(list, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		var r fieldRange
+		var err error
+		if from == "" {
+			r.start = 1
+		} else if r.start, err = strconv.Atoi(from); err != nil || r.start < 1 {
+			return nil, fmt.Errorf("invalid field value: %s", part)
+		}
+		switch {
+		case !isRange:
+			r.end = r.start
+		case to != "":
+			if r.end, err = strconv.Atoi(to); err != nil || r.end < r.start {
+				return nil, fmt.Errorf("invalid field range: %s", part)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// selects reports whether the 1-based position n is in any of the ranges
+func selects(ranges []fieldRange, n int) bool {
+	for _, r := range ranges {
+		if n >= r.start && (r.end == 0 || n <= r.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinCut prints selected fields (-f, split on -d, default tab) or
+// character positions (-c) of each input line. Lines without the delimiter
+// are printed whole in field mode.
+func builtinCut(s *Shell, args []string, stdio Stdio) int {
+	delimiter := "\t"
+	var fieldList, charList string
+	flags := newFlagSet("cut")
+	flags.String(&delimiter, "d")
+	flags.String(&fieldList, "f")
+	flags.String(&charList, "c")
+	files, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	if (fieldList == "") == (charList == "") || len([]rune(delimiter)) != 1 {
+		fmt.Fprintln(stdio.Stderr, "Usage: cut [-d DELIM] -f LIST | -c LIST [file...]")
+		return 1
+	}
+	list := fieldList
+	if list == "" {
+		list = charList
+	}
+	ranges, err := parseRangeList(list)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "cut:", err)
+		return 1
+	}
+
+	input, closeInputs, err := openInputs(files, stdio.Stdin)
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "cut:", err)
+		return 1
+	}
+	defer closeInputs()
+
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	reader := bufio.NewReader(input)
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "cut:", err)
+			return 1
+		}
+
+		if charList != "" {
+			for i, r := range []rune(line) {
+				if selects(ranges, i+1) {
+					out.WriteRune(r)
+				}
+			}
+			out.WriteByte('\n')
+			continue
+		}
+
+		if !strings.Contains(line, delimiter) {
+			fmt.Fprintln(out, line)
+			continue
+		}
+		var selected []string
+		for i, field := range strings.Split(line, delimiter) {
+			if selects(ranges, i+1) {
+				selected = append(selected, field)
+			}
+		}
+		fmt.Fprintln(out, strings.Join(selected, delimiter))
+	}
+	return 0
+}
+
+// charClasses maps tr's POSIX classes to predicates
+var charClasses = map[string]func(rune) bool{
+	"alpha": unicode.IsLetter,
+	"digit": unicode.IsDigit,
+	"alnum": func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"lower": unicode.IsLower,
+	"upper": unicode.IsUpper,
+	"space": unicode.IsSpace,
+	"blank": func(r rune) bool { return r == ' ' || r == '\t' },
+	"punct": unicode.IsPunct,
+}
+
+// expandCharSet expands a tr set with escapes, ranges (a-z) and ASCII
+// classes ([:upper:]) into the list of characters it denotes
+func expandCharSet(set string) ([]rune, error) {
+	var chars []rune
+	src := []rune(set)
+	for i := 0; i < len(src); i++ {
+		if src[i] == '[' && i+1 < len(src) && src[i+1] == ':' {
+			end := strings.Index(string(src[i:]), ":]")
+			if end > 0 {
+				name := string(src[i+2 : i+end])
+				pred, ok := charClasses[name]
+				if !ok {
+					return nil, fmt.Errorf("invalid character class '%s'", name)
+				}
+				for r := rune(0); r < 128; r++ {
+					if pred(r) {
+						chars = append(chars, r)
+					}
+				}
+				i += end + 1
+				continue
+			}
+		}
+
+		c := src[i]
+		if c == '\\' && i+1 < len(src) {
+			i++
+			c = unescapeChar(src[i])
+		}
+		if i+2 < len(src) && src[i+1] == '-' {
+			hi := src[i+2]
+			if hi == '\\' && i+3 < len(src) {
+				hi = unescapeChar(src[i+3])
+				i++
+			}
+			if hi < c {
+				return nil, fmt.Errorf("range-endpoints of '%c-%c' are in reverse order", c, hi)
+			}
+			for r := c; r <= hi; r++ {
+				chars = append(chars, r)
+			}
+			i += 2
+			continue
+		}
+		chars = append(chars, c)
+	}
+	return chars, nil
+}
+
+// unescapeChar returns the character denoted by a backslash escape
+func unescapeChar(c rune) rune {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	}
+	return c
+}
+
+// builtinTr translates characters of SET1 to the matching ones in SET2. With
+// -d characters in SET1 are deleted; -s squeezes repeats of the characters in
+// the last set into one.
+func builtinTr(s *Shell, args []string, stdio Stdio) int {
+	deleteChars, squeeze := false, false
+	flags := newFlagSet("tr")
+	flags.Bool(&deleteChars, "d")
+	flags.Bool(&squeeze, "s")
+	flags.stopAtOperand = true
+	sets, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+
+	wantSets := 2
+	if deleteChars || (squeeze && len(sets) == 1) {
+		wantSets = 1
+	}
+	if len(sets) != wantSets {
+		fmt.Fprintln(stdio.Stderr, "Usage: tr [-ds] SET1 [SET2]")
+		return 1
+	}
+
+	from, err := expandCharSet(sets[0])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "tr:", err)
+		return 1
+	}
+	var to []rune
+	if len(sets) == 2 {
+		if to, err = expandCharSet(sets[1]); err != nil || len(to) == 0 {
+			fmt.Fprintln(stdio.Stderr, "tr: invalid second set")
+			return 1
+		}
+	}
+
+	mapping := make(map[rune]rune)
+	deleted := make(map[rune]bool)
+	for i, r := range from {
+		switch {
+		case deleteChars:
+			deleted[r] = true
+		case to != nil:
+			// A short SET2 is padded with its last character
+			if i < len(to) {
+				mapping[r] = to[i]
+			} else {
+				mapping[r] = to[len(to)-1]
+			}
+		}
+	}
+	squeezed := make(map[rune]bool)
+	if squeeze {
+		last := from
+		if to != nil {
+			last = to
+		}
+		for _, r := range last {
+			squeezed[r] = true
+		}
+	}
+
+	reader := bufio.NewReader(stdio.Stdin)
+	out := bufio.NewWriter(stdio.Stdout)
+	defer out.Flush()
+	var previous rune = -1
+	for {
+		r, _, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "tr:", err)
+			return 1
+		}
+		if deleted[r] {
+			continue
+		}
+		if mapped, ok := mapping[r]; ok {
+			r = mapped
+		}
+		if squeezed[r] && r == previous {
+			continue
+		}
+		previous = r
+		out.WriteRune(r)
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package shell
+
+import "testing"
+
+func TestCut(t *testing.T) {
+	tests := []struct {
+		args  []string
+		input string
+		want  string
+	}{
+		{[]string{"cut", "-d", ",", "-f", "2"}, "a,b,c\nd,e,f\n", "b\ne\n"},
+		{[]string{"cut", "-d:", "-f1,3-"}, "a:b:c:d\n", "a:c:d\n"},
+		{[]string{"cut", "-f2"}, "x\ty\nno tabs\n", "y\nno tabs\n"},
+		{[]string{"cut", "-c", "2-4"}, "abcdef\n", "bcd\n"},
+		{[]string{"cut", "-c-2"}, "héllo\n", "hé\n"},
+	}
+	for _, tt := range tests {
+		if got, status := runBuiltin(t, tt.args, tt.input); status != 0 || got != tt.want {
+			t.Errorf("%v = %q (status %d), want %q", tt.args, got, status, tt.want)
+		}
+	}
+
+	if _, status := runBuiltin(t, []string{"cut", "-f", "0"}, ""); status == 0 {
+		t.Errorf("cut -f 0 succeeded, want error")
+	}
+}
+
+func TestTr(t *testing.T) {
+	tests := []struct {
+		args  []string
+		input string
+		want  string
+	}{
+		{[]string{"tr", "a-z", "A-Z"}, "hello\n", "HELLO\n"},
+		{[]string{"tr", "[:lower:]", "[:upper:]"}, "mixed Case\n", "MIXED CASE\n"},
+		{[]string{"tr", "abc", "x"}, "aabbcc\n", "xxxxxx\n"},
+		{[]string{"tr", "-d", "0-9"}, "a1b2c3\n", "abc\n"},
+		{[]string{"tr", "-s", " "}, "a    b  c\n", "a b c\n"},
+		{[]string{"tr", "\\n", " "}, "one\ntwo\n", "one two "},
+	}
+	for _, tt := range tests {
+		if got, status := runBuiltin(t, tt.args, tt.input); status != 0 || got != tt.want {
+			t.Errorf("%v = %q (status %d), want %q", tt.args, got, status, tt.want)
+		}
+	}
+}
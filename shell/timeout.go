@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"goshell/internal/parser"
+)
+
+func init() {
+	registerBuiltin("timeout", "timeout [-k GRACE] DURATION cmd...", "Run a command, stopping it if it runs too long", builtinTimeout)
+	registerFlags("timeout", Candidate{"-k", "How long to wait after SIGTERM before SIGKILL (default 5s)"})
+}
+
+// defaultGrace is how long a command that has run out of time gets to exit
+// after SIGTERM before it is killed
+const defaultGrace = 5 * time.Second
+
+// timedOutStatus is the exit status of a command that ran out of time, as
+// coreutils' timeout exits with
+const timedOutStatus = 124
+
+// commandLimit bounds how long the external commands of a pipeline run.
+// When its context ends they are sent SIGTERM, and killed if they are still
+// running after the grace period.
+type commandLimit struct {
+	ctx   context.Context
+	grace time.Duration
+}
+
+// builtinTimeout runs a command with a deadline. Durations are numbers of
+// seconds or carry a unit: 1.5s, 2m, 1h or 1d. A command still running at
+// the deadline is sent SIGTERM, then SIGKILL once the grace period given
+// with -k is over, and timeout exits with 124. A duration of 0 means no
+// limit. Builtins run within the shell and can't be stopped this way.
+func builtinTimeout(s *Shell, args []string, stdio Stdio) int {
+	grace := defaultGrace
+	flags := newFlagSet("timeout")
+	flags.Func(func(value string) error {
+		d, err := parseTimeout(value)
+		grace = d
+		return err
+	}, "k")
+	flags.stopAtOperand = true
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) < 2 {
+		return flags.usage(stdio)
+	}
+	limit, err := parseTimeout(operands[0])
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "timeout:", err)
+		return 125
+	}
+
+	words := make([]string, len(operands)-1)
+	for i, arg := range operands[1:] {
+		words[i] = shellQuote(arg)
+	}
+	p := &parser.Pipeline{Commands: []*parser.Command{{Words: words}}}
+	if limit == 0 {
+		return s.runPipeline(p, stdio)
+	}
+	ctx, cancel := context.WithTimeout(s.context(), limit)
+	defer cancel()
+	status := s.runPipelineWithin(p, &commandLimit{ctx: ctx, grace: grace}, stdio)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return timedOutStatus
+	}
+	return status
+}
+
+// parseTimeout parses a duration for timeout: a number of seconds, or one
+// with a unit of s, m, h or d, which time.ParseDuration lacks
+func parseTimeout(spec string) (time.Duration, error) {
+	number, unit := spec, time.Second
+	for suffix, d := range map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(spec, suffix); ok {
+			number, unit = n, d
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || n > float64(1<<62)/float64(unit) {
+		return 0, fmt.Errorf("invalid time interval '%s'", spec)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// terminate asks a process to exit with SIGTERM, or kills it where signals
+// other than kill can't be sent
+func terminate(p *os.Process) error {
+	if err := p.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return p.Kill()
+	}
+	return nil
+}
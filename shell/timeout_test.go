@@ -0,0 +1,57 @@
+package shell
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	for spec, want := range map[string]time.Duration{
+		"10":   10 * time.Second,
+		"1.5s": 1500 * time.Millisecond,
+		"2m":   2 * time.Minute,
+		"1h":   time.Hour,
+		"1d":   24 * time.Hour,
+		"0":    0,
+	} {
+		if got, err := parseTimeout(spec); err != nil || got != want {
+			t.Errorf("parseTimeout(%q) = %v, %v, want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "x", "-1", "5ms", "1e300d"} {
+		if _, err := parseTimeout(spec); err == nil {
+			t.Errorf("parseTimeout(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	shell := NewShell()
+	start := time.Now()
+	out, status := runCapture(t, shell, "timeout 0.2 sleep 5")
+	if status != timedOutStatus || out != "" {
+		t.Errorf("timeout 0.2 sleep 5 = %q (status %d), want no output and status %d", out, status, timedOutStatus)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the command ran for %v after its deadline", elapsed)
+	}
+
+	// A command that ignores SIGTERM is killed after the grace period
+	start = time.Now()
+	if _, status := runCapture(t, shell, `timeout -k 0.2 0.2 sh -c 'trap "" TERM; sleep 5'`); status != timedOutStatus {
+		t.Errorf("timeout of a command ignoring SIGTERM exited with %d", status)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the command ignoring SIGTERM ran for %v", elapsed)
+	}
+
+	if out, status := runCapture(t, shell, "timeout 5 echo done"); out != "done\n" || status != 0 {
+		t.Errorf("timeout 5 echo done = %q (status %d)", out, status)
+	}
+	if _, status := runCapture(t, shell, "timeout 5 false"); status != 1 {
+		t.Errorf("timeout 5 false exited with %d, want 1", status)
+	}
+	if _, status := runCapture(t, shell, "timeout soon true"); status != 125 {
+		t.Errorf("timeout with a bad duration exited with %d, want 125", status)
+	}
+}
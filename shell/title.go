@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"io"
+	"strings"
+	"unicode"
+)
+
+// The terminal's title, in the window or tab bar, shows the working
+// directory at the prompt and the command line while it runs, set with the
+// OSC 0 escape sequence. The title the terminal had is saved on its title
+// stack first and put back when the shell exits, or set +o title turns the
+// titles off; set +o title in ~/.goshellrc keeps them off.
+
+// maxTitleLength is how many characters of a command line the title shows
+const maxTitleLength = 80
+
+// showTitle sets the title of the terminal w, unless titles are off. A nil
+// w is not a terminal, so it has no title.
+func (s *Shell) showTitle(w io.Writer, title string) {
+	if w == nil {
+		return
+	}
+	if !s.options["title"] || s.env.Get("TERM") == "dumb" {
+		s.restoreTitle()
+		return
+	}
+	if s.title == nil {
+		// Save the terminal's own title to put back at the end
+		io.WriteString(w, "\x1b[22;0t")
+		s.title = w
+	}
+	io.WriteString(w, "\x1b]0;"+titleText(title)+"\a")
+}
+
+// restoreTitle puts back the title the terminal had before the shell set
+// its own
+func (s *Shell) restoreTitle() {
+	if s.title == nil {
+		return
+	}
+	io.WriteString(s.title, "\x1b[23;0t")
+	s.title = nil
+}
+
+// idleTitle is the title at the prompt: the working directory, with the
+// host in remote mode
+func (s *Shell) idleTitle() string {
+	if s.remote != nil {
+		return s.remote.host + ":" + s.remote.tildePath(s.remote.cwd)
+	}
+	dir, _ := s.Getwd()
+	return s.tildePath(dir)
+}
+
+// commandTitle is the title while line runs: its first line, with secrets
+// redacted as in the history file, cut short if long
+func (s *Shell) commandTitle(line string) string {
+	line, _, cut := strings.Cut(line, "\n")
+	if patterns, err := s.redactionPatterns(); err == nil {
+		line = redactSecrets(patterns, line)
+	} else {
+		line = redactSecrets(secretPatterns, line)
+	}
+	if runes := []rune(line); len(runes) > maxTitleLength {
+		line, cut = string(runes[:maxTitleLength-1]), true
+	}
+	if cut {
+		line += "…"
+	}
+	return line
+}
+
+// titleText returns title without the control characters that would end
+// the escape sequence setting it early
+func titleText(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, title)
+}
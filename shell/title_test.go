@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTitle(t *testing.T) {
+	shell := NewShell()
+	shell.env.Set("TERM", "xterm-256color")
+	var out bytes.Buffer
+	shell.showTitle(nil, "not a terminal")
+	shell.showTitle(&out, "~/src")
+	shell.showTitle(&out, "make test")
+	if got, want := out.String(), "\x1b[22;0t\x1b]0;~/src\a\x1b]0;make test\a"; got != want {
+		t.Errorf("titles = %q, want %q", got, want)
+	}
+
+	// Turning titles off puts the terminal's own title back
+	out.Reset()
+	shell.setOption("title", false)
+	shell.showTitle(&out, "~/src")
+	shell.restoreTitle()
+	if got, want := out.String(), "\x1b[23;0t"; got != want {
+		t.Errorf("titles off = %q, want %q", got, want)
+	}
+
+	out.Reset()
+	shell.setOption("title", true)
+	shell.showTitle(&out, "~")
+	shell.Close()
+	if got, want := out.String(), "\x1b[22;0t\x1b]0;~\a\x1b[23;0t"; got != want {
+		t.Errorf("titles until Close = %q, want %q", got, want)
+	}
+}
+
+func TestCommandTitle(t *testing.T) {
+	shell := NewShell()
+	tests := []struct {
+		line, want string
+	}{
+		{"make test", "make test"},
+		{"curl -H 'Authorization: Bearer abcdefghijkl' example.com", "curl -H 'Authorization: Bearer ***' example.com"},
+		{"for f in *\ndo echo $f\ndone", "for f in *…"},
+		{strings.Repeat("x", 100), strings.Repeat("x", maxTitleLength-1) + "…"},
+		{"printf '\x1b]0;evil\a'", "printf ']0;evil'"},
+	}
+	for _, tt := range tests {
+		if got := titleText(shell.commandTitle(tt.line)); got != tt.want {
+			t.Errorf("commandTitle(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
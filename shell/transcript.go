@@ -0,0 +1,186 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// set -o logfile=PATH keeps a transcript of the session: the prompt and
+// command line typed, and what the commands print on stdout and stderr,
+// each line with the time, without colors. strftime conversions in PATH
+// start a new file as the date changes, as in
+// set -o logfile=~/.goshell/logs/%Y%m%d.log, and set +o logfile stops it.
+// Like lastout, it has commands write through the shell; full-screen
+// programs still run on the terminal, with only their command line logged.
+
+// transcriptTimeFormat is the time each line of a transcript starts with
+const transcriptTimeFormat = "2006-01-02 15:04:05"
+
+// transcriptLog is the file a transcript is written to
+type transcriptLog struct {
+	pattern string // PATH as given to set -o logfile
+
+	mu   sync.Mutex
+	path string // of the file open, the pattern with its date filled in
+	file *os.File
+}
+
+// logfile returns the path set -o logfile gave, "" when there is none
+func (s *Shell) logfile() string {
+	if s.transcript == nil {
+		return ""
+	}
+	return s.transcript.pattern
+}
+
+// setLogfile starts a transcript in the file pattern names, with ~ for the
+// home directory and strftime conversions for the date, or stops it if
+// pattern is "". The file is opened at once, so a path that can't be
+// written to is reported.
+func (s *Shell) setLogfile(pattern string) error {
+	if s.transcript != nil {
+		s.transcript.close()
+		s.transcript = nil
+	}
+	if pattern == "" {
+		return nil
+	}
+	t := &transcriptLog{pattern: pattern}
+	if err := t.open(s, time.Now()); err != nil {
+		return err
+	}
+	s.transcript = t
+	return nil
+}
+
+// open opens the file the transcript goes to at now, closing the one open
+// if its date has passed. Missing directories are created, for the user
+// alone, as the transcript may hold secrets.
+func (t *transcriptLog) open(s *Shell, now time.Time) error {
+	path := formatHistoryTime(t.pattern, now)
+	if rest, ok := strings.CutPrefix(path, "~"); ok && (rest == "" || os.IsPathSeparator(rest[0])) {
+		path = s.homeDir() + rest
+	} else if !filepath.IsAbs(path) {
+		dir, _ := s.Getwd()
+		path = filepath.Join(dir, path)
+	}
+	if path == t.path && t.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return unwrapPathError(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return unwrapPathError(err)
+	}
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.path, t.file = path, f
+	return nil
+}
+
+// write adds lines of text to the transcript, each with the time
+func (t *transcriptLog) write(s *Shell, text string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if err := t.open(s, now); err != nil {
+		return err
+	}
+	var b strings.Builder
+	stamp := now.Format(transcriptTimeFormat)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		b.WriteString(stamp + " " + strings.TrimSuffix(stripANSI(line), "\r") + "\n")
+	}
+	_, err := io.WriteString(t.file, b.String())
+	return err
+}
+
+// close closes the file open
+func (t *transcriptLog) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// transcriptOutput passes a command's output on to w, writing each line to
+// the transcript as it is completed
+type transcriptOutput struct {
+	w     io.Writer
+	shell *Shell
+	log   *transcriptLog
+
+	mu      sync.Mutex
+	partial []byte // the line written so far
+}
+
+func (o *transcriptOutput) Write(p []byte) (int, error) {
+	o.keep(p)
+	return o.w.Write(p)
+}
+
+func (o *transcriptOutput) passTo() io.Writer {
+	return o.w
+}
+
+// keep writes the lines p completes to the transcript
+func (o *transcriptOutput) keep(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.partial = append(o.partial, p...)
+	if end := bytes.LastIndexByte(o.partial, '\n'); end >= 0 {
+		o.log.write(o.shell, string(o.partial[:end+1]))
+		o.partial = append([]byte(nil), o.partial[end+1:]...)
+	}
+}
+
+// flush writes a last line left without a newline to the transcript
+func (o *transcriptOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.partial) > 0 {
+		o.log.write(o.shell, string(o.partial))
+		o.partial = nil
+	}
+}
+
+// runLogged runs a command line typed at prompt, writing both and what the
+// commands print to the transcript when there is one, and then its exit
+// status if it failed
+func (s *Shell) runLogged(prompt, line string) int {
+	t := s.transcript
+	if t == nil {
+		return s.runKeepingOutput(line)
+	}
+	prompt = prompt[strings.LastIndex(prompt, "\n")+1:]
+	if err := t.write(s, prompt+line); err != nil {
+		fmt.Fprintln(s.stderr, "Error writing log file:", err)
+	}
+	if fullScreenCommand(line) {
+		return s.runKeepingOutput(line)
+	}
+
+	stdout := &transcriptOutput{w: s.stdout, shell: s, log: t}
+	stderr := &transcriptOutput{w: s.stderr, shell: s, log: t}
+	savedOut, savedErr := s.stdout, s.stderr
+	s.stdout, s.stderr = stdout, stderr
+	status := s.runKeepingOutput(line)
+	s.stdout, s.stderr = savedOut, savedErr
+	stdout.flush()
+	stderr.flush()
+	if status != 0 {
+		t.write(s, fmt.Sprintf("[exit status %d]", status))
+	}
+	return status
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscript(t *testing.T) {
+	home := t.TempDir()
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	var out syncBuffer
+	shell.stdout = &out
+	shell.stderr = &out
+
+	if _, status := runCapture(t, shell, "set -o logfile=~/logs/%Y%m%d.log"); status != 0 {
+		t.Fatalf("set -o logfile failed: %s", out.String())
+	}
+	shell.runLogged(Green+"goshell> "+Reset, "echo hello; printf partial")
+	shell.runLogged("multi\nline> ", "sh -c 'echo oops >&2; exit 3'")
+	shell.runLogged("> ", "less /dev/null")
+	if got, want := out.String(), "hello\npartialoops\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("output shown = %q, want %q first", got, want)
+	}
+
+	path := filepath.Join(home, "logs", time.Now().Format("20060102")+".log")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := `\d{4}-\d\d-\d\d \d\d:\d\d:\d\d `
+	want := regexp.MustCompile(`^` + stamp + `goshell> echo hello; printf partial\n` +
+		stamp + `hello\n` + stamp + `partial\n` +
+		stamp + `line> sh -c 'echo oops >&2; exit 3'\n` + stamp + `oops\n` +
+		stamp + `Error executing command: exit status 3\n` + stamp + `\[exit status 3\]\n` +
+		stamp + `> less /dev/null\n` + `(` + stamp + `.*\n)*$`)
+	if !want.Match(data) {
+		t.Errorf("transcript = %q", data)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 && os.PathSeparator == '/' {
+		t.Errorf("transcript mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if got, _ := runCapture(t, shell, "set +o"); !strings.Contains(got, "set -o logfile=~/logs/%Y%m%d.log\n") {
+		t.Errorf("set +o = %q, want the logfile restored", got)
+	}
+	runCapture(t, shell, "set +o logfile")
+	if shell.transcript != nil {
+		t.Error("set +o logfile left the transcript open")
+	}
+	if got, _ := runCapture(t, shell, "set +o"); !strings.Contains(got, "set +o logfile\n") {
+		t.Errorf("set +o = %q, want logfile off", got)
+	}
+}
+
+func TestTranscriptList(t *testing.T) {
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+	shell.env.Set("HOME", home)
+	var out syncBuffer
+	shell.stdout = &out
+	shell.stderr = &out
+
+	// Logging the output leaves ls the built-in listing
+	if _, status := runCapture(t, shell, "set -o logfile=~/goshell.log"); status != 0 {
+		t.Fatalf("set -o logfile failed: %s", out.String())
+	}
+	defer runCapture(t, shell, "set +o logfile")
+	shell.runLogged("> ", "ls "+home)
+	if got, want := stripANSI(out.String()), "📄 goshell.log  📄 notes.txt\n"; got != want {
+		t.Errorf("ls printed %q, want %q", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(home, "goshell.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stripANSI(string(data)), "📄 notes.txt") {
+		t.Errorf("transcript = %q, want the listing", data)
+	}
+}
+
+func TestSetValues(t *testing.T) {
+	shell := NewShell()
+	tests := []struct {
+		line, want string
+	}{
+		{"set -o logfile", "set: logfile takes a value: set -o logfile=VALUE\n"},
+		{"set -o vi=yes", "set: vi takes no value\n"},
+		{"set -o nope=1", "set: unknown option: nope\n"},
+	}
+	for _, tt := range tests {
+		if got, status := runCapture(t, shell, tt.line); got != tt.want || status != 1 {
+			t.Errorf("%s = %q, %d, want %q, 1", tt.line, got, status, tt.want)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0600)
+	if got, status := runCapture(t, shell, "set -o logfile="+file+"/log"); status != 1 || !strings.HasPrefix(got, "set: logfile: ") {
+		t.Errorf("set -o logfile under a file = %q, %d, want an error", got, status)
+	}
+}
@@ -0,0 +1,147 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func init() {
+	registerBuiltin("tree", "tree [-ag] [depth] [dir]", "Show a directory's contents recursively as a tree", builtinTree)
+	registerFlags("tree",
+		Candidate{"-a", "Show hidden files"},
+		Candidate{"-g", "Leave out files ignored by .gitignore"})
+}
+
+// treeOptions are the options of the tree builtin
+type treeOptions struct {
+	depth     int // how many levels below the directory to show, or 0 for all
+	all       bool
+	gitignore bool
+	color     bool // false when the output isn't a terminal
+	theme     lsTheme
+}
+
+// treeCounts totals the directories and files a tree shows
+type treeCounts struct {
+	dirs, files int
+}
+
+// builtinTree prints a directory and everything below it as a tree, with
+// the colors and icons of ls, directories before files at each level. A
+// first operand that is a number and not a directory limits the depth.
+func builtinTree(s *Shell, args []string, stdio Stdio) int {
+	var opts treeOptions
+	flags := newFlagSet("tree")
+	flags.Bool(&opts.all, "a")
+	flags.Bool(&opts.gitignore, "g")
+	operands, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(operands) > 0 {
+		depth, err := strconv.Atoi(operands[0])
+		if info, statErr := os.Stat(operands[0]); err == nil && (len(operands) == 2 || statErr != nil || !info.IsDir()) {
+			if depth < 1 {
+				fmt.Fprintf(stdio.Stderr, "tree: %s: depth must be at least 1\n", operands[0])
+				return 2
+			}
+			opts.depth = depth
+			operands = operands[1:]
+		}
+	}
+	if len(operands) > 1 {
+		return flags.usage(stdio)
+	}
+	dir := "."
+	if len(operands) == 1 {
+		dir = operands[0]
+	}
+	info, err := os.Stat(dir)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("%s: not a directory", dir)
+	}
+	if err != nil {
+		fmt.Fprintln(stdio.Stderr, "tree:", err)
+		return 1
+	}
+
+	opts.color = isTerminal(stdio.Stdout)
+	opts.theme = s.lsTheme()
+	return s.paged(stdio, func(stdio Stdio) int {
+		w := stdio.Stdout
+		fmt.Fprintln(w, opts.paint(Bold+Blue+dir+Reset))
+		var ignore *gitignore
+		if opts.gitignore {
+			ignore = readGitignore(nil, dir)
+		}
+		var counts treeCounts
+		printTree(w, dir, "", 1, ignore, opts, &counts)
+		fmt.Fprintf(w, "\n%d %s, %d %s\n", counts.dirs, plural(counts.dirs, "directory", "directories"), counts.files, plural(counts.files, "file", "files"))
+		return 0
+	})
+}
+
+// printTree prints the entries of dir, which is level levels below the top,
+// each line after prefix, and those of its subdirectories below them
+func printTree(w io.Writer, dir, prefix string, level int, ignore *gitignore, opts treeOptions, counts *treeCounts) {
+	entries, _, err := readListing(dir, lsOptions{almost: opts.all})
+	if err != nil {
+		fmt.Fprintf(w, "%s└── [%v]\n", prefix, err)
+		return
+	}
+	var shown []lsEntry
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.name)
+		isDir := entry.info != nil && entry.info.IsDir()
+		if entry.name == ".git" && opts.gitignore || ignore.ignored(path, isDir) {
+			continue
+		}
+		shown = append(shown, entry)
+	}
+
+	for i, entry := range shown {
+		branch, indent := "├── ", "│   "
+		if i == len(shown)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if entry.info == nil {
+			fmt.Fprintln(w, prefix+branch+entry.name)
+			counts.files++
+			continue
+		}
+		fmt.Fprintln(w, prefix+branch+opts.paint(opts.theme.styledName(entry.name, entry.info)))
+		if !entry.info.IsDir() {
+			counts.files++
+			continue
+		}
+		counts.dirs++
+		if opts.depth == 0 || level < opts.depth {
+			path := filepath.Join(dir, entry.name)
+			sub := ignore
+			if opts.gitignore {
+				sub = readGitignore(ignore, path)
+			}
+			printTree(w, path, prefix+indent, level+1, sub, opts, counts)
+		}
+	}
+}
+
+// paint returns text with its colors, or without them when the output
+// isn't a terminal
+func (o treeOptions) paint(text string) string {
+	if !o.color {
+		return stripANSI(text)
+	}
+	return text
+}
+
+// plural returns the singular or plural form of a noun for a count
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
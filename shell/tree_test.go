@@ -0,0 +1,59 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"src/app/main.go", "src/util.go", "README.md", "debug.log", ".env"} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	shell := NewShell()
+
+	for _, tt := range []struct {
+		args string
+		want string
+	}{
+		{"", `├── 📁 src/
+│   ├── 📁 app/
+│   │   └── 🔹 main.go
+│   └── 🔹 util.go
+├── 📄 README.md
+└── 📄 debug.log
+
+2 directories, 4 files
+`},
+		{"1", `├── 📁 src/
+├── 📄 README.md
+└── 📄 debug.log
+
+1 directory, 2 files
+`},
+		{"-ag 2", `├── 📁 src/
+│   ├── 📁 app/
+│   └── 🔹 util.go
+├── 📄 .env
+├── 📄 .gitignore
+└── 📄 README.md
+
+2 directories, 4 files
+`},
+	} {
+		out, status := runCapture(t, shell, "tree "+tt.args+" "+dir)
+		if want := dir + "\n" + tt.want; out != want || status != 0 {
+			t.Errorf("tree %s printed (status %d):\n%s\nwant:\n%s", tt.args, status, out, want)
+		}
+	}
+}
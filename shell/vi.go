@@ -0,0 +1,272 @@
+package shell
+
+import (
+	"unicode"
+
+	"goshell/internal/lineedit"
+)
+
+// viMode is the mode of vi-style editing
+type viMode int
+
+const (
+	viInsert viMode = iota
+	viNormal
+)
+
+// viIndicators are shown at the start of the prompt's last line in vi mode.
+// They have the same width so switching modes doesn't shift the line.
+var viIndicators = map[viMode]string{
+	viInsert: "[I] ",
+	viNormal: "[N] ",
+}
+
+// viState is the state of vi-style editing for the line being read
+type viState struct {
+	mode     viMode
+	operator rune   // d or c while waiting for its motion
+	register []rune // text removed by the last delete or change, for p and P
+}
+
+// viEnabled reports whether vi-style editing is on
+func (e *lineEditor) viEnabled() bool {
+	return e.shell.options["vi"]
+}
+
+// setViMode switches between insert and normal mode, updating the prompt's
+// mode indicator
+func (e *lineEditor) setViMode(mode viMode) {
+	e.vi.mode = mode
+	e.vi.operator = 0
+	e.ed.SetPrompt(e.displayPrompt())
+}
+
+// viFilter handles a key in vi mode. It returns the key to handle as
+// usual, which for some commands is another, such as Up for k; it reports
+// false for keys a vi command has dealt with.
+func (e *lineEditor) viFilter(key lineedit.Key) (lineedit.Key, bool) {
+	v := &e.vi
+	if key&lineedit.Meta != 0 && key&^lineedit.Meta < lineedit.KeyUp {
+		// Esc and a key typed quickly after it arrive as a Meta key
+		e.viFilter(lineedit.Esc)
+		key &^= lineedit.Meta
+	}
+	if key == lineedit.Esc {
+		line, pos := e.ed.Buffer()
+		if v.mode == viInsert {
+			pos--
+		}
+		e.setViMode(viNormal)
+		e.ed.SetBuffer(line, viClamp(line, pos))
+		return 0, false
+	}
+	if v.mode == viInsert || key < ' ' || key >= lineedit.KeyUp {
+		return key, true
+	}
+
+	r := rune(key)
+	if op := v.operator; op != 0 {
+		v.operator = 0
+		e.apply(func(line []rune, pos int) ([]rune, int) {
+			return e.viOperate(op, r, line, pos)
+		})
+		return 0, false
+	}
+	switch r {
+	case 'd', 'c':
+		v.operator = r
+		return 0, false
+	case 'k':
+		return lineedit.KeyUp, true
+	case 'j':
+		return lineedit.KeyDown, true
+	}
+	e.apply(func(line []rune, pos int) ([]rune, int) {
+		return e.viCommand(r, line, pos)
+	})
+	return 0, false
+}
+
+// viCommand runs a normal mode command other than an operator
+func (e *lineEditor) viCommand(r rune, line []rune, pos int) ([]rune, int) {
+	if target, _, ok := viMotion(r, line, pos); ok {
+		return line, viClamp(line, target)
+	}
+	v := &e.vi
+	insert := func(line []rune, pos int) ([]rune, int) {
+		e.setViMode(viInsert)
+		return line, pos
+	}
+	switch r {
+	case 'i':
+		return insert(line, pos)
+	case 'a':
+		return insert(line, min(pos+1, len(line)))
+	case 'I':
+		return insert(line, 0)
+	case 'A':
+		return insert(line, len(line))
+	case 'x', 's':
+		if pos < len(line) {
+			line, pos = e.viDelete(line, pos, pos+1)
+		}
+		if r == 's' {
+			return insert(line, pos)
+		}
+	case 'X':
+		if pos > 0 {
+			line, pos = e.viDelete(line, pos-1, pos)
+		}
+	case 'D', 'C':
+		line, pos = e.viDelete(line, pos, len(line))
+		if r == 'C' {
+			return insert(line, pos)
+		}
+	case 'S':
+		line, pos = e.viDelete(line, 0, len(line))
+		return insert(line, pos)
+	case 'p', 'P':
+		if len(v.register) == 0 {
+			break
+		}
+		at := pos
+		if r == 'p' && len(line) > 0 {
+			at++
+		}
+		line, pos = replaceRunes(line, at, at, string(v.register))
+		pos--
+	}
+	return line, viClamp(line, pos)
+}
+
+// viOperate applies the d or c operator over the text between the cursor
+// and the motion's target. Doubling the operator, as in dd, applies it to
+// the whole line.
+func (e *lineEditor) viOperate(op, motion rune, line []rune, pos int) ([]rune, int) {
+	from, to := 0, len(line)
+	if motion != op {
+		// cw changes to the end of the word, like ce
+		if op == 'c' && (motion == 'w' || motion == 'W') && pos < len(line) && !unicode.IsSpace(line[pos]) {
+			to = pos
+			class := viClass(line[pos], motion == 'W')
+			for to+1 < len(line) && viClass(line[to+1], motion == 'W') == class {
+				to++
+			}
+			from, to = pos, to+1
+		} else {
+			target, inclusive, ok := viMotion(motion, line, pos)
+			if !ok {
+				return line, pos
+			}
+			from, to = min(pos, target), max(pos, target)
+			if inclusive {
+				to = min(to+1, len(line))
+			}
+		}
+	}
+	line, pos = e.viDelete(line, from, to)
+	if op == 'c' {
+		e.setViMode(viInsert)
+		return line, pos
+	}
+	return line, viClamp(line, pos)
+}
+
+// viDelete removes line[from:to], keeping it in the register
+func (e *lineEditor) viDelete(line []rune, from, to int) ([]rune, int) {
+	e.vi.register = append([]rune(nil), line[from:to]...)
+	return replaceRunes(line, from, to, "")
+}
+
+// viMotion returns where a motion key moves the cursor, and whether the
+// character at the target is included when an operator uses the motion
+func viMotion(r rune, line []rune, pos int) (int, bool, bool) {
+	switch r {
+	case 'h':
+		return max(pos-1, 0), false, true
+	case 'l', ' ':
+		return min(pos+1, len(line)), false, true
+	case '0':
+		return 0, false, true
+	case '^':
+		i := 0
+		for i < len(line) && unicode.IsSpace(line[i]) {
+			i++
+		}
+		return i, false, true
+	case '$':
+		return len(line), false, true
+	case 'w', 'W':
+		return viNextWord(line, pos, r == 'W'), false, true
+	case 'b', 'B':
+		return viPrevWord(line, pos, r == 'B'), false, true
+	case 'e', 'E':
+		return viWordEnd(line, pos, r == 'E'), true, true
+	}
+	return pos, false, false
+}
+
+// viClass groups characters for word motions: blanks, word characters and
+// punctuation. Big words, as moved over by W, B and E, are any non-blanks.
+func viClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+// viNextWord returns the start of the word after the one at pos
+func viNextWord(line []rune, pos int, big bool) int {
+	i := pos
+	if i < len(line) {
+		if class := viClass(line[i], big); class != 0 {
+			for i < len(line) && viClass(line[i], big) == class {
+				i++
+			}
+		}
+	}
+	for i < len(line) && viClass(line[i], big) == 0 {
+		i++
+	}
+	return i
+}
+
+// viPrevWord returns the start of the word before pos
+func viPrevWord(line []rune, pos int, big bool) int {
+	i := pos
+	for i > 0 && viClass(line[i-1], big) == 0 {
+		i--
+	}
+	if i > 0 {
+		class := viClass(line[i-1], big)
+		for i > 0 && viClass(line[i-1], big) == class {
+			i--
+		}
+	}
+	return i
+}
+
+// viWordEnd returns the last character of the word ending after pos
+func viWordEnd(line []rune, pos int, big bool) int {
+	i := pos + 1
+	for i < len(line) && viClass(line[i], big) == 0 {
+		i++
+	}
+	if i >= len(line) {
+		return max(len(line)-1, 0)
+	}
+	class := viClass(line[i], big)
+	for i+1 < len(line) && viClass(line[i+1], big) == class {
+		i++
+	}
+	return i
+}
+
+// viClamp keeps the cursor on a character, as normal mode has no position
+// past the end of the line
+func viClamp(line []rune, pos int) int {
+	return max(min(pos, len(line)-1), 0)
+}
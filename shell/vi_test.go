@@ -0,0 +1,70 @@
+package shell
+
+import (
+	"testing"
+
+	"goshell/internal/lineedit"
+)
+
+func TestViEditing(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		want    string
+		wantPos int
+	}{
+		{"insert", "echo hi", "echo hi", 7},
+		{"escape moves back", "echo hi\x1b", "echo hi", 6},
+		{"word motions", "one two three\x1bbbx", "one wo three", 4},
+		{"line motions", "one two\x1b0x$x", "ne tw", 4},
+		{"delete word", "one two three\x1b0wdw", "one three", 4},
+		{"delete to end", "one two three\x1b0wD", "one ", 3},
+		{"change word", "one two three\x1b0wcwTWO", "one TWO three", 7},
+		{"change line", "one two\x1bccnew", "new", 3},
+		{"delete line and put", "one\x1bddatwo \x1bp", "two one", 6},
+		{"append", "one\x1b0A two", "one two", 7},
+		{"insert at start", "two\x1bIone ", "one two", 4},
+		{"end of word", "one two\x1b0dex", "two", 0},
+		{"arrow key in insert mode", "ac\x1b[Db", "abc", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := NewShell()
+			shell.setOption("vi", true)
+			editor := newTestEditor(shell)
+			editor.startLine("> ")
+			line, pos := typeKeys(editor, nil, 0, tt.keys)
+			if string(line) != tt.want || pos != tt.wantPos {
+				t.Errorf("line = %q, cursor %d, want %q, cursor %d", string(line), pos, tt.want, tt.wantPos)
+			}
+		})
+	}
+}
+
+func TestViModeIndicator(t *testing.T) {
+	shell := NewShell()
+	editor := newTestEditor(shell)
+	if got := editor.startLine("$ "); got != "$ " {
+		t.Errorf("emacs prompt = %q", got)
+	}
+
+	runCapture(t, shell, "set -o vi")
+	if got := editor.startLine("top\n$ "); got != "top\n[I] $ " {
+		t.Errorf("insert prompt = %q", got)
+	}
+	press(editor, []rune("ls"), 2, lineedit.Esc)
+	if got := editor.displayPrompt(); got != "top\n[N] $ " {
+		t.Errorf("normal prompt = %q", got)
+	}
+	if key, _ := editor.handleKey('k'); key != lineedit.KeyUp {
+		t.Errorf("k = %d, want history previous", key)
+	}
+	if got := editor.startLine("$ "); got != "[I] $ " {
+		t.Errorf("next line's prompt = %q, want insert mode", got)
+	}
+
+	runCapture(t, shell, "set +o vi")
+	if shell.options["vi"] || !shell.options["emacs"] {
+		t.Errorf("options = %v, want emacs mode", shell.options)
+	}
+}
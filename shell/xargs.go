@@ -0,0 +1,215 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+func init() {
+	registerBuiltin("xargs", "xargs [-0] [-n N] [-P N] [-I STR] [cmd...]", "Run a command with arguments read from stdin", builtinXargs)
+	registerFlags("xargs",
+		Candidate{"-0", "Items are separated by NUL, not whitespace"},
+		Candidate{"-n", "Use at most N items per command"},
+		Candidate{"-P", "Run up to N commands at once (0: no limit)"},
+		Candidate{"-I", "Run once per line, replacing STR in the arguments"})
+}
+
+// xargsReader splits xargs input into items
+type xargsReader struct {
+	r       *bufio.Reader
+	nul     bool // items are NUL-terminated
+	perLine bool // each line is one item (-I)
+}
+
+// next returns the next item, or io.EOF when the input is exhausted
+func (x *xargsReader) next() (string, error) {
+	switch {
+	case x.nul:
+		item, err := x.r.ReadString(0)
+		if err == io.EOF && item == "" {
+			return "", io.EOF
+		}
+		return strings.TrimSuffix(item, "\x00"), nil
+	case x.perLine:
+		for {
+			line, err := readLine(x.r)
+			if err != nil {
+				return "", err
+			}
+			if line = strings.TrimLeftFunc(line, unicode.IsSpace); line != "" {
+				return line, nil
+			}
+		}
+	}
+	return x.word()
+}
+
+// word reads a blank-separated item. Quotes and backslashes group
+// characters the same way they do on a command line.
+func (x *xargsReader) word() (string, error) {
+	var b strings.Builder
+	inWord := false
+	var quote rune
+	for {
+		r, _, err := x.r.ReadRune()
+		if err == io.EOF {
+			if quote != 0 {
+				return "", fmt.Errorf("unmatched %c quote", quote)
+			}
+			if inWord {
+				return b.String(), nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				b.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			next, _, err := x.r.ReadRune()
+			if err == nil {
+				b.WriteRune(next)
+			}
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				return b.String(), nil
+			}
+		default:
+			b.WriteRune(r)
+			inWord = true
+		}
+	}
+}
+
+// builtinXargs reads items from stdin and runs the command with them
+// appended, -n at a time, or once per line with -I substituting them into
+// the arguments. Up to -P external commands run concurrently; builtins,
+// which may change the shell's state, run one at a time. The command is echo
+// if none is given, and nothing runs when the input is empty. The status is
+// 123 if any command failed.
+func builtinXargs(s *Shell, args []string, stdio Stdio) int {
+	maxArgs, parallel := 0, 1
+	nul := false
+	replace := ""
+	// number parses the value of -n or -P, which must be at least min
+	number := func(p *int, flag string, min int) func(string) error {
+		return func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < min {
+				return fmt.Errorf("invalid number for -%s: %s", flag, value)
+			}
+			*p = n
+			return nil
+		}
+	}
+	flags := newFlagSet("xargs")
+	flags.Bool(&nul, "0")
+	flags.Func(number(&maxArgs, "n", 1), "n")
+	flags.Func(number(&parallel, "P", 0), "P")
+	flags.String(&replace, "I")
+	flags.stopAtOperand = true
+	command, err := flags.Parse(args[1:])
+	if err != nil {
+		return flags.fail(stdio, err)
+	}
+	if len(command) == 0 {
+		command = []string{"echo"}
+	}
+	if replace != "" {
+		maxArgs = 1
+	}
+
+	input := &xargsReader{r: bufio.NewReader(stdio.Stdin), nul: nul, perLine: replace != "" && !nul}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+	var slots chan struct{}
+	if parallel > 0 {
+		slots = make(chan struct{}, parallel)
+	}
+	record := func(status int) {
+		if status != 0 {
+			mu.Lock()
+			failed = true
+			mu.Unlock()
+		}
+	}
+	run := func(items []string) {
+		argv := xargsCommand(command, items, replace)
+		// Commands don't share xargs' input
+		cmdStdio := Stdio{Stdin: strings.NewReader(""), Stdout: stdio.Stdout, Stderr: stdio.Stderr}
+		if _, ok := s.lookupBuiltin(argv[0]); ok {
+			wg.Wait()
+			record(s.runArgs(argv, cmdStdio))
+			return
+		}
+		if slots != nil {
+			slots <- struct{}{}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := s.runArgs(argv, cmdStdio)
+			if slots != nil {
+				<-slots
+			}
+			record(status)
+		}()
+	}
+
+	var batch []string
+	status := 0
+	for {
+		item, err := input.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stdio.Stderr, "xargs:", err)
+			status = 1
+			break
+		}
+		batch = append(batch, item)
+		if maxArgs > 0 && len(batch) == maxArgs {
+			run(batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		run(batch)
+	}
+	wg.Wait()
+	if failed && status == 0 {
+		status = 123
+	}
+	return status
+}
+
+// xargsCommand builds the argument list for one invocation: items replace
+// each occurrence of replace in the arguments, or are appended if replace is
+// empty
+func xargsCommand(command, items []string, replace string) []string {
+	if replace == "" {
+		return append(append([]string(nil), command...), items...)
+	}
+	argv := make([]string, len(command))
+	for i, arg := range command {
+		argv[i] = strings.ReplaceAll(arg, replace, items[0])
+	}
+	return argv
+}
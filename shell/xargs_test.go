@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestXargs(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		want  string
+	}{
+		{"default echo", []string{"xargs"}, "a b\nc\n", "a b c\n"},
+		{"batches", []string{"xargs", "-n", "2", "echo"}, "1 2 3 4 5", "1 2\n3 4\n5\n"},
+		{"quoted items", []string{"xargs", "-n1"}, `"a b" 'c d' e\ f`, "a b\nc d\ne f\n"},
+		{"nul separated", []string{"xargs", "-0", "-n", "1"}, "x y\x00z\x00", "x y\nz\n"},
+		{"placeholder", []string{"xargs", "-I", "{}", "echo", "<{}>"}, "one\n  two three\n\n", "<one>\n<two three>\n"},
+		{"no expansion", []string{"xargs"}, "'$HOME' '*'", "$HOME *\n"},
+		{"empty input", []string{"xargs", "echo", "ran"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := runBuiltin(t, tt.args, tt.input)
+			if status != 0 || got != tt.want {
+				t.Errorf("%v = %q (status %d), want %q", tt.args, got, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestXargsParallel(t *testing.T) {
+	// Commands write concurrently, so capture through a syncBuffer
+	var out syncBuffer
+	status := builtinXargs(NewShell(), []string{"xargs", "-P", "4", "-n", "1", "echo"},
+		Stdio{Stdin: strings.NewReader("a b c d e f"), Stdout: &out, Stderr: &out})
+	got := out.String()
+	lines := strings.Fields(got)
+	sort.Strings(lines)
+	if status != 0 || strings.Join(lines, " ") != "a b c d e f" {
+		t.Errorf("xargs -P 4 = %q (status %d)", got, status)
+	}
+
+	// Builtins run one at a time, in order, as they change the shell's state
+	shell := NewShell()
+	var exported syncBuffer
+	status = builtinXargs(shell, []string{"xargs", "-P", "4", "-n", "1", "export"},
+		Stdio{Stdin: strings.NewReader("A=1 B=2 C=3 A=4"), Stdout: &exported, Stderr: &exported})
+	if status != 0 || shell.env.Get("A") != "4" || shell.env.Get("C") != "3" {
+		t.Errorf("xargs -P 4 export = %q (status %d), A=%q C=%q", exported.String(), status, shell.env.Get("A"), shell.env.Get("C"))
+	}
+
+	if _, status := runBuiltin(t, []string{"xargs", "-n", "1", "sh", "-c", `test "$0" != b`}, "a b c"); status != 123 {
+		t.Errorf("xargs with a failing command = %d, want 123", status)
+	}
+}